@@ -2,11 +2,16 @@ package main
 
 import (
 	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/authzacl"
+	"xorkevin.dev/governor/service/authzacl/aclmodel"
 	"xorkevin.dev/governor/service/conduit"
+	"xorkevin.dev/governor/service/conduit/blockmodel"
+	"xorkevin.dev/governor/service/conduit/botmodel"
 	"xorkevin.dev/governor/service/conduit/dmmodel"
 	"xorkevin.dev/governor/service/conduit/friendinvmodel"
 	"xorkevin.dev/governor/service/conduit/friendmodel"
 	"xorkevin.dev/governor/service/conduit/gdmmodel"
+	"xorkevin.dev/governor/service/conduit/modmodel"
 	"xorkevin.dev/governor/service/conduit/msgmodel"
 	"xorkevin.dev/governor/service/conduit/servermodel"
 	"xorkevin.dev/governor/service/courier"
@@ -18,6 +23,9 @@ import (
 	"xorkevin.dev/governor/service/gate/apikey/apikeymodel"
 	"xorkevin.dev/governor/service/kvstore"
 	"xorkevin.dev/governor/service/mail"
+	"xorkevin.dev/governor/service/mail/deliverymodel"
+	"xorkevin.dev/governor/service/mail/suppressionmodel"
+	"xorkevin.dev/governor/service/mail/verpmodel"
 	"xorkevin.dev/governor/service/mailinglist"
 	"xorkevin.dev/governor/service/mailinglist/mailinglistmodel"
 	"xorkevin.dev/governor/service/objstore"
@@ -73,10 +81,10 @@ func main() {
 	gov.Register("events", "/null/events", ev)
 	tpl := template.New()
 	gov.Register("template", "/null/tpl", tpl)
-	ml := mail.New(tpl, ev, obj.GetBucket("mail"))
-	gov.Register("mail", "/null/mail", ml)
 	ratelim := ratelimit.New(kv.Subtree("ratelimit"))
 	gov.Register("ratelimit", "/null/ratelimit", ratelim)
+	acl := authzacl.New(aclmodel.New(d, "authzacl"), ev)
+	gov.Register("authzacl", "/null/authzacl", acl)
 	rolesvc := role.New(rolemodel.New(d, "userroles"), kv.Subtree("roles"), ev)
 	gov.Register("role", "/null/role", rolesvc)
 	apikeysvc := apikey.New(apikeymodel.New(d, "apikeys"))
@@ -85,6 +93,8 @@ func main() {
 	gov.Register("token", "/null/token", tokensvc)
 	g := gate.New(rolesvc, apikeysvc, tokensvc)
 	gov.Register("gate", "/null/gate", g)
+	ml := mail.New(suppressionmodel.New(d, "mailsuppressions"), deliverymodel.New(d, "maildeliveries"), verpmodel.New(d, "mailverp"), tpl, ev, obj.GetBucket("mail"), ratelim.Subtree("mail"), g)
+	gov.Register("mail", "/mail", ml)
 	gov.Register("eventsapi", "/eventsapi", eventsapi.New(ps, g))
 	wssvc := ws.New(ps, ratelim.Subtree("ws"), g)
 	gov.Register("ws", "/ws", wssvc)
@@ -128,6 +138,7 @@ func main() {
 		profilemodel.New(d, "profiles"),
 		obj.GetBucket("profile-image"),
 		usersvc,
+		ev,
 		ratelim.Subtree("profile"),
 		g,
 	))
@@ -142,20 +153,27 @@ func main() {
 	gov.Register("conduit", "/conduit", conduit.New(
 		friendmodel.New(d, "friends"),
 		friendinvmodel.New(d, "friendinvitations"),
+		blockmodel.New(d, "userblocks"),
 		dmmodel.New(d, "dms"),
 		gdmmodel.New(d, "gdms", "gdmmembers", "gdmassocs"),
-		servermodel.New(d, "servers", "serverchannels", "serverpresence"),
-		msgmodel.New(d, "chatmsgs"),
+		servermodel.New(d, "servers", "serverchannels", "serverpresence", "servermembers", "serverinvites", "serverbans", "serverroles"),
+		msgmodel.New(d, "chatmsgs", "chatmsgsearch", "chatmsgedits", "chatmsgreactions", "chatmsgreads", "chatmsgretention"),
+		modmodel.New(d, "chatreports", "chatmutes", "chatmodaudit"),
+		botmodel.New(d, "chatbots", "chatbotchannels", "chatwebhooks"),
+		obj.GetBucket("conduit-attachment"),
 		kv.Subtree("conduit"),
 		usersvc,
+		rolesvc,
+		apikeysvc,
 		ps,
 		ev,
 		wssvc,
 		ratelim.Subtree("conduit"),
+		acl,
 		g,
 	))
 	gov.Register("mailinglist", "/mailinglist", mailinglist.New(
-		mailinglistmodel.New(d, "mailinglists", "mailinglistmembers", "mailinglistmsgs", "mailinglistsentmsgs", "mailinglisttree"),
+		mailinglistmodel.New(d, "mailinglists", "mailinglistmembers", "mailinglistmsgs", "mailinglistsentmsgs", "mailinglisttree", "mailinglistdigests", "mailinglistpending", "mailinglistsenders", "mailinglistcmds", "mailinglistsearch"),
 		obj.GetBucket("mailinglist"),
 		ev,
 		usersvc,
@@ -183,6 +201,16 @@ func main() {
 		Short: "manage users",
 		Long:  "manage users",
 	}, user.NewCmdClient(gateclient))
+	client.Register("mailinglist", "/mailinglist", &governor.CmdDesc{
+		Usage: "mailinglist",
+		Short: "manage mailing lists",
+		Long:  "manage mailing lists",
+	}, mailinglist.NewCmdClient(gateclient))
+	client.Register("conduit", "/conduit", &governor.CmdDesc{
+		Usage: "conduit",
+		Short: "manage conduit",
+		Long:  "manage conduit",
+	}, conduit.NewCmdClient(gateclient))
 
 	cmd := governor.NewCmd(opts, nil, gov, client)
 	cmd.Execute()
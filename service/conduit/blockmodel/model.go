@@ -0,0 +1,163 @@
+package blockmodel
+
+import (
+	"context"
+
+	"xorkevin.dev/governor/service/dbsql"
+	"xorkevin.dev/kerrors"
+)
+
+//go:generate forge model
+
+type (
+	// Repo is a user block list repository
+	Repo interface {
+		GetByID(ctx context.Context, userid, blocked string) (*Model, error)
+		GetBlocked(ctx context.Context, userid string, limit, offset int) ([]Model, error)
+		GetBlockedByID(ctx context.Context, userid string, blocked []string) ([]Model, error)
+		GetBlockersByID(ctx context.Context, blocked string, userids []string) ([]Model, error)
+		Insert(ctx context.Context, userid, blocked string, at int64) error
+		Remove(ctx context.Context, userid, blocked string) error
+		DeleteByUser(ctx context.Context, userid string) error
+		Setup(ctx context.Context) error
+	}
+
+	repo struct {
+		table *blockModelTable
+		db    dbsql.Database
+	}
+
+	// Model is the db user block model
+	//forge:model block
+	//forge:model:query block
+	Model struct {
+		Userid       string `model:"userid,VARCHAR(31)"`
+		Blocked      string `model:"blocked,VARCHAR(31)"`
+		CreationTime int64  `model:"creation_time,BIGINT NOT NULL"`
+	}
+)
+
+// New creates a new user block list repo
+func New(database dbsql.Database, table string) Repo {
+	return &repo{
+		table: &blockModelTable{
+			TableName: table,
+		},
+		db: database,
+	}
+}
+
+// GetByID returns a block of a user by a user
+func (r *repo) GetByID(ctx context.Context, userid, blocked string) (*Model, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.table.GetModelByUserBlocked(ctx, d, userid, blocked)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get block")
+	}
+	return m, nil
+}
+
+// GetBlocked returns the users blocked by a user
+func (r *repo) GetBlocked(ctx context.Context, userid string, limit, offset int) ([]Model, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.table.GetModelByUser(ctx, d, userid, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get blocked users")
+	}
+	return m, nil
+}
+
+// GetBlockedByID returns which of the users are blocked by a user
+func (r *repo) GetBlockedByID(ctx context.Context, userid string, blocked []string) ([]Model, error) {
+	if len(blocked) == 0 {
+		return nil, nil
+	}
+
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.table.GetModelByUserBlockeds(ctx, d, userid, blocked, len(blocked), 0)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get blocked users")
+	}
+	return m, nil
+}
+
+// GetBlockersByID returns which of the users have blocked a user
+func (r *repo) GetBlockersByID(ctx context.Context, blocked string, userids []string) ([]Model, error) {
+	if len(userids) == 0 {
+		return nil, nil
+	}
+
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.table.GetModelByBlockedUsers(ctx, d, blocked, userids, len(userids), 0)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get blocking users")
+	}
+	return m, nil
+}
+
+// Insert inserts a block into the db
+func (r *repo) Insert(ctx context.Context, userid, blocked string, at int64) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.table.Insert(ctx, d, &Model{
+		Userid:       userid,
+		Blocked:      blocked,
+		CreationTime: at,
+	}); err != nil {
+		return kerrors.WithMsg(err, "Failed to insert block")
+	}
+	return nil
+}
+
+// Remove removes a block
+func (r *repo) Remove(ctx context.Context, userid, blocked string) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.table.DelByUserBlocked(ctx, d, userid, blocked); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete block")
+	}
+	return nil
+}
+
+// DeleteByUser deletes all blocks by and of a user
+func (r *repo) DeleteByUser(ctx context.Context, userid string) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.table.DelByUser(ctx, d, userid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete user blocks")
+	}
+	if err := r.table.DelByBlocked(ctx, d, userid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete user blocks")
+	}
+	return nil
+}
+
+// Setup creates a new user block table
+func (r *repo) Setup(ctx context.Context) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.table.Setup(ctx, d); err != nil {
+		return kerrors.WithMsg(err, "Failed to setup user block model")
+	}
+	return nil
+}
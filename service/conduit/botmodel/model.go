@@ -0,0 +1,409 @@
+package botmodel
+
+import (
+	"context"
+	"time"
+
+	"xorkevin.dev/governor/service/dbsql"
+	"xorkevin.dev/governor/util/uid"
+	"xorkevin.dev/hunter2/h2hash"
+	"xorkevin.dev/hunter2/h2hash/blake2b"
+	"xorkevin.dev/kerrors"
+)
+
+//go:generate forge model
+
+type (
+	// Repo is a conduit bot and webhook repository
+	Repo interface {
+		New(name, avatar string, creatorid string) (*Model, error)
+		GetBot(ctx context.Context, botid string) (*Model, error)
+		GetCreatorBots(ctx context.Context, creatorid string, limit, offset int) ([]Model, error)
+		Insert(ctx context.Context, m *Model) error
+		UpdateProps(ctx context.Context, m *Model) error
+		Delete(ctx context.Context, botid string) error
+		NewChannel(botid, serverid, channelid string) *ChannelModel
+		GetChannel(ctx context.Context, botid, serverid, channelid string) (*ChannelModel, error)
+		GetBotChannels(ctx context.Context, botid string, limit, offset int) ([]ChannelModel, error)
+		GetChannelBots(ctx context.Context, serverid, channelid string, limit, offset int) ([]ChannelModel, error)
+		InsertChannel(ctx context.Context, m *ChannelModel) error
+		DeleteChannel(ctx context.Context, botid, serverid, channelid string) error
+		NewWebhook(serverid, channelid string, name, avatar string, creatorid string) (*WebhookModel, string, error)
+		ValidateWebhookKey(key string, m *WebhookModel) (bool, error)
+		GetWebhook(ctx context.Context, webhookid string) (*WebhookModel, error)
+		GetChannelWebhooks(ctx context.Context, serverid, channelid string, limit, offset int) ([]WebhookModel, error)
+		InsertWebhook(ctx context.Context, m *WebhookModel) error
+		UpdateWebhookProps(ctx context.Context, m *WebhookModel) error
+		DeleteWebhook(ctx context.Context, webhookid string) error
+		DeleteServerChannel(ctx context.Context, serverid, channelid string) error
+		Setup(ctx context.Context) error
+	}
+
+	repo struct {
+		table         *botModelTable
+		tableChannels *channelModelTable
+		tableWebhooks *webhookModelTable
+		db            dbsql.Database
+		hasher        h2hash.Hasher
+		verifier      *h2hash.Verifier
+	}
+
+	// Model is the db bot account model
+	//forge:model bot
+	//forge:model:query bot
+	Model struct {
+		Botid        string `model:"botid,VARCHAR(31) PRIMARY KEY"`
+		Name         string `model:"name,VARCHAR(127) NOT NULL"`
+		Avatar       string `model:"avatar,VARCHAR(4095) NOT NULL"`
+		Creatorid    string `model:"creatorid,VARCHAR(31) NOT NULL"`
+		CreationTime int64  `model:"creation_time,BIGINT NOT NULL"`
+	}
+
+	//forge:model:query bot
+	botProps struct {
+		Name   string `model:"name"`
+		Avatar string `model:"avatar"`
+	}
+
+	// ChannelModel is the db server channel bot model
+	//forge:model channel
+	//forge:model:query channel
+	ChannelModel struct {
+		Botid        string `model:"botid,VARCHAR(31)"`
+		ServerID     string `model:"serverid,VARCHAR(31)"`
+		ChannelID    string `model:"channelid,VARCHAR(31)"`
+		CreationTime int64  `model:"creation_time,BIGINT NOT NULL"`
+	}
+
+	// WebhookModel is the db server channel incoming webhook model
+	//forge:model webhook
+	//forge:model:query webhook
+	WebhookModel struct {
+		Webhookid    string `model:"webhookid,VARCHAR(31) PRIMARY KEY"`
+		ServerID     string `model:"serverid,VARCHAR(31) NOT NULL"`
+		ChannelID    string `model:"channelid,VARCHAR(31) NOT NULL"`
+		Name         string `model:"name,VARCHAR(127) NOT NULL"`
+		Avatar       string `model:"avatar,VARCHAR(4095) NOT NULL"`
+		KeyHash      string `model:"keyhash,VARCHAR(127) NOT NULL"`
+		Creatorid    string `model:"creatorid,VARCHAR(31) NOT NULL"`
+		CreationTime int64  `model:"creation_time,BIGINT NOT NULL"`
+	}
+
+	//forge:model:query webhook
+	webhookProps struct {
+		Name   string `model:"name"`
+		Avatar string `model:"avatar"`
+	}
+)
+
+// New creates a new bot and webhook repo
+func New(database dbsql.Database, table, tableChannels, tableWebhooks string) Repo {
+	hasher := blake2b.New(blake2b.Config{})
+	verifier := h2hash.NewVerifier()
+	verifier.Register(hasher)
+
+	return &repo{
+		table: &botModelTable{
+			TableName: table,
+		},
+		tableChannels: &channelModelTable{
+			TableName: tableChannels,
+		},
+		tableWebhooks: &webhookModelTable{
+			TableName: tableWebhooks,
+		},
+		db:       database,
+		hasher:   hasher,
+		verifier: verifier,
+	}
+}
+
+// New creates a new bot account
+func (r *repo) New(name, avatar string, creatorid string) (*Model, error) {
+	u, err := uid.New()
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to create new uid")
+	}
+	return &Model{
+		Botid:        u.Base64(),
+		Name:         name,
+		Avatar:       avatar,
+		Creatorid:    creatorid,
+		CreationTime: time.Now().Round(0).Unix(),
+	}, nil
+}
+
+// GetBot returns a bot by id
+func (r *repo) GetBot(ctx context.Context, botid string) (*Model, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.table.GetModelByID(ctx, d, botid)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get bot")
+	}
+	return m, nil
+}
+
+// GetCreatorBots returns the bots created by a user
+func (r *repo) GetCreatorBots(ctx context.Context, creatorid string, limit, offset int) ([]Model, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.table.GetModelByCreator(ctx, d, creatorid, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get bots")
+	}
+	return m, nil
+}
+
+// Insert inserts a new bot into the db
+func (r *repo) Insert(ctx context.Context, m *Model) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.table.Insert(ctx, d, m); err != nil {
+		return kerrors.WithMsg(err, "Failed to insert bot")
+	}
+	return nil
+}
+
+// UpdateProps updates bot props
+func (r *repo) UpdateProps(ctx context.Context, m *Model) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.table.UpdbotPropsByID(ctx, d, &botProps{
+		Name:   m.Name,
+		Avatar: m.Avatar,
+	}, m.Botid); err != nil {
+		return kerrors.WithMsg(err, "Failed to update bot")
+	}
+	return nil
+}
+
+// Delete deletes a bot and removes it from all channels
+func (r *repo) Delete(ctx context.Context, botid string) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tableChannels.DelByBot(ctx, d, botid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete bot channels")
+	}
+	if err := r.table.DelByID(ctx, d, botid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete bot")
+	}
+	return nil
+}
+
+// NewChannel creates a new channel bot
+func (r *repo) NewChannel(botid, serverid, channelid string) *ChannelModel {
+	return &ChannelModel{
+		Botid:        botid,
+		ServerID:     serverid,
+		ChannelID:    channelid,
+		CreationTime: time.Now().Round(0).Unix(),
+	}
+}
+
+// GetChannel returns a channel bot
+func (r *repo) GetChannel(ctx context.Context, botid, serverid, channelid string) (*ChannelModel, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.tableChannels.GetChannelModelByBotServerChannel(ctx, d, botid, serverid, channelid)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get channel bot")
+	}
+	return m, nil
+}
+
+// GetBotChannels returns the channels a bot was added to
+func (r *repo) GetBotChannels(ctx context.Context, botid string, limit, offset int) ([]ChannelModel, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.tableChannels.GetChannelModelByBot(ctx, d, botid, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get bot channels")
+	}
+	return m, nil
+}
+
+// GetChannelBots returns the bots of a channel
+func (r *repo) GetChannelBots(ctx context.Context, serverid, channelid string, limit, offset int) ([]ChannelModel, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.tableChannels.GetChannelModelByServerChannel(ctx, d, serverid, channelid, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get channel bots")
+	}
+	return m, nil
+}
+
+// InsertChannel adds a bot to a channel
+func (r *repo) InsertChannel(ctx context.Context, m *ChannelModel) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tableChannels.Insert(ctx, d, m); err != nil {
+		return kerrors.WithMsg(err, "Failed to insert channel bot")
+	}
+	return nil
+}
+
+// DeleteChannel removes a bot from a channel
+func (r *repo) DeleteChannel(ctx context.Context, botid, serverid, channelid string) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tableChannels.DelByBotServerChannel(ctx, d, botid, serverid, channelid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete channel bot")
+	}
+	return nil
+}
+
+// NewWebhook creates a new incoming webhook and returns its secret key
+func (r *repo) NewWebhook(serverid, channelid string, name, avatar string, creatorid string) (*WebhookModel, string, error) {
+	u, err := uid.New()
+	if err != nil {
+		return nil, "", kerrors.WithMsg(err, "Failed to create new webhook id")
+	}
+	keybytes, err := uid.NewKey()
+	if err != nil {
+		return nil, "", kerrors.WithMsg(err, "Failed to create new webhook key")
+	}
+	key := keybytes.Base64()
+	hash, err := r.hasher.Hash([]byte(key))
+	if err != nil {
+		return nil, "", kerrors.WithMsg(err, "Failed to hash webhook key")
+	}
+	return &WebhookModel{
+		Webhookid:    u.Base64(),
+		ServerID:     serverid,
+		ChannelID:    channelid,
+		Name:         name,
+		Avatar:       avatar,
+		KeyHash:      hash,
+		Creatorid:    creatorid,
+		CreationTime: time.Now().Round(0).Unix(),
+	}, key, nil
+}
+
+// ValidateWebhookKey validates the secret key of a webhook
+func (r *repo) ValidateWebhookKey(key string, m *WebhookModel) (bool, error) {
+	ok, err := r.verifier.Verify([]byte(key), m.KeyHash)
+	if err != nil {
+		return false, kerrors.WithMsg(err, "Failed to verify key")
+	}
+	return ok, nil
+}
+
+// GetWebhook returns a webhook by id
+func (r *repo) GetWebhook(ctx context.Context, webhookid string) (*WebhookModel, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.tableWebhooks.GetWebhookModelByID(ctx, d, webhookid)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get webhook")
+	}
+	return m, nil
+}
+
+// GetChannelWebhooks returns the webhooks of a channel
+func (r *repo) GetChannelWebhooks(ctx context.Context, serverid, channelid string, limit, offset int) ([]WebhookModel, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.tableWebhooks.GetWebhookModelByServerChannel(ctx, d, serverid, channelid, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get channel webhooks")
+	}
+	return m, nil
+}
+
+// InsertWebhook inserts a new webhook into the db
+func (r *repo) InsertWebhook(ctx context.Context, m *WebhookModel) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tableWebhooks.Insert(ctx, d, m); err != nil {
+		return kerrors.WithMsg(err, "Failed to insert webhook")
+	}
+	return nil
+}
+
+// UpdateWebhookProps updates webhook props
+func (r *repo) UpdateWebhookProps(ctx context.Context, m *WebhookModel) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tableWebhooks.UpdwebhookPropsByID(ctx, d, &webhookProps{
+		Name:   m.Name,
+		Avatar: m.Avatar,
+	}, m.Webhookid); err != nil {
+		return kerrors.WithMsg(err, "Failed to update webhook")
+	}
+	return nil
+}
+
+// DeleteWebhook deletes a webhook
+func (r *repo) DeleteWebhook(ctx context.Context, webhookid string) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tableWebhooks.DelByID(ctx, d, webhookid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete webhook")
+	}
+	return nil
+}
+
+// DeleteServerChannel deletes the bots and webhooks of a channel
+func (r *repo) DeleteServerChannel(ctx context.Context, serverid, channelid string) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tableChannels.DelByServerChannel(ctx, d, serverid, channelid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete channel bots")
+	}
+	if err := r.tableWebhooks.DelByServerChannel(ctx, d, serverid, channelid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete channel webhooks")
+	}
+	return nil
+}
+
+// Setup creates new bot, channel bot, and webhook tables
+func (r *repo) Setup(ctx context.Context) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.table.Setup(ctx, d); err != nil {
+		return kerrors.WithMsg(err, "Failed to setup bot model")
+	}
+	if err := r.tableChannels.Setup(ctx, d); err != nil {
+		return kerrors.WithMsg(err, "Failed to setup channel bot model")
+	}
+	if err := r.tableWebhooks.Setup(ctx, d); err != nil {
+		return kerrors.WithMsg(err, "Failed to setup webhook model")
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package conduit
+
+import (
+	"context"
+	"net/http"
+
+	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/user/gate"
+	"xorkevin.dev/kerrors"
+	"xorkevin.dev/klog"
+)
+
+type (
+	// CmdClient is a conduit cmd client
+	CmdClient struct {
+		gate  gate.Client
+		log   *klog.LevelLogger
+		httpc *governor.HTTPFetcher
+	}
+)
+
+func NewCmdClient(g gate.Client) *CmdClient {
+	return &CmdClient{
+		gate: g,
+	}
+}
+
+func (c *CmdClient) Register(r governor.ConfigRegistrar, cr governor.CmdRegistrar) {
+	cr.Register(governor.CmdDesc{
+		Usage: "backfill-members",
+		Short: "backfills server members",
+		Long:  "adds the org members of servers created before server membership was tracked, skipping servers that already have members",
+	}, governor.CmdHandlerFunc(c.backfillServerMembers))
+}
+
+func (c *CmdClient) Init(r governor.ClientConfigReader, kit governor.ClientKit) error {
+	c.log = klog.NewLevelLogger(kit.Logger)
+	c.httpc = governor.NewHTTPFetcher(kit.HTTPClient)
+	return nil
+}
+
+func (c *CmdClient) backfillServerMembers(args []string) error {
+	r, err := c.httpc.HTTPClient.Req(http.MethodPost, "/server/backfill/member", nil)
+	if err != nil {
+		return kerrors.WithMsg(err, "Failed to create backfill server members request")
+	}
+	if err := c.gate.AddSysToken(r); err != nil {
+		return kerrors.WithMsg(err, "Failed to add systoken")
+	}
+	var res resBackfillServerMembers
+	if _, err := c.httpc.DoJSON(context.Background(), r, &res); err != nil {
+		return kerrors.WithMsg(err, "Failed backfilling server members")
+	}
+	c.log.Info(context.Background(), "Backfilled server members",
+		klog.AInt("backfilled", res.Backfilled),
+		klog.AInt("skipped", res.Skipped),
+	)
+	return nil
+}
@@ -3,23 +3,32 @@ package conduit
 import (
 	"context"
 	"encoding/json"
+	"strings"
 	"time"
 
 	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/authzacl"
+	"xorkevin.dev/governor/service/conduit/blockmodel"
+	"xorkevin.dev/governor/service/conduit/botmodel"
 	"xorkevin.dev/governor/service/conduit/dmmodel"
 	"xorkevin.dev/governor/service/conduit/friendinvmodel"
 	"xorkevin.dev/governor/service/conduit/friendmodel"
 	"xorkevin.dev/governor/service/conduit/gdmmodel"
+	"xorkevin.dev/governor/service/conduit/modmodel"
 	"xorkevin.dev/governor/service/conduit/msgmodel"
 	"xorkevin.dev/governor/service/conduit/servermodel"
 	"xorkevin.dev/governor/service/events"
 	"xorkevin.dev/governor/service/events/sysevent"
+	"xorkevin.dev/governor/service/gate/apikey"
 	"xorkevin.dev/governor/service/kvstore"
+	"xorkevin.dev/governor/service/objstore"
 	"xorkevin.dev/governor/service/pubsub"
 	"xorkevin.dev/governor/service/ratelimit"
 	"xorkevin.dev/governor/service/user"
 	"xorkevin.dev/governor/service/user/gate"
+	"xorkevin.dev/governor/service/user/role"
 	"xorkevin.dev/governor/service/ws"
+	"xorkevin.dev/governor/util/bytefmt"
 	"xorkevin.dev/governor/util/kjson"
 	"xorkevin.dev/governor/util/ksync"
 	"xorkevin.dev/kerrors"
@@ -29,6 +38,7 @@ import (
 const (
 	conduitEventKindFriend   = "friend"
 	conduitEventKindUnfriend = "unfriend"
+	conduitEventKindMsgIndex = "msgindex"
 )
 
 type (
@@ -46,6 +56,7 @@ type (
 		Kind     string
 		Friend   friendProps
 		Unfriend unfriendProps
+		MsgIndex msgIndexProps
 	}
 
 	friendProps struct {
@@ -58,22 +69,36 @@ type (
 		Other  string `json:"other"`
 	}
 
+	msgIndexProps struct {
+		Chatid string `json:"chatid"`
+		Msgid  string `json:"msgid"`
+	}
+
 	// Conduit is a service for messaging
 	Conduit interface{}
 
 	Service struct {
 		friends            friendmodel.Repo
 		invitations        friendinvmodel.Repo
+		blocks             blockmodel.Repo
 		dms                dmmodel.Repo
 		gdms               gdmmodel.Repo
 		servers            servermodel.Repo
 		msgs               msgmodel.Repo
+		mods               modmodel.Repo
+		bots               botmodel.Repo
+		attachBucket       objstore.Bucket
+		attachDir          objstore.Dir
 		kvpresence         kvstore.KVStore
+		kvtyping           kvstore.KVStore
 		users              user.Users
+		roles              role.RolesManager
+		apikeys            apikey.Apikeys
 		pubsub             pubsub.Pubsub
 		events             events.Events
 		ws                 ws.WS
 		ratelimiter        ratelimit.Ratelimiter
+		acl                authzacl.Manager
 		gate               gate.Gate
 		config             governor.ConfigReader
 		log                *klog.LevelLogger
@@ -87,6 +112,11 @@ type (
 		eventsize          int32
 		invitationDuration time.Duration
 		gcDuration         time.Duration
+		attachMaxSize      int64
+		attachMediaTypes   map[string]struct{}
+		typing             typingConfig
+		retention          retentionConfig
+		webhookLimit       ratelimit.Params
 		wg                 *ksync.WaitGroup
 	}
 
@@ -96,11 +126,15 @@ type (
 	}
 
 	svcOpts struct {
-		PresenceQueryChannel string
-		DMMsgChannel         string
-		DMSettingsChannel    string
-		GDMMsgChannel        string
-		GDMSettingsChannel   string
+		PresenceQueryChannel       string
+		ServerPresenceQueryChannel string
+		DMMsgChannel               string
+		DMSettingsChannel          string
+		GDMMsgChannel              string
+		GDMSettingsChannel         string
+		ChannelMsgChannel          string
+		ServerMemberChannel        string
+		TypingChannel              string
 	}
 )
 
@@ -108,33 +142,49 @@ type (
 func New(
 	friends friendmodel.Repo,
 	invitations friendinvmodel.Repo,
+	blocks blockmodel.Repo,
 	dms dmmodel.Repo,
 	gdms gdmmodel.Repo,
 	servers servermodel.Repo,
 	msgs msgmodel.Repo,
+	mods modmodel.Repo,
+	bots botmodel.Repo,
+	obj objstore.Bucket,
 	kv kvstore.KVStore,
 	users user.Users,
+	roles role.RolesManager,
+	apikeys apikey.Apikeys,
 	ps pubsub.Pubsub,
 	ev events.Events,
 	wss ws.WS,
 	ratelimiter ratelimit.Ratelimiter,
+	acl authzacl.Manager,
 	g gate.Gate,
 ) *Service {
 	return &Service{
-		friends:     friends,
-		invitations: invitations,
-		dms:         dms,
-		gdms:        gdms,
-		servers:     servers,
-		msgs:        msgs,
-		kvpresence:  kv.Subtree("presence"),
-		users:       users,
-		pubsub:      ps,
-		events:      ev,
-		ws:          wss,
-		ratelimiter: ratelimiter,
-		gate:        g,
-		wg:          ksync.NewWaitGroup(),
+		friends:      friends,
+		invitations:  invitations,
+		blocks:       blocks,
+		dms:          dms,
+		gdms:         gdms,
+		servers:      servers,
+		msgs:         msgs,
+		mods:         mods,
+		bots:         bots,
+		attachBucket: obj,
+		attachDir:    obj.Subdir("attachment"),
+		kvpresence:   kv.Subtree("presence"),
+		kvtyping:     kv.Subtree("typing"),
+		users:        users,
+		roles:        roles,
+		apikeys:      apikeys,
+		pubsub:       ps,
+		events:       ev,
+		ws:           wss,
+		ratelimiter:  ratelimiter,
+		acl:          acl,
+		gate:         g,
+		wg:           ksync.NewWaitGroup(),
 	}
 }
 
@@ -144,17 +194,35 @@ func (s *Service) Register(r governor.ConfigRegistrar) {
 	s.streamns = r.Name()
 	s.streamconduit = r.Name()
 	s.opts = svcOpts{
-		PresenceQueryChannel: s.channelns + ".presence",
-		DMMsgChannel:         s.channelns + ".chat.dm.msg",
-		DMSettingsChannel:    s.channelns + ".chat.dm.settings",
-		GDMMsgChannel:        s.channelns + ".chat.gdm.msg",
-		GDMSettingsChannel:   s.channelns + ".chat.gdm.settings",
+		PresenceQueryChannel:       s.channelns + ".presence",
+		ServerPresenceQueryChannel: s.channelns + ".server.presence",
+		DMMsgChannel:               s.channelns + ".chat.dm.msg",
+		DMSettingsChannel:          s.channelns + ".chat.dm.settings",
+		GDMMsgChannel:              s.channelns + ".chat.gdm.msg",
+		GDMSettingsChannel:         s.channelns + ".chat.gdm.settings",
+		ChannelMsgChannel:          s.channelns + ".chat.server.msg",
+		ServerMemberChannel:        s.channelns + ".server.member",
+		TypingChannel:              s.channelns + ".chat.typing",
 	}
 
 	r.SetDefault("streamsize", "200M")
 	r.SetDefault("eventsize", "2K")
 	r.SetDefault("invitationduration", "72h")
 	r.SetDefault("gcduration", "72h")
+	r.SetDefault("attachment.maxsize", "16M")
+	r.SetDefault("attachment.mediatypes", defaultAttachmentMediaTypes)
+	r.SetDefault("retention.default", "0s")
+	r.SetDefault("retention.batchsize", 256)
+	r.SetDefault("typing.ttl", "8s")
+	r.SetDefault("typing.cacheduration", "1m")
+	r.SetDefault("typing.ratelimit", map[string]interface{}{
+		"period": 10,
+		"limit":  30,
+	})
+	r.SetDefault("webhook.ratelimit", map[string]interface{}{
+		"period": 60,
+		"limit":  30,
+	})
 }
 
 func (s *Service) router() *router {
@@ -178,11 +246,62 @@ func (s *Service) Init(ctx context.Context, r governor.ConfigReader, kit governo
 	if err != nil {
 		return kerrors.WithMsg(err, "Failed to parse gc duration")
 	}
+	s.attachMaxSize, err = bytefmt.ToBytes(r.GetStr("attachment.maxsize"))
+	if err != nil {
+		return kerrors.WithMsg(err, "Failed to parse attachment max size")
+	}
+	mediaTypes := r.GetStrSlice("attachment.mediatypes")
+	s.attachMediaTypes = make(map[string]struct{}, len(mediaTypes))
+	for _, i := range mediaTypes {
+		s.attachMediaTypes[strings.ToLower(strings.TrimSpace(i))] = struct{}{}
+	}
+	if len(s.attachMediaTypes) == 0 {
+		return kerrors.WithKind(nil, governor.ErrInvalidConfig, "Attachment media types must not be empty")
+	}
+	s.retention.defaultDuration, err = r.GetDuration("retention.default")
+	if err != nil {
+		return kerrors.WithMsg(err, "Failed to parse default retention duration")
+	}
+	if s.retention.defaultDuration < 0 {
+		return kerrors.WithKind(nil, governor.ErrInvalidConfig, "Default retention duration must not be negative")
+	}
+	s.retention.batchSize = r.GetInt("retention.batchsize")
+	if s.retention.batchSize <= 0 {
+		return kerrors.WithKind(nil, governor.ErrInvalidConfig, "Retention batch size must be positive")
+	}
+	s.typing.ttl, err = r.GetDuration("typing.ttl")
+	if err != nil {
+		return kerrors.WithMsg(err, "Failed to parse typing ttl")
+	}
+	s.typing.cacheDuration, err = r.GetDuration("typing.cacheduration")
+	if err != nil {
+		return kerrors.WithMsg(err, "Failed to parse typing cache duration")
+	}
+	if err := r.Unmarshal("typing.ratelimit", &s.typing.limit); err != nil {
+		return kerrors.WithKind(err, governor.ErrInvalidConfig, "Invalid typing ratelimit")
+	}
+	if s.typing.limit.Period <= 0 {
+		return kerrors.WithKind(nil, governor.ErrInvalidConfig, "Typing ratelimit period must be positive")
+	}
+	if err := r.Unmarshal("webhook.ratelimit", &s.webhookLimit); err != nil {
+		return kerrors.WithKind(err, governor.ErrInvalidConfig, "Invalid webhook ratelimit")
+	}
+	if s.webhookLimit.Period <= 0 {
+		return kerrors.WithKind(nil, governor.ErrInvalidConfig, "Webhook ratelimit period must be positive")
+	}
 
 	s.log.Info(ctx, "Loaded config",
 		klog.AString("streamsize", r.GetStr("streamsize")),
 		klog.AString("eventsize", r.GetStr("eventsize")),
 		klog.AString("invitationduration", s.invitationDuration.String()),
+		klog.AString("attachment.maxsize", bytefmt.ToString(s.attachMaxSize)),
+		klog.AString("attachment.mediatypes", strings.Join(mediaTypes, ", ")),
+		klog.AString("retention.default", s.retention.defaultDuration.String()),
+		klog.AInt("retention.batchsize", s.retention.batchSize),
+		klog.AString("typing.ttl", s.typing.ttl.String()),
+		klog.AString("typing.cacheduration", s.typing.cacheDuration.String()),
+		klog.AString("typing.ratelimit", s.typing.limit.String()),
+		klog.AString("webhook.ratelimit", s.webhookLimit.String()),
 	)
 
 	sr := s.router()
@@ -215,6 +334,22 @@ func (s *Service) Start(ctx context.Context) error {
 	go sysEvents.WatchGC(s.streamns+"_WORKER_INVITATION_GC", s.friendInvitationGCHook).Watch(ctx, s.wg, pubsub.WatchOpts{})
 	s.log.Info(ctx, "Subscribed to gov sys gc channel")
 
+	s.wg.Add(1)
+	go sysEvents.WatchGC(s.streamns+"_WORKER_SERVER_INVITE_GC", s.serverInviteGCHook).Watch(ctx, s.wg, pubsub.WatchOpts{})
+	s.log.Info(ctx, "Subscribed to gov sys gc channel for server invites")
+
+	s.wg.Add(1)
+	go sysEvents.WatchGC(s.streamns+"_WORKER_SERVER_PRESENCE_GC", s.serverPresenceGCHook).Watch(ctx, s.wg, pubsub.WatchOpts{})
+	s.log.Info(ctx, "Subscribed to gov sys gc channel for server presence")
+
+	s.wg.Add(1)
+	go sysEvents.WatchGC(s.streamns+"_WORKER_RETENTION_GC", s.retentionGCHook).Watch(ctx, s.wg, pubsub.WatchOpts{})
+	s.log.Info(ctx, "Subscribed to gov sys gc channel for msg retention")
+
+	s.wg.Add(1)
+	go sysEvents.WatchGC(s.streamns+"_WORKER_MUTE_GC", s.muteGCHook).Watch(ctx, s.wg, pubsub.WatchOpts{})
+	s.log.Info(ctx, "Subscribed to gov sys gc channel for mutes")
+
 	s.wg.Add(1)
 	go s.ws.WatchPresence(s.channelns+".>", s.streamns+"_WORKER_PRESENCE", s.presenceHandler).Watch(ctx, s.wg, pubsub.WatchOpts{})
 	s.log.Info(ctx, "Subscribed to ws presence channel")
@@ -223,6 +358,14 @@ func (s *Service) Start(ctx context.Context) error {
 	go s.ws.Watch(s.opts.PresenceQueryChannel, s.streamns+"_PRESENCE_QUERY", s.presenceQueryHandler).Watch(ctx, s.wg, pubsub.WatchOpts{})
 	s.log.Info(ctx, "Subscribed to ws conduit presence query channel")
 
+	s.wg.Add(1)
+	go s.ws.Watch(s.opts.ServerPresenceQueryChannel, s.streamns+"_SERVER_PRESENCE_QUERY", s.serverPresenceQueryHandler).Watch(ctx, s.wg, pubsub.WatchOpts{})
+	s.log.Info(ctx, "Subscribed to ws conduit server presence query channel")
+
+	s.wg.Add(1)
+	go s.ws.Watch(s.opts.TypingChannel, s.streamns+"_TYPING", s.typingHandler).Watch(ctx, s.wg, pubsub.WatchOpts{})
+	s.log.Info(ctx, "Subscribed to ws conduit typing channel")
+
 	return nil
 }
 
@@ -241,6 +384,10 @@ func (s *Service) Setup(ctx context.Context, req governor.ReqSetup) error {
 		return err
 	}
 	s.log.Info(ctx, "Created conduit friend invitation table")
+	if err := s.blocks.Setup(ctx); err != nil {
+		return err
+	}
+	s.log.Info(ctx, "Created conduit block table")
 	if err := s.dms.Setup(ctx); err != nil {
 		return err
 	}
@@ -249,10 +396,26 @@ func (s *Service) Setup(ctx context.Context, req governor.ReqSetup) error {
 		return err
 	}
 	s.log.Info(ctx, "Created conduit gdm tables")
+	if err := s.servers.Setup(ctx); err != nil {
+		return err
+	}
+	s.log.Info(ctx, "Created conduit server tables")
 	if err := s.msgs.Setup(ctx); err != nil {
 		return err
 	}
 	s.log.Info(ctx, "Created conduit msg table")
+	if err := s.mods.Setup(ctx); err != nil {
+		return err
+	}
+	s.log.Info(ctx, "Created conduit moderation tables")
+	if err := s.bots.Setup(ctx); err != nil {
+		return err
+	}
+	s.log.Info(ctx, "Created conduit bot tables")
+	if err := s.attachBucket.Init(ctx); err != nil {
+		return kerrors.WithMsg(err, "Failed to init conduit attachment bucket")
+	}
+	s.log.Info(ctx, "Created conduit attachment bucket")
 	if err := s.events.InitStream(ctx, s.streamconduit, events.StreamOpts{
 		Partitions:     16,
 		Replicas:       1,
@@ -297,6 +460,10 @@ func decodeConduitEvent(msgdata []byte) (*conduitEvent, error) {
 		if err := kjson.Unmarshal(m.Payload, &props.Unfriend); err != nil {
 			return nil, kerrors.WithKind(err, errConduitEvent{}, "Failed to decode unfriend event")
 		}
+	case conduitEventKindMsgIndex:
+		if err := kjson.Unmarshal(m.Payload, &props.MsgIndex); err != nil {
+			return nil, kerrors.WithKind(err, errConduitEvent{}, "Failed to decode msg index event")
+		}
 	default:
 		return nil, kerrors.WithKind(nil, errConduitEvent{}, "Invalid user event kind")
 	}
@@ -325,6 +492,17 @@ func encodeConduitEventUnfriend(props unfriendProps) ([]byte, error) {
 	return b, nil
 }
 
+func encodeConduitEventMsgIndex(props msgIndexProps) ([]byte, error) {
+	b, err := kjson.Marshal(conduitEventEnc{
+		Kind:    conduitEventKindMsgIndex,
+		Payload: props,
+	})
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to encode msg index props to json")
+	}
+	return b, nil
+}
+
 func (s *Service) conduitEventHandler(ctx context.Context, msg events.Msg) error {
 	props, err := decodeConduitEvent(msg.Value)
 	if err != nil {
@@ -335,6 +513,8 @@ func (s *Service) conduitEventHandler(ctx context.Context, msg events.Msg) error
 		return s.friendEventHandler(ctx, props.Friend)
 	case conduitEventKindUnfriend:
 		return s.unfriendEventHandler(ctx, props.Unfriend)
+	case conduitEventKindMsgIndex:
+		return s.msgIndexEventHandler(ctx, props.MsgIndex)
 	default:
 		return nil
 	}
@@ -375,6 +555,26 @@ func (s *Service) userDeleteEventHandler(ctx context.Context, props user.DeleteU
 	if err := s.invitations.DeleteByUser(ctx, props.Userid); err != nil {
 		return kerrors.WithMsg(err, "Failed to delete user invitations")
 	}
+	if err := s.blocks.DeleteByUser(ctx, props.Userid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete user blocks")
+	}
+	for {
+		m, err := s.bots.GetCreatorBots(ctx, props.Userid, chatDeleteBatchSize, 0)
+		if err != nil {
+			return kerrors.WithMsg(err, "Failed to get user bots")
+		}
+		if len(m) == 0 {
+			break
+		}
+		for _, i := range m {
+			if err := s.rmBot(ctx, i.Botid); err != nil {
+				return kerrors.WithMsg(err, "Failed to delete bot")
+			}
+		}
+		if len(m) < chatDeleteBatchSize {
+			break
+		}
+	}
 	for {
 		chatids, err := s.gdms.GetLatest(ctx, props.Userid, 0, chatDeleteBatchSize)
 		if err != nil {
@@ -392,6 +592,23 @@ func (s *Service) userDeleteEventHandler(ctx context.Context, props user.DeleteU
 			break
 		}
 	}
+	for {
+		m, err := s.servers.GetUserServers(ctx, props.Userid, chatDeleteBatchSize, 0)
+		if err != nil {
+			return kerrors.WithMsg(err, "Failed to get user servers")
+		}
+		if len(m) == 0 {
+			break
+		}
+		for _, i := range m {
+			if err := s.rmServerMember(ctx, i.ServerID, props.Userid); err != nil {
+				return kerrors.WithMsg(err, "Failed to remove server member")
+			}
+		}
+		if len(m) < chatDeleteBatchSize {
+			break
+		}
+	}
 	for {
 		friends, err := s.friends.GetFriends(ctx, props.Userid, "", chatDeleteBatchSize, 0)
 		if err != nil {
@@ -419,3 +636,11 @@ func (s *Service) friendInvitationGCHook(ctx context.Context, props sysevent.Tim
 	s.log.Info(ctx, "GC friend invitations")
 	return nil
 }
+
+func (s *Service) serverInviteGCHook(ctx context.Context, props sysevent.TimestampProps) error {
+	if err := s.servers.DeleteExpiredInvites(ctx, props.Timestamp); err != nil {
+		return kerrors.WithMsg(err, "Failed to GC server invites")
+	}
+	s.log.Info(ctx, "GC server invites")
+	return nil
+}
@@ -0,0 +1,344 @@
+package modmodel
+
+import (
+	"context"
+	"time"
+
+	"xorkevin.dev/forge/model/sqldb"
+	"xorkevin.dev/governor/service/dbsql"
+	"xorkevin.dev/governor/util/uid"
+	"xorkevin.dev/kerrors"
+)
+
+//go:generate forge model
+
+const (
+	// ReportStatusOpen is the status of a report awaiting moderation
+	ReportStatusOpen = "open"
+	// ReportStatusResolved is the status of a report that has been acted upon
+	ReportStatusResolved = "resolved"
+	// ReportStatusDismissed is the status of a report that required no action
+	ReportStatusDismissed = "dismissed"
+)
+
+type (
+	// Repo is a conduit moderation repository
+	Repo interface {
+		NewReport(serverid, chatid, msgid string, userid string, reporterid string, kind, value string, reason string) (*ReportModel, error)
+		GetReport(ctx context.Context, reportid string) (*ReportModel, error)
+		GetReports(ctx context.Context, serverid string, limit, offset int) ([]ReportModel, error)
+		GetReportsByStatus(ctx context.Context, serverid string, status string, limit, offset int) ([]ReportModel, error)
+		InsertReport(ctx context.Context, m *ReportModel) error
+		ResolveReport(ctx context.Context, m *ReportModel, status string, resolverid string) error
+		GetMute(ctx context.Context, serverid, userid string, now int64) (*MuteModel, error)
+		GetMutes(ctx context.Context, serverid string, now int64, limit, offset int) ([]MuteModel, error)
+		SetMute(ctx context.Context, serverid, userid string, mutedby string, reason string, expires int64) (*MuteModel, error)
+		DeleteMute(ctx context.Context, serverid, userid string) error
+		DeleteExpiredMutes(ctx context.Context, before int64) error
+		NewAudit(serverid string, actorid string, action string) (*AuditModel, error)
+		GetAudits(ctx context.Context, serverid string, limit, offset int) ([]AuditModel, error)
+		InsertAudit(ctx context.Context, m *AuditModel) error
+		Setup(ctx context.Context) error
+	}
+
+	repo struct {
+		tableReports *reportModelTable
+		tableMutes   *muteModelTable
+		tableAudits  *auditModelTable
+		db           dbsql.Database
+	}
+
+	// ReportModel is the db msg report model
+	//
+	// ServerID is empty for reports of dm and gdm msgs.
+	//forge:model report
+	//forge:model:query report
+	ReportModel struct {
+		Reportid     string `model:"reportid,VARCHAR(31)"`
+		ServerID     string `model:"serverid,VARCHAR(31) NOT NULL"`
+		Chatid       string `model:"chatid,VARCHAR(31) NOT NULL"`
+		Msgid        string `model:"msgid,VARCHAR(31) NOT NULL"`
+		Userid       string `model:"userid,VARCHAR(31) NOT NULL"`
+		Reporterid   string `model:"reporterid,VARCHAR(31) NOT NULL"`
+		Kind         string `model:"kind,VARCHAR(31) NOT NULL"`
+		Value        string `model:"value,VARCHAR(4095) NOT NULL"`
+		Reason       string `model:"reason,VARCHAR(255) NOT NULL"`
+		Status       string `model:"status,VARCHAR(31) NOT NULL"`
+		Resolverid   string `model:"resolverid,VARCHAR(31) NOT NULL"`
+		ResolveTime  int64  `model:"resolve_time,BIGINT NOT NULL"`
+		CreationTime int64  `model:"creation_time,BIGINT NOT NULL"`
+	}
+
+	//forge:model:query report
+	reportResolve struct {
+		Status      string `model:"status"`
+		Resolverid  string `model:"resolverid"`
+		ResolveTime int64  `model:"resolve_time"`
+	}
+
+	// MuteModel is the db user mute model
+	//
+	// ServerID is empty for mutes that apply to all chats.
+	//forge:model mute
+	//forge:model:query mute
+	MuteModel struct {
+		ServerID     string `model:"serverid,VARCHAR(31)"`
+		Userid       string `model:"userid,VARCHAR(31)"`
+		Mutedby      string `model:"mutedby,VARCHAR(31) NOT NULL"`
+		Reason       string `model:"reason,VARCHAR(255) NOT NULL"`
+		Expires      int64  `model:"expires,BIGINT NOT NULL"`
+		CreationTime int64  `model:"creation_time,BIGINT NOT NULL"`
+	}
+
+	// AuditModel is the db moderation audit log model
+	//
+	// ServerID is empty for actions taken by admins outside of a server.
+	//forge:model audit
+	//forge:model:query audit
+	AuditModel struct {
+		ServerID     string `model:"serverid,VARCHAR(31)"`
+		Auditid      string `model:"auditid,VARCHAR(31)"`
+		Actorid      string `model:"actorid,VARCHAR(31) NOT NULL"`
+		Action       string `model:"action,VARCHAR(31) NOT NULL"`
+		Targetid     string `model:"targetid,VARCHAR(31) NOT NULL"`
+		Reportid     string `model:"reportid,VARCHAR(31) NOT NULL"`
+		Chatid       string `model:"chatid,VARCHAR(31) NOT NULL"`
+		Msgid        string `model:"msgid,VARCHAR(31) NOT NULL"`
+		Detail       string `model:"detail,VARCHAR(255) NOT NULL"`
+		CreationTime int64  `model:"creation_time,BIGINT NOT NULL"`
+	}
+)
+
+// New creates a new moderation repo
+func New(database dbsql.Database, tableReports, tableMutes, tableAudits string) Repo {
+	return &repo{
+		tableReports: &reportModelTable{
+			TableName: tableReports,
+		},
+		tableMutes: &muteModelTable{
+			TableName: tableMutes,
+		},
+		tableAudits: &auditModelTable{
+			TableName: tableAudits,
+		},
+		db: database,
+	}
+}
+
+// NewReport creates a new open report that snapshots the content of a msg
+func (r *repo) NewReport(serverid, chatid, msgid string, userid string, reporterid string, kind, value string, reason string) (*ReportModel, error) {
+	u, err := uid.New()
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to create new uid")
+	}
+	return &ReportModel{
+		Reportid:     u.Base64(),
+		ServerID:     serverid,
+		Chatid:       chatid,
+		Msgid:        msgid,
+		Userid:       userid,
+		Reporterid:   reporterid,
+		Kind:         kind,
+		Value:        value,
+		Reason:       reason,
+		Status:       ReportStatusOpen,
+		Resolverid:   "",
+		ResolveTime:  0,
+		CreationTime: time.Now().Round(0).Unix(),
+	}, nil
+}
+
+func (r *repo) GetReport(ctx context.Context, reportid string) (*ReportModel, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.tableReports.GetReportModelByID(ctx, d, reportid)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get report")
+	}
+	return m, nil
+}
+
+func (r *repo) GetReports(ctx context.Context, serverid string, limit, offset int) ([]ReportModel, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.tableReports.GetReportModelByServer(ctx, d, serverid, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get reports")
+	}
+	return m, nil
+}
+
+func (r *repo) GetReportsByStatus(ctx context.Context, serverid string, status string, limit, offset int) ([]ReportModel, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.tableReports.GetReportModelByServerStatus(ctx, d, serverid, status, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get reports")
+	}
+	return m, nil
+}
+
+func (r *repo) InsertReport(ctx context.Context, m *ReportModel) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tableReports.Insert(ctx, d, m); err != nil {
+		return kerrors.WithMsg(err, "Failed to insert report")
+	}
+	return nil
+}
+
+func (r *repo) ResolveReport(ctx context.Context, m *ReportModel, status string, resolverid string) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	m.Status = status
+	m.Resolverid = resolverid
+	m.ResolveTime = time.Now().Round(0).Unix()
+	if err := r.tableReports.UpdreportResolveByID(ctx, d, &reportResolve{
+		Status:      m.Status,
+		Resolverid:  m.Resolverid,
+		ResolveTime: m.ResolveTime,
+	}, m.Reportid); err != nil {
+		return kerrors.WithMsg(err, "Failed to update report")
+	}
+	return nil
+}
+
+// GetMute returns the mute of a user that has not expired by now
+func (r *repo) GetMute(ctx context.Context, serverid, userid string, now int64) (*MuteModel, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.tableMutes.GetMuteModelByServerUserAfterExpires(ctx, d, serverid, userid, now)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get mute")
+	}
+	return m, nil
+}
+
+func (r *repo) GetMutes(ctx context.Context, serverid string, now int64, limit, offset int) ([]MuteModel, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.tableMutes.GetMuteModelByServerAfterExpires(ctx, d, serverid, now, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get mutes")
+	}
+	return m, nil
+}
+
+func (t *muteModelTable) Upsert(ctx context.Context, d sqldb.Executor, m *MuteModel) error {
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (serverid, userid, mutedby, reason, expires, creation_time) VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (serverid, userid) DO UPDATE SET (mutedby, reason, expires, creation_time) = (EXCLUDED.mutedby, EXCLUDED.reason, EXCLUDED.expires, EXCLUDED.creation_time);", m.ServerID, m.Userid, m.Mutedby, m.Reason, m.Expires, m.CreationTime)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// SetMute mutes a user until expires, replacing any existing mute
+func (r *repo) SetMute(ctx context.Context, serverid, userid string, mutedby string, reason string, expires int64) (*MuteModel, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m := &MuteModel{
+		ServerID:     serverid,
+		Userid:       userid,
+		Mutedby:      mutedby,
+		Reason:       reason,
+		Expires:      expires,
+		CreationTime: time.Now().Round(0).Unix(),
+	}
+	if err := r.tableMutes.Upsert(ctx, d, m); err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to set mute")
+	}
+	return m, nil
+}
+
+func (r *repo) DeleteMute(ctx context.Context, serverid, userid string) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tableMutes.DelByServerUser(ctx, d, serverid, userid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete mute")
+	}
+	return nil
+}
+
+func (r *repo) DeleteExpiredMutes(ctx context.Context, before int64) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tableMutes.DelBeforeExpires(ctx, d, before); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete expired mutes")
+	}
+	return nil
+}
+
+func (r *repo) NewAudit(serverid string, actorid string, action string) (*AuditModel, error) {
+	u, err := uid.New()
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to create new uid")
+	}
+	return &AuditModel{
+		ServerID:     serverid,
+		Auditid:      u.Base64(),
+		Actorid:      actorid,
+		Action:       action,
+		CreationTime: time.Now().Round(0).Unix(),
+	}, nil
+}
+
+func (r *repo) GetAudits(ctx context.Context, serverid string, limit, offset int) ([]AuditModel, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.tableAudits.GetAuditModelByServer(ctx, d, serverid, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get audit log")
+	}
+	return m, nil
+}
+
+func (r *repo) InsertAudit(ctx context.Context, m *AuditModel) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tableAudits.Insert(ctx, d, m); err != nil {
+		return kerrors.WithMsg(err, "Failed to insert audit log entry")
+	}
+	return nil
+}
+
+// Setup creates new moderation tables
+func (r *repo) Setup(ctx context.Context) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tableReports.Setup(ctx, d); err != nil {
+		return kerrors.WithMsg(err, "Failed to setup report model")
+	}
+	if err := r.tableMutes.Setup(ctx, d); err != nil {
+		return kerrors.WithMsg(err, "Failed to setup mute model")
+	}
+	if err := r.tableAudits.Setup(ctx, d); err != nil {
+		return kerrors.WithMsg(err, "Failed to setup audit model")
+	}
+	return nil
+}
@@ -2,8 +2,12 @@ package msgmodel
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
+	"xorkevin.dev/forge/model/sqldb"
 	"xorkevin.dev/governor/service/dbsql"
 	"xorkevin.dev/governor/util/uid"
 	"xorkevin.dev/kerrors"
@@ -11,63 +15,209 @@ import (
 
 //go:generate forge model
 
+const (
+	// AuthorKindUser is the author kind of a msg sent by a user
+	AuthorKindUser = "user"
+	// AuthorKindBot is the author kind of a msg sent by a bot account
+	AuthorKindBot = "bot"
+	// AuthorKindWebhook is the author kind of a msg sent by an incoming
+	// webhook
+	AuthorKindWebhook = "webhook"
+)
+
 type (
 	Repo interface {
-		New(chatid string, userid string, kind string, value string) (*Model, error)
+		New(chatid string, userid string, kind string, value string, parentid string) (*Model, error)
+		GetMsg(ctx context.Context, chatid string, msgid string) (*Model, error)
 		GetMsgs(ctx context.Context, chatid string, kind string, msgid string, limit int) ([]Model, error)
+		GetThreadMsgs(ctx context.Context, chatid string, parentid string, msgid string, limit int) ([]Model, error)
 		Insert(ctx context.Context, m *Model) error
+		EditMsg(ctx context.Context, m *Model, value string) error
+		GetMsgEdits(ctx context.Context, chatid string, msgid string, limit, offset int) ([]EditModel, error)
+		ToggleReaction(ctx context.Context, chatid string, msgid string, reaction string, userid string) (bool, error)
+		GetReactions(ctx context.Context, chatid string, msgids []string, userid string) ([]ReactionCount, error)
 		EraseMsgs(ctx context.Context, chatid string, msgids []string) error
 		DeleteChatMsgs(ctx context.Context, chatid string) error
+		InsertSearch(ctx context.Context, m *Model) error
+		SearchMsgs(ctx context.Context, chatid string, query string, limit, offset int) ([]SearchModel, error)
+		UpdateReadCursor(ctx context.Context, chatid string, userid string, msgid string) (*ReadModel, bool, error)
+		GetReadCursors(ctx context.Context, chatid string, limit, offset int) ([]ReadModel, error)
+		GetUnreadCounts(ctx context.Context, userid string, chatids []string, limit int) ([]UnreadCount, error)
+		GetRetention(ctx context.Context, chatid string) (*RetentionModel, error)
+		GetRetentions(ctx context.Context, limit, offset int) ([]RetentionModel, error)
+		SetRetention(ctx context.Context, chatid string, duration int64) error
+		DeleteRetention(ctx context.Context, chatid string) error
+		GetExpiredMsgs(ctx context.Context, chatid string, before int64, limit int) ([]string, error)
+		GetDefaultExpiredMsgs(ctx context.Context, before int64, limit int) ([]MsgRef, error)
 		Setup(ctx context.Context) error
 	}
 
 	repo struct {
-		table *msgModelTable
-		db    dbsql.Database
+		table          *msgModelTable
+		tableSearch    *searchModelTable
+		tableEdit      *editModelTable
+		tableReaction  *reactionModelTable
+		tableRead      *readModelTable
+		tableRetention *retentionModelTable
+		db             dbsql.Database
 	}
 
 	// Model is the db chat msg model
 	//forge:model msg
 	//forge:model:query msg
 	Model struct {
+		Chatid       string `model:"chatid,VARCHAR(31)"`
+		Msgid        string `model:"msgid,VARCHAR(31)"`
+		Userid       string `model:"userid,VARCHAR(31) NOT NULL"`
+		Timems       int64  `model:"time_ms,BIGINT NOT NULL"`
+		Kind         string `model:"kind,VARCHAR(31) NOT NULL"`
+		Value        string `model:"value,VARCHAR(4095) NOT NULL"`
+		Parentid     string `model:"parentid,VARCHAR(31) NOT NULL"`
+		Edittimems   int64  `model:"edit_time_ms,BIGINT NOT NULL"`
+		AuthorKind   string `model:"author_kind,VARCHAR(31) NOT NULL"`
+		AuthorName   string `model:"author_name,VARCHAR(127) NOT NULL"`
+		AuthorAvatar string `model:"author_avatar,VARCHAR(4095) NOT NULL"`
+	}
+
+	//forge:model:query msg
+	msgValue struct {
+		Value string `model:"value"`
+	}
+
+	//forge:model:query msg
+	msgEdit struct {
+		Value      string `model:"value"`
+		Edittimems int64  `model:"edit_time_ms"`
+	}
+
+	// SearchModel is the db chat msg full text search model
+	//forge:model search
+	//forge:model:query search
+	SearchModel struct {
 		Chatid string `model:"chatid,VARCHAR(31)"`
 		Msgid  string `model:"msgid,VARCHAR(31)"`
 		Userid string `model:"userid,VARCHAR(31) NOT NULL"`
 		Timems int64  `model:"time_ms,BIGINT NOT NULL"`
-		Kind   string `model:"kind,VARCHAR(31) NOT NULL"`
 		Value  string `model:"value,VARCHAR(4095) NOT NULL"`
 	}
 
-	//forge:model:query msg
-	msgValue struct {
-		Value string `model:"value"`
+	// EditModel is the db chat msg edit history model
+	//forge:model edit
+	//forge:model:query edit
+	EditModel struct {
+		Chatid string `model:"chatid,VARCHAR(31)"`
+		Msgid  string `model:"msgid,VARCHAR(31)"`
+		Timems int64  `model:"time_ms,BIGINT"`
+		Value  string `model:"value,VARCHAR(4095) NOT NULL"`
+	}
+
+	// ReactionModel is the db chat msg reaction model
+	//forge:model reaction
+	//forge:model:query reaction
+	ReactionModel struct {
+		Chatid   string `model:"chatid,VARCHAR(31)"`
+		Msgid    string `model:"msgid,VARCHAR(31)"`
+		Reaction string `model:"reaction,VARCHAR(63)"`
+		Userid   string `model:"userid,VARCHAR(31)"`
+		Timems   int64  `model:"time_ms,BIGINT NOT NULL"`
+	}
+
+	// ReactionCount is the number of users that have reacted to a msg with a
+	// reaction
+	ReactionCount struct {
+		Msgid    string
+		Reaction string
+		Count    int
+		Reacted  bool
+	}
+
+	// ReadModel is the db chat read cursor model
+	//forge:model read
+	//forge:model:query read
+	ReadModel struct {
+		Chatid string `model:"chatid,VARCHAR(31)"`
+		Userid string `model:"userid,VARCHAR(31)"`
+		Msgid  string `model:"msgid,VARCHAR(31) NOT NULL"`
+		Timems int64  `model:"time_ms,BIGINT NOT NULL"`
+	}
+
+	// UnreadCount is the number of msgs in a chat after the read cursor of a
+	// user
+	UnreadCount struct {
+		Chatid    string
+		ReadMsgid string
+		Count     int
+	}
+
+	// RetentionModel is the db chat msg retention policy model
+	//forge:model retention
+	//forge:model:query retention
+	RetentionModel struct {
+		Chatid      string `model:"chatid,VARCHAR(31)"`
+		Duration    int64  `model:"duration,BIGINT NOT NULL"`
+		LastUpdated int64  `model:"last_updated,BIGINT NOT NULL"`
+	}
+
+	// MsgRef references a msg of a chat
+	MsgRef struct {
+		Chatid string
+		Msgid  string
 	}
 )
 
-func New(database dbsql.Database, table string) Repo {
+func New(database dbsql.Database, table, tableSearch, tableEdit, tableReaction, tableRead, tableRetention string) Repo {
 	return &repo{
 		table: &msgModelTable{
 			TableName: table,
 		},
+		tableSearch: &searchModelTable{
+			TableName: tableSearch,
+		},
+		tableEdit: &editModelTable{
+			TableName: tableEdit,
+		},
+		tableReaction: &reactionModelTable{
+			TableName: tableReaction,
+		},
+		tableRead: &readModelTable{
+			TableName: tableRead,
+		},
+		tableRetention: &retentionModelTable{
+			TableName: tableRetention,
+		},
 		db: database,
 	}
 }
 
-func (r *repo) New(chatid string, userid string, kind string, value string) (*Model, error) {
+func (r *repo) New(chatid string, userid string, kind string, value string, parentid string) (*Model, error) {
 	u, err := uid.New()
 	if err != nil {
 		return nil, kerrors.WithMsg(err, "Failed to create new uid")
 	}
 	return &Model{
-		Chatid: chatid,
-		Msgid:  u.Base64(),
-		Userid: userid,
-		Timems: time.Now().Round(0).UnixMilli(),
-		Kind:   kind,
-		Value:  value,
+		Chatid:     chatid,
+		Msgid:      u.Base64(),
+		Userid:     userid,
+		Timems:     time.Now().Round(0).UnixMilli(),
+		Kind:       kind,
+		Value:      value,
+		Parentid:   parentid,
+		AuthorKind: AuthorKindUser,
 	}, nil
 }
 
+func (r *repo) GetMsg(ctx context.Context, chatid string, msgid string) (*Model, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.table.GetModelByChatMsg(ctx, d, chatid, msgid)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get chat msg")
+	}
+	return m, nil
+}
+
 func (r *repo) GetMsgs(ctx context.Context, chatid string, kind string, msgid string, limit int) ([]Model, error) {
 	d, err := r.db.DB(ctx)
 	if err != nil {
@@ -93,6 +243,23 @@ func (r *repo) GetMsgs(ctx context.Context, chatid string, kind string, msgid st
 	return m, nil
 }
 
+func (r *repo) GetThreadMsgs(ctx context.Context, chatid string, parentid string, msgid string, limit int) ([]Model, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var m []Model
+	if msgid == "" {
+		m, err = r.table.GetModelByChatParent(ctx, d, chatid, parentid, limit, 0)
+	} else {
+		m, err = r.table.GetModelByChatParentBeforeMsg(ctx, d, chatid, parentid, msgid, limit, 0)
+	}
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get chat thread msgs")
+	}
+	return m, nil
+}
+
 func (r *repo) Insert(ctx context.Context, m *Model) error {
 	d, err := r.db.DB(ctx)
 	if err != nil {
@@ -104,6 +271,125 @@ func (r *repo) Insert(ctx context.Context, m *Model) error {
 	return nil
 }
 
+func (r *repo) EditMsg(ctx context.Context, m *Model, value string) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now().Round(0).UnixMilli()
+	// the previous value of the msg is kept as its edit history
+	if err := r.tableEdit.Insert(ctx, d, &EditModel{
+		Chatid: m.Chatid,
+		Msgid:  m.Msgid,
+		Timems: now,
+		Value:  m.Value,
+	}); err != nil {
+		return kerrors.WithMsg(err, "Failed to insert chat msg edit")
+	}
+	if err := r.table.UpdmsgEditByChatMsg(ctx, d, &msgEdit{
+		Value:      value,
+		Edittimems: now,
+	}, m.Chatid, m.Msgid); err != nil {
+		return kerrors.WithMsg(err, "Failed to edit chat msg")
+	}
+	// the msg must be reindexed with its new value
+	if err := r.tableSearch.DelByChatMsgs(ctx, d, m.Chatid, []string{m.Msgid}); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete chat msg search index")
+	}
+	m.Value = value
+	m.Edittimems = now
+	return nil
+}
+
+func (r *repo) GetMsgEdits(ctx context.Context, chatid string, msgid string, limit, offset int) ([]EditModel, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.tableEdit.GetEditModelByChatMsg(ctx, d, chatid, msgid, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get chat msg edits")
+	}
+	return m, nil
+}
+
+func (r *repo) ToggleReaction(ctx context.Context, chatid string, msgid string, reaction string, userid string) (bool, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return false, err
+	}
+	if err := r.tableReaction.Insert(ctx, d, &ReactionModel{
+		Chatid:   chatid,
+		Msgid:    msgid,
+		Reaction: reaction,
+		Userid:   userid,
+		Timems:   time.Now().Round(0).UnixMilli(),
+	}); err != nil {
+		if !errors.Is(err, dbsql.ErrUnique) {
+			return false, kerrors.WithMsg(err, "Failed to insert chat msg reaction")
+		}
+		// user has already reacted, hence toggle the reaction off
+		if err := r.tableReaction.DelByChatMsgReactionUser(ctx, d, chatid, msgid, reaction, userid); err != nil {
+			return false, kerrors.WithMsg(err, "Failed to delete chat msg reaction")
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+func (t *reactionModelTable) GetCountsByChatMsgs(ctx context.Context, d sqldb.Executor, chatid string, msgids []string, userid string) (_ []ReactionCount, retErr error) {
+	paramCount := 2
+	args := make([]interface{}, 0, paramCount+len(msgids))
+	args = append(args, chatid, userid)
+	var placeholdersmsgids string
+	{
+		placeholders := make([]string, 0, len(msgids))
+		for _, i := range msgids {
+			paramCount++
+			placeholders = append(placeholders, fmt.Sprintf("($%d)", paramCount))
+			args = append(args, i)
+		}
+		placeholdersmsgids = strings.Join(placeholders, ", ")
+	}
+	var res []ReactionCount
+	rows, err := d.QueryContext(ctx, "SELECT msgid, reaction, COUNT(*), bool_or(userid = $2) FROM "+t.TableName+" WHERE chatid = $1 AND msgid IN (VALUES "+placeholdersmsgids+") GROUP BY msgid, reaction ORDER BY msgid DESC, MIN(time_ms);", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			retErr = errors.Join(retErr, kerrors.WithMsg(err, "Failed to close db rows"))
+		}
+	}()
+	for rows.Next() {
+		var m ReactionCount
+		if err := rows.Scan(&m.Msgid, &m.Reaction, &m.Count, &m.Reacted); err != nil {
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (r *repo) GetReactions(ctx context.Context, chatid string, msgids []string, userid string) ([]ReactionCount, error) {
+	if len(msgids) == 0 {
+		return nil, nil
+	}
+
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.tableReaction.GetCountsByChatMsgs(ctx, d, chatid, msgids, userid)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get chat msg reactions")
+	}
+	return m, nil
+}
+
 func (r *repo) EraseMsgs(ctx context.Context, chatid string, msgids []string) error {
 	if len(msgids) == 0 {
 		return nil
@@ -118,6 +404,15 @@ func (r *repo) EraseMsgs(ctx context.Context, chatid string, msgids []string) er
 	}, chatid, msgids); err != nil {
 		return kerrors.WithMsg(err, "Failed to erase chat msgs")
 	}
+	if err := r.tableSearch.DelByChatMsgs(ctx, d, chatid, msgids); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete chat msgs search index")
+	}
+	if err := r.tableEdit.DelByChatMsgs(ctx, d, chatid, msgids); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete chat msg edits")
+	}
+	if err := r.tableReaction.DelByChatMsgs(ctx, d, chatid, msgids); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete chat msg reactions")
+	}
 	return nil
 }
 
@@ -126,12 +421,350 @@ func (r *repo) DeleteChatMsgs(ctx context.Context, chatid string) error {
 	if err != nil {
 		return err
 	}
+	if err := r.tableSearch.DelByChat(ctx, d, chatid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete chat msgs search index")
+	}
+	if err := r.tableEdit.DelByChat(ctx, d, chatid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete chat msg edits")
+	}
+	if err := r.tableReaction.DelByChat(ctx, d, chatid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete chat msg reactions")
+	}
+	if err := r.tableRead.DelByChat(ctx, d, chatid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete chat read cursors")
+	}
+	if err := r.tableRetention.DelByChat(ctx, d, chatid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete chat retention policy")
+	}
 	if err := r.table.DelByChat(ctx, d, chatid); err != nil {
 		return kerrors.WithMsg(err, "Failed to delete chat msgs")
 	}
 	return nil
 }
 
+const (
+	searchConfig = "english"
+	// searchDoc must match the expression of the search index for the index to
+	// be used
+	searchDoc = "to_tsvector('" + searchConfig + "', value)"
+)
+
+// Upsert inserts a msg into the search index, replacing the value of a msg
+// that has already been indexed
+//
+// The search doc is an expression index on the value, so it is updated along
+// with the value.
+func (t *searchModelTable) Upsert(ctx context.Context, d sqldb.Executor, m *SearchModel) error {
+	if _, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (chatid, msgid, userid, time_ms, value) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (chatid, msgid) DO UPDATE SET value = EXCLUDED.value;", m.Chatid, m.Msgid, m.Userid, m.Timems, m.Value); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *repo) InsertSearch(ctx context.Context, m *Model) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	// a msg is indexed again when it is edited
+	if err := r.tableSearch.Upsert(ctx, d, &SearchModel{
+		Chatid: m.Chatid,
+		Msgid:  m.Msgid,
+		Userid: m.Userid,
+		Timems: m.Timems,
+		Value:  m.Value,
+	}); err != nil {
+		return kerrors.WithMsg(err, "Failed to insert chat msg search index")
+	}
+	return nil
+}
+
+func (t *searchModelTable) SetupSearchIndex(ctx context.Context, d sqldb.Executor) error {
+	if _, err := d.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS "+t.TableName+"_search_doc_index ON "+t.TableName+" USING GIN ("+searchDoc+");"); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *searchModelTable) SearchByChat(ctx context.Context, d sqldb.Executor, chatid string, query string, limit, offset int) (_ []SearchModel, retErr error) {
+	res := make([]SearchModel, 0, limit)
+	rows, err := d.QueryContext(ctx, "SELECT chatid, msgid, userid, time_ms, value FROM "+t.TableName+", websearch_to_tsquery('"+searchConfig+"', $3) q WHERE chatid = $4 AND "+searchDoc+" @@ q ORDER BY ts_rank("+searchDoc+", q) DESC, msgid DESC LIMIT $1 OFFSET $2;", limit, offset, query, chatid)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			retErr = errors.Join(retErr, kerrors.WithMsg(err, "Failed to close db rows"))
+		}
+	}()
+	for rows.Next() {
+		var m SearchModel
+		if err := rows.Scan(&m.Chatid, &m.Msgid, &m.Userid, &m.Timems, &m.Value); err != nil {
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (r *repo) SearchMsgs(ctx context.Context, chatid string, query string, limit, offset int) ([]SearchModel, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.tableSearch.SearchByChat(ctx, d, chatid, query, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to search chat msgs")
+	}
+	return m, nil
+}
+
+func (t *readModelTable) UpsertAdvance(ctx context.Context, d sqldb.Executor, m *ReadModel) (bool, error) {
+	// the read cursor only ever moves forward
+	res, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (chatid, userid, msgid, time_ms) VALUES ($1, $2, $3, $4) ON CONFLICT (chatid, userid) DO UPDATE SET (msgid, time_ms) = (EXCLUDED.msgid, EXCLUDED.time_ms) WHERE "+t.TableName+".msgid < EXCLUDED.msgid;", m.Chatid, m.Userid, m.Msgid, m.Timems)
+	if err != nil {
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *repo) UpdateReadCursor(ctx context.Context, chatid string, userid string, msgid string) (*ReadModel, bool, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	m := &ReadModel{
+		Chatid: chatid,
+		Userid: userid,
+		Msgid:  msgid,
+		Timems: time.Now().Round(0).UnixMilli(),
+	}
+	ok, err := r.tableRead.UpsertAdvance(ctx, d, m)
+	if err != nil {
+		return nil, false, kerrors.WithMsg(err, "Failed to update chat read cursor")
+	}
+	return m, ok, nil
+}
+
+func (r *repo) GetReadCursors(ctx context.Context, chatid string, limit, offset int) ([]ReadModel, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.tableRead.GetReadModelByChat(ctx, d, chatid, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get chat read cursors")
+	}
+	return m, nil
+}
+
+func (t *msgModelTable) GetUnreadCountsByChats(ctx context.Context, d sqldb.Executor, tableRead string, userid string, chatids []string, limit int) (_ []UnreadCount, retErr error) {
+	paramCount := 2
+	args := make([]interface{}, 0, paramCount+len(chatids))
+	args = append(args, userid, limit)
+	var placeholderschatids string
+	{
+		placeholders := make([]string, 0, len(chatids))
+		for _, i := range chatids {
+			paramCount++
+			placeholders = append(placeholders, fmt.Sprintf("($%d)", paramCount))
+			args = append(args, i)
+		}
+		placeholderschatids = strings.Join(placeholders, ", ")
+	}
+	res := make([]UnreadCount, 0, len(chatids))
+	// counts are bounded by limit so that each chat only scans at most limit
+	// msgs from the primary key index
+	rows, err := d.QueryContext(ctx, "SELECT c.chatid, COALESCE(r.msgid, ''), (SELECT COUNT(*) FROM (SELECT 1 FROM "+t.TableName+" m WHERE m.chatid = c.chatid AND m.msgid > COALESCE(r.msgid, '') AND m.userid <> $1 LIMIT $2) u) FROM (VALUES "+placeholderschatids+") AS c (chatid) LEFT JOIN "+tableRead+" r ON r.chatid = c.chatid AND r.userid = $1;", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			retErr = errors.Join(retErr, kerrors.WithMsg(err, "Failed to close db rows"))
+		}
+	}()
+	for rows.Next() {
+		var m UnreadCount
+		if err := rows.Scan(&m.Chatid, &m.ReadMsgid, &m.Count); err != nil {
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (r *repo) GetUnreadCounts(ctx context.Context, userid string, chatids []string, limit int) ([]UnreadCount, error) {
+	if len(chatids) == 0 {
+		return nil, nil
+	}
+
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.table.GetUnreadCountsByChats(ctx, d, r.tableRead.TableName, userid, chatids, limit)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get chat unread counts")
+	}
+	return m, nil
+}
+
+func (r *repo) GetRetention(ctx context.Context, chatid string) (*RetentionModel, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.tableRetention.GetRetentionModelByChat(ctx, d, chatid)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get chat retention policy")
+	}
+	return m, nil
+}
+
+func (r *repo) GetRetentions(ctx context.Context, limit, offset int) ([]RetentionModel, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.tableRetention.GetRetentionModelAll(ctx, d, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get chat retention policies")
+	}
+	return m, nil
+}
+
+func (t *retentionModelTable) Upsert(ctx context.Context, d sqldb.Executor, m *RetentionModel) error {
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (chatid, duration, last_updated) VALUES ($1, $2, $3) ON CONFLICT (chatid) DO UPDATE SET (duration, last_updated) = (EXCLUDED.duration, EXCLUDED.last_updated);", m.Chatid, m.Duration, m.LastUpdated)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *repo) SetRetention(ctx context.Context, chatid string, duration int64) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tableRetention.Upsert(ctx, d, &RetentionModel{
+		Chatid:      chatid,
+		Duration:    duration,
+		LastUpdated: time.Now().Round(0).Unix(),
+	}); err != nil {
+		return kerrors.WithMsg(err, "Failed to set chat retention policy")
+	}
+	return nil
+}
+
+func (r *repo) DeleteRetention(ctx context.Context, chatid string) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tableRetention.DelByChat(ctx, d, chatid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete chat retention policy")
+	}
+	return nil
+}
+
+// SetupExpiryIndex creates an index on the send time of msgs that have not
+// yet been erased, since erased msgs accumulate and would otherwise be
+// scanned on every retention pass
+func (t *msgModelTable) SetupExpiryIndex(ctx context.Context, d sqldb.Executor) error {
+	if _, err := d.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS "+t.TableName+"_unerased_time_index ON "+t.TableName+" (time_ms) WHERE value <> '';"); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *msgModelTable) GetExpiredByChat(ctx context.Context, d sqldb.Executor, chatid string, before int64, limit int) (_ []string, retErr error) {
+	res := make([]string, 0, limit)
+	// erased msgs have an empty value and are skipped
+	rows, err := d.QueryContext(ctx, "SELECT msgid FROM "+t.TableName+" WHERE chatid = $1 AND time_ms < $2 AND value <> '' ORDER BY msgid LIMIT $3;", chatid, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			retErr = errors.Join(retErr, kerrors.WithMsg(err, "Failed to close db rows"))
+		}
+	}()
+	for rows.Next() {
+		var m string
+		if err := rows.Scan(&m); err != nil {
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetExpiredMsgs returns msgs of a chat sent before a time in milliseconds
+// that have not yet been erased
+func (r *repo) GetExpiredMsgs(ctx context.Context, chatid string, before int64, limit int) ([]string, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.table.GetExpiredByChat(ctx, d, chatid, before, limit)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get expired chat msgs")
+	}
+	return m, nil
+}
+
+func (t *msgModelTable) GetExpiredWithoutRetention(ctx context.Context, d sqldb.Executor, tableRetention string, before int64, limit int) (_ []MsgRef, retErr error) {
+	res := make([]MsgRef, 0, limit)
+	rows, err := d.QueryContext(ctx, "SELECT m.chatid, m.msgid FROM "+t.TableName+" m WHERE m.time_ms < $1 AND m.value <> '' AND NOT EXISTS (SELECT 1 FROM "+tableRetention+" r WHERE r.chatid = m.chatid) ORDER BY m.time_ms LIMIT $2;", before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			retErr = errors.Join(retErr, kerrors.WithMsg(err, "Failed to close db rows"))
+		}
+	}()
+	for rows.Next() {
+		var m MsgRef
+		if err := rows.Scan(&m.Chatid, &m.Msgid); err != nil {
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetDefaultExpiredMsgs returns msgs sent before a time in milliseconds that
+// have not yet been erased, of chats without a retention policy
+func (r *repo) GetDefaultExpiredMsgs(ctx context.Context, before int64, limit int) ([]MsgRef, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.table.GetExpiredWithoutRetention(ctx, d, r.tableRetention.TableName, before, limit)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get expired msgs")
+	}
+	return m, nil
+}
+
 func (r *repo) Setup(ctx context.Context) error {
 	d, err := r.db.DB(ctx)
 	if err != nil {
@@ -140,5 +773,26 @@ func (r *repo) Setup(ctx context.Context) error {
 	if err := r.table.Setup(ctx, d); err != nil {
 		return kerrors.WithMsg(err, "Failed to setup chat msg model")
 	}
+	if err := r.table.SetupExpiryIndex(ctx, d); err != nil {
+		return kerrors.WithMsg(err, "Failed to setup chat msg expiry index")
+	}
+	if err := r.tableSearch.Setup(ctx, d); err != nil {
+		return kerrors.WithMsg(err, "Failed to setup chat msg search model")
+	}
+	if err := r.tableSearch.SetupSearchIndex(ctx, d); err != nil {
+		return kerrors.WithMsg(err, "Failed to setup chat msg search index")
+	}
+	if err := r.tableEdit.Setup(ctx, d); err != nil {
+		return kerrors.WithMsg(err, "Failed to setup chat msg edit model")
+	}
+	if err := r.tableReaction.Setup(ctx, d); err != nil {
+		return kerrors.WithMsg(err, "Failed to setup chat msg reaction model")
+	}
+	if err := r.tableRead.Setup(ctx, d); err != nil {
+		return kerrors.WithMsg(err, "Failed to setup chat read cursor model")
+	}
+	if err := r.tableRetention.Setup(ctx, d); err != nil {
+		return kerrors.WithMsg(err, "Failed to setup chat retention model")
+	}
 	return nil
 }
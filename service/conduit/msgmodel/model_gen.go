@@ -18,7 +18,7 @@ type (
 )
 
 func (t *msgModelTable) Setup(ctx context.Context, d sqldb.Executor) error {
-	_, err := d.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+t.TableName+" (chatid VARCHAR(31), msgid VARCHAR(31), userid VARCHAR(31) NOT NULL, time_ms BIGINT NOT NULL, kind VARCHAR(31) NOT NULL, value VARCHAR(4095) NOT NULL, PRIMARY KEY (chatid, msgid));")
+	_, err := d.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+t.TableName+" (chatid VARCHAR(31), msgid VARCHAR(31), userid VARCHAR(31) NOT NULL, time_ms BIGINT NOT NULL, kind VARCHAR(31) NOT NULL, value VARCHAR(4095) NOT NULL, parentid VARCHAR(31) NOT NULL, edit_time_ms BIGINT NOT NULL, author_kind VARCHAR(31) NOT NULL, author_name VARCHAR(127) NOT NULL, author_avatar VARCHAR(4095) NOT NULL, PRIMARY KEY (chatid, msgid));")
 	if err != nil {
 		return err
 	}
@@ -26,11 +26,15 @@ func (t *msgModelTable) Setup(ctx context.Context, d sqldb.Executor) error {
 	if err != nil {
 		return err
 	}
+	_, err = d.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS "+t.TableName+"_chat_parent_msg_index ON "+t.TableName+" (chatid, parentid, msgid);")
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
 func (t *msgModelTable) Insert(ctx context.Context, d sqldb.Executor, m *Model) error {
-	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (chatid, msgid, userid, time_ms, kind, value) VALUES ($1, $2, $3, $4, $5, $6);", m.Chatid, m.Msgid, m.Userid, m.Timems, m.Kind, m.Value)
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (chatid, msgid, userid, time_ms, kind, value, parentid, edit_time_ms, author_kind, author_name, author_avatar) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11);", m.Chatid, m.Msgid, m.Userid, m.Timems, m.Kind, m.Value, m.Parentid, m.Edittimems, m.AuthorKind, m.AuthorName, m.AuthorAvatar)
 	if err != nil {
 		return err
 	}
@@ -43,13 +47,13 @@ func (t *msgModelTable) InsertBulk(ctx context.Context, d sqldb.Executor, models
 		conflictSQL = " ON CONFLICT DO NOTHING"
 	}
 	placeholders := make([]string, 0, len(models))
-	args := make([]interface{}, 0, len(models)*6)
+	args := make([]interface{}, 0, len(models)*11)
 	for c, m := range models {
-		n := c * 6
-		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6))
-		args = append(args, m.Chatid, m.Msgid, m.Userid, m.Timems, m.Kind, m.Value)
+		n := c * 11
+		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9, n+10, n+11))
+		args = append(args, m.Chatid, m.Msgid, m.Userid, m.Timems, m.Kind, m.Value, m.Parentid, m.Edittimems, m.AuthorKind, m.AuthorName, m.AuthorAvatar)
 	}
-	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (chatid, msgid, userid, time_ms, kind, value) VALUES "+strings.Join(placeholders, ", ")+conflictSQL+";", args...)
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (chatid, msgid, userid, time_ms, kind, value, parentid, edit_time_ms, author_kind, author_name, author_avatar) VALUES "+strings.Join(placeholders, ", ")+conflictSQL+";", args...)
 	if err != nil {
 		return err
 	}
@@ -58,7 +62,7 @@ func (t *msgModelTable) InsertBulk(ctx context.Context, d sqldb.Executor, models
 
 func (t *msgModelTable) GetModelByChat(ctx context.Context, d sqldb.Executor, chatid string, limit, offset int) (_ []Model, retErr error) {
 	res := make([]Model, 0, limit)
-	rows, err := d.QueryContext(ctx, "SELECT chatid, msgid, userid, time_ms, kind, value FROM "+t.TableName+" WHERE chatid = $3 ORDER BY msgid DESC LIMIT $1 OFFSET $2;", limit, offset, chatid)
+	rows, err := d.QueryContext(ctx, "SELECT chatid, msgid, userid, time_ms, kind, value, parentid, edit_time_ms, author_kind, author_name, author_avatar FROM "+t.TableName+" WHERE chatid = $3 ORDER BY msgid DESC LIMIT $1 OFFSET $2;", limit, offset, chatid)
 	if err != nil {
 		return nil, err
 	}
@@ -69,7 +73,7 @@ func (t *msgModelTable) GetModelByChat(ctx context.Context, d sqldb.Executor, ch
 	}()
 	for rows.Next() {
 		var m Model
-		if err := rows.Scan(&m.Chatid, &m.Msgid, &m.Userid, &m.Timems, &m.Kind, &m.Value); err != nil {
+		if err := rows.Scan(&m.Chatid, &m.Msgid, &m.Userid, &m.Timems, &m.Kind, &m.Value, &m.Parentid, &m.Edittimems, &m.AuthorKind, &m.AuthorName, &m.AuthorAvatar); err != nil {
 			return nil, err
 		}
 		res = append(res, m)
@@ -82,7 +86,7 @@ func (t *msgModelTable) GetModelByChat(ctx context.Context, d sqldb.Executor, ch
 
 func (t *msgModelTable) GetModelByChatBeforeMsg(ctx context.Context, d sqldb.Executor, chatid string, msgid string, limit, offset int) (_ []Model, retErr error) {
 	res := make([]Model, 0, limit)
-	rows, err := d.QueryContext(ctx, "SELECT chatid, msgid, userid, time_ms, kind, value FROM "+t.TableName+" WHERE chatid = $3 AND msgid < $4 ORDER BY msgid DESC LIMIT $1 OFFSET $2;", limit, offset, chatid, msgid)
+	rows, err := d.QueryContext(ctx, "SELECT chatid, msgid, userid, time_ms, kind, value, parentid, edit_time_ms, author_kind, author_name, author_avatar FROM "+t.TableName+" WHERE chatid = $3 AND msgid < $4 ORDER BY msgid DESC LIMIT $1 OFFSET $2;", limit, offset, chatid, msgid)
 	if err != nil {
 		return nil, err
 	}
@@ -93,7 +97,7 @@ func (t *msgModelTable) GetModelByChatBeforeMsg(ctx context.Context, d sqldb.Exe
 	}()
 	for rows.Next() {
 		var m Model
-		if err := rows.Scan(&m.Chatid, &m.Msgid, &m.Userid, &m.Timems, &m.Kind, &m.Value); err != nil {
+		if err := rows.Scan(&m.Chatid, &m.Msgid, &m.Userid, &m.Timems, &m.Kind, &m.Value, &m.Parentid, &m.Edittimems, &m.AuthorKind, &m.AuthorName, &m.AuthorAvatar); err != nil {
 			return nil, err
 		}
 		res = append(res, m)
@@ -106,7 +110,7 @@ func (t *msgModelTable) GetModelByChatBeforeMsg(ctx context.Context, d sqldb.Exe
 
 func (t *msgModelTable) GetModelByChatKind(ctx context.Context, d sqldb.Executor, chatid string, kind string, limit, offset int) (_ []Model, retErr error) {
 	res := make([]Model, 0, limit)
-	rows, err := d.QueryContext(ctx, "SELECT chatid, msgid, userid, time_ms, kind, value FROM "+t.TableName+" WHERE chatid = $3 AND kind = $4 ORDER BY msgid DESC LIMIT $1 OFFSET $2;", limit, offset, chatid, kind)
+	rows, err := d.QueryContext(ctx, "SELECT chatid, msgid, userid, time_ms, kind, value, parentid, edit_time_ms, author_kind, author_name, author_avatar FROM "+t.TableName+" WHERE chatid = $3 AND kind = $4 ORDER BY msgid DESC LIMIT $1 OFFSET $2;", limit, offset, chatid, kind)
 	if err != nil {
 		return nil, err
 	}
@@ -117,7 +121,7 @@ func (t *msgModelTable) GetModelByChatKind(ctx context.Context, d sqldb.Executor
 	}()
 	for rows.Next() {
 		var m Model
-		if err := rows.Scan(&m.Chatid, &m.Msgid, &m.Userid, &m.Timems, &m.Kind, &m.Value); err != nil {
+		if err := rows.Scan(&m.Chatid, &m.Msgid, &m.Userid, &m.Timems, &m.Kind, &m.Value, &m.Parentid, &m.Edittimems, &m.AuthorKind, &m.AuthorName, &m.AuthorAvatar); err != nil {
 			return nil, err
 		}
 		res = append(res, m)
@@ -130,7 +134,7 @@ func (t *msgModelTable) GetModelByChatKind(ctx context.Context, d sqldb.Executor
 
 func (t *msgModelTable) GetModelByChatKindBeforeMsg(ctx context.Context, d sqldb.Executor, chatid string, kind string, msgid string, limit, offset int) (_ []Model, retErr error) {
 	res := make([]Model, 0, limit)
-	rows, err := d.QueryContext(ctx, "SELECT chatid, msgid, userid, time_ms, kind, value FROM "+t.TableName+" WHERE chatid = $3 AND kind = $4 AND msgid < $5 ORDER BY msgid DESC LIMIT $1 OFFSET $2;", limit, offset, chatid, kind, msgid)
+	rows, err := d.QueryContext(ctx, "SELECT chatid, msgid, userid, time_ms, kind, value, parentid, edit_time_ms, author_kind, author_name, author_avatar FROM "+t.TableName+" WHERE chatid = $3 AND kind = $4 AND msgid < $5 ORDER BY msgid DESC LIMIT $1 OFFSET $2;", limit, offset, chatid, kind, msgid)
 	if err != nil {
 		return nil, err
 	}
@@ -141,7 +145,7 @@ func (t *msgModelTable) GetModelByChatKindBeforeMsg(ctx context.Context, d sqldb
 	}()
 	for rows.Next() {
 		var m Model
-		if err := rows.Scan(&m.Chatid, &m.Msgid, &m.Userid, &m.Timems, &m.Kind, &m.Value); err != nil {
+		if err := rows.Scan(&m.Chatid, &m.Msgid, &m.Userid, &m.Timems, &m.Kind, &m.Value, &m.Parentid, &m.Edittimems, &m.AuthorKind, &m.AuthorName, &m.AuthorAvatar); err != nil {
 			return nil, err
 		}
 		res = append(res, m)
@@ -157,6 +161,62 @@ func (t *msgModelTable) DelByChat(ctx context.Context, d sqldb.Executor, chatid
 	return err
 }
 
+func (t *msgModelTable) GetModelByChatMsg(ctx context.Context, d sqldb.Executor, chatid string, msgid string) (*Model, error) {
+	m := &Model{}
+	if err := d.QueryRowContext(ctx, "SELECT chatid, msgid, userid, time_ms, kind, value, parentid, edit_time_ms, author_kind, author_name, author_avatar FROM "+t.TableName+" WHERE chatid = $1 AND msgid = $2;", chatid, msgid).Scan(&m.Chatid, &m.Msgid, &m.Userid, &m.Timems, &m.Kind, &m.Value, &m.Parentid, &m.Edittimems, &m.AuthorKind, &m.AuthorName, &m.AuthorAvatar); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (t *msgModelTable) GetModelByChatParent(ctx context.Context, d sqldb.Executor, chatid string, parentid string, limit, offset int) (_ []Model, retErr error) {
+	res := make([]Model, 0, limit)
+	rows, err := d.QueryContext(ctx, "SELECT chatid, msgid, userid, time_ms, kind, value, parentid, edit_time_ms, author_kind, author_name, author_avatar FROM "+t.TableName+" WHERE chatid = $3 AND parentid = $4 ORDER BY msgid DESC LIMIT $1 OFFSET $2;", limit, offset, chatid, parentid)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("Failed to close db rows: %w", err))
+		}
+	}()
+	for rows.Next() {
+		var m Model
+		if err := rows.Scan(&m.Chatid, &m.Msgid, &m.Userid, &m.Timems, &m.Kind, &m.Value, &m.Parentid, &m.Edittimems, &m.AuthorKind, &m.AuthorName, &m.AuthorAvatar); err != nil {
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (t *msgModelTable) GetModelByChatParentBeforeMsg(ctx context.Context, d sqldb.Executor, chatid string, parentid string, msgid string, limit, offset int) (_ []Model, retErr error) {
+	res := make([]Model, 0, limit)
+	rows, err := d.QueryContext(ctx, "SELECT chatid, msgid, userid, time_ms, kind, value, parentid, edit_time_ms, author_kind, author_name, author_avatar FROM "+t.TableName+" WHERE chatid = $3 AND parentid = $4 AND msgid < $5 ORDER BY msgid DESC LIMIT $1 OFFSET $2;", limit, offset, chatid, parentid, msgid)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("Failed to close db rows: %w", err))
+		}
+	}()
+	for rows.Next() {
+		var m Model
+		if err := rows.Scan(&m.Chatid, &m.Msgid, &m.Userid, &m.Timems, &m.Kind, &m.Value, &m.Parentid, &m.Edittimems, &m.AuthorKind, &m.AuthorName, &m.AuthorAvatar); err != nil {
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
 func (t *msgModelTable) UpdmsgValueByChatMsgs(ctx context.Context, d sqldb.Executor, m *msgValue, chatid string, msgids []string) error {
 	paramCount := 2
 	args := make([]interface{}, 0, paramCount+len(msgids))
@@ -177,3 +237,384 @@ func (t *msgModelTable) UpdmsgValueByChatMsgs(ctx context.Context, d sqldb.Execu
 	}
 	return nil
 }
+
+func (t *msgModelTable) UpdmsgEditByChatMsg(ctx context.Context, d sqldb.Executor, m *msgEdit, chatid string, msgid string) error {
+	_, err := d.ExecContext(ctx, "UPDATE "+t.TableName+" SET (value, edit_time_ms) = ($1, $2) WHERE chatid = $3 AND msgid = $4;", m.Value, m.Edittimems, chatid, msgid)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+type (
+	searchModelTable struct {
+		TableName string
+	}
+)
+
+func (t *searchModelTable) Setup(ctx context.Context, d sqldb.Executor) error {
+	_, err := d.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+t.TableName+" (chatid VARCHAR(31), msgid VARCHAR(31), userid VARCHAR(31) NOT NULL, time_ms BIGINT NOT NULL, value VARCHAR(4095) NOT NULL, PRIMARY KEY (chatid, msgid));")
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *searchModelTable) Insert(ctx context.Context, d sqldb.Executor, m *SearchModel) error {
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (chatid, msgid, userid, time_ms, value) VALUES ($1, $2, $3, $4, $5);", m.Chatid, m.Msgid, m.Userid, m.Timems, m.Value)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *searchModelTable) InsertBulk(ctx context.Context, d sqldb.Executor, models []*SearchModel, allowConflict bool) error {
+	conflictSQL := ""
+	if allowConflict {
+		conflictSQL = " ON CONFLICT DO NOTHING"
+	}
+	placeholders := make([]string, 0, len(models))
+	args := make([]interface{}, 0, len(models)*5)
+	for c, m := range models {
+		n := c * 5
+		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5))
+		args = append(args, m.Chatid, m.Msgid, m.Userid, m.Timems, m.Value)
+	}
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (chatid, msgid, userid, time_ms, value) VALUES "+strings.Join(placeholders, ", ")+conflictSQL+";", args...)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *searchModelTable) DelByChat(ctx context.Context, d sqldb.Executor, chatid string) error {
+	_, err := d.ExecContext(ctx, "DELETE FROM "+t.TableName+" WHERE chatid = $1;", chatid)
+	return err
+}
+
+func (t *searchModelTable) DelByChatMsgs(ctx context.Context, d sqldb.Executor, chatid string, msgids []string) error {
+	paramCount := 1
+	args := make([]interface{}, 0, paramCount+len(msgids))
+	args = append(args, chatid)
+	var placeholdersmsgids string
+	{
+		placeholders := make([]string, 0, len(msgids))
+		for _, i := range msgids {
+			paramCount++
+			placeholders = append(placeholders, fmt.Sprintf("($%d)", paramCount))
+			args = append(args, i)
+		}
+		placeholdersmsgids = strings.Join(placeholders, ", ")
+	}
+	_, err := d.ExecContext(ctx, "DELETE FROM "+t.TableName+" WHERE chatid = $1 AND msgid IN (VALUES "+placeholdersmsgids+");", args...)
+	return err
+}
+
+type (
+	editModelTable struct {
+		TableName string
+	}
+)
+
+func (t *editModelTable) Setup(ctx context.Context, d sqldb.Executor) error {
+	_, err := d.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+t.TableName+" (chatid VARCHAR(31), msgid VARCHAR(31), time_ms BIGINT, value VARCHAR(4095) NOT NULL, PRIMARY KEY (chatid, msgid, time_ms));")
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *editModelTable) Insert(ctx context.Context, d sqldb.Executor, m *EditModel) error {
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (chatid, msgid, time_ms, value) VALUES ($1, $2, $3, $4);", m.Chatid, m.Msgid, m.Timems, m.Value)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *editModelTable) InsertBulk(ctx context.Context, d sqldb.Executor, models []*EditModel, allowConflict bool) error {
+	conflictSQL := ""
+	if allowConflict {
+		conflictSQL = " ON CONFLICT DO NOTHING"
+	}
+	placeholders := make([]string, 0, len(models))
+	args := make([]interface{}, 0, len(models)*4)
+	for c, m := range models {
+		n := c * 4
+		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4))
+		args = append(args, m.Chatid, m.Msgid, m.Timems, m.Value)
+	}
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (chatid, msgid, time_ms, value) VALUES "+strings.Join(placeholders, ", ")+conflictSQL+";", args...)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *editModelTable) GetEditModelByChatMsg(ctx context.Context, d sqldb.Executor, chatid string, msgid string, limit, offset int) (_ []EditModel, retErr error) {
+	res := make([]EditModel, 0, limit)
+	rows, err := d.QueryContext(ctx, "SELECT chatid, msgid, time_ms, value FROM "+t.TableName+" WHERE chatid = $3 AND msgid = $4 ORDER BY time_ms DESC LIMIT $1 OFFSET $2;", limit, offset, chatid, msgid)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("Failed to close db rows: %w", err))
+		}
+	}()
+	for rows.Next() {
+		var m EditModel
+		if err := rows.Scan(&m.Chatid, &m.Msgid, &m.Timems, &m.Value); err != nil {
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (t *editModelTable) DelByChat(ctx context.Context, d sqldb.Executor, chatid string) error {
+	_, err := d.ExecContext(ctx, "DELETE FROM "+t.TableName+" WHERE chatid = $1;", chatid)
+	return err
+}
+
+func (t *editModelTable) DelByChatMsgs(ctx context.Context, d sqldb.Executor, chatid string, msgids []string) error {
+	paramCount := 1
+	args := make([]interface{}, 0, paramCount+len(msgids))
+	args = append(args, chatid)
+	var placeholdersmsgids string
+	{
+		placeholders := make([]string, 0, len(msgids))
+		for _, i := range msgids {
+			paramCount++
+			placeholders = append(placeholders, fmt.Sprintf("($%d)", paramCount))
+			args = append(args, i)
+		}
+		placeholdersmsgids = strings.Join(placeholders, ", ")
+	}
+	_, err := d.ExecContext(ctx, "DELETE FROM "+t.TableName+" WHERE chatid = $1 AND msgid IN (VALUES "+placeholdersmsgids+");", args...)
+	return err
+}
+
+type (
+	reactionModelTable struct {
+		TableName string
+	}
+)
+
+func (t *reactionModelTable) Setup(ctx context.Context, d sqldb.Executor) error {
+	_, err := d.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+t.TableName+" (chatid VARCHAR(31), msgid VARCHAR(31), reaction VARCHAR(63), userid VARCHAR(31), time_ms BIGINT NOT NULL, PRIMARY KEY (chatid, msgid, reaction, userid));")
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *reactionModelTable) Insert(ctx context.Context, d sqldb.Executor, m *ReactionModel) error {
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (chatid, msgid, reaction, userid, time_ms) VALUES ($1, $2, $3, $4, $5);", m.Chatid, m.Msgid, m.Reaction, m.Userid, m.Timems)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *reactionModelTable) InsertBulk(ctx context.Context, d sqldb.Executor, models []*ReactionModel, allowConflict bool) error {
+	conflictSQL := ""
+	if allowConflict {
+		conflictSQL = " ON CONFLICT DO NOTHING"
+	}
+	placeholders := make([]string, 0, len(models))
+	args := make([]interface{}, 0, len(models)*5)
+	for c, m := range models {
+		n := c * 5
+		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5))
+		args = append(args, m.Chatid, m.Msgid, m.Reaction, m.Userid, m.Timems)
+	}
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (chatid, msgid, reaction, userid, time_ms) VALUES "+strings.Join(placeholders, ", ")+conflictSQL+";", args...)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *reactionModelTable) DelByChatMsgReactionUser(ctx context.Context, d sqldb.Executor, chatid string, msgid string, reaction string, userid string) error {
+	_, err := d.ExecContext(ctx, "DELETE FROM "+t.TableName+" WHERE chatid = $1 AND msgid = $2 AND reaction = $3 AND userid = $4;", chatid, msgid, reaction, userid)
+	return err
+}
+
+func (t *reactionModelTable) DelByChat(ctx context.Context, d sqldb.Executor, chatid string) error {
+	_, err := d.ExecContext(ctx, "DELETE FROM "+t.TableName+" WHERE chatid = $1;", chatid)
+	return err
+}
+
+func (t *reactionModelTable) DelByChatMsgs(ctx context.Context, d sqldb.Executor, chatid string, msgids []string) error {
+	paramCount := 1
+	args := make([]interface{}, 0, paramCount+len(msgids))
+	args = append(args, chatid)
+	var placeholdersmsgids string
+	{
+		placeholders := make([]string, 0, len(msgids))
+		for _, i := range msgids {
+			paramCount++
+			placeholders = append(placeholders, fmt.Sprintf("($%d)", paramCount))
+			args = append(args, i)
+		}
+		placeholdersmsgids = strings.Join(placeholders, ", ")
+	}
+	_, err := d.ExecContext(ctx, "DELETE FROM "+t.TableName+" WHERE chatid = $1 AND msgid IN (VALUES "+placeholdersmsgids+");", args...)
+	return err
+}
+
+type (
+	readModelTable struct {
+		TableName string
+	}
+)
+
+func (t *readModelTable) Setup(ctx context.Context, d sqldb.Executor) error {
+	_, err := d.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+t.TableName+" (chatid VARCHAR(31), userid VARCHAR(31), msgid VARCHAR(31) NOT NULL, time_ms BIGINT NOT NULL, PRIMARY KEY (chatid, userid));")
+	if err != nil {
+		return err
+	}
+	_, err = d.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS "+t.TableName+"_chat_msg_index ON "+t.TableName+" (chatid, msgid);")
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *readModelTable) Insert(ctx context.Context, d sqldb.Executor, m *ReadModel) error {
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (chatid, userid, msgid, time_ms) VALUES ($1, $2, $3, $4);", m.Chatid, m.Userid, m.Msgid, m.Timems)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *readModelTable) InsertBulk(ctx context.Context, d sqldb.Executor, models []*ReadModel, allowConflict bool) error {
+	conflictSQL := ""
+	if allowConflict {
+		conflictSQL = " ON CONFLICT DO NOTHING"
+	}
+	placeholders := make([]string, 0, len(models))
+	args := make([]interface{}, 0, len(models)*4)
+	for c, m := range models {
+		n := c * 4
+		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4))
+		args = append(args, m.Chatid, m.Userid, m.Msgid, m.Timems)
+	}
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (chatid, userid, msgid, time_ms) VALUES "+strings.Join(placeholders, ", ")+conflictSQL+";", args...)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *readModelTable) GetReadModelByChat(ctx context.Context, d sqldb.Executor, chatid string, limit, offset int) (_ []ReadModel, retErr error) {
+	res := make([]ReadModel, 0, limit)
+	rows, err := d.QueryContext(ctx, "SELECT chatid, userid, msgid, time_ms FROM "+t.TableName+" WHERE chatid = $3 ORDER BY msgid DESC LIMIT $1 OFFSET $2;", limit, offset, chatid)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("Failed to close db rows: %w", err))
+		}
+	}()
+	for rows.Next() {
+		var m ReadModel
+		if err := rows.Scan(&m.Chatid, &m.Userid, &m.Msgid, &m.Timems); err != nil {
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (t *readModelTable) DelByChat(ctx context.Context, d sqldb.Executor, chatid string) error {
+	_, err := d.ExecContext(ctx, "DELETE FROM "+t.TableName+" WHERE chatid = $1;", chatid)
+	return err
+}
+
+type (
+	retentionModelTable struct {
+		TableName string
+	}
+)
+
+func (t *retentionModelTable) Setup(ctx context.Context, d sqldb.Executor) error {
+	_, err := d.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+t.TableName+" (chatid VARCHAR(31), duration BIGINT NOT NULL, last_updated BIGINT NOT NULL, PRIMARY KEY (chatid));")
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *retentionModelTable) Insert(ctx context.Context, d sqldb.Executor, m *RetentionModel) error {
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (chatid, duration, last_updated) VALUES ($1, $2, $3);", m.Chatid, m.Duration, m.LastUpdated)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *retentionModelTable) InsertBulk(ctx context.Context, d sqldb.Executor, models []*RetentionModel, allowConflict bool) error {
+	conflictSQL := ""
+	if allowConflict {
+		conflictSQL = " ON CONFLICT DO NOTHING"
+	}
+	placeholders := make([]string, 0, len(models))
+	args := make([]interface{}, 0, len(models)*3)
+	for c, m := range models {
+		n := c * 3
+		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d)", n+1, n+2, n+3))
+		args = append(args, m.Chatid, m.Duration, m.LastUpdated)
+	}
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (chatid, duration, last_updated) VALUES "+strings.Join(placeholders, ", ")+conflictSQL+";", args...)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *retentionModelTable) GetRetentionModelByChat(ctx context.Context, d sqldb.Executor, chatid string) (*RetentionModel, error) {
+	m := &RetentionModel{}
+	if err := d.QueryRowContext(ctx, "SELECT chatid, duration, last_updated FROM "+t.TableName+" WHERE chatid = $1;", chatid).Scan(&m.Chatid, &m.Duration, &m.LastUpdated); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (t *retentionModelTable) GetRetentionModelAll(ctx context.Context, d sqldb.Executor, limit, offset int) (_ []RetentionModel, retErr error) {
+	res := make([]RetentionModel, 0, limit)
+	rows, err := d.QueryContext(ctx, "SELECT chatid, duration, last_updated FROM "+t.TableName+" ORDER BY chatid LIMIT $1 OFFSET $2;", limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("Failed to close db rows: %w", err))
+		}
+	}()
+	for rows.Next() {
+		var m RetentionModel
+		if err := rows.Scan(&m.Chatid, &m.Duration, &m.LastUpdated); err != nil {
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (t *retentionModelTable) DelByChat(ctx context.Context, d sqldb.Executor, chatid string) error {
+	_, err := d.ExecContext(ctx, "DELETE FROM "+t.TableName+" WHERE chatid = $1;", chatid)
+	return err
+}
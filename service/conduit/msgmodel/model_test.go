@@ -0,0 +1,150 @@
+package msgmodel_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"xorkevin.dev/governor/service/conduit/msgmodel"
+	"xorkevin.dev/governor/service/dbsql/dbsqltest"
+	"xorkevin.dev/governor/util/uid"
+)
+
+func TestRepo(t *testing.T) {
+	if testing.Short() {
+		t.Skip("relies on db")
+	}
+
+	t.Parallel()
+
+	assert := require.New(t)
+
+	db := dbsqltest.NewStatic(t)
+	msgs := msgmodel.New(db, "conduitmsgs", "conduitmsgsearch", "conduitmsgedits", "conduitmsgreactions", "conduitmsgreads", "conduitmsgretention")
+
+	ctx := context.Background()
+	assert.NoError(msgs.Setup(ctx))
+
+	newChatid := func(t *testing.T) string {
+		t.Helper()
+		u, err := uid.New()
+		require.NoError(t, err)
+		return u.Base64()
+	}
+
+	newMsg := func(t *testing.T, chatid string, userid string, value string, parentid string) *msgmodel.Model {
+		t.Helper()
+		m, err := msgs.New(chatid, userid, "t", value, parentid)
+		require.NoError(t, err)
+		require.NoError(t, msgs.Insert(ctx, m))
+		return m
+	}
+
+	t.Run("EditMsg", func(t *testing.T) {
+		t.Parallel()
+
+		assert := require.New(t)
+
+		chatid := newChatid(t)
+		m := newMsg(t, chatid, "user1", "first", "")
+
+		edits, err := msgs.GetMsgEdits(ctx, chatid, m.Msgid, 8, 0)
+		assert.NoError(err)
+		assert.Len(edits, 0)
+
+		assert.NoError(msgs.EditMsg(ctx, m, "second"))
+		assert.Equal("second", m.Value)
+		assert.NotZero(m.Edittimems)
+		assert.NoError(msgs.EditMsg(ctx, m, "third"))
+
+		res, err := msgs.GetMsg(ctx, chatid, m.Msgid)
+		assert.NoError(err)
+		assert.Equal("third", res.Value)
+		assert.Equal(m.Edittimems, res.Edittimems)
+
+		// edit history holds the previous values of the msg
+		edits, err = msgs.GetMsgEdits(ctx, chatid, m.Msgid, 8, 0)
+		assert.NoError(err)
+		values := make([]string, 0, len(edits))
+		for _, i := range edits {
+			assert.Equal(chatid, i.Chatid)
+			assert.Equal(m.Msgid, i.Msgid)
+			values = append(values, i.Value)
+		}
+		assert.ElementsMatch([]string{"first", "second"}, values)
+	})
+
+	t.Run("ToggleReaction", func(t *testing.T) {
+		t.Parallel()
+
+		assert := require.New(t)
+
+		chatid := newChatid(t)
+		m := newMsg(t, chatid, "user1", "hello", "")
+
+		for _, tc := range []struct {
+			Userid   string
+			Reaction string
+			Added    bool
+		}{
+			{Userid: "user1", Reaction: "+1", Added: true},
+			{Userid: "user2", Reaction: "+1", Added: true},
+			{Userid: "user2", Reaction: "heart", Added: true},
+			{Userid: "user2", Reaction: "+1", Added: false},
+		} {
+			added, err := msgs.ToggleReaction(ctx, chatid, m.Msgid, tc.Reaction, tc.Userid)
+			assert.NoError(err)
+			assert.Equal(tc.Added, added)
+		}
+
+		reactions, err := msgs.GetReactions(ctx, chatid, []string{m.Msgid}, "user2")
+		assert.NoError(err)
+		assert.ElementsMatch([]msgmodel.ReactionCount{
+			{Msgid: m.Msgid, Reaction: "+1", Count: 1, Reacted: false},
+			{Msgid: m.Msgid, Reaction: "heart", Count: 1, Reacted: true},
+		}, reactions)
+
+		// toggling again adds the reaction back
+		added, err := msgs.ToggleReaction(ctx, chatid, m.Msgid, "+1", "user2")
+		assert.NoError(err)
+		assert.True(added)
+
+		reactions, err = msgs.GetReactions(ctx, chatid, []string{m.Msgid}, "user2")
+		assert.NoError(err)
+		assert.ElementsMatch([]msgmodel.ReactionCount{
+			{Msgid: m.Msgid, Reaction: "+1", Count: 2, Reacted: true},
+			{Msgid: m.Msgid, Reaction: "heart", Count: 1, Reacted: true},
+		}, reactions)
+	})
+
+	t.Run("GetThreadMsgs", func(t *testing.T) {
+		t.Parallel()
+
+		assert := require.New(t)
+
+		chatid := newChatid(t)
+		root := newMsg(t, chatid, "user1", "root", "")
+		reply1 := newMsg(t, chatid, "user2", "reply1", root.Msgid)
+		reply2 := newMsg(t, chatid, "user1", "reply2", root.Msgid)
+		other := newMsg(t, chatid, "user2", "other", "")
+
+		// a thread consists only of the direct replies to its root
+		thread, err := msgs.GetThreadMsgs(ctx, chatid, root.Msgid, "", 8)
+		assert.NoError(err)
+		msgids := make([]string, 0, len(thread))
+		for _, i := range thread {
+			assert.Equal(root.Msgid, i.Parentid)
+			msgids = append(msgids, i.Msgid)
+		}
+		assert.ElementsMatch([]string{reply1.Msgid, reply2.Msgid}, msgids)
+
+		thread, err = msgs.GetThreadMsgs(ctx, chatid, other.Msgid, "", 8)
+		assert.NoError(err)
+		assert.Len(thread, 0)
+
+		// thread replies are still listed in the chat
+		all, err := msgs.GetMsgs(ctx, chatid, "", "", 8)
+		assert.NoError(err)
+		assert.Len(all, 4)
+	})
+}
@@ -1,12 +1,16 @@
 package conduit
 
 import (
+	"mime"
 	"net/http"
 	"strings"
 
 	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/fileloader"
+	"xorkevin.dev/governor/service/image"
 	"xorkevin.dev/governor/service/user/gate"
 	"xorkevin.dev/governor/util/rank"
+	"xorkevin.dev/kerrors"
 )
 
 type (
@@ -206,6 +210,73 @@ func (s *router) getInvited(c *governor.Context) {
 	c.WriteJSON(http.StatusOK, res)
 }
 
+type (
+	//forge:valid
+	reqGetBlocked struct {
+		Userid string `valid:"userid,has" json:"-"`
+		Amount int    `valid:"amount" json:"-"`
+		Offset int    `valid:"offset" json:"-"`
+	}
+)
+
+func (s *router) getBlocked(c *governor.Context) {
+	req := reqGetBlocked{
+		Userid: gate.GetCtxUserid(c),
+		Amount: c.QueryInt("amount", -1),
+		Offset: c.QueryInt("offset", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getBlocked(c.Ctx(), req.Userid, req.Amount, req.Offset)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqBlockUser struct {
+		Userid string `valid:"userid,has" json:"-"`
+		Target string `valid:"userid,has" json:"-"`
+	}
+)
+
+func (s *router) blockUser(c *governor.Context) {
+	req := reqBlockUser{
+		Userid: gate.GetCtxUserid(c),
+		Target: c.Param("id"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	if err := s.s.blockUser(c.Ctx(), req.Userid, req.Target); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteStatus(http.StatusNoContent)
+}
+
+func (s *router) unblockUser(c *governor.Context) {
+	req := reqBlockUser{
+		Userid: gate.GetCtxUserid(c),
+		Target: c.Param("id"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	if err := s.s.unblockUser(c.Ctx(), req.Userid, req.Target); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteStatus(http.StatusNoContent)
+}
+
 type (
 	//forge:valid
 	reqGetLatestChats struct {
@@ -308,10 +379,11 @@ func (s *router) updateDM(c *governor.Context) {
 type (
 	//forge:valid
 	reqCreateMsg struct {
-		Userid string `valid:"userid,has" json:"-"`
-		Chatid string `valid:"chatid,has" json:"-"`
-		Kind   string `valid:"msgkind" json:"kind"`
-		Value  string `valid:"msgvalue" json:"value"`
+		Userid   string `valid:"userid,has" json:"-"`
+		Chatid   string `valid:"chatid,has" json:"-"`
+		Kind     string `valid:"msgkind" json:"kind"`
+		Value    string `valid:"msgvalue" json:"value"`
+		Parentid string `valid:"msgid,opt" json:"parentid"`
 	}
 )
 
@@ -327,7 +399,7 @@ func (s *router) createDMMsg(c *governor.Context) {
 		c.WriteError(err)
 		return
 	}
-	res, err := s.s.createDMMsg(c.Ctx(), req.Userid, req.Chatid, req.Kind, req.Value)
+	res, err := s.s.createDMMsg(c.Ctx(), req.Userid, req.Chatid, req.Kind, req.Value, req.Parentid)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -366,6 +438,37 @@ func (s *router) getDMMsgs(c *governor.Context) {
 	c.WriteJSON(http.StatusOK, res)
 }
 
+type (
+	//forge:valid
+	reqSearchMsgs struct {
+		Userid string `valid:"userid,has" json:"-"`
+		Chatid string `valid:"chatid,has" json:"-"`
+		Query  string `valid:"query,has" json:"-"`
+		Amount int    `valid:"amount" json:"-"`
+		Offset int    `valid:"offset" json:"-"`
+	}
+)
+
+func (s *router) searchDMMsgs(c *governor.Context) {
+	req := reqSearchMsgs{
+		Userid: gate.GetCtxUserid(c),
+		Chatid: c.Param("id"),
+		Query:  c.Query("q"),
+		Amount: c.QueryInt("amount", -1),
+		Offset: c.QueryInt("offset", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.searchDMMsgs(c.Ctx(), req.Userid, req.Chatid, req.Query, req.Amount, req.Offset)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
 type (
 	//forge:valid
 	reqDelMsg struct {
@@ -394,23 +497,28 @@ func (s *router) deleteDMMsg(c *governor.Context) {
 
 type (
 	//forge:valid
-	reqGetPresence struct {
-		Userid  string   `valid:"userid,has" json:"-"`
-		Userids []string `valid:"userids,has" json:"userids"`
+	reqEditMsg struct {
+		Userid string `valid:"userid,has" json:"-"`
+		Chatid string `valid:"chatid,has" json:"-"`
+		Msgid  string `valid:"msgid,has" json:"-"`
+		Value  string `valid:"msgvalue" json:"value"`
 	}
 )
 
-func (s *router) getLatestGDMs(c *governor.Context) {
-	req := reqGetLatestChats{
-		Userid: gate.GetCtxUserid(c),
-		Before: c.QueryInt64("before", 0),
-		Amount: c.QueryInt("amount", -1),
+func (s *router) editDMMsg(c *governor.Context) {
+	var req reqEditMsg
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
 	}
+	req.Userid = gate.GetCtxUserid(c)
+	req.Chatid = c.Param("id")
+	req.Msgid = c.Param("msgid")
 	if err := req.valid(); err != nil {
 		c.WriteError(err)
 		return
 	}
-	res, err := s.s.getLatestGDMs(c.Ctx(), req.Userid, req.Before, req.Amount)
+	res, err := s.s.editDMMsg(c.Ctx(), req.Userid, req.Chatid, req.Msgid, req.Value)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -418,16 +526,30 @@ func (s *router) getLatestGDMs(c *governor.Context) {
 	c.WriteJSON(http.StatusOK, res)
 }
 
-func (s *router) getGDMs(c *governor.Context) {
-	req := reqGetChats{
-		Userid:  gate.GetCtxUserid(c),
-		Chatids: strings.Split(c.Query("ids"), ","),
+type (
+	//forge:valid
+	reqGetMsgEdits struct {
+		Userid string `valid:"userid,has" json:"-"`
+		Chatid string `valid:"chatid,has" json:"-"`
+		Msgid  string `valid:"msgid,has" json:"-"`
+		Amount int    `valid:"amount" json:"-"`
+		Offset int    `valid:"offset" json:"-"`
+	}
+)
+
+func (s *router) getDMMsgEdits(c *governor.Context) {
+	req := reqGetMsgEdits{
+		Userid: gate.GetCtxUserid(c),
+		Chatid: c.Param("id"),
+		Msgid:  c.Param("msgid"),
+		Amount: c.QueryInt("amount", -1),
+		Offset: c.QueryInt("offset", -1),
 	}
 	if err := req.valid(); err != nil {
 		c.WriteError(err)
 		return
 	}
-	res, err := s.s.getGDMs(c.Ctx(), req.Userid, req.Chatids)
+	res, err := s.s.getDMMsgEdits(c.Ctx(), req.Userid, req.Chatid, req.Msgid, req.Amount, req.Offset)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -437,26 +559,28 @@ func (s *router) getGDMs(c *governor.Context) {
 
 type (
 	//forge:valid
-	reqSearchGDMs struct {
-		Userid1 string `valid:"userid,has" json:"-"`
-		Userid2 string `valid:"userid,has" json:"-"`
-		Amount  int    `valid:"amount" json:"-"`
-		Offset  int    `valid:"offset" json:"-"`
+	reqReactMsg struct {
+		Userid   string `valid:"userid,has" json:"-"`
+		Chatid   string `valid:"chatid,has" json:"-"`
+		Msgid    string `valid:"msgid,has" json:"-"`
+		Reaction string `valid:"reaction" json:"reaction"`
 	}
 )
 
-func (s *router) searchGDMs(c *governor.Context) {
-	req := reqSearchGDMs{
-		Userid1: gate.GetCtxUserid(c),
-		Userid2: c.Query("id"),
-		Amount:  c.QueryInt("amount", -1),
-		Offset:  c.QueryInt("offset", -1),
+func (s *router) reactDMMsg(c *governor.Context) {
+	var req reqReactMsg
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
 	}
+	req.Userid = gate.GetCtxUserid(c)
+	req.Chatid = c.Param("id")
+	req.Msgid = c.Param("msgid")
 	if err := req.valid(); err != nil {
 		c.WriteError(err)
 		return
 	}
-	res, err := s.s.searchGDMs(c.Ctx(), req.Userid1, req.Userid2, req.Amount, req.Offset)
+	res, err := s.s.reactDMMsg(c.Ctx(), req.Userid, req.Chatid, req.Msgid, req.Reaction)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -466,48 +590,46 @@ func (s *router) searchGDMs(c *governor.Context) {
 
 type (
 	//forge:valid
-	reqCreateGDM struct {
-		Userid  string   `valid:"userid,has" json:"-"`
-		Name    string   `valid:"name" json:"name"`
-		Theme   string   `valid:"theme" json:"theme"`
-		Members []string `valid:"userids,has" json:"members"`
+	reqGetThreadMsgs struct {
+		Userid   string `valid:"userid,has" json:"-"`
+		Chatid   string `valid:"chatid,has" json:"-"`
+		Parentid string `valid:"msgid,has" json:"-"`
+		Before   string `valid:"msgid,opt" json:"-"`
+		Amount   int    `valid:"amount" json:"-"`
 	}
 )
 
-func (s *router) createGDM(c *governor.Context) {
-	var req reqCreateGDM
-	if err := c.Bind(&req, false); err != nil {
-		c.WriteError(err)
-		return
+func (s *router) getDMThreadMsgs(c *governor.Context) {
+	req := reqGetThreadMsgs{
+		Userid:   gate.GetCtxUserid(c),
+		Chatid:   c.Param("id"),
+		Parentid: c.Param("msgid"),
+		Before:   c.Query("before"),
+		Amount:   c.QueryInt("amount", -1),
 	}
-	req.Userid = gate.GetCtxUserid(c)
 	if err := req.valid(); err != nil {
 		c.WriteError(err)
 		return
 	}
-	members := make([]string, len(req.Members)+1)
-	members[0] = req.Userid
-	copy(members[1:], req.Members)
-	res, err := s.s.createGDM(c.Ctx(), req.Name, req.Theme, members)
+	res, err := s.s.getDMThreadMsgs(c.Ctx(), req.Userid, req.Chatid, req.Parentid, req.Before, req.Amount)
 	if err != nil {
 		c.WriteError(err)
 		return
 	}
-	c.WriteJSON(http.StatusCreated, res)
+	c.WriteJSON(http.StatusOK, res)
 }
 
 type (
 	//forge:valid
-	reqUpdateGDM struct {
+	reqReadMsg struct {
 		Userid string `valid:"userid,has" json:"-"`
 		Chatid string `valid:"chatid,has" json:"-"`
-		Name   string `valid:"name" json:"name"`
-		Theme  string `valid:"theme" json:"theme"`
+		Msgid  string `valid:"msgid,has" json:"msgid"`
 	}
 )
 
-func (s *router) updateGDM(c *governor.Context) {
-	var req reqUpdateGDM
+func (s *router) readDMMsg(c *governor.Context) {
+	var req reqReadMsg
 	if err := c.Bind(&req, false); err != nil {
 		c.WriteError(err)
 		return
@@ -518,7 +640,7 @@ func (s *router) updateGDM(c *governor.Context) {
 		c.WriteError(err)
 		return
 	}
-	if err := s.s.updateGDM(c.Ctx(), req.Userid, req.Chatid, req.Name, req.Theme); err != nil {
+	if err := s.s.readDMMsg(c.Ctx(), req.Userid, req.Chatid, req.Msgid); err != nil {
 		c.WriteError(err)
 		return
 	}
@@ -527,70 +649,83 @@ func (s *router) updateGDM(c *governor.Context) {
 
 type (
 	//forge:valid
-	reqDelGDM struct {
+	reqGetReadCursors struct {
 		Userid string `valid:"userid,has" json:"-"`
 		Chatid string `valid:"chatid,has" json:"-"`
+		Amount int    `valid:"amount" json:"-"`
+		Offset int    `valid:"offset" json:"-"`
 	}
 )
 
-func (s *router) deleteGDM(c *governor.Context) {
-	req := reqDelGDM{
+func (s *router) getDMReadCursors(c *governor.Context) {
+	req := reqGetReadCursors{
 		Userid: gate.GetCtxUserid(c),
 		Chatid: c.Param("id"),
+		Amount: c.QueryInt("amount", -1),
+		Offset: c.QueryInt("offset", -1),
 	}
 	if err := req.valid(); err != nil {
 		c.WriteError(err)
 		return
 	}
-	if err := s.s.deleteGDM(c.Ctx(), req.Userid, req.Chatid); err != nil {
+	res, err := s.s.getDMReadCursors(c.Ctx(), req.Userid, req.Chatid, req.Amount, req.Offset)
+	if err != nil {
 		c.WriteError(err)
 		return
 	}
-	c.WriteStatus(http.StatusNoContent)
+	c.WriteJSON(http.StatusOK, res)
 }
 
-func (s *router) createGDMMsg(c *governor.Context) {
-	var req reqCreateMsg
-	if err := c.Bind(&req, false); err != nil {
-		c.WriteError(err)
-		return
+type (
+	//forge:valid
+	reqCreateAttachmentMsg struct {
+		Userid   string `valid:"userid,has" json:"-"`
+		Chatid   string `valid:"chatid,has" json:"-"`
+		Name     string `valid:"attachmentName" json:"-"`
+		Parentid string `valid:"msgid,opt" json:"-"`
+	}
+)
+
+func (s *router) createDMAttachmentMsg(c *governor.Context) {
+	req := reqCreateAttachmentMsg{
+		Userid:   gate.GetCtxUserid(c),
+		Chatid:   c.Param("id"),
+		Name:     c.FormValue("name"),
+		Parentid: c.FormValue("parentid"),
 	}
-	req.Userid = gate.GetCtxUserid(c)
-	req.Chatid = c.Param("id")
 	if err := req.valid(); err != nil {
 		c.WriteError(err)
 		return
 	}
-	res, err := s.s.createGDMMsg(c.Ctx(), req.Userid, req.Chatid, req.Kind, req.Value)
+	file, contentType, size, err := fileloader.LoadOpenFile(c, "file", s.s.attachMediaTypes)
 	if err != nil {
 		c.WriteError(err)
 		return
 	}
-	c.WriteJSON(http.StatusCreated, res)
-}
-
-func (s *router) getGDMMsgs(c *governor.Context) {
-	req := reqGetMsgs{
-		Userid: gate.GetCtxUserid(c),
-		Chatid: c.Param("id"),
-		Kind:   c.Query("kind"),
-		Before: c.Query("before"),
-		Amount: c.QueryInt("amount", -1),
-	}
-	if err := req.valid(); err != nil {
-		c.WriteError(err)
-		return
-	}
-	res, err := s.s.getGDMMsgs(c.Ctx(), req.Userid, req.Chatid, req.Kind, req.Before, req.Amount)
+	defer func() {
+		if err := file.Close(); err != nil {
+			s.s.log.Err(c.Ctx(), kerrors.WithMsg(err, "Failed to close open file on request"))
+		}
+	}()
+	res, err := s.s.createDMAttachmentMsg(c.Ctx(), req.Userid, req.Chatid, req.Name, file, contentType, size, req.Parentid)
 	if err != nil {
 		c.WriteError(err)
 		return
 	}
-	c.WriteJSON(http.StatusOK, res)
+	c.WriteJSON(http.StatusCreated, res)
 }
 
-func (s *router) deleteGDMMsg(c *governor.Context) {
-	req := reqDelMsg{
+type (
+	//forge:valid
+	reqGetAttachment struct {
+		Userid string `valid:"userid,has" json:"-"`
+		Chatid string `valid:"chatid,has" json:"-"`
+		Msgid  string `valid:"msgid,has" json:"-"`
+	}
+)
+
+func (s *router) getDMAttachmentObj(c *governor.Context, thumb bool) {
+	req := reqGetAttachment{
 		Userid: gate.GetCtxUserid(c),
 		Chatid: c.Param("id"),
 		Msgid:  c.Param("msgid"),
@@ -599,76 +734,2272 @@ func (s *router) deleteGDMMsg(c *governor.Context) {
 		c.WriteError(err)
 		return
 	}
-	if err := s.s.delGDMMsg(c.Ctx(), req.Userid, req.Chatid, req.Msgid); err != nil {
+	res, err := s.s.getDMAttachment(c.Ctx(), req.Userid, req.Chatid, req.Msgid, thumb)
+	if err != nil {
 		c.WriteError(err)
 		return
 	}
-	c.WriteStatus(http.StatusNoContent)
+	s.writeAttachment(c, res)
+}
+
+func (s *router) getDMAttachment(c *governor.Context) {
+	s.getDMAttachmentObj(c, false)
+}
+
+func (s *router) getDMAttachmentThumb(c *governor.Context) {
+	s.getDMAttachmentObj(c, true)
 }
 
 type (
 	//forge:valid
-	reqGDMMember struct {
+	reqGetPresence struct {
 		Userid  string   `valid:"userid,has" json:"-"`
-		Chatid  string   `valid:"chatid,has" json:"-"`
-		Members []string `valid:"userids,has" json:"members"`
+		Userids []string `valid:"userids,has" json:"userids"`
 	}
 )
 
-func (s *router) addGDMMember(c *governor.Context) {
-	var req reqGDMMember
-	if err := c.Bind(&req, false); err != nil {
-		c.WriteError(err)
-		return
+func (s *router) getLatestGDMs(c *governor.Context) {
+	req := reqGetLatestChats{
+		Userid: gate.GetCtxUserid(c),
+		Before: c.QueryInt64("before", 0),
+		Amount: c.QueryInt("amount", -1),
 	}
-	req.Userid = gate.GetCtxUserid(c)
-	req.Chatid = c.Param("id")
 	if err := req.valid(); err != nil {
 		c.WriteError(err)
 		return
 	}
-	if err := s.s.addGDMMembers(c.Ctx(), req.Userid, req.Chatid, req.Members); err != nil {
+	res, err := s.s.getLatestGDMs(c.Ctx(), req.Userid, req.Before, req.Amount)
+	if err != nil {
 		c.WriteError(err)
 		return
 	}
-	c.WriteStatus(http.StatusNoContent)
+	c.WriteJSON(http.StatusOK, res)
 }
 
-func (s *router) rmGDMMembers(c *governor.Context) {
-	var req reqGDMMember
-	if err := c.Bind(&req, false); err != nil {
-		c.WriteError(err)
-		return
+func (s *router) getGDMs(c *governor.Context) {
+	req := reqGetChats{
+		Userid:  gate.GetCtxUserid(c),
+		Chatids: strings.Split(c.Query("ids"), ","),
 	}
-	req.Userid = gate.GetCtxUserid(c)
-	req.Chatid = c.Param("id")
 	if err := req.valid(); err != nil {
 		c.WriteError(err)
 		return
 	}
-	if err := s.s.rmGDMMembers(c.Ctx(), req.Userid, req.Chatid, req.Members); err != nil {
+	res, err := s.s.getGDMs(c.Ctx(), req.Userid, req.Chatids)
+	if err != nil {
 		c.WriteError(err)
 		return
 	}
-	c.WriteStatus(http.StatusNoContent)
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqSearchGDMs struct {
+		Userid1 string `valid:"userid,has" json:"-"`
+		Userid2 string `valid:"userid,has" json:"-"`
+		Amount  int    `valid:"amount" json:"-"`
+		Offset  int    `valid:"offset" json:"-"`
+	}
+)
+
+func (s *router) searchGDMs(c *governor.Context) {
+	req := reqSearchGDMs{
+		Userid1: gate.GetCtxUserid(c),
+		Userid2: c.Query("id"),
+		Amount:  c.QueryInt("amount", -1),
+		Offset:  c.QueryInt("offset", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.searchGDMs(c.Ctx(), req.Userid1, req.Userid2, req.Amount, req.Offset)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqCreateGDM struct {
+		Userid  string   `valid:"userid,has" json:"-"`
+		Name    string   `valid:"name" json:"name"`
+		Theme   string   `valid:"theme" json:"theme"`
+		Members []string `valid:"userids,has" json:"members"`
+	}
+)
+
+func (s *router) createGDM(c *governor.Context) {
+	var req reqCreateGDM
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
+	}
+	req.Userid = gate.GetCtxUserid(c)
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	members := make([]string, len(req.Members)+1)
+	members[0] = req.Userid
+	copy(members[1:], req.Members)
+	res, err := s.s.createGDM(c.Ctx(), req.Name, req.Theme, members)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusCreated, res)
+}
+
+type (
+	//forge:valid
+	reqUpdateGDM struct {
+		Userid string `valid:"userid,has" json:"-"`
+		Chatid string `valid:"chatid,has" json:"-"`
+		Name   string `valid:"name" json:"name"`
+		Theme  string `valid:"theme" json:"theme"`
+	}
+)
+
+func (s *router) updateGDM(c *governor.Context) {
+	var req reqUpdateGDM
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
+	}
+	req.Userid = gate.GetCtxUserid(c)
+	req.Chatid = c.Param("id")
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	if err := s.s.updateGDM(c.Ctx(), req.Userid, req.Chatid, req.Name, req.Theme); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteStatus(http.StatusNoContent)
+}
+
+type (
+	//forge:valid
+	reqGetGDMRetention struct {
+		Userid string `valid:"userid,has" json:"-"`
+		Chatid string `valid:"chatid,has" json:"-"`
+	}
+)
+
+func (s *router) getGDMRetention(c *governor.Context) {
+	req := reqGetGDMRetention{
+		Userid: gate.GetCtxUserid(c),
+		Chatid: c.Param("id"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getGDMRetention(c.Ctx(), req.Userid, req.Chatid)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqUpdateGDMRetention struct {
+		Userid   string `valid:"userid,has" json:"-"`
+		Chatid   string `valid:"chatid,has" json:"-"`
+		Duration int64  `valid:"retentionDuration" json:"duration"`
+		Default  bool   `json:"default"`
+	}
+)
+
+func (s *router) updateGDMRetention(c *governor.Context) {
+	var req reqUpdateGDMRetention
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
+	}
+	req.Userid = gate.GetCtxUserid(c)
+	req.Chatid = c.Param("id")
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	if err := s.s.updateGDMRetention(c.Ctx(), req.Userid, req.Chatid, req.Duration, req.Default); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteStatus(http.StatusNoContent)
+}
+
+type (
+	//forge:valid
+	reqDelGDM struct {
+		Userid string `valid:"userid,has" json:"-"`
+		Chatid string `valid:"chatid,has" json:"-"`
+	}
+)
+
+func (s *router) deleteGDM(c *governor.Context) {
+	req := reqDelGDM{
+		Userid: gate.GetCtxUserid(c),
+		Chatid: c.Param("id"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	if err := s.s.deleteGDM(c.Ctx(), req.Userid, req.Chatid); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteStatus(http.StatusNoContent)
+}
+
+func (s *router) createGDMMsg(c *governor.Context) {
+	var req reqCreateMsg
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
+	}
+	req.Userid = gate.GetCtxUserid(c)
+	req.Chatid = c.Param("id")
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.createGDMMsg(c.Ctx(), req.Userid, req.Chatid, req.Kind, req.Value, req.Parentid)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusCreated, res)
+}
+
+func (s *router) getGDMMsgs(c *governor.Context) {
+	req := reqGetMsgs{
+		Userid: gate.GetCtxUserid(c),
+		Chatid: c.Param("id"),
+		Kind:   c.Query("kind"),
+		Before: c.Query("before"),
+		Amount: c.QueryInt("amount", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getGDMMsgs(c.Ctx(), req.Userid, req.Chatid, req.Kind, req.Before, req.Amount)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+func (s *router) searchGDMMsgs(c *governor.Context) {
+	req := reqSearchMsgs{
+		Userid: gate.GetCtxUserid(c),
+		Chatid: c.Param("id"),
+		Query:  c.Query("q"),
+		Amount: c.QueryInt("amount", -1),
+		Offset: c.QueryInt("offset", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.searchGDMMsgs(c.Ctx(), req.Userid, req.Chatid, req.Query, req.Amount, req.Offset)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+func (s *router) deleteGDMMsg(c *governor.Context) {
+	req := reqDelMsg{
+		Userid: gate.GetCtxUserid(c),
+		Chatid: c.Param("id"),
+		Msgid:  c.Param("msgid"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	if err := s.s.delGDMMsg(c.Ctx(), req.Userid, req.Chatid, req.Msgid); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteStatus(http.StatusNoContent)
+}
+
+func (s *router) editGDMMsg(c *governor.Context) {
+	var req reqEditMsg
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
+	}
+	req.Userid = gate.GetCtxUserid(c)
+	req.Chatid = c.Param("id")
+	req.Msgid = c.Param("msgid")
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.editGDMMsg(c.Ctx(), req.Userid, req.Chatid, req.Msgid, req.Value)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+func (s *router) getGDMMsgEdits(c *governor.Context) {
+	req := reqGetMsgEdits{
+		Userid: gate.GetCtxUserid(c),
+		Chatid: c.Param("id"),
+		Msgid:  c.Param("msgid"),
+		Amount: c.QueryInt("amount", -1),
+		Offset: c.QueryInt("offset", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getGDMMsgEdits(c.Ctx(), req.Userid, req.Chatid, req.Msgid, req.Amount, req.Offset)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+func (s *router) reactGDMMsg(c *governor.Context) {
+	var req reqReactMsg
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
+	}
+	req.Userid = gate.GetCtxUserid(c)
+	req.Chatid = c.Param("id")
+	req.Msgid = c.Param("msgid")
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.reactGDMMsg(c.Ctx(), req.Userid, req.Chatid, req.Msgid, req.Reaction)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+func (s *router) getGDMThreadMsgs(c *governor.Context) {
+	req := reqGetThreadMsgs{
+		Userid:   gate.GetCtxUserid(c),
+		Chatid:   c.Param("id"),
+		Parentid: c.Param("msgid"),
+		Before:   c.Query("before"),
+		Amount:   c.QueryInt("amount", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getGDMThreadMsgs(c.Ctx(), req.Userid, req.Chatid, req.Parentid, req.Before, req.Amount)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+func (s *router) readGDMMsg(c *governor.Context) {
+	var req reqReadMsg
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
+	}
+	req.Userid = gate.GetCtxUserid(c)
+	req.Chatid = c.Param("id")
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	if err := s.s.readGDMMsg(c.Ctx(), req.Userid, req.Chatid, req.Msgid); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteStatus(http.StatusNoContent)
+}
+
+func (s *router) getGDMReadCursors(c *governor.Context) {
+	req := reqGetReadCursors{
+		Userid: gate.GetCtxUserid(c),
+		Chatid: c.Param("id"),
+		Amount: c.QueryInt("amount", -1),
+		Offset: c.QueryInt("offset", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getGDMReadCursors(c.Ctx(), req.Userid, req.Chatid, req.Amount, req.Offset)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+func (s *router) createGDMAttachmentMsg(c *governor.Context) {
+	req := reqCreateAttachmentMsg{
+		Userid:   gate.GetCtxUserid(c),
+		Chatid:   c.Param("id"),
+		Name:     c.FormValue("name"),
+		Parentid: c.FormValue("parentid"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	file, contentType, size, err := fileloader.LoadOpenFile(c, "file", s.s.attachMediaTypes)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			s.s.log.Err(c.Ctx(), kerrors.WithMsg(err, "Failed to close open file on request"))
+		}
+	}()
+	res, err := s.s.createGDMAttachmentMsg(c.Ctx(), req.Userid, req.Chatid, req.Name, file, contentType, size, req.Parentid)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusCreated, res)
+}
+
+func (s *router) getGDMAttachmentObj(c *governor.Context, thumb bool) {
+	req := reqGetAttachment{
+		Userid: gate.GetCtxUserid(c),
+		Chatid: c.Param("id"),
+		Msgid:  c.Param("msgid"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getGDMAttachment(c.Ctx(), req.Userid, req.Chatid, req.Msgid, thumb)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	s.writeAttachment(c, res)
+}
+
+func (s *router) getGDMAttachment(c *governor.Context) {
+	s.getGDMAttachmentObj(c, false)
+}
+
+func (s *router) getGDMAttachmentThumb(c *governor.Context) {
+	s.getGDMAttachmentObj(c, true)
+}
+
+type (
+	//forge:valid
+	reqGDMMember struct {
+		Userid  string   `valid:"userid,has" json:"-"`
+		Chatid  string   `valid:"chatid,has" json:"-"`
+		Members []string `valid:"userids,has" json:"members"`
+	}
+)
+
+func (s *router) addGDMMember(c *governor.Context) {
+	var req reqGDMMember
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
+	}
+	req.Userid = gate.GetCtxUserid(c)
+	req.Chatid = c.Param("id")
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	if err := s.s.addGDMMembers(c.Ctx(), req.Userid, req.Chatid, req.Members); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteStatus(http.StatusNoContent)
+}
+
+func (s *router) rmGDMMembers(c *governor.Context) {
+	var req reqGDMMember
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
+	}
+	req.Userid = gate.GetCtxUserid(c)
+	req.Chatid = c.Param("id")
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	if err := s.s.rmGDMMembers(c.Ctx(), req.Userid, req.Chatid, req.Members); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteStatus(http.StatusNoContent)
+}
+
+type (
+	//forge:valid
+	reqGetServer struct {
+		ServerID string `valid:"serverID,has" json:"-"`
+	}
+)
+
+func (s *router) getServer(c *governor.Context) {
+	req := reqGetServer{
+		ServerID: c.Param("id"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getServer(c.Ctx(), req.ServerID)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqCreateServer struct {
+		Userid   string `valid:"userid,has" json:"-"`
+		ServerID string `valid:"serverID,has" json:"-"`
+		Name     string `valid:"name" json:"name"`
+		Desc     string `valid:"desc" json:"desc"`
+		Theme    string `valid:"theme" json:"theme"`
+	}
+)
+
+func (s *router) createServer(c *governor.Context) {
+	var req reqCreateServer
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
+	}
+	req.Userid = gate.GetCtxUserid(c)
+	req.ServerID = c.Param("id")
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.createServer(c.Ctx(), req.ServerID, req.Userid, req.Name, req.Desc, req.Theme)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusCreated, res)
+}
+
+func (s *router) updateServer(c *governor.Context) {
+	var req reqCreateServer
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
+	}
+	req.Userid = gate.GetCtxUserid(c)
+	req.ServerID = c.Param("id")
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	if err := s.s.updateServer(c.Ctx(), req.ServerID, req.Userid, req.Name, req.Desc, req.Theme); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteStatus(http.StatusNoContent)
+}
+
+type (
+	//forge:valid
+	reqGetUserServers struct {
+		Userid string `valid:"userid,has" json:"-"`
+		Amount int    `valid:"amount" json:"-"`
+		Offset int    `valid:"offset" json:"-"`
+	}
+)
+
+func (s *router) getUserServers(c *governor.Context) {
+	req := reqGetUserServers{
+		Userid: gate.GetCtxUserid(c),
+		Amount: c.QueryInt("amount", -1),
+		Offset: c.QueryInt("offset", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getUserServers(c.Ctx(), req.Userid, req.Amount, req.Offset)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+func (s *router) backfillServerMembers(c *governor.Context) {
+	res, err := s.s.backfillServerMembers(c.Ctx())
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqGetServerMembers struct {
+		ServerID string `valid:"serverID,has" json:"-"`
+		Amount   int    `valid:"amount" json:"-"`
+		Offset   int    `valid:"offset" json:"-"`
+	}
+)
+
+func (s *router) getServerMembers(c *governor.Context) {
+	req := reqGetServerMembers{
+		ServerID: c.Param("id"),
+		Amount:   c.QueryInt("amount", -1),
+		Offset:   c.QueryInt("offset", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getServerMembers(c.Ctx(), req.ServerID, req.Amount, req.Offset)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqGetServerPresence struct {
+		Userid    string `valid:"userid,has" json:"-"`
+		ServerID  string `valid:"serverID,has" json:"serverid"`
+		ChannelID string `valid:"channelID,opt" json:"channelid"`
+		Amount    int    `valid:"amount" json:"amount"`
+		Offset    int    `valid:"offset" json:"offset"`
+	}
+)
+
+func (s *router) getServerPresence(c *governor.Context) {
+	req := reqGetServerPresence{
+		Userid:    gate.GetCtxUserid(c),
+		ServerID:  c.Param("id"),
+		ChannelID: c.Param("cid"),
+		Amount:    c.QueryInt("amount", -1),
+		Offset:    c.QueryInt("offset", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getServerPresence(c.Ctx(), req.ServerID, req.ChannelID, req.Userid, req.Amount, req.Offset)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqLeaveServer struct {
+		Userid   string `valid:"userid,has" json:"-"`
+		ServerID string `valid:"serverID,has" json:"-"`
+	}
+)
+
+func (s *router) leaveServer(c *governor.Context) {
+	req := reqLeaveServer{
+		Userid:   gate.GetCtxUserid(c),
+		ServerID: c.Param("id"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	if err := s.s.leaveServer(c.Ctx(), req.ServerID, req.Userid); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteStatus(http.StatusNoContent)
+}
+
+type (
+	//forge:valid
+	reqServerMemberTarget struct {
+		Userid   string `valid:"userid,has" json:"-"`
+		ServerID string `valid:"serverID,has" json:"-"`
+		Target   string `valid:"userid,has" json:"-"`
+	}
+)
+
+func (s *router) kickServerMember(c *governor.Context) {
+	req := reqServerMemberTarget{
+		Userid:   gate.GetCtxUserid(c),
+		ServerID: c.Param("id"),
+		Target:   c.Param("uid"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	if err := s.s.kickServerMember(c.Ctx(), req.ServerID, req.Userid, req.Target); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteStatus(http.StatusNoContent)
+}
+
+type (
+	//forge:valid
+	reqCreateServerInvite struct {
+		Userid   string `valid:"userid,has" json:"-"`
+		ServerID string `valid:"serverID,has" json:"-"`
+		MaxUses  int    `valid:"inviteMaxUses" json:"max_uses"`
+		Duration int64  `valid:"inviteDuration" json:"duration"`
+	}
+)
+
+func (s *router) createServerInvite(c *governor.Context) {
+	var req reqCreateServerInvite
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
+	}
+	req.Userid = gate.GetCtxUserid(c)
+	req.ServerID = c.Param("id")
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.createServerInvite(c.Ctx(), req.ServerID, req.Userid, req.MaxUses, req.Duration)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusCreated, res)
+}
+
+type (
+	//forge:valid
+	reqGetServerInvites struct {
+		Userid   string `valid:"userid,has" json:"-"`
+		ServerID string `valid:"serverID,has" json:"-"`
+		Amount   int    `valid:"amount" json:"-"`
+		Offset   int    `valid:"offset" json:"-"`
+	}
+)
+
+func (s *router) getServerInvites(c *governor.Context) {
+	req := reqGetServerInvites{
+		Userid:   gate.GetCtxUserid(c),
+		ServerID: c.Param("id"),
+		Amount:   c.QueryInt("amount", -1),
+		Offset:   c.QueryInt("offset", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getServerInvites(c.Ctx(), req.ServerID, req.Userid, req.Amount, req.Offset)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqDelServerInvite struct {
+		Userid   string `valid:"userid,has" json:"-"`
+		ServerID string `valid:"serverID,has" json:"-"`
+		Code     string `valid:"inviteCode,has" json:"-"`
+	}
+)
+
+func (s *router) deleteServerInvite(c *governor.Context) {
+	req := reqDelServerInvite{
+		Userid:   gate.GetCtxUserid(c),
+		ServerID: c.Param("id"),
+		Code:     c.Param("code"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	if err := s.s.deleteServerInvite(c.Ctx(), req.ServerID, req.Userid, req.Code); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteStatus(http.StatusNoContent)
+}
+
+type (
+	//forge:valid
+	reqServerInvite struct {
+		Userid string `valid:"userid,has" json:"-"`
+		Code   string `valid:"inviteCode,has" json:"-"`
+	}
+)
+
+func (s *router) getServerByInvite(c *governor.Context) {
+	req := reqServerInvite{
+		Userid: gate.GetCtxUserid(c),
+		Code:   c.Param("code"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getServerByInvite(c.Ctx(), req.Code)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+func (s *router) joinServer(c *governor.Context) {
+	req := reqServerInvite{
+		Userid: gate.GetCtxUserid(c),
+		Code:   c.Param("code"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.joinServer(c.Ctx(), req.Userid, req.Code)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusCreated, res)
+}
+
+type (
+	//forge:valid
+	reqGetServerBans struct {
+		Userid   string `valid:"userid,has" json:"-"`
+		ServerID string `valid:"serverID,has" json:"-"`
+		Amount   int    `valid:"amount" json:"-"`
+		Offset   int    `valid:"offset" json:"-"`
+	}
+)
+
+func (s *router) getServerBans(c *governor.Context) {
+	req := reqGetServerBans{
+		Userid:   gate.GetCtxUserid(c),
+		ServerID: c.Param("id"),
+		Amount:   c.QueryInt("amount", -1),
+		Offset:   c.QueryInt("offset", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getServerBans(c.Ctx(), req.ServerID, req.Userid, req.Amount, req.Offset)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqBanServerUser struct {
+		Userid   string `valid:"userid,has" json:"-"`
+		ServerID string `valid:"serverID,has" json:"-"`
+		Target   string `valid:"userid,has" json:"-"`
+		Reason   string `valid:"banReason" json:"reason"`
+	}
+)
+
+func (s *router) banServerUser(c *governor.Context) {
+	var req reqBanServerUser
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
+	}
+	req.Userid = gate.GetCtxUserid(c)
+	req.ServerID = c.Param("id")
+	req.Target = c.Param("uid")
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	if err := s.s.banServerUser(c.Ctx(), req.ServerID, req.Userid, req.Target, req.Reason); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteStatus(http.StatusNoContent)
+}
+
+func (s *router) unbanServerUser(c *governor.Context) {
+	req := reqServerMemberTarget{
+		Userid:   gate.GetCtxUserid(c),
+		ServerID: c.Param("id"),
+		Target:   c.Param("uid"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	if err := s.s.unbanServerUser(c.Ctx(), req.ServerID, req.Userid, req.Target); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteStatus(http.StatusNoContent)
+}
+
+func (s *router) getServerRoles(c *governor.Context) {
+	req := reqGetServer{
+		ServerID: c.Param("id"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getServerRoles(c.Ctx(), req.ServerID)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqCreateServerRole struct {
+		Userid      string   `valid:"userid,has" json:"-"`
+		ServerID    string   `valid:"serverID,has" json:"-"`
+		Name        string   `valid:"roleName" json:"name"`
+		Permissions []string `valid:"serverPerms" json:"permissions"`
+	}
+)
+
+func (s *router) createServerRole(c *governor.Context) {
+	var req reqCreateServerRole
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
+	}
+	req.Userid = gate.GetCtxUserid(c)
+	req.ServerID = c.Param("id")
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.createServerRole(c.Ctx(), req.ServerID, req.Userid, req.Name, req.Permissions)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusCreated, res)
+}
+
+type (
+	//forge:valid
+	reqUpdateServerRole struct {
+		Userid      string   `valid:"userid,has" json:"-"`
+		ServerID    string   `valid:"serverID,has" json:"-"`
+		Roleid      string   `valid:"roleid,has" json:"-"`
+		Name        string   `valid:"roleName" json:"name"`
+		Permissions []string `valid:"serverPerms" json:"permissions"`
+	}
+)
+
+func (s *router) updateServerRole(c *governor.Context) {
+	var req reqUpdateServerRole
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
+	}
+	req.Userid = gate.GetCtxUserid(c)
+	req.ServerID = c.Param("id")
+	req.Roleid = c.Param("rid")
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	if err := s.s.updateServerRole(c.Ctx(), req.ServerID, req.Userid, req.Roleid, req.Name, req.Permissions); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteStatus(http.StatusNoContent)
+}
+
+type (
+	//forge:valid
+	reqDelServerRole struct {
+		Userid   string `valid:"userid,has" json:"-"`
+		ServerID string `valid:"serverID,has" json:"-"`
+		Roleid   string `valid:"roleid,has" json:"-"`
+	}
+)
+
+func (s *router) deleteServerRole(c *governor.Context) {
+	req := reqDelServerRole{
+		Userid:   gate.GetCtxUserid(c),
+		ServerID: c.Param("id"),
+		Roleid:   c.Param("rid"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	if err := s.s.deleteServerRole(c.Ctx(), req.ServerID, req.Userid, req.Roleid); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteStatus(http.StatusNoContent)
+}
+
+type (
+	//forge:valid
+	reqGetServerRoleMembers struct {
+		ServerID string `valid:"serverID,has" json:"-"`
+		Roleid   string `valid:"roleid,has" json:"-"`
+		After    string `valid:"userid,opt" json:"-"`
+		Amount   int    `valid:"amount" json:"-"`
+	}
+)
+
+func (s *router) getServerRoleMembers(c *governor.Context) {
+	req := reqGetServerRoleMembers{
+		ServerID: c.Param("id"),
+		Roleid:   c.Param("rid"),
+		After:    c.Query("after"),
+		Amount:   c.QueryInt("amount", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getServerRoleMembers(c.Ctx(), req.ServerID, req.Roleid, req.After, req.Amount)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqServerRoleMember struct {
+		Userid   string `valid:"userid,has" json:"-"`
+		ServerID string `valid:"serverID,has" json:"-"`
+		Roleid   string `valid:"roleid,has" json:"-"`
+		Target   string `valid:"userid,has" json:"-"`
+	}
+)
+
+func (s *router) addServerRoleMember(c *governor.Context) {
+	req := reqServerRoleMember{
+		Userid:   gate.GetCtxUserid(c),
+		ServerID: c.Param("id"),
+		Roleid:   c.Param("rid"),
+		Target:   c.Param("uid"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	if err := s.s.addServerRoleMember(c.Ctx(), req.ServerID, req.Userid, req.Roleid, req.Target); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteStatus(http.StatusNoContent)
+}
+
+func (s *router) rmServerRoleMember(c *governor.Context) {
+	req := reqServerRoleMember{
+		Userid:   gate.GetCtxUserid(c),
+		ServerID: c.Param("id"),
+		Roleid:   c.Param("rid"),
+		Target:   c.Param("uid"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	if err := s.s.rmServerRoleMember(c.Ctx(), req.ServerID, req.Userid, req.Roleid, req.Target); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteStatus(http.StatusNoContent)
+}
+
+type (
+	//forge:valid
+	reqGetChannel struct {
+		ServerID  string `valid:"serverID,has" json:"-"`
+		ChannelID string `valid:"channelID,has" json:"-"`
+	}
+)
+
+func (s *router) getChannel(c *governor.Context) {
+	req := reqGetChannel{
+		ServerID:  c.Param("id"),
+		ChannelID: c.Param("cid"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getChannel(c.Ctx(), req.ServerID, req.ChannelID)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqGetChannels struct {
+		Userid   string `valid:"userid,has" json:"-"`
+		ServerID string `valid:"serverID,has" json:"-"`
+		Amount   int    `valid:"amount" json:"-"`
+		Offset   int    `valid:"offset" json:"-"`
+	}
+)
+
+func (s *router) getChannels(c *governor.Context) {
+	req := reqGetChannels{
+		Userid:   gate.GetCtxUserid(c),
+		ServerID: c.Param("id"),
+		Amount:   c.QueryInt("amount", -1),
+		Offset:   c.QueryInt("offset", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getChannels(c.Ctx(), req.ServerID, req.Userid, "", req.Amount, req.Offset)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqSearchChannels struct {
+		Userid   string `valid:"userid,has" json:"-"`
+		ServerID string `valid:"serverID,has" json:"-"`
+		Prefix   string `valid:"channelID,has" json:"-"`
+		Amount   int    `valid:"amount" json:"-"`
+	}
+)
+
+func (s *router) searchChannels(c *governor.Context) {
+	req := reqSearchChannels{
+		Userid:   gate.GetCtxUserid(c),
+		ServerID: c.Param("id"),
+		Prefix:   c.Query("prefix"),
+		Amount:   c.QueryInt("amount", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getChannels(c.Ctx(), req.ServerID, req.Userid, req.Prefix, req.Amount, 0)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqCreateChannel struct {
+		Userid    string `valid:"userid,has" json:"-"`
+		ServerID  string `valid:"serverID,has" json:"-"`
+		ChannelID string `valid:"channelID" json:"channelid"`
+		Name      string `valid:"name" json:"name"`
+		Desc      string `valid:"desc" json:"desc"`
+		Theme     string `valid:"theme" json:"theme"`
+	}
+)
+
+func (s *router) createChannel(c *governor.Context) {
+	var req reqCreateChannel
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
+	}
+	req.Userid = gate.GetCtxUserid(c)
+	req.ServerID = c.Param("id")
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.createChannel(c.Ctx(), req.ServerID, req.ChannelID, req.Userid, req.Name, req.Desc, req.Theme)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusCreated, res)
+}
+
+type (
+	//forge:valid
+	reqUpdateChannel struct {
+		Userid    string `valid:"userid,has" json:"-"`
+		ServerID  string `valid:"serverID,has" json:"-"`
+		ChannelID string `valid:"channelID,has" json:"-"`
+		Name      string `valid:"name" json:"name"`
+		Desc      string `valid:"desc" json:"desc"`
+		Theme     string `valid:"theme" json:"theme"`
+	}
+)
+
+func (s *router) updateChannel(c *governor.Context) {
+	var req reqUpdateChannel
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
+	}
+	req.Userid = gate.GetCtxUserid(c)
+	req.ServerID = c.Param("id")
+	req.ChannelID = c.Param("cid")
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	if err := s.s.updateChannel(c.Ctx(), req.ServerID, req.ChannelID, req.Userid, req.Name, req.Desc, req.Theme); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteStatus(http.StatusNoContent)
+}
+
+type (
+	//forge:valid
+	reqGetChannelRetention struct {
+		ServerID  string `valid:"serverID,has" json:"-"`
+		ChannelID string `valid:"channelID,has" json:"-"`
+	}
+)
+
+func (s *router) getChannelRetention(c *governor.Context) {
+	req := reqGetChannelRetention{
+		ServerID:  c.Param("id"),
+		ChannelID: c.Param("cid"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getChannelRetention(c.Ctx(), req.ServerID, req.ChannelID)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqUpdateChannelRetention struct {
+		Userid    string `valid:"userid,has" json:"-"`
+		ServerID  string `valid:"serverID,has" json:"-"`
+		ChannelID string `valid:"channelID,has" json:"-"`
+		Duration  int64  `valid:"retentionDuration" json:"duration"`
+		Default   bool   `json:"default"`
+	}
+)
+
+func (s *router) updateChannelRetention(c *governor.Context) {
+	var req reqUpdateChannelRetention
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
+	}
+	req.Userid = gate.GetCtxUserid(c)
+	req.ServerID = c.Param("id")
+	req.ChannelID = c.Param("cid")
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	if err := s.s.updateChannelRetention(c.Ctx(), req.ServerID, req.ChannelID, req.Userid, req.Duration, req.Default); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteStatus(http.StatusNoContent)
+}
+
+type (
+	//forge:valid
+	reqDelChannel struct {
+		Userid    string `valid:"userid,has" json:"-"`
+		ServerID  string `valid:"serverID,has" json:"-"`
+		ChannelID string `valid:"channelID,has" json:"-"`
+	}
+)
+
+func (s *router) deleteChannel(c *governor.Context) {
+	req := reqDelChannel{
+		Userid:    gate.GetCtxUserid(c),
+		ServerID:  c.Param("id"),
+		ChannelID: c.Param("cid"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	if err := s.s.deleteChannel(c.Ctx(), req.ServerID, req.ChannelID, req.Userid); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteStatus(http.StatusNoContent)
+}
+
+type (
+	//forge:valid
+	reqCreateChannelMsg struct {
+		Userid    string `valid:"userid,has" json:"-"`
+		ServerID  string `valid:"serverID,has" json:"-"`
+		ChannelID string `valid:"channelID,has" json:"-"`
+		Kind      string `valid:"msgkind" json:"kind"`
+		Value     string `valid:"msgvalue" json:"value"`
+		Parentid  string `valid:"msgid,opt" json:"parentid"`
+	}
+)
+
+func (s *router) createChannelMsg(c *governor.Context) {
+	var req reqCreateChannelMsg
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
+	}
+	req.Userid = gate.GetCtxUserid(c)
+	req.ServerID = c.Param("id")
+	req.ChannelID = c.Param("cid")
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.createChannelMsg(c.Ctx(), req.ServerID, req.ChannelID, req.Userid, req.Kind, req.Value, req.Parentid)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusCreated, res)
+}
+
+type (
+	//forge:valid
+	reqGetChannelMsgs struct {
+		Userid    string `valid:"userid,has" json:"-"`
+		ServerID  string `valid:"serverID,has" json:"-"`
+		ChannelID string `valid:"channelID,has" json:"-"`
+		Kind      string `valid:"msgkind,opt" json:"-"`
+		Before    string `valid:"msgid,opt" json:"-"`
+		Amount    int    `valid:"amount" json:"-"`
+	}
+)
+
+func (s *router) getChannelMsgs(c *governor.Context) {
+	req := reqGetChannelMsgs{
+		Userid:    gate.GetCtxUserid(c),
+		ServerID:  c.Param("id"),
+		ChannelID: c.Param("cid"),
+		Kind:      c.Query("kind"),
+		Before:    c.Query("before"),
+		Amount:    c.QueryInt("amount", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getChannelMsgs(c.Ctx(), req.ServerID, req.ChannelID, req.Userid, req.Kind, req.Before, req.Amount)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqSearchChannelMsgs struct {
+		ServerID  string `valid:"serverID,has" json:"-"`
+		ChannelID string `valid:"channelID,has" json:"-"`
+		Query     string `valid:"query,has" json:"-"`
+		Amount    int    `valid:"amount" json:"-"`
+		Offset    int    `valid:"offset" json:"-"`
+	}
+)
+
+func (s *router) searchChannelMsgs(c *governor.Context) {
+	req := reqSearchChannelMsgs{
+		ServerID:  c.Param("id"),
+		ChannelID: c.Param("cid"),
+		Query:     c.Query("q"),
+		Amount:    c.QueryInt("amount", -1),
+		Offset:    c.QueryInt("offset", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.searchChannelMsgs(c.Ctx(), req.ServerID, req.ChannelID, req.Query, req.Amount, req.Offset)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqDelChannelMsg struct {
+		Userid    string `valid:"userid,has" json:"-"`
+		ServerID  string `valid:"serverID,has" json:"-"`
+		ChannelID string `valid:"channelID,has" json:"-"`
+		Msgid     string `valid:"msgid,has" json:"-"`
+	}
+)
+
+func (s *router) deleteChannelMsg(c *governor.Context) {
+	req := reqDelChannelMsg{
+		Userid:    gate.GetCtxUserid(c),
+		ServerID:  c.Param("id"),
+		ChannelID: c.Param("cid"),
+		Msgid:     c.Param("msgid"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	if err := s.s.deleteChannelMsg(c.Ctx(), req.ServerID, req.ChannelID, req.Userid, req.Msgid); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteStatus(http.StatusNoContent)
+}
+
+type (
+	//forge:valid
+	reqEditChannelMsg struct {
+		Userid    string `valid:"userid,has" json:"-"`
+		ServerID  string `valid:"serverID,has" json:"-"`
+		ChannelID string `valid:"channelID,has" json:"-"`
+		Msgid     string `valid:"msgid,has" json:"-"`
+		Value     string `valid:"msgvalue" json:"value"`
+	}
+)
+
+func (s *router) editChannelMsg(c *governor.Context) {
+	var req reqEditChannelMsg
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
+	}
+	req.Userid = gate.GetCtxUserid(c)
+	req.ServerID = c.Param("id")
+	req.ChannelID = c.Param("cid")
+	req.Msgid = c.Param("msgid")
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.editChannelMsg(c.Ctx(), req.ServerID, req.ChannelID, req.Userid, req.Msgid, req.Value)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqGetChannelMsgEdits struct {
+		ServerID  string `valid:"serverID,has" json:"-"`
+		ChannelID string `valid:"channelID,has" json:"-"`
+		Msgid     string `valid:"msgid,has" json:"-"`
+		Amount    int    `valid:"amount" json:"-"`
+		Offset    int    `valid:"offset" json:"-"`
+	}
+)
+
+func (s *router) getChannelMsgEdits(c *governor.Context) {
+	req := reqGetChannelMsgEdits{
+		ServerID:  c.Param("id"),
+		ChannelID: c.Param("cid"),
+		Msgid:     c.Param("msgid"),
+		Amount:    c.QueryInt("amount", -1),
+		Offset:    c.QueryInt("offset", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getChannelMsgEdits(c.Ctx(), req.ServerID, req.ChannelID, req.Msgid, req.Amount, req.Offset)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqReactChannelMsg struct {
+		Userid    string `valid:"userid,has" json:"-"`
+		ServerID  string `valid:"serverID,has" json:"-"`
+		ChannelID string `valid:"channelID,has" json:"-"`
+		Msgid     string `valid:"msgid,has" json:"-"`
+		Reaction  string `valid:"reaction" json:"reaction"`
+	}
+)
+
+func (s *router) reactChannelMsg(c *governor.Context) {
+	var req reqReactChannelMsg
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
+	}
+	req.Userid = gate.GetCtxUserid(c)
+	req.ServerID = c.Param("id")
+	req.ChannelID = c.Param("cid")
+	req.Msgid = c.Param("msgid")
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.reactChannelMsg(c.Ctx(), req.ServerID, req.ChannelID, req.Userid, req.Msgid, req.Reaction)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqGetChannelThreadMsgs struct {
+		Userid    string `valid:"userid,has" json:"-"`
+		ServerID  string `valid:"serverID,has" json:"-"`
+		ChannelID string `valid:"channelID,has" json:"-"`
+		Parentid  string `valid:"msgid,has" json:"-"`
+		Before    string `valid:"msgid,opt" json:"-"`
+		Amount    int    `valid:"amount" json:"-"`
+	}
+)
+
+func (s *router) getChannelThreadMsgs(c *governor.Context) {
+	req := reqGetChannelThreadMsgs{
+		Userid:    gate.GetCtxUserid(c),
+		ServerID:  c.Param("id"),
+		ChannelID: c.Param("cid"),
+		Parentid:  c.Param("msgid"),
+		Before:    c.Query("before"),
+		Amount:    c.QueryInt("amount", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getChannelThreadMsgs(c.Ctx(), req.ServerID, req.ChannelID, req.Userid, req.Parentid, req.Before, req.Amount)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqReadChannelMsg struct {
+		Userid    string `valid:"userid,has" json:"-"`
+		ServerID  string `valid:"serverID,has" json:"-"`
+		ChannelID string `valid:"channelID,has" json:"-"`
+		Msgid     string `valid:"msgid,has" json:"msgid"`
+	}
+)
+
+func (s *router) readChannelMsg(c *governor.Context) {
+	var req reqReadChannelMsg
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
+	}
+	req.Userid = gate.GetCtxUserid(c)
+	req.ServerID = c.Param("id")
+	req.ChannelID = c.Param("cid")
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	if err := s.s.readChannelMsg(c.Ctx(), req.ServerID, req.ChannelID, req.Userid, req.Msgid); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteStatus(http.StatusNoContent)
+}
+
+type (
+	//forge:valid
+	reqGetChannelReadCursors struct {
+		ServerID  string `valid:"serverID,has" json:"-"`
+		ChannelID string `valid:"channelID,has" json:"-"`
+		Amount    int    `valid:"amount" json:"-"`
+		Offset    int    `valid:"offset" json:"-"`
+	}
+)
+
+func (s *router) getChannelReadCursors(c *governor.Context) {
+	req := reqGetChannelReadCursors{
+		ServerID:  c.Param("id"),
+		ChannelID: c.Param("cid"),
+		Amount:    c.QueryInt("amount", -1),
+		Offset:    c.QueryInt("offset", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getChannelReadCursors(c.Ctx(), req.ServerID, req.ChannelID, req.Amount, req.Offset)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqCreateChannelAttachmentMsg struct {
+		Userid    string `valid:"userid,has" json:"-"`
+		ServerID  string `valid:"serverID,has" json:"-"`
+		ChannelID string `valid:"channelID,has" json:"-"`
+		Name      string `valid:"attachmentName" json:"-"`
+		Parentid  string `valid:"msgid,opt" json:"-"`
+	}
+)
+
+func (s *router) createChannelAttachmentMsg(c *governor.Context) {
+	req := reqCreateChannelAttachmentMsg{
+		Userid:    gate.GetCtxUserid(c),
+		ServerID:  c.Param("id"),
+		ChannelID: c.Param("cid"),
+		Name:      c.FormValue("name"),
+		Parentid:  c.FormValue("parentid"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	file, contentType, size, err := fileloader.LoadOpenFile(c, "file", s.s.attachMediaTypes)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			s.s.log.Err(c.Ctx(), kerrors.WithMsg(err, "Failed to close open file on request"))
+		}
+	}()
+	res, err := s.s.createChannelAttachmentMsg(c.Ctx(), req.ServerID, req.ChannelID, req.Userid, req.Name, file, contentType, size, req.Parentid)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusCreated, res)
+}
+
+type (
+	//forge:valid
+	reqGetChannelAttachment struct {
+		ServerID  string `valid:"serverID,has" json:"-"`
+		ChannelID string `valid:"channelID,has" json:"-"`
+		Msgid     string `valid:"msgid,has" json:"-"`
+	}
+)
+
+func (s *router) getChannelAttachmentObj(c *governor.Context, thumb bool) {
+	req := reqGetChannelAttachment{
+		ServerID:  c.Param("id"),
+		ChannelID: c.Param("cid"),
+		Msgid:     c.Param("msgid"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getChannelAttachment(c.Ctx(), req.ServerID, req.ChannelID, req.Msgid, thumb)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	s.writeAttachment(c, res)
+}
+
+func (s *router) getChannelAttachment(c *governor.Context) {
+	s.getChannelAttachmentObj(c, false)
+}
+
+func (s *router) getChannelAttachmentThumb(c *governor.Context) {
+	s.getChannelAttachmentObj(c, true)
+}
+
+func (s *router) writeAttachment(c *governor.Context, a *attachmentObj) {
+	defer func() {
+		if err := a.Obj.Close(); err != nil {
+			s.s.log.Err(c.Ctx(), kerrors.WithMsg(err, "Failed to close attachment"))
+		}
+	}()
+	// only images are displayed inline, and all other files are downloaded
+	disposition := "attachment"
+	if image.IsSupportedMediaType(a.ContentType) {
+		disposition = "inline"
+	}
+	c.SetHeader("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": a.Name}))
+	c.SetHeader("X-Content-Type-Options", "nosniff")
+	c.WriteFile(http.StatusOK, a.ContentType, a.Obj)
+}
+
+type (
+	//forge:valid
+	reqReportMsg struct {
+		Userid string `valid:"userid,has" json:"-"`
+		Chatid string `valid:"chatid,has" json:"-"`
+		Msgid  string `valid:"msgid,has" json:"-"`
+		Reason string `valid:"reportReason" json:"reason"`
+	}
+)
+
+func (s *router) reportDMMsg(c *governor.Context) {
+	var req reqReportMsg
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
+	}
+	req.Userid = gate.GetCtxUserid(c)
+	req.Chatid = c.Param("id")
+	req.Msgid = c.Param("msgid")
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.reportDMMsg(c.Ctx(), req.Userid, req.Chatid, req.Msgid, req.Reason)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusCreated, res)
+}
+
+func (s *router) reportGDMMsg(c *governor.Context) {
+	var req reqReportMsg
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
+	}
+	req.Userid = gate.GetCtxUserid(c)
+	req.Chatid = c.Param("id")
+	req.Msgid = c.Param("msgid")
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.reportGDMMsg(c.Ctx(), req.Userid, req.Chatid, req.Msgid, req.Reason)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusCreated, res)
+}
+
+type (
+	//forge:valid
+	reqReportChannelMsg struct {
+		Userid    string `valid:"userid,has" json:"-"`
+		ServerID  string `valid:"serverID,has" json:"-"`
+		ChannelID string `valid:"channelID,has" json:"-"`
+		Msgid     string `valid:"msgid,has" json:"-"`
+		Reason    string `valid:"reportReason" json:"reason"`
+	}
+)
+
+func (s *router) reportChannelMsg(c *governor.Context) {
+	var req reqReportChannelMsg
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
+	}
+	req.Userid = gate.GetCtxUserid(c)
+	req.ServerID = c.Param("id")
+	req.ChannelID = c.Param("cid")
+	req.Msgid = c.Param("msgid")
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.reportChannelMsg(c.Ctx(), req.ServerID, req.ChannelID, req.Userid, req.Msgid, req.Reason)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusCreated, res)
+}
+
+type (
+	//forge:valid
+	reqGetReports struct {
+		ServerID string `valid:"serverID,opt" json:"-"`
+		Status   string `valid:"reportStatus,opt" json:"-"`
+		Amount   int    `valid:"amount" json:"-"`
+		Offset   int    `valid:"offset" json:"-"`
+	}
+)
+
+func (s *router) getReports(c *governor.Context) {
+	req := reqGetReports{
+		ServerID: c.Query("serverid"),
+		Status:   c.Query("status"),
+		Amount:   c.QueryInt("amount", -1),
+		Offset:   c.QueryInt("offset", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getReports(c.Ctx(), req.ServerID, req.Status, req.Amount, req.Offset)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqGetServerReports struct {
+		Userid   string `valid:"userid,has" json:"-"`
+		ServerID string `valid:"serverID,has" json:"-"`
+		Status   string `valid:"reportStatus,opt" json:"-"`
+		Amount   int    `valid:"amount" json:"-"`
+		Offset   int    `valid:"offset" json:"-"`
+	}
+)
+
+func (s *router) getServerReports(c *governor.Context) {
+	req := reqGetServerReports{
+		Userid:   gate.GetCtxUserid(c),
+		ServerID: c.Param("id"),
+		Status:   c.Query("status"),
+		Amount:   c.QueryInt("amount", -1),
+		Offset:   c.QueryInt("offset", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getServerReports(c.Ctx(), req.ServerID, req.Userid, req.Status, req.Amount, req.Offset)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqGetReport struct {
+		Reportid string `valid:"reportid,has" json:"-"`
+	}
+)
+
+func (s *router) getReport(c *governor.Context) {
+	req := reqGetReport{
+		Reportid: c.Param("rid"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getAdminReport(c.Ctx(), req.Reportid)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqGetServerReport struct {
+		Userid   string `valid:"userid,has" json:"-"`
+		ServerID string `valid:"serverID,has" json:"-"`
+		Reportid string `valid:"reportid,has" json:"-"`
+	}
+)
+
+func (s *router) getServerReport(c *governor.Context) {
+	req := reqGetServerReport{
+		Userid:   gate.GetCtxUserid(c),
+		ServerID: c.Param("id"),
+		Reportid: c.Param("rid"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getServerReport(c.Ctx(), req.ServerID, req.Userid, req.Reportid)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqResolveReport struct {
+		Userid    string `valid:"userid,has" json:"-"`
+		ServerID  string `valid:"serverID,opt" json:"-"`
+		Reportid  string `valid:"reportid,has" json:"-"`
+		Status    string `valid:"resolveStatus" json:"status"`
+		DeleteMsg bool   `json:"delete_msg"`
+	}
+)
+
+func (s *router) resolveReport(c *governor.Context) {
+	var req reqResolveReport
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
+	}
+	req.Userid = gate.GetCtxUserid(c)
+	req.Reportid = c.Param("rid")
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	if err := s.s.resolveAdminReport(c.Ctx(), req.Userid, req.Reportid, req.Status, req.DeleteMsg); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteStatus(http.StatusNoContent)
+}
+
+func (s *router) resolveServerReport(c *governor.Context) {
+	var req reqResolveReport
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
+	}
+	req.Userid = gate.GetCtxUserid(c)
+	req.ServerID = c.Param("id")
+	req.Reportid = c.Param("rid")
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	if err := s.s.resolveServerReport(c.Ctx(), req.ServerID, req.Userid, req.Reportid, req.Status, req.DeleteMsg); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteStatus(http.StatusNoContent)
+}
+
+type (
+	//forge:valid
+	reqGetMutes struct {
+		Userid   string `valid:"userid,has" json:"-"`
+		ServerID string `valid:"serverID,opt" json:"-"`
+		Amount   int    `valid:"amount" json:"-"`
+		Offset   int    `valid:"offset" json:"-"`
+	}
+)
+
+func (s *router) getMutes(c *governor.Context) {
+	req := reqGetMutes{
+		Userid: gate.GetCtxUserid(c),
+		Amount: c.QueryInt("amount", -1),
+		Offset: c.QueryInt("offset", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getMutes(c.Ctx(), "", req.Amount, req.Offset)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+func (s *router) getServerMutes(c *governor.Context) {
+	req := reqGetMutes{
+		Userid:   gate.GetCtxUserid(c),
+		ServerID: c.Param("id"),
+		Amount:   c.QueryInt("amount", -1),
+		Offset:   c.QueryInt("offset", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getServerMutes(c.Ctx(), req.ServerID, req.Userid, req.Amount, req.Offset)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqMuteUser struct {
+		Userid   string `valid:"userid,has" json:"-"`
+		ServerID string `valid:"serverID,opt" json:"-"`
+		Target   string `valid:"userid,has" json:"-"`
+		Duration int64  `valid:"muteDuration" json:"duration"`
+		Reason   string `valid:"banReason" json:"reason"`
+	}
+)
+
+func (s *router) muteUser(c *governor.Context) {
+	var req reqMuteUser
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
+	}
+	req.Userid = gate.GetCtxUserid(c)
+	req.Target = c.Param("uid")
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.muteUser(c.Ctx(), "", req.Userid, req.Target, req.Duration, req.Reason)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+func (s *router) muteServerUser(c *governor.Context) {
+	var req reqMuteUser
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
+	}
+	req.Userid = gate.GetCtxUserid(c)
+	req.ServerID = c.Param("id")
+	req.Target = c.Param("uid")
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.muteServerUser(c.Ctx(), req.ServerID, req.Userid, req.Target, req.Duration, req.Reason)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqUnmuteUser struct {
+		Userid   string `valid:"userid,has" json:"-"`
+		ServerID string `valid:"serverID,opt" json:"-"`
+		Target   string `valid:"userid,has" json:"-"`
+	}
+)
+
+func (s *router) unmuteUser(c *governor.Context) {
+	req := reqUnmuteUser{
+		Userid: gate.GetCtxUserid(c),
+		Target: c.Param("uid"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	if err := s.s.unmuteUser(c.Ctx(), "", req.Userid, req.Target); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteStatus(http.StatusNoContent)
+}
+
+func (s *router) unmuteServerUser(c *governor.Context) {
+	req := reqUnmuteUser{
+		Userid:   gate.GetCtxUserid(c),
+		ServerID: c.Param("id"),
+		Target:   c.Param("uid"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	if err := s.s.unmuteServerUser(c.Ctx(), req.ServerID, req.Userid, req.Target); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteStatus(http.StatusNoContent)
+}
+
+type (
+	//forge:valid
+	reqGetAudits struct {
+		Userid   string `valid:"userid,has" json:"-"`
+		ServerID string `valid:"serverID,opt" json:"-"`
+		Amount   int    `valid:"amount" json:"-"`
+		Offset   int    `valid:"offset" json:"-"`
+	}
+)
+
+func (s *router) getAudits(c *governor.Context) {
+	req := reqGetAudits{
+		Userid:   gate.GetCtxUserid(c),
+		ServerID: c.Query("serverid"),
+		Amount:   c.QueryInt("amount", -1),
+		Offset:   c.QueryInt("offset", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getAudits(c.Ctx(), req.ServerID, req.Amount, req.Offset)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+func (s *router) getServerAudits(c *governor.Context) {
+	req := reqGetAudits{
+		Userid:   gate.GetCtxUserid(c),
+		ServerID: c.Param("id"),
+		Amount:   c.QueryInt("amount", -1),
+		Offset:   c.QueryInt("offset", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getServerAudits(c.Ctx(), req.ServerID, req.Userid, req.Amount, req.Offset)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqGetBots struct {
+		Userid string `valid:"userid,has" json:"-"`
+		Amount int    `valid:"amount" json:"-"`
+		Offset int    `valid:"offset" json:"-"`
+	}
+)
+
+func (s *router) getBots(c *governor.Context) {
+	req := reqGetBots{
+		Userid: gate.GetCtxUserid(c),
+		Amount: c.QueryInt("amount", -1),
+		Offset: c.QueryInt("offset", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getBots(c.Ctx(), req.Userid, req.Amount, req.Offset)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqCreateBot struct {
+		Userid string `valid:"userid,has" json:"-"`
+		Name   string `valid:"botName,has" json:"name"`
+		Avatar string `valid:"avatar" json:"avatar"`
+	}
+)
+
+func (s *router) createBot(c *governor.Context) {
+	var req reqCreateBot
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
+	}
+	req.Userid = gate.GetCtxUserid(c)
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.createBot(c.Ctx(), req.Userid, req.Name, req.Avatar)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusCreated, res)
 }
 
 type (
 	//forge:valid
-	reqGetServer struct {
-		ServerID string `valid:"serverID,has" json:"-"`
+	reqBotID struct {
+		Userid string `valid:"userid,has" json:"-"`
+		Botid  string `valid:"botid,has" json:"-"`
 	}
 )
 
-func (s *router) getServer(c *governor.Context) {
-	req := reqGetServer{
-		ServerID: c.Param("id"),
+func (s *router) getBot(c *governor.Context) {
+	req := reqBotID{
+		Userid: gate.GetCtxUserid(c),
+		Botid:  c.Param("bid"),
 	}
 	if err := req.valid(); err != nil {
 		c.WriteError(err)
 		return
 	}
-	res, err := s.s.getServer(c.Ctx(), req.ServerID)
+	res, err := s.s.getBot(c.Ctx(), req.Userid, req.Botid)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -678,45 +3009,43 @@ func (s *router) getServer(c *governor.Context) {
 
 type (
 	//forge:valid
-	reqCreateServer struct {
-		ServerID string `valid:"serverID,has" json:"-"`
-		Name     string `valid:"name" json:"name"`
-		Desc     string `valid:"desc" json:"desc"`
-		Theme    string `valid:"theme" json:"theme"`
+	reqUpdateBot struct {
+		Userid string `valid:"userid,has" json:"-"`
+		Botid  string `valid:"botid,has" json:"-"`
+		Name   string `valid:"botName,has" json:"name"`
+		Avatar string `valid:"avatar" json:"avatar"`
 	}
 )
 
-func (s *router) createServer(c *governor.Context) {
-	var req reqCreateServer
+func (s *router) updateBot(c *governor.Context) {
+	var req reqUpdateBot
 	if err := c.Bind(&req, false); err != nil {
 		c.WriteError(err)
 		return
 	}
-	req.ServerID = c.Param("id")
+	req.Userid = gate.GetCtxUserid(c)
+	req.Botid = c.Param("bid")
 	if err := req.valid(); err != nil {
 		c.WriteError(err)
 		return
 	}
-	res, err := s.s.createServer(c.Ctx(), req.ServerID, req.Name, req.Desc, req.Theme)
-	if err != nil {
+	if err := s.s.updateBot(c.Ctx(), req.Userid, req.Botid, req.Name, req.Avatar); err != nil {
 		c.WriteError(err)
 		return
 	}
-	c.WriteJSON(http.StatusCreated, res)
+	c.WriteStatus(http.StatusNoContent)
 }
 
-func (s *router) updateServer(c *governor.Context) {
-	var req reqCreateServer
-	if err := c.Bind(&req, false); err != nil {
-		c.WriteError(err)
-		return
+func (s *router) deleteBot(c *governor.Context) {
+	req := reqBotID{
+		Userid: gate.GetCtxUserid(c),
+		Botid:  c.Param("bid"),
 	}
-	req.ServerID = c.Param("id")
 	if err := req.valid(); err != nil {
 		c.WriteError(err)
 		return
 	}
-	if err := s.s.updateServer(c.Ctx(), req.ServerID, req.Name, req.Desc, req.Theme); err != nil {
+	if err := s.s.deleteBot(c.Ctx(), req.Userid, req.Botid); err != nil {
 		c.WriteError(err)
 		return
 	}
@@ -725,22 +3054,26 @@ func (s *router) updateServer(c *governor.Context) {
 
 type (
 	//forge:valid
-	reqGetChannel struct {
-		ServerID  string `valid:"serverID,has" json:"-"`
-		ChannelID string `valid:"channelID,has" json:"-"`
+	reqGetBotKeys struct {
+		Userid string `valid:"userid,has" json:"-"`
+		Botid  string `valid:"botid,has" json:"-"`
+		Amount int    `valid:"amount" json:"-"`
+		Offset int    `valid:"offset" json:"-"`
 	}
 )
 
-func (s *router) getChannel(c *governor.Context) {
-	req := reqGetChannel{
-		ServerID:  c.Param("id"),
-		ChannelID: c.Param("cid"),
+func (s *router) getBotKeys(c *governor.Context) {
+	req := reqGetBotKeys{
+		Userid: gate.GetCtxUserid(c),
+		Botid:  c.Param("bid"),
+		Amount: c.QueryInt("amount", -1),
+		Offset: c.QueryInt("offset", -1),
 	}
 	if err := req.valid(); err != nil {
 		c.WriteError(err)
 		return
 	}
-	res, err := s.s.getChannel(c.Ctx(), req.ServerID, req.ChannelID)
+	res, err := s.s.getBotKeys(c.Ctx(), req.Userid, req.Botid, req.Amount, req.Offset)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -750,81 +3083,122 @@ func (s *router) getChannel(c *governor.Context) {
 
 type (
 	//forge:valid
-	reqGetChannels struct {
-		ServerID string `valid:"serverID,has" json:"-"`
-		Amount   int    `valid:"amount" json:"-"`
-		Offset   int    `valid:"offset" json:"-"`
+	reqCreateBotKey struct {
+		Userid string `valid:"userid,has" json:"-"`
+		Botid  string `valid:"botid,has" json:"-"`
+		Name   string `valid:"name" json:"name"`
+		Desc   string `valid:"desc" json:"desc"`
 	}
 )
 
-func (s *router) getChannels(c *governor.Context) {
-	req := reqGetChannels{
-		ServerID: c.Param("id"),
-		Amount:   c.QueryInt("amount", -1),
-		Offset:   c.QueryInt("offset", -1),
+func (s *router) createBotKey(c *governor.Context) {
+	var req reqCreateBotKey
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
 	}
+	req.Userid = gate.GetCtxUserid(c)
+	req.Botid = c.Param("bid")
 	if err := req.valid(); err != nil {
 		c.WriteError(err)
 		return
 	}
-	res, err := s.s.getChannels(c.Ctx(), req.ServerID, "", req.Amount, req.Offset)
+	res, err := s.s.createBotKey(c.Ctx(), req.Userid, req.Botid, req.Name, req.Desc)
 	if err != nil {
 		c.WriteError(err)
 		return
 	}
-	c.WriteJSON(http.StatusOK, res)
+	c.WriteJSON(http.StatusCreated, res)
 }
 
 type (
 	//forge:valid
-	reqSearchChannels struct {
-		ServerID string `valid:"serverID,has" json:"-"`
-		Prefix   string `valid:"channelID,has" json:"-"`
-		Amount   int    `valid:"amount" json:"-"`
+	reqBotKeyID struct {
+		Userid string `valid:"userid,has" json:"-"`
+		Botid  string `valid:"botid,has" json:"-"`
+		Keyid  string `valid:"keyid,has" json:"-"`
 	}
 )
 
-func (s *router) searchChannels(c *governor.Context) {
-	req := reqSearchChannels{
-		ServerID: c.Param("id"),
-		Prefix:   c.Query("prefix"),
-		Amount:   c.QueryInt("amount", -1),
+func (s *router) deleteBotKey(c *governor.Context) {
+	req := reqBotKeyID{
+		Userid: gate.GetCtxUserid(c),
+		Botid:  c.Param("bid"),
+		Keyid:  c.Param("kid"),
 	}
 	if err := req.valid(); err != nil {
 		c.WriteError(err)
 		return
 	}
-	res, err := s.s.getChannels(c.Ctx(), req.ServerID, req.Prefix, req.Amount, 0)
-	if err != nil {
+	if err := s.s.deleteBotKey(c.Ctx(), req.Userid, req.Botid, req.Keyid); err != nil {
 		c.WriteError(err)
 		return
 	}
-	c.WriteJSON(http.StatusOK, res)
+	c.WriteStatus(http.StatusNoContent)
 }
 
 type (
 	//forge:valid
-	reqCreateChannel struct {
-		ServerID  string `valid:"serverID,has" json:"-"`
-		ChannelID string `valid:"channelID" json:"channelid"`
-		Name      string `valid:"name" json:"name"`
-		Desc      string `valid:"desc" json:"desc"`
-		Theme     string `valid:"theme" json:"theme"`
+	reqGetBotChannels struct {
+		Botid  string `valid:"botid,has" json:"-"`
+		Amount int    `valid:"amount" json:"-"`
+		Offset int    `valid:"offset" json:"-"`
 	}
 )
 
-func (s *router) createChannel(c *governor.Context) {
-	var req reqCreateChannel
+func (s *router) getBotChannels(c *governor.Context) {
+	req := reqGetBotChannels{
+		Botid:  gate.GetCtxUserid(c),
+		Amount: c.QueryInt("amount", -1),
+		Offset: c.QueryInt("offset", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getBotChannels(c.Ctx(), req.Botid, req.Amount, req.Offset)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+func (s *router) getBotChannelMsgs(c *governor.Context) {
+	req := reqGetChannelMsgs{
+		Userid:    gate.GetCtxUserid(c),
+		ServerID:  c.Param("id"),
+		ChannelID: c.Param("cid"),
+		Kind:      c.Query("kind"),
+		Before:    c.Query("before"),
+		Amount:    c.QueryInt("amount", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getBotChannelMsgs(c.Ctx(), req.Userid, req.ServerID, req.ChannelID, req.Kind, req.Before, req.Amount)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+func (s *router) createBotChannelMsg(c *governor.Context) {
+	var req reqCreateChannelMsg
 	if err := c.Bind(&req, false); err != nil {
 		c.WriteError(err)
 		return
 	}
+	req.Userid = gate.GetCtxUserid(c)
 	req.ServerID = c.Param("id")
+	req.ChannelID = c.Param("cid")
 	if err := req.valid(); err != nil {
 		c.WriteError(err)
 		return
 	}
-	res, err := s.s.createChannel(c.Ctx(), req.ServerID, req.ChannelID, req.Name, req.Desc, req.Theme)
+	res, err := s.s.createBotChannelMsg(c.Ctx(), req.Userid, req.ServerID, req.ChannelID, req.Kind, req.Value, req.Parentid)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -834,44 +3208,73 @@ func (s *router) createChannel(c *governor.Context) {
 
 type (
 	//forge:valid
-	reqUpdateChannel struct {
+	reqGetChannelBots struct {
 		ServerID  string `valid:"serverID,has" json:"-"`
 		ChannelID string `valid:"channelID,has" json:"-"`
-		Name      string `valid:"name" json:"name"`
-		Desc      string `valid:"desc" json:"desc"`
-		Theme     string `valid:"theme" json:"theme"`
+		Amount    int    `valid:"amount" json:"-"`
+		Offset    int    `valid:"offset" json:"-"`
 	}
 )
 
-func (s *router) updateChannel(c *governor.Context) {
-	var req reqUpdateChannel
-	if err := c.Bind(&req, false); err != nil {
+func (s *router) getChannelBots(c *governor.Context) {
+	req := reqGetChannelBots{
+		ServerID:  c.Param("id"),
+		ChannelID: c.Param("cid"),
+		Amount:    c.QueryInt("amount", -1),
+		Offset:    c.QueryInt("offset", -1),
+	}
+	if err := req.valid(); err != nil {
 		c.WriteError(err)
 		return
 	}
-	req.ServerID = c.Param("id")
-	req.ChannelID = c.Param("cid")
+	res, err := s.s.getChannelBots(c.Ctx(), req.ServerID, req.ChannelID, req.Amount, req.Offset)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqChannelBot struct {
+		Userid    string `valid:"userid,has" json:"-"`
+		ServerID  string `valid:"serverID,has" json:"-"`
+		ChannelID string `valid:"channelID,has" json:"-"`
+		Botid     string `valid:"botid,has" json:"-"`
+	}
+)
+
+func (s *router) addChannelBot(c *governor.Context) {
+	req := reqChannelBot{
+		Userid:    gate.GetCtxUserid(c),
+		ServerID:  c.Param("id"),
+		ChannelID: c.Param("cid"),
+		Botid:     c.Param("bid"),
+	}
 	if err := req.valid(); err != nil {
 		c.WriteError(err)
 		return
 	}
-	if err := s.s.updateChannel(c.Ctx(), req.ServerID, req.ChannelID, req.Name, req.Desc, req.Theme); err != nil {
+	if err := s.s.addChannelBot(c.Ctx(), req.ServerID, req.ChannelID, req.Userid, req.Botid); err != nil {
 		c.WriteError(err)
 		return
 	}
 	c.WriteStatus(http.StatusNoContent)
 }
 
-func (s *router) deleteChannel(c *governor.Context) {
-	req := reqGetChannel{
+func (s *router) rmChannelBot(c *governor.Context) {
+	req := reqChannelBot{
+		Userid:    gate.GetCtxUserid(c),
 		ServerID:  c.Param("id"),
 		ChannelID: c.Param("cid"),
+		Botid:     c.Param("bid"),
 	}
 	if err := req.valid(); err != nil {
 		c.WriteError(err)
 		return
 	}
-	if err := s.s.deleteChannel(c.Ctx(), req.ServerID, req.ChannelID); err != nil {
+	if err := s.s.rmChannelBot(c.Ctx(), req.ServerID, req.ChannelID, req.Userid, req.Botid); err != nil {
 		c.WriteError(err)
 		return
 	}
@@ -880,17 +3283,48 @@ func (s *router) deleteChannel(c *governor.Context) {
 
 type (
 	//forge:valid
-	reqCreateChannelMsg struct {
+	reqGetWebhooks struct {
 		Userid    string `valid:"userid,has" json:"-"`
 		ServerID  string `valid:"serverID,has" json:"-"`
 		ChannelID string `valid:"channelID,has" json:"-"`
-		Kind      string `valid:"msgkind" json:"kind"`
-		Value     string `valid:"msgvalue" json:"value"`
+		Amount    int    `valid:"amount" json:"-"`
+		Offset    int    `valid:"offset" json:"-"`
 	}
 )
 
-func (s *router) createChannelMsg(c *governor.Context) {
-	var req reqCreateChannelMsg
+func (s *router) getWebhooks(c *governor.Context) {
+	req := reqGetWebhooks{
+		Userid:    gate.GetCtxUserid(c),
+		ServerID:  c.Param("id"),
+		ChannelID: c.Param("cid"),
+		Amount:    c.QueryInt("amount", -1),
+		Offset:    c.QueryInt("offset", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getWebhooks(c.Ctx(), req.ServerID, req.ChannelID, req.Userid, req.Amount, req.Offset)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqCreateWebhook struct {
+		Userid    string `valid:"userid,has" json:"-"`
+		ServerID  string `valid:"serverID,has" json:"-"`
+		ChannelID string `valid:"channelID,has" json:"-"`
+		Name      string `valid:"botName,has" json:"name"`
+		Avatar    string `valid:"avatar" json:"avatar"`
+	}
+)
+
+func (s *router) createWebhook(c *governor.Context) {
+	var req reqCreateWebhook
 	if err := c.Bind(&req, false); err != nil {
 		c.WriteError(err)
 		return
@@ -902,7 +3336,7 @@ func (s *router) createChannelMsg(c *governor.Context) {
 		c.WriteError(err)
 		return
 	}
-	res, err := s.s.createChannelMsg(c.Ctx(), req.ServerID, req.ChannelID, req.Userid, req.Kind, req.Value)
+	res, err := s.s.createWebhook(c.Ctx(), req.ServerID, req.ChannelID, req.Userid, req.Name, req.Avatar)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -912,62 +3346,126 @@ func (s *router) createChannelMsg(c *governor.Context) {
 
 type (
 	//forge:valid
-	reqGetChannelMsgs struct {
+	reqUpdateWebhook struct {
+		Userid    string `valid:"userid,has" json:"-"`
 		ServerID  string `valid:"serverID,has" json:"-"`
 		ChannelID string `valid:"channelID,has" json:"-"`
-		Kind      string `valid:"msgkind,opt" json:"-"`
-		Before    string `valid:"msgid,opt" json:"-"`
-		Amount    int    `valid:"amount" json:"-"`
+		Webhookid string `valid:"webhookid,has" json:"-"`
+		Name      string `valid:"botName,has" json:"name"`
+		Avatar    string `valid:"avatar" json:"avatar"`
 	}
 )
 
-func (s *router) getChannelMsgs(c *governor.Context) {
-	req := reqGetChannelMsgs{
-		ServerID:  c.Param("id"),
-		ChannelID: c.Param("cid"),
-		Kind:      c.Query("kind"),
-		Before:    c.Query("before"),
-		Amount:    c.QueryInt("amount", -1),
+func (s *router) updateWebhook(c *governor.Context) {
+	var req reqUpdateWebhook
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
 	}
+	req.Userid = gate.GetCtxUserid(c)
+	req.ServerID = c.Param("id")
+	req.ChannelID = c.Param("cid")
+	req.Webhookid = c.Param("wid")
 	if err := req.valid(); err != nil {
 		c.WriteError(err)
 		return
 	}
-	res, err := s.s.getChannelMsgs(c.Ctx(), req.ServerID, req.ChannelID, req.Kind, req.Before, req.Amount)
-	if err != nil {
+	if err := s.s.updateWebhook(c.Ctx(), req.ServerID, req.ChannelID, req.Userid, req.Webhookid, req.Name, req.Avatar); err != nil {
 		c.WriteError(err)
 		return
 	}
-	c.WriteJSON(http.StatusOK, res)
+	c.WriteStatus(http.StatusNoContent)
 }
 
 type (
 	//forge:valid
-	reqDelChannelMsg struct {
+	reqWebhookID struct {
+		Userid    string `valid:"userid,has" json:"-"`
 		ServerID  string `valid:"serverID,has" json:"-"`
 		ChannelID string `valid:"channelID,has" json:"-"`
-		Msgid     string `valid:"msgid,has" json:"-"`
+		Webhookid string `valid:"webhookid,has" json:"-"`
 	}
 )
 
-func (s *router) deleteChannelMsg(c *governor.Context) {
-	req := reqDelChannelMsg{
+func (s *router) deleteWebhook(c *governor.Context) {
+	req := reqWebhookID{
+		Userid:    gate.GetCtxUserid(c),
 		ServerID:  c.Param("id"),
 		ChannelID: c.Param("cid"),
-		Msgid:     c.Param("msgid"),
+		Webhookid: c.Param("wid"),
 	}
 	if err := req.valid(); err != nil {
 		c.WriteError(err)
 		return
 	}
-	if err := s.s.deleteChannelMsg(c.Ctx(), req.ServerID, req.ChannelID, req.Msgid); err != nil {
+	if err := s.s.deleteWebhook(c.Ctx(), req.ServerID, req.ChannelID, req.Userid, req.Webhookid); err != nil {
 		c.WriteError(err)
 		return
 	}
 	c.WriteStatus(http.StatusNoContent)
 }
 
+type (
+	//forge:valid
+	reqExecWebhook struct {
+		Webhookid string `valid:"webhookid,has" json:"-"`
+		Key       string `valid:"webhookKey,has" json:"-"`
+		Name      string `valid:"botName,opt" json:"name"`
+		Avatar    string `valid:"avatar" json:"avatar"`
+		Value     string `valid:"msgvalue" json:"value"`
+	}
+)
+
+func (s *router) execWebhook(c *governor.Context) {
+	var req reqExecWebhook
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
+	}
+	req.Webhookid = c.Param("wid")
+	req.Key = c.Param("key")
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.execWebhook(c.Ctx(), req.Webhookid, req.Key, req.Name, req.Avatar, req.Value)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusCreated, res)
+}
+
+type (
+	//forge:valid
+	reqTypingSignal struct {
+		Userid    string `valid:"userid,has" json:"-"`
+		Kind      string `valid:"typingKind" json:"kind"`
+		Chatid    string `valid:"chatid,opt" json:"chatid"`
+		ServerID  string `valid:"serverID,opt" json:"serverid"`
+		ChannelID string `valid:"channelID,opt" json:"channelid"`
+		Typing    bool   `json:"typing"`
+	}
+)
+
 func (s *router) serverMember(c *governor.Context, userid string) (string, bool, bool) {
+	serverid := c.Param("id")
+	if err := validhasServerID(serverid); err != nil {
+		return "", false, false
+	}
+	ok, err := s.s.checkServerMember(c.Ctx(), serverid, userid)
+	if err != nil {
+		s.s.log.Err(c.Ctx(), err)
+		return "", false, false
+	}
+	return "", ok, true
+}
+
+// serverOrg returns the org of a server
+//
+// Server ids share the org namespace, so only a moderator of the org of the
+// same name may create a server, and becomes its owner.
+func (s *router) serverOrg(c *governor.Context, userid string) (string, bool, bool) {
 	serverid := c.Param("id")
 	if err := validhasServerID(serverid); err != nil {
 		return "", false, false
@@ -975,6 +3473,22 @@ func (s *router) serverMember(c *governor.Context, userid string) (string, bool,
 	return rank.ToOrgName(serverid), false, true
 }
 
+// bot is a middleware function to validate if the request is made by a bot
+// account authenticated with an api key
+func (s *router) bot(scope string) governor.MiddlewareCtx {
+	return s.s.gate.AuthenticateCtx(func(c gate.Context) bool {
+		if c.IsSystem() {
+			return false
+		}
+		ok, err := s.s.checkBot(c.Ctx().Ctx(), c.Userid())
+		if err != nil {
+			s.s.log.Err(c.Ctx().Ctx(), err)
+			return false
+		}
+		return ok
+	}, scope)
+}
+
 func (s *router) mountRoutes(r governor.Router) {
 	m := governor.NewMethodRouter(r)
 
@@ -989,6 +3503,9 @@ func (s *router) mountRoutes(r governor.Router) {
 	m.PostCtx("/friend/invitation/id/{id}/accept", s.acceptFriendInvitation, gate.User(s.s.gate, scopeFriendWrite), s.rt)
 	m.DeleteCtx("/friend/invitation/id/{id}", s.deleteUserFriendInvitation, gate.User(s.s.gate, scopeFriendWrite), s.rt)
 	m.DeleteCtx("/friend/invitation/invited/{id}", s.deleteInvitedFriendInvitation, gate.User(s.s.gate, scopeFriendWrite), s.rt)
+	m.GetCtx("/friend/block", s.getBlocked, gate.User(s.s.gate, scopeFriendRead), s.rt)
+	m.PutCtx("/friend/block/id/{id}", s.blockUser, gate.User(s.s.gate, scopeFriendWrite), s.rt)
+	m.DeleteCtx("/friend/block/id/{id}", s.unblockUser, gate.User(s.s.gate, scopeFriendWrite), s.rt)
 
 	scopeChatRead := s.s.scopens + ".chat:read"
 	scopeChatWrite := s.s.scopens + ".chat:write"
@@ -999,7 +3516,18 @@ func (s *router) mountRoutes(r governor.Router) {
 	m.PutCtx("/dm/id/{id}", s.updateDM, gate.User(s.s.gate, scopeChatAdminWrite), s.rt)
 	m.PostCtx("/dm/id/{id}/msg", s.createDMMsg, gate.User(s.s.gate, scopeChatWrite), s.rt)
 	m.GetCtx("/dm/id/{id}/msg", s.getDMMsgs, gate.User(s.s.gate, scopeChatRead), s.rt)
+	m.GetCtx("/dm/id/{id}/msg/search", s.searchDMMsgs, gate.User(s.s.gate, scopeChatRead), s.rt)
+	m.PutCtx("/dm/id/{id}/msg/id/{msgid}", s.editDMMsg, gate.User(s.s.gate, scopeChatWrite), s.rt)
 	m.DeleteCtx("/dm/id/{id}/msg/id/{msgid}", s.deleteDMMsg, gate.User(s.s.gate, scopeChatWrite), s.rt)
+	m.PostCtx("/dm/id/{id}/msg/id/{msgid}/report", s.reportDMMsg, gate.User(s.s.gate, scopeChatWrite), s.rt)
+	m.GetCtx("/dm/id/{id}/msg/id/{msgid}/edits", s.getDMMsgEdits, gate.User(s.s.gate, scopeChatRead), s.rt)
+	m.PostCtx("/dm/id/{id}/msg/id/{msgid}/reaction", s.reactDMMsg, gate.User(s.s.gate, scopeChatWrite), s.rt)
+	m.GetCtx("/dm/id/{id}/msg/id/{msgid}/thread", s.getDMThreadMsgs, gate.User(s.s.gate, scopeChatRead), s.rt)
+	m.PostCtx("/dm/id/{id}/read", s.readDMMsg, gate.User(s.s.gate, scopeChatWrite), s.rt)
+	m.GetCtx("/dm/id/{id}/read", s.getDMReadCursors, gate.User(s.s.gate, scopeChatRead), s.rt)
+	m.PostCtx("/dm/id/{id}/msg/attachment", s.createDMAttachmentMsg, gate.User(s.s.gate, scopeChatWrite), s.rt)
+	m.GetCtx("/dm/id/{id}/msg/id/{msgid}/attachment", s.getDMAttachment, gate.User(s.s.gate, scopeChatRead), s.rt)
+	m.GetCtx("/dm/id/{id}/msg/id/{msgid}/attachment/thumb", s.getDMAttachmentThumb, gate.User(s.s.gate, scopeChatRead), s.rt)
 
 	m.GetCtx("/gdm", s.getLatestGDMs, gate.User(s.s.gate, scopeChatRead), s.rt)
 	m.GetCtx("/gdm/ids", s.getGDMs, gate.User(s.s.gate, scopeChatRead), s.rt)
@@ -1007,25 +3535,113 @@ func (s *router) mountRoutes(r governor.Router) {
 	m.PostCtx("/gdm", s.createGDM, gate.User(s.s.gate, scopeChatAdminWrite), s.rt)
 	m.PutCtx("/gdm/id/{id}", s.updateGDM, gate.User(s.s.gate, scopeChatAdminWrite), s.rt)
 	m.DeleteCtx("/gdm/id/{id}", s.deleteGDM, gate.User(s.s.gate, scopeChatAdminWrite), s.rt)
+	m.GetCtx("/gdm/id/{id}/retention", s.getGDMRetention, gate.User(s.s.gate, scopeChatRead), s.rt)
+	m.PutCtx("/gdm/id/{id}/retention", s.updateGDMRetention, gate.User(s.s.gate, scopeChatAdminWrite), s.rt)
 	m.PatchCtx("/gdm/id/{id}/member/add", s.addGDMMember, gate.User(s.s.gate, scopeChatAdminWrite), s.rt)
 	m.PatchCtx("/gdm/id/{id}/member/rm", s.rmGDMMembers, gate.User(s.s.gate, scopeChatAdminWrite), s.rt)
 	m.PostCtx("/gdm/id/{id}/msg", s.createGDMMsg, gate.User(s.s.gate, scopeChatWrite), s.rt)
 	m.GetCtx("/gdm/id/{id}/msg", s.getGDMMsgs, gate.User(s.s.gate, scopeChatRead), s.rt)
+	m.GetCtx("/gdm/id/{id}/msg/search", s.searchGDMMsgs, gate.User(s.s.gate, scopeChatRead), s.rt)
+	m.PutCtx("/gdm/id/{id}/msg/id/{msgid}", s.editGDMMsg, gate.User(s.s.gate, scopeChatWrite), s.rt)
 	m.DeleteCtx("/gdm/id/{id}/msg/id/{msgid}", s.deleteGDMMsg, gate.User(s.s.gate, scopeChatWrite), s.rt)
+	m.PostCtx("/gdm/id/{id}/msg/id/{msgid}/report", s.reportGDMMsg, gate.User(s.s.gate, scopeChatWrite), s.rt)
+	m.GetCtx("/gdm/id/{id}/msg/id/{msgid}/edits", s.getGDMMsgEdits, gate.User(s.s.gate, scopeChatRead), s.rt)
+	m.PostCtx("/gdm/id/{id}/msg/id/{msgid}/reaction", s.reactGDMMsg, gate.User(s.s.gate, scopeChatWrite), s.rt)
+	m.GetCtx("/gdm/id/{id}/msg/id/{msgid}/thread", s.getGDMThreadMsgs, gate.User(s.s.gate, scopeChatRead), s.rt)
+	m.PostCtx("/gdm/id/{id}/read", s.readGDMMsg, gate.User(s.s.gate, scopeChatWrite), s.rt)
+	m.GetCtx("/gdm/id/{id}/read", s.getGDMReadCursors, gate.User(s.s.gate, scopeChatRead), s.rt)
+	m.PostCtx("/gdm/id/{id}/msg/attachment", s.createGDMAttachmentMsg, gate.User(s.s.gate, scopeChatWrite), s.rt)
+	m.GetCtx("/gdm/id/{id}/msg/id/{msgid}/attachment", s.getGDMAttachment, gate.User(s.s.gate, scopeChatRead), s.rt)
+	m.GetCtx("/gdm/id/{id}/msg/id/{msgid}/attachment/thumb", s.getGDMAttachmentThumb, gate.User(s.s.gate, scopeChatRead), s.rt)
 
 	scopeServerRead := s.s.scopens + ".server:read"
 	scopeServerWrite := s.s.scopens + ".server:write"
 	scopeServerChatWrite := s.s.scopens + ".server.chat:write"
+	m.GetCtx("/server", s.getUserServers, gate.User(s.s.gate, scopeServerRead), s.rt)
+	m.GetCtx("/server/invite/id/{code}", s.getServerByInvite, gate.User(s.s.gate, scopeServerRead), s.rt)
+	m.PostCtx("/server/invite/id/{code}", s.joinServer, gate.User(s.s.gate, scopeServerWrite), s.rt)
+	m.PostCtx("/server/backfill/member", s.backfillServerMembers, gate.System(s.s.gate, scopeServerWrite), s.rt)
 	m.GetCtx("/server/id/{id}", s.getServer, gate.MemberF(s.s.gate, s.serverMember, scopeServerRead), s.rt)
-	m.PostCtx("/server/id/{id}", s.createServer, gate.MemberF(s.s.gate, s.serverMember, scopeServerWrite), s.rt)
+	m.PostCtx("/server/id/{id}", s.createServer, gate.ModF(s.s.gate, s.serverOrg, scopeServerWrite), s.rt)
 	m.PutCtx("/server/id/{id}", s.updateServer, gate.MemberF(s.s.gate, s.serverMember, scopeServerWrite), s.rt)
+	m.GetCtx("/server/id/{id}/member", s.getServerMembers, gate.MemberF(s.s.gate, s.serverMember, scopeServerRead), s.rt)
+	m.GetCtx("/server/id/{id}/presence", s.getServerPresence, gate.MemberF(s.s.gate, s.serverMember, scopeServerRead), s.rt)
+	m.DeleteCtx("/server/id/{id}/member", s.leaveServer, gate.MemberF(s.s.gate, s.serverMember, scopeServerWrite), s.rt)
+	m.DeleteCtx("/server/id/{id}/member/id/{uid}", s.kickServerMember, gate.MemberF(s.s.gate, s.serverMember, scopeServerWrite), s.rt)
+	m.GetCtx("/server/id/{id}/invite", s.getServerInvites, gate.MemberF(s.s.gate, s.serverMember, scopeServerRead), s.rt)
+	m.PostCtx("/server/id/{id}/invite", s.createServerInvite, gate.MemberF(s.s.gate, s.serverMember, scopeServerWrite), s.rt)
+	m.DeleteCtx("/server/id/{id}/invite/id/{code}", s.deleteServerInvite, gate.MemberF(s.s.gate, s.serverMember, scopeServerWrite), s.rt)
+	m.GetCtx("/server/id/{id}/ban", s.getServerBans, gate.MemberF(s.s.gate, s.serverMember, scopeServerRead), s.rt)
+	m.PutCtx("/server/id/{id}/ban/id/{uid}", s.banServerUser, gate.MemberF(s.s.gate, s.serverMember, scopeServerWrite), s.rt)
+	m.DeleteCtx("/server/id/{id}/ban/id/{uid}", s.unbanServerUser, gate.MemberF(s.s.gate, s.serverMember, scopeServerWrite), s.rt)
+	m.GetCtx("/server/id/{id}/role", s.getServerRoles, gate.MemberF(s.s.gate, s.serverMember, scopeServerRead), s.rt)
+	m.PostCtx("/server/id/{id}/role", s.createServerRole, gate.MemberF(s.s.gate, s.serverMember, scopeServerWrite), s.rt)
+	m.PutCtx("/server/id/{id}/role/id/{rid}", s.updateServerRole, gate.MemberF(s.s.gate, s.serverMember, scopeServerWrite), s.rt)
+	m.DeleteCtx("/server/id/{id}/role/id/{rid}", s.deleteServerRole, gate.MemberF(s.s.gate, s.serverMember, scopeServerWrite), s.rt)
+	m.GetCtx("/server/id/{id}/role/id/{rid}/member", s.getServerRoleMembers, gate.MemberF(s.s.gate, s.serverMember, scopeServerRead), s.rt)
+	m.PutCtx("/server/id/{id}/role/id/{rid}/member/id/{uid}", s.addServerRoleMember, gate.MemberF(s.s.gate, s.serverMember, scopeServerWrite), s.rt)
+	m.DeleteCtx("/server/id/{id}/role/id/{rid}/member/id/{uid}", s.rmServerRoleMember, gate.MemberF(s.s.gate, s.serverMember, scopeServerWrite), s.rt)
 	m.GetCtx("/server/id/{id}/channel/id/{cid}", s.getChannel, gate.MemberF(s.s.gate, s.serverMember, scopeServerRead), s.rt)
 	m.GetCtx("/server/id/{id}/channel", s.getChannels, gate.MemberF(s.s.gate, s.serverMember, scopeServerRead), s.rt)
 	m.GetCtx("/server/id/{id}/channel/search", s.searchChannels, gate.MemberF(s.s.gate, s.serverMember, scopeServerRead), s.rt)
 	m.PostCtx("/server/id/{id}/channel", s.createChannel, gate.MemberF(s.s.gate, s.serverMember, scopeServerWrite), s.rt)
 	m.PutCtx("/server/id/{id}/channel/id/{cid}", s.updateChannel, gate.MemberF(s.s.gate, s.serverMember, scopeServerWrite), s.rt)
 	m.DeleteCtx("/server/id/{id}/channel/id/{cid}", s.deleteChannel, gate.MemberF(s.s.gate, s.serverMember, scopeServerWrite), s.rt)
+	m.GetCtx("/server/id/{id}/channel/id/{cid}/retention", s.getChannelRetention, gate.MemberF(s.s.gate, s.serverMember, scopeServerRead), s.rt)
+	m.PutCtx("/server/id/{id}/channel/id/{cid}/retention", s.updateChannelRetention, gate.MemberF(s.s.gate, s.serverMember, scopeServerWrite), s.rt)
 	m.PostCtx("/server/id/{id}/channel/id/{cid}/msg", s.createChannelMsg, gate.MemberF(s.s.gate, s.serverMember, scopeServerChatWrite), s.rt)
 	m.GetCtx("/server/id/{id}/channel/id/{cid}/msg", s.getChannelMsgs, gate.MemberF(s.s.gate, s.serverMember, scopeServerRead), s.rt)
+	m.GetCtx("/server/id/{id}/channel/id/{cid}/msg/search", s.searchChannelMsgs, gate.MemberF(s.s.gate, s.serverMember, scopeServerRead), s.rt)
+	m.PutCtx("/server/id/{id}/channel/id/{cid}/msg/id/{msgid}", s.editChannelMsg, gate.MemberF(s.s.gate, s.serverMember, scopeServerChatWrite), s.rt)
 	m.DeleteCtx("/server/id/{id}/channel/id/{cid}/msg/id/{msgid}", s.deleteChannelMsg, gate.MemberF(s.s.gate, s.serverMember, scopeServerChatWrite), s.rt)
+	m.GetCtx("/server/id/{id}/channel/id/{cid}/msg/id/{msgid}/edits", s.getChannelMsgEdits, gate.MemberF(s.s.gate, s.serverMember, scopeServerRead), s.rt)
+	m.PostCtx("/server/id/{id}/channel/id/{cid}/msg/id/{msgid}/reaction", s.reactChannelMsg, gate.MemberF(s.s.gate, s.serverMember, scopeServerChatWrite), s.rt)
+	m.GetCtx("/server/id/{id}/channel/id/{cid}/msg/id/{msgid}/thread", s.getChannelThreadMsgs, gate.MemberF(s.s.gate, s.serverMember, scopeServerRead), s.rt)
+	m.PostCtx("/server/id/{id}/channel/id/{cid}/read", s.readChannelMsg, gate.MemberF(s.s.gate, s.serverMember, scopeServerChatWrite), s.rt)
+	m.GetCtx("/server/id/{id}/channel/id/{cid}/read", s.getChannelReadCursors, gate.MemberF(s.s.gate, s.serverMember, scopeServerRead), s.rt)
+	m.GetCtx("/server/id/{id}/channel/id/{cid}/presence", s.getServerPresence, gate.MemberF(s.s.gate, s.serverMember, scopeServerRead), s.rt)
+	m.PostCtx("/server/id/{id}/channel/id/{cid}/msg/attachment", s.createChannelAttachmentMsg, gate.MemberF(s.s.gate, s.serverMember, scopeServerChatWrite), s.rt)
+	m.GetCtx("/server/id/{id}/channel/id/{cid}/msg/id/{msgid}/attachment", s.getChannelAttachment, gate.MemberF(s.s.gate, s.serverMember, scopeServerRead), s.rt)
+	m.GetCtx("/server/id/{id}/channel/id/{cid}/msg/id/{msgid}/attachment/thumb", s.getChannelAttachmentThumb, gate.MemberF(s.s.gate, s.serverMember, scopeServerRead), s.rt)
+	m.PostCtx("/server/id/{id}/channel/id/{cid}/msg/id/{msgid}/report", s.reportChannelMsg, gate.MemberF(s.s.gate, s.serverMember, scopeServerChatWrite), s.rt)
+	m.GetCtx("/server/id/{id}/report", s.getServerReports, gate.MemberF(s.s.gate, s.serverMember, scopeServerRead), s.rt)
+	m.GetCtx("/server/id/{id}/report/id/{rid}", s.getServerReport, gate.MemberF(s.s.gate, s.serverMember, scopeServerRead), s.rt)
+	m.PostCtx("/server/id/{id}/report/id/{rid}/resolve", s.resolveServerReport, gate.MemberF(s.s.gate, s.serverMember, scopeServerWrite), s.rt)
+	m.GetCtx("/server/id/{id}/mute", s.getServerMutes, gate.MemberF(s.s.gate, s.serverMember, scopeServerRead), s.rt)
+	m.PutCtx("/server/id/{id}/mute/id/{uid}", s.muteServerUser, gate.MemberF(s.s.gate, s.serverMember, scopeServerWrite), s.rt)
+	m.DeleteCtx("/server/id/{id}/mute/id/{uid}", s.unmuteServerUser, gate.MemberF(s.s.gate, s.serverMember, scopeServerWrite), s.rt)
+	m.GetCtx("/server/id/{id}/audit", s.getServerAudits, gate.MemberF(s.s.gate, s.serverMember, scopeServerRead), s.rt)
+
+	m.GetCtx("/server/id/{id}/channel/id/{cid}/bot", s.getChannelBots, gate.MemberF(s.s.gate, s.serverMember, scopeServerRead), s.rt)
+	m.PutCtx("/server/id/{id}/channel/id/{cid}/bot/id/{bid}", s.addChannelBot, gate.MemberF(s.s.gate, s.serverMember, scopeServerWrite), s.rt)
+	m.DeleteCtx("/server/id/{id}/channel/id/{cid}/bot/id/{bid}", s.rmChannelBot, gate.MemberF(s.s.gate, s.serverMember, scopeServerWrite), s.rt)
+	m.GetCtx("/server/id/{id}/channel/id/{cid}/webhook", s.getWebhooks, gate.MemberF(s.s.gate, s.serverMember, scopeServerRead), s.rt)
+	m.PostCtx("/server/id/{id}/channel/id/{cid}/webhook", s.createWebhook, gate.MemberF(s.s.gate, s.serverMember, scopeServerWrite), s.rt)
+	m.PutCtx("/server/id/{id}/channel/id/{cid}/webhook/id/{wid}", s.updateWebhook, gate.MemberF(s.s.gate, s.serverMember, scopeServerWrite), s.rt)
+	m.DeleteCtx("/server/id/{id}/channel/id/{cid}/webhook/id/{wid}", s.deleteWebhook, gate.MemberF(s.s.gate, s.serverMember, scopeServerWrite), s.rt)
+	m.PostCtx("/webhook/id/{wid}/{key}", s.execWebhook, s.rt)
+
+	scopeModRead := s.s.scopens + ".mod:read"
+	scopeModWrite := s.s.scopens + ".mod:write"
+	m.GetCtx("/mod/report", s.getReports, gate.Admin(s.s.gate, scopeModRead), s.rt)
+	m.GetCtx("/mod/report/id/{rid}", s.getReport, gate.Admin(s.s.gate, scopeModRead), s.rt)
+	m.PostCtx("/mod/report/id/{rid}/resolve", s.resolveReport, gate.Admin(s.s.gate, scopeModWrite), s.rt)
+	m.GetCtx("/mod/mute", s.getMutes, gate.Admin(s.s.gate, scopeModRead), s.rt)
+	m.PutCtx("/mod/mute/id/{uid}", s.muteUser, gate.Admin(s.s.gate, scopeModWrite), s.rt)
+	m.DeleteCtx("/mod/mute/id/{uid}", s.unmuteUser, gate.Admin(s.s.gate, scopeModWrite), s.rt)
+	m.GetCtx("/mod/audit", s.getAudits, gate.Admin(s.s.gate, scopeModRead), s.rt)
+
+	scopeBotRead := s.s.scopens + ".bot:read"
+	scopeBotWrite := s.s.scopens + ".bot:write"
+	m.GetCtx("/bot", s.getBots, gate.User(s.s.gate, scopeBotRead), s.rt)
+	m.PostCtx("/bot", s.createBot, gate.User(s.s.gate, scopeBotWrite), s.rt)
+	m.GetCtx("/bot/id/{bid}", s.getBot, gate.User(s.s.gate, scopeBotRead), s.rt)
+	m.PutCtx("/bot/id/{bid}", s.updateBot, gate.User(s.s.gate, scopeBotWrite), s.rt)
+	m.DeleteCtx("/bot/id/{bid}", s.deleteBot, gate.User(s.s.gate, scopeBotWrite), s.rt)
+	m.GetCtx("/bot/id/{bid}/key", s.getBotKeys, gate.User(s.s.gate, scopeBotRead), s.rt)
+	m.PostCtx("/bot/id/{bid}/key", s.createBotKey, gate.User(s.s.gate, scopeBotWrite), s.rt)
+	m.DeleteCtx("/bot/id/{bid}/key/id/{kid}", s.deleteBotKey, gate.User(s.s.gate, scopeBotWrite), s.rt)
+	m.GetCtx("/bot/chat/channel", s.getBotChannels, s.bot(s.s.botScopeRead()), s.rt)
+	m.GetCtx("/bot/chat/server/id/{id}/channel/id/{cid}/msg", s.getBotChannelMsgs, s.bot(s.s.botScopeRead()), s.rt)
+	m.PostCtx("/bot/chat/server/id/{id}/channel/id/{cid}/msg", s.createBotChannelMsg, s.bot(s.s.botScopeWrite()), s.rt)
 }
@@ -16,7 +16,9 @@ type (
 	Repo interface {
 		New(serverid string, name, desc string, theme string) *Model
 		GetServer(ctx context.Context, serverid string) (*Model, error)
+		GetServers(ctx context.Context, limit, offset int) ([]Model, error)
 		GetChannel(ctx context.Context, serverid, channelid string) (*ChannelModel, error)
+		GetChannelByChatid(ctx context.Context, chatid string) (*ChannelModel, error)
 		GetChannels(ctx context.Context, serverid string, prefix string, limit, offset int) ([]ChannelModel, error)
 		GetPresence(ctx context.Context, serverid string, after int64, limit, offset int) ([]PresenceModel, error)
 		Insert(ctx context.Context, m *Model) error
@@ -27,6 +29,32 @@ type (
 		DeleteChannels(ctx context.Context, serverid string, channelids []string) error
 		UpdatePresence(ctx context.Context, serverid string, userid string, t int64) error
 		DeletePresence(ctx context.Context, serverid string, before int64) error
+		DeleteStalePresence(ctx context.Context, before int64) error
+		NewMember(serverid, userid string) *MemberModel
+		GetMember(ctx context.Context, serverid, userid string) (*MemberModel, error)
+		GetMembers(ctx context.Context, serverid string, limit, offset int) ([]MemberModel, error)
+		GetUserServers(ctx context.Context, userid string, limit, offset int) ([]MemberModel, error)
+		InsertMember(ctx context.Context, m *MemberModel) error
+		InsertMembers(ctx context.Context, m []*MemberModel) error
+		DeleteMember(ctx context.Context, serverid, userid string) error
+		NewInvite(serverid, creatorid string, maxUses int, expires int64) (*InviteModel, error)
+		GetInvite(ctx context.Context, code string) (*InviteModel, error)
+		GetInvites(ctx context.Context, serverid string, limit, offset int) ([]InviteModel, error)
+		InsertInvite(ctx context.Context, m *InviteModel) error
+		UseInvite(ctx context.Context, code string, now int64) (bool, error)
+		DeleteInvite(ctx context.Context, serverid, code string) error
+		DeleteExpiredInvites(ctx context.Context, before int64) error
+		NewBan(serverid, userid, creatorid string, reason string) *BanModel
+		GetBan(ctx context.Context, serverid, userid string) (*BanModel, error)
+		GetBans(ctx context.Context, serverid string, limit, offset int) ([]BanModel, error)
+		InsertBan(ctx context.Context, m *BanModel) error
+		DeleteBan(ctx context.Context, serverid, userid string) error
+		NewRole(serverid string, name string) (*RoleModel, error)
+		GetRole(ctx context.Context, serverid, roleid string) (*RoleModel, error)
+		GetRoles(ctx context.Context, serverid string, limit, offset int) ([]RoleModel, error)
+		InsertRole(ctx context.Context, m *RoleModel) error
+		UpdateRole(ctx context.Context, m *RoleModel) error
+		DeleteRole(ctx context.Context, serverid, roleid string) error
 		Delete(ctx context.Context, serverid string) error
 		Setup(ctx context.Context) error
 	}
@@ -35,6 +63,10 @@ type (
 		table         *serverModelTable
 		tableChannels *channelModelTable
 		tablePresence *presenceModelTable
+		tableMembers  *memberModelTable
+		tableInvites  *inviteModelTable
+		tableBans     *banModelTable
+		tableRoles    *roleModelTable
 		db            dbsql.Database
 	}
 
@@ -84,19 +116,79 @@ type (
 		Userid      string `model:"userid,VARCHAR(31)"`
 		LastUpdated int64  `model:"last_updated,BIGINT NOT NULL"`
 	}
+
+	// MemberModel is the db server member model
+	//forge:model member
+	//forge:model:query member
+	MemberModel struct {
+		ServerID     string `model:"serverid,VARCHAR(31)"`
+		Userid       string `model:"userid,VARCHAR(31)"`
+		CreationTime int64  `model:"creation_time,BIGINT NOT NULL"`
+	}
+
+	// InviteModel is the db server invite model
+	//forge:model invite
+	//forge:model:query invite
+	InviteModel struct {
+		Code         string `model:"code,VARCHAR(31) PRIMARY KEY"`
+		ServerID     string `model:"serverid,VARCHAR(31) NOT NULL"`
+		CreatorID    string `model:"creator_id,VARCHAR(31) NOT NULL"`
+		MaxUses      int    `model:"max_uses,INT NOT NULL"`
+		Uses         int    `model:"uses,INT NOT NULL"`
+		Expires      int64  `model:"expires,BIGINT NOT NULL"`
+		CreationTime int64  `model:"creation_time,BIGINT NOT NULL"`
+	}
+
+	// BanModel is the db server ban model
+	//forge:model ban
+	//forge:model:query ban
+	BanModel struct {
+		ServerID     string `model:"serverid,VARCHAR(31)"`
+		Userid       string `model:"userid,VARCHAR(31)"`
+		CreatorID    string `model:"creator_id,VARCHAR(31) NOT NULL"`
+		Reason       string `model:"reason,VARCHAR(255) NOT NULL"`
+		CreationTime int64  `model:"creation_time,BIGINT NOT NULL"`
+	}
+
+	// RoleModel is the db server role model
+	//forge:model role
+	//forge:model:query role
+	RoleModel struct {
+		ServerID     string `model:"serverid,VARCHAR(31)"`
+		Roleid       string `model:"roleid,VARCHAR(31)"`
+		Name         string `model:"name,VARCHAR(255) NOT NULL"`
+		CreationTime int64  `model:"creation_time,BIGINT NOT NULL"`
+	}
+
+	//forge:model:query role
+	roleProps struct {
+		Name string `model:"name"`
+	}
 )
 
-func New(database dbsql.Database, table, tableChannels, tablePresence string) Repo {
+func New(database dbsql.Database, table, tableChannels, tablePresence, tableMembers, tableInvites, tableBans, tableRoles string) Repo {
 	return &repo{
 		table: &serverModelTable{
 			TableName: table,
 		},
 		tableChannels: &channelModelTable{
-			TableName: tablePresence,
+			TableName: tableChannels,
 		},
 		tablePresence: &presenceModelTable{
 			TableName: tablePresence,
 		},
+		tableMembers: &memberModelTable{
+			TableName: tableMembers,
+		},
+		tableInvites: &inviteModelTable{
+			TableName: tableInvites,
+		},
+		tableBans: &banModelTable{
+			TableName: tableBans,
+		},
+		tableRoles: &roleModelTable{
+			TableName: tableRoles,
+		},
 		db: database,
 	}
 }
@@ -125,6 +217,19 @@ func (r *repo) GetServer(ctx context.Context, serverid string) (*Model, error) {
 	return m, nil
 }
 
+// GetServers returns all servers
+func (r *repo) GetServers(ctx context.Context, limit, offset int) ([]Model, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.table.GetModelAll(ctx, d, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get servers")
+	}
+	return m, nil
+}
+
 func (r *repo) GetChannel(ctx context.Context, serverid string, channelid string) (*ChannelModel, error) {
 	d, err := r.db.DB(ctx)
 	if err != nil {
@@ -137,6 +242,18 @@ func (r *repo) GetChannel(ctx context.Context, serverid string, channelid string
 	return m, nil
 }
 
+func (r *repo) GetChannelByChatid(ctx context.Context, chatid string) (*ChannelModel, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.tableChannels.GetChannelModelByChat(ctx, d, chatid)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get channel")
+	}
+	return m, nil
+}
+
 func (r *repo) GetChannels(ctx context.Context, serverid string, prefix string, limit, offset int) ([]ChannelModel, error) {
 	d, err := r.db.DB(ctx)
 	if err != nil {
@@ -280,11 +397,374 @@ func (r *repo) DeletePresence(ctx context.Context, serverid string, before int64
 	return nil
 }
 
+// DeleteStalePresence deletes presence of all servers last updated before a
+// time
+func (r *repo) DeleteStalePresence(ctx context.Context, before int64) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tablePresence.DelBeforeLastUpdated(ctx, d, before); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete stale presence")
+	}
+	return nil
+}
+
+// NewMember creates a new server member
+func (r *repo) NewMember(serverid, userid string) *MemberModel {
+	return &MemberModel{
+		ServerID:     serverid,
+		Userid:       userid,
+		CreationTime: time.Now().Round(0).Unix(),
+	}
+}
+
+// GetMember returns a server member
+func (r *repo) GetMember(ctx context.Context, serverid, userid string) (*MemberModel, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.tableMembers.GetMemberModelByServerUser(ctx, d, serverid, userid)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get server member")
+	}
+	return m, nil
+}
+
+// GetMembers returns server members
+func (r *repo) GetMembers(ctx context.Context, serverid string, limit, offset int) ([]MemberModel, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.tableMembers.GetMemberModelByServer(ctx, d, serverid, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get server members")
+	}
+	return m, nil
+}
+
+// GetUserServers returns the server memberships of a user
+func (r *repo) GetUserServers(ctx context.Context, userid string, limit, offset int) ([]MemberModel, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.tableMembers.GetMemberModelByUser(ctx, d, userid, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get user servers")
+	}
+	return m, nil
+}
+
+// InsertMember adds a server member
+func (r *repo) InsertMember(ctx context.Context, m *MemberModel) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tableMembers.Insert(ctx, d, m); err != nil {
+		return kerrors.WithMsg(err, "Failed to insert server member")
+	}
+	return nil
+}
+
+// InsertMembers adds server members, ignoring existing members
+func (r *repo) InsertMembers(ctx context.Context, m []*MemberModel) error {
+	if len(m) == 0 {
+		return nil
+	}
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tableMembers.InsertBulk(ctx, d, m, true); err != nil {
+		return kerrors.WithMsg(err, "Failed to insert server members")
+	}
+	return nil
+}
+
+// DeleteMember removes a server member
+func (r *repo) DeleteMember(ctx context.Context, serverid, userid string) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tableMembers.DelByServerUser(ctx, d, serverid, userid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete server member")
+	}
+	return nil
+}
+
+// NewInvite creates a new server invite
+//
+// A maxUses of 0 allows unlimited uses, and an expires of 0 never expires.
+func (r *repo) NewInvite(serverid, creatorid string, maxUses int, expires int64) (*InviteModel, error) {
+	u, err := uid.NewRandSnowflake()
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to create new invite code")
+	}
+	return &InviteModel{
+		Code:         u.Base64(),
+		ServerID:     serverid,
+		CreatorID:    creatorid,
+		MaxUses:      maxUses,
+		Uses:         0,
+		Expires:      expires,
+		CreationTime: time.Now().Round(0).Unix(),
+	}, nil
+}
+
+// GetInvite returns a server invite by code
+func (r *repo) GetInvite(ctx context.Context, code string) (*InviteModel, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.tableInvites.GetInviteModelByCode(ctx, d, code)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get server invite")
+	}
+	return m, nil
+}
+
+// GetInvites returns server invites
+func (r *repo) GetInvites(ctx context.Context, serverid string, limit, offset int) ([]InviteModel, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.tableInvites.GetInviteModelByServer(ctx, d, serverid, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get server invites")
+	}
+	return m, nil
+}
+
+// InsertInvite adds a server invite
+func (r *repo) InsertInvite(ctx context.Context, m *InviteModel) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tableInvites.Insert(ctx, d, m); err != nil {
+		return kerrors.WithMsg(err, "Failed to insert server invite")
+	}
+	return nil
+}
+
+func (t *inviteModelTable) IncrUses(ctx context.Context, d sqldb.Executor, code string, now int64) (bool, error) {
+	res, err := d.ExecContext(ctx, "UPDATE "+t.TableName+" SET uses = uses + 1 WHERE code = $1 AND (max_uses = 0 OR uses < max_uses) AND (expires = 0 OR expires > $2);", code, now)
+	if err != nil {
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// UseInvite consumes a use of an invite, and returns false if the invite is
+// expired or has no remaining uses
+func (r *repo) UseInvite(ctx context.Context, code string, now int64) (bool, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return false, err
+	}
+	ok, err := r.tableInvites.IncrUses(ctx, d, code, now)
+	if err != nil {
+		return false, kerrors.WithMsg(err, "Failed to use server invite")
+	}
+	return ok, nil
+}
+
+// DeleteInvite deletes a server invite
+func (r *repo) DeleteInvite(ctx context.Context, serverid, code string) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tableInvites.DelByServerCode(ctx, d, serverid, code); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete server invite")
+	}
+	return nil
+}
+
+func (t *inviteModelTable) DelExpired(ctx context.Context, d sqldb.Executor, before int64) error {
+	_, err := d.ExecContext(ctx, "DELETE FROM "+t.TableName+" WHERE (expires <> 0 AND expires <= $1) OR (max_uses <> 0 AND uses >= max_uses);", before)
+	return err
+}
+
+// DeleteExpiredInvites deletes invites that are expired or used up
+func (r *repo) DeleteExpiredInvites(ctx context.Context, before int64) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tableInvites.DelExpired(ctx, d, before); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete expired server invites")
+	}
+	return nil
+}
+
+// NewBan creates a new server ban
+func (r *repo) NewBan(serverid, userid, creatorid string, reason string) *BanModel {
+	return &BanModel{
+		ServerID:     serverid,
+		Userid:       userid,
+		CreatorID:    creatorid,
+		Reason:       reason,
+		CreationTime: time.Now().Round(0).Unix(),
+	}
+}
+
+// GetBan returns a server ban
+func (r *repo) GetBan(ctx context.Context, serverid, userid string) (*BanModel, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.tableBans.GetBanModelByServerUser(ctx, d, serverid, userid)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get server ban")
+	}
+	return m, nil
+}
+
+// GetBans returns server bans
+func (r *repo) GetBans(ctx context.Context, serverid string, limit, offset int) ([]BanModel, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.tableBans.GetBanModelByServer(ctx, d, serverid, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get server bans")
+	}
+	return m, nil
+}
+
+// InsertBan adds a server ban
+func (r *repo) InsertBan(ctx context.Context, m *BanModel) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tableBans.Insert(ctx, d, m); err != nil {
+		return kerrors.WithMsg(err, "Failed to insert server ban")
+	}
+	return nil
+}
+
+// DeleteBan removes a server ban
+func (r *repo) DeleteBan(ctx context.Context, serverid, userid string) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tableBans.DelByServerUser(ctx, d, serverid, userid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete server ban")
+	}
+	return nil
+}
+
+// NewRole creates a new server role
+func (r *repo) NewRole(serverid string, name string) (*RoleModel, error) {
+	u, err := uid.New()
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to create new uid")
+	}
+	return &RoleModel{
+		ServerID:     serverid,
+		Roleid:       u.Base64(),
+		Name:         name,
+		CreationTime: time.Now().Round(0).Unix(),
+	}, nil
+}
+
+// GetRole returns a server role
+func (r *repo) GetRole(ctx context.Context, serverid, roleid string) (*RoleModel, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.tableRoles.GetRoleModelByServerRole(ctx, d, serverid, roleid)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get server role")
+	}
+	return m, nil
+}
+
+// GetRoles returns server roles
+func (r *repo) GetRoles(ctx context.Context, serverid string, limit, offset int) ([]RoleModel, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.tableRoles.GetRoleModelByServer(ctx, d, serverid, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get server roles")
+	}
+	return m, nil
+}
+
+// InsertRole adds a server role
+func (r *repo) InsertRole(ctx context.Context, m *RoleModel) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tableRoles.Insert(ctx, d, m); err != nil {
+		return kerrors.WithMsg(err, "Failed to insert server role")
+	}
+	return nil
+}
+
+// UpdateRole updates a server role
+func (r *repo) UpdateRole(ctx context.Context, m *RoleModel) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tableRoles.UpdrolePropsByServerRole(ctx, d, &roleProps{
+		Name: m.Name,
+	}, m.ServerID, m.Roleid); err != nil {
+		return kerrors.WithMsg(err, "Failed to update server role")
+	}
+	return nil
+}
+
+// DeleteRole deletes a server role
+func (r *repo) DeleteRole(ctx context.Context, serverid, roleid string) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tableRoles.DelByServerRole(ctx, d, serverid, roleid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete server role")
+	}
+	return nil
+}
+
 func (r *repo) Delete(ctx context.Context, serverid string) error {
 	d, err := r.db.DB(ctx)
 	if err != nil {
 		return err
 	}
+	if err := r.tableRoles.DelByServer(ctx, d, serverid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete roles")
+	}
+	if err := r.tableBans.DelByServer(ctx, d, serverid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete bans")
+	}
+	if err := r.tableInvites.DelByServer(ctx, d, serverid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete invites")
+	}
+	if err := r.tableMembers.DelByServer(ctx, d, serverid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete members")
+	}
 	if err := r.tablePresence.DelByServer(ctx, d, serverid); err != nil {
 		return kerrors.WithMsg(err, "Failed to delete presence")
 	}
@@ -297,7 +777,8 @@ func (r *repo) Delete(ctx context.Context, serverid string) error {
 	return nil
 }
 
-// Setup creates new server, channel, and presence tables
+// Setup creates new server, channel, presence, member, invite, ban, and role
+// tables
 func (r *repo) Setup(ctx context.Context) error {
 	d, err := r.db.DB(ctx)
 	if err != nil {
@@ -312,5 +793,17 @@ func (r *repo) Setup(ctx context.Context) error {
 	if err := r.tablePresence.Setup(ctx, d); err != nil {
 		return kerrors.WithMsg(err, "Failed to setup server presence model")
 	}
+	if err := r.tableMembers.Setup(ctx, d); err != nil {
+		return kerrors.WithMsg(err, "Failed to setup server member model")
+	}
+	if err := r.tableInvites.Setup(ctx, d); err != nil {
+		return kerrors.WithMsg(err, "Failed to setup server invite model")
+	}
+	if err := r.tableBans.Setup(ctx, d); err != nil {
+		return kerrors.WithMsg(err, "Failed to setup server ban model")
+	}
+	if err := r.tableRoles.Setup(ctx, d); err != nil {
+		return kerrors.WithMsg(err, "Failed to setup server role model")
+	}
 	return nil
 }
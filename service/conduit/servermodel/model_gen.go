@@ -60,6 +60,30 @@ func (t *serverModelTable) GetModelByID(ctx context.Context, d sqldb.Executor, s
 	return m, nil
 }
 
+func (t *serverModelTable) GetModelAll(ctx context.Context, d sqldb.Executor, limit, offset int) (_ []Model, retErr error) {
+	res := make([]Model, 0, limit)
+	rows, err := d.QueryContext(ctx, "SELECT serverid, name, desc, theme, creation_time FROM "+t.TableName+" ORDER BY serverid LIMIT $1 OFFSET $2;", limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("Failed to close db rows: %w", err))
+		}
+	}()
+	for rows.Next() {
+		var m Model
+		if err := rows.Scan(&m.ServerID, &m.Name, &m.Desc, &m.Theme, &m.CreationTime); err != nil {
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
 func (t *serverModelTable) DelByID(ctx context.Context, d sqldb.Executor, serverid string) error {
 	_, err := d.ExecContext(ctx, "DELETE FROM "+t.TableName+" WHERE serverid = $1;", serverid)
 	return err
@@ -127,6 +151,14 @@ func (t *channelModelTable) GetChannelModelByServerChannel(ctx context.Context,
 	return m, nil
 }
 
+func (t *channelModelTable) GetChannelModelByChat(ctx context.Context, d sqldb.Executor, chatid string) (*ChannelModel, error) {
+	m := &ChannelModel{}
+	if err := d.QueryRowContext(ctx, "SELECT serverid, channelid, chatid, name, desc, theme, creation_time FROM "+t.TableName+" WHERE chatid = $1;", chatid).Scan(&m.ServerID, &m.ChannelID, &m.Chatid, &m.Name, &m.Desc, &m.Theme, &m.CreationTime); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (t *channelModelTable) GetChannelModelByServer(ctx context.Context, d sqldb.Executor, serverid string, limit, offset int) (_ []ChannelModel, retErr error) {
 	res := make([]ChannelModel, 0, limit)
 	rows, err := d.QueryContext(ctx, "SELECT serverid, channelid, chatid, name, desc, theme, creation_time FROM "+t.TableName+" WHERE serverid = $3 ORDER BY channelid LIMIT $1 OFFSET $2;", limit, offset, serverid)
@@ -216,6 +248,10 @@ func (t *presenceModelTable) Setup(ctx context.Context, d sqldb.Executor) error
 	if err != nil {
 		return err
 	}
+	_, err = d.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS "+t.TableName+"_last_updated_index ON "+t.TableName+" (last_updated);")
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -279,3 +315,384 @@ func (t *presenceModelTable) DelByServerBeforeLastUpdated(ctx context.Context, d
 	_, err := d.ExecContext(ctx, "DELETE FROM "+t.TableName+" WHERE serverid = $1 AND last_updated <= $2;", serverid, lastupdated)
 	return err
 }
+
+func (t *presenceModelTable) DelBeforeLastUpdated(ctx context.Context, d sqldb.Executor, lastupdated int64) error {
+	_, err := d.ExecContext(ctx, "DELETE FROM "+t.TableName+" WHERE last_updated <= $1;", lastupdated)
+	return err
+}
+
+type (
+	memberModelTable struct {
+		TableName string
+	}
+)
+
+func (t *memberModelTable) Setup(ctx context.Context, d sqldb.Executor) error {
+	_, err := d.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+t.TableName+" (serverid VARCHAR(31), userid VARCHAR(31), creation_time BIGINT NOT NULL, PRIMARY KEY (serverid, userid));")
+	if err != nil {
+		return err
+	}
+	_, err = d.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS "+t.TableName+"_user_creation_time_index ON "+t.TableName+" (userid, creation_time);")
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *memberModelTable) Insert(ctx context.Context, d sqldb.Executor, m *MemberModel) error {
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (serverid, userid, creation_time) VALUES ($1, $2, $3);", m.ServerID, m.Userid, m.CreationTime)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *memberModelTable) InsertBulk(ctx context.Context, d sqldb.Executor, models []*MemberModel, allowConflict bool) error {
+	conflictSQL := ""
+	if allowConflict {
+		conflictSQL = " ON CONFLICT DO NOTHING"
+	}
+	placeholders := make([]string, 0, len(models))
+	args := make([]interface{}, 0, len(models)*3)
+	for c, m := range models {
+		n := c * 3
+		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d)", n+1, n+2, n+3))
+		args = append(args, m.ServerID, m.Userid, m.CreationTime)
+	}
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (serverid, userid, creation_time) VALUES "+strings.Join(placeholders, ", ")+conflictSQL+";", args...)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *memberModelTable) GetMemberModelByServerUser(ctx context.Context, d sqldb.Executor, serverid string, userid string) (*MemberModel, error) {
+	m := &MemberModel{}
+	if err := d.QueryRowContext(ctx, "SELECT serverid, userid, creation_time FROM "+t.TableName+" WHERE serverid = $1 AND userid = $2;", serverid, userid).Scan(&m.ServerID, &m.Userid, &m.CreationTime); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (t *memberModelTable) GetMemberModelByServer(ctx context.Context, d sqldb.Executor, serverid string, limit, offset int) (_ []MemberModel, retErr error) {
+	res := make([]MemberModel, 0, limit)
+	rows, err := d.QueryContext(ctx, "SELECT serverid, userid, creation_time FROM "+t.TableName+" WHERE serverid = $3 ORDER BY userid LIMIT $1 OFFSET $2;", limit, offset, serverid)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("Failed to close db rows: %w", err))
+		}
+	}()
+	for rows.Next() {
+		var m MemberModel
+		if err := rows.Scan(&m.ServerID, &m.Userid, &m.CreationTime); err != nil {
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (t *memberModelTable) GetMemberModelByUser(ctx context.Context, d sqldb.Executor, userid string, limit, offset int) (_ []MemberModel, retErr error) {
+	res := make([]MemberModel, 0, limit)
+	rows, err := d.QueryContext(ctx, "SELECT serverid, userid, creation_time FROM "+t.TableName+" WHERE userid = $3 ORDER BY creation_time DESC LIMIT $1 OFFSET $2;", limit, offset, userid)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("Failed to close db rows: %w", err))
+		}
+	}()
+	for rows.Next() {
+		var m MemberModel
+		if err := rows.Scan(&m.ServerID, &m.Userid, &m.CreationTime); err != nil {
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (t *memberModelTable) DelByServer(ctx context.Context, d sqldb.Executor, serverid string) error {
+	_, err := d.ExecContext(ctx, "DELETE FROM "+t.TableName+" WHERE serverid = $1;", serverid)
+	return err
+}
+
+func (t *memberModelTable) DelByServerUser(ctx context.Context, d sqldb.Executor, serverid string, userid string) error {
+	_, err := d.ExecContext(ctx, "DELETE FROM "+t.TableName+" WHERE serverid = $1 AND userid = $2;", serverid, userid)
+	return err
+}
+
+type (
+	inviteModelTable struct {
+		TableName string
+	}
+)
+
+func (t *inviteModelTable) Setup(ctx context.Context, d sqldb.Executor) error {
+	_, err := d.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+t.TableName+" (code VARCHAR(31) PRIMARY KEY, serverid VARCHAR(31) NOT NULL, creator_id VARCHAR(31) NOT NULL, max_uses INT NOT NULL, uses INT NOT NULL, expires BIGINT NOT NULL, creation_time BIGINT NOT NULL);")
+	if err != nil {
+		return err
+	}
+	_, err = d.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS "+t.TableName+"_server_creation_time_index ON "+t.TableName+" (serverid, creation_time);")
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *inviteModelTable) Insert(ctx context.Context, d sqldb.Executor, m *InviteModel) error {
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (code, serverid, creator_id, max_uses, uses, expires, creation_time) VALUES ($1, $2, $3, $4, $5, $6, $7);", m.Code, m.ServerID, m.CreatorID, m.MaxUses, m.Uses, m.Expires, m.CreationTime)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *inviteModelTable) InsertBulk(ctx context.Context, d sqldb.Executor, models []*InviteModel, allowConflict bool) error {
+	conflictSQL := ""
+	if allowConflict {
+		conflictSQL = " ON CONFLICT DO NOTHING"
+	}
+	placeholders := make([]string, 0, len(models))
+	args := make([]interface{}, 0, len(models)*7)
+	for c, m := range models {
+		n := c * 7
+		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6, n+7))
+		args = append(args, m.Code, m.ServerID, m.CreatorID, m.MaxUses, m.Uses, m.Expires, m.CreationTime)
+	}
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (code, serverid, creator_id, max_uses, uses, expires, creation_time) VALUES "+strings.Join(placeholders, ", ")+conflictSQL+";", args...)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *inviteModelTable) GetInviteModelByCode(ctx context.Context, d sqldb.Executor, code string) (*InviteModel, error) {
+	m := &InviteModel{}
+	if err := d.QueryRowContext(ctx, "SELECT code, serverid, creator_id, max_uses, uses, expires, creation_time FROM "+t.TableName+" WHERE code = $1;", code).Scan(&m.Code, &m.ServerID, &m.CreatorID, &m.MaxUses, &m.Uses, &m.Expires, &m.CreationTime); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (t *inviteModelTable) GetInviteModelByServer(ctx context.Context, d sqldb.Executor, serverid string, limit, offset int) (_ []InviteModel, retErr error) {
+	res := make([]InviteModel, 0, limit)
+	rows, err := d.QueryContext(ctx, "SELECT code, serverid, creator_id, max_uses, uses, expires, creation_time FROM "+t.TableName+" WHERE serverid = $3 ORDER BY creation_time DESC LIMIT $1 OFFSET $2;", limit, offset, serverid)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("Failed to close db rows: %w", err))
+		}
+	}()
+	for rows.Next() {
+		var m InviteModel
+		if err := rows.Scan(&m.Code, &m.ServerID, &m.CreatorID, &m.MaxUses, &m.Uses, &m.Expires, &m.CreationTime); err != nil {
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (t *inviteModelTable) DelByServer(ctx context.Context, d sqldb.Executor, serverid string) error {
+	_, err := d.ExecContext(ctx, "DELETE FROM "+t.TableName+" WHERE serverid = $1;", serverid)
+	return err
+}
+
+func (t *inviteModelTable) DelByServerCode(ctx context.Context, d sqldb.Executor, serverid string, code string) error {
+	_, err := d.ExecContext(ctx, "DELETE FROM "+t.TableName+" WHERE serverid = $1 AND code = $2;", serverid, code)
+	return err
+}
+
+type (
+	banModelTable struct {
+		TableName string
+	}
+)
+
+func (t *banModelTable) Setup(ctx context.Context, d sqldb.Executor) error {
+	_, err := d.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+t.TableName+" (serverid VARCHAR(31), userid VARCHAR(31), creator_id VARCHAR(31) NOT NULL, reason VARCHAR(255) NOT NULL, creation_time BIGINT NOT NULL, PRIMARY KEY (serverid, userid));")
+	if err != nil {
+		return err
+	}
+	_, err = d.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS "+t.TableName+"_server_creation_time_index ON "+t.TableName+" (serverid, creation_time);")
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *banModelTable) Insert(ctx context.Context, d sqldb.Executor, m *BanModel) error {
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (serverid, userid, creator_id, reason, creation_time) VALUES ($1, $2, $3, $4, $5);", m.ServerID, m.Userid, m.CreatorID, m.Reason, m.CreationTime)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *banModelTable) InsertBulk(ctx context.Context, d sqldb.Executor, models []*BanModel, allowConflict bool) error {
+	conflictSQL := ""
+	if allowConflict {
+		conflictSQL = " ON CONFLICT DO NOTHING"
+	}
+	placeholders := make([]string, 0, len(models))
+	args := make([]interface{}, 0, len(models)*5)
+	for c, m := range models {
+		n := c * 5
+		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5))
+		args = append(args, m.ServerID, m.Userid, m.CreatorID, m.Reason, m.CreationTime)
+	}
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (serverid, userid, creator_id, reason, creation_time) VALUES "+strings.Join(placeholders, ", ")+conflictSQL+";", args...)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *banModelTable) GetBanModelByServerUser(ctx context.Context, d sqldb.Executor, serverid string, userid string) (*BanModel, error) {
+	m := &BanModel{}
+	if err := d.QueryRowContext(ctx, "SELECT serverid, userid, creator_id, reason, creation_time FROM "+t.TableName+" WHERE serverid = $1 AND userid = $2;", serverid, userid).Scan(&m.ServerID, &m.Userid, &m.CreatorID, &m.Reason, &m.CreationTime); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (t *banModelTable) GetBanModelByServer(ctx context.Context, d sqldb.Executor, serverid string, limit, offset int) (_ []BanModel, retErr error) {
+	res := make([]BanModel, 0, limit)
+	rows, err := d.QueryContext(ctx, "SELECT serverid, userid, creator_id, reason, creation_time FROM "+t.TableName+" WHERE serverid = $3 ORDER BY creation_time DESC LIMIT $1 OFFSET $2;", limit, offset, serverid)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("Failed to close db rows: %w", err))
+		}
+	}()
+	for rows.Next() {
+		var m BanModel
+		if err := rows.Scan(&m.ServerID, &m.Userid, &m.CreatorID, &m.Reason, &m.CreationTime); err != nil {
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (t *banModelTable) DelByServer(ctx context.Context, d sqldb.Executor, serverid string) error {
+	_, err := d.ExecContext(ctx, "DELETE FROM "+t.TableName+" WHERE serverid = $1;", serverid)
+	return err
+}
+
+func (t *banModelTable) DelByServerUser(ctx context.Context, d sqldb.Executor, serverid string, userid string) error {
+	_, err := d.ExecContext(ctx, "DELETE FROM "+t.TableName+" WHERE serverid = $1 AND userid = $2;", serverid, userid)
+	return err
+}
+
+type (
+	roleModelTable struct {
+		TableName string
+	}
+)
+
+func (t *roleModelTable) Setup(ctx context.Context, d sqldb.Executor) error {
+	_, err := d.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+t.TableName+" (serverid VARCHAR(31), roleid VARCHAR(31), name VARCHAR(255) NOT NULL, creation_time BIGINT NOT NULL, PRIMARY KEY (serverid, roleid));")
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *roleModelTable) Insert(ctx context.Context, d sqldb.Executor, m *RoleModel) error {
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (serverid, roleid, name, creation_time) VALUES ($1, $2, $3, $4);", m.ServerID, m.Roleid, m.Name, m.CreationTime)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *roleModelTable) InsertBulk(ctx context.Context, d sqldb.Executor, models []*RoleModel, allowConflict bool) error {
+	conflictSQL := ""
+	if allowConflict {
+		conflictSQL = " ON CONFLICT DO NOTHING"
+	}
+	placeholders := make([]string, 0, len(models))
+	args := make([]interface{}, 0, len(models)*4)
+	for c, m := range models {
+		n := c * 4
+		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4))
+		args = append(args, m.ServerID, m.Roleid, m.Name, m.CreationTime)
+	}
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (serverid, roleid, name, creation_time) VALUES "+strings.Join(placeholders, ", ")+conflictSQL+";", args...)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *roleModelTable) GetRoleModelByServerRole(ctx context.Context, d sqldb.Executor, serverid string, roleid string) (*RoleModel, error) {
+	m := &RoleModel{}
+	if err := d.QueryRowContext(ctx, "SELECT serverid, roleid, name, creation_time FROM "+t.TableName+" WHERE serverid = $1 AND roleid = $2;", serverid, roleid).Scan(&m.ServerID, &m.Roleid, &m.Name, &m.CreationTime); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (t *roleModelTable) GetRoleModelByServer(ctx context.Context, d sqldb.Executor, serverid string, limit, offset int) (_ []RoleModel, retErr error) {
+	res := make([]RoleModel, 0, limit)
+	rows, err := d.QueryContext(ctx, "SELECT serverid, roleid, name, creation_time FROM "+t.TableName+" WHERE serverid = $3 ORDER BY roleid LIMIT $1 OFFSET $2;", limit, offset, serverid)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("Failed to close db rows: %w", err))
+		}
+	}()
+	for rows.Next() {
+		var m RoleModel
+		if err := rows.Scan(&m.ServerID, &m.Roleid, &m.Name, &m.CreationTime); err != nil {
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (t *roleModelTable) DelByServer(ctx context.Context, d sqldb.Executor, serverid string) error {
+	_, err := d.ExecContext(ctx, "DELETE FROM "+t.TableName+" WHERE serverid = $1;", serverid)
+	return err
+}
+
+func (t *roleModelTable) DelByServerRole(ctx context.Context, d sqldb.Executor, serverid string, roleid string) error {
+	_, err := d.ExecContext(ctx, "DELETE FROM "+t.TableName+" WHERE serverid = $1 AND roleid = $2;", serverid, roleid)
+	return err
+}
+
+func (t *roleModelTable) UpdrolePropsByServerRole(ctx context.Context, d sqldb.Executor, m *roleProps, serverid string, roleid string) error {
+	_, err := d.ExecContext(ctx, "UPDATE "+t.TableName+" SET name = $1 WHERE serverid = $2 AND roleid = $3;", m.Name, serverid, roleid)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,309 @@
+package conduit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/conduit/msgmodel"
+	"xorkevin.dev/governor/service/image"
+	"xorkevin.dev/governor/service/objstore"
+	"xorkevin.dev/governor/util/kjson"
+	"xorkevin.dev/kerrors"
+)
+
+const (
+	chatMsgKindAttachment = "a"
+)
+
+const (
+	attachmentFileDir        = "file"
+	attachmentVariantThumb   = "thumb"
+	attachmentThumbLen       = 384
+	attachmentThumbQuality   = 85
+	attachmentPlaceholderXYC = 4
+	attachmentListBatchSize  = 256
+)
+
+var attachmentVariants = []image.Variant{
+	{
+		Name:      attachmentVariantThumb,
+		Width:     attachmentThumbLen,
+		Height:    attachmentThumbLen,
+		Fill:      false,
+		MediaType: image.MediaTypeJpeg,
+		Quality:   attachmentThumbQuality,
+	},
+}
+
+// defaultAttachmentMediaTypes are the media types that may be uploaded as
+// attachments by default
+var defaultAttachmentMediaTypes = []string{
+	image.MediaTypePng,
+	image.MediaTypeJpeg,
+	image.MediaTypeGif,
+	image.MediaTypeWebp,
+	image.MediaTypeBmp,
+	image.MediaTypeTiff,
+	"application/pdf",
+	"application/zip",
+	"text/plain",
+	"audio/mpeg",
+	"audio/ogg",
+	"video/mp4",
+	"video/webm",
+}
+
+type (
+	// attachmentValue is the msg value of an attachment msg
+	attachmentValue struct {
+		Name        string `json:"name"`
+		ContentType string `json:"content_type"`
+		Size        int64  `json:"size"`
+		Width       int    `json:"width,omitempty"`
+		Height      int    `json:"height,omitempty"`
+		Thumb       bool   `json:"thumb"`
+		Placeholder string `json:"placeholder,omitempty"`
+	}
+)
+
+func (s *Service) getAttachmentDir(chatid string) objstore.Dir {
+	return s.attachDir.Subdir(chatid)
+}
+
+// putAttachment stores an uploaded file along with its thumbnail if it is an
+// image, and returns the msg value referencing it
+func (s *Service) putAttachment(ctx context.Context, chatid, msgid string, name string, file io.ReadSeeker, contentType string, size int64) (string, error) {
+	if size > s.attachMaxSize {
+		return "", governor.ErrWithRes(nil, http.StatusRequestEntityTooLarge, "", "Attachment is too large")
+	}
+	val := attachmentValue{
+		Name:        name,
+		ContentType: contentType,
+		Size:        size,
+	}
+	d := s.getAttachmentDir(chatid)
+	if image.IsSupportedMediaType(contentType) {
+		img, err := image.FromReader(file, contentType, s.attachMaxSize)
+		if err != nil {
+			return "", err
+		}
+		imgSize := img.Size()
+		val.Width = imgSize.W
+		val.Height = imgSize.H
+		placeholder, err := img.ToBlurhash(attachmentPlaceholderXYC, attachmentPlaceholderXYC)
+		if err != nil {
+			return "", kerrors.WithMsg(err, "Failed to encode attachment placeholder")
+		}
+		val.Placeholder = placeholder
+		if err := image.PutVariants(ctx, d, msgid, img, attachmentVariants, nil); err != nil {
+			return "", kerrors.WithMsg(err, "Failed to save attachment thumbnail")
+		}
+		val.Thumb = true
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return "", kerrors.WithMsg(err, "Failed to seek attachment file")
+		}
+	}
+	if err := d.Subdir(attachmentFileDir).Put(ctx, msgid, contentType, size, nil, file); err != nil {
+		return "", kerrors.WithMsg(err, "Failed to save attachment")
+	}
+	b, err := kjson.Marshal(val)
+	if err != nil {
+		return "", kerrors.WithMsg(err, "Failed to encode attachment msg")
+	}
+	if len(b) > lengthCapMsg {
+		return "", governor.ErrWithRes(nil, http.StatusBadRequest, "", "Attachment name is too long")
+	}
+	return string(b), nil
+}
+
+// delAttachments deletes the stored objects of msgs, ignoring msgs that have
+// no attachment
+func (s *Service) delAttachments(ctx context.Context, chatid string, msgids []string) error {
+	d := s.getAttachmentDir(chatid)
+	for _, i := range msgids {
+		if err := d.Subdir(attachmentFileDir).Del(ctx, i); err != nil {
+			if !errors.Is(err, objstore.ErrNotFound) {
+				return kerrors.WithMsg(err, "Failed to delete attachment")
+			}
+		}
+		if err := image.DelVariants(ctx, d, i, attachmentVariants); err != nil {
+			return kerrors.WithMsg(err, "Failed to delete attachment thumbnail")
+		}
+	}
+	return nil
+}
+
+// eraseMsgs erases msgs along with their attachments
+func (s *Service) eraseMsgs(ctx context.Context, chatid string, msgids []string) error {
+	if err := s.delAttachments(ctx, chatid, msgids); err != nil {
+		return err
+	}
+	if err := s.msgs.EraseMsgs(ctx, chatid, msgids); err != nil {
+		return kerrors.WithMsg(err, "Failed to erase msgs")
+	}
+	return nil
+}
+
+// deleteChatMsgs deletes all msgs of a chat along with their attachments
+func (s *Service) deleteChatMsgs(ctx context.Context, chatid string) error {
+	d := s.getAttachmentDir(chatid)
+	after := ""
+	for {
+		m, err := d.List(ctx, "", attachmentListBatchSize, after)
+		if err != nil {
+			return kerrors.WithMsg(err, "Failed to list attachments")
+		}
+		for _, i := range m {
+			after = i.Name
+			if err := d.Del(ctx, i.Name); err != nil {
+				if !errors.Is(err, objstore.ErrNotFound) {
+					return kerrors.WithMsg(err, "Failed to delete attachment")
+				}
+			}
+		}
+		if len(m) < attachmentListBatchSize {
+			break
+		}
+	}
+	if err := s.msgs.DeleteChatMsgs(ctx, chatid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete chat msgs")
+	}
+	return nil
+}
+
+// createAttachmentMsg creates an attachment msg in a chat that the user has
+// access to
+func (s *Service) createAttachmentMsg(ctx context.Context, chatid string, userid string, name string, file io.ReadSeeker, contentType string, size int64, parentid string) (*msgmodel.Model, error) {
+	if err := s.checkMsgParent(ctx, chatid, parentid); err != nil {
+		return nil, err
+	}
+	m, err := s.msgs.New(chatid, userid, chatMsgKindAttachment, "", parentid)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to create new msg")
+	}
+	value, err := s.putAttachment(ctx, chatid, m.Msgid, name, file, contentType, size)
+	if err != nil {
+		if err := s.delAttachments(ctx, chatid, []string{m.Msgid}); err != nil {
+			s.log.Err(ctx, kerrors.WithMsg(err, "Failed to clean up attachment"))
+		}
+		return nil, err
+	}
+	m.Value = value
+	if err := s.msgs.Insert(ctx, m); err != nil {
+		if err := s.delAttachments(ctx, chatid, []string{m.Msgid}); err != nil {
+			s.log.Err(ctx, kerrors.WithMsg(err, "Failed to clean up attachment"))
+		}
+		return nil, kerrors.WithMsg(err, "Failed to send new msg")
+	}
+	return m, nil
+}
+
+type (
+	// attachmentObj is an open attachment
+	attachmentObj struct {
+		Obj         io.ReadCloser
+		Name        string
+		ContentType string
+	}
+)
+
+// getAttachment returns an open attachment, or its thumbnail if thumb is
+// true, of a msg in a chat that the user has access to
+func (s *Service) getAttachment(ctx context.Context, chatid string, msgid string, thumb bool) (*attachmentObj, error) {
+	m, err := s.getChatMsg(ctx, chatid, msgid)
+	if err != nil {
+		return nil, err
+	}
+	if m.Kind != chatMsgKindAttachment || m.Value == "" {
+		return nil, governor.ErrWithRes(nil, http.StatusNotFound, "", "Attachment not found")
+	}
+	var val attachmentValue
+	if err := kjson.Unmarshal([]byte(m.Value), &val); err != nil {
+		return nil, kerrors.WithMsg(err, "Invalid attachment msg")
+	}
+	d := s.getAttachmentDir(chatid).Subdir(attachmentFileDir)
+	if thumb {
+		if !val.Thumb {
+			return nil, governor.ErrWithRes(nil, http.StatusNotFound, "", "Attachment thumbnail not found")
+		}
+		d = s.getAttachmentDir(chatid).Subdir(attachmentVariantThumb)
+	}
+	obj, objinfo, err := d.Get(ctx, msgid)
+	if err != nil {
+		if errors.Is(err, objstore.ErrNotFound) {
+			return nil, governor.ErrWithRes(err, http.StatusNotFound, "", "Attachment not found")
+		}
+		return nil, kerrors.WithMsg(err, "Failed to get attachment")
+	}
+	return &attachmentObj{
+		Obj:         obj,
+		Name:        val.Name,
+		ContentType: objinfo.ContentType,
+	}, nil
+}
+
+func (s *Service) createDMAttachmentMsg(ctx context.Context, userid string, chatid string, name string, file io.ReadSeeker, contentType string, size int64, parentid string) (*resMsg, error) {
+	dm, err := s.getDMByChatid(ctx, userid, chatid)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkMuted(ctx, "", userid); err != nil {
+		return nil, err
+	}
+	m, err := s.createAttachmentMsg(ctx, chatid, userid, name, file, contentType, size, parentid)
+	if err != nil {
+		return nil, err
+	}
+	return s.dmMsgCreated(ctx, dm, m)
+}
+
+func (s *Service) getDMAttachment(ctx context.Context, userid string, chatid string, msgid string, thumb bool) (*attachmentObj, error) {
+	if _, err := s.getDMByChatid(ctx, userid, chatid); err != nil {
+		return nil, err
+	}
+	return s.getAttachment(ctx, chatid, msgid, thumb)
+}
+
+func (s *Service) createGDMAttachmentMsg(ctx context.Context, userid string, chatid string, name string, file io.ReadSeeker, contentType string, size int64, parentid string) (*resMsg, error) {
+	if _, err := s.getGDMByChatid(ctx, userid, chatid); err != nil {
+		return nil, err
+	}
+	if err := s.checkMuted(ctx, "", userid); err != nil {
+		return nil, err
+	}
+	m, err := s.createAttachmentMsg(ctx, chatid, userid, name, file, contentType, size, parentid)
+	if err != nil {
+		return nil, err
+	}
+	return s.gdmMsgCreated(ctx, m)
+}
+
+func (s *Service) getGDMAttachment(ctx context.Context, userid string, chatid string, msgid string, thumb bool) (*attachmentObj, error) {
+	if _, err := s.getGDMByChatid(ctx, userid, chatid); err != nil {
+		return nil, err
+	}
+	return s.getAttachment(ctx, chatid, msgid, thumb)
+}
+
+func (s *Service) createChannelAttachmentMsg(ctx context.Context, serverid, channelid string, userid string, name string, file io.ReadSeeker, contentType string, size int64, parentid string) (*resMsg, error) {
+	ch, err := s.getServerChannelWriter(ctx, serverid, channelid, userid)
+	if err != nil {
+		return nil, err
+	}
+	m, err := s.createAttachmentMsg(ctx, ch.Chatid, userid, name, file, contentType, size, parentid)
+	if err != nil {
+		return nil, err
+	}
+	return s.channelMsgCreated(ctx, ch, m)
+}
+
+func (s *Service) getChannelAttachment(ctx context.Context, serverid, channelid string, msgid string, thumb bool) (*attachmentObj, error) {
+	ch, err := s.getServerChannel(ctx, serverid, channelid)
+	if err != nil {
+		return nil, err
+	}
+	return s.getAttachment(ctx, ch.Chatid, msgid, thumb)
+}
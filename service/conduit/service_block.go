@@ -0,0 +1,139 @@
+package conduit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/dbsql"
+	"xorkevin.dev/governor/service/user"
+	"xorkevin.dev/kerrors"
+)
+
+type (
+	resBlock struct {
+		Userid       string `json:"userid"`
+		CreationTime int64  `json:"creation_time"`
+	}
+
+	resBlocks struct {
+		Blocks []resBlock `json:"blocks"`
+	}
+)
+
+func (s *Service) getBlocked(ctx context.Context, userid string, limit, offset int) (*resBlocks, error) {
+	m, err := s.blocks.GetBlocked(ctx, userid, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get blocked users")
+	}
+	res := make([]resBlock, 0, len(m))
+	for _, i := range m {
+		res = append(res, resBlock{
+			Userid:       i.Blocked,
+			CreationTime: i.CreationTime,
+		})
+	}
+	return &resBlocks{
+		Blocks: res,
+	}, nil
+}
+
+// blockUser blocks a user, removing any friendship and friend invitations
+// between the two users
+func (s *Service) blockUser(ctx context.Context, userid, target string) error {
+	if userid == target {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "May not block self")
+	}
+	if _, err := s.users.GetByID(ctx, target); err != nil {
+		if errors.Is(err, user.ErrNotFound) {
+			return governor.ErrWithRes(err, http.StatusNotFound, "", "User not found")
+		}
+		return kerrors.WithMsg(err, "Failed to get user")
+	}
+	now := time.Now().Round(0).Unix()
+	if err := s.blocks.Insert(ctx, userid, target, now); err != nil {
+		if errors.Is(err, dbsql.ErrUnique) {
+			return governor.ErrWithRes(err, http.StatusBadRequest, "", "User already blocked")
+		}
+		return kerrors.WithMsg(err, "Failed to block user")
+	}
+	if err := s.invitations.DeleteByID(ctx, userid, target); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete friend invitation")
+	}
+	if err := s.invitations.DeleteByID(ctx, target, userid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete friend invitation")
+	}
+	if _, err := s.friends.GetByID(ctx, userid, target); err != nil {
+		if !errors.Is(err, dbsql.ErrNotFound) {
+			return kerrors.WithMsg(err, "Failed to get friends")
+		}
+		return nil
+	}
+	if err := s.removeFriend(ctx, userid, target); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Service) unblockUser(ctx context.Context, userid, target string) error {
+	if _, err := s.blocks.GetByID(ctx, userid, target); err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			return governor.ErrWithRes(err, http.StatusNotFound, "", "Blocked user not found")
+		}
+		return kerrors.WithMsg(err, "Failed to get blocked user")
+	}
+	if err := s.blocks.Remove(ctx, userid, target); err != nil {
+		return kerrors.WithMsg(err, "Failed to unblock user")
+	}
+	return nil
+}
+
+// isBlocked returns if a user has blocked another user
+func (s *Service) isBlocked(ctx context.Context, userid, other string) (bool, error) {
+	if _, err := s.blocks.GetByID(ctx, userid, other); err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			return false, nil
+		}
+		return false, kerrors.WithMsg(err, "Failed to get blocked user")
+	}
+	return true, nil
+}
+
+// checkNotBlocked returns an error if any of the users have blocked a user
+//
+// The error is indistinguishable from not being friends so as to not reveal
+// the block.
+func (s *Service) checkNotBlocked(ctx context.Context, userid string, userids []string) error {
+	m, err := s.blocks.GetBlockersByID(ctx, userid, userids)
+	if err != nil {
+		return kerrors.WithMsg(err, "Failed to get blocking users")
+	}
+	if len(m) != 0 {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "May only add friends to group chat")
+	}
+	return nil
+}
+
+// filterBlocked removes the users blocked by a user
+func (s *Service) filterBlocked(ctx context.Context, userid string, userids []string) ([]string, error) {
+	m, err := s.blocks.GetBlockedByID(ctx, userid, userids)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get blocked users")
+	}
+	if len(m) == 0 {
+		return userids, nil
+	}
+	blocked := make(map[string]struct{}, len(m))
+	for _, i := range m {
+		blocked[i.Blocked] = struct{}{}
+	}
+	res := make([]string, 0, len(userids))
+	for _, i := range userids {
+		if _, ok := blocked[i]; !ok {
+			res = append(res, i)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,507 @@
+package conduit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/conduit/botmodel"
+	"xorkevin.dev/governor/service/conduit/msgmodel"
+	"xorkevin.dev/governor/service/conduit/servermodel"
+	"xorkevin.dev/governor/service/dbsql"
+	"xorkevin.dev/governor/service/gate/apikey"
+	"xorkevin.dev/governor/service/ratelimit"
+	"xorkevin.dev/kerrors"
+)
+
+const (
+	webhookRatelimitKeyID = "conduitwebhook.id"
+)
+
+// botScopeRead returns the scope of bot channel read routes
+func (s *Service) botScopeRead() string {
+	return s.scopens + ".bot.chat:read"
+}
+
+// botScopeWrite returns the scope of bot channel write routes
+func (s *Service) botScopeWrite() string {
+	return s.scopens + ".bot.chat:write"
+}
+
+type (
+	resBot struct {
+		Botid        string `json:"botid"`
+		Name         string `json:"name"`
+		Avatar       string `json:"avatar"`
+		Creatorid    string `json:"creatorid"`
+		CreationTime int64  `json:"creation_time"`
+	}
+
+	resBots struct {
+		Bots []resBot `json:"bots"`
+	}
+)
+
+func botToRes(m *botmodel.Model) resBot {
+	return resBot{
+		Botid:        m.Botid,
+		Name:         m.Name,
+		Avatar:       m.Avatar,
+		Creatorid:    m.Creatorid,
+		CreationTime: m.CreationTime,
+	}
+}
+
+func (s *Service) createBot(ctx context.Context, userid string, name, avatar string) (*resBot, error) {
+	m, err := s.bots.New(name, avatar, userid)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to create new bot")
+	}
+	if err := s.bots.Insert(ctx, m); err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to create new bot")
+	}
+	res := botToRes(m)
+	return &res, nil
+}
+
+// getCreatorBot returns a bot created by the user
+func (s *Service) getCreatorBot(ctx context.Context, userid string, botid string) (*botmodel.Model, error) {
+	m, err := s.bots.GetBot(ctx, botid)
+	if err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			return nil, governor.ErrWithRes(err, http.StatusNotFound, "", "Bot not found")
+		}
+		return nil, kerrors.WithMsg(err, "Failed to get bot")
+	}
+	if m.Creatorid != userid {
+		return nil, governor.ErrWithRes(nil, http.StatusNotFound, "", "Bot not found")
+	}
+	return m, nil
+}
+
+func (s *Service) getBot(ctx context.Context, userid string, botid string) (*resBot, error) {
+	m, err := s.getCreatorBot(ctx, userid, botid)
+	if err != nil {
+		return nil, err
+	}
+	res := botToRes(m)
+	return &res, nil
+}
+
+func (s *Service) getBots(ctx context.Context, userid string, limit, offset int) (*resBots, error) {
+	m, err := s.bots.GetCreatorBots(ctx, userid, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get bots")
+	}
+	res := make([]resBot, 0, len(m))
+	for _, i := range m {
+		res = append(res, botToRes(&i))
+	}
+	return &resBots{
+		Bots: res,
+	}, nil
+}
+
+func (s *Service) updateBot(ctx context.Context, userid string, botid string, name, avatar string) error {
+	m, err := s.getCreatorBot(ctx, userid, botid)
+	if err != nil {
+		return err
+	}
+	m.Name = name
+	m.Avatar = avatar
+	if err := s.bots.UpdateProps(ctx, m); err != nil {
+		return kerrors.WithMsg(err, "Failed to update bot")
+	}
+	return nil
+}
+
+func (s *Service) deleteBot(ctx context.Context, userid string, botid string) error {
+	if _, err := s.getCreatorBot(ctx, userid, botid); err != nil {
+		return err
+	}
+	return s.rmBot(ctx, botid)
+}
+
+// rmBot deletes a bot along with its api keys
+func (s *Service) rmBot(ctx context.Context, botid string) error {
+	if err := s.apikeys.DeleteUserKeys(ctx, botid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete bot api keys")
+	}
+	if err := s.bots.Delete(ctx, botid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete bot")
+	}
+	return nil
+}
+
+type (
+	resBotKeys struct {
+		Keys []apikey.Props `json:"keys"`
+	}
+)
+
+// createBotKey creates an api key with which a bot authenticates
+func (s *Service) createBotKey(ctx context.Context, userid string, botid string, name, desc string) (*apikey.Key, error) {
+	if _, err := s.getCreatorBot(ctx, userid, botid); err != nil {
+		return nil, err
+	}
+	res, err := s.apikeys.InsertKey(ctx, botid, s.botScopeRead()+" "+s.botScopeWrite(), name, desc)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to create bot api key")
+	}
+	return res, nil
+}
+
+func (s *Service) getBotKeys(ctx context.Context, userid string, botid string, limit, offset int) (*resBotKeys, error) {
+	if _, err := s.getCreatorBot(ctx, userid, botid); err != nil {
+		return nil, err
+	}
+	res, err := s.apikeys.GetUserKeys(ctx, botid, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get bot api keys")
+	}
+	if res == nil {
+		res = []apikey.Props{}
+	}
+	return &resBotKeys{
+		Keys: res,
+	}, nil
+}
+
+func (s *Service) deleteBotKey(ctx context.Context, userid string, botid string, keyid string) error {
+	if _, err := s.getCreatorBot(ctx, userid, botid); err != nil {
+		return err
+	}
+	if err := s.apikeys.DeleteKey(ctx, botid, keyid); err != nil {
+		if errors.Is(err, apikey.ErrNotFound) {
+			return governor.ErrWithRes(err, http.StatusNotFound, "", "Api key not found")
+		}
+		return kerrors.WithMsg(err, "Failed to delete bot api key")
+	}
+	return nil
+}
+
+// checkBot returns if the id belongs to a bot
+func (s *Service) checkBot(ctx context.Context, botid string) (bool, error) {
+	if _, err := s.bots.GetBot(ctx, botid); err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			return false, nil
+		}
+		return false, kerrors.WithMsg(err, "Failed to get bot")
+	}
+	return true, nil
+}
+
+type (
+	resChannelBot struct {
+		Botid        string `json:"botid"`
+		Name         string `json:"name"`
+		Avatar       string `json:"avatar"`
+		CreationTime int64  `json:"creation_time"`
+	}
+
+	resChannelBots struct {
+		Bots []resChannelBot `json:"bots"`
+	}
+)
+
+func (s *Service) getChannelBots(ctx context.Context, serverid, channelid string, limit, offset int) (*resChannelBots, error) {
+	if _, err := s.getServerChannel(ctx, serverid, channelid); err != nil {
+		return nil, err
+	}
+	m, err := s.bots.GetChannelBots(ctx, serverid, channelid, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get channel bots")
+	}
+	res := make([]resChannelBot, 0, len(m))
+	for _, i := range m {
+		b, err := s.bots.GetBot(ctx, i.Botid)
+		if err != nil {
+			if errors.Is(err, dbsql.ErrNotFound) {
+				continue
+			}
+			return nil, kerrors.WithMsg(err, "Failed to get bot")
+		}
+		res = append(res, resChannelBot{
+			Botid:        i.Botid,
+			Name:         b.Name,
+			Avatar:       b.Avatar,
+			CreationTime: i.CreationTime,
+		})
+	}
+	return &resChannelBots{
+		Bots: res,
+	}, nil
+}
+
+func (s *Service) addChannelBot(ctx context.Context, serverid, channelid string, userid string, botid string) error {
+	if err := s.requireServerPerm(ctx, serverid, userid, serverPermManageChannels); err != nil {
+		return err
+	}
+	if _, err := s.getServerChannel(ctx, serverid, channelid); err != nil {
+		return err
+	}
+	if ok, err := s.checkBot(ctx, botid); err != nil {
+		return err
+	} else if !ok {
+		return governor.ErrWithRes(nil, http.StatusNotFound, "", "Bot not found")
+	}
+	if err := s.bots.InsertChannel(ctx, s.bots.NewChannel(botid, serverid, channelid)); err != nil {
+		if errors.Is(err, dbsql.ErrUnique) {
+			return governor.ErrWithRes(err, http.StatusBadRequest, "", "Bot already added to channel")
+		}
+		return kerrors.WithMsg(err, "Failed to add bot to channel")
+	}
+	return nil
+}
+
+func (s *Service) rmChannelBot(ctx context.Context, serverid, channelid string, userid string, botid string) error {
+	if err := s.requireServerPerm(ctx, serverid, userid, serverPermManageChannels); err != nil {
+		return err
+	}
+	if _, err := s.bots.GetChannel(ctx, botid, serverid, channelid); err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			return governor.ErrWithRes(err, http.StatusNotFound, "", "Bot not found")
+		}
+		return kerrors.WithMsg(err, "Failed to get channel bot")
+	}
+	if err := s.bots.DeleteChannel(ctx, botid, serverid, channelid); err != nil {
+		return kerrors.WithMsg(err, "Failed to remove bot from channel")
+	}
+	return nil
+}
+
+type (
+	resBotChannel struct {
+		ServerID     string `json:"serverid"`
+		ChannelID    string `json:"channelid"`
+		CreationTime int64  `json:"creation_time"`
+	}
+
+	resBotChannels struct {
+		Channels []resBotChannel `json:"channels"`
+	}
+)
+
+func (s *Service) getBotChannels(ctx context.Context, botid string, limit, offset int) (*resBotChannels, error) {
+	m, err := s.bots.GetBotChannels(ctx, botid, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get bot channels")
+	}
+	res := make([]resBotChannel, 0, len(m))
+	for _, i := range m {
+		res = append(res, resBotChannel{
+			ServerID:     i.ServerID,
+			ChannelID:    i.ChannelID,
+			CreationTime: i.CreationTime,
+		})
+	}
+	return &resBotChannels{
+		Channels: res,
+	}, nil
+}
+
+// getBotChannel returns a channel that a bot was added to
+func (s *Service) getBotChannel(ctx context.Context, botid string, serverid, channelid string) (*servermodel.ChannelModel, error) {
+	if _, err := s.bots.GetChannel(ctx, botid, serverid, channelid); err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			return nil, governor.ErrWithRes(err, http.StatusNotFound, "", "Channel not found")
+		}
+		return nil, kerrors.WithMsg(err, "Failed to get channel bot")
+	}
+	return s.getServerChannel(ctx, serverid, channelid)
+}
+
+func (s *Service) getBotChannelMsgs(ctx context.Context, botid string, serverid, channelid string, kind string, before string, limit int) (*resMsgs, error) {
+	ch, err := s.getBotChannel(ctx, botid, serverid, channelid)
+	if err != nil {
+		return nil, err
+	}
+	return s.getChatMsgs(ctx, ch.Chatid, botid, kind, before, limit)
+}
+
+func (s *Service) createBotChannelMsg(ctx context.Context, botid string, serverid, channelid string, kind string, value string, parentid string) (*resMsg, error) {
+	b, err := s.bots.GetBot(ctx, botid)
+	if err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			return nil, governor.ErrWithRes(err, http.StatusNotFound, "", "Bot not found")
+		}
+		return nil, kerrors.WithMsg(err, "Failed to get bot")
+	}
+	ch, err := s.getBotChannel(ctx, botid, serverid, channelid)
+	if err != nil {
+		return nil, err
+	}
+	m, err := s.createAuthorMsg(ctx, ch.Chatid, botid, msgAuthor{
+		Kind:   msgmodel.AuthorKindBot,
+		Name:   b.Name,
+		Avatar: b.Avatar,
+	}, kind, value, parentid)
+	if err != nil {
+		return nil, err
+	}
+	return s.channelMsgCreated(ctx, ch, m)
+}
+
+type (
+	resWebhook struct {
+		Webhookid    string `json:"webhookid"`
+		ServerID     string `json:"serverid"`
+		ChannelID    string `json:"channelid"`
+		Name         string `json:"name"`
+		Avatar       string `json:"avatar"`
+		Creatorid    string `json:"creatorid"`
+		CreationTime int64  `json:"creation_time"`
+	}
+
+	resWebhooks struct {
+		Webhooks []resWebhook `json:"webhooks"`
+	}
+
+	resWebhookKey struct {
+		Webhookid string `json:"webhookid"`
+		Key       string `json:"key"`
+	}
+)
+
+func webhookToRes(m *botmodel.WebhookModel) resWebhook {
+	return resWebhook{
+		Webhookid:    m.Webhookid,
+		ServerID:     m.ServerID,
+		ChannelID:    m.ChannelID,
+		Name:         m.Name,
+		Avatar:       m.Avatar,
+		Creatorid:    m.Creatorid,
+		CreationTime: m.CreationTime,
+	}
+}
+
+// getChannelWebhook returns a webhook of a channel
+func (s *Service) getChannelWebhook(ctx context.Context, serverid, channelid string, webhookid string) (*botmodel.WebhookModel, error) {
+	m, err := s.bots.GetWebhook(ctx, webhookid)
+	if err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			return nil, governor.ErrWithRes(err, http.StatusNotFound, "", "Webhook not found")
+		}
+		return nil, kerrors.WithMsg(err, "Failed to get webhook")
+	}
+	if m.ServerID != serverid || m.ChannelID != channelid {
+		return nil, governor.ErrWithRes(nil, http.StatusNotFound, "", "Webhook not found")
+	}
+	return m, nil
+}
+
+func (s *Service) createWebhook(ctx context.Context, serverid, channelid string, userid string, name, avatar string) (*resWebhookKey, error) {
+	if err := s.requireServerPerm(ctx, serverid, userid, serverPermManageChannels); err != nil {
+		return nil, err
+	}
+	if _, err := s.getServerChannel(ctx, serverid, channelid); err != nil {
+		return nil, err
+	}
+	m, key, err := s.bots.NewWebhook(serverid, channelid, name, avatar, userid)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to create new webhook")
+	}
+	if err := s.bots.InsertWebhook(ctx, m); err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to create new webhook")
+	}
+	return &resWebhookKey{
+		Webhookid: m.Webhookid,
+		Key:       key,
+	}, nil
+}
+
+func (s *Service) getWebhooks(ctx context.Context, serverid, channelid string, userid string, limit, offset int) (*resWebhooks, error) {
+	if err := s.requireServerPerm(ctx, serverid, userid, serverPermManageChannels); err != nil {
+		return nil, err
+	}
+	if _, err := s.getServerChannel(ctx, serverid, channelid); err != nil {
+		return nil, err
+	}
+	m, err := s.bots.GetChannelWebhooks(ctx, serverid, channelid, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get webhooks")
+	}
+	res := make([]resWebhook, 0, len(m))
+	for _, i := range m {
+		res = append(res, webhookToRes(&i))
+	}
+	return &resWebhooks{
+		Webhooks: res,
+	}, nil
+}
+
+func (s *Service) updateWebhook(ctx context.Context, serverid, channelid string, userid string, webhookid string, name, avatar string) error {
+	if err := s.requireServerPerm(ctx, serverid, userid, serverPermManageChannels); err != nil {
+		return err
+	}
+	m, err := s.getChannelWebhook(ctx, serverid, channelid, webhookid)
+	if err != nil {
+		return err
+	}
+	m.Name = name
+	m.Avatar = avatar
+	if err := s.bots.UpdateWebhookProps(ctx, m); err != nil {
+		return kerrors.WithMsg(err, "Failed to update webhook")
+	}
+	return nil
+}
+
+func (s *Service) deleteWebhook(ctx context.Context, serverid, channelid string, userid string, webhookid string) error {
+	if err := s.requireServerPerm(ctx, serverid, userid, serverPermManageChannels); err != nil {
+		return err
+	}
+	if _, err := s.getChannelWebhook(ctx, serverid, channelid, webhookid); err != nil {
+		return err
+	}
+	if err := s.bots.DeleteWebhook(ctx, webhookid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete webhook")
+	}
+	return nil
+}
+
+// execWebhook posts a msg to the channel of a webhook, where name and avatar
+// optionally override those of the webhook
+func (s *Service) execWebhook(ctx context.Context, webhookid string, key string, name, avatar string, value string) (*resMsg, error) {
+	// ratelimit before validating the key in order to limit key guessing
+	if err := s.ratelimiter.Ratelimit(ctx, []ratelimit.Tag{
+		{
+			Key:    webhookRatelimitKeyID,
+			Value:  webhookid,
+			Params: s.webhookLimit,
+		},
+	}); err != nil {
+		return nil, err
+	}
+	m, err := s.bots.GetWebhook(ctx, webhookid)
+	if err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			return nil, governor.ErrWithRes(err, http.StatusNotFound, "", "Webhook not found")
+		}
+		return nil, kerrors.WithMsg(err, "Failed to get webhook")
+	}
+	if ok, err := s.bots.ValidateWebhookKey(key, m); err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to validate webhook key")
+	} else if !ok {
+		return nil, governor.ErrWithRes(nil, http.StatusNotFound, "", "Webhook not found")
+	}
+	ch, err := s.getServerChannel(ctx, m.ServerID, m.ChannelID)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = m.Name
+	}
+	if avatar == "" {
+		avatar = m.Avatar
+	}
+	msg, err := s.createAuthorMsg(ctx, ch.Chatid, m.Webhookid, msgAuthor{
+		Kind:   msgmodel.AuthorKindWebhook,
+		Name:   name,
+		Avatar: avatar,
+	}, chatMsgKindTxt, value, "")
+	if err != nil {
+		return nil, err
+	}
+	return s.channelMsgCreated(ctx, ch, msg)
+}
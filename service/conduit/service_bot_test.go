@@ -0,0 +1,128 @@
+package conduit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/conduit/botmodel"
+	"xorkevin.dev/governor/service/dbsql"
+	"xorkevin.dev/governor/service/ratelimit"
+	"xorkevin.dev/kerrors"
+)
+
+type (
+	testBots struct {
+		botmodel.Repo
+		webhooks  map[string]*botmodel.WebhookModel
+		gets      int
+		validates int
+	}
+
+	testRatelimiter struct {
+		ratelimit.Ratelimiter
+		err  error
+		tags []ratelimit.Tag
+	}
+)
+
+func (r *testBots) GetWebhook(ctx context.Context, webhookid string) (*botmodel.WebhookModel, error) {
+	r.gets++
+	m, ok := r.webhooks[webhookid]
+	if !ok {
+		return nil, kerrors.WithKind(nil, dbsql.ErrNotFound, "Webhook not found")
+	}
+	return m, nil
+}
+
+func (r *testBots) ValidateWebhookKey(key string, m *botmodel.WebhookModel) (bool, error) {
+	r.validates++
+	return "hash:"+key == m.KeyHash, nil
+}
+
+func (r *testRatelimiter) Ratelimit(ctx context.Context, tags []ratelimit.Tag) error {
+	r.tags = append(r.tags, tags...)
+	return r.err
+}
+
+func TestExecWebhook(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		Test        string
+		Webhookid   string
+		Key         string
+		Ratelimited bool
+		Gets        int
+		Validates   int
+		Status      int
+	}{
+		{
+			Test:        "ratelimits before validating key",
+			Webhookid:   "hook1",
+			Key:         "secret",
+			Ratelimited: true,
+			Status:      http.StatusTooManyRequests,
+		},
+		{
+			Test:      "wrong key",
+			Webhookid: "hook1",
+			Key:       "guess",
+			Gets:      1,
+			Validates: 1,
+			Status:    http.StatusNotFound,
+		},
+		{
+			Test:      "missing webhook",
+			Webhookid: "missing",
+			Key:       "secret",
+			Gets:      1,
+			Status:    http.StatusNotFound,
+		},
+	} {
+		t.Run(tc.Test, func(t *testing.T) {
+			t.Parallel()
+
+			assert := require.New(t)
+
+			bots := &testBots{
+				webhooks: map[string]*botmodel.WebhookModel{
+					"hook1": {
+						Webhookid: "hook1",
+						ServerID:  "server1",
+						ChannelID: "channel1",
+						KeyHash:   "hash:secret",
+					},
+				},
+			}
+			limiter := &testRatelimiter{}
+			if tc.Ratelimited {
+				limiter.err = governor.ErrWithRes(nil, http.StatusTooManyRequests, "", "Ratelimited")
+			}
+			limit := ratelimit.Params{Period: 60, Limit: 8}
+			s := &Service{
+				bots:         bots,
+				ratelimiter:  limiter,
+				webhookLimit: limit,
+			}
+
+			_, err := s.execWebhook(context.Background(), tc.Webhookid, tc.Key, "", "", "hello")
+			assert.Error(err)
+			var errres *governor.ErrorRes
+			assert.True(errors.As(err, &errres))
+			assert.Equal(tc.Status, errres.Status)
+			assert.Equal([]ratelimit.Tag{
+				{
+					Key:    webhookRatelimitKeyID,
+					Value:  tc.Webhookid,
+					Params: limit,
+				},
+			}, limiter.tags)
+			assert.Equal(tc.Gets, bots.gets)
+			assert.Equal(tc.Validates, bots.validates)
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"xorkevin.dev/governor"
 	"xorkevin.dev/governor/service/conduit/dmmodel"
+	"xorkevin.dev/governor/service/conduit/msgmodel"
 	"xorkevin.dev/governor/service/dbsql"
 	"xorkevin.dev/kerrors"
 	"xorkevin.dev/klog"
@@ -96,6 +97,8 @@ type (
 		Theme        string `json:"theme"`
 		LastUpdated  int64  `json:"last_updated"`
 		CreationTime int64  `json:"creation_time"`
+		ReadMsgid    string `json:"read_msgid"`
+		Unread       int    `json:"unread"`
 	}
 
 	resDMs struct {
@@ -110,6 +113,24 @@ func useridDiff(a, b, c string) string {
 	return b
 }
 
+func (s *Service) fillDMsUnread(ctx context.Context, userid string, res []resDM) error {
+	chatids := make([]string, 0, len(res))
+	for _, i := range res {
+		chatids = append(chatids, i.Chatid)
+	}
+	unread, err := s.getUnread(ctx, userid, chatids)
+	if err != nil {
+		return err
+	}
+	for n, i := range res {
+		if k, ok := unread[i.Chatid]; ok {
+			res[n].ReadMsgid = k.ReadMsgid
+			res[n].Unread = k.Count
+		}
+	}
+	return nil
+}
+
 func (s *Service) getLatestDMs(ctx context.Context, userid string, before int64, limit int) (*resDMs, error) {
 	m, err := s.dms.GetLatest(ctx, userid, before, limit)
 	if err != nil {
@@ -126,6 +147,9 @@ func (s *Service) getLatestDMs(ctx context.Context, userid string, before int64,
 			CreationTime: i.CreationTime,
 		})
 	}
+	if err := s.fillDMsUnread(ctx, userid, res); err != nil {
+		return nil, err
+	}
 	return &resDMs{
 		DMs: res,
 	}, nil
@@ -150,6 +174,9 @@ func (s *Service) getDMs(ctx context.Context, userid string, chatids []string) (
 			CreationTime: i.CreationTime,
 		})
 	}
+	if err := s.fillDMsUnread(ctx, userid, res); err != nil {
+		return nil, err
+	}
 	return &resDMs{
 		DMs: res,
 	}, nil
@@ -198,83 +225,62 @@ func (s *Service) searchDMs(ctx context.Context, userid string, prefix string, l
 	}, nil
 }
 
-type (
-	resMsg struct {
-		Chatid string `json:"chatid"`
-		Msgid  string `json:"msgid"`
-		Userid string `json:"userid"`
-		Timems int64  `json:"time_ms"`
-		Kind   string `json:"kind"`
-		Value  string `json:"value"`
-	}
-)
-
-func (s *Service) createDMMsg(ctx context.Context, userid string, chatid string, kind string, value string) (*resMsg, error) {
+func (s *Service) createDMMsg(ctx context.Context, userid string, chatid string, kind string, value string, parentid string) (*resMsg, error) {
 	dm, err := s.getDMByChatid(ctx, userid, chatid)
 	if err != nil {
 		return nil, err
 	}
-	m, err := s.msgs.New(chatid, userid, kind, value)
-	if err != nil {
-		return nil, kerrors.WithMsg(err, "Failed to create new dm msg")
+	if err := s.checkMuted(ctx, "", userid); err != nil {
+		return nil, err
 	}
-	if err := s.msgs.Insert(ctx, m); err != nil {
-		return nil, kerrors.WithMsg(err, "Failed to send new dm msg")
+	m, err := s.createMsg(ctx, chatid, userid, kind, value, parentid)
+	if err != nil {
+		return nil, err
 	}
+	return s.dmMsgCreated(ctx, dm, m)
+}
+
+// dmMsgCreated updates the dm and notifies its users of a new msg
+func (s *Service) dmMsgCreated(ctx context.Context, dm *dmmodel.Model, m *msgmodel.Model) (*resMsg, error) {
 	if err := s.dms.UpdateLastUpdated(ctx, dm.Userid1, dm.Userid2, m.Timems); err != nil {
 		return nil, kerrors.WithMsg(err, "Failed to update dm last updated")
 	}
-	res := resMsg{
-		Chatid: m.Chatid,
-		Msgid:  m.Msgid,
-		Userid: m.Userid,
-		Timems: m.Timems,
-		Kind:   m.Kind,
-		Value:  m.Value,
-	}
+	res := msgToRes(m)
 	// must make a best effort attempt to publish dm msg event
 	ctx = klog.ExtendCtx(context.Background(), ctx)
-	s.publishDMMsgEvent(ctx, []string{dm.Userid1, dm.Userid2}, res)
+	s.publishDMMsgEvent(ctx, []string{dm.Userid1, dm.Userid2}, resMsgEvent{
+		Kind: msgEventKindCreate,
+		Msg:  &res,
+	})
+	if m.Kind == chatMsgKindTxt {
+		s.publishMsgIndexEvent(ctx, m.Chatid, m.Msgid)
+	}
 	return &res, nil
 }
 
-type (
-	resMsgs struct {
-		Msgs []resMsg `json:"msgs"`
-	}
-)
-
 func (s *Service) getDMMsgs(ctx context.Context, userid string, chatid string, kind string, before string, limit int) (*resMsgs, error) {
 	if _, err := s.getDMByChatid(ctx, userid, chatid); err != nil {
 		return nil, err
 	}
-	m, err := s.msgs.GetMsgs(ctx, chatid, kind, before, limit)
-	if err != nil {
-		return nil, kerrors.WithMsg(err, "Failed to get dm msgs")
-	}
-	res := make([]resMsg, 0, len(m))
-	for _, i := range m {
-		res = append(res, resMsg{
-			Chatid: i.Chatid,
-			Msgid:  i.Msgid,
-			Userid: i.Userid,
-			Timems: i.Timems,
-			Kind:   i.Kind,
-			Value:  i.Value,
-		})
-	}
-	return &resMsgs{
-		Msgs: res,
-	}, nil
+	return s.getChatMsgs(ctx, chatid, userid, kind, before, limit)
 }
 
 func (s *Service) delDMMsg(ctx context.Context, userid string, chatid string, msgid string) error {
-	if _, err := s.getDMByChatid(ctx, userid, chatid); err != nil {
+	dm, err := s.getDMByChatid(ctx, userid, chatid)
+	if err != nil {
 		return err
 	}
-	if err := s.msgs.EraseMsgs(ctx, chatid, []string{msgid}); err != nil {
+	if err := s.eraseMsgs(ctx, chatid, []string{msgid}); err != nil {
 		return kerrors.WithMsg(err, "Failed to delete dm msg")
 	}
-	// TODO: emit msg delete event
+	// must make a best effort attempt to publish dm msg event
+	ctx = klog.ExtendCtx(context.Background(), ctx)
+	s.publishDMMsgEvent(ctx, []string{dm.Userid1, dm.Userid2}, resMsgEvent{
+		Kind: msgEventKindErase,
+		Erase: &resMsgErase{
+			Chatid: chatid,
+			Msgids: []string{msgid},
+		},
+	})
 	return nil
 }
@@ -95,6 +95,17 @@ func (s *Service) removeFriend(ctx context.Context, userid1, userid2 string) err
 }
 
 func (s *Service) inviteFriend(ctx context.Context, userid string, invitedBy string) error {
+	if ok, err := s.isBlocked(ctx, invitedBy, userid); err != nil {
+		return err
+	} else if ok {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "May not invite blocked user")
+	}
+	if ok, err := s.isBlocked(ctx, userid, invitedBy); err != nil {
+		return err
+	} else if ok {
+		// invitations from blocked users are silently dropped
+		return nil
+	}
 	if _, err := s.friends.GetByID(ctx, userid, invitedBy); err != nil {
 		if !errors.Is(err, dbsql.ErrNotFound) {
 			return kerrors.WithMsg(err, "Failed to search friends")
@@ -135,6 +146,16 @@ func (s *Service) acceptFriendInvitation(ctx context.Context, userid, inviter st
 		}
 		return kerrors.WithMsg(err, "Failed to get friend invitation")
 	}
+	if ok, err := s.isBlocked(ctx, userid, inviter); err != nil {
+		return err
+	} else if ok {
+		return governor.ErrWithRes(nil, http.StatusNotFound, "", "Friend invitation not found")
+	}
+	if ok, err := s.isBlocked(ctx, inviter, userid); err != nil {
+		return err
+	} else if ok {
+		return governor.ErrWithRes(nil, http.StatusNotFound, "", "Friend invitation not found")
+	}
 
 	b, err := encodeConduitEventFriend(friendProps{
 		Userid:    m.Userid,
@@ -241,7 +262,7 @@ func (s *Service) unfriendEventHandler(ctx context.Context, props unfriendProps)
 		}
 		return kerrors.WithMsg(err, "Failed to get dm")
 	}
-	if err := s.msgs.DeleteChatMsgs(ctx, m.Chatid); err != nil {
+	if err := s.deleteChatMsgs(ctx, m.Chatid); err != nil {
 		return kerrors.WithMsg(err, "Failed to delete dm msgs")
 	}
 	if err := s.dms.Delete(ctx, props.Userid, props.Other); err != nil {
@@ -257,7 +278,7 @@ func (s *Service) rmFriend(ctx context.Context, userid1, userid2 string) error {
 			return kerrors.WithMsg(err, "Failed to get dm")
 		}
 	} else {
-		if err := s.msgs.DeleteChatMsgs(ctx, m.Chatid); err != nil {
+		if err := s.deleteChatMsgs(ctx, m.Chatid); err != nil {
 			return kerrors.WithMsg(err, "Failed to delete dm msgs")
 		}
 		if err := s.dms.Delete(ctx, userid1, userid2); err != nil {
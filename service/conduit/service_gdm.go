@@ -8,6 +8,7 @@ import (
 
 	"xorkevin.dev/governor"
 	"xorkevin.dev/governor/service/conduit/gdmmodel"
+	"xorkevin.dev/governor/service/conduit/msgmodel"
 	"xorkevin.dev/governor/service/dbsql"
 	"xorkevin.dev/kerrors"
 	"xorkevin.dev/klog"
@@ -106,6 +107,8 @@ type (
 		LastUpdated  int64    `json:"last_updated"`
 		CreationTime int64    `json:"creation_time"`
 		Members      []string `json:"members"`
+		ReadMsgid    string   `json:"read_msgid"`
+		Unread       int      `json:"unread"`
 	}
 )
 
@@ -124,6 +127,9 @@ func (s *Service) createGDM(ctx context.Context, name string, theme string, requ
 	if err := s.checkFriends(ctx, userids[0], userids[1:]); err != nil {
 		return nil, err
 	}
+	if err := s.checkNotBlocked(ctx, userids[0], userids[1:]); err != nil {
+		return nil, err
+	}
 
 	m, err := s.gdms.New(name, theme)
 	if err != nil {
@@ -212,6 +218,9 @@ func (s *Service) addGDMMembers(ctx context.Context, userid string, chatid strin
 	if err := s.checkFriends(ctx, userid, members); err != nil {
 		return err
 	}
+	if err := s.checkNotBlocked(ctx, userid, members); err != nil {
+		return err
+	}
 
 	// TODO use transaction to maintain member count
 	count, err := s.gdms.GetMembersCount(ctx, chatid)
@@ -283,7 +292,7 @@ func (s *Service) deleteGDM(ctx context.Context, userid string, chatid string) e
 		return err
 	}
 
-	if err := s.msgs.DeleteChatMsgs(ctx, chatid); err != nil {
+	if err := s.deleteChatMsgs(ctx, chatid); err != nil {
 		return kerrors.WithMsg(err, "Failed to delete group chat messages")
 	}
 	if err := s.gdms.Delete(ctx, chatid); err != nil {
@@ -313,7 +322,7 @@ func (s *Service) rmGDMUser(ctx context.Context, chatid string, userid string) e
 		}
 		return kerrors.WithMsg(err, "Failed to get gdm")
 	}
-	if err := s.msgs.DeleteChatMsgs(ctx, chatid); err != nil {
+	if err := s.deleteChatMsgs(ctx, chatid); err != nil {
 		return kerrors.WithMsg(err, "Failed to delete group chat messages")
 	}
 	if err := s.gdms.Delete(ctx, chatid); err != nil {
@@ -329,7 +338,7 @@ type (
 	}
 )
 
-func (s *Service) getGDMsWithMembers(ctx context.Context, chatids []string) (*resGDMs, error) {
+func (s *Service) getGDMsWithMembers(ctx context.Context, userid string, chatids []string) (*resGDMs, error) {
 	m, err := s.gdms.GetChats(ctx, chatids)
 	if err != nil {
 		return nil, kerrors.WithMsg(err, "Failed to get group chats")
@@ -342,6 +351,10 @@ func (s *Service) getGDMsWithMembers(ctx context.Context, chatids []string) (*re
 	for _, i := range members {
 		memMap[i.Chatid] = append(memMap[i.Chatid], i.Userid)
 	}
+	unread, err := s.getUnread(ctx, userid, chatids)
+	if err != nil {
+		return nil, err
+	}
 	chatMap := map[string]gdmmodel.Model{}
 	for _, i := range m {
 		chatMap[i.Chatid] = i
@@ -359,6 +372,8 @@ func (s *Service) getGDMsWithMembers(ctx context.Context, chatids []string) (*re
 			LastUpdated:  k.LastUpdated,
 			CreationTime: k.CreationTime,
 			Members:      memMap[k.Chatid],
+			ReadMsgid:    unread[k.Chatid].ReadMsgid,
+			Unread:       unread[k.Chatid].Count,
 		})
 	}
 	return &resGDMs{
@@ -371,7 +386,7 @@ func (s *Service) getLatestGDMs(ctx context.Context, userid string, before int64
 	if err != nil {
 		return nil, kerrors.WithMsg(err, "Failed to get latest group chats")
 	}
-	return s.getGDMsWithMembers(ctx, chatids)
+	return s.getGDMsWithMembers(ctx, userid, chatids)
 }
 
 func (s *Service) getGDMs(ctx context.Context, userid string, reqchatids []string) (*resGDMs, error) {
@@ -379,7 +394,7 @@ func (s *Service) getGDMs(ctx context.Context, userid string, reqchatids []strin
 	if err != nil {
 		return nil, kerrors.WithMsg(err, "Failed to get group chats")
 	}
-	return s.getGDMsWithMembers(ctx, chatids)
+	return s.getGDMsWithMembers(ctx, userid, chatids)
 }
 
 func (s *Service) searchGDMs(ctx context.Context, userid1, userid2 string, limit, offset int) (*resGDMs, error) {
@@ -387,34 +402,38 @@ func (s *Service) searchGDMs(ctx context.Context, userid1, userid2 string, limit
 	if err != nil {
 		return nil, kerrors.WithMsg(err, "Failed to search group chats")
 	}
-	return s.getGDMsWithMembers(ctx, chatids)
+	return s.getGDMsWithMembers(ctx, userid1, chatids)
 }
 
-func (s *Service) createGDMMsg(ctx context.Context, userid string, chatid string, kind string, value string) (*resMsg, error) {
+func (s *Service) createGDMMsg(ctx context.Context, userid string, chatid string, kind string, value string, parentid string) (*resMsg, error) {
 	if _, err := s.getGDMByChatid(ctx, userid, chatid); err != nil {
 		return nil, err
 	}
-	m, err := s.msgs.New(chatid, userid, kind, value)
-	if err != nil {
-		return nil, kerrors.WithMsg(err, "Failed to create new group chat msg")
+	if err := s.checkMuted(ctx, "", userid); err != nil {
+		return nil, err
 	}
-	if err := s.msgs.Insert(ctx, m); err != nil {
-		return nil, kerrors.WithMsg(err, "Failed to send new group chat msg")
+	m, err := s.createMsg(ctx, chatid, userid, kind, value, parentid)
+	if err != nil {
+		return nil, err
 	}
-	if err := s.gdms.UpdateLastUpdated(ctx, chatid, m.Timems); err != nil {
+	return s.gdmMsgCreated(ctx, m)
+}
+
+// gdmMsgCreated updates the group chat and notifies its members of a new msg
+func (s *Service) gdmMsgCreated(ctx context.Context, m *msgmodel.Model) (*resMsg, error) {
+	if err := s.gdms.UpdateLastUpdated(ctx, m.Chatid, m.Timems); err != nil {
 		return nil, kerrors.WithMsg(err, "Failed to update group chat last updated")
 	}
-	res := resMsg{
-		Chatid: m.Chatid,
-		Msgid:  m.Msgid,
-		Userid: m.Userid,
-		Timems: m.Timems,
-		Kind:   m.Kind,
-		Value:  m.Value,
-	}
+	res := msgToRes(m)
 	// must make a best effort to publish gdm msg event
 	ctx = klog.ExtendCtx(context.Background(), ctx)
-	s.publishGDMMsgEvent(ctx, chatid, res)
+	s.publishGDMMsgEvent(ctx, m.Chatid, resMsgEvent{
+		Kind: msgEventKindCreate,
+		Msg:  &res,
+	})
+	if m.Kind == chatMsgKindTxt {
+		s.publishMsgIndexEvent(ctx, m.Chatid, m.Msgid)
+	}
 	return &res, nil
 }
 
@@ -422,33 +441,24 @@ func (s *Service) getGDMMsgs(ctx context.Context, userid string, chatid string,
 	if _, err := s.getGDMByChatid(ctx, userid, chatid); err != nil {
 		return nil, err
 	}
-	m, err := s.msgs.GetMsgs(ctx, chatid, kind, before, limit)
-	if err != nil {
-		return nil, kerrors.WithMsg(err, "Failed to get group chat msgs")
-	}
-	res := make([]resMsg, 0, len(m))
-	for _, i := range m {
-		res = append(res, resMsg{
-			Chatid: i.Chatid,
-			Msgid:  i.Msgid,
-			Userid: i.Userid,
-			Timems: i.Timems,
-			Kind:   i.Kind,
-			Value:  i.Value,
-		})
-	}
-	return &resMsgs{
-		Msgs: res,
-	}, nil
+	return s.getChatMsgs(ctx, chatid, userid, kind, before, limit)
 }
 
 func (s *Service) delGDMMsg(ctx context.Context, userid string, chatid string, msgid string) error {
 	if _, err := s.getGDMByChatid(ctx, userid, chatid); err != nil {
 		return err
 	}
-	if err := s.msgs.EraseMsgs(ctx, chatid, []string{msgid}); err != nil {
+	if err := s.eraseMsgs(ctx, chatid, []string{msgid}); err != nil {
 		return kerrors.WithMsg(err, "Failed to delete group chat msg")
 	}
-	// TODO: emit msg delete event
+	// must make a best effort to publish gdm msg event
+	ctx = klog.ExtendCtx(context.Background(), ctx)
+	s.publishGDMMsgEvent(ctx, chatid, resMsgEvent{
+		Kind: msgEventKindErase,
+		Erase: &resMsgErase{
+			Chatid: chatid,
+			Msgids: []string{msgid},
+		},
+	})
 	return nil
 }
@@ -0,0 +1,607 @@
+package conduit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/authzacl"
+	"xorkevin.dev/governor/service/conduit/servermodel"
+	"xorkevin.dev/governor/service/dbsql"
+	"xorkevin.dev/governor/util/rank"
+	"xorkevin.dev/kerrors"
+	"xorkevin.dev/klog"
+)
+
+const (
+	aclNSUser       = "gov.user"
+	aclNSServer     = "gov.conduit.server"
+	aclNSServerRole = "gov.conduit.server.role"
+	aclRelOwner     = "owner"
+	aclRelMember    = "member"
+)
+
+const (
+	serverPermManageServer   = "manage_server"
+	serverPermManageChannels = "manage_channels"
+	serverPermDeleteMsgs     = "delete_msgs"
+	serverPermKick           = "kick"
+	serverPermBan            = "ban"
+	serverPermModerate       = "moderate"
+)
+
+// serverPerms are all permissions that may be granted to a server role
+var serverPerms = []string{
+	serverPermManageServer,
+	serverPermManageChannels,
+	serverPermDeleteMsgs,
+	serverPermKick,
+	serverPermBan,
+	serverPermModerate,
+}
+
+func serverOwnerRel(serverid, userid string) authzacl.Relation {
+	return authzacl.Rel(aclNSServer, serverid, aclRelOwner, aclNSUser, userid, "")
+}
+
+func (s *Service) checkServerOwner(ctx context.Context, serverid, userid string) (bool, error) {
+	ok, err := s.acl.Check(ctx, authzacl.Obj{
+		NS:  aclNSServer,
+		Key: serverid,
+	}, aclRelOwner, authzacl.Sub{
+		NS:  aclNSUser,
+		Key: userid,
+	})
+	if err != nil {
+		return false, kerrors.WithMsg(err, "Failed to check server owner")
+	}
+	return ok, nil
+}
+
+// checkServerPerm returns if a user has a server permission either as the
+// server owner or through one of their server roles
+func (s *Service) checkServerPerm(ctx context.Context, serverid, userid string, perm string) (bool, error) {
+	if ok, err := s.checkServerOwner(ctx, serverid, userid); err != nil {
+		return false, err
+	} else if ok {
+		return true, nil
+	}
+	subs, err := s.acl.Read(ctx, authzacl.ObjRel{
+		NS:   aclNSServer,
+		Key:  serverid,
+		Pred: perm,
+	}, nil, serverRoleCap)
+	if err != nil {
+		return false, kerrors.WithMsg(err, "Failed to get server permission roles")
+	}
+	for _, i := range subs {
+		if i.NS != aclNSServerRole || i.Pred != aclRelMember {
+			continue
+		}
+		ok, err := s.acl.Check(ctx, authzacl.Obj{
+			NS:  aclNSServerRole,
+			Key: i.Key,
+		}, aclRelMember, authzacl.Sub{
+			NS:  aclNSUser,
+			Key: userid,
+		})
+		if err != nil {
+			return false, kerrors.WithMsg(err, "Failed to check server role member")
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *Service) requireServerPerm(ctx context.Context, serverid, userid string, perm string) error {
+	ok, err := s.checkServerPerm(ctx, serverid, userid, perm)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return governor.ErrWithRes(nil, http.StatusForbidden, "", "User does not have permission")
+	}
+	return nil
+}
+
+const (
+	serverBackfillBatchSize = 256
+)
+
+const (
+	serverMemberEventKindJoin  = "join"
+	serverMemberEventKindLeave = "leave"
+)
+
+type (
+	resBackfillServerMembers struct {
+		Backfilled int `json:"backfilled"`
+		Skipped    int `json:"skipped"`
+	}
+)
+
+// backfillServerMembers adds the members and owners of servers created
+// before server membership was tracked by conduit
+//
+// Servers were previously scoped to the org of the same name, so the org
+// members become server members and the org moderators become server owners.
+// Servers that already have members are skipped, since their membership is
+// managed by conduit, and banned users are never added.
+func (s *Service) backfillServerMembers(ctx context.Context) (*resBackfillServerMembers, error) {
+	res := &resBackfillServerMembers{}
+	for offset := 0; ; offset += serverBackfillBatchSize {
+		m, err := s.servers.GetServers(ctx, serverBackfillBatchSize, offset)
+		if err != nil {
+			return nil, kerrors.WithMsg(err, "Failed to get servers")
+		}
+		for _, i := range m {
+			members, err := s.servers.GetMembers(ctx, i.ServerID, 1, 0)
+			if err != nil {
+				return nil, kerrors.WithMsg(err, "Failed to get server members")
+			}
+			if len(members) > 0 {
+				res.Skipped++
+				continue
+			}
+			if err := s.backfillServerOrgMembers(ctx, i.ServerID); err != nil {
+				return nil, err
+			}
+			res.Backfilled++
+		}
+		if len(m) < serverBackfillBatchSize {
+			return res, nil
+		}
+	}
+}
+
+func (s *Service) backfillServerOrgMembers(ctx context.Context, serverid string) error {
+	orgname := rank.ToOrgName(serverid)
+	if err := s.backfillServerRoleMembers(ctx, serverid, rank.ToModName(orgname), true); err != nil {
+		return err
+	}
+	if err := s.backfillServerRoleMembers(ctx, serverid, rank.ToUsrName(orgname), false); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Service) backfillServerRoleMembers(ctx context.Context, serverid string, roleName string, owner bool) error {
+	for offset := 0; ; offset += serverBackfillBatchSize {
+		userids, err := s.roles.GetByRole(ctx, roleName, serverBackfillBatchSize, offset)
+		if err != nil {
+			return kerrors.WithMsg(err, "Failed to get org role users")
+		}
+		members := make([]*servermodel.MemberModel, 0, len(userids))
+		var rels []authzacl.Relation
+		for _, i := range userids {
+			if _, err := s.servers.GetBan(ctx, serverid, i); err != nil {
+				if !errors.Is(err, dbsql.ErrNotFound) {
+					return kerrors.WithMsg(err, "Failed to get server ban")
+				}
+			} else {
+				continue
+			}
+			members = append(members, s.servers.NewMember(serverid, i))
+			if owner {
+				rels = append(rels, serverOwnerRel(serverid, i))
+			}
+		}
+		if err := s.servers.InsertMembers(ctx, members); err != nil {
+			return kerrors.WithMsg(err, "Failed to add server members")
+		}
+		if len(rels) > 0 {
+			if err := s.acl.InsertRelations(ctx, rels); err != nil {
+				return kerrors.WithMsg(err, "Failed to add server owners")
+			}
+		}
+		if len(userids) < serverBackfillBatchSize {
+			return nil
+		}
+	}
+}
+
+func (s *Service) checkServerMember(ctx context.Context, serverid, userid string) (bool, error) {
+	if _, err := s.servers.GetMember(ctx, serverid, userid); err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			return false, nil
+		}
+		return false, kerrors.WithMsg(err, "Failed to get server member")
+	}
+	return true, nil
+}
+
+func (s *Service) checkServerBan(ctx context.Context, serverid, userid string) error {
+	if _, err := s.servers.GetBan(ctx, serverid, userid); err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			return nil
+		}
+		return kerrors.WithMsg(err, "Failed to get server ban")
+	}
+	return governor.ErrWithRes(nil, http.StatusForbidden, "", "User is banned from server")
+}
+
+// getServerChannelWriter returns a server channel that the user may send msgs
+// to
+func (s *Service) getServerChannelWriter(ctx context.Context, serverid, channelid string, userid string) (*servermodel.ChannelModel, error) {
+	if err := s.checkServerBan(ctx, serverid, userid); err != nil {
+		return nil, err
+	}
+	if err := s.checkMuted(ctx, serverid, userid); err != nil {
+		return nil, err
+	}
+	return s.getServerChannel(ctx, serverid, channelid)
+}
+
+type (
+	resServerMember struct {
+		ServerID     string `json:"serverid"`
+		Userid       string `json:"userid"`
+		CreationTime int64  `json:"creation_time"`
+	}
+
+	resServerMembers struct {
+		Members []resServerMember `json:"members"`
+	}
+
+	resServerMemberEvent struct {
+		Kind     string `json:"kind"`
+		ServerID string `json:"serverid"`
+		Userid   string `json:"userid"`
+	}
+)
+
+// publishServerMemberEvent publishes a member event to the online members of
+// a server and to the member themselves
+func (s *Service) publishServerMemberEvent(ctx context.Context, serverid string, v resServerMemberEvent) {
+	after := time.Now().Round(0).Add(-presenceDuration).Unix()
+	m, err := s.servers.GetPresence(ctx, serverid, after, channelPresenceScanCap, 0)
+	if err != nil {
+		s.log.Err(ctx, kerrors.WithMsg(err, "Failed to get server presence"))
+		return
+	}
+	userids := make([]string, 0, len(m)+1)
+	userids = append(userids, v.Userid)
+	for _, i := range m {
+		if i.Userid != v.Userid {
+			userids = append(userids, i.Userid)
+		}
+	}
+	for _, i := range userids {
+		if err := s.ws.Publish(ctx, i, s.opts.ServerMemberChannel, v); err != nil {
+			s.log.Err(ctx, kerrors.WithMsg(err, "Failed to publish server member event"))
+		}
+	}
+}
+
+func serverMembersToRes(m []servermodel.MemberModel) *resServerMembers {
+	res := make([]resServerMember, 0, len(m))
+	for _, i := range m {
+		res = append(res, resServerMember{
+			ServerID:     i.ServerID,
+			Userid:       i.Userid,
+			CreationTime: i.CreationTime,
+		})
+	}
+	return &resServerMembers{
+		Members: res,
+	}
+}
+
+func (s *Service) getServerMembers(ctx context.Context, serverid string, limit, offset int) (*resServerMembers, error) {
+	m, err := s.servers.GetMembers(ctx, serverid, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get server members")
+	}
+	return serverMembersToRes(m), nil
+}
+
+func (s *Service) getUserServers(ctx context.Context, userid string, limit, offset int) (*resServerMembers, error) {
+	m, err := s.servers.GetUserServers(ctx, userid, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get user servers")
+	}
+	return serverMembersToRes(m), nil
+}
+
+// rmServerMember removes a user from a server along with their server roles
+func (s *Service) rmServerMember(ctx context.Context, serverid, userid string) error {
+	roles, err := s.servers.GetRoles(ctx, serverid, serverRoleCap, 0)
+	if err != nil {
+		return kerrors.WithMsg(err, "Failed to get server roles")
+	}
+	rels := make([]authzacl.Relation, 0, len(roles))
+	for _, i := range roles {
+		rels = append(rels, serverRoleMemberRel(i.Roleid, userid))
+	}
+	if err := s.acl.DeleteRelations(ctx, rels); err != nil {
+		return kerrors.WithMsg(err, "Failed to remove server member roles")
+	}
+	if err := s.servers.DeleteMember(ctx, serverid, userid); err != nil {
+		return kerrors.WithMsg(err, "Failed to remove server member")
+	}
+	// must make a best effort to publish server member event
+	ctx = klog.ExtendCtx(context.Background(), ctx)
+	s.publishServerMemberEvent(ctx, serverid, resServerMemberEvent{
+		Kind:     serverMemberEventKindLeave,
+		ServerID: serverid,
+		Userid:   userid,
+	})
+	return nil
+}
+
+func (s *Service) leaveServer(ctx context.Context, serverid, userid string) error {
+	if ok, err := s.checkServerOwner(ctx, serverid, userid); err != nil {
+		return err
+	} else if ok {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Server owner may not leave the server")
+	}
+	return s.rmServerMember(ctx, serverid, userid)
+}
+
+// checkServerModTarget returns an error if the target of a moderation action
+// may not be moderated
+func (s *Service) checkServerModTarget(ctx context.Context, serverid, userid string, target string) error {
+	if userid == target {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "May not moderate self")
+	}
+	if ok, err := s.checkServerOwner(ctx, serverid, target); err != nil {
+		return err
+	} else if ok {
+		return governor.ErrWithRes(nil, http.StatusForbidden, "", "May not moderate server owner")
+	}
+	return nil
+}
+
+func (s *Service) kickServerMember(ctx context.Context, serverid, userid string, target string) error {
+	if err := s.requireServerPerm(ctx, serverid, userid, serverPermKick); err != nil {
+		return err
+	}
+	if err := s.checkServerModTarget(ctx, serverid, userid, target); err != nil {
+		return err
+	}
+	if ok, err := s.checkServerMember(ctx, serverid, target); err != nil {
+		return err
+	} else if !ok {
+		return governor.ErrWithRes(nil, http.StatusNotFound, "", "Member not found")
+	}
+	if err := s.rmServerMember(ctx, serverid, target); err != nil {
+		return err
+	}
+	if err := s.insertAudit(ctx, serverid, userid, auditActionKick, auditEntry{
+		Targetid: target,
+	}); err != nil {
+		return err
+	}
+	return nil
+}
+
+type (
+	resServerInvite struct {
+		Code         string `json:"code"`
+		ServerID     string `json:"serverid"`
+		CreatorID    string `json:"creator_id"`
+		MaxUses      int    `json:"max_uses"`
+		Uses         int    `json:"uses"`
+		Expires      int64  `json:"expires"`
+		CreationTime int64  `json:"creation_time"`
+	}
+
+	resServerInvites struct {
+		Invites []resServerInvite `json:"invites"`
+	}
+)
+
+func serverInviteToRes(m *servermodel.InviteModel) resServerInvite {
+	return resServerInvite{
+		Code:         m.Code,
+		ServerID:     m.ServerID,
+		CreatorID:    m.CreatorID,
+		MaxUses:      m.MaxUses,
+		Uses:         m.Uses,
+		Expires:      m.Expires,
+		CreationTime: m.CreationTime,
+	}
+}
+
+func (s *Service) createServerInvite(ctx context.Context, serverid, userid string, maxUses int, duration int64) (*resServerInvite, error) {
+	if err := s.requireServerPerm(ctx, serverid, userid, serverPermManageServer); err != nil {
+		return nil, err
+	}
+	var expires int64
+	if duration > 0 {
+		expires = time.Now().Round(0).Unix() + duration
+	}
+	m, err := s.servers.NewInvite(serverid, userid, maxUses, expires)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to create server invite")
+	}
+	if err := s.servers.InsertInvite(ctx, m); err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to create server invite")
+	}
+	res := serverInviteToRes(m)
+	return &res, nil
+}
+
+func (s *Service) getServerInvites(ctx context.Context, serverid, userid string, limit, offset int) (*resServerInvites, error) {
+	if err := s.requireServerPerm(ctx, serverid, userid, serverPermManageServer); err != nil {
+		return nil, err
+	}
+	m, err := s.servers.GetInvites(ctx, serverid, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get server invites")
+	}
+	res := make([]resServerInvite, 0, len(m))
+	for _, i := range m {
+		res = append(res, serverInviteToRes(&i))
+	}
+	return &resServerInvites{
+		Invites: res,
+	}, nil
+}
+
+func (s *Service) deleteServerInvite(ctx context.Context, serverid, userid string, code string) error {
+	if err := s.requireServerPerm(ctx, serverid, userid, serverPermManageServer); err != nil {
+		return err
+	}
+	if err := s.servers.DeleteInvite(ctx, serverid, code); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete server invite")
+	}
+	return nil
+}
+
+// getValidServerInvite returns an invite if it is not expired and has
+// remaining uses
+func (s *Service) getValidServerInvite(ctx context.Context, code string) (*servermodel.InviteModel, error) {
+	m, err := s.servers.GetInvite(ctx, code)
+	if err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			return nil, governor.ErrWithRes(err, http.StatusNotFound, "", "Invite not found")
+		}
+		return nil, kerrors.WithMsg(err, "Failed to get server invite")
+	}
+	if m.Expires != 0 && m.Expires <= time.Now().Round(0).Unix() {
+		return nil, governor.ErrWithRes(nil, http.StatusBadRequest, "", "Invite has expired")
+	}
+	if m.MaxUses != 0 && m.Uses >= m.MaxUses {
+		return nil, governor.ErrWithRes(nil, http.StatusBadRequest, "", "Invite has expired")
+	}
+	return m, nil
+}
+
+func (s *Service) getServerByInvite(ctx context.Context, code string) (*resServer, error) {
+	m, err := s.getValidServerInvite(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	return s.getServer(ctx, m.ServerID)
+}
+
+func (s *Service) joinServer(ctx context.Context, userid string, code string) (*resServerMember, error) {
+	inv, err := s.getValidServerInvite(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkServerBan(ctx, inv.ServerID, userid); err != nil {
+		return nil, err
+	}
+	if ok, err := s.checkServerMember(ctx, inv.ServerID, userid); err != nil {
+		return nil, err
+	} else if ok {
+		return nil, governor.ErrWithRes(nil, http.StatusConflict, "", "Already a server member")
+	}
+	m := s.servers.NewMember(inv.ServerID, userid)
+	if err := s.servers.InsertMember(ctx, m); err != nil {
+		if errors.Is(err, dbsql.ErrUnique) {
+			return nil, governor.ErrWithRes(err, http.StatusConflict, "", "Already a server member")
+		}
+		return nil, kerrors.WithMsg(err, "Failed to add server member")
+	}
+	// the invite use is spent only after the member is added, and the member is
+	// removed if the use fails
+	ok, err := s.servers.UseInvite(ctx, code, time.Now().Round(0).Unix())
+	if err != nil {
+		err = kerrors.WithMsg(err, "Failed to use server invite")
+	} else if !ok {
+		err = governor.ErrWithRes(nil, http.StatusBadRequest, "", "Invite has expired")
+	}
+	if err != nil {
+		if err := s.servers.DeleteMember(ctx, inv.ServerID, userid); err != nil {
+			s.log.Err(ctx, kerrors.WithMsg(err, "Failed to remove server member"))
+		}
+		return nil, err
+	}
+	// must make a best effort to publish server member event
+	ctx = klog.ExtendCtx(context.Background(), ctx)
+	s.publishServerMemberEvent(ctx, m.ServerID, resServerMemberEvent{
+		Kind:     serverMemberEventKindJoin,
+		ServerID: m.ServerID,
+		Userid:   m.Userid,
+	})
+	return &resServerMember{
+		ServerID:     m.ServerID,
+		Userid:       m.Userid,
+		CreationTime: m.CreationTime,
+	}, nil
+}
+
+type (
+	resServerBan struct {
+		ServerID     string `json:"serverid"`
+		Userid       string `json:"userid"`
+		CreatorID    string `json:"creator_id"`
+		Reason       string `json:"reason"`
+		CreationTime int64  `json:"creation_time"`
+	}
+
+	resServerBans struct {
+		Bans []resServerBan `json:"bans"`
+	}
+)
+
+func (s *Service) banServerUser(ctx context.Context, serverid, userid string, target string, reason string) error {
+	if err := s.requireServerPerm(ctx, serverid, userid, serverPermBan); err != nil {
+		return err
+	}
+	if err := s.checkServerModTarget(ctx, serverid, userid, target); err != nil {
+		return err
+	}
+	m := s.servers.NewBan(serverid, target, userid, reason)
+	if err := s.servers.InsertBan(ctx, m); err != nil {
+		if errors.Is(err, dbsql.ErrUnique) {
+			return governor.ErrWithRes(err, http.StatusConflict, "", "User already banned")
+		}
+		return kerrors.WithMsg(err, "Failed to ban user")
+	}
+	if err := s.rmServerMember(ctx, serverid, target); err != nil {
+		return err
+	}
+	if err := s.insertAudit(ctx, serverid, userid, auditActionBan, auditEntry{
+		Targetid: target,
+		Detail:   reason,
+	}); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Service) unbanServerUser(ctx context.Context, serverid, userid string, target string) error {
+	if err := s.requireServerPerm(ctx, serverid, userid, serverPermBan); err != nil {
+		return err
+	}
+	if err := s.servers.DeleteBan(ctx, serverid, target); err != nil {
+		return kerrors.WithMsg(err, "Failed to unban user")
+	}
+	if err := s.insertAudit(ctx, serverid, userid, auditActionUnban, auditEntry{
+		Targetid: target,
+	}); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Service) getServerBans(ctx context.Context, serverid, userid string, limit, offset int) (*resServerBans, error) {
+	if err := s.requireServerPerm(ctx, serverid, userid, serverPermBan); err != nil {
+		return nil, err
+	}
+	m, err := s.servers.GetBans(ctx, serverid, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get server bans")
+	}
+	res := make([]resServerBan, 0, len(m))
+	for _, i := range m {
+		res = append(res, resServerBan{
+			ServerID:     i.ServerID,
+			Userid:       i.Userid,
+			CreatorID:    i.CreatorID,
+			Reason:       i.Reason,
+			CreationTime: i.CreationTime,
+		})
+	}
+	return &resServerBans{
+		Bans: res,
+	}, nil
+}
@@ -0,0 +1,460 @@
+package conduit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/conduit/modmodel"
+	"xorkevin.dev/governor/service/dbsql"
+	"xorkevin.dev/governor/service/events/sysevent"
+	"xorkevin.dev/kerrors"
+)
+
+const (
+	auditActionReportResolve = "report.resolve"
+	auditActionReportDismiss = "report.dismiss"
+	auditActionMsgDelete     = "msg.delete"
+	auditActionMute          = "mute"
+	auditActionUnmute        = "unmute"
+	auditActionKick          = "kick"
+	auditActionBan           = "ban"
+	auditActionUnban         = "unban"
+)
+
+type (
+	// auditEntry describes the target of a moderation action
+	auditEntry struct {
+		Targetid string
+		Reportid string
+		Chatid   string
+		Msgid    string
+		Detail   string
+	}
+)
+
+// insertAudit records a moderation action in the audit log of a server, or
+// the global audit log if serverid is empty
+func (s *Service) insertAudit(ctx context.Context, serverid string, actorid string, action string, entry auditEntry) error {
+	m, err := s.mods.NewAudit(serverid, actorid, action)
+	if err != nil {
+		return kerrors.WithMsg(err, "Failed to create audit log entry")
+	}
+	m.Targetid = entry.Targetid
+	m.Reportid = entry.Reportid
+	m.Chatid = entry.Chatid
+	m.Msgid = entry.Msgid
+	m.Detail = entry.Detail
+	if err := s.mods.InsertAudit(ctx, m); err != nil {
+		return kerrors.WithMsg(err, "Failed to record moderation action")
+	}
+	return nil
+}
+
+func (s *Service) checkMute(ctx context.Context, serverid, userid string, now int64) error {
+	if _, err := s.mods.GetMute(ctx, serverid, userid, now); err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			return nil
+		}
+		return kerrors.WithMsg(err, "Failed to get mute")
+	}
+	return governor.ErrWithRes(nil, http.StatusForbidden, "", "User is muted")
+}
+
+// checkMuted returns an error if the user has been muted globally, or in the
+// server if serverid is provided
+func (s *Service) checkMuted(ctx context.Context, serverid, userid string) error {
+	now := time.Now().Round(0).Unix()
+	if err := s.checkMute(ctx, "", userid, now); err != nil {
+		return err
+	}
+	if serverid != "" {
+		if err := s.checkMute(ctx, serverid, userid, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type (
+	resReport struct {
+		Reportid     string `json:"reportid"`
+		ServerID     string `json:"serverid"`
+		Chatid       string `json:"chatid"`
+		Msgid        string `json:"msgid"`
+		Userid       string `json:"userid"`
+		Reporterid   string `json:"reporterid"`
+		Kind         string `json:"kind"`
+		Value        string `json:"value"`
+		Reason       string `json:"reason"`
+		Status       string `json:"status"`
+		Resolverid   string `json:"resolverid"`
+		ResolveTime  int64  `json:"resolve_time"`
+		CreationTime int64  `json:"creation_time"`
+	}
+
+	resReports struct {
+		Reports []resReport `json:"reports"`
+	}
+
+	resReportID struct {
+		Reportid string `json:"reportid"`
+	}
+)
+
+func reportToRes(m *modmodel.ReportModel) resReport {
+	return resReport{
+		Reportid:     m.Reportid,
+		ServerID:     m.ServerID,
+		Chatid:       m.Chatid,
+		Msgid:        m.Msgid,
+		Userid:       m.Userid,
+		Reporterid:   m.Reporterid,
+		Kind:         m.Kind,
+		Value:        m.Value,
+		Reason:       m.Reason,
+		Status:       m.Status,
+		Resolverid:   m.Resolverid,
+		ResolveTime:  m.ResolveTime,
+		CreationTime: m.CreationTime,
+	}
+}
+
+// reportMsg snapshots the content of a msg into the moderation queue of a
+// server, or the global moderation queue if serverid is empty
+func (s *Service) reportMsg(ctx context.Context, serverid, chatid string, userid string, msgid string, reason string) (*resReportID, error) {
+	msg, err := s.getChatMsg(ctx, chatid, msgid)
+	if err != nil {
+		return nil, err
+	}
+	if msg.Value == "" {
+		return nil, governor.ErrWithRes(nil, http.StatusBadRequest, "", "Msg has been deleted")
+	}
+	m, err := s.mods.NewReport(serverid, chatid, msgid, msg.Userid, userid, msg.Kind, msg.Value, reason)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to create report")
+	}
+	if err := s.mods.InsertReport(ctx, m); err != nil {
+		if errors.Is(err, dbsql.ErrUnique) {
+			return nil, governor.ErrWithRes(err, http.StatusConflict, "", "Msg already reported")
+		}
+		return nil, kerrors.WithMsg(err, "Failed to report msg")
+	}
+	return &resReportID{
+		Reportid: m.Reportid,
+	}, nil
+}
+
+func (s *Service) reportDMMsg(ctx context.Context, userid string, chatid string, msgid string, reason string) (*resReportID, error) {
+	if _, err := s.getDMByChatid(ctx, userid, chatid); err != nil {
+		return nil, err
+	}
+	return s.reportMsg(ctx, "", chatid, userid, msgid, reason)
+}
+
+func (s *Service) reportGDMMsg(ctx context.Context, userid string, chatid string, msgid string, reason string) (*resReportID, error) {
+	if _, err := s.getGDMByChatid(ctx, userid, chatid); err != nil {
+		return nil, err
+	}
+	return s.reportMsg(ctx, "", chatid, userid, msgid, reason)
+}
+
+func (s *Service) reportChannelMsg(ctx context.Context, serverid, channelid string, userid string, msgid string, reason string) (*resReportID, error) {
+	ch, err := s.getServerChannel(ctx, serverid, channelid)
+	if err != nil {
+		return nil, err
+	}
+	return s.reportMsg(ctx, serverid, ch.Chatid, userid, msgid, reason)
+}
+
+func (s *Service) getReports(ctx context.Context, serverid string, status string, limit, offset int) (*resReports, error) {
+	var m []modmodel.ReportModel
+	var err error
+	if status == "" {
+		m, err = s.mods.GetReports(ctx, serverid, limit, offset)
+	} else {
+		m, err = s.mods.GetReportsByStatus(ctx, serverid, status, limit, offset)
+	}
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get reports")
+	}
+	res := make([]resReport, 0, len(m))
+	for _, i := range m {
+		res = append(res, reportToRes(&i))
+	}
+	return &resReports{
+		Reports: res,
+	}, nil
+}
+
+func (s *Service) getServerReports(ctx context.Context, serverid, userid string, status string, limit, offset int) (*resReports, error) {
+	if err := s.requireServerPerm(ctx, serverid, userid, serverPermModerate); err != nil {
+		return nil, err
+	}
+	return s.getReports(ctx, serverid, status, limit, offset)
+}
+
+func (s *Service) getReport(ctx context.Context, reportid string) (*modmodel.ReportModel, error) {
+	m, err := s.mods.GetReport(ctx, reportid)
+	if err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			return nil, governor.ErrWithRes(err, http.StatusNotFound, "", "Report not found")
+		}
+		return nil, kerrors.WithMsg(err, "Failed to get report")
+	}
+	return m, nil
+}
+
+func (s *Service) getAdminReport(ctx context.Context, reportid string) (*resReport, error) {
+	m, err := s.getReport(ctx, reportid)
+	if err != nil {
+		return nil, err
+	}
+	res := reportToRes(m)
+	return &res, nil
+}
+
+func (s *Service) getServerReport(ctx context.Context, serverid, userid string, reportid string) (*resReport, error) {
+	if err := s.requireServerPerm(ctx, serverid, userid, serverPermModerate); err != nil {
+		return nil, err
+	}
+	m, err := s.getReport(ctx, reportid)
+	if err != nil {
+		return nil, err
+	}
+	if m.ServerID != serverid {
+		return nil, governor.ErrWithRes(nil, http.StatusNotFound, "", "Report not found")
+	}
+	res := reportToRes(m)
+	return &res, nil
+}
+
+// resolveReport resolves or dismisses a report, optionally deleting the
+// reported msg
+func (s *Service) resolveReport(ctx context.Context, m *modmodel.ReportModel, userid string, status string, deleteMsg bool) error {
+	if m.Status != modmodel.ReportStatusOpen {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Report already resolved")
+	}
+	if deleteMsg && status != modmodel.ReportStatusResolved {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "May only delete msg of resolved report")
+	}
+	if deleteMsg {
+		if err := s.expireMsgs(ctx, m.Chatid, []string{m.Msgid}); err != nil {
+			return kerrors.WithMsg(err, "Failed to delete reported msg")
+		}
+		if err := s.insertAudit(ctx, m.ServerID, userid, auditActionMsgDelete, auditEntry{
+			Targetid: m.Userid,
+			Reportid: m.Reportid,
+			Chatid:   m.Chatid,
+			Msgid:    m.Msgid,
+		}); err != nil {
+			return err
+		}
+	}
+	if err := s.mods.ResolveReport(ctx, m, status, userid); err != nil {
+		return kerrors.WithMsg(err, "Failed to resolve report")
+	}
+	action := auditActionReportResolve
+	if status == modmodel.ReportStatusDismissed {
+		action = auditActionReportDismiss
+	}
+	if err := s.insertAudit(ctx, m.ServerID, userid, action, auditEntry{
+		Targetid: m.Userid,
+		Reportid: m.Reportid,
+		Chatid:   m.Chatid,
+		Msgid:    m.Msgid,
+	}); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Service) resolveAdminReport(ctx context.Context, userid string, reportid string, status string, deleteMsg bool) error {
+	m, err := s.getReport(ctx, reportid)
+	if err != nil {
+		return err
+	}
+	return s.resolveReport(ctx, m, userid, status, deleteMsg)
+}
+
+func (s *Service) resolveServerReport(ctx context.Context, serverid, userid string, reportid string, status string, deleteMsg bool) error {
+	if err := s.requireServerPerm(ctx, serverid, userid, serverPermModerate); err != nil {
+		return err
+	}
+	if deleteMsg {
+		if err := s.requireServerPerm(ctx, serverid, userid, serverPermDeleteMsgs); err != nil {
+			return err
+		}
+	}
+	m, err := s.getReport(ctx, reportid)
+	if err != nil {
+		return err
+	}
+	if m.ServerID != serverid {
+		return governor.ErrWithRes(nil, http.StatusNotFound, "", "Report not found")
+	}
+	return s.resolveReport(ctx, m, userid, status, deleteMsg)
+}
+
+type (
+	resMute struct {
+		ServerID     string `json:"serverid"`
+		Userid       string `json:"userid"`
+		Mutedby      string `json:"mutedby"`
+		Reason       string `json:"reason"`
+		Expires      int64  `json:"expires"`
+		CreationTime int64  `json:"creation_time"`
+	}
+
+	resMutes struct {
+		Mutes []resMute `json:"mutes"`
+	}
+)
+
+func muteToRes(m *modmodel.MuteModel) resMute {
+	return resMute{
+		ServerID:     m.ServerID,
+		Userid:       m.Userid,
+		Mutedby:      m.Mutedby,
+		Reason:       m.Reason,
+		Expires:      m.Expires,
+		CreationTime: m.CreationTime,
+	}
+}
+
+// muteUser prevents a user from sending msgs in a server, or all chats if
+// serverid is empty, for a duration in seconds
+func (s *Service) muteUser(ctx context.Context, serverid, userid string, target string, duration int64, reason string) (*resMute, error) {
+	if userid == target {
+		return nil, governor.ErrWithRes(nil, http.StatusBadRequest, "", "May not moderate self")
+	}
+	expires := time.Now().Round(0).Add(time.Duration(duration) * time.Second).Unix()
+	m, err := s.mods.SetMute(ctx, serverid, target, userid, reason, expires)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to mute user")
+	}
+	if err := s.insertAudit(ctx, serverid, userid, auditActionMute, auditEntry{
+		Targetid: target,
+		Detail:   reason,
+	}); err != nil {
+		return nil, err
+	}
+	res := muteToRes(m)
+	return &res, nil
+}
+
+func (s *Service) unmuteUser(ctx context.Context, serverid, userid string, target string) error {
+	if err := s.mods.DeleteMute(ctx, serverid, target); err != nil {
+		return kerrors.WithMsg(err, "Failed to unmute user")
+	}
+	if err := s.insertAudit(ctx, serverid, userid, auditActionUnmute, auditEntry{
+		Targetid: target,
+	}); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Service) getMutes(ctx context.Context, serverid string, limit, offset int) (*resMutes, error) {
+	m, err := s.mods.GetMutes(ctx, serverid, time.Now().Round(0).Unix(), limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get mutes")
+	}
+	res := make([]resMute, 0, len(m))
+	for _, i := range m {
+		res = append(res, muteToRes(&i))
+	}
+	return &resMutes{
+		Mutes: res,
+	}, nil
+}
+
+func (s *Service) muteServerUser(ctx context.Context, serverid, userid string, target string, duration int64, reason string) (*resMute, error) {
+	if err := s.requireServerPerm(ctx, serverid, userid, serverPermModerate); err != nil {
+		return nil, err
+	}
+	if err := s.checkServerModTarget(ctx, serverid, userid, target); err != nil {
+		return nil, err
+	}
+	if ok, err := s.checkServerMember(ctx, serverid, target); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, governor.ErrWithRes(nil, http.StatusNotFound, "", "Member not found")
+	}
+	return s.muteUser(ctx, serverid, userid, target, duration, reason)
+}
+
+func (s *Service) unmuteServerUser(ctx context.Context, serverid, userid string, target string) error {
+	if err := s.requireServerPerm(ctx, serverid, userid, serverPermModerate); err != nil {
+		return err
+	}
+	return s.unmuteUser(ctx, serverid, userid, target)
+}
+
+func (s *Service) getServerMutes(ctx context.Context, serverid, userid string, limit, offset int) (*resMutes, error) {
+	if err := s.requireServerPerm(ctx, serverid, userid, serverPermModerate); err != nil {
+		return nil, err
+	}
+	return s.getMutes(ctx, serverid, limit, offset)
+}
+
+type (
+	resAudit struct {
+		ServerID     string `json:"serverid"`
+		Auditid      string `json:"auditid"`
+		Actorid      string `json:"actorid"`
+		Action       string `json:"action"`
+		Targetid     string `json:"targetid"`
+		Reportid     string `json:"reportid"`
+		Chatid       string `json:"chatid"`
+		Msgid        string `json:"msgid"`
+		Detail       string `json:"detail"`
+		CreationTime int64  `json:"creation_time"`
+	}
+
+	resAudits struct {
+		Audits []resAudit `json:"audits"`
+	}
+)
+
+func (s *Service) getAudits(ctx context.Context, serverid string, limit, offset int) (*resAudits, error) {
+	m, err := s.mods.GetAudits(ctx, serverid, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get audit log")
+	}
+	res := make([]resAudit, 0, len(m))
+	for _, i := range m {
+		res = append(res, resAudit{
+			ServerID:     i.ServerID,
+			Auditid:      i.Auditid,
+			Actorid:      i.Actorid,
+			Action:       i.Action,
+			Targetid:     i.Targetid,
+			Reportid:     i.Reportid,
+			Chatid:       i.Chatid,
+			Msgid:        i.Msgid,
+			Detail:       i.Detail,
+			CreationTime: i.CreationTime,
+		})
+	}
+	return &resAudits{
+		Audits: res,
+	}, nil
+}
+
+func (s *Service) getServerAudits(ctx context.Context, serverid, userid string, limit, offset int) (*resAudits, error) {
+	if err := s.requireServerPerm(ctx, serverid, userid, serverPermModerate); err != nil {
+		return nil, err
+	}
+	return s.getAudits(ctx, serverid, limit, offset)
+}
+
+func (s *Service) muteGCHook(ctx context.Context, props sysevent.TimestampProps) error {
+	if err := s.mods.DeleteExpiredMutes(ctx, props.Timestamp); err != nil {
+		return kerrors.WithMsg(err, "Failed to GC expired mutes")
+	}
+	s.log.Info(ctx, "GC expired mutes")
+	return nil
+}
@@ -0,0 +1,438 @@
+package conduit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/conduit/msgmodel"
+	"xorkevin.dev/governor/service/dbsql"
+	"xorkevin.dev/kerrors"
+	"xorkevin.dev/klog"
+)
+
+const (
+	msgEventKindCreate   = "create"
+	msgEventKindEdit     = "edit"
+	msgEventKindReaction = "reaction"
+	msgEventKindRead     = "read"
+	msgEventKindErase    = "erase"
+)
+
+type (
+	resMsg struct {
+		Chatid       string        `json:"chatid"`
+		Msgid        string        `json:"msgid"`
+		Userid       string        `json:"userid"`
+		Timems       int64         `json:"time_ms"`
+		Kind         string        `json:"kind"`
+		Value        string        `json:"value"`
+		Parentid     string        `json:"parentid"`
+		Edittimems   int64         `json:"edit_time_ms"`
+		AuthorKind   string        `json:"author_kind"`
+		AuthorName   string        `json:"author_name,omitempty"`
+		AuthorAvatar string        `json:"author_avatar,omitempty"`
+		Reactions    []resReaction `json:"reactions"`
+	}
+
+	resReaction struct {
+		Reaction string `json:"reaction"`
+		Count    int    `json:"count"`
+		Reacted  bool   `json:"reacted"`
+	}
+
+	resMsgs struct {
+		Msgs []resMsg `json:"msgs"`
+	}
+
+	resMsgReaction struct {
+		Chatid   string `json:"chatid"`
+		Msgid    string `json:"msgid"`
+		Userid   string `json:"userid"`
+		Reaction string `json:"reaction"`
+		Added    bool   `json:"added"`
+	}
+
+	resMsgEdit struct {
+		Timems int64  `json:"time_ms"`
+		Value  string `json:"value"`
+	}
+
+	resMsgEdits struct {
+		Edits []resMsgEdit `json:"edits"`
+	}
+
+	// resMsgEvent is a msg event published to chat members
+	resMsgEvent struct {
+		Kind     string          `json:"kind"`
+		Msg      *resMsg         `json:"msg,omitempty"`
+		Reaction *resMsgReaction `json:"reaction,omitempty"`
+		Read     *resReadCursor  `json:"read,omitempty"`
+		Erase    *resMsgErase    `json:"erase,omitempty"`
+	}
+
+	// resMsgErase lists msgs that have been erased
+	resMsgErase struct {
+		Chatid string   `json:"chatid"`
+		Msgids []string `json:"msgids"`
+	}
+)
+
+func msgToRes(m *msgmodel.Model) resMsg {
+	return resMsg{
+		Chatid:       m.Chatid,
+		Msgid:        m.Msgid,
+		Userid:       m.Userid,
+		Timems:       m.Timems,
+		Kind:         m.Kind,
+		Value:        m.Value,
+		Parentid:     m.Parentid,
+		Edittimems:   m.Edittimems,
+		AuthorKind:   m.AuthorKind,
+		AuthorName:   m.AuthorName,
+		AuthorAvatar: m.AuthorAvatar,
+		Reactions:    []resReaction{},
+	}
+}
+
+func (s *Service) getChatMsg(ctx context.Context, chatid string, msgid string) (*msgmodel.Model, error) {
+	m, err := s.msgs.GetMsg(ctx, chatid, msgid)
+	if err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			return nil, governor.ErrWithRes(err, http.StatusNotFound, "", "Msg not found")
+		}
+		return nil, kerrors.WithMsg(err, "Failed to get msg")
+	}
+	return m, nil
+}
+
+// checkMsgParent checks that a msg may be a reply to the parent msg
+func (s *Service) checkMsgParent(ctx context.Context, chatid string, parentid string) error {
+	if parentid == "" {
+		return nil
+	}
+	parent, err := s.getChatMsg(ctx, chatid, parentid)
+	if err != nil {
+		return err
+	}
+	if parent.Parentid != "" {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "May not reply to a thread reply")
+	}
+	return nil
+}
+
+// createMsg creates a msg in a chat that the user has access to
+func (s *Service) createMsg(ctx context.Context, chatid string, userid string, kind string, value string, parentid string) (*msgmodel.Model, error) {
+	return s.createAuthorMsg(ctx, chatid, userid, msgAuthor{
+		Kind: msgmodel.AuthorKindUser,
+	}, kind, value, parentid)
+}
+
+type (
+	// msgAuthor is the display identity of the sender of a msg
+	msgAuthor struct {
+		Kind   string
+		Name   string
+		Avatar string
+	}
+)
+
+// createAuthorMsg creates a msg with the display identity of its author, which
+// may be a user, bot, or webhook
+func (s *Service) createAuthorMsg(ctx context.Context, chatid string, userid string, author msgAuthor, kind string, value string, parentid string) (*msgmodel.Model, error) {
+	if err := s.checkMsgParent(ctx, chatid, parentid); err != nil {
+		return nil, err
+	}
+	m, err := s.msgs.New(chatid, userid, kind, value, parentid)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to create new msg")
+	}
+	m.AuthorKind = author.Kind
+	m.AuthorName = author.Name
+	m.AuthorAvatar = author.Avatar
+	if err := s.msgs.Insert(ctx, m); err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to send new msg")
+	}
+	return m, nil
+}
+
+// msgsToRes returns msgs along with their reactions from the perspective of
+// the user
+func (s *Service) msgsToRes(ctx context.Context, chatid string, userid string, m []msgmodel.Model) (*resMsgs, error) {
+	res := make([]resMsg, 0, len(m))
+	msgids := make([]string, 0, len(m))
+	msgIndex := make(map[string]int, len(m))
+	for n, i := range m {
+		res = append(res, msgToRes(&i))
+		msgids = append(msgids, i.Msgid)
+		msgIndex[i.Msgid] = n
+	}
+	reactions, err := s.msgs.GetReactions(ctx, chatid, msgids, userid)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get msg reactions")
+	}
+	for _, i := range reactions {
+		n, ok := msgIndex[i.Msgid]
+		if !ok {
+			continue
+		}
+		res[n].Reactions = append(res[n].Reactions, resReaction{
+			Reaction: i.Reaction,
+			Count:    i.Count,
+			Reacted:  i.Reacted,
+		})
+	}
+	return &resMsgs{
+		Msgs: res,
+	}, nil
+}
+
+func (s *Service) getChatMsgs(ctx context.Context, chatid string, userid string, kind string, before string, limit int) (*resMsgs, error) {
+	m, err := s.msgs.GetMsgs(ctx, chatid, kind, before, limit)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get msgs")
+	}
+	return s.msgsToRes(ctx, chatid, userid, m)
+}
+
+func (s *Service) getThreadMsgs(ctx context.Context, chatid string, userid string, parentid string, before string, limit int) (*resMsgs, error) {
+	if _, err := s.getChatMsg(ctx, chatid, parentid); err != nil {
+		return nil, err
+	}
+	m, err := s.msgs.GetThreadMsgs(ctx, chatid, parentid, before, limit)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get thread msgs")
+	}
+	return s.msgsToRes(ctx, chatid, userid, m)
+}
+
+func (s *Service) editMsg(ctx context.Context, chatid string, userid string, msgid string, value string) (*resMsg, error) {
+	m, err := s.getChatMsg(ctx, chatid, msgid)
+	if err != nil {
+		return nil, err
+	}
+	if m.Userid != userid {
+		return nil, governor.ErrWithRes(nil, http.StatusForbidden, "", "May only edit own msgs")
+	}
+	if m.Kind != chatMsgKindTxt {
+		return nil, governor.ErrWithRes(nil, http.StatusBadRequest, "", "Msg may not be edited")
+	}
+	if m.Value == "" {
+		return nil, governor.ErrWithRes(nil, http.StatusBadRequest, "", "Msg has been deleted")
+	}
+	if m.Value == value {
+		res := msgToRes(m)
+		return &res, nil
+	}
+	if err := s.msgs.EditMsg(ctx, m, value); err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to edit msg")
+	}
+	res := msgToRes(m)
+	// must make a best effort to publish msg index event
+	s.publishMsgIndexEvent(klog.ExtendCtx(context.Background(), ctx), m.Chatid, m.Msgid)
+	return &res, nil
+}
+
+func (s *Service) getMsgEdits(ctx context.Context, chatid string, msgid string, limit, offset int) (*resMsgEdits, error) {
+	if _, err := s.getChatMsg(ctx, chatid, msgid); err != nil {
+		return nil, err
+	}
+	m, err := s.msgs.GetMsgEdits(ctx, chatid, msgid, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get msg edits")
+	}
+	res := make([]resMsgEdit, 0, len(m))
+	for _, i := range m {
+		res = append(res, resMsgEdit{
+			Timems: i.Timems,
+			Value:  i.Value,
+		})
+	}
+	return &resMsgEdits{
+		Edits: res,
+	}, nil
+}
+
+func (s *Service) reactMsg(ctx context.Context, chatid string, userid string, msgid string, reaction string) (*resMsgReaction, error) {
+	m, err := s.getChatMsg(ctx, chatid, msgid)
+	if err != nil {
+		return nil, err
+	}
+	if m.Value == "" {
+		return nil, governor.ErrWithRes(nil, http.StatusBadRequest, "", "Msg has been deleted")
+	}
+	added, err := s.msgs.ToggleReaction(ctx, chatid, msgid, reaction, userid)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to toggle msg reaction")
+	}
+	return &resMsgReaction{
+		Chatid:   chatid,
+		Msgid:    msgid,
+		Userid:   userid,
+		Reaction: reaction,
+		Added:    added,
+	}, nil
+}
+
+func (s *Service) editDMMsg(ctx context.Context, userid string, chatid string, msgid string, value string) (*resMsg, error) {
+	dm, err := s.getDMByChatid(ctx, userid, chatid)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkMuted(ctx, "", userid); err != nil {
+		return nil, err
+	}
+	res, err := s.editMsg(ctx, chatid, userid, msgid, value)
+	if err != nil {
+		return nil, err
+	}
+	// must make a best effort attempt to publish dm msg event
+	ctx = klog.ExtendCtx(context.Background(), ctx)
+	s.publishDMMsgEvent(ctx, []string{dm.Userid1, dm.Userid2}, resMsgEvent{
+		Kind: msgEventKindEdit,
+		Msg:  res,
+	})
+	return res, nil
+}
+
+func (s *Service) getDMMsgEdits(ctx context.Context, userid string, chatid string, msgid string, limit, offset int) (*resMsgEdits, error) {
+	if _, err := s.getDMByChatid(ctx, userid, chatid); err != nil {
+		return nil, err
+	}
+	return s.getMsgEdits(ctx, chatid, msgid, limit, offset)
+}
+
+func (s *Service) reactDMMsg(ctx context.Context, userid string, chatid string, msgid string, reaction string) (*resMsgReaction, error) {
+	dm, err := s.getDMByChatid(ctx, userid, chatid)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkMuted(ctx, "", userid); err != nil {
+		return nil, err
+	}
+	res, err := s.reactMsg(ctx, chatid, userid, msgid, reaction)
+	if err != nil {
+		return nil, err
+	}
+	// must make a best effort attempt to publish dm msg event
+	ctx = klog.ExtendCtx(context.Background(), ctx)
+	s.publishDMMsgEvent(ctx, []string{dm.Userid1, dm.Userid2}, resMsgEvent{
+		Kind:     msgEventKindReaction,
+		Reaction: res,
+	})
+	return res, nil
+}
+
+func (s *Service) getDMThreadMsgs(ctx context.Context, userid string, chatid string, parentid string, before string, limit int) (*resMsgs, error) {
+	if _, err := s.getDMByChatid(ctx, userid, chatid); err != nil {
+		return nil, err
+	}
+	return s.getThreadMsgs(ctx, chatid, userid, parentid, before, limit)
+}
+
+func (s *Service) editGDMMsg(ctx context.Context, userid string, chatid string, msgid string, value string) (*resMsg, error) {
+	if _, err := s.getGDMByChatid(ctx, userid, chatid); err != nil {
+		return nil, err
+	}
+	if err := s.checkMuted(ctx, "", userid); err != nil {
+		return nil, err
+	}
+	res, err := s.editMsg(ctx, chatid, userid, msgid, value)
+	if err != nil {
+		return nil, err
+	}
+	// must make a best effort to publish gdm msg event
+	ctx = klog.ExtendCtx(context.Background(), ctx)
+	s.publishGDMMsgEvent(ctx, chatid, resMsgEvent{
+		Kind: msgEventKindEdit,
+		Msg:  res,
+	})
+	return res, nil
+}
+
+func (s *Service) getGDMMsgEdits(ctx context.Context, userid string, chatid string, msgid string, limit, offset int) (*resMsgEdits, error) {
+	if _, err := s.getGDMByChatid(ctx, userid, chatid); err != nil {
+		return nil, err
+	}
+	return s.getMsgEdits(ctx, chatid, msgid, limit, offset)
+}
+
+func (s *Service) reactGDMMsg(ctx context.Context, userid string, chatid string, msgid string, reaction string) (*resMsgReaction, error) {
+	if _, err := s.getGDMByChatid(ctx, userid, chatid); err != nil {
+		return nil, err
+	}
+	if err := s.checkMuted(ctx, "", userid); err != nil {
+		return nil, err
+	}
+	res, err := s.reactMsg(ctx, chatid, userid, msgid, reaction)
+	if err != nil {
+		return nil, err
+	}
+	// must make a best effort to publish gdm msg event
+	ctx = klog.ExtendCtx(context.Background(), ctx)
+	s.publishGDMMsgEvent(ctx, chatid, resMsgEvent{
+		Kind:     msgEventKindReaction,
+		Reaction: res,
+	})
+	return res, nil
+}
+
+func (s *Service) getGDMThreadMsgs(ctx context.Context, userid string, chatid string, parentid string, before string, limit int) (*resMsgs, error) {
+	if _, err := s.getGDMByChatid(ctx, userid, chatid); err != nil {
+		return nil, err
+	}
+	return s.getThreadMsgs(ctx, chatid, userid, parentid, before, limit)
+}
+
+func (s *Service) editChannelMsg(ctx context.Context, serverid, channelid string, userid string, msgid string, value string) (*resMsg, error) {
+	ch, err := s.getServerChannelWriter(ctx, serverid, channelid, userid)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.editMsg(ctx, ch.Chatid, userid, msgid, value)
+	if err != nil {
+		return nil, err
+	}
+	// must make a best effort to publish channel msg event
+	ctx = klog.ExtendCtx(context.Background(), ctx)
+	s.publishChannelMsgEvent(ctx, serverid, channelid, resMsgEvent{
+		Kind: msgEventKindEdit,
+		Msg:  res,
+	})
+	return res, nil
+}
+
+func (s *Service) getChannelMsgEdits(ctx context.Context, serverid, channelid string, msgid string, limit, offset int) (*resMsgEdits, error) {
+	ch, err := s.getServerChannel(ctx, serverid, channelid)
+	if err != nil {
+		return nil, err
+	}
+	return s.getMsgEdits(ctx, ch.Chatid, msgid, limit, offset)
+}
+
+func (s *Service) reactChannelMsg(ctx context.Context, serverid, channelid string, userid string, msgid string, reaction string) (*resMsgReaction, error) {
+	ch, err := s.getServerChannelWriter(ctx, serverid, channelid, userid)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.reactMsg(ctx, ch.Chatid, userid, msgid, reaction)
+	if err != nil {
+		return nil, err
+	}
+	// must make a best effort to publish channel msg event
+	ctx = klog.ExtendCtx(context.Background(), ctx)
+	s.publishChannelMsgEvent(ctx, serverid, channelid, resMsgEvent{
+		Kind:     msgEventKindReaction,
+		Reaction: res,
+	})
+	return res, nil
+}
+
+func (s *Service) getChannelThreadMsgs(ctx context.Context, serverid, channelid string, userid string, parentid string, before string, limit int) (*resMsgs, error) {
+	ch, err := s.getServerChannel(ctx, serverid, channelid)
+	if err != nil {
+		return nil, err
+	}
+	return s.getThreadMsgs(ctx, ch.Chatid, userid, parentid, before, limit)
+}
@@ -0,0 +1,90 @@
+package conduit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/conduit/msgmodel"
+	"xorkevin.dev/governor/service/dbsql"
+	"xorkevin.dev/kerrors"
+)
+
+type (
+	testMsgs struct {
+		msgmodel.Repo
+		msgs map[string]*msgmodel.Model
+	}
+)
+
+func (r *testMsgs) GetMsg(ctx context.Context, chatid string, msgid string) (*msgmodel.Model, error) {
+	m, ok := r.msgs[chatid+"."+msgid]
+	if !ok {
+		return nil, kerrors.WithKind(nil, dbsql.ErrNotFound, "Msg not found")
+	}
+	return m, nil
+}
+
+func TestCheckMsgParent(t *testing.T) {
+	t.Parallel()
+
+	s := &Service{
+		msgs: &testMsgs{
+			msgs: map[string]*msgmodel.Model{
+				"chat.root": {
+					Chatid: "chat",
+					Msgid:  "root",
+				},
+				"chat.reply": {
+					Chatid:   "chat",
+					Msgid:    "reply",
+					Parentid: "root",
+				},
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		Test     string
+		Parentid string
+		Status   int
+	}{
+		{
+			Test:     "not a reply",
+			Parentid: "",
+		},
+		{
+			Test:     "reply to root msg",
+			Parentid: "root",
+		},
+		{
+			Test:     "reply to thread reply",
+			Parentid: "reply",
+			Status:   http.StatusBadRequest,
+		},
+		{
+			Test:     "reply to missing msg",
+			Parentid: "missing",
+			Status:   http.StatusNotFound,
+		},
+	} {
+		t.Run(tc.Test, func(t *testing.T) {
+			t.Parallel()
+
+			assert := require.New(t)
+
+			err := s.checkMsgParent(context.Background(), "chat", tc.Parentid)
+			if tc.Status == 0 {
+				assert.NoError(err)
+				return
+			}
+			assert.Error(err)
+			var errres *governor.ErrorRes
+			assert.True(errors.As(err, &errres))
+			assert.Equal(tc.Status, errres.Status)
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 	"time"
 
+	"xorkevin.dev/governor/service/events/sysevent"
 	"xorkevin.dev/governor/service/kvstore"
 	"xorkevin.dev/governor/service/ws"
 	"xorkevin.dev/governor/util/kjson"
@@ -13,19 +14,72 @@ import (
 )
 
 const (
-	locDM  = "dm"
-	locGDM = "gdm"
+	locDM      = "dm"
+	locGDM     = "gdm"
+	locServer  = "server"
+	locChannel = "channel"
 )
 
+const (
+	presenceDuration = time.Minute
+	// channelPresenceScanCap is the max number of online server members scanned
+	// for the online members of a channel
+	channelPresenceScanCap   = 1024
+	channelPresenceBatchSize = 256
+)
+
+// parseServerLoc parses a server location of the form server.<id> or
+// server.<id>.channel.<cid>
+func parseServerLoc(subloc string) (string, string, bool) {
+	parts := strings.Split(subloc, ".")
+	switch len(parts) {
+	case 2:
+		if parts[0] != locServer {
+			return "", "", false
+		}
+		if err := validhasServerID(parts[1]); err != nil {
+			return "", "", false
+		}
+		return parts[1], "", true
+	case 4:
+		if parts[0] != locServer || parts[2] != locChannel {
+			return "", "", false
+		}
+		if err := validhasServerID(parts[1]); err != nil {
+			return "", "", false
+		}
+		if err := validhasChannelID(parts[3]); err != nil {
+			return "", "", false
+		}
+		return parts[1], parts[3], true
+	default:
+		return "", "", false
+	}
+}
+
+func serverChannelLoc(serverid, channelid string) string {
+	return locServer + "." + serverid + "." + locChannel + "." + channelid
+}
+
 func (s *Service) presenceHandler(ctx context.Context, props ws.PresenceEventProps) error {
 	subloc := strings.TrimPrefix(props.Location, s.channelns+".")
 	switch subloc {
-	// TODO handle server and channel presence
 	case locDM, locGDM:
 	default:
-		return nil
+		serverid, _, ok := parseServerLoc(subloc)
+		if !ok {
+			return nil
+		}
+		if ok, err := s.checkServerMember(ctx, serverid, props.Userid); err != nil {
+			return err
+		} else if !ok {
+			return nil
+		}
+		if err := s.servers.UpdatePresence(ctx, serverid, props.Userid, props.Timestamp); err != nil {
+			return kerrors.WithMsg(err, "Failed to update server presence")
+		}
 	}
-	if err := s.kvpresence.Set(ctx, props.Userid, subloc, time.Minute); err != nil {
+	if err := s.kvpresence.Set(ctx, props.Userid, subloc, presenceDuration); err != nil {
 		return kerrors.WithMsg(err, "Failed to set presence")
 	}
 	return nil
@@ -113,3 +167,126 @@ func (s *Service) presenceQueryHandler(ctx context.Context, topic string, userid
 	}
 	return nil
 }
+
+type (
+	resServerPresence struct {
+		ServerID  string   `json:"serverid"`
+		ChannelID string   `json:"channelid"`
+		Userids   []string `json:"userids"`
+	}
+)
+
+// getServerPresence returns the online members of a server, or of a server
+// channel if channelid is provided, excluding users blocked by the requesting
+// user
+func (s *Service) getServerPresence(ctx context.Context, serverid, channelid string, userid string, limit, offset int) (*resServerPresence, error) {
+	var userids []string
+	if channelid != "" {
+		var err error
+		userids, err = s.getChannelPresence(ctx, serverid, channelid, userid, limit, offset)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		after := time.Now().Round(0).Add(-presenceDuration).Unix()
+		m, err := s.servers.GetPresence(ctx, serverid, after, limit, offset)
+		if err != nil {
+			return nil, kerrors.WithMsg(err, "Failed to get server presence")
+		}
+		userids = make([]string, 0, len(m))
+		for _, i := range m {
+			userids = append(userids, i.Userid)
+		}
+		userids, err = s.filterBlocked(ctx, userid, userids)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if userids == nil {
+		userids = []string{}
+	}
+	return &resServerPresence{
+		ServerID:  serverid,
+		ChannelID: channelid,
+		Userids:   userids,
+	}, nil
+}
+
+// getChannelPresence returns a page of the online members of a server channel
+//
+// Channel presence is a subset of server presence, so server presence is
+// filtered by channel presence before it is paginated.
+func (s *Service) getChannelPresence(ctx context.Context, serverid, channelid string, userid string, limit, offset int) ([]string, error) {
+	if _, err := s.getServerChannel(ctx, serverid, channelid); err != nil {
+		return nil, err
+	}
+	loc := serverChannelLoc(serverid, channelid)
+	after := time.Now().Round(0).Add(-presenceDuration).Unix()
+	var res []string
+	for scanned := 0; scanned < channelPresenceScanCap && len(res) < offset+limit; scanned += channelPresenceBatchSize {
+		m, err := s.servers.GetPresence(ctx, serverid, after, channelPresenceBatchSize, scanned)
+		if err != nil {
+			return nil, kerrors.WithMsg(err, "Failed to get server presence")
+		}
+		userids := make([]string, 0, len(m))
+		for _, i := range m {
+			userids = append(userids, i.Userid)
+		}
+		userids, err = s.getPresence(ctx, loc, userids)
+		if err != nil {
+			return nil, kerrors.WithMsg(err, "Failed to get channel presence")
+		}
+		userids, err = s.filterBlocked(ctx, userid, userids)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, userids...)
+		if len(m) < channelPresenceBatchSize {
+			break
+		}
+	}
+	if offset >= len(res) {
+		return nil, nil
+	}
+	res = res[offset:]
+	if len(res) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+func (s *Service) serverPresenceQueryHandler(ctx context.Context, topic string, userid string, msgdata []byte) error {
+	var req reqGetServerPresence
+	if err := kjson.Unmarshal(msgdata, &req); err != nil {
+		s.log.WarnErr(ctx, kerrors.WithMsg(err, "Invalid get server presence request"))
+		return nil
+	}
+	req.Userid = userid
+	if err := req.valid(); err != nil {
+		s.log.WarnErr(ctx, kerrors.WithMsg(err, "Invalid get server presence request"))
+		return nil
+	}
+	if ok, err := s.checkServerMember(ctx, req.ServerID, req.Userid); err != nil {
+		return err
+	} else if !ok {
+		s.log.WarnErr(ctx, kerrors.WithMsg(nil, "Get server presence request by non-member"))
+		return nil
+	}
+
+	res, err := s.getServerPresence(ctx, req.ServerID, req.ChannelID, req.Userid, req.Amount, req.Offset)
+	if err != nil {
+		return err
+	}
+	if err := s.ws.Publish(ctx, req.Userid, s.opts.ServerPresenceQueryChannel, res); err != nil {
+		return kerrors.WithMsg(err, "Failed to publish server presence res event")
+	}
+	return nil
+}
+
+func (s *Service) serverPresenceGCHook(ctx context.Context, props sysevent.TimestampProps) error {
+	if err := s.servers.DeleteStalePresence(ctx, time.Unix(props.Timestamp, 0).Add(-presenceDuration).Unix()); err != nil {
+		return kerrors.WithMsg(err, "Failed to GC server presence")
+	}
+	s.log.Info(ctx, "GC server presence")
+	return nil
+}
@@ -0,0 +1,83 @@
+package conduit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseServerLoc(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		Test      string
+		Loc       string
+		ServerID  string
+		ChannelID string
+		Ok        bool
+	}{
+		{
+			Test:     "server",
+			Loc:      "server.myserver",
+			ServerID: "myserver",
+			Ok:       true,
+		},
+		{
+			Test:      "server channel",
+			Loc:       "server.myserver.channel.general",
+			ServerID:  "myserver",
+			ChannelID: "general",
+			Ok:        true,
+		},
+		{
+			Test:      "round trips channel loc",
+			Loc:       serverChannelLoc("myserver", "general"),
+			ServerID:  "myserver",
+			ChannelID: "general",
+			Ok:        true,
+		},
+		{
+			Test: "empty",
+			Loc:  "",
+		},
+		{
+			Test: "other kind",
+			Loc:  "gdm.myserver",
+		},
+		{
+			Test: "empty server id",
+			Loc:  "server.",
+		},
+		{
+			Test: "long server id",
+			Loc:  "server.abcdefghijklmnopqrstuvwxyz0123456",
+		},
+		{
+			Test: "other channel kind",
+			Loc:  "server.myserver.thread.general",
+		},
+		{
+			Test: "empty channel id",
+			Loc:  "server.myserver.channel.",
+		},
+		{
+			Test: "missing channel id",
+			Loc:  "server.myserver.channel",
+		},
+		{
+			Test: "trailing parts",
+			Loc:  "server.myserver.channel.general.extra",
+		},
+	} {
+		t.Run(tc.Test, func(t *testing.T) {
+			t.Parallel()
+
+			assert := require.New(t)
+
+			serverid, channelid, ok := parseServerLoc(tc.Loc)
+			assert.Equal(tc.Ok, ok)
+			assert.Equal(tc.ServerID, serverid)
+			assert.Equal(tc.ChannelID, channelid)
+		})
+	}
+}
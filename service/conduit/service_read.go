@@ -0,0 +1,165 @@
+package conduit
+
+import (
+	"context"
+
+	"xorkevin.dev/governor/service/conduit/msgmodel"
+	"xorkevin.dev/kerrors"
+	"xorkevin.dev/klog"
+)
+
+const (
+	// unreadCountCap is the max unread count reported for a chat
+	unreadCountCap = 100
+)
+
+type (
+	resReadCursor struct {
+		Chatid string `json:"chatid"`
+		Userid string `json:"userid"`
+		Msgid  string `json:"msgid"`
+		Timems int64  `json:"time_ms"`
+	}
+
+	resReadCursors struct {
+		Cursors []resReadCursor `json:"cursors"`
+	}
+)
+
+// getUnread returns the read cursors and unread counts of a user keyed by
+// chatid
+func (s *Service) getUnread(ctx context.Context, userid string, chatids []string) (map[string]msgmodel.UnreadCount, error) {
+	m, err := s.msgs.GetUnreadCounts(ctx, userid, chatids, unreadCountCap)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get unread counts")
+	}
+	res := make(map[string]msgmodel.UnreadCount, len(m))
+	for _, i := range m {
+		res[i.Chatid] = i
+	}
+	return res, nil
+}
+
+// readChatMsg advances the read cursor of a user in a chat that the user has
+// access to, and reports whether the cursor was advanced
+func (s *Service) readChatMsg(ctx context.Context, chatid string, userid string, msgid string) (*resReadCursor, bool, error) {
+	if _, err := s.getChatMsg(ctx, chatid, msgid); err != nil {
+		return nil, false, err
+	}
+	m, ok, err := s.msgs.UpdateReadCursor(ctx, chatid, userid, msgid)
+	if err != nil {
+		return nil, false, kerrors.WithMsg(err, "Failed to update read cursor")
+	}
+	if !ok {
+		return nil, false, nil
+	}
+	return &resReadCursor{
+		Chatid: m.Chatid,
+		Userid: m.Userid,
+		Msgid:  m.Msgid,
+		Timems: m.Timems,
+	}, true, nil
+}
+
+func (s *Service) getChatReadCursors(ctx context.Context, chatid string, limit, offset int) (*resReadCursors, error) {
+	m, err := s.msgs.GetReadCursors(ctx, chatid, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get read cursors")
+	}
+	res := make([]resReadCursor, 0, len(m))
+	for _, i := range m {
+		res = append(res, resReadCursor{
+			Chatid: i.Chatid,
+			Userid: i.Userid,
+			Msgid:  i.Msgid,
+			Timems: i.Timems,
+		})
+	}
+	return &resReadCursors{
+		Cursors: res,
+	}, nil
+}
+
+func (s *Service) readDMMsg(ctx context.Context, userid string, chatid string, msgid string) error {
+	dm, err := s.getDMByChatid(ctx, userid, chatid)
+	if err != nil {
+		return err
+	}
+	res, ok, err := s.readChatMsg(ctx, chatid, userid, msgid)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	// must make a best effort attempt to publish dm msg event
+	ctx = klog.ExtendCtx(context.Background(), ctx)
+	s.publishDMMsgEvent(ctx, []string{dm.Userid1, dm.Userid2}, resMsgEvent{
+		Kind: msgEventKindRead,
+		Read: res,
+	})
+	return nil
+}
+
+func (s *Service) getDMReadCursors(ctx context.Context, userid string, chatid string, limit, offset int) (*resReadCursors, error) {
+	if _, err := s.getDMByChatid(ctx, userid, chatid); err != nil {
+		return nil, err
+	}
+	return s.getChatReadCursors(ctx, chatid, limit, offset)
+}
+
+func (s *Service) readGDMMsg(ctx context.Context, userid string, chatid string, msgid string) error {
+	if _, err := s.getGDMByChatid(ctx, userid, chatid); err != nil {
+		return err
+	}
+	res, ok, err := s.readChatMsg(ctx, chatid, userid, msgid)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	// must make a best effort to publish gdm msg event
+	ctx = klog.ExtendCtx(context.Background(), ctx)
+	s.publishGDMMsgEvent(ctx, chatid, resMsgEvent{
+		Kind: msgEventKindRead,
+		Read: res,
+	})
+	return nil
+}
+
+func (s *Service) getGDMReadCursors(ctx context.Context, userid string, chatid string, limit, offset int) (*resReadCursors, error) {
+	if _, err := s.getGDMByChatid(ctx, userid, chatid); err != nil {
+		return nil, err
+	}
+	return s.getChatReadCursors(ctx, chatid, limit, offset)
+}
+
+func (s *Service) readChannelMsg(ctx context.Context, serverid, channelid string, userid string, msgid string) error {
+	ch, err := s.getServerChannel(ctx, serverid, channelid)
+	if err != nil {
+		return err
+	}
+	res, ok, err := s.readChatMsg(ctx, ch.Chatid, userid, msgid)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	// must make a best effort attempt to publish channel msg event
+	ctx = klog.ExtendCtx(context.Background(), ctx)
+	s.publishChannelMsgEvent(ctx, serverid, channelid, resMsgEvent{
+		Kind: msgEventKindRead,
+		Read: res,
+	})
+	return nil
+}
+
+func (s *Service) getChannelReadCursors(ctx context.Context, serverid, channelid string, limit, offset int) (*resReadCursors, error) {
+	ch, err := s.getServerChannel(ctx, serverid, channelid)
+	if err != nil {
+		return nil, err
+	}
+	return s.getChatReadCursors(ctx, ch.Chatid, limit, offset)
+}
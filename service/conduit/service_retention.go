@@ -0,0 +1,233 @@
+package conduit
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"xorkevin.dev/governor/service/dbsql"
+	"xorkevin.dev/governor/service/events/sysevent"
+	"xorkevin.dev/kerrors"
+	"xorkevin.dev/klog"
+)
+
+type (
+	retentionConfig struct {
+		defaultDuration time.Duration
+		batchSize       int
+	}
+
+	resRetention struct {
+		Chatid   string `json:"chatid"`
+		Duration int64  `json:"duration"`
+		Default  bool   `json:"default"`
+	}
+)
+
+// getRetention returns the retention policy of a chat in seconds, where 0
+// means msgs are kept forever
+func (s *Service) getRetention(ctx context.Context, chatid string) (*resRetention, error) {
+	m, err := s.msgs.GetRetention(ctx, chatid)
+	if err != nil {
+		if !errors.Is(err, dbsql.ErrNotFound) {
+			return nil, kerrors.WithMsg(err, "Failed to get chat retention policy")
+		}
+		return &resRetention{
+			Chatid:   chatid,
+			Duration: int64(s.retention.defaultDuration / time.Second),
+			Default:  true,
+		}, nil
+	}
+	return &resRetention{
+		Chatid:   m.Chatid,
+		Duration: m.Duration,
+		Default:  false,
+	}, nil
+}
+
+// setRetention sets the retention policy of a chat, or reverts it to the
+// default policy
+func (s *Service) setRetention(ctx context.Context, chatid string, duration int64, useDefault bool) error {
+	if useDefault {
+		if err := s.msgs.DeleteRetention(ctx, chatid); err != nil {
+			return kerrors.WithMsg(err, "Failed to reset chat retention policy")
+		}
+		return nil
+	}
+	if err := s.msgs.SetRetention(ctx, chatid, duration); err != nil {
+		return kerrors.WithMsg(err, "Failed to set chat retention policy")
+	}
+	return nil
+}
+
+func (s *Service) getGDMRetention(ctx context.Context, userid string, chatid string) (*resRetention, error) {
+	if _, err := s.getGDMByChatid(ctx, userid, chatid); err != nil {
+		return nil, err
+	}
+	return s.getRetention(ctx, chatid)
+}
+
+func (s *Service) updateGDMRetention(ctx context.Context, userid string, chatid string, duration int64, useDefault bool) error {
+	if _, err := s.getGDMByChatid(ctx, userid, chatid); err != nil {
+		return err
+	}
+	return s.setRetention(ctx, chatid, duration, useDefault)
+}
+
+func (s *Service) getChannelRetention(ctx context.Context, serverid, channelid string) (*resRetention, error) {
+	ch, err := s.getServerChannel(ctx, serverid, channelid)
+	if err != nil {
+		return nil, err
+	}
+	return s.getRetention(ctx, ch.Chatid)
+}
+
+func (s *Service) updateChannelRetention(ctx context.Context, serverid, channelid string, userid string, duration int64, useDefault bool) error {
+	if err := s.requireServerPerm(ctx, serverid, userid, serverPermManageChannels); err != nil {
+		return err
+	}
+	ch, err := s.getServerChannel(ctx, serverid, channelid)
+	if err != nil {
+		return err
+	}
+	return s.setRetention(ctx, ch.Chatid, duration, useDefault)
+}
+
+// publishMsgEraseEvent notifies the members of a chat that msgs have been
+// erased
+func (s *Service) publishMsgEraseEvent(ctx context.Context, chatid string, msgids []string) {
+	ev := resMsgEvent{
+		Kind: msgEventKindErase,
+		Erase: &resMsgErase{
+			Chatid: chatid,
+			Msgids: msgids,
+		},
+	}
+	if dm, err := s.dms.GetByChatID(ctx, chatid); err != nil {
+		if !errors.Is(err, dbsql.ErrNotFound) {
+			s.log.Err(ctx, kerrors.WithMsg(err, "Failed to get dm"))
+			return
+		}
+	} else {
+		s.publishDMMsgEvent(ctx, []string{dm.Userid1, dm.Userid2}, ev)
+		return
+	}
+	if _, err := s.gdms.GetByID(ctx, chatid); err != nil {
+		if !errors.Is(err, dbsql.ErrNotFound) {
+			s.log.Err(ctx, kerrors.WithMsg(err, "Failed to get group chat"))
+			return
+		}
+	} else {
+		s.publishGDMMsgEvent(ctx, chatid, ev)
+		return
+	}
+	ch, err := s.servers.GetChannelByChatid(ctx, chatid)
+	if err != nil {
+		if !errors.Is(err, dbsql.ErrNotFound) {
+			s.log.Err(ctx, kerrors.WithMsg(err, "Failed to get channel"))
+		}
+		return
+	}
+	s.publishChannelMsgEvent(ctx, ch.ServerID, ch.ChannelID, ev)
+}
+
+// expireMsgs erases msgs of a chat and notifies its members
+func (s *Service) expireMsgs(ctx context.Context, chatid string, msgids []string) error {
+	if err := s.eraseMsgs(ctx, chatid, msgids); err != nil {
+		return err
+	}
+	// must make a best effort to publish msg erase event
+	s.publishMsgEraseEvent(klog.ExtendCtx(context.Background(), ctx), chatid, msgids)
+	return nil
+}
+
+// expireChatMsgs erases msgs of a chat sent before a time in milliseconds in
+// batches
+func (s *Service) expireChatMsgs(ctx context.Context, chatid string, before int64) (int, error) {
+	count := 0
+	for {
+		msgids, err := s.msgs.GetExpiredMsgs(ctx, chatid, before, s.retention.batchSize)
+		if err != nil {
+			return count, kerrors.WithMsg(err, "Failed to get expired msgs")
+		}
+		if len(msgids) == 0 {
+			return count, nil
+		}
+		if err := s.expireMsgs(ctx, chatid, msgids); err != nil {
+			return count, err
+		}
+		count += len(msgids)
+		if len(msgids) < s.retention.batchSize {
+			return count, nil
+		}
+	}
+}
+
+// expireDefaultMsgs erases msgs of chats without a retention policy sent
+// before a time in milliseconds in batches
+func (s *Service) expireDefaultMsgs(ctx context.Context, before int64) (int, error) {
+	count := 0
+	for {
+		m, err := s.msgs.GetDefaultExpiredMsgs(ctx, before, s.retention.batchSize)
+		if err != nil {
+			return count, kerrors.WithMsg(err, "Failed to get expired msgs")
+		}
+		if len(m) == 0 {
+			return count, nil
+		}
+		var chatids []string
+		chatMsgs := map[string][]string{}
+		for _, i := range m {
+			if _, ok := chatMsgs[i.Chatid]; !ok {
+				chatids = append(chatids, i.Chatid)
+			}
+			chatMsgs[i.Chatid] = append(chatMsgs[i.Chatid], i.Msgid)
+		}
+		for _, i := range chatids {
+			if err := s.expireMsgs(ctx, i, chatMsgs[i]); err != nil {
+				return count, err
+			}
+		}
+		count += len(m)
+		if len(m) < s.retention.batchSize {
+			return count, nil
+		}
+	}
+}
+
+func (s *Service) retentionGCHook(ctx context.Context, props sysevent.TimestampProps) error {
+	now := time.Unix(props.Timestamp, 0)
+	count := 0
+	offset := 0
+	for {
+		m, err := s.msgs.GetRetentions(ctx, s.retention.batchSize, offset)
+		if err != nil {
+			return kerrors.WithMsg(err, "Failed to get chat retention policies")
+		}
+		for _, i := range m {
+			if i.Duration <= 0 {
+				continue
+			}
+			k, err := s.expireChatMsgs(ctx, i.Chatid, now.Add(-time.Duration(i.Duration)*time.Second).UnixMilli())
+			count += k
+			if err != nil {
+				return err
+			}
+		}
+		if len(m) < s.retention.batchSize {
+			break
+		}
+		offset += len(m)
+	}
+	if s.retention.defaultDuration > 0 {
+		k, err := s.expireDefaultMsgs(ctx, now.Add(-s.retention.defaultDuration).UnixMilli())
+		count += k
+		if err != nil {
+			return err
+		}
+	}
+	s.log.Info(ctx, "GC expired chat msgs",
+		klog.AInt("count", count),
+	)
+	return nil
+}
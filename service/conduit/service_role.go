@@ -0,0 +1,278 @@
+package conduit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/authzacl"
+	"xorkevin.dev/governor/service/conduit/servermodel"
+	"xorkevin.dev/governor/service/dbsql"
+	"xorkevin.dev/kerrors"
+)
+
+const (
+	serverRoleCap = 64
+)
+
+func serverRoleMemberRel(roleid, userid string) authzacl.Relation {
+	return authzacl.Rel(aclNSServerRole, roleid, aclRelMember, aclNSUser, userid, "")
+}
+
+func serverRolePermRel(serverid, roleid string, perm string) authzacl.Relation {
+	return authzacl.Rel(aclNSServer, serverid, perm, aclNSServerRole, roleid, aclRelMember)
+}
+
+type (
+	resServerRole struct {
+		ServerID     string   `json:"serverid"`
+		Roleid       string   `json:"roleid"`
+		Name         string   `json:"name"`
+		Permissions  []string `json:"permissions"`
+		CreationTime int64    `json:"creation_time"`
+	}
+
+	resServerRoles struct {
+		Roles []resServerRole `json:"roles"`
+	}
+)
+
+func (s *Service) getServerRole(ctx context.Context, serverid, roleid string) (*servermodel.RoleModel, error) {
+	m, err := s.servers.GetRole(ctx, serverid, roleid)
+	if err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			return nil, governor.ErrWithRes(err, http.StatusNotFound, "", "Role not found")
+		}
+		return nil, kerrors.WithMsg(err, "Failed to get server role")
+	}
+	return m, nil
+}
+
+// getServerRolePerms returns the permissions granted to each role of a server
+func (s *Service) getServerRolePerms(ctx context.Context, serverid string) (map[string][]string, error) {
+	res := map[string][]string{}
+	for _, i := range serverPerms {
+		subs, err := s.acl.Read(ctx, authzacl.ObjRel{
+			NS:   aclNSServer,
+			Key:  serverid,
+			Pred: i,
+		}, nil, serverRoleCap)
+		if err != nil {
+			return nil, kerrors.WithMsg(err, "Failed to get server permission roles")
+		}
+		for _, j := range subs {
+			if j.NS != aclNSServerRole || j.Pred != aclRelMember {
+				continue
+			}
+			res[j.Key] = append(res[j.Key], i)
+		}
+	}
+	return res, nil
+}
+
+func (s *Service) getServerRoles(ctx context.Context, serverid string) (*resServerRoles, error) {
+	m, err := s.servers.GetRoles(ctx, serverid, serverRoleCap, 0)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get server roles")
+	}
+	perms, err := s.getServerRolePerms(ctx, serverid)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]resServerRole, 0, len(m))
+	for _, i := range m {
+		p := perms[i.Roleid]
+		if p == nil {
+			p = []string{}
+		}
+		res = append(res, resServerRole{
+			ServerID:     i.ServerID,
+			Roleid:       i.Roleid,
+			Name:         i.Name,
+			Permissions:  p,
+			CreationTime: i.CreationTime,
+		})
+	}
+	return &resServerRoles{
+		Roles: res,
+	}, nil
+}
+
+// setServerRolePerms replaces the permissions granted to a role
+func (s *Service) setServerRolePerms(ctx context.Context, serverid, roleid string, perms []string) error {
+	rm := make([]authzacl.Relation, 0, len(serverPerms))
+	for _, i := range serverPerms {
+		rm = append(rm, serverRolePermRel(serverid, roleid, i))
+	}
+	if err := s.acl.DeleteRelations(ctx, rm); err != nil {
+		return kerrors.WithMsg(err, "Failed to remove server role permissions")
+	}
+	add := make([]authzacl.Relation, 0, len(perms))
+	for _, i := range perms {
+		add = append(add, serverRolePermRel(serverid, roleid, i))
+	}
+	if err := s.acl.InsertRelations(ctx, add); err != nil {
+		return kerrors.WithMsg(err, "Failed to add server role permissions")
+	}
+	return nil
+}
+
+func (s *Service) createServerRole(ctx context.Context, serverid, userid string, name string, perms []string) (*resServerRole, error) {
+	if err := s.requireServerPerm(ctx, serverid, userid, serverPermManageServer); err != nil {
+		return nil, err
+	}
+	roles, err := s.servers.GetRoles(ctx, serverid, serverRoleCap, 0)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get server roles")
+	}
+	if len(roles) >= serverRoleCap {
+		return nil, governor.ErrWithRes(nil, http.StatusBadRequest, "", "Server has too many roles")
+	}
+	m, err := s.servers.NewRole(serverid, name)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to create server role")
+	}
+	if err := s.servers.InsertRole(ctx, m); err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to create server role")
+	}
+	if err := s.setServerRolePerms(ctx, serverid, m.Roleid, perms); err != nil {
+		return nil, err
+	}
+	return &resServerRole{
+		ServerID:     m.ServerID,
+		Roleid:       m.Roleid,
+		Name:         m.Name,
+		Permissions:  perms,
+		CreationTime: m.CreationTime,
+	}, nil
+}
+
+func (s *Service) updateServerRole(ctx context.Context, serverid, userid string, roleid string, name string, perms []string) error {
+	if err := s.requireServerPerm(ctx, serverid, userid, serverPermManageServer); err != nil {
+		return err
+	}
+	m, err := s.getServerRole(ctx, serverid, roleid)
+	if err != nil {
+		return err
+	}
+	m.Name = name
+	if err := s.servers.UpdateRole(ctx, m); err != nil {
+		return kerrors.WithMsg(err, "Failed to update server role")
+	}
+	if err := s.setServerRolePerms(ctx, serverid, roleid, perms); err != nil {
+		return err
+	}
+	return nil
+}
+
+const (
+	roleMemberBatchSize = 256
+)
+
+func (s *Service) deleteServerRole(ctx context.Context, serverid, userid string, roleid string) error {
+	if err := s.requireServerPerm(ctx, serverid, userid, serverPermManageServer); err != nil {
+		return err
+	}
+	if _, err := s.getServerRole(ctx, serverid, roleid); err != nil {
+		return err
+	}
+	if err := s.setServerRolePerms(ctx, serverid, roleid, nil); err != nil {
+		return err
+	}
+	for {
+		subs, err := s.acl.Read(ctx, authzacl.ObjRel{
+			NS:   aclNSServerRole,
+			Key:  roleid,
+			Pred: aclRelMember,
+		}, nil, roleMemberBatchSize)
+		if err != nil {
+			return kerrors.WithMsg(err, "Failed to get server role members")
+		}
+		if len(subs) == 0 {
+			break
+		}
+		rels := make([]authzacl.Relation, 0, len(subs))
+		for _, i := range subs {
+			rels = append(rels, authzacl.Rel(aclNSServerRole, roleid, aclRelMember, i.NS, i.Key, i.Pred))
+		}
+		if err := s.acl.DeleteRelations(ctx, rels); err != nil {
+			return kerrors.WithMsg(err, "Failed to remove server role members")
+		}
+		if len(subs) < roleMemberBatchSize {
+			break
+		}
+	}
+	if err := s.servers.DeleteRole(ctx, serverid, roleid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete server role")
+	}
+	return nil
+}
+
+type (
+	resServerRoleMembers struct {
+		Userids []string `json:"userids"`
+	}
+)
+
+func (s *Service) getServerRoleMembers(ctx context.Context, serverid string, roleid string, after string, limit int) (*resServerRoleMembers, error) {
+	if _, err := s.getServerRole(ctx, serverid, roleid); err != nil {
+		return nil, err
+	}
+	var cursor *authzacl.Sub
+	if after != "" {
+		cursor = &authzacl.Sub{
+			NS:  aclNSUser,
+			Key: after,
+		}
+	}
+	subs, err := s.acl.Read(ctx, authzacl.ObjRel{
+		NS:   aclNSServerRole,
+		Key:  roleid,
+		Pred: aclRelMember,
+	}, cursor, limit)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get server role members")
+	}
+	res := make([]string, 0, len(subs))
+	for _, i := range subs {
+		if i.NS != aclNSUser {
+			continue
+		}
+		res = append(res, i.Key)
+	}
+	return &resServerRoleMembers{
+		Userids: res,
+	}, nil
+}
+
+func (s *Service) addServerRoleMember(ctx context.Context, serverid, userid string, roleid string, target string) error {
+	if err := s.requireServerPerm(ctx, serverid, userid, serverPermManageServer); err != nil {
+		return err
+	}
+	if _, err := s.getServerRole(ctx, serverid, roleid); err != nil {
+		return err
+	}
+	if ok, err := s.checkServerMember(ctx, serverid, target); err != nil {
+		return err
+	} else if !ok {
+		return governor.ErrWithRes(nil, http.StatusNotFound, "", "Member not found")
+	}
+	if err := s.acl.InsertRelations(ctx, []authzacl.Relation{serverRoleMemberRel(roleid, target)}); err != nil {
+		return kerrors.WithMsg(err, "Failed to add server role member")
+	}
+	return nil
+}
+
+func (s *Service) rmServerRoleMember(ctx context.Context, serverid, userid string, roleid string, target string) error {
+	if err := s.requireServerPerm(ctx, serverid, userid, serverPermManageServer); err != nil {
+		return err
+	}
+	if _, err := s.getServerRole(ctx, serverid, roleid); err != nil {
+		return err
+	}
+	if err := s.acl.DeleteRelations(ctx, []authzacl.Relation{serverRoleMemberRel(roleid, target)}); err != nil {
+		return kerrors.WithMsg(err, "Failed to remove server role member")
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package conduit
+
+import (
+	"context"
+	"errors"
+
+	"xorkevin.dev/governor/service/conduit/msgmodel"
+	"xorkevin.dev/governor/service/dbsql"
+	"xorkevin.dev/governor/service/events"
+	"xorkevin.dev/kerrors"
+)
+
+func (s *Service) publishMsgIndexEvent(ctx context.Context, chatid string, msgid string) {
+	b, err := encodeConduitEventMsgIndex(msgIndexProps{
+		Chatid: chatid,
+		Msgid:  msgid,
+	})
+	if err != nil {
+		s.log.Err(ctx, kerrors.WithMsg(err, "Failed to encode msg index event"))
+		return
+	}
+	if err := s.events.Publish(ctx, events.NewMsgs(s.streamconduit, chatid, b)...); err != nil {
+		s.log.Err(ctx, kerrors.WithMsg(err, "Failed to publish msg index event"))
+	}
+}
+
+func (s *Service) msgIndexEventHandler(ctx context.Context, props msgIndexProps) error {
+	m, err := s.msgs.GetMsg(ctx, props.Chatid, props.Msgid)
+	if err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			// chat has been deleted
+			return nil
+		}
+		return kerrors.WithMsg(err, "Failed to get chat msg")
+	}
+	if m.Kind != chatMsgKindTxt || m.Value == "" {
+		// only text msgs that have not been erased are searchable
+		return nil
+	}
+	if err := s.msgs.InsertSearch(ctx, m); err != nil {
+		return kerrors.WithMsg(err, "Failed to index chat msg")
+	}
+	return nil
+}
+
+func searchResToMsgs(m []msgmodel.SearchModel) *resMsgs {
+	res := make([]resMsg, 0, len(m))
+	for _, i := range m {
+		res = append(res, resMsg{
+			Chatid:    i.Chatid,
+			Msgid:     i.Msgid,
+			Userid:    i.Userid,
+			Timems:    i.Timems,
+			Kind:      chatMsgKindTxt,
+			Value:     i.Value,
+			Reactions: []resReaction{},
+		})
+	}
+	return &resMsgs{
+		Msgs: res,
+	}
+}
+
+func (s *Service) searchDMMsgs(ctx context.Context, userid string, chatid string, query string, limit, offset int) (*resMsgs, error) {
+	if _, err := s.getDMByChatid(ctx, userid, chatid); err != nil {
+		return nil, err
+	}
+	m, err := s.msgs.SearchMsgs(ctx, chatid, query, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to search dm msgs")
+	}
+	return searchResToMsgs(m), nil
+}
+
+func (s *Service) searchGDMMsgs(ctx context.Context, userid string, chatid string, query string, limit, offset int) (*resMsgs, error) {
+	if _, err := s.getGDMByChatid(ctx, userid, chatid); err != nil {
+		return nil, err
+	}
+	m, err := s.msgs.SearchMsgs(ctx, chatid, query, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to search group chat msgs")
+	}
+	return searchResToMsgs(m), nil
+}
+
+func (s *Service) searchChannelMsgs(ctx context.Context, serverid, channelid string, query string, limit, offset int) (*resMsgs, error) {
+	ch, err := s.getServerChannel(ctx, serverid, channelid)
+	if err != nil {
+		return nil, err
+	}
+	m, err := s.msgs.SearchMsgs(ctx, ch.Chatid, query, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to search server chat msgs")
+	}
+	return searchResToMsgs(m), nil
+}
@@ -6,9 +6,12 @@ import (
 	"net/http"
 
 	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/authzacl"
+	"xorkevin.dev/governor/service/conduit/msgmodel"
 	"xorkevin.dev/governor/service/conduit/servermodel"
 	"xorkevin.dev/governor/service/dbsql"
 	"xorkevin.dev/kerrors"
+	"xorkevin.dev/klog"
 )
 
 type (
@@ -21,7 +24,30 @@ type (
 	}
 )
 
-func (s *Service) createServer(ctx context.Context, serverid string, name, desc string, theme string) (*resServer, error) {
+// publishChannelMsgEvent publishes a msg event to the online members of a
+// server viewing a channel
+func (s *Service) publishChannelMsgEvent(ctx context.Context, serverid, channelid string, v interface{}) {
+	m, err := s.getTypingChannelMembers(ctx, serverid, channelid)
+	if err != nil {
+		s.log.Err(ctx, kerrors.WithMsg(err, "Failed to get channel members"))
+		return
+	}
+	if len(m) == 0 {
+		return
+	}
+	present, err := s.getPresence(ctx, serverChannelLoc(serverid, channelid), m)
+	if err != nil {
+		s.log.Err(ctx, kerrors.WithMsg(err, "Failed to get presence"))
+		return
+	}
+	for _, i := range present {
+		if err := s.ws.Publish(ctx, i, s.opts.ChannelMsgChannel, v); err != nil {
+			s.log.Err(ctx, kerrors.WithMsg(err, "Failed to publish channel msg event"))
+		}
+	}
+}
+
+func (s *Service) createServer(ctx context.Context, serverid string, userid string, name, desc string, theme string) (*resServer, error) {
 	m := s.servers.New(serverid, name, desc, theme)
 	if err := s.servers.Insert(ctx, m); err != nil {
 		if errors.Is(err, dbsql.ErrUnique) {
@@ -29,6 +55,12 @@ func (s *Service) createServer(ctx context.Context, serverid string, name, desc
 		}
 		return nil, kerrors.WithMsg(err, "Failed to create server")
 	}
+	if err := s.acl.InsertRelations(ctx, []authzacl.Relation{serverOwnerRel(serverid, userid)}); err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to add server owner")
+	}
+	if err := s.servers.InsertMember(ctx, s.servers.NewMember(serverid, userid)); err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to add server owner member")
+	}
 	return &resServer{
 		ServerID:     m.ServerID,
 		Name:         m.Name,
@@ -55,7 +87,10 @@ func (s *Service) getServer(ctx context.Context, serverid string) (*resServer, e
 	}, nil
 }
 
-func (s *Service) updateServer(ctx context.Context, serverid string, name, desc string, theme string) error {
+func (s *Service) updateServer(ctx context.Context, serverid string, userid string, name, desc string, theme string) error {
+	if err := s.requireServerPerm(ctx, serverid, userid, serverPermManageServer); err != nil {
+		return err
+	}
 	m, err := s.servers.GetServer(ctx, serverid)
 	if err != nil {
 		if errors.Is(err, dbsql.ErrNotFound) {
@@ -82,10 +117,15 @@ type (
 		Desc         string `json:"desc"`
 		Theme        string `json:"theme"`
 		CreationTime int64  `json:"creation_time"`
+		ReadMsgid    string `json:"read_msgid"`
+		Unread       int    `json:"unread"`
 	}
 )
 
-func (s *Service) createChannel(ctx context.Context, serverid, channelid string, name, desc string, theme string) (*resChannel, error) {
+func (s *Service) createChannel(ctx context.Context, serverid, channelid string, userid string, name, desc string, theme string) (*resChannel, error) {
+	if err := s.requireServerPerm(ctx, serverid, userid, serverPermManageChannels); err != nil {
+		return nil, err
+	}
 	if _, err := s.servers.GetServer(ctx, serverid); err != nil {
 		if errors.Is(err, dbsql.ErrNotFound) {
 			return nil, governor.ErrWithRes(err, http.StatusNotFound, "", "Server not found")
@@ -152,7 +192,7 @@ type (
 	}
 )
 
-func (s *Service) getChannels(ctx context.Context, serverid string, prefix string, limit, offset int) (*resChannels, error) {
+func (s *Service) getChannels(ctx context.Context, serverid string, userid string, prefix string, limit, offset int) (*resChannels, error) {
 	if _, err := s.servers.GetServer(ctx, serverid); err != nil {
 		if errors.Is(err, dbsql.ErrNotFound) {
 			return nil, governor.ErrWithRes(err, http.StatusNotFound, "", "Server not found")
@@ -163,6 +203,14 @@ func (s *Service) getChannels(ctx context.Context, serverid string, prefix strin
 	if err != nil {
 		return nil, kerrors.WithMsg(err, "Failed to get channels")
 	}
+	chatids := make([]string, 0, len(m))
+	for _, i := range m {
+		chatids = append(chatids, i.Chatid)
+	}
+	unread, err := s.getUnread(ctx, userid, chatids)
+	if err != nil {
+		return nil, err
+	}
 	res := make([]resChannel, 0, len(m))
 	for _, i := range m {
 		res = append(res, resChannel{
@@ -173,6 +221,8 @@ func (s *Service) getChannels(ctx context.Context, serverid string, prefix strin
 			Desc:         i.Desc,
 			Theme:        i.Theme,
 			CreationTime: i.CreationTime,
+			ReadMsgid:    unread[i.Chatid].ReadMsgid,
+			Unread:       unread[i.Chatid].Count,
 		})
 	}
 	return &resChannels{
@@ -180,7 +230,10 @@ func (s *Service) getChannels(ctx context.Context, serverid string, prefix strin
 	}, nil
 }
 
-func (s *Service) updateChannel(ctx context.Context, serverid, channelid string, name, desc string, theme string) error {
+func (s *Service) updateChannel(ctx context.Context, serverid, channelid string, userid string, name, desc string, theme string) error {
+	if err := s.requireServerPerm(ctx, serverid, userid, serverPermManageChannels); err != nil {
+		return err
+	}
 	m, err := s.getServerChannel(ctx, serverid, channelid)
 	if err != nil {
 		return err
@@ -195,14 +248,20 @@ func (s *Service) updateChannel(ctx context.Context, serverid, channelid string,
 	return nil
 }
 
-func (s *Service) deleteChannel(ctx context.Context, serverid, channelid string) error {
+func (s *Service) deleteChannel(ctx context.Context, serverid, channelid string, userid string) error {
+	if err := s.requireServerPerm(ctx, serverid, userid, serverPermManageChannels); err != nil {
+		return err
+	}
 	m, err := s.getServerChannel(ctx, serverid, channelid)
 	if err != nil {
 		return err
 	}
-	if err := s.msgs.DeleteChatMsgs(ctx, m.Chatid); err != nil {
+	if err := s.deleteChatMsgs(ctx, m.Chatid); err != nil {
 		return kerrors.WithMsg(err, "Failed to delete channel messages")
 	}
+	if err := s.bots.DeleteServerChannel(ctx, serverid, channelid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete channel bots and webhooks")
+	}
 	if err := s.servers.DeleteChannels(ctx, serverid, []string{channelid}); err != nil {
 		return kerrors.WithMsg(err, "Failed to delete channel")
 	}
@@ -210,63 +269,66 @@ func (s *Service) deleteChannel(ctx context.Context, serverid, channelid string)
 	return nil
 }
 
-func (s *Service) createChannelMsg(ctx context.Context, serverid, channelid string, userid string, kind string, value string) (*resMsg, error) {
-	ch, err := s.getServerChannel(ctx, serverid, channelid)
+func (s *Service) createChannelMsg(ctx context.Context, serverid, channelid string, userid string, kind string, value string, parentid string) (*resMsg, error) {
+	ch, err := s.getServerChannelWriter(ctx, serverid, channelid, userid)
 	if err != nil {
 		return nil, err
 	}
-	m, err := s.msgs.New(ch.Chatid, userid, kind, value)
+	m, err := s.createMsg(ctx, ch.Chatid, userid, kind, value, parentid)
 	if err != nil {
-		return nil, kerrors.WithMsg(err, "Failed to create new server msg")
-	}
-	if err := s.msgs.Insert(ctx, m); err != nil {
-		return nil, kerrors.WithMsg(err, "Failed to send new server msg")
+		return nil, err
 	}
-	res := resMsg{
-		Chatid: m.Chatid,
-		Msgid:  m.Msgid,
-		Userid: m.Userid,
-		Timems: m.Timems,
-		Kind:   m.Kind,
-		Value:  m.Value,
+	return s.channelMsgCreated(ctx, ch, m)
+}
+
+// channelMsgCreated notifies server members of a new channel msg
+func (s *Service) channelMsgCreated(ctx context.Context, ch *servermodel.ChannelModel, m *msgmodel.Model) (*resMsg, error) {
+	res := msgToRes(m)
+	// must make a best effort to publish channel msg event
+	ctx = klog.ExtendCtx(context.Background(), ctx)
+	s.publishChannelMsgEvent(ctx, ch.ServerID, ch.ChannelID, resMsgEvent{
+		Kind: msgEventKindCreate,
+		Msg:  &res,
+	})
+	if m.Kind == chatMsgKindTxt {
+		s.publishMsgIndexEvent(ctx, m.Chatid, m.Msgid)
 	}
-	// TODO publish channel message event
 	return &res, nil
 }
 
-func (s *Service) getChannelMsgs(ctx context.Context, serverid, channelid string, kind string, before string, limit int) (*resMsgs, error) {
+func (s *Service) getChannelMsgs(ctx context.Context, serverid, channelid string, userid string, kind string, before string, limit int) (*resMsgs, error) {
 	ch, err := s.getServerChannel(ctx, serverid, channelid)
 	if err != nil {
 		return nil, err
 	}
-	m, err := s.msgs.GetMsgs(ctx, ch.Chatid, kind, before, limit)
-	if err != nil {
-		return nil, kerrors.WithMsg(err, "Failed to get server chat msgs")
-	}
-	res := make([]resMsg, 0, len(m))
-	for _, i := range m {
-		res = append(res, resMsg{
-			Chatid: i.Chatid,
-			Msgid:  i.Msgid,
-			Userid: i.Userid,
-			Timems: i.Timems,
-			Kind:   i.Kind,
-			Value:  i.Value,
-		})
-	}
-	return &resMsgs{
-		Msgs: res,
-	}, nil
+	return s.getChatMsgs(ctx, ch.Chatid, userid, kind, before, limit)
 }
 
-func (s *Service) deleteChannelMsg(ctx context.Context, serverid, channelid string, msgid string) error {
+func (s *Service) deleteChannelMsg(ctx context.Context, serverid, channelid string, userid string, msgid string) error {
 	ch, err := s.getServerChannel(ctx, serverid, channelid)
 	if err != nil {
 		return err
 	}
-	if err := s.msgs.EraseMsgs(ctx, ch.Chatid, []string{msgid}); err != nil {
+	m, err := s.getChatMsg(ctx, ch.Chatid, msgid)
+	if err != nil {
+		return err
+	}
+	if m.Userid != userid {
+		if err := s.requireServerPerm(ctx, serverid, userid, serverPermDeleteMsgs); err != nil {
+			return err
+		}
+	}
+	if err := s.expireMsgs(ctx, ch.Chatid, []string{msgid}); err != nil {
 		return kerrors.WithMsg(err, "Failed to delete server chat msg")
 	}
-	// TODO: publish msg delete event
+	if m.Userid != userid {
+		if err := s.insertAudit(ctx, serverid, userid, auditActionMsgDelete, auditEntry{
+			Targetid: m.Userid,
+			Chatid:   m.Chatid,
+			Msgid:    m.Msgid,
+		}); err != nil {
+			return err
+		}
+	}
 	return nil
 }
@@ -0,0 +1,311 @@
+package conduit
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"xorkevin.dev/governor/service/dbsql"
+	"xorkevin.dev/governor/service/kvstore"
+	"xorkevin.dev/governor/service/ratelimit"
+	"xorkevin.dev/governor/util/kjson"
+	"xorkevin.dev/kerrors"
+)
+
+const (
+	typingRatelimitKeyUser = "conduittyping.user"
+	typingRecipientCap     = 256
+)
+
+type (
+	typingConfig struct {
+		ttl           time.Duration
+		cacheDuration time.Duration
+		limit         ratelimit.Params
+	}
+
+	// resTypingEvent is an ephemeral typing signal published to chat
+	// participants
+	resTypingEvent struct {
+		Kind      string `json:"kind"`
+		Chatid    string `json:"chatid,omitempty"`
+		ServerID  string `json:"serverid,omitempty"`
+		ChannelID string `json:"channelid,omitempty"`
+		Userid    string `json:"userid"`
+		Typing    bool   `json:"typing"`
+		TTL       int64  `json:"ttl"`
+	}
+)
+
+// getTypingCache returns a list of userids cached in the kv store, falling
+// back to fetch on a cache miss
+//
+// Typing signals are sent for every keystroke, so chat membership is cached
+// for a short duration rather than read from the db on each signal.
+func (s *Service) getTypingCache(ctx context.Context, key string, fetch func() ([]string, error)) ([]string, error) {
+	if v, err := s.kvtyping.Get(ctx, key); err != nil {
+		if !errors.Is(err, kvstore.ErrNotFound) {
+			s.log.Err(ctx, kerrors.WithMsg(err, "Failed to get typing cache"))
+		}
+	} else {
+		var res []string
+		if err := kjson.Unmarshal([]byte(v), &res); err != nil {
+			s.log.Err(ctx, kerrors.WithMsg(err, "Failed to decode typing cache"))
+		} else {
+			return res, nil
+		}
+	}
+
+	res, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	if res == nil {
+		res = []string{}
+	}
+	b, err := kjson.Marshal(res)
+	if err != nil {
+		s.log.Err(ctx, kerrors.WithMsg(err, "Failed to encode typing cache"))
+		return res, nil
+	}
+	if err := s.kvtyping.Set(ctx, key, string(b), s.typing.cacheDuration); err != nil {
+		s.log.Err(ctx, kerrors.WithMsg(err, "Failed to set typing cache"))
+	}
+	return res, nil
+}
+
+func (s *Service) getTypingDMMembers(ctx context.Context, chatid string) ([]string, error) {
+	return s.getTypingCache(ctx, locDM+"."+chatid, func() ([]string, error) {
+		m, err := s.dms.GetByChatID(ctx, chatid)
+		if err != nil {
+			if errors.Is(err, dbsql.ErrNotFound) {
+				return nil, nil
+			}
+			return nil, kerrors.WithMsg(err, "Failed to get dm")
+		}
+		return []string{m.Userid1, m.Userid2}, nil
+	})
+}
+
+func (s *Service) getTypingGDMMembers(ctx context.Context, chatid string) ([]string, error) {
+	return s.getTypingCache(ctx, locGDM+"."+chatid, func() ([]string, error) {
+		m, err := s.gdms.GetChatsMembers(ctx, []string{chatid}, groupChatMemberCap*2)
+		if err != nil {
+			return nil, kerrors.WithMsg(err, "Failed to get gdm members")
+		}
+		res := make([]string, 0, len(m))
+		for _, i := range m {
+			res = append(res, i.Userid)
+		}
+		return res, nil
+	})
+}
+
+func (s *Service) checkTypingServerMember(ctx context.Context, serverid, userid string) (bool, error) {
+	m, err := s.getTypingCache(ctx, locServer+"."+serverid+".member."+userid, func() ([]string, error) {
+		if ok, err := s.checkServerMember(ctx, serverid, userid); err != nil {
+			return nil, err
+		} else if !ok {
+			return nil, nil
+		}
+		return []string{userid}, nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return len(m) != 0, nil
+}
+
+// getTypingChannelMembers returns the online members of a server who may be
+// viewing a channel
+func (s *Service) getTypingChannelMembers(ctx context.Context, serverid, channelid string) ([]string, error) {
+	return s.getTypingCache(ctx, serverChannelLoc(serverid, channelid), func() ([]string, error) {
+		if _, err := s.servers.GetChannel(ctx, serverid, channelid); err != nil {
+			if errors.Is(err, dbsql.ErrNotFound) {
+				return nil, nil
+			}
+			return nil, kerrors.WithMsg(err, "Failed to get channel")
+		}
+		after := time.Now().Round(0).Add(-presenceDuration).Unix()
+		m, err := s.servers.GetPresence(ctx, serverid, after, typingRecipientCap, 0)
+		if err != nil {
+			return nil, kerrors.WithMsg(err, "Failed to get server presence")
+		}
+		res := make([]string, 0, len(m))
+		for _, i := range m {
+			res = append(res, i.Userid)
+		}
+		return res, nil
+	})
+}
+
+// typingChatKey returns the typing cache key of the chat of a typing signal
+func typingChatKey(req reqTypingSignal) string {
+	switch req.Kind {
+	case locDM, locGDM:
+		return req.Kind + "." + req.Chatid
+	default:
+		return serverChannelLoc(req.ServerID, req.ChannelID)
+	}
+}
+
+// filterTypingBlockers removes the users who have blocked the sender of a
+// typing signal
+//
+// Blocks are cached along with chat membership, so a block may take up to the
+// cache duration to apply to typing signals.
+func (s *Service) filterTypingBlockers(ctx context.Context, req reqTypingSignal, userids []string) ([]string, error) {
+	blockers, err := s.getTypingCache(ctx, typingChatKey(req)+".blocker."+req.Userid, func() ([]string, error) {
+		m, err := s.blocks.GetBlockersByID(ctx, req.Userid, userids)
+		if err != nil {
+			return nil, kerrors.WithMsg(err, "Failed to get blocking users")
+		}
+		res := make([]string, 0, len(m))
+		for _, i := range m {
+			res = append(res, i.Userid)
+		}
+		return res, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(blockers) == 0 {
+		return userids, nil
+	}
+	res := make([]string, 0, len(userids))
+	for _, i := range userids {
+		if !containsStr(blockers, i) {
+			res = append(res, i)
+		}
+	}
+	return res, nil
+}
+
+func containsStr(a []string, s string) bool {
+	for _, i := range a {
+		if i == s {
+			return true
+		}
+	}
+	return false
+}
+
+// getTypingRecipients returns the presence location and participants of the
+// chat of a typing signal, or false if the user is not a participant
+func (s *Service) getTypingRecipients(ctx context.Context, req reqTypingSignal) (string, []string, bool, error) {
+	switch req.Kind {
+	case locDM:
+		m, err := s.getTypingDMMembers(ctx, req.Chatid)
+		if err != nil {
+			return "", nil, false, err
+		}
+		if !containsStr(m, req.Userid) {
+			return "", nil, false, nil
+		}
+		return locDM, m, true, nil
+	case locGDM:
+		m, err := s.getTypingGDMMembers(ctx, req.Chatid)
+		if err != nil {
+			return "", nil, false, err
+		}
+		if !containsStr(m, req.Userid) {
+			return "", nil, false, nil
+		}
+		return locGDM, m, true, nil
+	case locChannel:
+		if ok, err := s.checkTypingServerMember(ctx, req.ServerID, req.Userid); err != nil {
+			return "", nil, false, err
+		} else if !ok {
+			return "", nil, false, nil
+		}
+		m, err := s.getTypingChannelMembers(ctx, req.ServerID, req.ChannelID)
+		if err != nil {
+			return "", nil, false, err
+		}
+		return serverChannelLoc(req.ServerID, req.ChannelID), m, true, nil
+	default:
+		return "", nil, false, nil
+	}
+}
+
+func (s *Service) typingHandler(ctx context.Context, topic string, userid string, msgdata []byte) error {
+	var req reqTypingSignal
+	if err := kjson.Unmarshal(msgdata, &req); err != nil {
+		s.log.WarnErr(ctx, kerrors.WithMsg(err, "Invalid typing signal"))
+		return nil
+	}
+	req.Userid = userid
+	if err := req.valid(); err != nil {
+		s.log.WarnErr(ctx, kerrors.WithMsg(err, "Invalid typing signal"))
+		return nil
+	}
+	switch req.Kind {
+	case locDM, locGDM:
+		if err := validhasChatid(req.Chatid); err != nil {
+			s.log.WarnErr(ctx, kerrors.WithMsg(err, "Invalid typing signal"))
+			return nil
+		}
+		req.ServerID = ""
+		req.ChannelID = ""
+	case locChannel:
+		if err := validhasServerID(req.ServerID); err != nil {
+			s.log.WarnErr(ctx, kerrors.WithMsg(err, "Invalid typing signal"))
+			return nil
+		}
+		if err := validhasChannelID(req.ChannelID); err != nil {
+			s.log.WarnErr(ctx, kerrors.WithMsg(err, "Invalid typing signal"))
+			return nil
+		}
+		req.Chatid = ""
+	}
+
+	if err := s.ratelimiter.Ratelimit(ctx, []ratelimit.Tag{
+		{
+			Key:    typingRatelimitKeyUser,
+			Value:  req.Userid,
+			Params: s.typing.limit,
+		},
+	}); err != nil {
+		s.log.WarnErr(ctx, kerrors.WithMsg(err, "Typing signal ratelimited"))
+		return nil
+	}
+
+	loc, members, ok, err := s.getTypingRecipients(ctx, req)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		s.log.Warn(ctx, "Typing signal by non-member")
+		return nil
+	}
+
+	userids := make([]string, 0, len(members))
+	for _, i := range members {
+		if i != req.Userid {
+			userids = append(userids, i)
+		}
+	}
+	userids, err = s.filterTypingBlockers(ctx, req, userids)
+	if err != nil {
+		return err
+	}
+	present, err := s.getPresence(ctx, loc, userids)
+	if err != nil {
+		return kerrors.WithMsg(err, "Failed to get presence")
+	}
+	ev := resTypingEvent{
+		Kind:      req.Kind,
+		Chatid:    req.Chatid,
+		ServerID:  req.ServerID,
+		ChannelID: req.ChannelID,
+		Userid:    req.Userid,
+		Typing:    req.Typing,
+		TTL:       int64(s.typing.ttl / time.Second),
+	}
+	for _, i := range present {
+		if err := s.ws.Publish(ctx, i, s.opts.TypingChannel, ev); err != nil {
+			s.log.Err(ctx, kerrors.WithMsg(err, "Failed to publish typing event"))
+		}
+	}
+	return nil
+}
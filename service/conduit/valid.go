@@ -6,22 +6,40 @@ import (
 	"regexp"
 
 	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/conduit/modmodel"
 )
 
 //go:generate forge validation
 
 const (
-	lengthCapChatid    = 31
-	lengthCapServerID  = 31
-	lengthCapChannelID = 31
-	lengthCapKind      = 31
-	lengthCapName      = 127
-	lengthCapDesc      = 127
-	lengthCapTheme     = 4095
-	lengthCapUserid    = 31
-	lengthCapMsgid     = 31
-	lengthCapMsg       = 4095
-	amountCap          = 255
+	lengthCapChatid      = 31
+	lengthCapServerID    = 31
+	lengthCapChannelID   = 31
+	lengthCapKind        = 31
+	lengthCapName        = 127
+	lengthCapDesc        = 127
+	lengthCapTheme       = 4095
+	lengthCapUserid      = 31
+	lengthCapMsgid       = 31
+	lengthCapMsg         = 4095
+	lengthCapQuery       = 255
+	lengthCapReaction    = 63
+	lengthCapFilename    = 255
+	lengthCapCode        = 31
+	lengthCapRoleid      = 31
+	lengthCapReason      = 255
+	lengthCapReportid    = 31
+	lengthCapBotid       = 31
+	lengthCapWebhookid   = 31
+	lengthCapKeyid       = 63
+	lengthCapWebhookKey  = 127
+	lengthCapAvatar      = 4095
+	amountCap            = 255
+	inviteMaxUsesCap     = 65535
+	inviteDurationCap    = 30 * 24 * 60 * 60
+	retentionDurationMin = 60 * 60
+	retentionDurationCap = 100 * 365 * 24 * 60 * 60
+	muteDurationCap      = 365 * 24 * 60 * 60
 )
 
 var channelRegex = regexp.MustCompile(`^[a-z0-9_-]+$`)
@@ -36,6 +54,13 @@ func validhasChatid(chatid string) error {
 	return nil
 }
 
+func validoptChatid(chatid string) error {
+	if len(chatid) > lengthCapChatid {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Chat id must be shorter than 32 characters")
+	}
+	return nil
+}
+
 func validhasChatids(chatids []string) error {
 	if len(chatids) == 0 {
 		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "IDs must be provided")
@@ -61,6 +86,13 @@ func validhasServerID(serverid string) error {
 	return nil
 }
 
+func validoptServerID(serverid string) error {
+	if len(serverid) > lengthCapServerID {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Server id must be shorter than 32 characters")
+	}
+	return nil
+}
+
 func validChannelID(channelid string) error {
 	if len(channelid) < 3 {
 		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Channel id must be longer than 2 characters")
@@ -71,6 +103,13 @@ func validChannelID(channelid string) error {
 	return nil
 }
 
+func validoptChannelID(channelid string) error {
+	if len(channelid) > lengthCapChannelID {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Channel id must be shorter than 32 characters")
+	}
+	return nil
+}
+
 func validhasChannelID(channelid string) error {
 	if len(channelid) == 0 {
 		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Channel id must be provided")
@@ -102,6 +141,16 @@ func validSearch(search string) error {
 	return nil
 }
 
+func validhasQuery(query string) error {
+	if len(query) == 0 {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Query must be provided")
+	}
+	if len(query) > lengthCapQuery {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Query must be shorter than 256 characters")
+	}
+	return nil
+}
+
 func validTheme(theme string) error {
 	if len(theme) > lengthCapTheme {
 		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Theme must be shorter than 4096 characters")
@@ -122,6 +171,13 @@ func validhasUserid(userid string) error {
 	return nil
 }
 
+func validoptUserid(userid string) error {
+	if len(userid) > lengthCapUserid {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Userid must be shorter than 32 characters")
+	}
+	return nil
+}
+
 func validhasUserids(userids []string) error {
 	if len(userids) == 0 {
 		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "IDs must be provided")
@@ -219,6 +275,15 @@ func validoptMsgkind(kind string) error {
 	return nil
 }
 
+func validTypingKind(kind string) error {
+	switch kind {
+	case locDM, locGDM, locChannel:
+	default:
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Invalid typing chat kind")
+	}
+	return nil
+}
+
 func validMsgvalue(value string) error {
 	if len(value) == 0 {
 		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Msg value must be provided")
@@ -228,3 +293,224 @@ func validMsgvalue(value string) error {
 	}
 	return nil
 }
+
+func validReaction(reaction string) error {
+	if len(reaction) == 0 {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Reaction must be provided")
+	}
+	if len(reaction) > lengthCapReaction {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Reaction must be shorter than 64 characters")
+	}
+	return nil
+}
+
+func validAttachmentName(name string) error {
+	if len(name) == 0 {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Attachment name must be provided")
+	}
+	if len(name) > lengthCapFilename {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Attachment name must be shorter than 256 characters")
+	}
+	return nil
+}
+
+func validhasInviteCode(code string) error {
+	if len(code) == 0 {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Invite code must be provided")
+	}
+	if len(code) > lengthCapCode {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Invite code must be shorter than 32 characters")
+	}
+	return nil
+}
+
+func validInviteMaxUses(amt int) error {
+	if amt < 0 {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Max uses must not be negative")
+	}
+	if amt > inviteMaxUsesCap {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Max uses must be less than 65536")
+	}
+	return nil
+}
+
+func validInviteDuration(duration int64) error {
+	if duration < 0 {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Duration must not be negative")
+	}
+	if duration > inviteDurationCap {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Duration must not be longer than 30 days")
+	}
+	return nil
+}
+
+func validRetentionDuration(duration int64) error {
+	if duration < 0 {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Retention duration must not be negative")
+	}
+	if duration != 0 && duration < retentionDurationMin {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Retention duration must be at least an hour")
+	}
+	if duration > retentionDurationCap {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Retention duration must not be longer than 100 years")
+	}
+	return nil
+}
+
+func validBanReason(reason string) error {
+	if len(reason) > lengthCapReason {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Reason must be shorter than 256 characters")
+	}
+	return nil
+}
+
+func validReportReason(reason string) error {
+	if len(reason) == 0 {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Reason must be provided")
+	}
+	if len(reason) > lengthCapReason {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Reason must be shorter than 256 characters")
+	}
+	return nil
+}
+
+func validhasReportid(reportid string) error {
+	if len(reportid) == 0 {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Report id must be provided")
+	}
+	if len(reportid) > lengthCapReportid {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Report id must be shorter than 32 characters")
+	}
+	return nil
+}
+
+func validoptReportStatus(status string) error {
+	switch status {
+	case "", modmodel.ReportStatusOpen, modmodel.ReportStatusResolved, modmodel.ReportStatusDismissed:
+		return nil
+	default:
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Invalid report status")
+	}
+}
+
+func validResolveStatus(status string) error {
+	switch status {
+	case modmodel.ReportStatusResolved, modmodel.ReportStatusDismissed:
+		return nil
+	default:
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Invalid resolve status")
+	}
+}
+
+func validMuteDuration(duration int64) error {
+	if duration <= 0 {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Mute duration must be positive")
+	}
+	if duration > muteDurationCap {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Mute duration must not be longer than a year")
+	}
+	return nil
+}
+
+func validhasRoleid(roleid string) error {
+	if len(roleid) == 0 {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Role id must be provided")
+	}
+	if len(roleid) > lengthCapRoleid {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Role id must be shorter than 32 characters")
+	}
+	return nil
+}
+
+func validRoleName(name string) error {
+	if len(name) == 0 {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Role name must be provided")
+	}
+	if len(name) > lengthCapName {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Role name must be shorter than 128 characters")
+	}
+	return nil
+}
+
+func validServerPerms(perms []string) error {
+	if len(perms) > len(serverPerms) {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Too many permissions")
+	}
+	set := map[string]struct{}{}
+	for _, i := range perms {
+		switch i {
+		case serverPermManageServer, serverPermManageChannels, serverPermDeleteMsgs, serverPermKick, serverPermBan, serverPermModerate:
+		default:
+			return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Invalid permission")
+		}
+		if _, ok := set[i]; ok {
+			return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Must not have duplicate permissions")
+		}
+		set[i] = struct{}{}
+	}
+	return nil
+}
+
+func validhasBotid(botid string) error {
+	if len(botid) == 0 {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Bot id must be provided")
+	}
+	if len(botid) > lengthCapBotid {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Bot id must be shorter than 32 characters")
+	}
+	return nil
+}
+
+func validhasBotName(name string) error {
+	if len(name) == 0 {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Name must be provided")
+	}
+	if len(name) > lengthCapName {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Name must be shorter than 128 characters")
+	}
+	return nil
+}
+
+func validoptBotName(name string) error {
+	if len(name) == 0 {
+		return nil
+	}
+	return validhasBotName(name)
+}
+
+func validAvatar(avatar string) error {
+	if len(avatar) > lengthCapAvatar {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Avatar must be shorter than 4096 characters")
+	}
+	return nil
+}
+
+func validhasKeyid(keyid string) error {
+	if len(keyid) == 0 {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Key id must be provided")
+	}
+	if len(keyid) > lengthCapKeyid {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Key id must be shorter than 64 characters")
+	}
+	return nil
+}
+
+func validhasWebhookid(webhookid string) error {
+	if len(webhookid) == 0 {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Webhook id must be provided")
+	}
+	if len(webhookid) > lengthCapWebhookid {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Webhook id must be shorter than 32 characters")
+	}
+	return nil
+}
+
+func validhasWebhookKey(key string) error {
+	if len(key) == 0 {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Webhook key must be provided")
+	}
+	if len(key) > lengthCapWebhookKey {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Webhook key is invalid")
+	}
+	return nil
+}
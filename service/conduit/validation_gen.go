@@ -64,6 +64,29 @@ func (r reqGetFriendInvitations) valid() error {
 	return nil
 }
 
+func (r reqGetBlocked) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validAmount(r.Amount); err != nil {
+		return err
+	}
+	if err := validOffset(r.Offset); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqBlockUser) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasUserid(r.Target); err != nil {
+		return err
+	}
+	return nil
+}
+
 func (r reqGetLatestChats) valid() error {
 	if err := validhasUserid(r.Userid); err != nil {
 		return err
@@ -113,6 +136,9 @@ func (r reqCreateMsg) valid() error {
 	if err := validMsgvalue(r.Value); err != nil {
 		return err
 	}
+	if err := validoptMsgid(r.Parentid); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -135,6 +161,25 @@ func (r reqGetMsgs) valid() error {
 	return nil
 }
 
+func (r reqSearchMsgs) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasChatid(r.Chatid); err != nil {
+		return err
+	}
+	if err := validhasQuery(r.Query); err != nil {
+		return err
+	}
+	if err := validAmount(r.Amount); err != nil {
+		return err
+	}
+	if err := validOffset(r.Offset); err != nil {
+		return err
+	}
+	return nil
+}
+
 func (r reqDelMsg) valid() error {
 	if err := validhasUserid(r.Userid); err != nil {
 		return err
@@ -148,6 +193,134 @@ func (r reqDelMsg) valid() error {
 	return nil
 }
 
+func (r reqEditMsg) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasChatid(r.Chatid); err != nil {
+		return err
+	}
+	if err := validhasMsgid(r.Msgid); err != nil {
+		return err
+	}
+	if err := validMsgvalue(r.Value); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqGetMsgEdits) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasChatid(r.Chatid); err != nil {
+		return err
+	}
+	if err := validhasMsgid(r.Msgid); err != nil {
+		return err
+	}
+	if err := validAmount(r.Amount); err != nil {
+		return err
+	}
+	if err := validOffset(r.Offset); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqReactMsg) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasChatid(r.Chatid); err != nil {
+		return err
+	}
+	if err := validhasMsgid(r.Msgid); err != nil {
+		return err
+	}
+	if err := validReaction(r.Reaction); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqGetThreadMsgs) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasChatid(r.Chatid); err != nil {
+		return err
+	}
+	if err := validhasMsgid(r.Parentid); err != nil {
+		return err
+	}
+	if err := validoptMsgid(r.Before); err != nil {
+		return err
+	}
+	if err := validAmount(r.Amount); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqReadMsg) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasChatid(r.Chatid); err != nil {
+		return err
+	}
+	if err := validhasMsgid(r.Msgid); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqGetReadCursors) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasChatid(r.Chatid); err != nil {
+		return err
+	}
+	if err := validAmount(r.Amount); err != nil {
+		return err
+	}
+	if err := validOffset(r.Offset); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqCreateAttachmentMsg) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasChatid(r.Chatid); err != nil {
+		return err
+	}
+	if err := validAttachmentName(r.Name); err != nil {
+		return err
+	}
+	if err := validoptMsgid(r.Parentid); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqGetAttachment) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasChatid(r.Chatid); err != nil {
+		return err
+	}
+	if err := validhasMsgid(r.Msgid); err != nil {
+		return err
+	}
+	return nil
+}
+
 func (r reqGetPresence) valid() error {
 	if err := validhasUserid(r.Userid); err != nil {
 		return err
@@ -158,6 +331,25 @@ func (r reqGetPresence) valid() error {
 	return nil
 }
 
+func (r reqTypingSignal) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validTypingKind(r.Kind); err != nil {
+		return err
+	}
+	if err := validoptChatid(r.Chatid); err != nil {
+		return err
+	}
+	if err := validoptServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validoptChannelID(r.ChannelID); err != nil {
+		return err
+	}
+	return nil
+}
+
 func (r reqSearchGDMs) valid() error {
 	if err := validhasUserid(r.Userid1); err != nil {
 		return err
@@ -206,6 +398,29 @@ func (r reqUpdateGDM) valid() error {
 	return nil
 }
 
+func (r reqGetGDMRetention) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasChatid(r.Chatid); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqUpdateGDMRetention) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasChatid(r.Chatid); err != nil {
+		return err
+	}
+	if err := validRetentionDuration(r.Duration); err != nil {
+		return err
+	}
+	return nil
+}
+
 func (r reqDelGDM) valid() error {
 	if err := validhasUserid(r.Userid); err != nil {
 		return err
@@ -237,6 +452,9 @@ func (r reqGetServer) valid() error {
 }
 
 func (r reqCreateServer) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
 	if err := validhasServerID(r.ServerID); err != nil {
 		return err
 	}
@@ -252,17 +470,20 @@ func (r reqCreateServer) valid() error {
 	return nil
 }
 
-func (r reqGetChannel) valid() error {
-	if err := validhasServerID(r.ServerID); err != nil {
+func (r reqGetUserServers) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
 		return err
 	}
-	if err := validhasChannelID(r.ChannelID); err != nil {
+	if err := validAmount(r.Amount); err != nil {
+		return err
+	}
+	if err := validOffset(r.Offset); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (r reqGetChannels) valid() error {
+func (r reqGetServerMembers) valid() error {
 	if err := validhasServerID(r.ServerID); err != nil {
 		return err
 	}
@@ -275,103 +496,964 @@ func (r reqGetChannels) valid() error {
 	return nil
 }
 
-func (r reqSearchChannels) valid() error {
+func (r reqGetServerPresence) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
 	if err := validhasServerID(r.ServerID); err != nil {
 		return err
 	}
-	if err := validhasChannelID(r.Prefix); err != nil {
+	if err := validoptChannelID(r.ChannelID); err != nil {
 		return err
 	}
 	if err := validAmount(r.Amount); err != nil {
 		return err
 	}
+	if err := validOffset(r.Offset); err != nil {
+		return err
+	}
 	return nil
 }
 
-func (r reqCreateChannel) valid() error {
-	if err := validhasServerID(r.ServerID); err != nil {
+func (r reqLeaveServer) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
 		return err
 	}
-	if err := validChannelID(r.ChannelID); err != nil {
+	if err := validhasServerID(r.ServerID); err != nil {
 		return err
 	}
-	if err := validName(r.Name); err != nil {
+	return nil
+}
+
+func (r reqServerMemberTarget) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
 		return err
 	}
-	if err := validDesc(r.Desc); err != nil {
+	if err := validhasServerID(r.ServerID); err != nil {
 		return err
 	}
-	if err := validTheme(r.Theme); err != nil {
+	if err := validhasUserid(r.Target); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (r reqUpdateChannel) valid() error {
-	if err := validhasServerID(r.ServerID); err != nil {
-		return err
-	}
-	if err := validhasChannelID(r.ChannelID); err != nil {
+func (r reqCreateServerInvite) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
 		return err
 	}
-	if err := validName(r.Name); err != nil {
+	if err := validhasServerID(r.ServerID); err != nil {
 		return err
 	}
-	if err := validDesc(r.Desc); err != nil {
+	if err := validInviteMaxUses(r.MaxUses); err != nil {
 		return err
 	}
-	if err := validTheme(r.Theme); err != nil {
+	if err := validInviteDuration(r.Duration); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (r reqCreateChannelMsg) valid() error {
+func (r reqGetServerInvites) valid() error {
 	if err := validhasUserid(r.Userid); err != nil {
 		return err
 	}
 	if err := validhasServerID(r.ServerID); err != nil {
 		return err
 	}
-	if err := validhasChannelID(r.ChannelID); err != nil {
-		return err
-	}
-	if err := validMsgkind(r.Kind); err != nil {
+	if err := validAmount(r.Amount); err != nil {
 		return err
 	}
-	if err := validMsgvalue(r.Value); err != nil {
+	if err := validOffset(r.Offset); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (r reqGetChannelMsgs) valid() error {
-	if err := validhasServerID(r.ServerID); err != nil {
+func (r reqDelServerInvite) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
 		return err
 	}
-	if err := validhasChannelID(r.ChannelID); err != nil {
+	if err := validhasServerID(r.ServerID); err != nil {
 		return err
 	}
-	if err := validoptMsgkind(r.Kind); err != nil {
+	if err := validhasInviteCode(r.Code); err != nil {
 		return err
 	}
-	if err := validoptMsgid(r.Before); err != nil {
+	return nil
+}
+
+func (r reqServerInvite) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
 		return err
 	}
-	if err := validAmount(r.Amount); err != nil {
+	if err := validhasInviteCode(r.Code); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (r reqDelChannelMsg) valid() error {
+func (r reqGetServerBans) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validAmount(r.Amount); err != nil {
+		return err
+	}
+	if err := validOffset(r.Offset); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqBanServerUser) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validhasUserid(r.Target); err != nil {
+		return err
+	}
+	if err := validBanReason(r.Reason); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqCreateServerRole) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validRoleName(r.Name); err != nil {
+		return err
+	}
+	if err := validServerPerms(r.Permissions); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqUpdateServerRole) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validhasRoleid(r.Roleid); err != nil {
+		return err
+	}
+	if err := validRoleName(r.Name); err != nil {
+		return err
+	}
+	if err := validServerPerms(r.Permissions); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqDelServerRole) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validhasRoleid(r.Roleid); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqGetServerRoleMembers) valid() error {
+	if err := validhasServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validhasRoleid(r.Roleid); err != nil {
+		return err
+	}
+	if err := validoptUserid(r.After); err != nil {
+		return err
+	}
+	if err := validAmount(r.Amount); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqServerRoleMember) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validhasRoleid(r.Roleid); err != nil {
+		return err
+	}
+	if err := validhasUserid(r.Target); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqGetChannel) valid() error {
+	if err := validhasServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validhasChannelID(r.ChannelID); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqGetChannels) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validAmount(r.Amount); err != nil {
+		return err
+	}
+	if err := validOffset(r.Offset); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqSearchChannels) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validhasChannelID(r.Prefix); err != nil {
+		return err
+	}
+	if err := validAmount(r.Amount); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqCreateChannel) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validChannelID(r.ChannelID); err != nil {
+		return err
+	}
+	if err := validName(r.Name); err != nil {
+		return err
+	}
+	if err := validDesc(r.Desc); err != nil {
+		return err
+	}
+	if err := validTheme(r.Theme); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqUpdateChannel) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validhasChannelID(r.ChannelID); err != nil {
+		return err
+	}
+	if err := validName(r.Name); err != nil {
+		return err
+	}
+	if err := validDesc(r.Desc); err != nil {
+		return err
+	}
+	if err := validTheme(r.Theme); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqGetChannelRetention) valid() error {
+	if err := validhasServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validhasChannelID(r.ChannelID); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqUpdateChannelRetention) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validhasChannelID(r.ChannelID); err != nil {
+		return err
+	}
+	if err := validRetentionDuration(r.Duration); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqDelChannel) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validhasChannelID(r.ChannelID); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqCreateChannelMsg) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validhasChannelID(r.ChannelID); err != nil {
+		return err
+	}
+	if err := validMsgkind(r.Kind); err != nil {
+		return err
+	}
+	if err := validMsgvalue(r.Value); err != nil {
+		return err
+	}
+	if err := validoptMsgid(r.Parentid); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqGetChannelMsgs) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validhasChannelID(r.ChannelID); err != nil {
+		return err
+	}
+	if err := validoptMsgkind(r.Kind); err != nil {
+		return err
+	}
+	if err := validoptMsgid(r.Before); err != nil {
+		return err
+	}
+	if err := validAmount(r.Amount); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqSearchChannelMsgs) valid() error {
+	if err := validhasServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validhasChannelID(r.ChannelID); err != nil {
+		return err
+	}
+	if err := validhasQuery(r.Query); err != nil {
+		return err
+	}
+	if err := validAmount(r.Amount); err != nil {
+		return err
+	}
+	if err := validOffset(r.Offset); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqDelChannelMsg) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validhasChannelID(r.ChannelID); err != nil {
+		return err
+	}
+	if err := validhasMsgid(r.Msgid); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqEditChannelMsg) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validhasChannelID(r.ChannelID); err != nil {
+		return err
+	}
+	if err := validhasMsgid(r.Msgid); err != nil {
+		return err
+	}
+	if err := validMsgvalue(r.Value); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqGetChannelMsgEdits) valid() error {
+	if err := validhasServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validhasChannelID(r.ChannelID); err != nil {
+		return err
+	}
+	if err := validhasMsgid(r.Msgid); err != nil {
+		return err
+	}
+	if err := validAmount(r.Amount); err != nil {
+		return err
+	}
+	if err := validOffset(r.Offset); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqReactChannelMsg) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validhasChannelID(r.ChannelID); err != nil {
+		return err
+	}
+	if err := validhasMsgid(r.Msgid); err != nil {
+		return err
+	}
+	if err := validReaction(r.Reaction); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqGetChannelThreadMsgs) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validhasChannelID(r.ChannelID); err != nil {
+		return err
+	}
+	if err := validhasMsgid(r.Parentid); err != nil {
+		return err
+	}
+	if err := validoptMsgid(r.Before); err != nil {
+		return err
+	}
+	if err := validAmount(r.Amount); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqReadChannelMsg) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validhasChannelID(r.ChannelID); err != nil {
+		return err
+	}
+	if err := validhasMsgid(r.Msgid); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqGetChannelReadCursors) valid() error {
+	if err := validhasServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validhasChannelID(r.ChannelID); err != nil {
+		return err
+	}
+	if err := validAmount(r.Amount); err != nil {
+		return err
+	}
+	if err := validOffset(r.Offset); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqCreateChannelAttachmentMsg) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validhasChannelID(r.ChannelID); err != nil {
+		return err
+	}
+	if err := validAttachmentName(r.Name); err != nil {
+		return err
+	}
+	if err := validoptMsgid(r.Parentid); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqGetChannelAttachment) valid() error {
+	if err := validhasServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validhasChannelID(r.ChannelID); err != nil {
+		return err
+	}
+	if err := validhasMsgid(r.Msgid); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqReportMsg) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasChatid(r.Chatid); err != nil {
+		return err
+	}
+	if err := validhasMsgid(r.Msgid); err != nil {
+		return err
+	}
+	if err := validReportReason(r.Reason); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqReportChannelMsg) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validhasChannelID(r.ChannelID); err != nil {
+		return err
+	}
+	if err := validhasMsgid(r.Msgid); err != nil {
+		return err
+	}
+	if err := validReportReason(r.Reason); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqGetReports) valid() error {
+	if err := validoptServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validoptReportStatus(r.Status); err != nil {
+		return err
+	}
+	if err := validAmount(r.Amount); err != nil {
+		return err
+	}
+	if err := validOffset(r.Offset); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqGetServerReports) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validoptReportStatus(r.Status); err != nil {
+		return err
+	}
+	if err := validAmount(r.Amount); err != nil {
+		return err
+	}
+	if err := validOffset(r.Offset); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqGetReport) valid() error {
+	if err := validhasReportid(r.Reportid); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqGetServerReport) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validhasReportid(r.Reportid); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqResolveReport) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validoptServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validhasReportid(r.Reportid); err != nil {
+		return err
+	}
+	if err := validResolveStatus(r.Status); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqGetMutes) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validoptServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validAmount(r.Amount); err != nil {
+		return err
+	}
+	if err := validOffset(r.Offset); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqMuteUser) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validoptServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validhasUserid(r.Target); err != nil {
+		return err
+	}
+	if err := validMuteDuration(r.Duration); err != nil {
+		return err
+	}
+	if err := validBanReason(r.Reason); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqUnmuteUser) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validoptServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validhasUserid(r.Target); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqGetAudits) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validoptServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validAmount(r.Amount); err != nil {
+		return err
+	}
+	if err := validOffset(r.Offset); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqGetBots) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validAmount(r.Amount); err != nil {
+		return err
+	}
+	if err := validOffset(r.Offset); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqCreateBot) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasBotName(r.Name); err != nil {
+		return err
+	}
+	if err := validAvatar(r.Avatar); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqBotID) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasBotid(r.Botid); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqUpdateBot) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasBotid(r.Botid); err != nil {
+		return err
+	}
+	if err := validhasBotName(r.Name); err != nil {
+		return err
+	}
+	if err := validAvatar(r.Avatar); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqGetBotKeys) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasBotid(r.Botid); err != nil {
+		return err
+	}
+	if err := validAmount(r.Amount); err != nil {
+		return err
+	}
+	if err := validOffset(r.Offset); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqCreateBotKey) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasBotid(r.Botid); err != nil {
+		return err
+	}
+	if err := validName(r.Name); err != nil {
+		return err
+	}
+	if err := validDesc(r.Desc); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqBotKeyID) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasBotid(r.Botid); err != nil {
+		return err
+	}
+	if err := validhasKeyid(r.Keyid); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqGetBotChannels) valid() error {
+	if err := validhasBotid(r.Botid); err != nil {
+		return err
+	}
+	if err := validAmount(r.Amount); err != nil {
+		return err
+	}
+	if err := validOffset(r.Offset); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqGetChannelBots) valid() error {
 	if err := validhasServerID(r.ServerID); err != nil {
 		return err
 	}
 	if err := validhasChannelID(r.ChannelID); err != nil {
 		return err
 	}
-	if err := validhasMsgid(r.Msgid); err != nil {
+	if err := validAmount(r.Amount); err != nil {
+		return err
+	}
+	if err := validOffset(r.Offset); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqChannelBot) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validhasChannelID(r.ChannelID); err != nil {
+		return err
+	}
+	if err := validhasBotid(r.Botid); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqGetWebhooks) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validhasChannelID(r.ChannelID); err != nil {
+		return err
+	}
+	if err := validAmount(r.Amount); err != nil {
+		return err
+	}
+	if err := validOffset(r.Offset); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqCreateWebhook) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validhasChannelID(r.ChannelID); err != nil {
+		return err
+	}
+	if err := validhasBotName(r.Name); err != nil {
+		return err
+	}
+	if err := validAvatar(r.Avatar); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqUpdateWebhook) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validhasChannelID(r.ChannelID); err != nil {
+		return err
+	}
+	if err := validhasWebhookid(r.Webhookid); err != nil {
+		return err
+	}
+	if err := validhasBotName(r.Name); err != nil {
+		return err
+	}
+	if err := validAvatar(r.Avatar); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqWebhookID) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasServerID(r.ServerID); err != nil {
+		return err
+	}
+	if err := validhasChannelID(r.ChannelID); err != nil {
+		return err
+	}
+	if err := validhasWebhookid(r.Webhookid); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqExecWebhook) valid() error {
+	if err := validhasWebhookid(r.Webhookid); err != nil {
+		return err
+	}
+	if err := validhasWebhookKey(r.Key); err != nil {
+		return err
+	}
+	if err := validoptBotName(r.Name); err != nil {
+		return err
+	}
+	if err := validAvatar(r.Avatar); err != nil {
+		return err
+	}
+	if err := validMsgvalue(r.Value); err != nil {
 		return err
 	}
 	return nil
@@ -0,0 +1,122 @@
+package image
+
+import (
+	"image/color"
+	"math"
+	"strings"
+
+	"xorkevin.dev/kerrors"
+)
+
+const (
+	blurhashChars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+	// blurhashSampleSize is the max dimension of the image sampled for a
+	// blurhash, since the components are only low frequencies
+	blurhashSampleSize = 32
+)
+
+func encodeBase83(b *strings.Builder, value, length int) {
+	for i := length - 1; i >= 0; i-- {
+		digit := value
+		for j := 0; j < i; j++ {
+			digit /= 83
+		}
+		b.WriteByte(blurhashChars[digit%83])
+	}
+}
+
+func srgbToLinear(v uint8) float64 {
+	f := float64(v) / 255
+	if f <= 0.04045 {
+		return f / 12.92
+	}
+	return math.Pow((f+0.055)/1.055, 2.4)
+}
+
+func linearToSrgb(f float64) int {
+	f = max(0, min(1, f))
+	if f <= 0.0031308 {
+		return int(f*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(f, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(v, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(v), exp), v)
+}
+
+// ToBlurhash encodes the image as a compact placeholder string in the
+// blurhash format with the given number of horizontal and vertical components
+// between 1 and 9
+func (i *imageData) ToBlurhash(xComponents, yComponents int) (string, error) {
+	if xComponents < 1 || xComponents > 9 || yComponents < 1 || yComponents > 9 {
+		return "", kerrors.WithKind(nil, ErrEncode, "Invalid blurhash components")
+	}
+	sample := i.Duplicate()
+	sample.ResizeLimit(blurhashSampleSize, blurhashSampleSize)
+	bounds := sample.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w == 0 || h == 0 {
+		return "", kerrors.WithKind(nil, ErrEncode, "Empty image")
+	}
+
+	linear := make([][3]float64, 0, w*h)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(sample.At(x, y)).(color.NRGBA)
+			linear = append(linear, [3]float64{srgbToLinear(c.R), srgbToLinear(c.G), srgbToLinear(c.B)})
+		}
+	}
+
+	factors := make([][3]float64, 0, xComponents*yComponents)
+	for j := 0; j < yComponents; j++ {
+		for k := 0; k < xComponents; k++ {
+			var f [3]float64
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					basis := math.Cos(math.Pi*float64(k)*float64(x)/float64(w)) * math.Cos(math.Pi*float64(j)*float64(y)/float64(h))
+					p := linear[y*w+x]
+					f[0] += basis * p[0]
+					f[1] += basis * p[1]
+					f[2] += basis * p[2]
+				}
+			}
+			normalization := 2.0
+			if j == 0 && k == 0 {
+				normalization = 1
+			}
+			scale := normalization / float64(w*h)
+			factors = append(factors, [3]float64{f[0] * scale, f[1] * scale, f[2] * scale})
+		}
+	}
+
+	var b strings.Builder
+	encodeBase83(&b, (xComponents-1)+(yComponents-1)*9, 1)
+
+	dc := factors[0]
+	ac := factors[1:]
+	maxValue := 1.0
+	if len(ac) > 0 {
+		actualMax := 0.0
+		for _, f := range ac {
+			actualMax = max(actualMax, math.Abs(f[0]), math.Abs(f[1]), math.Abs(f[2]))
+		}
+		quantMax := max(0, min(82, int(math.Floor(actualMax*166-0.5))))
+		maxValue = float64(quantMax+1) / 166
+		encodeBase83(&b, quantMax, 1)
+	} else {
+		encodeBase83(&b, 0, 1)
+	}
+
+	encodeBase83(&b, linearToSrgb(dc[0])<<16+linearToSrgb(dc[1])<<8+linearToSrgb(dc[2]), 4)
+
+	for _, f := range ac {
+		var q [3]int
+		for n, v := range f {
+			q[n] = max(0, min(18, int(math.Floor(signPow(v/maxValue, 0.5)*9+9.5))))
+		}
+		encodeBase83(&b, q[0]*19*19+q[1]*19+q[2], 2)
+	}
+
+	return b.String(), nil
+}
@@ -0,0 +1,188 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	goimg "image"
+)
+
+// Orientation is an EXIF image orientation
+type Orientation int
+
+// EXIF orientations describing the transform to display an image upright
+const (
+	OrientationNormal Orientation = iota + 1
+	OrientationFlipH
+	OrientationRotate180
+	OrientationFlipV
+	OrientationTranspose
+	OrientationRotate90
+	OrientationTransverse
+	OrientationRotate270
+)
+
+const (
+	exifTagOrientation = 0x0112
+	exifTypeShort      = 3
+)
+
+var exifHeader = []byte("Exif\x00\x00")
+
+// ExifOrientation returns the EXIF orientation of an image file of a media
+// type, and [OrientationNormal] if there is none
+func ExifOrientation(data []byte, mediaType string) Orientation {
+	var tiffData []byte
+	switch mediaType {
+	case MediaTypeJpeg:
+		tiffData = jpegExif(data)
+	case MediaTypeWebp:
+		tiffData = webpExif(data)
+	case MediaTypeTiff:
+		tiffData = data
+	}
+	if tiffData == nil {
+		return OrientationNormal
+	}
+	return tiffOrientation(tiffData)
+}
+
+func jpegExif(data []byte) []byte {
+	if len(data) < 2 || data[0] != 0xff || data[1] != 0xd8 {
+		return nil
+	}
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xff {
+			return nil
+		}
+		marker := data[pos+1]
+		if marker == 0xff {
+			// fill byte
+			pos++
+			continue
+		}
+		if marker == 0x01 || (marker >= 0xd0 && marker <= 0xd7) {
+			// standalone markers without a length
+			pos += 2
+			continue
+		}
+		if marker == 0xda || marker == 0xd9 {
+			// metadata segments precede the start of scan
+			return nil
+		}
+		segLen := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if segLen < 2 || pos+2+segLen > len(data) {
+			return nil
+		}
+		seg := data[pos+4 : pos+2+segLen]
+		if marker == 0xe1 && bytes.HasPrefix(seg, exifHeader) {
+			return seg[len(exifHeader):]
+		}
+		pos += 2 + segLen
+	}
+	return nil
+}
+
+func webpExif(data []byte) []byte {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil
+	}
+	pos := 12
+	for pos+8 <= len(data) {
+		fourcc := string(data[pos : pos+4])
+		chunkLen := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		if chunkLen < 0 || pos+8+chunkLen > len(data) {
+			return nil
+		}
+		if fourcc == "EXIF" {
+			return bytes.TrimPrefix(data[pos+8:pos+8+chunkLen], exifHeader)
+		}
+		// chunks are padded to an even length
+		pos += 8 + chunkLen + chunkLen%2
+	}
+	return nil
+}
+
+func tiffOrientation(data []byte) Orientation {
+	if len(data) < 8 {
+		return OrientationNormal
+	}
+	var order binary.ByteOrder
+	switch string(data[0:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return OrientationNormal
+	}
+	if order.Uint16(data[2:]) != 42 {
+		return OrientationNormal
+	}
+	ifd := int(order.Uint32(data[4:]))
+	if ifd < 8 || ifd+2 > len(data) {
+		return OrientationNormal
+	}
+	count := int(order.Uint16(data[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(data) {
+			return OrientationNormal
+		}
+		if order.Uint16(data[entry:]) != exifTagOrientation {
+			continue
+		}
+		if order.Uint16(data[entry+2:]) != exifTypeShort {
+			return OrientationNormal
+		}
+		o := Orientation(order.Uint16(data[entry+8:]))
+		if o < OrientationNormal || o > OrientationRotate270 {
+			return OrientationNormal
+		}
+		return o
+	}
+	return OrientationNormal
+}
+
+// orientPoint returns the position of the point x, y in an image of width w
+// and height h after it is transformed by the orientation
+func orientPoint(o Orientation, x, y, w, h int) (int, int) {
+	switch o {
+	case OrientationFlipH:
+		return w - 1 - x, y
+	case OrientationRotate180:
+		return w - 1 - x, h - 1 - y
+	case OrientationFlipV:
+		return x, h - 1 - y
+	case OrientationTranspose:
+		return y, x
+	case OrientationRotate90:
+		return h - 1 - y, x
+	case OrientationTransverse:
+		return h - 1 - y, w - 1 - x
+	case OrientationRotate270:
+		return y, w - 1 - x
+	default:
+		return x, y
+	}
+}
+
+func (i *imageData) Orient(o Orientation) {
+	if o <= OrientationNormal || o > OrientationRotate270 {
+		return
+	}
+	bounds := i.img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	tw, th := w, h
+	if o >= OrientationTranspose {
+		tw, th = h, w
+	}
+	target := goimg.NewNRGBA64(goimg.Rect(0, 0, tw, th))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			tx, ty := orientPoint(o, x, y, w, h)
+			target.Set(tx, ty, i.img.At(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+	i.img = target
+}
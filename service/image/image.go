@@ -12,7 +12,10 @@ import (
 	"io"
 	"net/http"
 
+	"golang.org/x/image/bmp"
 	"golang.org/x/image/draw"
+	"golang.org/x/image/tiff"
+	"golang.org/x/image/webp"
 	"xorkevin.dev/governor"
 	"xorkevin.dev/governor/service/fileloader"
 	"xorkevin.dev/kerrors"
@@ -35,9 +38,11 @@ type (
 		ResizeLimit(width, height int)
 		Crop(x, y, w, h int)
 		ResizeFill(width, height int)
+		Orient(o Orientation)
 		ToJpeg(quality int) (*bytes.Buffer, error)
 		ToPng(level PngCompressionOpt) (*bytes.Buffer, error)
 		ToBase64(quality int) (string, error)
+		ToBlurhash(xComponents, yComponents int) (string, error)
 	}
 
 	imageData struct {
@@ -56,6 +61,9 @@ type (
 	}
 )
 
+// MaxDimension is the max width and height of a decoded image
+const MaxDimension = 8192
+
 // FromImage translates a go image.Image to Image
 func FromImage(img goimg.Image) Image {
 	bounds := img.Bounds()
@@ -113,6 +121,33 @@ func FromGif(file io.Reader) (Image, error) {
 	return FromImage(i), nil
 }
 
+// FromWebp parses a webp file
+func FromWebp(file io.Reader) (Image, error) {
+	i, err := webp.Decode(file)
+	if err != nil {
+		return nil, governor.ErrWithRes(kerrors.WithKind(err, ErrInvalidImage, "Invalid WebP"), http.StatusBadRequest, "", "Invalid WebP image")
+	}
+	return FromImage(i), nil
+}
+
+// FromBmp parses a bmp file
+func FromBmp(file io.Reader) (Image, error) {
+	i, err := bmp.Decode(file)
+	if err != nil {
+		return nil, governor.ErrWithRes(kerrors.WithKind(err, ErrInvalidImage, "Invalid BMP"), http.StatusBadRequest, "", "Invalid BMP image")
+	}
+	return FromImage(i), nil
+}
+
+// FromTiff parses a tiff file
+func FromTiff(file io.Reader) (Image, error) {
+	i, err := tiff.Decode(file)
+	if err != nil {
+		return nil, governor.ErrWithRes(kerrors.WithKind(err, ErrInvalidImage, "Invalid TIFF"), http.StatusBadRequest, "", "Invalid TIFF image")
+	}
+	return FromImage(i), nil
+}
+
 const (
 	// MediaTypeJpeg is the mime type for jpeg images
 	MediaTypeJpeg = "image/jpeg"
@@ -120,17 +155,81 @@ const (
 	MediaTypePng = "image/png"
 	// MediaTypeGif is the mime type for gif images
 	MediaTypeGif = "image/gif"
+	// MediaTypeWebp is the mime type for webp images
+	MediaTypeWebp = "image/webp"
+	// MediaTypeBmp is the mime type for bmp images
+	MediaTypeBmp = "image/bmp"
+	// MediaTypeTiff is the mime type for tiff images
+	MediaTypeTiff = "image/tiff"
 )
 
 var allowedMediaTypes = map[string]struct{}{
 	MediaTypePng:  {},
 	MediaTypeJpeg: {},
 	MediaTypeGif:  {},
+	MediaTypeWebp: {},
+	MediaTypeBmp:  {},
+	MediaTypeTiff: {},
+}
+
+// IsSupportedMediaType returns whether an image media type may be decoded
+func IsSupportedMediaType(mediaType string) bool {
+	_, ok := allowedMediaTypes[mediaType]
+	return ok
 }
 
-// LoadImage returns an image file from a Context
-func LoadImage(c *governor.Context, formField string) (_ Image, retErr error) {
-	file, mediaType, _, err := fileloader.LoadOpenFile(c, formField, allowedMediaTypes)
+// FromReader parses an image file of a media type that is at most maxSize
+// bytes
+//
+// The image is rotated and flipped upright according to its EXIF orientation.
+// All other file metadata is discarded when decoding, hence images encoded
+// from the returned image are stripped of metadata.
+func FromReader(file io.Reader, mediaType string, maxSize int64) (Image, error) {
+	if !IsSupportedMediaType(mediaType) {
+		return nil, governor.ErrWithRes(kerrors.WithKind(nil, fileloader.ErrUnsupportedMIME, "Unsupported MIME type"), http.StatusUnsupportedMediaType, "", mediaType+" is unsupported")
+	}
+	// read one byte past the max size in order to detect larger files
+	data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to read image file")
+	}
+	if int64(len(data)) > maxSize {
+		return nil, governor.ErrWithRes(nil, http.StatusRequestEntityTooLarge, "", "Image is too large")
+	}
+	// a small compressed file may decode into a very large image, hence the
+	// dimensions must be checked before decoding
+	cfg, _, err := goimg.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, governor.ErrWithRes(kerrors.WithKind(err, ErrInvalidImage, "Invalid image"), http.StatusBadRequest, "", "Invalid image")
+	}
+	if cfg.Width > MaxDimension || cfg.Height > MaxDimension {
+		return nil, governor.ErrWithRes(nil, http.StatusRequestEntityTooLarge, "", "Image dimensions are too large")
+	}
+	var img Image
+	switch mediaType {
+	case MediaTypeJpeg:
+		img, err = FromJpeg(bytes.NewReader(data))
+	case MediaTypePng:
+		img, err = FromPng(bytes.NewReader(data))
+	case MediaTypeGif:
+		img, err = FromGif(bytes.NewReader(data))
+	case MediaTypeWebp:
+		img, err = FromWebp(bytes.NewReader(data))
+	case MediaTypeBmp:
+		img, err = FromBmp(bytes.NewReader(data))
+	case MediaTypeTiff:
+		img, err = FromTiff(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, err
+	}
+	img.Orient(ExifOrientation(data, mediaType))
+	return img, nil
+}
+
+// LoadImage returns an image file of at most maxSize bytes from a Context
+func LoadImage(c *governor.Context, formField string, maxSize int64) (_ Image, retErr error) {
+	file, mediaType, size, err := fileloader.LoadOpenFile(c, formField, allowedMediaTypes)
 	if err != nil {
 		return nil, kerrors.WithMsg(err, "Invalid image file")
 	}
@@ -139,16 +238,10 @@ func LoadImage(c *governor.Context, formField string) (_ Image, retErr error) {
 			retErr = errors.Join(retErr, kerrors.WithMsg(err, "Failed to close open file on request"))
 		}
 	}()
-	switch mediaType {
-	case MediaTypeJpeg:
-		return FromJpeg(file)
-	case MediaTypePng:
-		return FromPng(file)
-	case MediaTypeGif:
-		return FromGif(file)
-	default:
-		return nil, governor.ErrWithUnreachable(nil, "Invalid file type "+mediaType)
+	if size > maxSize {
+		return nil, governor.ErrWithRes(nil, http.StatusRequestEntityTooLarge, "", "Image is too large")
 	}
+	return FromReader(file, mediaType, maxSize)
 }
 
 func (i imageData) ColorModel() color.Model {
@@ -1,9 +1,14 @@
 package image
 
 import (
+	"bytes"
+	goimg "image"
+	"image/color"
+	"net/http"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"xorkevin.dev/governor"
 )
 
 func TestDimensionsFit(t *testing.T) {
@@ -148,3 +153,192 @@ func TestDimensionsFill(t *testing.T) {
 		})
 	}
 }
+
+func TestOrient(t *testing.T) {
+	t.Parallel()
+
+	red := color.NRGBA64{R: 0xffff, A: 0xffff}
+	blue := color.NRGBA64{B: 0xffff, A: 0xffff}
+
+	for _, tc := range []struct {
+		Test   string
+		O      Orientation
+		Size   Size
+		RedPos goimg.Point
+	}{
+		{
+			Test:   "normal",
+			O:      OrientationNormal,
+			Size:   Size{W: 2, H: 1},
+			RedPos: goimg.Pt(0, 0),
+		},
+		{
+			Test:   "flip horizontal",
+			O:      OrientationFlipH,
+			Size:   Size{W: 2, H: 1},
+			RedPos: goimg.Pt(1, 0),
+		},
+		{
+			Test:   "rotate 90",
+			O:      OrientationRotate90,
+			Size:   Size{W: 1, H: 2},
+			RedPos: goimg.Pt(0, 0),
+		},
+		{
+			Test:   "rotate 270",
+			O:      OrientationRotate270,
+			Size:   Size{W: 1, H: 2},
+			RedPos: goimg.Pt(0, 1),
+		},
+	} {
+		t.Run(tc.Test, func(t *testing.T) {
+			t.Parallel()
+
+			assert := require.New(t)
+
+			src := goimg.NewNRGBA64(goimg.Rect(0, 0, 2, 1))
+			src.SetNRGBA64(0, 0, red)
+			src.SetNRGBA64(1, 0, blue)
+			img := FromImage(src)
+			img.Orient(tc.O)
+			assert.Equal(tc.Size, img.Size())
+			assert.Equal(red, color.NRGBA64Model.Convert(img.At(tc.RedPos.X, tc.RedPos.Y)))
+		})
+	}
+}
+
+func TestExifOrientation(t *testing.T) {
+	t.Parallel()
+
+	tiffLE := []byte{
+		'I', 'I', 42, 0, 8, 0, 0, 0,
+		1, 0,
+		0x12, 0x01, 3, 0, 1, 0, 0, 0, 6, 0, 0, 0,
+		0, 0, 0, 0,
+	}
+	tiffBE := []byte{
+		'M', 'M', 0, 42, 0, 0, 0, 8,
+		0, 1,
+		0x01, 0x12, 0, 3, 0, 0, 0, 1, 0, 8, 0, 0,
+		0, 0, 0, 0,
+	}
+	app1 := append([]byte("Exif\x00\x00"), tiffLE...)
+	jpegData := append([]byte{0xff, 0xd8, 0xff, 0xe1, byte((len(app1) + 2) >> 8), byte(len(app1) + 2)}, app1...)
+	jpegData = append(jpegData, 0xff, 0xda, 0, 2)
+	webpData := append([]byte("RIFF\x00\x00\x00\x00WEBPEXIF"), byte(len(tiffBE)), 0, 0, 0)
+	webpData = append(webpData, tiffBE...)
+
+	assert := require.New(t)
+	assert.Equal(OrientationRotate90, ExifOrientation(tiffLE, MediaTypeTiff))
+	assert.Equal(OrientationRotate270, ExifOrientation(tiffBE, MediaTypeTiff))
+	assert.Equal(OrientationRotate90, ExifOrientation(jpegData, MediaTypeJpeg))
+	assert.Equal(OrientationRotate270, ExifOrientation(webpData, MediaTypeWebp))
+	assert.Equal(OrientationNormal, ExifOrientation(jpegData, MediaTypePng))
+	assert.Equal(OrientationNormal, ExifOrientation([]byte{0xff, 0xd8, 0xff, 0xda}, MediaTypeJpeg))
+}
+
+func TestBlurhash(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	src := goimg.NewNRGBA(goimg.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			src.SetNRGBA(x, y, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+		}
+	}
+	img := FromImage(src)
+
+	hash, err := img.ToBlurhash(4, 3)
+	assert.NoError(err)
+	assert.Len(hash, 1+1+4+2*(4*3-1))
+	// size flag, max ac, then a white dc
+	assert.Equal("L", hash[0:1])
+	assert.Equal("TSUA", hash[2:6])
+
+	hash, err = img.ToBlurhash(1, 1)
+	assert.NoError(err)
+	assert.Equal("00TSUA", hash)
+
+	_, err = img.ToBlurhash(0, 10)
+	assert.ErrorIs(err, ErrEncode)
+}
+
+func TestFromReader(t *testing.T) {
+	t.Parallel()
+
+	src := goimg.NewNRGBA(goimg.Rect(0, 0, 4, 2))
+	b, err := FromImage(src).ToPng(PngBest)
+	require.NoError(t, err)
+	data := b.Bytes()
+
+	b, err = FromImage(goimg.NewNRGBA(goimg.Rect(0, 0, MaxDimension+1, 1))).ToPng(PngBest)
+	require.NoError(t, err)
+	wide := b.Bytes()
+
+	for _, tc := range []struct {
+		Test    string
+		Data    []byte
+		MaxSize int64
+		Status  int
+	}{
+		{
+			Test:    "within max size",
+			Data:    data,
+			MaxSize: int64(len(data)),
+		},
+		{
+			Test:    "exceeds max size",
+			Data:    data,
+			MaxSize: int64(len(data)) - 1,
+			Status:  http.StatusRequestEntityTooLarge,
+		},
+		{
+			Test:    "exceeds max dimensions",
+			Data:    wide,
+			MaxSize: int64(len(wide)),
+			Status:  http.StatusRequestEntityTooLarge,
+		},
+		{
+			Test:    "invalid image",
+			Data:    data[:8],
+			MaxSize: int64(len(data)),
+			Status:  http.StatusBadRequest,
+		},
+	} {
+		t.Run(tc.Test, func(t *testing.T) {
+			t.Parallel()
+
+			assert := require.New(t)
+
+			img, err := FromReader(bytes.NewReader(tc.Data), MediaTypePng, tc.MaxSize)
+			if tc.Status != 0 {
+				var rerr *governor.ErrorRes
+				assert.ErrorAs(err, &rerr)
+				assert.Equal(tc.Status, rerr.Status)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(Size{W: 4, H: 2}, img.Size())
+		})
+	}
+}
+
+func TestFindVariant(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	variants := []Variant{
+		{Name: "sm", Width: 64, Height: 64},
+		{Name: "md", Width: 256, Height: 256},
+	}
+
+	v, ok := FindVariant(variants, "md")
+	assert.True(ok)
+	assert.Equal(variants[1], *v)
+
+	_, ok = FindVariant(variants, "lg")
+	assert.False(ok)
+}
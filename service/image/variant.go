@@ -0,0 +1,88 @@
+package image
+
+import (
+	"bytes"
+	"context"
+	"errors"
+
+	"xorkevin.dev/governor/service/objstore"
+	"xorkevin.dev/kerrors"
+)
+
+type (
+	// Variant is a specification of a resized and encoded image
+	//
+	// If Fill is set, the image is cropped to fill the width and height.
+	// Otherwise it is shrunk to fit within them. MediaType is one of
+	// [MediaTypeJpeg] and [MediaTypePng], and Quality is the jpeg quality.
+	Variant struct {
+		Name      string
+		Width     int
+		Height    int
+		Fill      bool
+		MediaType string
+		Quality   int
+	}
+)
+
+// Encode encodes an image as a media type of either [MediaTypeJpeg] or
+// [MediaTypePng]
+func Encode(img Image, mediaType string, quality int) (*bytes.Buffer, error) {
+	switch mediaType {
+	case MediaTypeJpeg:
+		return img.ToJpeg(quality)
+	case MediaTypePng:
+		return img.ToPng(PngBest)
+	default:
+		return nil, kerrors.WithKind(nil, ErrEncode, "Unsupported encode media type")
+	}
+}
+
+// Apply returns a resized copy of the image according to the variant
+func (v Variant) Apply(img Image) Image {
+	k := img.Duplicate()
+	if v.Fill {
+		k.ResizeFill(v.Width, v.Height)
+	} else {
+		k.ResizeLimit(v.Width, v.Height)
+	}
+	return k
+}
+
+// FindVariant returns the variant with a name
+func FindVariant(variants []Variant, name string) (*Variant, bool) {
+	for _, i := range variants {
+		if i.Name == name {
+			return &i, true
+		}
+	}
+	return nil, false
+}
+
+// PutVariants stores each variant of an image at name in the subdir of the
+// variant name
+func PutVariants(ctx context.Context, d objstore.Dir, name string, img Image, variants []Variant, userMeta map[string]string) error {
+	for _, i := range variants {
+		b, err := Encode(i.Apply(img), i.MediaType, i.Quality)
+		if err != nil {
+			return kerrors.WithMsg(err, "Failed to encode image variant "+i.Name)
+		}
+		if err := d.Subdir(i.Name).Put(ctx, name, i.MediaType, int64(b.Len()), userMeta, b); err != nil {
+			return kerrors.WithMsg(err, "Failed to save image variant "+i.Name)
+		}
+	}
+	return nil
+}
+
+// DelVariants deletes each variant of an image at name, ignoring variants that
+// do not exist
+func DelVariants(ctx context.Context, d objstore.Dir, name string, variants []Variant) error {
+	for _, i := range variants {
+		if err := d.Subdir(i.Name).Del(ctx, name); err != nil {
+			if !errors.Is(err, objstore.ErrNotFound) {
+				return kerrors.WithMsg(err, "Failed to delete image variant "+i.Name)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,500 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-msgauth/authres"
+	"xorkevin.dev/governor/util/dns"
+	"xorkevin.dev/kerrors"
+)
+
+const (
+	headerARCSeal        = "ARC-Seal"
+	headerARCMsgSig      = "ARC-Message-Signature"
+	headerARCAuthResults = "ARC-Authentication-Results"
+	headerAuthResults    = "Authentication-Results"
+
+	arcMaxInstance = 50
+
+	arcChainNone = "none"
+	arcChainPass = "pass"
+	arcChainFail = "fail"
+)
+
+// arcHeaderKeys are the headers signed by arc message signatures, which must
+// not include arc headers
+var arcHeaderKeys = append([]string{"DKIM-Signature"}, dkimHeaderKeys...)
+
+var arcSigTagB = regexp.MustCompile(`(^|;)(\s*b\s*=)[^;]*`)
+
+type (
+	// headerField is a raw message header field including its trailing CRLF
+	headerField struct {
+		key string
+		raw string
+	}
+
+	arcSet struct {
+		aar *headerField
+		ams *headerField
+		as  *headerField
+	}
+)
+
+func isWSP(c rune) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// splitMsg splits a raw message into its header fields and body
+func splitMsg(msg []byte) ([]headerField, []byte, error) {
+	var fields []headerField
+	rest := msg
+	for len(rest) > 0 {
+		line := rest
+		next := len(rest)
+		if k := bytes.IndexByte(rest, '\n'); k >= 0 {
+			line = rest[:k+1]
+			next = k + 1
+		}
+		if len(bytes.TrimRight(line, "\r\n")) == 0 {
+			// empty line separates header from body
+			return fields, rest[next:], nil
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			if len(fields) == 0 {
+				return nil, nil, kerrors.WithKind(nil, ErrInvalidMail, "Malformed mail header")
+			}
+			fields[len(fields)-1].raw += string(line)
+		} else {
+			k, _, ok := bytes.Cut(line, []byte{':'})
+			if !ok {
+				return nil, nil, kerrors.WithKind(nil, ErrInvalidMail, "Malformed mail header")
+			}
+			fields = append(fields, headerField{
+				key: strings.TrimRight(string(k), " \t"),
+				raw: string(line),
+			})
+		}
+		rest = rest[next:]
+	}
+	return fields, nil, nil
+}
+
+func (h headerField) value() string {
+	_, v, _ := strings.Cut(h.raw, ":")
+	return v
+}
+
+// relaxedHeader canonicalizes a header field according to the dkim relaxed
+// header canonicalization algorithm in RFC 6376 section 3.4.2
+func relaxedHeader(raw string) string {
+	k, v, _ := strings.Cut(raw, ":")
+	k = strings.ToLower(strings.TrimRight(k, " \t"))
+	v = strings.Join(strings.FieldsFunc(v, isWSP), " ")
+	return k + ":" + v + "\r\n"
+}
+
+// relaxedBodyHash hashes a body canonicalized according to the dkim relaxed
+// body canonicalization algorithm in RFC 6376 section 3.4.4
+func relaxedBodyHash(body []byte) []byte {
+	lines := strings.Split(string(body), "\n")
+	canon := make([]string, 0, len(lines))
+	for _, i := range lines {
+		i = strings.TrimRight(i, "\r")
+		var b strings.Builder
+		wsp := false
+		for _, c := range i {
+			if c == ' ' || c == '\t' {
+				wsp = true
+				continue
+			}
+			if wsp {
+				b.WriteByte(' ')
+				wsp = false
+			}
+			b.WriteRune(c)
+		}
+		canon = append(canon, b.String())
+	}
+	for len(canon) > 0 && canon[len(canon)-1] == "" {
+		canon = canon[:len(canon)-1]
+	}
+	h := sha256.New()
+	for _, i := range canon {
+		h.Write([]byte(i))
+		h.Write([]byte("\r\n"))
+	}
+	return h.Sum(nil)
+}
+
+// parseTagList parses a dkim tag list, removing all whitespace from values
+func parseTagList(v string) map[string]string {
+	tags := map[string]string{}
+	for _, i := range strings.Split(v, ";") {
+		k, v, ok := strings.Cut(i, "=")
+		if !ok {
+			continue
+		}
+		tags[strings.TrimSpace(k)] = strings.Join(strings.FieldsFunc(v, isWSP), "")
+	}
+	return tags
+}
+
+func stripSigValue(raw string) string {
+	k, v, _ := strings.Cut(raw, ":")
+	return k + ":" + arcSigTagB.ReplaceAllString(v, "$1$2")
+}
+
+func arcInstance(h headerField) (int, bool) {
+	v := h.value()
+	if strings.EqualFold(h.key, headerARCAuthResults) {
+		// the instance tag is the first tag of the authentication results
+		v, _, _ = strings.Cut(v, ";")
+	}
+	i, err := strconv.Atoi(parseTagList(v)["i"])
+	if err != nil || i < 1 || i > arcMaxInstance {
+		return 0, false
+	}
+	return i, true
+}
+
+// arcSets collects the arc sets of a message in instance order
+func arcSets(fields []headerField) ([]arcSet, error) {
+	sets := map[int]*arcSet{}
+	maxInstance := 0
+	for n := range fields {
+		h := &fields[n]
+		var slot func(s *arcSet) **headerField
+		switch {
+		case strings.EqualFold(h.key, headerARCAuthResults):
+			slot = func(s *arcSet) **headerField { return &s.aar }
+		case strings.EqualFold(h.key, headerARCMsgSig):
+			slot = func(s *arcSet) **headerField { return &s.ams }
+		case strings.EqualFold(h.key, headerARCSeal):
+			slot = func(s *arcSet) **headerField { return &s.as }
+		default:
+			continue
+		}
+		i, ok := arcInstance(*h)
+		if !ok {
+			return nil, kerrors.WithKind(nil, ErrInvalidMail, "Invalid arc instance")
+		}
+		s, ok := sets[i]
+		if !ok {
+			s = &arcSet{}
+			sets[i] = s
+		}
+		f := slot(s)
+		if *f != nil {
+			return nil, kerrors.WithKind(nil, ErrInvalidMail, "Duplicate arc header")
+		}
+		*f = h
+		maxInstance = max(maxInstance, i)
+	}
+	if len(sets) != maxInstance {
+		return nil, kerrors.WithKind(nil, ErrInvalidMail, "Missing arc set")
+	}
+	res := make([]arcSet, 0, maxInstance)
+	for i := 1; i <= maxInstance; i++ {
+		s := sets[i]
+		if s.aar == nil || s.ams == nil || s.as == nil {
+			return nil, kerrors.WithKind(nil, ErrInvalidMail, "Incomplete arc set")
+		}
+		res = append(res, *s)
+	}
+	return res, nil
+}
+
+// selectHeaders returns the canonicalized headers to sign for a list of header
+// keys, where repeated keys select instances from the bottom up as in RFC 6376
+// section 5.4.2
+func selectHeaders(fields []headerField, keys []string) string {
+	used := map[int]struct{}{}
+	var b strings.Builder
+	for _, k := range keys {
+		for n := len(fields) - 1; n >= 0; n-- {
+			if _, ok := used[n]; ok {
+				continue
+			}
+			if strings.EqualFold(fields[n].key, k) {
+				used[n] = struct{}{}
+				b.WriteString(relaxedHeader(fields[n].raw))
+				break
+			}
+		}
+	}
+	return b.String()
+}
+
+func hasHeader(fields []headerField, key string) bool {
+	for _, i := range fields {
+		if strings.EqualFold(i.key, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// sigHashInput returns the canonicalized signature header with an empty
+// signature value and without the trailing CRLF
+func sigHashInput(raw string) string {
+	return strings.TrimSuffix(relaxedHeader(stripSigValue(raw)), "\r\n")
+}
+
+func arcSealHashInput(sets []arcSet) string {
+	var b strings.Builder
+	for n, i := range sets {
+		b.WriteString(relaxedHeader(i.aar.raw))
+		b.WriteString(relaxedHeader(i.ams.raw))
+		if n == len(sets)-1 {
+			b.WriteString(sigHashInput(i.as.raw))
+		} else {
+			b.WriteString(relaxedHeader(i.as.raw))
+		}
+	}
+	return b.String()
+}
+
+// arcSeal returns the message sealed with a new arc set, where the arc
+// authentication results are copied from the authentication results header
+// added by authservid
+func arcSeal(msg []byte, authservid string, key *dkimKey, now time.Time) ([]byte, error) {
+	fields, body, err := splitMsg(msg)
+	if err != nil {
+		return nil, err
+	}
+	var authResults string
+	for _, i := range fields {
+		if !strings.EqualFold(i.key, headerAuthResults) {
+			continue
+		}
+		v := strings.TrimSpace(strings.Join(strings.FieldsFunc(i.value(), isWSP), " "))
+		if id, _, _ := authres.Parse(v); id != authservid {
+			continue
+		}
+		// use the topmost matching header, which is the most recently added
+		authResults = v
+		break
+	}
+	if authResults == "" {
+		return nil, kerrors.WithKind(nil, ErrInvalidMail, "Missing authentication results for arc seal")
+	}
+
+	sets, err := arcSets(fields)
+	if err != nil {
+		return nil, err
+	}
+	cv := arcChainNone
+	if len(sets) > 0 {
+		if len(sets) >= arcMaxInstance {
+			return nil, kerrors.WithKind(nil, ErrInvalidMail, "Max arc instance reached")
+		}
+		if parseTagList(sets[len(sets)-1].as.value())["cv"] == arcChainFail {
+			return nil, kerrors.WithKind(nil, ErrInvalidMail, "Arc chain has failed")
+		}
+		cv = arcChainFail
+		// results are partially parsed on error
+		_, results, _ := authres.Parse(authResults)
+		for _, i := range results {
+			if r, ok := i.(*authres.GenericResult); ok && r.Method == "arc" && r.Value == authres.ResultPass {
+				cv = arcChainPass
+			}
+		}
+	}
+
+	instance := strconv.Itoa(len(sets) + 1)
+	t := strconv.FormatInt(now.Unix(), 10)
+
+	aar := headerField{
+		key: headerARCAuthResults,
+		raw: headerARCAuthResults + ": i=" + instance + "; " + authResults + "\r\n",
+	}
+
+	signedKeys := make([]string, 0, len(arcHeaderKeys))
+	for _, i := range arcHeaderKeys {
+		if hasHeader(fields, i) {
+			signedKeys = append(signedKeys, i)
+		}
+	}
+	amsPrefix := headerARCMsgSig + ": i=" + instance + "; a=" + key.alg + "; c=relaxed/relaxed; d=" + key.domain + "; s=" + key.selector + ";\r\n" +
+		" t=" + t + "; h=" + strings.Join(signedKeys, ":") + ";\r\n" +
+		" bh=" + base64.StdEncoding.EncodeToString(relaxedBodyHash(body)) + ";\r\n" +
+		" b="
+	amsHash := sha256.Sum256([]byte(selectHeaders(fields, signedKeys) + sigHashInput(amsPrefix+"\r\n")))
+	amsSig, err := key.signHash(amsHash[:])
+	if err != nil {
+		return nil, err
+	}
+	ams := headerField{
+		key: headerARCMsgSig,
+		raw: amsPrefix + base64.StdEncoding.EncodeToString(amsSig) + "\r\n",
+	}
+
+	asPrefix := headerARCSeal + ": i=" + instance + "; a=" + key.alg + "; t=" + t + "; cv=" + cv + ";\r\n" +
+		" d=" + key.domain + "; s=" + key.selector + ";\r\n" +
+		" b="
+	as := headerField{
+		key: headerARCSeal,
+		raw: asPrefix + "\r\n",
+	}
+	sets = append(sets, arcSet{aar: &aar, ams: &ams, as: &as})
+	asHash := sha256.Sum256([]byte(arcSealHashInput(sets)))
+	asSig, err := key.signHash(asHash[:])
+	if err != nil {
+		return nil, err
+	}
+	as.raw = asPrefix + base64.StdEncoding.EncodeToString(asSig) + "\r\n"
+
+	var b bytes.Buffer
+	b.Grow(len(as.raw) + len(ams.raw) + len(aar.raw) + len(msg))
+	b.WriteString(as.raw)
+	b.WriteString(ams.raw)
+	b.WriteString(aar.raw)
+	b.Write(msg)
+	return b.Bytes(), nil
+}
+
+func lookupARCKey(ctx context.Context, resolver dns.Resolver, domain, selector string) (crypto.PublicKey, error) {
+	txts, err := resolver.LookupTXT(ctx, selector+"._domainkey."+domain)
+	if err != nil {
+		var dnsErr interface{ Temporary() bool }
+		if errors.As(err, &dnsErr) && dnsErr.Temporary() {
+			return nil, kerrors.WithKind(err, errARCTemp{}, "Failed to lookup arc key")
+		}
+		return nil, kerrors.WithKind(err, errARCFail{}, "Failed to lookup arc key")
+	}
+	tags := parseTagList(strings.Join(txts, ""))
+	p, err := base64.StdEncoding.DecodeString(tags["p"])
+	if err != nil || len(p) == 0 {
+		return nil, kerrors.WithKind(err, errARCFail{}, "Invalid arc key")
+	}
+	switch tags["k"] {
+	case "", "rsa":
+		if pub, err := x509.ParsePKIXPublicKey(p); err == nil {
+			if k, ok := pub.(*rsa.PublicKey); ok {
+				return k, nil
+			}
+			return nil, kerrors.WithKind(nil, errARCFail{}, "Invalid arc rsa key")
+		}
+		k, err := x509.ParsePKCS1PublicKey(p)
+		if err != nil {
+			return nil, kerrors.WithKind(err, errARCFail{}, "Invalid arc rsa key")
+		}
+		return k, nil
+	case "ed25519":
+		if len(p) != ed25519.PublicKeySize {
+			return nil, kerrors.WithKind(nil, errARCFail{}, "Invalid arc ed25519 key")
+		}
+		return ed25519.PublicKey(p), nil
+	default:
+		return nil, kerrors.WithKind(nil, errARCFail{}, "Unsupported arc key type")
+	}
+}
+
+func verifyARCSig(ctx context.Context, resolver dns.Resolver, tags map[string]string, hashed []byte) error {
+	sig, err := base64.StdEncoding.DecodeString(tags["b"])
+	if err != nil {
+		return kerrors.WithKind(err, errARCFail{}, "Invalid arc signature")
+	}
+	pub, err := lookupARCKey(ctx, resolver, tags["d"], tags["s"])
+	if err != nil {
+		return err
+	}
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		if tags["a"] != dkimAlgRSA {
+			return kerrors.WithKind(nil, errARCFail{}, "Mismatched arc signature algorithm")
+		}
+		if err := rsa.VerifyPKCS1v15(k, crypto.SHA256, hashed, sig); err != nil {
+			return kerrors.WithKind(err, errARCFail{}, "Invalid arc signature")
+		}
+	case ed25519.PublicKey:
+		if tags["a"] != dkimAlgEd25519 {
+			return kerrors.WithKind(nil, errARCFail{}, "Mismatched arc signature algorithm")
+		}
+		if !ed25519.Verify(k, hashed, sig) {
+			return kerrors.WithKind(nil, errARCFail{}, "Invalid arc signature")
+		}
+	}
+	return nil
+}
+
+// VerifyARC validates the arc chain of a message as described in RFC 8617
+// section 5.2, returning an error describing the reason for a non passing
+// result
+func VerifyARC(ctx context.Context, msg []byte, resolver dns.Resolver) (authres.ResultValue, error) {
+	fields, body, err := splitMsg(msg)
+	if err != nil {
+		return authres.ResultPermError, err
+	}
+	sets, err := arcSets(fields)
+	if err != nil {
+		return authres.ResultFail, err
+	}
+	if len(sets) == 0 {
+		return authres.ResultNone, nil
+	}
+	for n, i := range sets {
+		cv := parseTagList(i.as.value())["cv"]
+		if n == 0 && cv != arcChainNone || n > 0 && cv != arcChainPass {
+			return authres.ResultFail, kerrors.WithKind(nil, errARCFail{}, "Invalid arc chain validation status")
+		}
+	}
+
+	latest := sets[len(sets)-1]
+	amsTags := parseTagList(latest.ams.value())
+	if amsTags["bh"] != base64.StdEncoding.EncodeToString(relaxedBodyHash(body)) {
+		return authres.ResultFail, kerrors.WithKind(nil, errARCFail{}, "Arc body hash mismatch")
+	}
+	var signed []headerField
+	for _, i := range fields {
+		if !strings.EqualFold(i.key, headerARCSeal) {
+			signed = append(signed, i)
+		}
+	}
+	amsHash := sha256.Sum256([]byte(selectHeaders(signed, strings.Split(amsTags["h"], ":")) + sigHashInput(latest.ams.raw)))
+	if err := verifyARCSig(ctx, resolver, amsTags, amsHash[:]); err != nil {
+		if errors.Is(err, errARCTemp{}) {
+			return authres.ResultTempError, err
+		}
+		return authres.ResultFail, err
+	}
+
+	for n := len(sets); n > 0; n-- {
+		asHash := sha256.Sum256([]byte(arcSealHashInput(sets[:n])))
+		if err := verifyARCSig(ctx, resolver, parseTagList(sets[n-1].as.value()), asHash[:]); err != nil {
+			if errors.Is(err, errARCTemp{}) {
+				return authres.ResultTempError, err
+			}
+			return authres.ResultFail, err
+		}
+	}
+	return authres.ResultPass, nil
+}
+
+type (
+	// errARCTemp is returned when arc validation temporarily fails
+	errARCTemp struct{}
+	// errARCFail is returned when arc validation fails
+	errARCFail struct{}
+)
+
+func (e errARCTemp) Error() string {
+	return "Temporary arc validation failure"
+}
+
+func (e errARCFail) Error() string {
+	return "Arc validation failed"
+}
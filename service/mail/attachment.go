@@ -0,0 +1,437 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+
+	emmessage "github.com/emersion/go-message"
+	emmail "github.com/emersion/go-message/mail"
+	"xorkevin.dev/governor/service/objstore"
+	"xorkevin.dev/hunter2/h2streamcipher"
+	"xorkevin.dev/hunter2/h2streamcipher/xchacha20"
+	"xorkevin.dev/kerrors"
+)
+
+const (
+	mediaTypeMultipartMixed       = "multipart/mixed"
+	mediaTypeMultipartRelated     = "multipart/related"
+	mediaTypeMultipartAlternative = "multipart/alternative"
+)
+
+const (
+	dispositionInline     = "inline"
+	dispositionAttachment = "attachment"
+)
+
+type (
+	// Attachment is a mail attachment
+	//
+	// The attachment content is read from Body, or from the object Name in Dir
+	// if Body is nil. ContentType and Size default to those of the object when
+	// unset, and Size of a Body may be left unset if it is unknown. If ContentID
+	// is set, the attachment is inline, and may be referenced by the html body
+	// as cid:ContentID.
+	Attachment struct {
+		Filename    string
+		ContentType string
+		ContentID   string
+		Size        int64
+		Body        io.Reader
+		Dir         objstore.Dir
+		Name        string
+	}
+
+	attachmentData struct {
+		Path        string `json:"path"`
+		Filename    string `json:"filename"`
+		ContentType string `json:"content_type"`
+		ContentID   string `json:"content_id,omitempty"`
+		Key         string `json:"key"`
+		Tag         string `json:"tag"`
+	}
+
+	mailAttachment struct {
+		filename    string
+		contentType string
+		contentID   string
+		body        io.Reader
+	}
+
+	openAttachment struct {
+		att       mailAttachment
+		obj       io.Closer
+		decStream *h2streamcipher.DecStreamReader
+		tag       string
+	}
+)
+
+// AttachmentFromReader creates an attachment read from body
+func AttachmentFromReader(filename, contentType string, size int64, body io.Reader) Attachment {
+	return Attachment{
+		Filename:    filename,
+		ContentType: contentType,
+		Size:        size,
+		Body:        body,
+	}
+}
+
+// AttachmentFromObj creates an attachment read from an objstore object
+func AttachmentFromObj(filename string, d objstore.Dir, name string) Attachment {
+	return Attachment{
+		Filename: filename,
+		Dir:      d,
+		Name:     name,
+	}
+}
+
+// Inline returns the attachment as an inline attachment with a content id
+func (a Attachment) Inline(contentID string) Attachment {
+	a.ContentID = contentID
+	return a
+}
+
+func attachmentPaths(atts []attachmentData) []string {
+	if len(atts) == 0 {
+		return nil
+	}
+	paths := make([]string, 0, len(atts))
+	for _, i := range atts {
+		paths = append(paths, i.Path)
+	}
+	return paths
+}
+
+func (s *Service) putAttachment(ctx context.Context, path string, att Attachment, encrypt bool) (_ *attachmentData, retErr error) {
+	body := att.Body
+	contentType := att.ContentType
+	size := att.Size
+	if body == nil {
+		if att.Dir == nil {
+			return nil, kerrors.WithKind(nil, ErrInvalidMail, "Attachment must have content")
+		}
+		obj, info, err := att.Dir.Get(ctx, att.Name)
+		if err != nil {
+			if errors.Is(err, objstore.ErrNotFound) {
+				return nil, kerrors.WithKind(err, ErrInvalidMail, "Attachment content not found")
+			}
+			return nil, kerrors.WithMsg(err, "Failed to get attachment content")
+		}
+		defer func() {
+			if err := obj.Close(); err != nil {
+				retErr = errors.Join(retErr, kerrors.WithMsg(err, "Failed to close attachment content"))
+			}
+		}()
+		body = obj
+		if contentType == "" {
+			contentType = info.ContentType
+		}
+		size = info.Size
+	}
+	if contentType == "" {
+		contentType = mediaTypeOctet
+	}
+	if size <= 0 {
+		// size is unknown
+		size = -1
+	}
+
+	data := &attachmentData{
+		Path:        path,
+		Filename:    att.Filename,
+		ContentType: contentType,
+		ContentID:   att.ContentID,
+	}
+	storeType := contentType
+
+	var encStream *h2streamcipher.EncStreamReader
+	if encrypt {
+		secrets, err := s.getSecrets(ctx)
+		if err != nil {
+			return nil, err
+		}
+		data.Filename, err = secrets.cipher.Encrypt([]byte(att.Filename))
+		if err != nil {
+			return nil, kerrors.WithMsg(err, "Failed to encrypt attachment filename")
+		}
+
+		storeType = mediaTypeOctet
+		config, err := xchacha20.NewConfig()
+		if err != nil {
+			return nil, kerrors.WithMsg(err, "Failed to create attachment data key")
+		}
+		data.Key, err = secrets.cipher.Encrypt([]byte(config.String()))
+		if err != nil {
+			return nil, kerrors.WithMsg(err, "Failed to encrypt attachment data key")
+		}
+		stream, auth, err := xchacha20.NewFromConfig(*config)
+		if err != nil {
+			return nil, kerrors.WithMsg(err, "Failed to create encryption stream")
+		}
+		encStream = h2streamcipher.NewEncStreamReader(stream, auth, body)
+		body = encStream
+	}
+
+	if err := s.sendAttDir.Put(ctx, path, storeType, size, nil, body); err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to save attachment")
+	}
+	if encStream != nil {
+		if err := encStream.Close(); err != nil {
+			return nil, kerrors.WithMsg(err, "Failed to close encryption stream")
+		}
+		data.Tag = encStream.Tag()
+	}
+	return data, nil
+}
+
+// putAttachments stores attachments to be sent with the mail message at path
+func (s *Service) putAttachments(ctx context.Context, path string, atts []Attachment, encrypt bool) ([]attachmentData, error) {
+	if len(atts) == 0 {
+		return nil, nil
+	}
+	res := make([]attachmentData, 0, len(atts))
+	for n, i := range atts {
+		attpath := path + "/" + strconv.Itoa(n)
+		data, err := s.putAttachment(ctx, attpath, i, encrypt)
+		if err != nil {
+			// the failed attachment may have been partially stored
+			s.delAttachments(ctx, append(attachmentPaths(res), attpath))
+			return nil, err
+		}
+		res = append(res, *data)
+	}
+	return res, nil
+}
+
+// delAttachments makes a best effort attempt to delete the stored attachments
+// of a mail message that will not be sent
+func (s *Service) delAttachments(ctx context.Context, paths []string) {
+	for _, i := range paths {
+		if err := s.sendAttDir.Del(ctx, i); err != nil {
+			if !errors.Is(err, objstore.ErrNotFound) {
+				s.log.Err(ctx, kerrors.WithMsg(err, "Failed to delete mail attachment"))
+			}
+		}
+	}
+}
+
+// openAttachments opens the stored attachments of a mail message. The
+// returned attachments must be closed by the caller, and encrypted
+// attachments must be verified after they are read.
+func (s *Service) openAttachments(ctx context.Context, atts []attachmentData, encrypted bool) (_ []openAttachment, retErr error) {
+	if len(atts) == 0 {
+		return nil, nil
+	}
+	res := make([]openAttachment, 0, len(atts))
+	defer func() {
+		if retErr != nil {
+			if err := closeAttachments(res); err != nil {
+				retErr = errors.Join(retErr, err)
+			}
+		}
+	}()
+	for _, i := range atts {
+		obj, _, err := s.sendAttDir.Get(ctx, i.Path)
+		if err != nil {
+			if errors.Is(err, objstore.ErrNotFound) {
+				return nil, err
+			}
+			return nil, kerrors.WithKind(err, errMailEvent{}, "Failed to get mail attachment")
+		}
+		k := openAttachment{
+			att: mailAttachment{
+				filename:    i.Filename,
+				contentType: i.ContentType,
+				contentID:   i.ContentID,
+				body:        obj,
+			},
+			obj: obj,
+		}
+		res = append(res, k)
+		if encrypted {
+			secrets, err := s.getSecrets(ctx)
+			if err != nil {
+				return nil, err
+			}
+			filename, err := secrets.keyring.Decrypt(i.Filename)
+			if err != nil {
+				return nil, kerrors.WithKind(err, errMailEvent{}, "Failed to decrypt mail attachment filename")
+			}
+			dataKey, err := secrets.keyring.Decrypt(i.Key)
+			if err != nil {
+				return nil, kerrors.WithKind(err, errMailEvent{}, "Failed to decrypt mail attachment data key")
+			}
+			decStream, err := h2streamcipher.NewDecStreamReaderFromParams(string(dataKey), s.streamAlgs, obj)
+			if err != nil {
+				if errors.Is(err, h2streamcipher.ErrKeyInvalid) {
+					return nil, kerrors.WithKind(err, errMailEvent{}, "Failed to parse mail attachment data key")
+				}
+				return nil, kerrors.WithMsg(err, "Failed to create decryption stream")
+			}
+			k.att.filename = string(filename)
+			k.att.body = decStream
+			k.decStream = decStream
+			k.tag = i.Tag
+			res[len(res)-1] = k
+		}
+	}
+	return res, nil
+}
+
+func closeAttachments(atts []openAttachment) error {
+	var errs []error
+	for _, i := range atts {
+		if err := i.obj.Close(); err != nil {
+			errs = append(errs, kerrors.WithMsg(err, "Failed to close mail attachment"))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func verifyAttachments(atts []openAttachment) error {
+	for _, i := range atts {
+		if i.decStream == nil {
+			continue
+		}
+		if err := i.decStream.Close(); err != nil {
+			return kerrors.WithMsg(err, "Failed to close decryption stream")
+		}
+		if ok, err := i.decStream.Verify(i.tag); err != nil {
+			return kerrors.WithKind(err, errMailEvent{}, "Failed to authenticate mail attachment")
+		} else if !ok {
+			return kerrors.WithKind(err, errMailEvent{}, "Mail attachment failed authentication")
+		}
+	}
+	return nil
+}
+
+func writeMsgTextPart(w *emmessage.Writer, contentType string, body io.Reader) (retErr error) {
+	var h emmessage.Header
+	h.SetContentType(contentType, map[string]string{"charset": "utf-8"})
+	h.Set("Content-Transfer-Encoding", "quoted-printable")
+	pw, err := w.CreatePart(h)
+	if err != nil {
+		return kerrors.WithKind(err, errBuildMail{}, "Failed to create mail body part writer")
+	}
+	defer func() {
+		if err := pw.Close(); err != nil {
+			retErr = errors.Join(retErr, kerrors.WithMsg(err, "Failed closing mail body part writer"))
+		}
+	}()
+	if _, err := io.Copy(pw, body); err != nil {
+		return kerrors.WithKind(err, errBuildMail{}, "Failed to write mail body part")
+	}
+	return nil
+}
+
+func writeMsgBody(w *emmessage.Writer, body, htmlbody io.Reader) (retErr error) {
+	if htmlbody == nil {
+		return writeMsgTextPart(w, mediaTypeTextPlain, body)
+	}
+	var h emmessage.Header
+	h.SetContentType(mediaTypeMultipartAlternative, nil)
+	aw, err := w.CreatePart(h)
+	if err != nil {
+		return kerrors.WithKind(err, errBuildMail{}, "Failed to create mail body writer")
+	}
+	defer func() {
+		if err := aw.Close(); err != nil {
+			retErr = errors.Join(retErr, kerrors.WithMsg(err, "Failed closing mail body writer"))
+		}
+	}()
+	if err := writeMsgTextPart(aw, mediaTypeTextPlain, body); err != nil {
+		return err
+	}
+	if err := writeMsgTextPart(aw, mediaTypeTextHTML, htmlbody); err != nil {
+		return err
+	}
+	return nil
+}
+
+func writeMsgAttachment(w *emmessage.Writer, att mailAttachment, disposition string) (retErr error) {
+	var h emmessage.Header
+	h.SetContentType(att.contentType, nil)
+	h.SetContentDisposition(disposition, map[string]string{"filename": att.filename})
+	if att.contentID != "" {
+		h.Set("Content-Id", "<"+att.contentID+">")
+	}
+	h.Set("Content-Transfer-Encoding", "base64")
+	pw, err := w.CreatePart(h)
+	if err != nil {
+		return kerrors.WithKind(err, errBuildMail{}, "Failed to create mail attachment writer")
+	}
+	defer func() {
+		if err := pw.Close(); err != nil {
+			retErr = errors.Join(retErr, kerrors.WithMsg(err, "Failed closing mail attachment writer"))
+		}
+	}()
+	if _, err := io.Copy(pw, att.body); err != nil {
+		return kerrors.WithKind(err, errBuildMail{}, "Failed to write mail attachment")
+	}
+	return nil
+}
+
+func writeMsgRelated(w *emmessage.Writer, body, htmlbody io.Reader, inline []mailAttachment) (retErr error) {
+	var h emmessage.Header
+	h.SetContentType(mediaTypeMultipartRelated, map[string]string{"type": mediaTypeMultipartAlternative})
+	rw, err := w.CreatePart(h)
+	if err != nil {
+		return kerrors.WithKind(err, errBuildMail{}, "Failed to create mail related writer")
+	}
+	defer func() {
+		if err := rw.Close(); err != nil {
+			retErr = errors.Join(retErr, kerrors.WithMsg(err, "Failed closing mail related writer"))
+		}
+	}()
+	if err := writeMsgBody(rw, body, htmlbody); err != nil {
+		return err
+	}
+	for _, i := range inline {
+		if err := writeMsgAttachment(rw, i, dispositionInline); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mixedMsgToBytes writes a multipart/mixed message with attachments, where
+// inline attachments are written with the html body in a multipart/related
+// part
+func mixedMsgToBytes(h emmail.Header, body, htmlbody io.Reader, atts []mailAttachment, dst io.Writer) (retErr error) {
+	h.SetContentType(mediaTypeMultipartMixed, nil)
+	mw, err := emmessage.CreateWriter(dst, h.Header)
+	if err != nil {
+		return kerrors.WithKind(err, errBuildMail{}, "Failed to create mail writer")
+	}
+	defer func() {
+		if err := mw.Close(); err != nil {
+			retErr = errors.Join(retErr, kerrors.WithMsg(err, "Failed closing mail writer"))
+		}
+	}()
+
+	var inline, attached []mailAttachment
+	for _, i := range atts {
+		if i.contentID != "" && htmlbody != nil {
+			inline = append(inline, i)
+		} else {
+			attached = append(attached, i)
+		}
+	}
+
+	if len(inline) > 0 {
+		if err := writeMsgRelated(mw, body, htmlbody, inline); err != nil {
+			return err
+		}
+	} else {
+		if err := writeMsgBody(mw, body, htmlbody); err != nil {
+			return err
+		}
+	}
+	for _, i := range attached {
+		if err := writeMsgAttachment(mw, i, dispositionAttachment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
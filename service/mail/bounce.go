@@ -0,0 +1,223 @@
+package mail
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net/textproto"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/emersion/go-message"
+	"xorkevin.dev/governor/service/mail/suppressionmodel"
+	"xorkevin.dev/kerrors"
+)
+
+const (
+	mediaTypeMultipartReport      = "multipart/report"
+	mediaTypeDeliveryStatus       = "message/delivery-status"
+	mediaTypeGlobalDeliveryStatus = "message/global-delivery-status"
+	mediaTypeFeedbackReport       = "message/feedback-report"
+)
+
+const (
+	reportKindDSN = "dsn"
+	reportKindARF = "arf"
+)
+
+const (
+	dsnActionFailed = "failed"
+	// lengthCapReason is the max length of a suppression reason
+	lengthCapReason = 4095
+)
+
+type (
+	// dsnRecipient is a per-recipient delivery status notification
+	//
+	// See RFC 3464 section 2.3
+	dsnRecipient struct {
+		FinalRecipient string
+		Action         string
+		Status         string
+		Diagnostic     string
+	}
+
+	// bounceReport is a parsed delivery status notification or abuse feedback
+	// report
+	bounceReport struct {
+		Kind         string
+		Recipients   []dsnRecipient
+		FeedbackType string
+	}
+
+	// errBounceReport is returned when a message is not a valid report
+	errBounceReport struct{}
+)
+
+func (e errBounceReport) Error() string {
+	return "Invalid bounce report"
+}
+
+// firstToken returns the first whitespace separated token of a field,
+// stripping any trailing comments
+func firstToken(v string) string {
+	f := strings.Fields(v)
+	if len(f) == 0 {
+		return ""
+	}
+	return f[0]
+}
+
+// stripAddrType removes the address type prefix from an address field of the
+// form addr-type;address
+func stripAddrType(v string) string {
+	if _, addr, ok := strings.Cut(v, ";"); ok {
+		return strings.TrimSpace(addr)
+	}
+	return strings.TrimSpace(v)
+}
+
+func readReportFields(r io.Reader) ([]textproto.MIMEHeader, error) {
+	tr := textproto.NewReader(bufio.NewReader(r))
+	var blocks []textproto.MIMEHeader
+	for {
+		h, err := tr.ReadMIMEHeader()
+		if len(h) > 0 {
+			blocks = append(blocks, h)
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return blocks, nil
+			}
+			return nil, kerrors.WithKind(err, errBounceReport{}, "Failed to parse report fields")
+		}
+	}
+}
+
+func parseDSN(r io.Reader) ([]dsnRecipient, error) {
+	blocks, err := readReportFields(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(blocks) < 2 {
+		return nil, kerrors.WithKind(nil, errBounceReport{}, "Delivery status has no recipients")
+	}
+	// first block contains the per-message fields
+	rcpts := make([]dsnRecipient, 0, len(blocks)-1)
+	for _, i := range blocks[1:] {
+		rcpts = append(rcpts, dsnRecipient{
+			FinalRecipient: stripAddrType(i.Get("Final-Recipient")),
+			Action:         strings.ToLower(firstToken(i.Get("Action"))),
+			Status:         firstToken(i.Get("Status")),
+			Diagnostic:     strings.TrimSpace(i.Get("Diagnostic-Code")),
+		})
+	}
+	return rcpts, nil
+}
+
+func parseFeedbackType(r io.Reader) (string, error) {
+	blocks, err := readReportFields(r)
+	if err != nil {
+		return "", err
+	}
+	if len(blocks) == 0 {
+		return "", kerrors.WithKind(nil, errBounceReport{}, "Empty feedback report")
+	}
+	return strings.ToLower(firstToken(blocks[0].Get("Feedback-Type"))), nil
+}
+
+// parseBounceReport parses a multipart/report message containing either a
+// delivery status notification (RFC 3464) or an abuse feedback report (RFC
+// 5965)
+func parseBounceReport(r io.Reader) (*bounceReport, error) {
+	m, err := message.Read(r)
+	if err != nil && !message.IsUnknownCharset(err) {
+		return nil, kerrors.WithKind(err, errBounceReport{}, "Failed to parse report message")
+	}
+	contentType, _, err := m.Header.ContentType()
+	if err != nil {
+		return nil, kerrors.WithKind(err, errBounceReport{}, "Failed to parse report content type")
+	}
+	if contentType != mediaTypeMultipartReport {
+		return nil, kerrors.WithKind(nil, errBounceReport{}, "Message is not a report")
+	}
+	mr := m.MultipartReader()
+	if mr == nil {
+		return nil, kerrors.WithKind(nil, errBounceReport{}, "Malformed multipart report")
+	}
+	for {
+		p, err := mr.NextPart()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, kerrors.WithKind(nil, errBounceReport{}, "Report has no machine readable part")
+			}
+			if !message.IsUnknownCharset(err) {
+				return nil, kerrors.WithKind(err, errBounceReport{}, "Failed to read report part")
+			}
+		}
+		partType, _, err := p.Header.ContentType()
+		if err != nil {
+			continue
+		}
+		switch partType {
+		case mediaTypeDeliveryStatus, mediaTypeGlobalDeliveryStatus:
+			rcpts, err := parseDSN(p.Body)
+			if err != nil {
+				return nil, err
+			}
+			return &bounceReport{
+				Kind:       reportKindDSN,
+				Recipients: rcpts,
+			}, nil
+		case mediaTypeFeedbackReport:
+			feedbackType, err := parseFeedbackType(p.Body)
+			if err != nil {
+				return nil, err
+			}
+			return &bounceReport{
+				Kind:         reportKindARF,
+				FeedbackType: feedbackType,
+			}, nil
+		}
+	}
+}
+
+// suppression returns the suppression kind and reason if the report indicates
+// that the recipient should no longer be sent mail
+//
+// Only permanent delivery failures and complaints result in a suppression.
+// Transient failures and delays are retried by the sending mta.
+func (r *bounceReport) suppression() (string, string, bool) {
+	switch r.Kind {
+	case reportKindDSN:
+		for _, i := range r.Recipients {
+			if i.Action != dsnActionFailed || !strings.HasPrefix(i.Status, "5.") {
+				continue
+			}
+			reason := i.Status
+			if i.Diagnostic != "" {
+				reason += " " + i.Diagnostic
+			}
+			return suppressionmodel.KindBounce, truncateReason(reason), true
+		}
+	case reportKindARF:
+		switch r.FeedbackType {
+		case "", "not-spam", "auth-failure":
+		default:
+			return suppressionmodel.KindComplaint, truncateReason("Feedback-Type: " + r.FeedbackType), true
+		}
+	}
+	return "", "", false
+}
+
+func truncateReason(reason string) string {
+	if len(reason) <= lengthCapReason {
+		return reason
+	}
+	// truncate on a rune boundary so as not to store invalid utf8
+	n := lengthCapReason
+	for n > 0 && !utf8.RuneStart(reason[n]) {
+		n--
+	}
+	return reason[:n]
+}
@@ -0,0 +1,201 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/netip"
+
+	"github.com/emersion/go-smtp"
+	"xorkevin.dev/governor/service/dbsql"
+	"xorkevin.dev/kerrors"
+	"xorkevin.dev/klog"
+)
+
+var (
+	errSMTPBase = &smtp.SMTPError{
+		Code:         451,
+		EnhancedCode: smtp.EnhancedCode{4, 0, 0},
+		Message:      "Temporary error",
+	}
+	errSMTPConn = &smtp.SMTPError{
+		Code:         451,
+		EnhancedCode: smtp.EnhancedCode{4, 0, 0},
+		Message:      "Invalid client ip address",
+	}
+	errSMTPMailbox = &smtp.SMTPError{
+		Code:         550,
+		EnhancedCode: smtp.EnhancedCode{5, 1, 1},
+		Message:      "Invalid recipient mailbox",
+	}
+	errSMTPSeq = &smtp.SMTPError{
+		Code:         503,
+		EnhancedCode: smtp.EnhancedCode{5, 5, 1},
+		Message:      "Invalid command sequence",
+	}
+)
+
+func (s *Service) createBounceServer() *smtp.Server {
+	be := &bounceBackend{
+		service: s,
+		log:     klog.NewLevelLogger(s.log.Logger.Sublogger("bounceserver")),
+	}
+	return NewSMTPServer(be, SMTPServerOpts{
+		Port:         s.bounce.port,
+		Domain:       s.bounce.domain,
+		MaxMsgSize:   s.bounce.maxmsgsize,
+		ReadTimeout:  s.bounce.readtimeout,
+		WriteTimeout: s.bounce.writetimeout,
+	})
+}
+
+type bounceBackend struct {
+	service *Service
+	log     *klog.LevelLogger
+}
+
+func (s *bounceBackend) NewSession(c *smtp.Conn) (smtp.Session, error) {
+	ctx := klog.CtxWithAttrs(context.Background(),
+		klog.AString("smtp.cmd", "helo"),
+	)
+	addrport, err := netip.ParseAddrPort(c.Conn().RemoteAddr().String())
+	if err != nil {
+		s.log.WarnErr(ctx, kerrors.WithMsg(err, "Failed to parse smtp remote addr"),
+			klog.AString("smtp.remoteaddr", c.Conn().RemoteAddr().String()),
+		)
+		return nil, errSMTPConn
+	}
+	ctx = klog.CtxWithAttrs(ctx,
+		klog.AString("smtp.session.ip", addrport.Addr().String()),
+		klog.AString("smtp.session.helo", c.Hostname()),
+	)
+	return &bounceSession{
+		service: s.service,
+		log:     klog.NewLevelLogger(s.log.Logger.Sublogger("session")),
+		ctx:     ctx,
+	}, nil
+}
+
+type bounceSession struct {
+	service *Service
+	log     *klog.LevelLogger
+	ctx     context.Context
+	id      string
+	from    string
+	hasFrom bool
+	tag     string
+	rcpt    string
+}
+
+func (s *bounceSession) Mail(from string, opts *smtp.MailOptions) error {
+	// bounces are sent with a null return path, so any return path is accepted
+	s.id = s.service.tracer.LReqID()
+	s.from = from
+	s.hasFrom = true
+	return nil
+}
+
+func (s *bounceSession) Rcpt(to string, _ *smtp.RcptOptions) error {
+	ctx := klog.CtxWithAttrs(s.ctx,
+		klog.AString("smtp.cmd", "rcpt"),
+		klog.AString("reqid", s.id),
+		klog.AString("smtp.rcptto", to),
+	)
+	if !s.hasFrom {
+		s.log.Warn(ctx, "Failed smtp command sequence")
+		return errSMTPSeq
+	}
+	secrets, err := s.service.getSecrets(ctx)
+	if err != nil {
+		s.log.Err(ctx, kerrors.WithMsg(err, "Failed to get verp keys"))
+		return errSMTPBase
+	}
+	token, err := decodeVERP(secrets.verp, s.service.bounce.prefix, s.service.bounce.domain, to)
+	if err != nil {
+		s.log.WarnErr(ctx, kerrors.WithMsg(err, "Invalid bounce recipient"))
+		return errSMTPMailbox
+	}
+	m, err := s.service.verps.GetByToken(ctx, token)
+	if err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			s.log.WarnErr(ctx, kerrors.WithMsg(err, "Bounce recipient not found"))
+			return errSMTPMailbox
+		}
+		s.log.Err(ctx, kerrors.WithMsg(err, "Failed to get bounce recipient"))
+		return errSMTPBase
+	}
+	s.tag = m.Tag
+	s.rcpt = m.Rcpt
+	return nil
+}
+
+func (s *bounceSession) Data(r io.Reader) error {
+	ctx := klog.CtxWithAttrs(s.ctx,
+		klog.AString("smtp.cmd", "data"),
+		klog.AString("reqid", s.id),
+		klog.AString("smtp.from", s.from),
+		klog.AString("mail.verp.tag", s.tag),
+		klog.AString("mail.verp.rcpt", s.rcpt),
+	)
+	if !s.hasFrom || s.rcpt == "" {
+		s.log.Warn(ctx, "Failed smtp command sequence")
+		return errSMTPSeq
+	}
+
+	var b bytes.Buffer
+	if _, err := io.Copy(&b, r); err != nil {
+		s.log.WarnErr(ctx, kerrors.WithMsg(err, "Failed to read smtp data"))
+		return errSMTPBase
+	}
+	report, err := parseBounceReport(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		// auto replies and other non-report mail sent to the return path are
+		// accepted and dropped to avoid generating backscatter
+		s.log.Info(ctx, "Dropped non-report mail to return path",
+			klog.AString("reason", err.Error()),
+		)
+		return nil
+	}
+	kind, reason, ok := report.suppression()
+	if !ok {
+		s.log.Info(ctx, "Received non-permanent bounce report",
+			klog.AString("mail.report.kind", report.Kind),
+		)
+		return nil
+	}
+	if err := s.service.suppress(ctx, s.rcpt, kind, reason); err != nil {
+		s.log.Err(ctx, err)
+		return errSMTPBase
+	}
+	s.log.Info(ctx, "Suppressed mail address",
+		klog.AString("mail.report.kind", report.Kind),
+		klog.AString("mail.suppression.kind", kind),
+	)
+	return nil
+}
+
+func (s *bounceSession) Reset() {
+	s.id = ""
+	s.from = ""
+	s.hasFrom = false
+	s.tag = ""
+	s.rcpt = ""
+}
+
+func (s *bounceSession) Logout() error {
+	return nil
+}
+
+func (s *Service) suppress(ctx context.Context, address, kind, reason string) error {
+	m := s.suppressions.New(address, kind, reason)
+	if err := s.suppressions.Insert(ctx, m); err != nil {
+		if !errors.Is(err, dbsql.ErrUnique) {
+			return kerrors.WithMsg(err, "Failed to add suppression")
+		}
+		if err := s.suppressions.Update(ctx, m); err != nil {
+			return kerrors.WithMsg(err, "Failed to update suppression")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,511 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-message"
+	emmail "github.com/emersion/go-message/mail"
+	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/objstore"
+	"xorkevin.dev/governor/service/template"
+	"xorkevin.dev/governor/util/kjson"
+	"xorkevin.dev/governor/util/uid"
+	"xorkevin.dev/kerrors"
+)
+
+const (
+	deliveryModeCatch = "catch"
+)
+
+const (
+	// catcherBackendMem keeps caught msgs in the memory of each instance, and
+	// is only suitable for a single instance deployment since the catcher
+	// routes only return the msgs caught by the instance serving the request
+	catcherBackendMem = "mem"
+	// catcherBackendObj keeps caught msgs in the mail bucket, shared between
+	// all instances
+	catcherBackendObj = "objstore"
+
+	catcherListBatchSize = 256
+)
+
+type (
+	catcherConfig struct {
+		route    string
+		backend  string
+		capacity int
+	}
+
+	caughtMsg struct {
+		id           string
+		retpath      string
+		to           []string
+		raw          []byte
+		creationTime int64
+	}
+
+	// catcherStore holds the most recently sent messages instead of delivering
+	// them, for previewing mail during development
+	catcherStore interface {
+		add(ctx context.Context, m caughtMsg) error
+		list(ctx context.Context, amount, offset int) ([]caughtMsg, error)
+		get(ctx context.Context, id string) (*caughtMsg, bool, error)
+		clear(ctx context.Context) error
+	}
+
+	// mailCatcher holds caught messages in memory
+	mailCatcher struct {
+		mu       *sync.RWMutex
+		msgs     []caughtMsg
+		capacity int
+	}
+)
+
+func newMailCatcher(capacity int) *mailCatcher {
+	return &mailCatcher{
+		mu:       &sync.RWMutex{},
+		capacity: capacity,
+	}
+}
+
+// add stores a message, evicting the oldest message if the catcher is full
+func (c *mailCatcher) add(ctx context.Context, m caughtMsg) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.msgs) >= c.capacity {
+		c.msgs = slices.Delete(c.msgs, 0, len(c.msgs)-c.capacity+1)
+	}
+	c.msgs = append(c.msgs, m)
+	return nil
+}
+
+// list returns messages from newest to oldest
+func (c *mailCatcher) list(ctx context.Context, amount, offset int) ([]caughtMsg, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	res := make([]caughtMsg, 0, amount)
+	for i := len(c.msgs) - 1 - offset; i >= 0 && len(res) < amount; i-- {
+		res = append(res, c.msgs[i])
+	}
+	return res, nil
+}
+
+func (c *mailCatcher) get(ctx context.Context, id string) (*caughtMsg, bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, i := range c.msgs {
+		if i.id == id {
+			return &i, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+func (c *mailCatcher) clear(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.msgs = nil
+	return nil
+}
+
+type (
+	// objCatcher holds caught messages in an object store dir
+	//
+	// Objects are named by their inverted creation time followed by a uid so
+	// that listing objects in lexicographic order returns the newest first.
+	objCatcher struct {
+		dir      objstore.Dir
+		capacity int
+	}
+
+	caughtMsgObj struct {
+		RetPath      string   `json:"retpath"`
+		To           []string `json:"to"`
+		Raw          []byte   `json:"raw"`
+		CreationTime int64    `json:"creation_time"`
+	}
+)
+
+func newObjCatcher(dir objstore.Dir, capacity int) *objCatcher {
+	return &objCatcher{
+		dir:      dir,
+		capacity: capacity,
+	}
+}
+
+// newCaughtMsgID returns an id that sorts newer msgs first
+func newCaughtMsgID(now time.Time) (string, error) {
+	u, err := uid.New()
+	if err != nil {
+		return "", kerrors.WithMsg(err, "Failed to generate caught mail msg id")
+	}
+	return fmt.Sprintf("%016x", uint64(math.MaxInt64-now.UnixMilli())) + u.Base64(), nil
+}
+
+// add stores a message, evicting the oldest messages if the catcher is full
+func (c *objCatcher) add(ctx context.Context, m caughtMsg) error {
+	b, err := kjson.Marshal(caughtMsgObj{
+		RetPath:      m.retpath,
+		To:           m.to,
+		Raw:          m.raw,
+		CreationTime: m.creationTime,
+	})
+	if err != nil {
+		return kerrors.WithMsg(err, "Failed to encode caught mail msg")
+	}
+	if err := c.dir.Put(ctx, m.id, mediaTypeJSON, int64(len(b)), nil, bytes.NewReader(b)); err != nil {
+		return kerrors.WithMsg(err, "Failed to save caught mail msg")
+	}
+	count := 0
+	after := ""
+	for {
+		objs, err := c.dir.List(ctx, "", catcherListBatchSize, after)
+		if err != nil {
+			return kerrors.WithMsg(err, "Failed to list caught mail msgs")
+		}
+		for _, i := range objs {
+			after = i.Name
+			count++
+			if count <= c.capacity {
+				continue
+			}
+			if err := c.dir.Del(ctx, i.Name); err != nil {
+				if !errors.Is(err, objstore.ErrNotFound) {
+					return kerrors.WithMsg(err, "Failed to evict caught mail msg")
+				}
+			}
+		}
+		if len(objs) < catcherListBatchSize {
+			return nil
+		}
+	}
+}
+
+// list returns messages from newest to oldest
+func (c *objCatcher) list(ctx context.Context, amount, offset int) ([]caughtMsg, error) {
+	if offset >= c.capacity {
+		return nil, nil
+	}
+	objs, err := c.dir.List(ctx, "", offset+amount, "")
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to list caught mail msgs")
+	}
+	if offset >= len(objs) {
+		return nil, nil
+	}
+	res := make([]caughtMsg, 0, len(objs)-offset)
+	for _, i := range objs[offset:] {
+		m, ok, err := c.get(ctx, i.Name)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			// msg was evicted or cleared after being listed
+			continue
+		}
+		res = append(res, *m)
+	}
+	return res, nil
+}
+
+func (c *objCatcher) get(ctx context.Context, id string) (_ *caughtMsg, _ bool, retErr error) {
+	if strings.Contains(id, "/") {
+		return nil, false, nil
+	}
+	obj, _, err := c.dir.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, objstore.ErrNotFound) {
+			return nil, false, nil
+		}
+		return nil, false, kerrors.WithMsg(err, "Failed to get caught mail msg")
+	}
+	defer func() {
+		if err := obj.Close(); err != nil {
+			retErr = errors.Join(retErr, kerrors.WithMsg(err, "Failed to close caught mail msg"))
+		}
+	}()
+	b, err := io.ReadAll(obj)
+	if err != nil {
+		return nil, false, kerrors.WithMsg(err, "Failed to read caught mail msg")
+	}
+	var m caughtMsgObj
+	if err := kjson.Unmarshal(b, &m); err != nil {
+		return nil, false, kerrors.WithMsg(err, "Failed to decode caught mail msg")
+	}
+	return &caughtMsg{
+		id:           id,
+		retpath:      m.RetPath,
+		to:           m.To,
+		raw:          m.Raw,
+		creationTime: m.CreationTime,
+	}, true, nil
+}
+
+func (c *objCatcher) clear(ctx context.Context) error {
+	for {
+		objs, err := c.dir.List(ctx, "", catcherListBatchSize, "")
+		if err != nil {
+			return kerrors.WithMsg(err, "Failed to list caught mail msgs")
+		}
+		for _, i := range objs {
+			if err := c.dir.Del(ctx, i.Name); err != nil {
+				if !errors.Is(err, objstore.ErrNotFound) {
+					return kerrors.WithMsg(err, "Failed to delete caught mail msg")
+				}
+			}
+		}
+		if len(objs) < catcherListBatchSize {
+			return nil
+		}
+	}
+}
+
+func (s *Service) catchMsg(ctx context.Context, retpath string, to []string, msg io.Reader) error {
+	b := &bytes.Buffer{}
+	if _, err := io.Copy(b, msg); err != nil {
+		return kerrors.WithMsg(err, "Failed to read mail msg")
+	}
+	now := time.Now().Round(0)
+	id, err := newCaughtMsgID(now)
+	if err != nil {
+		return err
+	}
+	if err := s.catcher.add(ctx, caughtMsg{
+		id:           id,
+		retpath:      retpath,
+		to:           to,
+		raw:          b.Bytes(),
+		creationTime: now.Unix(),
+	}); err != nil {
+		return err
+	}
+	s.log.Info(ctx, "Caught mail msg")
+	return nil
+}
+
+type (
+	msgPreview struct {
+		From    string
+		To      string
+		Subject string
+		Date    string
+		Text    string
+		HTML    string
+	}
+)
+
+// parseMsgPreview extracts the headers and first inline text and html bodies
+// of a message
+func parseMsgPreview(raw []byte) (*msgPreview, error) {
+	r, err := emmail.CreateReader(bytes.NewReader(raw))
+	if err != nil && !message.IsUnknownCharset(err) {
+		return nil, kerrors.WithMsg(err, "Failed to parse mail msg")
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+	preview := &msgPreview{
+		From:    r.Header.Get("From"),
+		To:      r.Header.Get("To"),
+		Subject: r.Header.Get("Subject"),
+		Date:    r.Header.Get("Date"),
+	}
+	if subject, err := r.Header.Subject(); err == nil {
+		preview.Subject = subject
+	}
+	for {
+		p, err := r.NextPart()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if !message.IsUnknownCharset(err) || p == nil {
+				return nil, kerrors.WithMsg(err, "Failed to parse mail msg part")
+			}
+		}
+		h, ok := p.Header.(*emmail.InlineHeader)
+		if !ok {
+			continue
+		}
+		mediaType, _, err := h.ContentType()
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case mediaTypeTextPlain:
+			if preview.Text != "" {
+				continue
+			}
+		case mediaTypeTextHTML:
+			if preview.HTML != "" {
+				continue
+			}
+		default:
+			continue
+		}
+		b, err := io.ReadAll(p.Body)
+		if err != nil {
+			return nil, kerrors.WithMsg(err, "Failed to read mail msg part")
+		}
+		if mediaType == mediaTypeTextPlain {
+			preview.Text = string(b)
+		} else {
+			preview.HTML = string(b)
+		}
+	}
+	return preview, nil
+}
+
+type (
+	resCaughtMsg struct {
+		ID           string   `json:"id"`
+		RetPath      string   `json:"retpath"`
+		To           []string `json:"to"`
+		HeaderFrom   string   `json:"header_from"`
+		HeaderTo     string   `json:"header_to"`
+		Subject      string   `json:"subject"`
+		Date         string   `json:"date"`
+		Size         int      `json:"size"`
+		CreationTime int64    `json:"creation_time"`
+	}
+
+	resCaughtMsgs struct {
+		Msgs []resCaughtMsg `json:"msgs"`
+	}
+
+	resCaughtMsgPreview struct {
+		resCaughtMsg
+		Text string `json:"text"`
+		HTML string `json:"html"`
+	}
+)
+
+func toResCaughtMsg(m caughtMsg, preview *msgPreview) resCaughtMsg {
+	return resCaughtMsg{
+		ID:           m.id,
+		RetPath:      m.retpath,
+		To:           m.to,
+		HeaderFrom:   preview.From,
+		HeaderTo:     preview.To,
+		Subject:      preview.Subject,
+		Date:         preview.Date,
+		Size:         len(m.raw),
+		CreationTime: m.creationTime,
+	}
+}
+
+func (s *Service) getCaughtMsgs(ctx context.Context, amount, offset int) (*resCaughtMsgs, error) {
+	msgs, err := s.catcher.list(ctx, amount, offset)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]resCaughtMsg, 0, len(msgs))
+	for _, i := range msgs {
+		preview, err := parseMsgPreview(i.raw)
+		if err != nil {
+			preview = &msgPreview{}
+		}
+		res = append(res, toResCaughtMsg(i, preview))
+	}
+	return &resCaughtMsgs{
+		Msgs: res,
+	}, nil
+}
+
+func (s *Service) getCaughtMsgRaw(ctx context.Context, id string) ([]byte, error) {
+	m, ok, err := s.catcher.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, governor.ErrWithRes(nil, http.StatusNotFound, "", "Mail msg not found")
+	}
+	return m.raw, nil
+}
+
+func (s *Service) getCaughtMsg(ctx context.Context, id string) (*resCaughtMsgPreview, error) {
+	m, ok, err := s.catcher.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, governor.ErrWithRes(nil, http.StatusNotFound, "", "Mail msg not found")
+	}
+	preview, err := parseMsgPreview(m.raw)
+	if err != nil {
+		return nil, governor.ErrWithRes(err, http.StatusUnprocessableEntity, "", "Malformed mail msg")
+	}
+	return &resCaughtMsgPreview{
+		resCaughtMsg: toResCaughtMsg(*m, preview),
+		Text:         preview.Text,
+		HTML:         preview.HTML,
+	}, nil
+}
+
+func (s *Service) clearCaughtMsgs(ctx context.Context) error {
+	return s.catcher.clear(ctx)
+}
+
+type (
+	resTplPreview struct {
+		Subject string `json:"subject"`
+		Text    string `json:"text"`
+		HTML    string `json:"html"`
+	}
+)
+
+// previewTpl renders a mail template with its sample data
+func (s *Service) previewTpl(ctx context.Context, name string) (*resTplPreview, error) {
+	emdata := map[string]string{}
+	if b, err := s.tpl.Sample(template.KindLocal, name+s.tplSuffix.sample); err != nil {
+		if !errors.Is(err, template.ErrTemplateDNE) {
+			return nil, kerrors.WithMsg(err, "Failed to get mail template sample data")
+		}
+	} else {
+		if err := kjson.Unmarshal(b, &emdata); err != nil {
+			return nil, governor.ErrWithRes(err, http.StatusUnprocessableEntity, "", "Invalid mail template sample data")
+		}
+	}
+
+	subject := &bytes.Buffer{}
+	if err := s.tpl.Execute(subject, template.KindLocal, name+s.tplSuffix.subject, emdata); err != nil {
+		if errors.Is(err, template.ErrTemplateDNE) {
+			return nil, governor.ErrWithRes(err, http.StatusNotFound, "", "Mail template not found")
+		}
+		return nil, governor.ErrWithRes(err, http.StatusUnprocessableEntity, "", "Failed to execute mail subject template")
+	}
+	text := &bytes.Buffer{}
+	if err := s.tpl.Execute(text, template.KindLocal, name+s.tplSuffix.text, emdata); err != nil {
+		if errors.Is(err, template.ErrTemplateDNE) {
+			return nil, governor.ErrWithRes(err, http.StatusNotFound, "", "Mail template not found")
+		}
+		return nil, governor.ErrWithRes(err, http.StatusUnprocessableEntity, "", "Failed to execute mail body template")
+	}
+	html := &bytes.Buffer{}
+	if err := s.tpl.ExecuteHTML(html, template.KindLocal, name+s.tplSuffix.html, emdata); err != nil {
+		if !errors.Is(err, template.ErrTemplateDNE) {
+			return nil, governor.ErrWithRes(err, http.StatusUnprocessableEntity, "", "Failed to execute mail html body template")
+		}
+		html.Reset()
+	}
+	return &resTplPreview{
+		Subject: subject.String(),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
+
+func mediaTypeUTF8(mediaType string) string {
+	return mime.FormatMediaType(mediaType, map[string]string{"charset": "utf-8"})
+}
@@ -0,0 +1,508 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/dbsql"
+	"xorkevin.dev/governor/service/mail/deliverymodel"
+	"xorkevin.dev/governor/service/mail/suppressionmodel"
+	"xorkevin.dev/governor/service/objstore"
+	"xorkevin.dev/governor/util/ksync"
+	"xorkevin.dev/governor/util/uid"
+	"xorkevin.dev/hunter2/h2streamcipher"
+	"xorkevin.dev/hunter2/h2streamcipher/xchacha20"
+	"xorkevin.dev/kerrors"
+	"xorkevin.dev/klog"
+)
+
+const (
+	deliveryModeRelay  = "relay"
+	deliveryModeDirect = "direct"
+)
+
+const (
+	mediaTypeRFC822Headers = "text/rfc822-headers"
+	queueMetaKey           = "key"
+	queueMetaTag           = "tag"
+)
+
+type (
+	directConfig struct {
+		enabled       bool
+		helo          string
+		port          string
+		dialtimeout   time.Duration
+		cmdtimeout    time.Duration
+		connidle      time.Duration
+		retrybase     time.Duration
+		retrymax      time.Duration
+		retrylimit    time.Duration
+		retryinterval time.Duration
+		retrybatch    int
+		lease         time.Duration
+		statusage     time.Duration
+	}
+
+	// queueRcpt is a recipient of a queued message with its return path
+	queueRcpt struct {
+		retpath string
+		rcpt    string
+	}
+)
+
+// deliveryBackoff returns the exponential backoff before the next delivery
+// attempt
+func deliveryBackoff(base, maxInterval time.Duration, attempts int) time.Duration {
+	d := base
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= maxInterval {
+			return maxInterval
+		}
+	}
+	return min(d, maxInterval)
+}
+
+// putQueuedMsg stores a message body in the delivery queue, always encrypted
+// since the message is fully rendered
+func (s *Service) putQueuedMsg(ctx context.Context, tag string, msg []byte) error {
+	secrets, err := s.getSecrets(ctx)
+	if err != nil {
+		return err
+	}
+	config, err := xchacha20.NewConfig()
+	if err != nil {
+		return kerrors.WithMsg(err, "Failed to create mail data key")
+	}
+	key, err := secrets.cipher.Encrypt([]byte(config.String()))
+	if err != nil {
+		return kerrors.WithMsg(err, "Failed to encrypt mail data key")
+	}
+	stream, auth, err := xchacha20.NewFromConfig(*config)
+	if err != nil {
+		return kerrors.WithMsg(err, "Failed to create encryption stream")
+	}
+	b := &bytes.Buffer{}
+	encStream := h2streamcipher.NewEncStreamReader(stream, auth, bytes.NewReader(msg))
+	if _, err := io.Copy(b, encStream); err != nil {
+		return kerrors.WithMsg(err, "Failed to encrypt mail msg")
+	}
+	if err := encStream.Close(); err != nil {
+		return kerrors.WithMsg(err, "Failed to close encryption stream")
+	}
+	if err := s.queueDir.Put(ctx, tag, mediaTypeOctet, int64(b.Len()), map[string]string{
+		queueMetaKey: key,
+		queueMetaTag: encStream.Tag(),
+	}, b); err != nil {
+		return kerrors.WithMsg(err, "Failed to save queued mail msg")
+	}
+	return nil
+}
+
+func (s *Service) getQueuedMsg(ctx context.Context, tag string) (_ []byte, retErr error) {
+	obj, info, err := s.queueDir.Get(ctx, tag)
+	if err != nil {
+		if errors.Is(err, objstore.ErrNotFound) {
+			return nil, err
+		}
+		return nil, kerrors.WithMsg(err, "Failed to get queued mail msg")
+	}
+	defer func() {
+		if err := obj.Close(); err != nil {
+			retErr = errors.Join(retErr, kerrors.WithMsg(err, "Failed to close queued mail msg"))
+		}
+	}()
+	secrets, err := s.getSecrets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := secrets.keyring.Decrypt(info.UserMeta[queueMetaKey])
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to decrypt queued mail data key")
+	}
+	decStream, err := h2streamcipher.NewDecStreamReaderFromParams(string(dataKey), s.streamAlgs, obj)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to create decryption stream")
+	}
+	b := &bytes.Buffer{}
+	if _, err := io.Copy(b, decStream); err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to read queued mail msg")
+	}
+	if err := decStream.Close(); err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to close decryption stream")
+	}
+	if ok, err := decStream.Verify(info.UserMeta[queueMetaTag]); err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to authenticate queued mail msg")
+	} else if !ok {
+		return nil, kerrors.WithMsg(nil, "Queued mail msg failed authentication")
+	}
+	return b.Bytes(), nil
+}
+
+// queueMsg persists a message for direct delivery and makes the first
+// delivery attempt
+//
+// Queueing is idempotent for a tag, so that a redelivered mail event does not
+// resend to recipients which have already been attempted.
+func (s *Service) queueMsg(ctx context.Context, tag string, rcpts []queueRcpt, msg []byte) error {
+	if err := s.putQueuedMsg(ctx, tag, msg); err != nil {
+		return err
+	}
+	m := make([]*deliverymodel.Model, 0, len(rcpts))
+	for _, i := range rcpts {
+		m = append(m, s.deliveries.New(tag, i.rcpt, i.retpath))
+	}
+	if err := s.deliveries.InsertBulk(ctx, m); err != nil {
+		return kerrors.WithMsg(err, "Failed to queue mail deliveries")
+	}
+	now := time.Now().Round(0)
+	claimed, err := s.deliveries.ClaimTag(ctx, tag, now.Unix(), now.Add(s.direct.lease).Unix())
+	if err != nil {
+		return kerrors.WithMsg(err, "Failed to claim mail deliveries")
+	}
+	if err := s.attemptDeliveries(ctx, tag, claimed, msg); err != nil {
+		return err
+	}
+	return nil
+}
+
+type (
+	deliveryGroup struct {
+		domain  string
+		retpath string
+		rows    []*deliverymodel.Model
+	}
+)
+
+// attemptDeliveries attempts delivery of a message to claimed recipients and
+// records the result of each
+func (s *Service) attemptDeliveries(ctx context.Context, tag string, rows []deliverymodel.Model, msg []byte) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	ctx = klog.CtxWithAttrs(ctx,
+		klog.AString("mail.delivery.tag", tag),
+	)
+
+	var groups []*deliveryGroup
+	groupIdx := map[string]int{}
+	for n := range rows {
+		i := &rows[n]
+		k := i.Domain + " " + i.RetPath
+		idx, ok := groupIdx[k]
+		if !ok {
+			idx = len(groups)
+			groupIdx[k] = idx
+			groups = append(groups, &deliveryGroup{
+				domain:  i.Domain,
+				retpath: i.RetPath,
+			})
+		}
+		groups[idx].rows = append(groups[idx].rows, i)
+	}
+
+	for _, g := range groups {
+		rcpts := make([]string, 0, len(g.rows))
+		for _, i := range g.rows {
+			rcpts = append(rcpts, i.Rcpt)
+		}
+		var errs []error
+		if msg == nil {
+			errs = make([]error, len(rcpts))
+			for n := range errs {
+				errs[n] = kerrors.WithKind(nil, errDeliverPerm{}, "Mail msg content not found")
+			}
+		} else {
+			errs = s.deliverMX(ctx, g.domain, g.retpath, rcpts, msg)
+		}
+		for n, i := range g.rows {
+			if err := s.recordDelivery(ctx, i, errs[n], msg); err != nil {
+				return err
+			}
+		}
+	}
+
+	pending, err := s.deliveries.HasPending(ctx, tag)
+	if err != nil {
+		return kerrors.WithMsg(err, "Failed to get pending mail deliveries")
+	}
+	if !pending {
+		if err := s.queueDir.Del(ctx, tag); err != nil {
+			if !errors.Is(err, objstore.ErrNotFound) {
+				return kerrors.WithMsg(err, "Failed to delete queued mail msg")
+			}
+		}
+	}
+	return nil
+}
+
+// recordDelivery updates a delivery with the result of an attempt, scheduling
+// a retry for temporary failures and generating a bounce for permanent
+// failures
+func (s *Service) recordDelivery(ctx context.Context, m *deliverymodel.Model, deliverErr error, msg []byte) error {
+	now := time.Now().Round(0)
+	m.Attempts++
+	m.LastUpdated = now.Unix()
+	ctx = klog.CtxWithAttrs(ctx,
+		klog.AString("mail.delivery.domain", m.Domain),
+		klog.AInt("mail.delivery.attempts", m.Attempts),
+	)
+	bounceStatus := ""
+	if deliverErr == nil {
+		m.Status = deliverymodel.StatusDelivered
+		m.LastError = ""
+	} else {
+		m.LastError = truncateReason(deliverErr.Error())
+		if errors.Is(deliverErr, errDeliverPerm{}) {
+			m.Status = deliverymodel.StatusFailed
+			bounceStatus = smtpErrStatus(deliverErr)
+		} else if now.Sub(time.Unix(m.CreationTime, 0)) >= s.direct.retrylimit {
+			m.Status = deliverymodel.StatusFailed
+			m.LastError = truncateReason("Retry limit exceeded: " + m.LastError)
+			// delivery time expired
+			bounceStatus = "5.4.7"
+		} else {
+			m.Status = deliverymodel.StatusPending
+			m.NextAttempt = now.Add(deliveryBackoff(s.direct.retrybase, s.direct.retrymax, m.Attempts)).Unix()
+		}
+	}
+	if err := s.deliveries.Update(ctx, m); err != nil {
+		return kerrors.WithMsg(err, "Failed to update mail delivery")
+	}
+	switch m.Status {
+	case deliverymodel.StatusDelivered:
+		s.log.Info(ctx, "Mail delivery succeeded")
+	case deliverymodel.StatusPending:
+		s.log.WarnErr(ctx, kerrors.WithMsg(deliverErr, "Mail delivery deferred"),
+			klog.AInt64("mail.delivery.next", m.NextAttempt),
+		)
+	default:
+		s.log.WarnErr(ctx, kerrors.WithMsg(deliverErr, "Mail delivery failed"))
+	}
+	if bounceStatus != "" {
+		if err := s.bounceDelivery(ctx, m, bounceStatus, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bounceDelivery reports a permanently failed delivery to its return path
+//
+// Failures to a verp return path of this service suppress the recipient
+// directly instead of sending a delivery status notification to itself. Mail
+// with a null return path is never bounced.
+func (s *Service) bounceDelivery(ctx context.Context, m *deliverymodel.Model, status string, msg []byte) error {
+	if m.RetPath == "" {
+		return nil
+	}
+	if s.bounce.domain != "" {
+		secrets, err := s.getSecrets(ctx)
+		if err != nil {
+			return err
+		}
+		if token, err := decodeVERP(secrets.verp, s.bounce.prefix, s.bounce.domain, m.RetPath); err == nil {
+			v, err := s.verps.GetByToken(ctx, token)
+			if err != nil {
+				if errors.Is(err, dbsql.ErrNotFound) {
+					// the verp recipient has expired, and the failure cannot be
+					// attributed to a recipient
+					return nil
+				}
+				return kerrors.WithMsg(err, "Failed to get verp recipient")
+			}
+			if err := s.suppress(ctx, v.Rcpt, suppressionmodel.KindBounce, m.LastError); err != nil {
+				return err
+			}
+			return nil
+		}
+	}
+	u, err := uid.New()
+	if err != nil {
+		return kerrors.WithMsg(err, "Failed to generate bounce msg tag")
+	}
+	msgid, err := genMsgID(s.msgiddomain)
+	if err != nil {
+		return err
+	}
+	b := &bytes.Buffer{}
+	if err := buildDSN(msgid, s.fromAddress, s.direct.helo, m, status, msg, b); err != nil {
+		return err
+	}
+	if err := s.queueMsg(ctx, u.Base64(), []queueRcpt{{retpath: "", rcpt: m.RetPath}}, b.Bytes()); err != nil {
+		return kerrors.WithMsg(err, "Failed to queue bounce msg")
+	}
+	return nil
+}
+
+// buildDSN builds a delivery status notification as per RFC 3464 for a
+// failed delivery
+func buildDSN(msgid string, from string, mta string, m *deliverymodel.Model, status string, msg []byte, dst io.Writer) error {
+	boundary, err := uid.New()
+	if err != nil {
+		return kerrors.WithMsg(err, "Failed to generate mime boundary")
+	}
+	diag := strings.ReplaceAll(m.LastError, "\n", " ")
+	var headers []byte
+	if msg != nil {
+		if i := bytes.Index(msg, []byte("\r\n\r\n")); i >= 0 {
+			headers = msg[:i+2]
+		} else {
+			headers = msg
+		}
+	}
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "From: Mail Delivery System <%s>\r\n", from)
+	fmt.Fprintf(b, "To: <%s>\r\n", m.RetPath)
+	b.WriteString("Subject: Undelivered Mail Returned to Sender\r\n")
+	fmt.Fprintf(b, "Date: %s\r\n", time.Now().Round(0).UTC().Format(time.RFC1123Z))
+	fmt.Fprintf(b, "Message-Id: <%s>\r\n", msgid)
+	b.WriteString("Auto-Submitted: auto-replied\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(b, "Content-Type: %s; report-type=delivery-status; boundary=\"%s\"\r\n", mediaTypeMultipartReport, boundary.Base64())
+	b.WriteString("\r\n")
+	fmt.Fprintf(b, "--%s\r\n", boundary.Base64())
+	fmt.Fprintf(b, "Content-Type: %s; charset=utf-8\r\n\r\n", mediaTypeTextPlain)
+	fmt.Fprintf(b, "Your message could not be delivered to <%s>.\r\n\r\n%s\r\n\r\n", m.Rcpt, diag)
+	fmt.Fprintf(b, "--%s\r\n", boundary.Base64())
+	fmt.Fprintf(b, "Content-Type: %s\r\n\r\n", mediaTypeDeliveryStatus)
+	fmt.Fprintf(b, "Reporting-MTA: dns; %s\r\n", mta)
+	fmt.Fprintf(b, "Arrival-Date: %s\r\n\r\n", time.Unix(m.CreationTime, 0).UTC().Format(time.RFC1123Z))
+	fmt.Fprintf(b, "Final-Recipient: rfc822; %s\r\n", m.Rcpt)
+	b.WriteString("Action: failed\r\n")
+	fmt.Fprintf(b, "Status: %s\r\n", status)
+	fmt.Fprintf(b, "Diagnostic-Code: smtp; %s\r\n", diag)
+	if len(headers) > 0 {
+		b.WriteString("\r\n")
+		fmt.Fprintf(b, "--%s\r\n", boundary.Base64())
+		fmt.Fprintf(b, "Content-Type: %s\r\n\r\n", mediaTypeRFC822Headers)
+		b.Write(headers)
+	}
+	fmt.Fprintf(b, "\r\n--%s--\r\n", boundary.Base64())
+	if _, err := io.WriteString(dst, b.String()); err != nil {
+		return kerrors.WithKind(err, errBuildMail{}, "Failed to write bounce msg")
+	}
+	return nil
+}
+
+// retryDeliveries attempts all due deliveries in batches
+func (s *Service) retryDeliveries(ctx context.Context) {
+	s.mxpool.closeIdle(time.Now())
+	for {
+		now := time.Now().Round(0)
+		rows, err := s.deliveries.ClaimDue(ctx, now.Unix(), now.Add(s.direct.lease).Unix(), s.direct.retrybatch)
+		if err != nil {
+			s.log.Err(ctx, kerrors.WithMsg(err, "Failed to claim due mail deliveries"))
+			return
+		}
+		byTag := map[string][]deliverymodel.Model{}
+		var tags []string
+		for _, i := range rows {
+			if _, ok := byTag[i.Tag]; !ok {
+				tags = append(tags, i.Tag)
+			}
+			byTag[i.Tag] = append(byTag[i.Tag], i)
+		}
+		for _, i := range tags {
+			msg, err := s.getQueuedMsg(ctx, i)
+			if err != nil {
+				if !errors.Is(err, objstore.ErrNotFound) {
+					s.log.Err(ctx, kerrors.WithMsg(err, "Failed to get queued mail msg"),
+						klog.AString("mail.delivery.tag", i),
+					)
+					continue
+				}
+				msg = nil
+			}
+			if err := s.attemptDeliveries(ctx, i, byTag[i], msg); err != nil {
+				s.log.Err(ctx, kerrors.WithMsg(err, "Failed to retry mail deliveries"),
+					klog.AString("mail.delivery.tag", i),
+				)
+			}
+		}
+		if len(rows) < s.direct.retrybatch {
+			break
+		}
+	}
+	if err := s.deliveries.DeleteBefore(ctx, time.Now().Round(0).Add(-s.direct.statusage).Unix()); err != nil {
+		s.log.Err(ctx, kerrors.WithMsg(err, "Failed to gc mail deliveries"))
+	}
+}
+
+func (s *Service) deliveryRetryLoop(ctx context.Context, wg *ksync.WaitGroup) {
+	defer wg.Done()
+	defer s.mxpool.closeAll()
+	ticker := time.NewTicker(s.direct.retryinterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.retryDeliveries(ctx)
+		}
+	}
+}
+
+type (
+	resDelivery struct {
+		Tag          string `json:"tag"`
+		Rcpt         string `json:"rcpt"`
+		Status       string `json:"status"`
+		Attempts     int    `json:"attempts"`
+		NextAttempt  int64  `json:"next_attempt"`
+		LastError    string `json:"last_error"`
+		CreationTime int64  `json:"creation_time"`
+		LastUpdated  int64  `json:"last_updated"`
+	}
+
+	resDeliveries struct {
+		Deliveries []resDelivery `json:"deliveries"`
+	}
+)
+
+func toResDeliveries(m []deliverymodel.Model) *resDeliveries {
+	res := make([]resDelivery, 0, len(m))
+	for _, i := range m {
+		res = append(res, resDelivery{
+			Tag:          i.Tag,
+			Rcpt:         i.Rcpt,
+			Status:       i.Status,
+			Attempts:     i.Attempts,
+			NextAttempt:  i.NextAttempt,
+			LastError:    i.LastError,
+			CreationTime: i.CreationTime,
+			LastUpdated:  i.LastUpdated,
+		})
+	}
+	return &resDeliveries{
+		Deliveries: res,
+	}
+}
+
+func (s *Service) getDeliveriesByTag(ctx context.Context, tag string, amount, offset int) (*resDeliveries, error) {
+	m, err := s.deliveries.GetByTag(ctx, tag, amount, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get mail deliveries")
+	}
+	if len(m) == 0 && offset == 0 {
+		return nil, governor.ErrWithRes(nil, http.StatusNotFound, "", "Mail deliveries not found")
+	}
+	return toResDeliveries(m), nil
+}
+
+func (s *Service) getDeliveriesByRcpt(ctx context.Context, rcpt string, amount, offset int) (*resDeliveries, error) {
+	m, err := s.deliveries.GetByRcpt(ctx, rcpt, amount, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get mail deliveries")
+	}
+	return toResDeliveries(m), nil
+}
@@ -0,0 +1,224 @@
+package deliverymodel
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"xorkevin.dev/forge/model/sqldb"
+	"xorkevin.dev/governor/service/dbsql"
+	"xorkevin.dev/kerrors"
+)
+
+//go:generate forge model
+
+const (
+	// StatusPending is a delivery that has not yet been completed
+	StatusPending = "pending"
+	// StatusDelivered is a delivery accepted by the recipient mx
+	StatusDelivered = "delivered"
+	// StatusFailed is a delivery that permanently failed
+	StatusFailed = "failed"
+)
+
+type (
+	// Repo is a mail delivery repository
+	Repo interface {
+		New(tag, rcpt, retpath string) *Model
+		GetByTag(ctx context.Context, tag string, limit, offset int) ([]Model, error)
+		GetByRcpt(ctx context.Context, rcpt string, limit, offset int) ([]Model, error)
+		HasPending(ctx context.Context, tag string) (bool, error)
+		ClaimTag(ctx context.Context, tag string, now, lease int64) ([]Model, error)
+		ClaimDue(ctx context.Context, now, lease int64, limit int) ([]Model, error)
+		InsertBulk(ctx context.Context, m []*Model) error
+		Update(ctx context.Context, m *Model) error
+		DeleteBefore(ctx context.Context, t int64) error
+		Setup(ctx context.Context) error
+	}
+
+	repo struct {
+		table *deliveryModelTable
+		db    dbsql.Database
+	}
+
+	// Model is the db per recipient mail delivery model
+	//forge:model delivery
+	//forge:model:query delivery
+	Model struct {
+		Tag          string `model:"tag,VARCHAR(31)"`
+		Rcpt         string `model:"rcpt,VARCHAR(255)"`
+		Domain       string `model:"domain,VARCHAR(255) NOT NULL"`
+		RetPath      string `model:"retpath,VARCHAR(1023) NOT NULL"`
+		Status       string `model:"status,VARCHAR(31) NOT NULL"`
+		Attempts     int    `model:"attempts,INT NOT NULL"`
+		NextAttempt  int64  `model:"next_attempt,BIGINT NOT NULL"`
+		LastError    string `model:"last_error,VARCHAR(4095) NOT NULL"`
+		CreationTime int64  `model:"creation_time,BIGINT NOT NULL"`
+		LastUpdated  int64  `model:"last_updated,BIGINT NOT NULL"`
+	}
+)
+
+// New creates a new delivery repository
+func New(database dbsql.Database, table string) Repo {
+	return &repo{
+		table: &deliveryModelTable{
+			TableName: table,
+		},
+		db: database,
+	}
+}
+
+func (r *repo) New(tag, rcpt, retpath string) *Model {
+	now := time.Now().Round(0).Unix()
+	domain := ""
+	if i := strings.LastIndexByte(rcpt, '@'); i >= 0 {
+		domain = strings.ToLower(rcpt[i+1:])
+	}
+	return &Model{
+		Tag:          tag,
+		Rcpt:         rcpt,
+		Domain:       domain,
+		RetPath:      retpath,
+		Status:       StatusPending,
+		Attempts:     0,
+		NextAttempt:  now,
+		LastError:    "",
+		CreationTime: now,
+		LastUpdated:  now,
+	}
+}
+
+func (r *repo) GetByTag(ctx context.Context, tag string, limit, offset int) ([]Model, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.table.GetModelByTag(ctx, d, tag, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get deliveries")
+	}
+	return m, nil
+}
+
+func (r *repo) GetByRcpt(ctx context.Context, rcpt string, limit, offset int) ([]Model, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.table.GetModelByRcpt(ctx, d, rcpt, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get deliveries")
+	}
+	return m, nil
+}
+
+func (r *repo) HasPending(ctx context.Context, tag string) (bool, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return false, err
+	}
+	m, err := r.table.GetModelByTagStatus(ctx, d, tag, StatusPending, 1, 0)
+	if err != nil {
+		return false, kerrors.WithMsg(err, "Failed to get pending deliveries")
+	}
+	return len(m) > 0, nil
+}
+
+func (t *deliveryModelTable) claimDue(ctx context.Context, d sqldb.Executor, filter string, args ...interface{}) (_ []Model, retErr error) {
+	rows, err := d.QueryContext(ctx, "UPDATE "+t.TableName+" SET (next_attempt) = ROW($1) WHERE (tag, rcpt) IN (SELECT tag, rcpt FROM "+t.TableName+" WHERE status = $2 AND next_attempt <= $3"+filter+" FOR UPDATE SKIP LOCKED) RETURNING tag, rcpt, domain, retpath, status, attempts, next_attempt, last_error, creation_time, last_updated;", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			retErr = errors.Join(retErr, kerrors.WithMsg(err, "Failed to close db rows"))
+		}
+	}()
+	var res []Model
+	for rows.Next() {
+		var m Model
+		if err := rows.Scan(&m.Tag, &m.Rcpt, &m.Domain, &m.RetPath, &m.Status, &m.Attempts, &m.NextAttempt, &m.LastError, &m.CreationTime, &m.LastUpdated); err != nil {
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ClaimTag leases the due pending deliveries of a message until lease
+func (r *repo) ClaimTag(ctx context.Context, tag string, now, lease int64) ([]Model, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.table.claimDue(ctx, d, " AND tag = $4", lease, StatusPending, now, tag)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to claim deliveries")
+	}
+	return m, nil
+}
+
+// ClaimDue leases up to limit due pending deliveries until lease
+func (r *repo) ClaimDue(ctx context.Context, now, lease int64, limit int) ([]Model, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.table.claimDue(ctx, d, " ORDER BY next_attempt LIMIT $4", lease, StatusPending, now, limit)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to claim deliveries")
+	}
+	return m, nil
+}
+
+func (r *repo) InsertBulk(ctx context.Context, m []*Model) error {
+	if len(m) == 0 {
+		return nil
+	}
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	// existing deliveries are left untouched when a message is requeued
+	if err := r.table.InsertBulk(ctx, d, m, true); err != nil {
+		return kerrors.WithMsg(err, "Failed to insert deliveries")
+	}
+	return nil
+}
+
+func (r *repo) Update(ctx context.Context, m *Model) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.table.UpdModelByTagRcpt(ctx, d, m, m.Tag, m.Rcpt); err != nil {
+		return kerrors.WithMsg(err, "Failed to update delivery")
+	}
+	return nil
+}
+
+func (r *repo) DeleteBefore(ctx context.Context, t int64) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.table.DelBeforeCreationTime(ctx, d, t); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete deliveries")
+	}
+	return nil
+}
+
+func (r *repo) Setup(ctx context.Context) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.table.Setup(ctx, d); err != nil {
+		return kerrors.WithMsg(err, "Failed to setup delivery model")
+	}
+	return nil
+}
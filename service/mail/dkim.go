@@ -0,0 +1,169 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"io"
+	"time"
+
+	"github.com/emersion/go-msgauth/dkim"
+	"xorkevin.dev/governor"
+	"xorkevin.dev/hunter2/h2signer"
+	"xorkevin.dev/kerrors"
+	"xorkevin.dev/klog"
+)
+
+const (
+	dkimAlgRSA     = "rsa-sha256"
+	dkimAlgEd25519 = "ed25519-sha256"
+)
+
+// dkimHeaderKeys are the headers signed by outbound dkim signatures
+//
+// See RFC 6376 section 5.4.1 for recommended header fields
+var dkimHeaderKeys = []string{
+	"From",
+	"Reply-To",
+	"Subject",
+	"Date",
+	"To",
+	"Cc",
+	"Resent-Date",
+	"Resent-From",
+	"Resent-To",
+	"Resent-Cc",
+	"In-Reply-To",
+	"References",
+	"List-Id",
+	"List-Help",
+	"List-Unsubscribe",
+	"List-Unsubscribe-Post",
+	"List-Subscribe",
+	"List-Post",
+	"List-Owner",
+	"List-Archive",
+	"Message-Id",
+	"Mime-Version",
+	"Content-Type",
+	"Content-Transfer-Encoding",
+}
+
+type (
+	secretDKIM struct {
+		Domain   string `mapstructure:"domain"`
+		Selector string `mapstructure:"selector"`
+		Key      string `mapstructure:"key"`
+	}
+
+	// dkimKey is a signing key published at selector._domainkey.domain
+	dkimKey struct {
+		domain   string
+		selector string
+		kid      string
+		alg      string
+		signer   crypto.Signer
+	}
+)
+
+func (s *Service) getDKIMKey(ctx context.Context, current *dkimKey) (*dkimKey, error) {
+	if !s.dkimsign {
+		return nil, nil
+	}
+	var dkimSecrets secretDKIM
+	if err := s.config.GetSecret(ctx, "dkimkey", s.authrefresh, &dkimSecrets); err != nil {
+		return nil, kerrors.WithKind(err, governor.ErrInvalidConfig, "Invalid dkim secrets")
+	}
+	if dkimSecrets.Domain == "" || dkimSecrets.Selector == "" || dkimSecrets.Key == "" {
+		return nil, kerrors.WithKind(nil, governor.ErrInvalidConfig, "No dkim key present")
+	}
+	k, err := h2signer.SigningKeyFromParams(dkimSecrets.Key, s.signingAlgs)
+	if err != nil {
+		return nil, kerrors.WithKind(err, governor.ErrInvalidConfig, "Invalid dkim key param")
+	}
+	kid := k.Verifier().ID()
+	if current != nil && current.kid == kid && current.domain == dkimSecrets.Domain && current.selector == dkimSecrets.Selector {
+		// key and selector match current key, therefore no change in keys
+		return current, nil
+	}
+	signer, ok := k.Private().(crypto.Signer)
+	if !ok {
+		return nil, kerrors.WithKind(nil, governor.ErrInvalidConfig, "Invalid dkim signing key")
+	}
+	var alg string
+	switch signer.Public().(type) {
+	case *rsa.PublicKey:
+		alg = dkimAlgRSA
+	case ed25519.PublicKey:
+		alg = dkimAlgEd25519
+	default:
+		return nil, kerrors.WithKind(nil, governor.ErrInvalidConfig, "Unsupported dkim key algorithm")
+	}
+	key := &dkimKey{
+		domain:   dkimSecrets.Domain,
+		selector: dkimSecrets.Selector,
+		kid:      kid,
+		alg:      alg,
+		signer:   signer,
+	}
+	s.log.Info(ctx, "Refreshed dkim key",
+		klog.AString("dkim.domain", key.domain),
+		klog.AString("dkim.selector", key.selector),
+		klog.AString("kid", key.kid),
+	)
+	return key, nil
+}
+
+// sign returns the message with a dkim signature header prepended
+func (k *dkimKey) sign(msg io.Reader) (*bytes.Buffer, error) {
+	b := &bytes.Buffer{}
+	if err := dkim.Sign(b, msg, &dkim.SignOptions{
+		Domain:                 k.domain,
+		Selector:               k.selector,
+		Signer:                 k.signer,
+		Hash:                   crypto.SHA256,
+		HeaderCanonicalization: dkim.CanonicalizationRelaxed,
+		BodyCanonicalization:   dkim.CanonicalizationRelaxed,
+		HeaderKeys:             dkimHeaderKeys,
+	}); err != nil {
+		return nil, kerrors.WithKind(err, errBuildMail{}, "Failed to dkim sign mail")
+	}
+	return b, nil
+}
+
+// signHash signs a sha256 digest with the key
+func (k *dkimKey) signHash(hashed []byte) ([]byte, error) {
+	var opts crypto.SignerOpts = crypto.SHA256
+	if k.alg == dkimAlgEd25519 {
+		// ed25519-sha256 signs the sha256 digest as the message
+		opts = crypto.Hash(0)
+	}
+	sig, err := k.signer.Sign(rand.Reader, hashed, opts)
+	if err != nil {
+		return nil, kerrors.WithKind(err, errBuildMail{}, "Failed to sign mail")
+	}
+	return sig, nil
+}
+
+// signMsg reads the message and signs it with the dkim key if it exists. If
+// authservid is not empty, the message is first sealed with an arc set.
+func signMsg(msg io.Reader, key *dkimKey, authservid string, now time.Time) (io.Reader, error) {
+	b := &bytes.Buffer{}
+	if _, err := io.Copy(b, msg); err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to read mail msg")
+	}
+	if key == nil {
+		return b, nil
+	}
+	if authservid != "" {
+		sealed, err := arcSeal(b.Bytes(), authservid, key, now)
+		if err != nil {
+			return nil, kerrors.WithMsg(err, "Failed to arc seal mail msg")
+		}
+		b = bytes.NewBuffer(sealed)
+	}
+	return key.sign(b)
+}
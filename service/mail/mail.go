@@ -7,6 +7,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -17,9 +19,15 @@ import (
 	"github.com/emersion/go-smtp"
 	"xorkevin.dev/governor"
 	"xorkevin.dev/governor/service/events"
+	"xorkevin.dev/governor/service/mail/deliverymodel"
+	"xorkevin.dev/governor/service/mail/suppressionmodel"
+	"xorkevin.dev/governor/service/mail/verpmodel"
 	"xorkevin.dev/governor/service/objstore"
+	"xorkevin.dev/governor/service/ratelimit"
 	"xorkevin.dev/governor/service/template"
+	"xorkevin.dev/governor/service/user/gate"
 	"xorkevin.dev/governor/util/bytefmt"
+	"xorkevin.dev/governor/util/dns"
 	"xorkevin.dev/governor/util/kjson"
 	"xorkevin.dev/governor/util/ksync"
 	"xorkevin.dev/governor/util/lifecycle"
@@ -27,6 +35,9 @@ import (
 	"xorkevin.dev/hunter2/h2cipher"
 	"xorkevin.dev/hunter2/h2cipher/aes"
 	"xorkevin.dev/hunter2/h2cipher/xchacha20poly1305"
+	"xorkevin.dev/hunter2/h2signer"
+	"xorkevin.dev/hunter2/h2signer/eddsa"
+	"xorkevin.dev/hunter2/h2signer/rsasig"
 	"xorkevin.dev/hunter2/h2streamcipher"
 	"xorkevin.dev/hunter2/h2streamcipher/xchacha20"
 	"xorkevin.dev/kerrors"
@@ -48,14 +59,22 @@ const (
 	mediaTypeTextPlain = "text/plain"
 	mediaTypeTextHTML  = "text/html"
 	mediaTypeOctet     = "application/octet-stream"
+	mediaTypeJSON      = "application/json"
 )
 
 type (
 	// Mailer is a service wrapper around a mailer instance
+	//
+	// If retpath is empty, each recipient is sent mail with a verp return path
+	// when bounce processing is enabled, and otherwise the default return path.
 	Mailer interface {
 		FwdStream(ctx context.Context, retpath string, to []Addr, size int64, body io.Reader, encrypt bool) error
+		FwdStreamSeal(ctx context.Context, retpath string, to []Addr, authservid string, size int64, body io.Reader, encrypt bool) error
 		SendStream(ctx context.Context, retpath string, from Addr, to []Addr, subject string, size int64, body io.Reader, encrypt bool) error
+		SendStreamAttach(ctx context.Context, retpath string, from Addr, to []Addr, subject string, size int64, body io.Reader, atts []Attachment, encrypt bool) error
 		SendTpl(ctx context.Context, retpath string, from Addr, to []Addr, tpl Tpl, emdata interface{}, encrypt bool) error
+		SendTplAttach(ctx context.Context, retpath string, from Addr, to []Addr, tpl Tpl, emdata interface{}, atts []Attachment, encrypt bool) error
+		FilterSuppressed(ctx context.Context, to []Addr) ([]Addr, error)
 	}
 
 	// Tpl points to a mail template
@@ -65,26 +84,29 @@ type (
 	}
 
 	tplData struct {
-		MsgID     string `json:"msgid"`
-		Tpl       Tpl    `json:"tpl"`
-		Emdata    string `json:"emdata"`
-		Encrypted bool   `json:"encrypted"`
+		MsgID       string           `json:"msgid"`
+		Tpl         Tpl              `json:"tpl"`
+		Emdata      string           `json:"emdata"`
+		Attachments []attachmentData `json:"attachments,omitempty"`
+		Encrypted   bool             `json:"encrypted"`
 	}
 
 	rawData struct {
-		MsgID     string `json:"msgid"`
-		Subject   string `json:"subject"`
-		Path      string `json:"path"`
-		Key       string `json:"key"`
-		Tag       string `json:"tag"`
-		Encrypted bool   `json:"encrypted"`
+		MsgID       string           `json:"msgid"`
+		Subject     string           `json:"subject"`
+		Path        string           `json:"path"`
+		Key         string           `json:"key"`
+		Tag         string           `json:"tag"`
+		Attachments []attachmentData `json:"attachments,omitempty"`
+		Encrypted   bool             `json:"encrypted"`
 	}
 
 	fwdData struct {
-		Path      string `json:"path"`
-		Key       string `json:"key"`
-		Tag       string `json:"tag"`
-		Encrypted bool   `json:"encrypted"`
+		Path       string `json:"path"`
+		Key        string `json:"key"`
+		Tag        string `json:"tag"`
+		AuthservID string `json:"authservid,omitempty"`
+		Encrypted  bool   `json:"encrypted"`
 	}
 
 	// Addr is a mail address
@@ -105,6 +127,7 @@ type (
 
 	mailmsg struct {
 		ReturnPath string  `json:"retpath"`
+		Tag        string  `json:"tag,omitempty"`
 		From       Addr    `json:"from"`
 		To         []Addr  `json:"to"`
 		Kind       string  `json:"kind"`
@@ -114,7 +137,8 @@ type (
 	}
 
 	mailgcmsg struct {
-		MsgPath string `json:"msgpath"`
+		MsgPath  string   `json:"msgpath"`
+		AttPaths []string `json:"att_paths,omitempty"`
 	}
 
 	msgbuilder struct {
@@ -127,40 +151,75 @@ type (
 		auth    secretAuth
 		cipher  h2cipher.Cipher
 		keyring *h2cipher.Keyring
+		dkim    *dkimKey
+		verp    [][]byte
 	}
 
 	tplSuffix struct {
 		subject string
 		text    string
 		html    string
+		sample  string
+	}
+
+	bounceConfig struct {
+		domain       string
+		prefix       string
+		port         string
+		maxmsgsize   int64
+		readtimeout  time.Duration
+		writetimeout time.Duration
+		verpage      time.Duration
 	}
 
 	Service struct {
-		tpl         template.Template
-		events      events.Events
-		mailBucket  objstore.Bucket
-		sendMailDir objstore.Dir
-		lc          *lifecycle.Lifecycle[mailSecrets]
-		cipherAlgs  h2cipher.Algs
-		streamAlgs  h2streamcipher.Algs
-		config      governor.ConfigReader
-		log         *klog.LevelLogger
-		tracer      governor.Tracer
-		streamns    string
-		streammail  string
-		host        string
-		addr        string
-		msgiddomain string
-		returnpath  string
-		fromAddress string
-		fromName    string
-		tplSuffix   tplSuffix
-		streamsize  int64
-		eventsize   int32
-		hbfailed    int
-		hbmaxfail   int
-		authrefresh time.Duration
-		wg          *ksync.WaitGroup
+		suppressions suppressionmodel.Repo
+		deliveries   deliverymodel.Repo
+		verps        verpmodel.Repo
+		tpl          template.Template
+		events       events.Events
+		mailBucket   objstore.Bucket
+		sendMailDir  objstore.Dir
+		sendAttDir   objstore.Dir
+		queueDir     objstore.Dir
+		ratelimiter  ratelimit.Ratelimiter
+		gate         gate.Gate
+		resolver     dns.Resolver
+		lc           *lifecycle.Lifecycle[mailSecrets]
+		cipherAlgs   h2cipher.Algs
+		streamAlgs   h2streamcipher.Algs
+		signingAlgs  h2signer.SigningKeyAlgs
+		config       governor.ConfigReader
+		log          *klog.LevelLogger
+		tracer       governor.Tracer
+		scopens      string
+		streamns     string
+		streammail   string
+		host         string
+		addr         string
+		msgiddomain  string
+		returnpath   string
+		fromAddress  string
+		fromName     string
+		tplSuffix    tplSuffix
+		bounce       bounceConfig
+		server       *smtp.Server
+		direct       directConfig
+		mxpool       *mxPool
+		catcher      catcherStore
+		catchercfg   catcherConfig
+		streamsize   int64
+		eventsize    int32
+		hbfailed     int
+		hbmaxfail    int
+		authrefresh  time.Duration
+		dkimsign     bool
+		wg           *ksync.WaitGroup
+	}
+
+	router struct {
+		s  *Service
+		rt governor.MiddlewareCtx
 	}
 )
 
@@ -173,25 +232,40 @@ func TplLocal(name string) Tpl {
 }
 
 // New creates a new Mailer
-func New(tpl template.Template, ev events.Events, obj objstore.Bucket) *Service {
+func New(suppressions suppressionmodel.Repo, deliveries deliverymodel.Repo, verps verpmodel.Repo, tpl template.Template, ev events.Events, obj objstore.Bucket, ratelimiter ratelimit.Ratelimiter, g gate.Gate) *Service {
 	cipherAlgs := h2cipher.NewAlgsMap()
 	xchacha20poly1305.Register(cipherAlgs)
 	aes.Register(cipherAlgs)
 	streamAlgs := h2streamcipher.NewAlgsMap()
 	xchacha20.Register(streamAlgs)
+	signingAlgs := h2signer.NewSigningKeysMap()
+	eddsa.RegisterSigner(signingAlgs)
+	rsasig.RegisterSigner(signingAlgs)
 	return &Service{
-		tpl:         tpl,
-		events:      ev,
-		mailBucket:  obj,
-		sendMailDir: obj.Subdir("sendmail"),
+		suppressions: suppressions,
+		deliveries:   deliveries,
+		verps:        verps,
+		tpl:          tpl,
+		events:       ev,
+		mailBucket:   obj,
+		sendMailDir:  obj.Subdir("sendmail"),
+		sendAttDir:   obj.Subdir("sendmailatt"),
+		queueDir:     obj.Subdir("sendmailqueue"),
+		ratelimiter:  ratelimiter,
+		gate:         g,
+		resolver: dns.NewCachingResolver(&net.Resolver{
+			PreferGo: true,
+		}, time.Minute),
 		cipherAlgs:  cipherAlgs,
 		streamAlgs:  streamAlgs,
+		signingAlgs: signingAlgs,
 		hbfailed:    0,
 		wg:          ksync.NewWaitGroup(),
 	}
 }
 
 func (s *Service) Register(r governor.ConfigRegistrar) {
+	s.scopens = "gov." + r.Name()
 	s.streamns = r.Name()
 	s.streammail = r.Name()
 
@@ -204,11 +278,45 @@ func (s *Service) Register(r governor.ConfigRegistrar) {
 	r.SetDefault("tplsuffix.subject", "_subject.txt.tmpl")
 	r.SetDefault("tplsuffix.text", ".txt.tmpl")
 	r.SetDefault("tplsuffix.html", ".html.tmpl")
+	r.SetDefault("tplsuffix.sample", ".sample.json")
 	r.SetDefault("streamsize", "200M")
 	r.SetDefault("eventsize", "16K")
 	r.SetDefault("hbinterval", "5s")
 	r.SetDefault("hbmaxfail", 6)
 	r.SetDefault("authrefresh", "1m")
+	r.SetDefault("dkimsign", false)
+	r.SetDefault("bounce.domain", "")
+	r.SetDefault("bounce.prefix", "bounce")
+	r.SetDefault("bounce.port", "2526")
+	r.SetDefault("bounce.maxmsgsize", "2M")
+	r.SetDefault("bounce.readtimeout", "5s")
+	r.SetDefault("bounce.writetimeout", "5s")
+	r.SetDefault("bounce.verpage", "720h")
+	r.SetDefault("verpkey", "")
+	r.SetDefault("delivery", deliveryModeRelay)
+	r.SetDefault("mockdnssource", "")
+	r.SetDefault("direct.helo", "localhost")
+	r.SetDefault("direct.port", "25")
+	r.SetDefault("direct.dialtimeout", "30s")
+	r.SetDefault("direct.cmdtimeout", "5m")
+	r.SetDefault("direct.connidle", "30s")
+	r.SetDefault("direct.retrybase", "5m")
+	r.SetDefault("direct.retrymax", "4h")
+	r.SetDefault("direct.retrylimit", "72h")
+	r.SetDefault("direct.retryinterval", "1m")
+	r.SetDefault("direct.retrybatch", 64)
+	r.SetDefault("direct.lease", "15m")
+	r.SetDefault("direct.statusage", "168h")
+	r.SetDefault("catcher.route", "/catcher")
+	r.SetDefault("catcher.backend", catcherBackendMem)
+	r.SetDefault("catcher.capacity", 256)
+}
+
+func (s *Service) router() *router {
+	return &router{
+		s:  s,
+		rt: s.ratelimiter.BaseCtx(),
+	}
 }
 
 type (
@@ -231,6 +339,7 @@ func (s *Service) Init(ctx context.Context, r governor.ConfigReader, kit governo
 	s.tplSuffix.subject = r.GetStr("tplsuffix.subject")
 	s.tplSuffix.text = r.GetStr("tplsuffix.text")
 	s.tplSuffix.html = r.GetStr("tplsuffix.html")
+	s.tplSuffix.sample = r.GetStr("tplsuffix.sample")
 	var err error
 	s.streamsize, err = bytefmt.ToBytes(r.GetStr("streamsize"))
 	if err != nil {
@@ -250,6 +359,106 @@ func (s *Service) Init(ctx context.Context, r governor.ConfigReader, kit governo
 	if err != nil {
 		return kerrors.WithMsg(err, "Failed to parse authrefresh")
 	}
+	s.dkimsign = r.GetBool("dkimsign")
+	s.bounce.domain = r.GetStr("bounce.domain")
+	s.bounce.prefix = r.GetStr("bounce.prefix")
+	s.bounce.port = r.GetStr("bounce.port")
+	s.bounce.maxmsgsize, err = bytefmt.ToBytes(r.GetStr("bounce.maxmsgsize"))
+	if err != nil {
+		return kerrors.WithMsg(err, "Invalid bounce max message size")
+	}
+	s.bounce.readtimeout, err = r.GetDuration("bounce.readtimeout")
+	if err != nil {
+		return kerrors.WithMsg(err, "Invalid read timeout for bounce server")
+	}
+	s.bounce.writetimeout, err = r.GetDuration("bounce.writetimeout")
+	if err != nil {
+		return kerrors.WithMsg(err, "Invalid write timeout for bounce server")
+	}
+	s.bounce.verpage, err = r.GetDuration("bounce.verpage")
+	if err != nil {
+		return kerrors.WithMsg(err, "Invalid bounce verp age")
+	}
+	switch mode := r.GetStr("delivery"); mode {
+	case deliveryModeRelay:
+		s.direct.enabled = false
+	case deliveryModeDirect:
+		s.direct.enabled = true
+	case deliveryModeCatch:
+		s.catchercfg.route = r.GetStr("catcher.route")
+		s.catchercfg.capacity = r.GetInt("catcher.capacity")
+		if s.catchercfg.capacity < 1 {
+			return kerrors.WithKind(nil, governor.ErrInvalidConfig, "Invalid mail catcher capacity")
+		}
+		s.catchercfg.backend = r.GetStr("catcher.backend")
+		switch s.catchercfg.backend {
+		case catcherBackendMem:
+			s.catcher = newMailCatcher(s.catchercfg.capacity)
+		case catcherBackendObj:
+			s.catcher = newObjCatcher(s.mailBucket.Subdir("catcher"), s.catchercfg.capacity)
+		default:
+			return kerrors.WithKind(nil, governor.ErrInvalidConfig, "Invalid mail catcher backend")
+		}
+	default:
+		return kerrors.WithKind(nil, governor.ErrInvalidConfig, "Invalid delivery mode")
+	}
+	s.direct.helo = r.GetStr("direct.helo")
+	s.direct.port = r.GetStr("direct.port")
+	s.direct.dialtimeout, err = r.GetDuration("direct.dialtimeout")
+	if err != nil {
+		return kerrors.WithMsg(err, "Invalid direct delivery dial timeout")
+	}
+	s.direct.cmdtimeout, err = r.GetDuration("direct.cmdtimeout")
+	if err != nil {
+		return kerrors.WithMsg(err, "Invalid direct delivery command timeout")
+	}
+	s.direct.connidle, err = r.GetDuration("direct.connidle")
+	if err != nil {
+		return kerrors.WithMsg(err, "Invalid direct delivery connection idle duration")
+	}
+	s.direct.retrybase, err = r.GetDuration("direct.retrybase")
+	if err != nil {
+		return kerrors.WithMsg(err, "Invalid direct delivery retry base")
+	}
+	s.direct.retrymax, err = r.GetDuration("direct.retrymax")
+	if err != nil {
+		return kerrors.WithMsg(err, "Invalid direct delivery retry max")
+	}
+	s.direct.retrylimit, err = r.GetDuration("direct.retrylimit")
+	if err != nil {
+		return kerrors.WithMsg(err, "Invalid direct delivery retry limit")
+	}
+	s.direct.retryinterval, err = r.GetDuration("direct.retryinterval")
+	if err != nil {
+		return kerrors.WithMsg(err, "Invalid direct delivery retry interval")
+	}
+	s.direct.retrybatch = r.GetInt("direct.retrybatch")
+	if s.direct.retrybatch < 1 {
+		return kerrors.WithKind(nil, governor.ErrInvalidConfig, "Invalid direct delivery retry batch")
+	}
+	s.direct.lease, err = r.GetDuration("direct.lease")
+	if err != nil {
+		return kerrors.WithMsg(err, "Invalid direct delivery lease")
+	}
+	s.direct.statusage, err = r.GetDuration("direct.statusage")
+	if err != nil {
+		return kerrors.WithMsg(err, "Invalid direct delivery status age")
+	}
+	if s.direct.statusage <= s.direct.retrylimit {
+		return kerrors.WithKind(nil, governor.ErrInvalidConfig, "Direct delivery status age must exceed retry limit")
+	}
+	s.mxpool = newMXPool(s.direct.connidle)
+
+	if src := r.GetStr("mockdnssource"); src != "" {
+		var err error
+		s.resolver, err = dns.NewMockResolverFromFile(src)
+		if err != nil {
+			return kerrors.WithKind(err, governor.ErrInvalidConfig, "Invalid mockdns source")
+		}
+		s.log.Info(ctx, "Use mockdns",
+			klog.AString("source", src),
+		)
+	}
 
 	s.log.Info(ctx, "Initialize mail service",
 		klog.AString("smtp.addr", s.addr),
@@ -265,6 +474,30 @@ func (s *Service) Init(ctx context.Context, r governor.ConfigReader, kit governo
 		klog.AString("hbinterval", hbinterval.String()),
 		klog.AInt("hbmaxfail", s.hbmaxfail),
 		klog.AString("authrefresh", s.authrefresh.String()),
+		klog.ABool("dkimsign", s.dkimsign),
+		klog.AString("bounce.domain", s.bounce.domain),
+		klog.AString("bounce.prefix", s.bounce.prefix),
+		klog.AString("bounce.port", s.bounce.port),
+		klog.AString("bounce.maxmsgsize", r.GetStr("bounce.maxmsgsize")),
+		klog.AString("bounce.readtimeout", s.bounce.readtimeout.String()),
+		klog.AString("bounce.writetimeout", s.bounce.writetimeout.String()),
+		klog.AString("bounce.verpage", s.bounce.verpage.String()),
+		klog.AString("delivery", r.GetStr("delivery")),
+		klog.AString("direct.helo", s.direct.helo),
+		klog.AString("direct.port", s.direct.port),
+		klog.AString("direct.dialtimeout", s.direct.dialtimeout.String()),
+		klog.AString("direct.cmdtimeout", s.direct.cmdtimeout.String()),
+		klog.AString("direct.connidle", s.direct.connidle.String()),
+		klog.AString("direct.retrybase", s.direct.retrybase.String()),
+		klog.AString("direct.retrymax", s.direct.retrymax.String()),
+		klog.AString("direct.retrylimit", s.direct.retrylimit.String()),
+		klog.AString("direct.retryinterval", s.direct.retryinterval.String()),
+		klog.AInt("direct.retrybatch", s.direct.retrybatch),
+		klog.AString("direct.lease", s.direct.lease.String()),
+		klog.AString("direct.statusage", s.direct.statusage.String()),
+		klog.AString("catcher.route", s.catchercfg.route),
+		klog.AString("catcher.backend", s.catchercfg.backend),
+		klog.AInt("catcher.capacity", s.catchercfg.capacity),
 	)
 
 	ctx = klog.CtxWithAttrs(ctx, klog.AString("gov.phase", "run"))
@@ -277,6 +510,17 @@ func (s *Service) Init(ctx context.Context, r governor.ConfigReader, kit governo
 	)
 	go s.lc.Heartbeat(ctx, s.wg)
 
+	sr := s.router()
+	sr.mountRoutes(kit.Router)
+	if s.catcher != nil {
+		sr.mountCatcherRoutes(kit.Router.Group(s.catchercfg.route))
+		s.log.Warn(ctx, "Mail catcher enabled, mail will not be delivered",
+			klog.AString("catcher.route", s.catchercfg.route),
+			klog.AString("catcher.backend", s.catchercfg.backend),
+		)
+	}
+	s.log.Info(ctx, "Mounted http routes")
+
 	return nil
 }
 
@@ -313,6 +557,20 @@ func (s *Service) handleGetSecrets(ctx context.Context, m *lifecycle.State[mailS
 		)
 	}
 
+	var currentDKIM *dkimKey
+	if currentSecrets != nil {
+		currentDKIM = currentSecrets.dkim
+	}
+	dkim, err := s.getDKIMKey(ctx, currentDKIM)
+	if err != nil {
+		return nil, err
+	}
+
+	verp, err := s.getVERPKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	var maildataSecrets secretMaildata
 	if err := s.config.GetSecret(ctx, "mailkey", s.authrefresh, &maildataSecrets); err != nil {
 		return nil, kerrors.WithKind(err, governor.ErrInvalidConfig, "Invalid mailkey secrets")
@@ -328,8 +586,9 @@ func (s *Service) handleGetSecrets(ctx context.Context, m *lifecycle.State[mailS
 			return nil, kerrors.WithKind(err, governor.ErrInvalidConfig, "Invalid cipher param")
 		}
 		if n == 0 {
-			if currentSecrets != nil && currentSecrets.cipher.ID() == c.ID() {
-				// first, newest cipher matches current cipher, therefore no change in ciphers
+			if currentSecrets != nil && currentSecrets.cipher.ID() == c.ID() && auth == currentSecrets.auth && dkim == currentSecrets.dkim && slices.EqualFunc(verp, currentSecrets.verp, bytes.Equal) {
+				// first, newest cipher matches current cipher, and there is no change
+				// in auth, dkim key, or verp keys, therefore no change in secrets
 				return currentSecrets, nil
 			}
 			cipher = c
@@ -343,6 +602,8 @@ func (s *Service) handleGetSecrets(ctx context.Context, m *lifecycle.State[mailS
 		auth:    auth,
 		cipher:  cipher,
 		keyring: keyring,
+		dkim:    dkim,
+		verp:    verp,
 	}
 
 	s.log.Info(ctx, "Refreshed mailkey with new keys",
@@ -355,6 +616,27 @@ func (s *Service) handleGetSecrets(ctx context.Context, m *lifecycle.State[mailS
 	return secrets, nil
 }
 
+func (s *Service) getVERPKeys(ctx context.Context) ([][]byte, error) {
+	if s.bounce.domain == "" {
+		return nil, nil
+	}
+	var verpSecrets secretMaildata
+	if err := s.config.GetSecret(ctx, "verpkey", s.authrefresh, &verpSecrets); err != nil {
+		return nil, kerrors.WithKind(err, governor.ErrInvalidConfig, "Invalid verpkey secrets")
+	}
+	if len(verpSecrets.Keys) == 0 {
+		return nil, kerrors.WithKind(nil, governor.ErrInvalidConfig, "No verpkey present")
+	}
+	keys := make([][]byte, 0, len(verpSecrets.Keys))
+	for _, i := range verpSecrets.Keys {
+		if i == "" {
+			return nil, kerrors.WithKind(nil, governor.ErrInvalidConfig, "Empty verpkey")
+		}
+		keys = append(keys, []byte(i))
+	}
+	return keys, nil
+}
+
 func (s *Service) closeSecrets(ctx context.Context, secrets *mailSecrets) {
 	// nothing to close
 }
@@ -368,6 +650,19 @@ func (s *Service) getSecrets(ctx context.Context) (*mailSecrets, error) {
 }
 
 func (s *Service) Start(ctx context.Context) error {
+	if s.bounce.domain != "" {
+		s.server = s.createBounceServer()
+		s.wg.Add(1)
+		go ServeSMTP(klog.CtxWithAttrs(ctx, klog.AString("gov.phase", "run")), s.wg, s.log, s.server, "bounce")
+		s.wg.Add(1)
+		go s.verpGCLoop(klog.CtxWithAttrs(ctx, klog.AString("gov.phase", "run")), s.wg)
+	}
+
+	if s.direct.enabled {
+		s.wg.Add(1)
+		go s.deliveryRetryLoop(klog.CtxWithAttrs(ctx, klog.AString("gov.phase", "run")), s.wg)
+	}
+
 	s.wg.Add(1)
 	go events.NewWatcher(
 		s.events,
@@ -385,12 +680,29 @@ func (s *Service) Start(ctx context.Context) error {
 }
 
 func (s *Service) Stop(ctx context.Context) {
+	if s.server != nil {
+		if err := s.server.Shutdown(ctx); err != nil {
+			s.log.Err(ctx, kerrors.WithMsg(err, "Shutdown bounce SMTP server error"))
+		}
+	}
 	if err := s.wg.Wait(ctx); err != nil {
 		s.log.WarnErr(ctx, kerrors.WithMsg(err, "Failed to stop"))
 	}
 }
 
 func (s *Service) Setup(ctx context.Context, req governor.ReqSetup) error {
+	if err := s.suppressions.Setup(ctx); err != nil {
+		return err
+	}
+	s.log.Info(ctx, "Created mail suppression table")
+	if err := s.deliveries.Setup(ctx); err != nil {
+		return err
+	}
+	s.log.Info(ctx, "Created mail delivery table")
+	if err := s.verps.Setup(ctx); err != nil {
+		return err
+	}
+	s.log.Info(ctx, "Created mail verp table")
 	if err := s.mailBucket.Init(ctx); err != nil {
 		return kerrors.WithMsg(err, "Failed to init mail bucket")
 	}
@@ -502,6 +814,7 @@ func (s *Service) mailHandler(ctx context.Context, msgdata []byte) (retErr error
 
 	var tag string
 	var decStream *h2streamcipher.DecStreamReader
+	var authservid string
 
 	if emmsg.Kind == mailMsgKindFwd {
 		data := emmsg.FwdData
@@ -510,6 +823,7 @@ func (s *Service) mailHandler(ctx context.Context, msgdata []byte) (retErr error
 			klog.AString("mail.msg.data.path", data.Path),
 		)
 		s.log.Info(ctx, "Received mail msg to send")
+		authservid = data.AuthservID
 		b1, _, err := s.sendMailDir.Get(ctx, data.Path)
 		if err != nil {
 			if errors.Is(err, objstore.ErrNotFound) {
@@ -546,9 +860,13 @@ func (s *Service) mailHandler(ctx context.Context, msgdata []byte) (retErr error
 	} else {
 		var msgid string
 		var subject, body, htmlbody io.Reader
+		var attData []attachmentData
+		var attEncrypted bool
 		if emmsg.Kind == mailMsgKindRaw {
 			data := emmsg.RawData
 			msgid = data.MsgID
+			attData = data.Attachments
+			attEncrypted = data.Encrypted
 			ctx = klog.CtxWithAttrs(ctx,
 				klog.AString("mail.msg.kind", "raw"),
 				klog.AString("mail.msg.data.path", data.Path),
@@ -584,7 +902,7 @@ func (s *Service) mailHandler(ctx context.Context, msgdata []byte) (retErr error
 					return kerrors.WithKind(err, errMailEvent{}, "Failed to decrypt mail data key")
 				}
 				tag = data.Tag
-				decStream, err = h2streamcipher.NewDecStreamReaderFromParams(string(dataKey), s.streamAlgs, msg)
+				decStream, err = h2streamcipher.NewDecStreamReaderFromParams(string(dataKey), s.streamAlgs, body)
 				if err != nil {
 					if errors.Is(err, h2streamcipher.ErrKeyInvalid) {
 						return kerrors.WithKind(err, errMailEvent{}, "Failed to parse mail data key")
@@ -597,6 +915,8 @@ func (s *Service) mailHandler(ctx context.Context, msgdata []byte) (retErr error
 		} else if emmsg.Kind == mailMsgKindTpl {
 			data := emmsg.TplData
 			msgid = data.MsgID
+			attData = data.Attachments
+			attEncrypted = data.Encrypted
 			ctx = klog.CtxWithAttrs(ctx,
 				klog.AString("mail.msg.kind", "tpl"),
 				klog.AString("mail.msg.id", msgid),
@@ -643,11 +963,44 @@ func (s *Service) mailHandler(ctx context.Context, msgdata []byte) (retErr error
 			return kerrors.WithKind(nil, errMailEvent{}, "Invalid mail message kind")
 		}
 
+		atts, err := s.openAttachments(ctx, attData, attEncrypted)
+		if err != nil {
+			if errors.Is(err, objstore.ErrNotFound) {
+				s.log.Err(ctx, kerrors.WithMsg(err, "Mail attachment content not found"))
+				return nil
+			}
+			return err
+		}
+		defer func() {
+			if err := closeAttachments(atts); err != nil {
+				retErr = errors.Join(retErr, err)
+			}
+		}()
+		mailAtts := make([]mailAttachment, 0, len(atts))
+		for _, i := range atts {
+			mailAtts = append(mailAtts, i.att)
+		}
+
 		b := &bytes.Buffer{}
-		if err := msgToBytes(ctx, msgid, emmsg.From, emmsg.To, subject, body, htmlbody, b); err != nil {
+		if err := msgToBytes(ctx, msgid, emmsg.From, emmsg.To, subject, body, htmlbody, mailAtts, b); err != nil {
 			return err
 		}
 		msg = b
+
+		if err := verifyAttachments(atts); err != nil {
+			return err
+		}
+	}
+
+	secrets, err := s.getSecrets(ctx)
+	if err != nil {
+		return err
+	}
+	// signing reads the entire message, so the decryption stream must be
+	// verified afterwards
+	msg, err = signMsg(msg, secrets.dkim, authservid, time.Now().Round(0))
+	if err != nil {
+		return err
 	}
 
 	if decStream != nil {
@@ -665,13 +1018,105 @@ func (s *Service) mailHandler(ctx context.Context, msgdata []byte) (retErr error
 	for _, i := range emmsg.To {
 		to = append(to, i.Address)
 	}
-	if err := s.handleSendMail(ctx, emmsg.ReturnPath, to, msg); err != nil {
+	if s.catcher != nil {
+		retpath := emmsg.ReturnPath
+		if retpath == "" {
+			retpath = s.returnpath
+		}
+		if err := s.catchMsg(ctx, retpath, to, msg); err != nil {
+			return err
+		}
+		return nil
+	}
+	if s.direct.enabled {
+		b := &bytes.Buffer{}
+		if _, err := io.Copy(b, msg); err != nil {
+			return kerrors.WithMsg(err, "Failed to read mail msg")
+		}
+		tag := emmsg.Tag
+		if tag == "" {
+			u, err := uid.New()
+			if err != nil {
+				return kerrors.WithMsg(err, "Failed to generate mail delivery tag")
+			}
+			tag = u.Base64()
+		}
+		var verps map[string]*verpmodel.Model
+		if emmsg.ReturnPath == "" && emmsg.Tag != "" && s.bounce.domain != "" {
+			verps, err = s.getVERPRcpts(ctx, emmsg.Tag, to)
+			if err != nil {
+				return err
+			}
+		}
+		rcpts := make([]queueRcpt, 0, len(to))
+		for _, i := range to {
+			retpath := emmsg.ReturnPath
+			if retpath == "" {
+				if v, ok := verps[i]; ok {
+					retpath = encodeVERP(secrets.verp[0], s.bounce.prefix, s.bounce.domain, v.Token)
+				} else {
+					retpath = s.returnpath
+				}
+			}
+			rcpts = append(rcpts, queueRcpt{
+				retpath: retpath,
+				rcpt:    i,
+			})
+		}
+		if err := s.queueMsg(ctx, tag, rcpts, b.Bytes()); err != nil {
+			return err
+		}
+		return nil
+	}
+	if emmsg.ReturnPath == "" && emmsg.Tag != "" && s.bounce.domain != "" {
+		// each recipient is sent a separate envelope with its own verp return
+		// path in order for bounces to identify the failed recipient
+		b := &bytes.Buffer{}
+		if _, err := io.Copy(b, msg); err != nil {
+			return kerrors.WithMsg(err, "Failed to read mail msg")
+		}
+		verps, err := s.getVERPRcpts(ctx, emmsg.Tag, to)
+		if err != nil {
+			return err
+		}
+		// the message is sent to all remaining recipients before failing, and
+		// recipients already sent the message are skipped when retried
+		var errs []error
+		for _, i := range to {
+			v, ok := verps[i]
+			if !ok {
+				errs = append(errs, kerrors.WithMsg(nil, "Missing verp recipient"))
+				continue
+			}
+			if v.Sent {
+				continue
+			}
+			retpath := encodeVERP(secrets.verp[0], s.bounce.prefix, s.bounce.domain, v.Token)
+			if err := s.handleSendMail(ctx, retpath, []string{i}, bytes.NewReader(b.Bytes())); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			v.Sent = true
+			if err := s.verps.Update(ctx, v); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if len(errs) > 0 {
+			return kerrors.WithMsg(errors.Join(errs...), "Failed to send mail to recipients")
+		}
+		return nil
+	}
+	retpath := emmsg.ReturnPath
+	if retpath == "" {
+		retpath = s.returnpath
+	}
+	if err := s.handleSendMail(ctx, retpath, to, msg); err != nil {
 		return err
 	}
 	return nil
 }
 
-func msgToBytes(ctx context.Context, msgid string, from Addr, to []Addr, subject, body, htmlbody io.Reader, dst io.Writer) (retErr error) {
+func msgToBytes(ctx context.Context, msgid string, from Addr, to []Addr, subject, body, htmlbody io.Reader, atts []mailAttachment, dst io.Writer) (retErr error) {
 	var h emmail.Header
 	h.SetMessageID(msgid)
 	h.SetDate(time.Now().Round(0).UTC())
@@ -695,6 +1140,10 @@ func msgToBytes(ctx context.Context, msgid string, from Addr, to []Addr, subject
 	}
 	h.SetAddressList("To", emto)
 
+	if len(atts) > 0 {
+		return mixedMsgToBytes(h, body, htmlbody, atts, dst)
+	}
+
 	if htmlbody == nil {
 		h.SetContentType(mediaTypeTextPlain, map[string]string{"charset": "utf-8"})
 		mw, err := emmail.CreateSingleInlineWriter(dst, h)
@@ -779,9 +1228,18 @@ func (s *Service) gcHandler(ctx context.Context, msgdata []byte) error {
 	if err := kjson.Unmarshal(msgdata, &gcmsg); err != nil {
 		return kerrors.WithKind(err, errMailEvent{}, "Failed to decode mail gc message")
 	}
-	if err := s.sendMailDir.Del(ctx, gcmsg.MsgPath); err != nil {
-		if !errors.Is(err, objstore.ErrNotFound) {
-			return kerrors.WithMsg(err, "Failed to delete mail body")
+	if gcmsg.MsgPath != "" {
+		if err := s.sendMailDir.Del(ctx, gcmsg.MsgPath); err != nil {
+			if !errors.Is(err, objstore.ErrNotFound) {
+				return kerrors.WithMsg(err, "Failed to delete mail body")
+			}
+		}
+	}
+	for _, i := range gcmsg.AttPaths {
+		if err := s.sendAttDir.Del(ctx, i); err != nil {
+			if !errors.Is(err, objstore.ErrNotFound) {
+				return kerrors.WithMsg(err, "Failed to delete mail attachment")
+			}
 		}
 	}
 	return nil
@@ -797,10 +1255,25 @@ func genMsgID(msgiddomain string) (string, error) {
 
 // SendTpl creates and sends a message given a template and data
 func (s *Service) SendTpl(ctx context.Context, retpath string, from Addr, to []Addr, tpl Tpl, emdata interface{}, encrypt bool) error {
+	return s.SendTplAttach(ctx, retpath, from, to, tpl, emdata, nil, encrypt)
+}
+
+// SendTplAttach creates and sends a message with attachments given a template
+// and data
+func (s *Service) SendTplAttach(ctx context.Context, retpath string, from Addr, to []Addr, tpl Tpl, emdata interface{}, atts []Attachment, encrypt bool) error {
 	if len(to) == 0 {
 		return kerrors.WithKind(nil, ErrInvalidMail, "Email must have at least one recipient")
 	}
 
+	to, err := s.FilterSuppressed(ctx, to)
+	if err != nil {
+		return err
+	}
+	if len(to) == 0 {
+		// all recipients are suppressed
+		return nil
+	}
+
 	msgid, err := genMsgID(s.msgiddomain)
 	if err != nil {
 		return err
@@ -828,9 +1301,6 @@ func (s *Service) SendTpl(ctx context.Context, retpath string, from Addr, to []A
 		}
 	}
 
-	if retpath == "" {
-		retpath = s.returnpath
-	}
 	if from.Address == "" {
 		from.Address = s.fromAddress
 	}
@@ -838,36 +1308,76 @@ func (s *Service) SendTpl(ctx context.Context, retpath string, from Addr, to []A
 		from.Name = s.fromName
 	}
 
-	b, err := kjson.Marshal(mailEventEnc{
+	attData, err := s.putAttachments(ctx, path, atts, encrypt)
+	if err != nil {
+		return err
+	}
+
+	b0, err := kjson.Marshal(mailEventEnc{
 		Kind: mailEventKindMail,
 		Payload: mailmsg{
 			ReturnPath: retpath,
+			Tag:        path,
 			From:       from,
 			To:         to,
 			Kind:       mailMsgKindTpl,
 			TplData: tplData{
-				MsgID:     msgid,
-				Tpl:       tpl,
-				Emdata:    datastring,
-				Encrypted: encrypt,
+				MsgID:       msgid,
+				Tpl:         tpl,
+				Emdata:      datastring,
+				Attachments: attData,
+				Encrypted:   encrypt,
 			},
 		},
 	})
 	if err != nil {
+		s.delAttachments(ctx, attachmentPaths(attData))
+		return kerrors.WithMsg(err, "Failed to encode mail event to json")
+	}
+	if len(attData) == 0 {
+		if err := s.events.Publish(ctx, events.NewMsgs(s.streammail, path, b0)...); err != nil {
+			return kerrors.WithMsg(err, "Failed to publish mail event")
+		}
+		return nil
+	}
+	b1, err := kjson.Marshal(mailEventEnc{
+		Kind: mailEventKindGC,
+		Payload: mailgcmsg{
+			AttPaths: attachmentPaths(attData),
+		},
+	})
+	if err != nil {
+		s.delAttachments(ctx, attachmentPaths(attData))
 		return kerrors.WithMsg(err, "Failed to encode mail event to json")
 	}
-	if err := s.events.Publish(ctx, events.NewMsgs(s.streammail, path, b)...); err != nil {
-		return kerrors.WithMsg(err, "Failed to publish mail event")
+	if err := s.events.Publish(ctx, events.NewMsgs(s.streammail, path, b0, b1)...); err != nil {
+		s.delAttachments(ctx, attachmentPaths(attData))
+		return kerrors.WithMsg(err, "Failed to publish mail events")
 	}
 	return nil
 }
 
 // SendStream creates and sends a message from a given body
 func (s *Service) SendStream(ctx context.Context, retpath string, from Addr, to []Addr, subject string, size int64, body io.Reader, encrypt bool) error {
+	return s.SendStreamAttach(ctx, retpath, from, to, subject, size, body, nil, encrypt)
+}
+
+// SendStreamAttach creates and sends a message with attachments from a given
+// body
+func (s *Service) SendStreamAttach(ctx context.Context, retpath string, from Addr, to []Addr, subject string, size int64, body io.Reader, atts []Attachment, encrypt bool) error {
 	if len(to) == 0 {
 		return kerrors.WithKind(nil, ErrInvalidMail, "Email must have at least one recipient")
 	}
 
+	to, err := s.FilterSuppressed(ctx, to)
+	if err != nil {
+		return err
+	}
+	if len(to) == 0 {
+		// all recipients are suppressed
+		return nil
+	}
+
 	msgid, err := genMsgID(s.msgiddomain)
 	if err != nil {
 		return err
@@ -921,9 +1431,11 @@ func (s *Service) SendStream(ctx context.Context, retpath string, from Addr, to
 		tag = encStream.Tag()
 	}
 
-	if retpath == "" {
-		retpath = s.returnpath
+	attData, err := s.putAttachments(ctx, path, atts, encrypt)
+	if err != nil {
+		return err
 	}
+
 	if from.Address == "" {
 		from.Address = s.fromAddress
 	}
@@ -935,32 +1447,38 @@ func (s *Service) SendStream(ctx context.Context, retpath string, from Addr, to
 		Kind: mailEventKindMail,
 		Payload: mailmsg{
 			ReturnPath: retpath,
+			Tag:        path,
 			From:       from,
 			To:         to,
 			Kind:       mailMsgKindRaw,
 			RawData: rawData{
-				MsgID:     msgid,
-				Subject:   subject,
-				Path:      path,
-				Key:       key,
-				Tag:       tag,
-				Encrypted: encrypt,
+				MsgID:       msgid,
+				Subject:     subject,
+				Path:        path,
+				Key:         key,
+				Tag:         tag,
+				Attachments: attData,
+				Encrypted:   encrypt,
 			},
 		},
 	})
 	if err != nil {
+		s.delAttachments(ctx, attachmentPaths(attData))
 		return kerrors.WithMsg(err, "Failed to encode mail event to json")
 	}
 	b1, err := kjson.Marshal(mailEventEnc{
 		Kind: mailEventKindGC,
 		Payload: mailgcmsg{
-			MsgPath: path,
+			MsgPath:  path,
+			AttPaths: attachmentPaths(attData),
 		},
 	})
 	if err != nil {
+		s.delAttachments(ctx, attachmentPaths(attData))
 		return kerrors.WithMsg(err, "Failed to encode mail event to json")
 	}
 	if err := s.events.Publish(ctx, events.NewMsgs(s.streammail, path, b0, b1)...); err != nil {
+		s.delAttachments(ctx, attachmentPaths(attData))
 		return kerrors.WithMsg(err, "Failed to publish mail events")
 	}
 	return nil
@@ -968,6 +1486,13 @@ func (s *Service) SendStream(ctx context.Context, retpath string, from Addr, to
 
 // FwdStream forwards an rfc5322 message
 func (s *Service) FwdStream(ctx context.Context, retpath string, to []Addr, size int64, body io.Reader, encrypt bool) error {
+	return s.FwdStreamSeal(ctx, retpath, to, "", size, body, encrypt)
+}
+
+// FwdStreamSeal forwards an rfc5322 message, and seals it with an arc set
+// containing the authentication results added by authservid if authservid is
+// not empty
+func (s *Service) FwdStreamSeal(ctx context.Context, retpath string, to []Addr, authservid string, size int64, body io.Reader, encrypt bool) error {
 	if len(to) == 0 {
 		return kerrors.WithKind(nil, ErrInvalidMail, "Email must have at least one recipient")
 	}
@@ -1015,21 +1540,19 @@ func (s *Service) FwdStream(ctx context.Context, retpath string, to []Addr, size
 		tag = encStream.Tag()
 	}
 
-	if retpath == "" {
-		retpath = s.returnpath
-	}
-
 	b0, err := kjson.Marshal(mailEventEnc{
 		Kind: mailEventKindMail,
 		Payload: mailmsg{
 			ReturnPath: retpath,
+			Tag:        path,
 			To:         to,
 			Kind:       mailMsgKindFwd,
 			FwdData: fwdData{
-				Path:      path,
-				Key:       key,
-				Tag:       tag,
-				Encrypted: encrypt,
+				Path:       path,
+				Key:        key,
+				Tag:        tag,
+				AuthservID: authservid,
+				Encrypted:  encrypt,
 			},
 		},
 	})
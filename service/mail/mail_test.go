@@ -3,15 +3,26 @@ package mail
 import (
 	"bytes"
 	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
 	"io"
 	"mime"
 	"mime/multipart"
 	"mime/quotedprintable"
+	"net"
 	gomail "net/mail"
 	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
 
+	"github.com/emersion/go-msgauth/authres"
+	"github.com/emersion/go-msgauth/dkim"
 	"github.com/stretchr/testify/require"
+	"xorkevin.dev/governor/service/mail/deliverymodel"
+	"xorkevin.dev/governor/service/objstore"
+	"xorkevin.dev/governor/util/dns"
 )
 
 func TestBuildMail(t *testing.T) {
@@ -45,7 +56,7 @@ func TestBuildMail(t *testing.T) {
 			assert := require.New(t)
 
 			var buf bytes.Buffer
-			assert.NoError(msgToBytes(context.Background(), tc.MsgID, tc.From, tc.To, strings.NewReader(tc.Subject), strings.NewReader(tc.Body), nil, &buf))
+			assert.NoError(msgToBytes(context.Background(), tc.MsgID, tc.From, tc.To, strings.NewReader(tc.Subject), strings.NewReader(tc.Body), nil, nil, &buf))
 			t.Log(buf.String())
 			m, err := gomail.ReadMessage(bytes.NewBuffer(buf.Bytes()))
 			assert.NoError(err)
@@ -107,7 +118,7 @@ func TestBuildMail(t *testing.T) {
 			assert := require.New(t)
 
 			var buf bytes.Buffer
-			assert.NoError(msgToBytes(context.Background(), tc.MsgID, tc.From, tc.To, strings.NewReader(tc.Subject), strings.NewReader(tc.Body), strings.NewReader(tc.HtmlBody), &buf))
+			assert.NoError(msgToBytes(context.Background(), tc.MsgID, tc.From, tc.To, strings.NewReader(tc.Subject), strings.NewReader(tc.Body), strings.NewReader(tc.HtmlBody), nil, &buf))
 			t.Log(buf.String())
 			m, err := gomail.ReadMessage(bytes.NewBuffer(buf.Bytes()))
 			assert.NoError(err)
@@ -164,4 +175,651 @@ func TestBuildMail(t *testing.T) {
 			assert.Equal(io.EOF, err)
 		})
 	}
+
+	for _, tc := range []struct {
+		Test     string
+		MsgID    string
+		Subject  string
+		From     Addr
+		To       []Addr
+		Body     string
+		HtmlBody string
+		Inline   string
+		Attached string
+	}{
+		{
+			Test:    "html email with inline image and attachment",
+			Subject: "Hello World",
+			MsgID:   "msgid@mail.example.com",
+			From: Addr{
+				Address: "kevin@xorkevin.com",
+				Name:    "Kevin Wang",
+			},
+			To: []Addr{
+				{Address: "other@xorkevin.com"},
+			},
+			Body:     "This is a test plain text alternate.",
+			HtmlBody: `<html><body><img src="cid:logo@mail.example.com"/></body></html>`,
+			Inline:   "not really a png",
+			Attached: "some attached text that goes over the line limit of 78 characters for base64.",
+		},
+	} {
+		t.Run(tc.Test, func(t *testing.T) {
+			t.Parallel()
+			assert := require.New(t)
+
+			var buf bytes.Buffer
+			assert.NoError(msgToBytes(context.Background(), tc.MsgID, tc.From, tc.To, strings.NewReader(tc.Subject), strings.NewReader(tc.Body), strings.NewReader(tc.HtmlBody), []mailAttachment{
+				{
+					filename:    "logo.png",
+					contentType: "image/png",
+					contentID:   "logo@mail.example.com",
+					body:        strings.NewReader(tc.Inline),
+				},
+				{
+					filename:    "notes.txt",
+					contentType: "text/plain",
+					body:        strings.NewReader(tc.Attached),
+				},
+			}, &buf))
+			t.Log(buf.String())
+			m, err := gomail.ReadMessage(bytes.NewBuffer(buf.Bytes()))
+			assert.NoError(err)
+			assert.Equal("<"+tc.MsgID+">", m.Header.Get("Message-Id"))
+			mixedcontenttype, mixedparams, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+			assert.NoError(err)
+			assert.Equal("multipart/mixed", mixedcontenttype)
+			r := multipart.NewReader(m.Body, mixedparams["boundary"])
+
+			related, err := r.NextPart()
+			assert.NoError(err)
+			relcontenttype, relparams, err := mime.ParseMediaType(related.Header.Get("Content-Type"))
+			assert.NoError(err)
+			assert.Equal("multipart/related", relcontenttype)
+			assert.Equal("multipart/alternative", relparams["type"])
+			rr := multipart.NewReader(related, relparams["boundary"])
+			alt, err := rr.NextPart()
+			assert.NoError(err)
+			altcontenttype, altparams, err := mime.ParseMediaType(alt.Header.Get("Content-Type"))
+			assert.NoError(err)
+			assert.Equal("multipart/alternative", altcontenttype)
+			b := multipart.NewReader(alt, altparams["boundary"])
+			plain, err := b.NextPart()
+			assert.NoError(err)
+			plainbytes, err := io.ReadAll(plain)
+			assert.NoError(err)
+			assert.Equal(tc.Body, string(plainbytes))
+			htmlpart, err := b.NextPart()
+			assert.NoError(err)
+			htmlbytes, err := io.ReadAll(htmlpart)
+			assert.NoError(err)
+			assert.Equal(tc.HtmlBody, string(htmlbytes))
+			_, err = b.NextPart()
+			assert.Equal(io.EOF, err)
+			inline, err := rr.NextPart()
+			assert.NoError(err)
+			assert.Equal("image/png", inline.Header.Get("Content-Type"))
+			assert.Equal("<logo@mail.example.com>", inline.Header.Get("Content-Id"))
+			disp, dispparams, err := mime.ParseMediaType(inline.Header.Get("Content-Disposition"))
+			assert.NoError(err)
+			assert.Equal("inline", disp)
+			assert.Equal("logo.png", dispparams["filename"])
+			inlinebytes, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, inline))
+			assert.NoError(err)
+			assert.Equal(tc.Inline, string(inlinebytes))
+			_, err = rr.NextPart()
+			assert.Equal(io.EOF, err)
+
+			attached, err := r.NextPart()
+			assert.NoError(err)
+			disp, dispparams, err = mime.ParseMediaType(attached.Header.Get("Content-Disposition"))
+			assert.NoError(err)
+			assert.Equal("attachment", disp)
+			assert.Equal("notes.txt", dispparams["filename"])
+			assert.Equal("base64", attached.Header.Get("Content-Transfer-Encoding"))
+			attachedbytes, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, attached))
+			assert.NoError(err)
+			assert.Equal(tc.Attached, string(attachedbytes))
+			_, err = r.NextPart()
+			assert.Equal(io.EOF, err)
+		})
+	}
+}
+
+func TestSignMsg(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	ctx := context.Background()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(err)
+	key := &dkimKey{
+		domain:   "lists.example.com",
+		selector: "sel",
+		alg:      dkimAlgEd25519,
+		signer:   priv,
+	}
+	resolver := dns.NewMockResolver(map[string]dns.MockZone{
+		dns.FQDN("sel._domainkey.lists.example.com"): {
+			TXT: []string{"v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(pub)},
+		},
+	})
+
+	msg := strings.Join([]string{
+		"Authentication-Results: lists.example.com; spf=pass smtp.mailfrom=kevin@xorkevin.com",
+		"From: Kevin Wang <kevin@xorkevin.com>",
+		"To: list@lists.example.com",
+		"Subject: Hello World",
+		"Message-Id: <msgid@mail.example.com>",
+		"",
+		"This is a test  body.",
+		"",
+		"",
+	}, "\r\n")
+
+	res, err := VerifyARC(ctx, []byte(msg), resolver)
+	assert.NoError(err)
+	assert.Equal(authres.ResultNone, res)
+
+	signed, err := signMsg(strings.NewReader(msg), key, "lists.example.com", time.Now().Round(0))
+	assert.NoError(err)
+	signedBytes, err := io.ReadAll(signed)
+	assert.NoError(err)
+	t.Log(string(signedBytes))
+
+	verifications, err := dkim.VerifyWithOptions(bytes.NewReader(signedBytes), &dkim.VerifyOptions{
+		LookupTXT: func(domain string) ([]string, error) {
+			return resolver.LookupTXT(ctx, domain)
+		},
+	})
+	assert.NoError(err)
+	assert.Len(verifications, 1)
+	assert.NoError(verifications[0].Err)
+	assert.Equal("lists.example.com", verifications[0].Domain)
+
+	res, err = VerifyARC(ctx, signedBytes, resolver)
+	assert.NoError(err)
+	assert.Equal(authres.ResultValue(authres.ResultPass), res)
+
+	fields, _, err := splitMsg(signedBytes)
+	assert.NoError(err)
+	sets, err := arcSets(fields)
+	assert.NoError(err)
+	assert.Len(sets, 1)
+	assert.Equal(arcChainNone, parseTagList(sets[0].as.value())["cv"])
+
+	resealed, err := arcSeal(append([]byte("Authentication-Results: lists.example.com; arc=pass\r\n"), signedBytes...), "lists.example.com", key, time.Now().Round(0))
+	assert.NoError(err)
+	res, err = VerifyARC(ctx, resealed, resolver)
+	assert.NoError(err)
+	assert.Equal(authres.ResultValue(authres.ResultPass), res)
+	fields, _, err = splitMsg(resealed)
+	assert.NoError(err)
+	sets, err = arcSets(fields)
+	assert.NoError(err)
+	assert.Len(sets, 2)
+	assert.Equal(arcChainPass, parseTagList(sets[1].as.value())["cv"])
+
+	res, err = VerifyARC(ctx, bytes.Replace(resealed, []byte("test  body"), []byte("modified body"), 1), resolver)
+	assert.Error(err)
+	assert.Equal(authres.ResultValue(authres.ResultFail), res)
+
+	_, err = arcSeal([]byte(msg), "other.example.com", key, time.Now().Round(0))
+	assert.ErrorIs(err, ErrInvalidMail)
+}
+
+func TestVERP(t *testing.T) {
+	t.Parallel()
+
+	key1 := []byte("verp key 1")
+	key2 := []byte("verp key 2")
+	token := "aaaqeayeaudaocajbifqydiob4"
+
+	addr := encodeVERP(key1, "bounce", "bounce.example.com", token)
+	localPart, _, _ := strings.Cut(addr, "@")
+	require.LessOrEqual(t, len(localPart), 64)
+
+	for _, tc := range []struct {
+		Test  string
+		Keys  [][]byte
+		Addr  string
+		Token string
+		Err   bool
+	}{
+		{
+			Test:  "round trip",
+			Keys:  [][]byte{key1},
+			Addr:  addr,
+			Token: token,
+		},
+		{
+			Test:  "rotated key",
+			Keys:  [][]byte{key2, key1},
+			Addr:  addr,
+			Token: token,
+		},
+		{
+			Test:  "case insensitive",
+			Keys:  [][]byte{key1},
+			Addr:  strings.ToUpper(addr),
+			Token: token,
+		},
+		{
+			Test: "unknown key",
+			Keys: [][]byte{key2},
+			Addr: addr,
+			Err:  true,
+		},
+		{
+			Test: "other domain",
+			Keys: [][]byte{key1},
+			Addr: strings.Replace(addr, "bounce.example.com", "other.example.com", 1),
+			Err:  true,
+		},
+		{
+			Test: "other prefix",
+			Keys: [][]byte{key1},
+			Addr: strings.Replace(addr, "bounce+", "other+", 1),
+			Err:  true,
+		},
+		{
+			Test: "tampered token",
+			Keys: [][]byte{key1},
+			Addr: strings.Replace(addr, token, "baaqeayeaudaocajbifqydiob4", 1),
+			Err:  true,
+		},
+		{
+			Test: "tampered mac",
+			Keys: [][]byte{key1},
+			Addr: strings.Replace(addr, "@", "a@", 1),
+			Err:  true,
+		},
+		{
+			Test: "missing mac",
+			Keys: [][]byte{key1},
+			Addr: "bounce+" + token + "@bounce.example.com",
+			Err:  true,
+		},
+		{
+			Test: "not an address",
+			Keys: [][]byte{key1},
+			Addr: "bounce",
+			Err:  true,
+		},
+	} {
+		t.Run(tc.Test, func(t *testing.T) {
+			t.Parallel()
+
+			assert := require.New(t)
+
+			token, err := decodeVERP(tc.Keys, "bounce", "bounce.example.com", tc.Addr)
+			if tc.Err {
+				assert.ErrorIs(err, errVERP{})
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tc.Token, token)
+		})
+	}
+}
+
+func TestParseBounceReport(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		Test       string
+		Msg        string
+		Kind       string
+		Suppress   bool
+		Suppressed string
+		Err        bool
+	}{
+		{
+			Test: "permanent delivery failure",
+			Msg: `From: MAILER-DAEMON@mx.example.org
+To: bounce+tag.abc.def@bounce.example.com
+Subject: Undelivered Mail Returned to Sender
+MIME-Version: 1.0
+Content-Type: multipart/report; report-type=delivery-status; boundary="BOUNDARY"
+
+--BOUNDARY
+Content-Type: text/plain
+
+This message could not be delivered.
+
+--BOUNDARY
+Content-Type: message/delivery-status
+
+Reporting-MTA: dns; mx.example.org
+Arrival-Date: Mon, 1 Jan 2024 00:00:00 +0000
+
+Final-Recipient: rfc822; user@example.org
+Action: failed
+Status: 5.1.1
+Diagnostic-Code: smtp; 550 5.1.1 <user@example.org>:
+ Recipient address rejected
+
+--BOUNDARY--
+`,
+			Kind:       reportKindDSN,
+			Suppress:   true,
+			Suppressed: "bounce",
+		},
+		{
+			Test: "transient delivery failure",
+			Msg: `From: MAILER-DAEMON@mx.example.org
+MIME-Version: 1.0
+Content-Type: multipart/report; report-type=delivery-status; boundary="BOUNDARY"
+
+--BOUNDARY
+Content-Type: message/delivery-status
+
+Reporting-MTA: dns; mx.example.org
+
+Final-Recipient: rfc822; user@example.org
+Action: delayed
+Status: 4.4.1 (connection timed out)
+
+--BOUNDARY--
+`,
+			Kind:     reportKindDSN,
+			Suppress: false,
+		},
+		{
+			Test: "abuse complaint",
+			Msg: `From: feedback@isp.example.org
+MIME-Version: 1.0
+Content-Type: multipart/report; report-type=feedback-report; boundary="BOUNDARY"
+
+--BOUNDARY
+Content-Type: text/plain
+
+This is an email abuse report.
+
+--BOUNDARY
+Content-Type: message/feedback-report
+
+Feedback-Type: abuse
+User-Agent: SomeGenerator/1.0
+Version: 1
+
+--BOUNDARY--
+`,
+			Kind:       reportKindARF,
+			Suppress:   true,
+			Suppressed: "complaint",
+		},
+		{
+			Test: "auto reply",
+			Msg: `From: user@example.org
+Content-Type: text/plain
+
+I am out of office.
+`,
+			Err: true,
+		},
+	} {
+		t.Run(tc.Test, func(t *testing.T) {
+			t.Parallel()
+			assert := require.New(t)
+
+			report, err := parseBounceReport(strings.NewReader(strings.ReplaceAll(tc.Msg, "\n", "\r\n")))
+			if tc.Err {
+				assert.ErrorIs(err, errBounceReport{})
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tc.Kind, report.Kind)
+			kind, _, ok := report.suppression()
+			assert.Equal(tc.Suppress, ok)
+			assert.Equal(tc.Suppressed, kind)
+		})
+	}
+}
+
+func TestTruncateReason(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		Test   string
+		Reason string
+		Len    int
+	}{
+		{
+			Test:   "short",
+			Reason: "mailbox full",
+			Len:    len("mailbox full"),
+		},
+		{
+			Test:   "exact length",
+			Reason: strings.Repeat("a", lengthCapReason),
+			Len:    lengthCapReason,
+		},
+		{
+			Test:   "ascii",
+			Reason: strings.Repeat("a", lengthCapReason+1),
+			Len:    lengthCapReason,
+		},
+		{
+			Test:   "splits two byte rune",
+			Reason: strings.Repeat("a", lengthCapReason-1) + "é",
+			Len:    lengthCapReason - 1,
+		},
+		{
+			Test:   "splits three byte rune",
+			Reason: strings.Repeat("a", lengthCapReason-2) + "€",
+			Len:    lengthCapReason - 2,
+		},
+	} {
+		t.Run(tc.Test, func(t *testing.T) {
+			t.Parallel()
+
+			assert := require.New(t)
+
+			reason := truncateReason(tc.Reason)
+			assert.Len(reason, tc.Len)
+			assert.True(utf8.ValidString(reason))
+			assert.True(strings.HasPrefix(tc.Reason, reason))
+		})
+	}
+}
+
+func TestDeliveryBackoff(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		Attempts int
+		Backoff  time.Duration
+	}{
+		{Attempts: 1, Backoff: 5 * time.Minute},
+		{Attempts: 2, Backoff: 10 * time.Minute},
+		{Attempts: 4, Backoff: 40 * time.Minute},
+		{Attempts: 6, Backoff: 160 * time.Minute},
+		{Attempts: 7, Backoff: 4 * time.Hour},
+		{Attempts: 64, Backoff: 4 * time.Hour},
+	} {
+		require.Equal(t, tc.Backoff, deliveryBackoff(5*time.Minute, 4*time.Hour, tc.Attempts))
+	}
+}
+
+func TestLookupMX(t *testing.T) {
+	t.Parallel()
+
+	s := &Service{
+		resolver: dns.NewMockResolver(map[string]dns.MockZone{
+			"example.com.": {
+				MX: []net.MX{
+					{Host: "mx2.example.com.", Pref: 20},
+					{Host: "mx1.example.com.", Pref: 10},
+				},
+			},
+			"nomail.example.com.": {
+				MX: []net.MX{
+					{Host: ".", Pref: 0},
+				},
+			},
+		}),
+	}
+
+	for _, tc := range []struct {
+		Test   string
+		Domain string
+		Hosts  []string
+		Err    error
+	}{
+		{
+			Test:   "orders by preference",
+			Domain: "example.com",
+			Hosts:  []string{"mx1.example.com", "mx2.example.com"},
+		},
+		{
+			Test:   "implicit mx",
+			Domain: "implicit.example.com",
+			Hosts:  []string{"implicit.example.com"},
+		},
+		{
+			Test:   "null mx",
+			Domain: "nomail.example.com",
+			Err:    errDeliverPerm{},
+		},
+	} {
+		t.Run(tc.Test, func(t *testing.T) {
+			t.Parallel()
+			assert := require.New(t)
+
+			hosts, err := s.lookupMX(context.Background(), tc.Domain)
+			if tc.Err != nil {
+				assert.ErrorIs(err, tc.Err)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tc.Hosts, hosts)
+		})
+	}
+}
+
+func TestBuildDSN(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	m := &deliverymodel.Model{
+		Tag:          "tag",
+		Rcpt:         "user@example.org",
+		Domain:       "example.org",
+		RetPath:      "sender@example.com",
+		Status:       deliverymodel.StatusFailed,
+		LastError:    "SMTP error 550: mailbox unavailable",
+		CreationTime: time.Now().Round(0).Unix(),
+	}
+	msg := []byte("From: sender@example.com\r\nTo: user@example.org\r\nSubject: hello\r\n\r\nbody\r\n")
+	b := &bytes.Buffer{}
+	assert.NoError(buildDSN("msgid@mail.example.com", "no-reply@mail.example.com", "mail.example.com", m, "5.1.1", msg, b))
+	assert.NotContains(b.String(), "body")
+
+	report, err := parseBounceReport(bytes.NewReader(b.Bytes()))
+	assert.NoError(err)
+	assert.Equal(reportKindDSN, report.Kind)
+	assert.Len(report.Recipients, 1)
+	assert.Equal("user@example.org", report.Recipients[0].FinalRecipient)
+	kind, reason, ok := report.suppression()
+	assert.True(ok)
+	assert.Equal("bounce", kind)
+	assert.Equal("5.1.1 smtp; SMTP error 550: mailbox unavailable", reason)
+}
+
+func TestMailCatcher(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		Test    string
+		Catcher func() catcherStore
+	}{
+		{
+			Test: "mem",
+			Catcher: func() catcherStore {
+				return newMailCatcher(2)
+			},
+		},
+		{
+			Test: "objstore",
+			Catcher: func() catcherStore {
+				return newObjCatcher(objstore.NewMemBucket().Subdir("catcher"), 2)
+			},
+		},
+	} {
+		t.Run(tc.Test, func(t *testing.T) {
+			t.Parallel()
+
+			assert := require.New(t)
+
+			ctx := context.Background()
+			c := tc.Catcher()
+
+			now := time.Now().Round(0)
+			var ids []string
+			for n, i := range []string{"a", "b", "c"} {
+				id, err := newCaughtMsgID(now.Add(time.Duration(n) * time.Second))
+				assert.NoError(err)
+				ids = append(ids, id)
+				assert.NoError(c.add(ctx, caughtMsg{
+					id:           id,
+					retpath:      "bounce@example.com",
+					to:           []string{i + "@example.com"},
+					raw:          []byte(i),
+					creationTime: int64(n),
+				}))
+			}
+
+			_, ok, err := c.get(ctx, ids[0])
+			assert.NoError(err)
+			assert.False(ok)
+			m, ok, err := c.get(ctx, ids[1])
+			assert.NoError(err)
+			assert.True(ok)
+			assert.Equal(&caughtMsg{
+				id:           ids[1],
+				retpath:      "bounce@example.com",
+				to:           []string{"b@example.com"},
+				raw:          []byte("b"),
+				creationTime: 1,
+			}, m)
+
+			msgs, err := c.list(ctx, 8, 0)
+			assert.NoError(err)
+			assert.Len(msgs, 2)
+			assert.Equal(ids[2], msgs[0].id)
+			assert.Equal(ids[1], msgs[1].id)
+			msgs, err = c.list(ctx, 8, 1)
+			assert.NoError(err)
+			assert.Len(msgs, 1)
+			assert.Equal(ids[1], msgs[0].id)
+			msgs, err = c.list(ctx, 8, 2)
+			assert.NoError(err)
+			assert.Len(msgs, 0)
+
+			assert.NoError(c.clear(ctx))
+			msgs, err = c.list(ctx, 8, 0)
+			assert.NoError(err)
+			assert.Len(msgs, 0)
+		})
+	}
+}
+
+func TestParseMsgPreview(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	var buf bytes.Buffer
+	assert.NoError(msgToBytes(context.Background(), "msgid@mail.example.com", Addr{Address: "kevin@xorkevin.com"}, []Addr{{Address: "other@xorkevin.com"}}, strings.NewReader("Hello World"), strings.NewReader("plain body"), strings.NewReader("<p>html body</p>"), nil, &buf))
+	preview, err := parseMsgPreview(buf.Bytes())
+	assert.NoError(err)
+	assert.Equal("Hello World", preview.Subject)
+	assert.Contains(preview.From, "kevin@xorkevin.com")
+	assert.Contains(preview.To, "other@xorkevin.com")
+	assert.Equal("plain body", strings.TrimSpace(preview.Text))
+	assert.Equal("<p>html body</p>", strings.TrimSpace(preview.HTML))
 }
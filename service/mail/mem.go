@@ -3,6 +3,7 @@ package mail
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
 	"slices"
 
@@ -17,49 +18,113 @@ type (
 	}
 
 	LogRecord struct {
-		RetPath string
-		To      []Addr
-		BodyRaw *bytes.Buffer
-		From    Addr
-		Subject string
-		BodyTxt *bytes.Buffer
-		Tpl     Tpl
-		TplData map[string]string
-		Encrypt bool
+		RetPath  string
+		To       []Addr
+		BodyRaw  *bytes.Buffer
+		Authserv string
+		From     Addr
+		Subject  string
+		BodyTxt  *bytes.Buffer
+		Tpl      Tpl
+		TplData  map[string]string
+		Atts     []LogAttachment
+		Encrypt  bool
+	}
+
+	LogAttachment struct {
+		Filename    string
+		ContentType string
+		ContentID   string
+		Body        *bytes.Buffer
 	}
 )
 
+func logAttachments(ctx context.Context, atts []Attachment) ([]LogAttachment, error) {
+	if len(atts) == 0 {
+		return nil, nil
+	}
+	res := make([]LogAttachment, 0, len(atts))
+	for _, i := range atts {
+		buf := &bytes.Buffer{}
+		if i.Body != nil {
+			if _, err := io.Copy(buf, i.Body); err != nil {
+				return nil, err
+			}
+		} else {
+			obj, info, err := i.Dir.Get(ctx, i.Name)
+			if err != nil {
+				return nil, err
+			}
+			_, err = io.Copy(buf, obj)
+			if cerr := obj.Close(); cerr != nil {
+				err = errors.Join(err, cerr)
+			}
+			if err != nil {
+				return nil, err
+			}
+			if i.ContentType == "" {
+				i.ContentType = info.ContentType
+			}
+		}
+		res = append(res, LogAttachment{
+			Filename:    i.Filename,
+			ContentType: i.ContentType,
+			ContentID:   i.ContentID,
+			Body:        buf,
+		})
+	}
+	return res, nil
+}
+
 func (s *MemLog) FwdStream(ctx context.Context, retpath string, to []Addr, size int64, body io.Reader, encrypt bool) error {
+	return s.FwdStreamSeal(ctx, retpath, to, "", size, body, encrypt)
+}
+
+func (s *MemLog) FwdStreamSeal(ctx context.Context, retpath string, to []Addr, authservid string, size int64, body io.Reader, encrypt bool) error {
 	buf := &bytes.Buffer{}
 	if _, err := io.Copy(buf, body); err != nil {
 		return err
 	}
 	s.Records = append(s.Records, LogRecord{
-		RetPath: retpath,
-		To:      slices.Clone(to),
-		BodyRaw: buf,
-		Encrypt: encrypt,
+		RetPath:  retpath,
+		To:       slices.Clone(to),
+		BodyRaw:  buf,
+		Authserv: authservid,
+		Encrypt:  encrypt,
 	})
 	return nil
 }
 
 func (s *MemLog) SendStream(ctx context.Context, retpath string, from Addr, to []Addr, subject string, size int64, body io.Reader, encrypt bool) error {
+	return s.SendStreamAttach(ctx, retpath, from, to, subject, size, body, nil, encrypt)
+}
+
+func (s *MemLog) SendStreamAttach(ctx context.Context, retpath string, from Addr, to []Addr, subject string, size int64, body io.Reader, atts []Attachment, encrypt bool) error {
 	buf := &bytes.Buffer{}
 	if _, err := io.Copy(buf, body); err != nil {
 		return err
 	}
+	logAtts, err := logAttachments(ctx, atts)
+	if err != nil {
+		return err
+	}
 	s.Records = append(s.Records, LogRecord{
 		RetPath: retpath,
 		To:      slices.Clone(to),
 		From:    from,
 		Subject: subject,
 		BodyTxt: buf,
+		Atts:    logAtts,
 		Encrypt: encrypt,
 	})
 	return nil
 }
 
 func (s *MemLog) SendTpl(ctx context.Context, retpath string, from Addr, to []Addr, tpl Tpl, emdata interface{}, encrypt bool) error {
+	return s.SendTplAttach(ctx, retpath, from, to, tpl, emdata, nil, encrypt)
+}
+
+func (s *MemLog) SendTplAttach(ctx context.Context, retpath string, from Addr, to []Addr, tpl Tpl, emdata interface{}, atts []Attachment, encrypt bool) error {
 	b, err := kjson.Marshal(emdata)
 	if err != nil {
 		return err
@@ -68,12 +133,17 @@ func (s *MemLog) SendTpl(ctx context.Context, retpath string, from Addr, to []Ad
 	if err := kjson.Unmarshal(b, &data); err != nil {
 		return err
 	}
+	logAtts, err := logAttachments(ctx, atts)
+	if err != nil {
+		return err
+	}
 	s.Records = append(s.Records, LogRecord{
 		RetPath: retpath,
 		To:      slices.Clone(to),
 		From:    from,
 		Tpl:     tpl,
 		TplData: data,
+		Atts:    logAtts,
 		Encrypt: encrypt,
 	})
 	return nil
@@ -85,3 +155,7 @@ func (s *MemLog) Reset() {
 	}
 	s.Records = s.Records[:0]
 }
+
+func (s *MemLog) FilterSuppressed(ctx context.Context, to []Addr) ([]Addr, error) {
+	return to, nil
+}
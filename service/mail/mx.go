@@ -0,0 +1,313 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-smtp"
+	"xorkevin.dev/governor/util/dns"
+	"xorkevin.dev/kerrors"
+	"xorkevin.dev/klog"
+)
+
+type (
+	// errDeliverPerm is returned when a delivery permanently fails
+	errDeliverPerm struct{}
+	// errDeliverTemp is returned when a delivery temporarily fails
+	errDeliverTemp struct{}
+)
+
+func (e errDeliverPerm) Error() string {
+	return "Permanent delivery failure"
+}
+
+func (e errDeliverTemp) Error() string {
+	return "Temporary delivery failure"
+}
+
+// classifySMTPErr categorizes an smtp error as either a permanent or a
+// temporary failure. Only 5xx replies are permanent. All other errors,
+// including network errors, are temporary.
+func classifySMTPErr(err error, msg string) error {
+	var smtpErr *smtp.SMTPError
+	if errors.As(err, &smtpErr) && smtpErr.Code/100 == 5 {
+		return kerrors.WithKind(err, errDeliverPerm{}, msg)
+	}
+	return kerrors.WithKind(err, errDeliverTemp{}, msg)
+}
+
+// smtpErrStatus returns the enhanced status code of a permanent delivery
+// failure
+func smtpErrStatus(err error) string {
+	var smtpErr *smtp.SMTPError
+	if errors.As(err, &smtpErr) && smtpErr.EnhancedCode[0] == 5 {
+		return fmt.Sprintf("%d.%d.%d", smtpErr.EnhancedCode[0], smtpErr.EnhancedCode[1], smtpErr.EnhancedCode[2])
+	}
+	return "5.0.0"
+}
+
+type (
+	mxConn struct {
+		c        *smtp.Client
+		host     string
+		lastUsed time.Time
+	}
+
+	// mxPool holds at most one idle connection per recipient domain
+	mxPool struct {
+		mu    *sync.Mutex
+		conns map[string]*mxConn
+		idle  time.Duration
+	}
+)
+
+func newMXPool(idle time.Duration) *mxPool {
+	return &mxPool{
+		mu:    &sync.Mutex{},
+		conns: map[string]*mxConn{},
+		idle:  idle,
+	}
+}
+
+// get removes and returns the idle connection for a domain if it exists and
+// has not exceeded the idle duration
+func (p *mxPool) get(domain string, now time.Time) *mxConn {
+	p.mu.Lock()
+	c, ok := p.conns[domain]
+	if ok {
+		delete(p.conns, domain)
+	}
+	p.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	if now.Sub(c.lastUsed) > p.idle {
+		closeMXConn(c)
+		return nil
+	}
+	return c
+}
+
+// put returns a connection to the pool, closing it if the domain already has
+// an idle connection
+func (p *mxPool) put(domain string, c *mxConn) {
+	p.mu.Lock()
+	_, ok := p.conns[domain]
+	if !ok {
+		p.conns[domain] = c
+	}
+	p.mu.Unlock()
+	if ok {
+		closeMXConn(c)
+	}
+}
+
+// closeIdle closes all connections that have exceeded the idle duration
+func (p *mxPool) closeIdle(now time.Time) {
+	var expired []*mxConn
+	p.mu.Lock()
+	for k, v := range p.conns {
+		if now.Sub(v.lastUsed) > p.idle {
+			expired = append(expired, v)
+			delete(p.conns, k)
+		}
+	}
+	p.mu.Unlock()
+	for _, i := range expired {
+		closeMXConn(i)
+	}
+}
+
+func (p *mxPool) closeAll() {
+	p.mu.Lock()
+	conns := p.conns
+	p.conns = map[string]*mxConn{}
+	p.mu.Unlock()
+	for _, i := range conns {
+		closeMXConn(i)
+	}
+}
+
+func closeMXConn(c *mxConn) {
+	if err := c.c.Quit(); err != nil {
+		_ = c.c.Close()
+	}
+}
+
+// lookupMX returns the mail exchange hosts of a domain in order of preference
+//
+// A domain without mx records is its own mail exchange as per RFC 5321
+// section 5.1, and a domain with a null mx record as per RFC 7505 does not
+// accept mail.
+func (s *Service) lookupMX(ctx context.Context, domain string) ([]string, error) {
+	records, err := s.resolver.LookupMX(ctx, domain)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.Is(err, dns.ErrNotFound) || (errors.As(err, &dnsErr) && dnsErr.IsNotFound) {
+			return []string{domain}, nil
+		}
+		return nil, kerrors.WithKind(err, errDeliverTemp{}, "Failed to lookup mx records")
+	}
+	if len(records) == 0 {
+		return []string{domain}, nil
+	}
+	slices.SortStableFunc(records, func(a, b *net.MX) int {
+		return int(a.Pref) - int(b.Pref)
+	})
+	hosts := make([]string, 0, len(records))
+	for _, i := range records {
+		host := strings.TrimSuffix(i.Host, ".")
+		if host == "" {
+			if len(records) == 1 {
+				return nil, kerrors.WithKind(nil, errDeliverPerm{}, "Domain does not accept mail")
+			}
+			continue
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts, nil
+}
+
+// dialMX connects to a mail exchange, opportunistically upgrading the
+// connection with STARTTLS
+func (s *Service) dialMX(ctx context.Context, host string) (*smtp.Client, error) {
+	addr := net.JoinHostPort(host, s.direct.port)
+	dialer := &net.Dialer{
+		Timeout: s.direct.dialtimeout,
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, kerrors.WithKind(err, errDeliverTemp{}, "Failed to connect to mx")
+	}
+	// Opportunistic TLS as per RFC 7435 does not authenticate the server, since
+	// mx hosts are not required to present a certificate valid for their name.
+	c, err := smtp.NewClientStartTLS(conn, &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS12,
+	})
+	if err == nil {
+		c.CommandTimeout = s.direct.cmdtimeout
+		if err := c.Hello(s.direct.helo); err == nil {
+			return c, nil
+		}
+		_ = c.Close()
+	}
+	s.log.Debug(ctx, "Failed mx starttls, falling back to plaintext",
+		klog.AString("mx.host", host),
+	)
+	conn, err = dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, kerrors.WithKind(err, errDeliverTemp{}, "Failed to connect to mx")
+	}
+	c = smtp.NewClient(conn)
+	c.CommandTimeout = s.direct.cmdtimeout
+	if err := c.Hello(s.direct.helo); err != nil {
+		_ = c.Close()
+		return nil, classifySMTPErr(err, "Failed mx hello")
+	}
+	return c, nil
+}
+
+// connMX returns a pooled connection for a domain or dials a new one
+func (s *Service) connMX(ctx context.Context, domain string) (*mxConn, error) {
+	if c := s.mxpool.get(domain, time.Now()); c != nil {
+		if err := c.c.Reset(); err == nil {
+			return c, nil
+		}
+		_ = c.c.Close()
+	}
+	hosts, err := s.lookupMX(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	for _, i := range hosts {
+		c, err := s.dialMX(ctx, i)
+		if err != nil {
+			errs = append(errs, err)
+			if errors.Is(err, errDeliverPerm{}) {
+				// a permanent greeting or hello reply from the most preferred
+				// reachable mx is authoritative
+				return nil, err
+			}
+			continue
+		}
+		return &mxConn{
+			c:    c,
+			host: i,
+		}, nil
+	}
+	return nil, kerrors.WithKind(errors.Join(errs...), errDeliverTemp{}, "Failed to connect to any mx")
+}
+
+// deliverMX delivers a message to recipients of a single domain, returning
+// an error for each recipient that failed delivery
+func (s *Service) deliverMX(ctx context.Context, domain string, from string, rcpts []string, msg []byte) []error {
+	errs := make([]error, len(rcpts))
+	setAll := func(err error) []error {
+		for n, i := range errs {
+			if i == nil {
+				errs[n] = err
+			}
+		}
+		return errs
+	}
+
+	c, err := s.connMX(ctx, domain)
+	if err != nil {
+		return setAll(err)
+	}
+	if err := c.c.Mail(from, &smtp.MailOptions{
+		Size: int64(len(msg)),
+	}); err != nil {
+		_ = c.c.Close()
+		return setAll(classifySMTPErr(err, "Failed mx mail from"))
+	}
+	accepted := 0
+	for n, i := range rcpts {
+		if err := c.c.Rcpt(i, nil); err != nil {
+			errs[n] = classifySMTPErr(err, "Failed mx rcpt to")
+			continue
+		}
+		accepted++
+	}
+	if accepted == 0 {
+		if err := c.c.Reset(); err != nil {
+			_ = c.c.Close()
+			return errs
+		}
+		c.lastUsed = time.Now()
+		s.mxpool.put(domain, c)
+		return errs
+	}
+	if err := func() error {
+		w, err := c.c.Data()
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(msg); err != nil {
+			_ = w.Close()
+			return err
+		}
+		return w.Close()
+	}(); err != nil {
+		_ = c.c.Close()
+		return setAll(classifySMTPErr(err, "Failed mx data"))
+	}
+	c.lastUsed = time.Now()
+	s.mxpool.put(domain, c)
+	s.log.Info(ctx, "Mail delivered to mx",
+		klog.AString("mx.host", c.host),
+		klog.AString("mx.domain", domain),
+		klog.AInt("mx.rcpts", accepted),
+	)
+	return errs
+}
@@ -0,0 +1,193 @@
+package mail
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/user/gate"
+)
+
+const (
+	// sandbox previews of mail html to prevent scripts in html bodies from
+	// running with the credentials of the viewer
+	headerContentSecurityPolicy = "Content-Security-Policy"
+	cspPreviewSandbox           = "sandbox"
+)
+
+type (
+	//forge:valid
+	reqCaughtMsgs struct {
+		Amount int `valid:"amount" json:"-"`
+		Offset int `valid:"offset" json:"-"`
+	}
+)
+
+func (s *router) getCaughtMsgs(c *governor.Context) {
+	req := reqCaughtMsgs{
+		Amount: c.QueryInt("amount", -1),
+		Offset: c.QueryInt("offset", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getCaughtMsgs(c.Ctx(), req.Amount, req.Offset)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+func (s *router) deleteCaughtMsgs(c *governor.Context) {
+	if err := s.s.clearCaughtMsgs(c.Ctx()); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteStatus(http.StatusNoContent)
+}
+
+type (
+	//forge:valid
+	reqCaughtMsg struct {
+		ID string `valid:"msgid,has" json:"-"`
+	}
+)
+
+func (s *router) getCaughtMsg(c *governor.Context) {
+	req := reqCaughtMsg{
+		ID: c.Param("id"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getCaughtMsg(c.Ctx(), req.ID)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+func (s *router) getCaughtMsgRaw(c *governor.Context) {
+	req := reqCaughtMsg{
+		ID: c.Param("id"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getCaughtMsgRaw(c.Ctx(), req.ID)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteFile(http.StatusOK, mediaTypeUTF8(mediaTypeTextPlain), bytes.NewReader(res))
+}
+
+func (s *router) getCaughtMsgText(c *governor.Context) {
+	req := reqCaughtMsg{
+		ID: c.Param("id"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getCaughtMsg(c.Ctx(), req.ID)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteString(http.StatusOK, res.Text)
+}
+
+func (s *router) getCaughtMsgHTML(c *governor.Context) {
+	req := reqCaughtMsg{
+		ID: c.Param("id"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getCaughtMsg(c.Ctx(), req.ID)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.SetHeader(headerContentSecurityPolicy, cspPreviewSandbox)
+	c.WriteFile(http.StatusOK, mediaTypeUTF8(mediaTypeTextHTML), strings.NewReader(res.HTML))
+}
+
+type (
+	//forge:valid
+	reqTplPreview struct {
+		Name string `valid:"tplname,has" json:"-"`
+	}
+)
+
+func (s *router) getTplPreview(c *governor.Context) {
+	req := reqTplPreview{
+		Name: c.Param("name"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.previewTpl(c.Ctx(), req.Name)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+func (s *router) getTplPreviewText(c *governor.Context) {
+	req := reqTplPreview{
+		Name: c.Param("name"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.previewTpl(c.Ctx(), req.Name)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteString(http.StatusOK, res.Text)
+}
+
+func (s *router) getTplPreviewHTML(c *governor.Context) {
+	req := reqTplPreview{
+		Name: c.Param("name"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.previewTpl(c.Ctx(), req.Name)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.SetHeader(headerContentSecurityPolicy, cspPreviewSandbox)
+	c.WriteFile(http.StatusOK, mediaTypeUTF8(mediaTypeTextHTML), strings.NewReader(res.HTML))
+}
+
+func (s *router) mountCatcherRoutes(r governor.Router) {
+	m := governor.NewMethodRouter(r)
+	scopeCatcherRead := s.s.scopens + ".catcher:read"
+	scopeCatcherWrite := s.s.scopens + ".catcher:write"
+	m.GetCtx("/msg", s.getCaughtMsgs, gate.Admin(s.s.gate, scopeCatcherRead), s.rt)
+	m.DeleteCtx("/msg", s.deleteCaughtMsgs, gate.Admin(s.s.gate, scopeCatcherWrite), s.rt)
+	m.GetCtx("/msg/id/{id}", s.getCaughtMsg, gate.Admin(s.s.gate, scopeCatcherRead), s.rt)
+	m.GetCtx("/msg/id/{id}/raw", s.getCaughtMsgRaw, gate.Admin(s.s.gate, scopeCatcherRead), s.rt)
+	m.GetCtx("/msg/id/{id}/text", s.getCaughtMsgText, gate.Admin(s.s.gate, scopeCatcherRead), s.rt)
+	m.GetCtx("/msg/id/{id}/html", s.getCaughtMsgHTML, gate.Admin(s.s.gate, scopeCatcherRead), s.rt)
+	m.GetCtx("/tpl/id/{name}", s.getTplPreview, gate.Admin(s.s.gate, scopeCatcherRead), s.rt)
+	m.GetCtx("/tpl/id/{name}/text", s.getTplPreviewText, gate.Admin(s.s.gate, scopeCatcherRead), s.rt)
+	m.GetCtx("/tpl/id/{name}/html", s.getTplPreviewHTML, gate.Admin(s.s.gate, scopeCatcherRead), s.rt)
+}
@@ -0,0 +1,61 @@
+package mail
+
+import (
+	"net/http"
+
+	"xorkevin.dev/governor"
+)
+
+type (
+	//forge:valid
+	reqDeliveriesTag struct {
+		Tag    string `valid:"tag,has" json:"-"`
+		Amount int    `valid:"amount" json:"-"`
+		Offset int    `valid:"offset" json:"-"`
+	}
+)
+
+func (s *router) getDeliveriesByTag(c *governor.Context) {
+	req := reqDeliveriesTag{
+		Tag:    c.Param("tag"),
+		Amount: c.QueryInt("amount", -1),
+		Offset: c.QueryInt("offset", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getDeliveriesByTag(c.Ctx(), req.Tag, req.Amount, req.Offset)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqDeliveriesRcpt struct {
+		Address string `valid:"address,has" json:"-"`
+		Amount  int    `valid:"amount" json:"-"`
+		Offset  int    `valid:"offset" json:"-"`
+	}
+)
+
+func (s *router) getDeliveriesByRcpt(c *governor.Context) {
+	req := reqDeliveriesRcpt{
+		Address: c.Param("address"),
+		Amount:  c.QueryInt("amount", -1),
+		Offset:  c.QueryInt("offset", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getDeliveriesByRcpt(c.Ctx(), req.Address, req.Amount, req.Offset)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
@@ -0,0 +1,83 @@
+package mail
+
+import (
+	"net/http"
+
+	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/user/gate"
+)
+
+type (
+	//forge:valid
+	reqSuppressions struct {
+		Amount int `valid:"amount" json:"-"`
+		Offset int `valid:"offset" json:"-"`
+	}
+)
+
+func (s *router) getSuppressions(c *governor.Context) {
+	req := reqSuppressions{
+		Amount: c.QueryInt("amount", -1),
+		Offset: c.QueryInt("offset", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getSuppressions(c.Ctx(), req.Amount, req.Offset)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqSuppression struct {
+		Address string `valid:"address,has" json:"-"`
+	}
+)
+
+func (s *router) getSuppression(c *governor.Context) {
+	req := reqSuppression{
+		Address: c.Param("address"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getSuppression(c.Ctx(), req.Address)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+func (s *router) deleteSuppression(c *governor.Context) {
+	req := reqSuppression{
+		Address: c.Param("address"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	if err := s.s.deleteSuppression(c.Ctx(), req.Address); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteStatus(http.StatusNoContent)
+}
+
+func (s *router) mountRoutes(r governor.Router) {
+	m := governor.NewMethodRouter(r)
+	scopeSuppressionRead := s.s.scopens + ".suppression:read"
+	scopeSuppressionWrite := s.s.scopens + ".suppression:write"
+	m.GetCtx("/suppression", s.getSuppressions, gate.Admin(s.s.gate, scopeSuppressionRead), s.rt)
+	m.GetCtx("/suppression/id/{address}", s.getSuppression, gate.Admin(s.s.gate, scopeSuppressionRead), s.rt)
+	m.DeleteCtx("/suppression/id/{address}", s.deleteSuppression, gate.Admin(s.s.gate, scopeSuppressionWrite), s.rt)
+	scopeDeliveryRead := s.s.scopens + ".delivery:read"
+	m.GetCtx("/delivery/id/{tag}", s.getDeliveriesByTag, gate.Admin(s.s.gate, scopeDeliveryRead), s.rt)
+	m.GetCtx("/delivery/rcpt/{address}", s.getDeliveriesByRcpt, gate.Admin(s.s.gate, scopeDeliveryRead), s.rt)
+}
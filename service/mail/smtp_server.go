@@ -0,0 +1,50 @@
+package mail
+
+import (
+	"context"
+	"time"
+
+	"github.com/emersion/go-smtp"
+	"xorkevin.dev/governor/util/ksync"
+	"xorkevin.dev/kerrors"
+	"xorkevin.dev/klog"
+)
+
+type (
+	// SMTPServerOpts are the options of an smtp server
+	SMTPServerOpts struct {
+		Port         string
+		Domain       string
+		MaxMsgSize   int64
+		ReadTimeout  time.Duration
+		WriteTimeout time.Duration
+	}
+)
+
+// NewSMTPServer creates an smtp server that accepts a single recipient per msg
+func NewSMTPServer(be smtp.Backend, opts SMTPServerOpts) *smtp.Server {
+	server := smtp.NewServer(be)
+	server.Addr = ":" + opts.Port
+	server.Domain = opts.Domain
+	server.MaxRecipients = 1
+	server.MaxMessageBytes = opts.MaxMsgSize
+	server.ReadTimeout = opts.ReadTimeout
+	server.WriteTimeout = opts.WriteTimeout
+	return server
+}
+
+// ServeSMTP serves an smtp server until the context is canceled, restarting it
+// if it exits with an error
+func ServeSMTP(ctx context.Context, wg *ksync.WaitGroup, log *klog.LevelLogger, server *smtp.Server, name string) {
+	defer wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+			if err := server.ListenAndServe(); err != nil {
+				log.Err(ctx, kerrors.WithMsg(err, "Shutting down "+name+" SMTP server"))
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/dbsql"
+	"xorkevin.dev/governor/service/mail/suppressionmodel"
+	"xorkevin.dev/kerrors"
+	"xorkevin.dev/klog"
+)
+
+// FilterSuppressed returns the recipients whose addresses are not suppressed
+func (s *Service) FilterSuppressed(ctx context.Context, to []Addr) ([]Addr, error) {
+	if len(to) == 0 {
+		return to, nil
+	}
+	addrs := make([]string, 0, len(to))
+	for _, i := range to {
+		addrs = append(addrs, i.Address)
+	}
+	m, err := s.suppressions.GetByAddresses(ctx, addrs)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get suppressions")
+	}
+	if len(m) == 0 {
+		return to, nil
+	}
+	suppressed := make(map[string]struct{}, len(m))
+	for _, i := range m {
+		suppressed[i.Address] = struct{}{}
+	}
+	res := make([]Addr, 0, len(to))
+	for _, i := range to {
+		if _, ok := suppressed[suppressionmodel.NormalizeAddress(i.Address)]; ok {
+			continue
+		}
+		res = append(res, i)
+	}
+	if len(res) != len(to) {
+		s.log.Info(ctx, "Skipped suppressed mail recipients",
+			klog.AInt("count", len(to)-len(res)),
+		)
+	}
+	return res, nil
+}
+
+type (
+	resSuppression struct {
+		Address      string `json:"address"`
+		Kind         string `json:"kind"`
+		Reason       string `json:"reason"`
+		CreationTime int64  `json:"creation_time"`
+	}
+
+	resSuppressions struct {
+		Suppressions []resSuppression `json:"suppressions"`
+	}
+)
+
+func (s *Service) getSuppression(ctx context.Context, address string) (*resSuppression, error) {
+	m, err := s.suppressions.GetByAddress(ctx, address)
+	if err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			return nil, governor.ErrWithRes(err, http.StatusNotFound, "", "Suppression not found")
+		}
+		return nil, kerrors.WithMsg(err, "Failed to get suppression")
+	}
+	return &resSuppression{
+		Address:      m.Address,
+		Kind:         m.Kind,
+		Reason:       m.Reason,
+		CreationTime: m.CreationTime,
+	}, nil
+}
+
+func (s *Service) getSuppressions(ctx context.Context, amount, offset int) (*resSuppressions, error) {
+	m, err := s.suppressions.GetLatest(ctx, amount, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get suppressions")
+	}
+	res := make([]resSuppression, 0, len(m))
+	for _, i := range m {
+		res = append(res, resSuppression{
+			Address:      i.Address,
+			Kind:         i.Kind,
+			Reason:       i.Reason,
+			CreationTime: i.CreationTime,
+		})
+	}
+	return &resSuppressions{
+		Suppressions: res,
+	}, nil
+}
+
+func (s *Service) deleteSuppression(ctx context.Context, address string) error {
+	m, err := s.suppressions.GetByAddress(ctx, address)
+	if err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			return governor.ErrWithRes(err, http.StatusNotFound, "", "Suppression not found")
+		}
+		return kerrors.WithMsg(err, "Failed to get suppression")
+	}
+	if err := s.suppressions.Delete(ctx, m); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete suppression")
+	}
+	s.log.Info(ctx, "Cleared mail suppression",
+		klog.AString("mail.suppression.kind", m.Kind),
+	)
+	return nil
+}
@@ -0,0 +1,160 @@
+package suppressionmodel
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"xorkevin.dev/governor/service/dbsql"
+	"xorkevin.dev/kerrors"
+)
+
+//go:generate forge model
+
+const (
+	// KindBounce is a suppression from a permanent delivery failure
+	KindBounce = "bounce"
+	// KindComplaint is a suppression from an abuse complaint
+	KindComplaint = "complaint"
+)
+
+type (
+	// Repo is a mail suppression repository
+	Repo interface {
+		New(address, kind, reason string) *Model
+		GetByAddress(ctx context.Context, address string) (*Model, error)
+		GetByAddresses(ctx context.Context, addresses []string) ([]Model, error)
+		GetLatest(ctx context.Context, limit, offset int) ([]Model, error)
+		Insert(ctx context.Context, m *Model) error
+		Update(ctx context.Context, m *Model) error
+		Delete(ctx context.Context, m *Model) error
+		Setup(ctx context.Context) error
+	}
+
+	repo struct {
+		table *suppressionModelTable
+		db    dbsql.Database
+	}
+
+	// Model is the db suppressed mail address model
+	//forge:model suppression
+	//forge:model:query suppression
+	Model struct {
+		Address      string `model:"address,VARCHAR(255) PRIMARY KEY"`
+		Kind         string `model:"kind,VARCHAR(31) NOT NULL"`
+		Reason       string `model:"reason,VARCHAR(4095) NOT NULL"`
+		CreationTime int64  `model:"creation_time,BIGINT NOT NULL"`
+	}
+)
+
+// New creates a new suppression repository
+func New(database dbsql.Database, table string) Repo {
+	return &repo{
+		table: &suppressionModelTable{
+			TableName: table,
+		},
+		db: database,
+	}
+}
+
+// NormalizeAddress returns the canonical form of an address for suppression
+// lookups
+func NormalizeAddress(address string) string {
+	return strings.ToLower(strings.TrimSpace(address))
+}
+
+func (r *repo) New(address, kind, reason string) *Model {
+	return &Model{
+		Address:      NormalizeAddress(address),
+		Kind:         kind,
+		Reason:       reason,
+		CreationTime: time.Now().Round(0).Unix(),
+	}
+}
+
+func (r *repo) GetByAddress(ctx context.Context, address string) (*Model, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.table.GetModelByAddress(ctx, d, NormalizeAddress(address))
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get suppression")
+	}
+	return m, nil
+}
+
+func (r *repo) GetByAddresses(ctx context.Context, addresses []string) ([]Model, error) {
+	if len(addresses) == 0 {
+		return nil, nil
+	}
+	addrs := make([]string, 0, len(addresses))
+	for _, i := range addresses {
+		addrs = append(addrs, NormalizeAddress(i))
+	}
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.table.GetModelByAddresses(ctx, d, addrs, len(addrs), 0)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get suppressions")
+	}
+	return m, nil
+}
+
+func (r *repo) GetLatest(ctx context.Context, limit, offset int) ([]Model, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.table.GetModelAll(ctx, d, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get suppressions")
+	}
+	return m, nil
+}
+
+func (r *repo) Insert(ctx context.Context, m *Model) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.table.Insert(ctx, d, m); err != nil {
+		return kerrors.WithMsg(err, "Failed to insert suppression")
+	}
+	return nil
+}
+
+func (r *repo) Update(ctx context.Context, m *Model) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.table.UpdModelByAddress(ctx, d, m, m.Address); err != nil {
+		return kerrors.WithMsg(err, "Failed to update suppression")
+	}
+	return nil
+}
+
+func (r *repo) Delete(ctx context.Context, m *Model) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.table.DelByAddress(ctx, d, m.Address); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete suppression")
+	}
+	return nil
+}
+
+func (r *repo) Setup(ctx context.Context) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.table.Setup(ctx, d); err != nil {
+		return kerrors.WithMsg(err, "Failed to setup suppression model")
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package mail
+
+import (
+	"net/http"
+
+	"xorkevin.dev/governor"
+)
+
+//go:generate forge validation
+
+const (
+	lengthCapAddress = 255
+	lengthCapTag     = 31
+	lengthCapMsgID   = 31
+	lengthCapTplName = 255
+	amountCap        = 255
+)
+
+func validhasAddress(address string) error {
+	if len(address) == 0 {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Address must be provided")
+	}
+	if len(address) > lengthCapAddress {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Address must be shorter than 256 characters")
+	}
+	return nil
+}
+
+func validhasTag(tag string) error {
+	if len(tag) == 0 {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Tag must be provided")
+	}
+	if len(tag) > lengthCapTag {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Tag must be shorter than 32 characters")
+	}
+	return nil
+}
+
+func validhasMsgid(id string) error {
+	if len(id) == 0 {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Msg id must be provided")
+	}
+	if len(id) > lengthCapMsgID {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Msg id must be shorter than 32 characters")
+	}
+	return nil
+}
+
+func validhasTplname(name string) error {
+	if len(name) == 0 {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Template name must be provided")
+	}
+	if len(name) > lengthCapTplName {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Template name must be shorter than 256 characters")
+	}
+	return nil
+}
+
+func validAmount(amt int) error {
+	if amt < 1 {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Amount must be positive")
+	}
+	if amt > amountCap {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Amount must be less than 256")
+	}
+	return nil
+}
+
+func validOffset(offset int) error {
+	if offset < 0 {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Offset must not be negative")
+	}
+	return nil
+}
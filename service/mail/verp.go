@@ -0,0 +1,137 @@
+package mail
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base32"
+	"strings"
+	"time"
+
+	"xorkevin.dev/governor/service/mail/verpmodel"
+	"xorkevin.dev/governor/util/ksync"
+	"xorkevin.dev/kerrors"
+)
+
+const (
+	verpSeparator  = "."
+	verpMACSize    = 8
+	verpGCInterval = time.Hour
+)
+
+// verpEncoding is case insensitive in order to survive mail servers that
+// change the case of the local part of a return path
+var verpEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+type (
+	// errVERP is returned when a verp address is invalid
+	errVERP struct{}
+)
+
+func (e errVERP) Error() string {
+	return "Invalid verp address"
+}
+
+func verpMAC(key []byte, token string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(token))
+	return h.Sum(nil)[:verpMACSize]
+}
+
+// encodeVERP returns a return path of the form prefix+token.mac@domain, where
+// the token identifies a recipient record and the mac authenticates the token
+//
+// The recipient is not encoded in the return path itself in order to remain
+// within the 64 octet local part limit of RFC 5321.
+func encodeVERP(key []byte, prefix, domain, token string) string {
+	mac := verpEncoding.EncodeToString(verpMAC(key, token))
+	return prefix + "+" + token + verpSeparator + mac + "@" + domain
+}
+
+// decodeVERP parses a verp return path and authenticates it with any of the
+// keys, returning the recipient token
+func decodeVERP(keys [][]byte, prefix, domain, addr string) (string, error) {
+	localPart, addrDomain, ok := strings.Cut(addr, "@")
+	if !ok || !strings.EqualFold(addrDomain, domain) {
+		return "", kerrors.WithKind(nil, errVERP{}, "Invalid verp domain")
+	}
+	rest, ok := strings.CutPrefix(strings.ToLower(localPart), strings.ToLower(prefix)+"+")
+	if !ok {
+		return "", kerrors.WithKind(nil, errVERP{}, "Invalid verp prefix")
+	}
+	token, encMAC, ok := strings.Cut(rest, verpSeparator)
+	if !ok || token == "" {
+		return "", kerrors.WithKind(nil, errVERP{}, "Invalid verp token")
+	}
+	mac, err := verpEncoding.DecodeString(encMAC)
+	if err != nil {
+		return "", kerrors.WithKind(err, errVERP{}, "Invalid verp mac")
+	}
+	for _, i := range keys {
+		if hmac.Equal(mac, verpMAC(i, token)) {
+			return token, nil
+		}
+	}
+	return "", kerrors.WithKind(nil, errVERP{}, "Failed to authenticate verp address")
+}
+
+// getVERPRcpts returns the verp records of the recipients of a message by
+// recipient, creating them if they do not yet exist
+//
+// Records are reused when a message is retried, so that each recipient keeps
+// the same return path and recipients already sent the message may be
+// skipped.
+func (s *Service) getVERPRcpts(ctx context.Context, tag string, rcpts []string) (map[string]*verpmodel.Model, error) {
+	res, err := s.getVERPRcptsByTag(ctx, tag, len(rcpts))
+	if err != nil {
+		return nil, err
+	}
+	var m []*verpmodel.Model
+	for _, i := range rcpts {
+		if _, ok := res[i]; ok {
+			continue
+		}
+		v, err := s.verps.New(tag, i)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = v
+		m = append(m, v)
+	}
+	if len(m) == 0 {
+		return res, nil
+	}
+	if err := s.verps.InsertBulk(ctx, m); err != nil {
+		return nil, err
+	}
+	// records may have been concurrently created by another attempt
+	return s.getVERPRcptsByTag(ctx, tag, len(rcpts))
+}
+
+func (s *Service) getVERPRcptsByTag(ctx context.Context, tag string, limit int) (map[string]*verpmodel.Model, error) {
+	m, err := s.verps.GetByTag(ctx, tag, limit, 0)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]*verpmodel.Model, limit)
+	for n, i := range m {
+		res[i.Rcpt] = &m[n]
+	}
+	return res, nil
+}
+
+func (s *Service) verpGCLoop(ctx context.Context, wg *ksync.WaitGroup) {
+	defer wg.Done()
+	ticker := time.NewTicker(verpGCInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.verps.DeleteBefore(ctx, time.Now().Round(0).Add(-s.bounce.verpage).Unix()); err != nil {
+				s.log.Err(ctx, kerrors.WithMsg(err, "Failed to gc verp recipients"))
+			}
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package verpmodel
+
+import (
+	"context"
+	"encoding/base32"
+	"time"
+
+	"xorkevin.dev/governor/service/dbsql"
+	"xorkevin.dev/governor/util/uid"
+	"xorkevin.dev/kerrors"
+)
+
+//go:generate forge model
+
+// tokenEncoding is case insensitive in order to survive mail servers that
+// change the case of the local part of a return path
+var tokenEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+type (
+	// Repo is a mail verp recipient repository
+	Repo interface {
+		New(tag, rcpt string) (*Model, error)
+		GetByToken(ctx context.Context, token string) (*Model, error)
+		GetByTag(ctx context.Context, tag string, limit, offset int) ([]Model, error)
+		InsertBulk(ctx context.Context, m []*Model) error
+		Update(ctx context.Context, m *Model) error
+		DeleteBefore(ctx context.Context, t int64) error
+		Setup(ctx context.Context) error
+	}
+
+	repo struct {
+		table *verpModelTable
+		db    dbsql.Database
+	}
+
+	// Model is the db verp recipient model
+	//
+	// Each recipient of a message is identified in its verp return path by an
+	// opaque token, since the recipient address itself may exceed the local
+	// part length limit.
+	//forge:model verp
+	//forge:model:query verp
+	Model struct {
+		Token        string `model:"token,VARCHAR(31) PRIMARY KEY"`
+		Tag          string `model:"tag,VARCHAR(31) NOT NULL"`
+		Rcpt         string `model:"rcpt,VARCHAR(255) NOT NULL"`
+		Sent         bool   `model:"sent,BOOLEAN NOT NULL"`
+		CreationTime int64  `model:"creation_time,BIGINT NOT NULL"`
+	}
+)
+
+// New creates a new verp recipient repository
+func New(database dbsql.Database, table string) Repo {
+	return &repo{
+		table: &verpModelTable{
+			TableName: table,
+		},
+		db: database,
+	}
+}
+
+func (r *repo) New(tag, rcpt string) (*Model, error) {
+	u, err := uid.New()
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to create new verp token")
+	}
+	return &Model{
+		Token:        tokenEncoding.EncodeToString(u.Bytes()),
+		Tag:          tag,
+		Rcpt:         rcpt,
+		Sent:         false,
+		CreationTime: time.Now().Round(0).Unix(),
+	}, nil
+}
+
+func (r *repo) GetByToken(ctx context.Context, token string) (*Model, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.table.GetModelByToken(ctx, d, token)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get verp recipient")
+	}
+	return m, nil
+}
+
+func (r *repo) GetByTag(ctx context.Context, tag string, limit, offset int) ([]Model, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.table.GetModelByTag(ctx, d, tag, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get verp recipients")
+	}
+	return m, nil
+}
+
+func (r *repo) InsertBulk(ctx context.Context, m []*Model) error {
+	if len(m) == 0 {
+		return nil
+	}
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	// existing recipients of a message are left untouched when it is retried
+	if err := r.table.InsertBulk(ctx, d, m, true); err != nil {
+		return kerrors.WithMsg(err, "Failed to insert verp recipients")
+	}
+	return nil
+}
+
+func (r *repo) Update(ctx context.Context, m *Model) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.table.UpdModelByToken(ctx, d, m, m.Token); err != nil {
+		return kerrors.WithMsg(err, "Failed to update verp recipient")
+	}
+	return nil
+}
+
+func (r *repo) DeleteBefore(ctx context.Context, t int64) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.table.DelBeforeCreationTime(ctx, d, t); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete verp recipients")
+	}
+	return nil
+}
+
+func (r *repo) Setup(ctx context.Context) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.table.Setup(ctx, d); err != nil {
+		return kerrors.WithMsg(err, "Failed to setup verp recipient model")
+	}
+	return nil
+}
@@ -0,0 +1,398 @@
+package mailinglist
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/emersion/go-message"
+	emmail "github.com/emersion/go-message/mail"
+	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/dbsql"
+	"xorkevin.dev/governor/service/events"
+	"xorkevin.dev/governor/service/mailinglist/mailinglistmodel"
+	"xorkevin.dev/governor/service/user"
+	"xorkevin.dev/kerrors"
+	"xorkevin.dev/klog"
+)
+
+const (
+	mboxFromLinePrefix = "From "
+	mboxSenderUnknown  = "MAILER-DAEMON"
+	mboxTimeFormat     = "Mon Jan _2 15:04:05 2006"
+	mediaTypeMbox      = "application/mbox"
+
+	mboxExportBatchSize = 256
+)
+
+type (
+	// mboxWriter writes msgs in the mboxrd format
+	mboxWriter struct {
+		w *bufio.Writer
+	}
+)
+
+func newMboxWriter(w io.Writer) *mboxWriter {
+	return &mboxWriter{
+		w: bufio.NewWriter(w),
+	}
+}
+
+// isMboxFromLine returns if a line, excluding any number of leading '>', is a
+// From_ line
+func isMboxFromLine(line []byte) bool {
+	return bytes.HasPrefix(bytes.TrimLeft(line, ">"), []byte(mboxFromLinePrefix))
+}
+
+// WriteMsg writes a msg preceded by its From_ line. Line endings are converted
+// to LF, and From_ lines in the msg are quoted with an additional '>'.
+func (w *mboxWriter) WriteMsg(sender string, t time.Time, msg io.Reader) error {
+	if _, err := w.w.WriteString(mboxFromLinePrefix + sender + " " + t.UTC().Format(mboxTimeFormat) + "\n"); err != nil {
+		return kerrors.WithMsg(err, "Failed to write mbox from line")
+	}
+	r := bufio.NewReader(msg)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			line = bytes.TrimSuffix(bytes.TrimSuffix(line, []byte("\n")), []byte("\r"))
+			if isMboxFromLine(line) {
+				if err := w.w.WriteByte('>'); err != nil {
+					return kerrors.WithMsg(err, "Failed to write mbox msg")
+				}
+			}
+			if _, err := w.w.Write(line); err != nil {
+				return kerrors.WithMsg(err, "Failed to write mbox msg")
+			}
+			if err := w.w.WriteByte('\n'); err != nil {
+				return kerrors.WithMsg(err, "Failed to write mbox msg")
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return kerrors.WithMsg(err, "Failed to read msg")
+		}
+	}
+	// msgs are separated by an empty line
+	if err := w.w.WriteByte('\n'); err != nil {
+		return kerrors.WithMsg(err, "Failed to write mbox msg")
+	}
+	return nil
+}
+
+// Flush writes any buffered data to the underlying writer
+func (w *mboxWriter) Flush() error {
+	if err := w.w.Flush(); err != nil {
+		return kerrors.WithMsg(err, "Failed to flush mbox")
+	}
+	return nil
+}
+
+type (
+	// mboxReader reads msgs in the mboxrd format
+	mboxReader struct {
+		r       *bufio.Reader
+		started bool
+		done    bool
+	}
+)
+
+func newMboxReader(r io.Reader) *mboxReader {
+	return &mboxReader{
+		r: bufio.NewReader(r),
+	}
+}
+
+// Next returns the next msg in the mbox with CRLF line endings and with any
+// From_ line quoting removed. It returns [io.EOF] when no msgs remain.
+func (r *mboxReader) Next() ([]byte, error) {
+	if r.done {
+		return nil, io.EOF
+	}
+	var msg bytes.Buffer
+	// the last empty line before a From_ line separates msgs and is not part of
+	// the msg
+	pendingEmpty := 0
+	endMsg := func() []byte {
+		for ; pendingEmpty > 1; pendingEmpty-- {
+			msg.WriteString("\r\n")
+		}
+		return msg.Bytes()
+	}
+	for {
+		line, err := r.r.ReadBytes('\n')
+		if len(line) > 0 {
+			line = bytes.TrimSuffix(bytes.TrimSuffix(line, []byte("\n")), []byte("\r"))
+			if bytes.HasPrefix(line, []byte(mboxFromLinePrefix)) {
+				if r.started {
+					return endMsg(), nil
+				}
+				r.started = true
+			} else if r.started {
+				if len(line) == 0 {
+					pendingEmpty++
+				} else {
+					for ; pendingEmpty > 0; pendingEmpty-- {
+						msg.WriteString("\r\n")
+					}
+					if line[0] == '>' && isMboxFromLine(line) {
+						line = line[1:]
+					}
+					msg.Write(line)
+					msg.WriteString("\r\n")
+				}
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				r.done = true
+				if !r.started {
+					return nil, io.EOF
+				}
+				return endMsg(), nil
+			}
+			return nil, kerrors.WithMsg(err, "Failed to read mbox")
+		}
+	}
+}
+
+// exportListMbox writes the msgs of a list, or of a single thread if threadid
+// is provided, as an mbox in order of creation
+func (s *Service) exportListMbox(ctx context.Context, ml *mailinglistmodel.ListModel, threadid string, w io.Writer) error {
+	mw := newMboxWriter(w)
+	before := time.Now().Round(0).UnixMilli()
+	senders := map[string]string{}
+	for offset := 0; ; offset += mboxExportBatchSize {
+		var msgs []mailinglistmodel.MsgModel
+		var err error
+		if threadid != "" {
+			msgs, err = s.lists.GetListThread(ctx, ml.ListID, threadid, mboxExportBatchSize, offset)
+			if err != nil {
+				return kerrors.WithMsg(err, "Failed to get list thread")
+			}
+		} else {
+			msgs, err = s.lists.GetListMsgsRange(ctx, ml.ListID, -1, before, mboxExportBatchSize, offset)
+			if err != nil {
+				return kerrors.WithMsg(err, "Failed to get list msgs")
+			}
+		}
+		if err := s.getMboxSenders(ctx, msgs, senders); err != nil {
+			return err
+		}
+		for _, i := range msgs {
+			if i.Deleted {
+				continue
+			}
+			sender := mboxSenderUnknown
+			if v, ok := senders[i.Userid]; ok {
+				sender = v
+			}
+			if err := s.writeMboxMsg(ctx, mw, &i, sender); err != nil {
+				return err
+			}
+		}
+		if len(msgs) < mboxExportBatchSize {
+			break
+		}
+	}
+	return mw.Flush()
+}
+
+// getMboxSenders adds the email addresses of any msg senders not already in
+// senders
+func (s *Service) getMboxSenders(ctx context.Context, msgs []mailinglistmodel.MsgModel, senders map[string]string) error {
+	userids := make([]string, 0, len(msgs))
+	for _, i := range msgs {
+		if i.Userid == "" {
+			continue
+		}
+		if _, ok := senders[i.Userid]; ok {
+			continue
+		}
+		senders[i.Userid] = mboxSenderUnknown
+		userids = append(userids, i.Userid)
+	}
+	if len(userids) == 0 {
+		return nil
+	}
+	res, err := s.users.GetInfoBulk(ctx, userids)
+	if err != nil {
+		return kerrors.WithMsg(err, "Failed to get msg senders")
+	}
+	for _, i := range res.Users {
+		senders[i.Userid] = i.Email
+	}
+	return nil
+}
+
+func (s *Service) writeMboxMsg(ctx context.Context, mw *mboxWriter, m *mailinglistmodel.MsgModel, sender string) (retErr error) {
+	obj, _, err := s.rcvMailDir.Subdir(m.ListID).Get(ctx, s.encodeMsgid(m.Msgid))
+	if err != nil {
+		return kerrors.WithMsg(err, "Failed to get msg content")
+	}
+	defer func() {
+		if err := obj.Close(); err != nil {
+			retErr = errors.Join(retErr, kerrors.WithMsg(err, "Failed to close msg content"))
+		}
+	}()
+	if err := mw.WriteMsg(sender, time.UnixMilli(m.CreationTime), obj); err != nil {
+		return err
+	}
+	return nil
+}
+
+type (
+	resImportMbox struct {
+		Imported int `json:"imported"`
+		Skipped  int `json:"skipped"`
+	}
+)
+
+// importListMbox adds the msgs of an mbox to the archive of a list. Imported
+// msgs are threaded by their In-Reply-To or References headers, and are not
+// sent to list members.
+func (s *Service) importListMbox(ctx context.Context, creatorid string, listname string, r io.Reader) (*resImportMbox, error) {
+	ml, err := s.getListByName(ctx, creatorid, listname)
+	if err != nil {
+		return nil, err
+	}
+	mr := newMboxReader(r)
+	senders := map[string]string{}
+	res := &resImportMbox{}
+	for {
+		b, err := mr.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, governor.ErrWithRes(err, http.StatusBadRequest, "", "Invalid mbox")
+		}
+		ok, err := s.importMboxMsg(ctx, ml, b, senders)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			res.Imported++
+		} else {
+			res.Skipped++
+		}
+	}
+	return res, nil
+}
+
+// getMboxSenderID returns the userid of a msg sender, or an empty string if
+// the sender is not a user
+func (s *Service) getMboxSenderID(ctx context.Context, headers emmail.Header, senders map[string]string) (string, error) {
+	fromAddrs, err := headers.AddressList(headerFrom)
+	if err != nil || len(fromAddrs) != 1 {
+		return "", nil
+	}
+	addr := fromAddrs[0].Address
+	if v, ok := senders[addr]; ok {
+		return v, nil
+	}
+	userid := ""
+	if u, err := s.users.GetByEmail(ctx, addr); err != nil {
+		if !errors.Is(err, user.ErrNotFound) {
+			return "", kerrors.WithMsg(err, "Failed to get msg sender")
+		}
+	} else {
+		userid = u.Userid
+	}
+	senders[addr] = userid
+	return userid, nil
+}
+
+func (s *Service) importMboxMsg(ctx context.Context, ml *mailinglistmodel.ListModel, b []byte, senders map[string]string) (bool, error) {
+	m, err := message.Read(bytes.NewReader(b))
+	if err != nil && !message.IsUnknownCharset(err) && !message.IsUnknownEncoding(err) {
+		s.log.WarnErr(ctx, kerrors.WithMsg(err, "Failed to parse mbox msg"))
+		return false, nil
+	}
+	headers := emmail.Header{
+		Header: m.Header,
+	}
+	msgid, err := headers.MessageID()
+	if err != nil || msgid == "" || len(msgid) > lengthCapMsgid {
+		s.log.WarnErr(ctx, kerrors.WithMsg(err, "Invalid mbox msg msgid"))
+		return false, nil
+	}
+	ctx = klog.CtxWithAttrs(ctx,
+		klog.AString("list.msgid", msgid),
+	)
+	if _, err := s.lists.GetMsg(ctx, ml.ListID, msgid); err != nil {
+		if !errors.Is(err, dbsql.ErrNotFound) {
+			return false, kerrors.WithMsg(err, "Failed to get list msg")
+		}
+	} else {
+		// msg has already been imported
+		return false, nil
+	}
+	contentType, _, err := headers.ContentType()
+	if err != nil {
+		s.log.WarnErr(ctx, kerrors.WithMsg(err, "Failed to parse mbox msg content type"))
+		return false, nil
+	}
+	userid, err := s.getMboxSenderID(ctx, headers, senders)
+	if err != nil {
+		return false, err
+	}
+
+	msg := s.lists.NewMsg(ml.ListID, msgid, userid)
+	// the msg date is clamped to the import time, since a msg dated in the
+	// future would otherwise be excluded from exports until that date
+	if t, err := headers.Date(); err == nil && !t.IsZero() && t.UnixMilli() < msg.CreationTime {
+		msg.CreationTime = t.UnixMilli()
+	}
+	if subject, err := headers.Subject(); err == nil {
+		if len(subject) > maxSubjectLength {
+			subject = subject[:maxSubjectLength]
+		}
+		msg.Subject = subject
+	}
+	if inReplyTo, err := headers.MsgIDList(headerInReplyTo); err == nil && len(inReplyTo) == 1 {
+		msg.InReplyTo = inReplyTo[0]
+	} else if refs, err := headers.MsgIDList(headerReferences); err == nil && len(refs) > 0 {
+		// the last reference is the direct parent of the msg
+		msg.InReplyTo = refs[len(refs)-1]
+	}
+	if len(msg.InReplyTo) > lengthCapMsgid {
+		msg.InReplyTo = ""
+	}
+	// imported msgs must not be sent to list members
+	msg.Processed = true
+	msg.Sent = true
+
+	if err := s.rcvMailDir.Subdir(ml.ListID).Put(ctx, s.encodeMsgid(msgid), contentType, int64(len(b)), nil, bytes.NewReader(b)); err != nil {
+		return false, kerrors.WithMsg(err, "Failed to store mail msg")
+	}
+	if err := s.lists.InsertMsg(ctx, msg); err != nil {
+		if errors.Is(err, dbsql.ErrUnique) {
+			// the stored msg is referenced by the concurrently imported msg
+			return false, nil
+		}
+		if err := s.rcvMailDir.Subdir(ml.ListID).Del(ctx, s.encodeMsgid(msgid)); err != nil {
+			s.log.Err(ctx, kerrors.WithMsg(err, "Failed to clean up mail msg"))
+		}
+		return false, kerrors.WithMsg(err, "Failed to add list msg")
+	}
+	if err := s.threadListMsg(ctx, ml, msg); err != nil {
+		return false, err
+	}
+	j, err := encodeListEventIndex(indexProps{
+		ListID: ml.ListID,
+		MsgID:  msgid,
+	})
+	if err != nil {
+		return false, err
+	}
+	if err := s.events.Publish(ctx, events.NewMsgs(s.streammail, ml.ListID, j)...); err != nil {
+		return false, kerrors.WithMsg(err, "Failed to publish list index event")
+	}
+	return true, nil
+}
@@ -0,0 +1,217 @@
+package mailinglist
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMboxWriter(t *testing.T) {
+	t.Parallel()
+
+	msgTime := time.Date(2023, time.March, 4, 5, 6, 7, 0, time.UTC)
+
+	for _, tc := range []struct {
+		Test   string
+		Sender string
+		Msg    string
+		Exp    string
+	}{
+		{
+			Test:   "converts line endings",
+			Sender: "alice@example.com",
+			Msg:    "Subject: hello\r\n\r\nbody\r\n",
+			Exp:    "From alice@example.com Sat Mar  4 05:06:07 2023\nSubject: hello\n\nbody\n\n",
+		},
+		{
+			Test:   "terminates last line",
+			Sender: mboxSenderUnknown,
+			Msg:    "Subject: hello\r\n\r\nbody",
+			Exp:    "From MAILER-DAEMON Sat Mar  4 05:06:07 2023\nSubject: hello\n\nbody\n\n",
+		},
+		{
+			Test:   "quotes from lines",
+			Sender: "alice@example.com",
+			Msg:    "Subject: hello\r\n\r\nFrom here\r\n>From there\r\n>>From everywhere\r\nFrom: not a from line\r\n> From also not\r\n",
+			Exp:    "From alice@example.com Sat Mar  4 05:06:07 2023\nSubject: hello\n\n>From here\n>>From there\n>>>From everywhere\nFrom: not a from line\n> From also not\n\n",
+		},
+	} {
+		t.Run(tc.Test, func(t *testing.T) {
+			t.Parallel()
+
+			assert := require.New(t)
+
+			var b bytes.Buffer
+			w := newMboxWriter(&b)
+			assert.NoError(w.WriteMsg(tc.Sender, msgTime, strings.NewReader(tc.Msg)))
+			assert.NoError(w.Flush())
+			assert.Equal(tc.Exp, b.String())
+		})
+	}
+}
+
+func TestMboxReader(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		Test string
+		Mbox string
+		Msgs []string
+	}{
+		{
+			Test: "empty",
+			Mbox: "",
+			Msgs: nil,
+		},
+		{
+			Test: "ignores leading lines",
+			Mbox: "garbage\n\nFrom alice@example.com Sat Mar  4 05:06:07 2023\nSubject: hello\n\nbody\n",
+			Msgs: []string{
+				"Subject: hello\r\n\r\nbody\r\n",
+			},
+		},
+		{
+			Test: "multiple msgs",
+			Mbox: "From alice@example.com Sat Mar  4 05:06:07 2023\nSubject: one\n\nbody one\n\nFrom bob@example.com Sat Mar  4 05:06:08 2023\nSubject: two\n\nbody two\n\n",
+			Msgs: []string{
+				"Subject: one\r\n\r\nbody one\r\n",
+				"Subject: two\r\n\r\nbody two\r\n",
+			},
+		},
+		{
+			Test: "preserves trailing empty lines in msg",
+			Mbox: "From alice@example.com Sat Mar  4 05:06:07 2023\r\nSubject: one\r\n\r\nbody one\r\n\r\n\r\n\r\nFrom bob@example.com Sat Mar  4 05:06:08 2023\r\nSubject: two\r\n\r\nbody two",
+			Msgs: []string{
+				"Subject: one\r\n\r\nbody one\r\n\r\n\r\n",
+				"Subject: two\r\n\r\nbody two\r\n",
+			},
+		},
+		{
+			Test: "unquotes from lines",
+			Mbox: "From alice@example.com Sat Mar  4 05:06:07 2023\nSubject: hello\n\n>From here\n>>From there\n>>>From everywhere\n> From also not\n>not a from line\n\n",
+			Msgs: []string{
+				"Subject: hello\r\n\r\nFrom here\r\n>From there\r\n>>From everywhere\r\n> From also not\r\n>not a from line\r\n",
+			},
+		},
+	} {
+		t.Run(tc.Test, func(t *testing.T) {
+			t.Parallel()
+
+			assert := require.New(t)
+
+			r := newMboxReader(strings.NewReader(tc.Mbox))
+			var msgs []string
+			for {
+				b, err := r.Next()
+				if err != nil {
+					assert.ErrorIs(err, io.EOF)
+					break
+				}
+				msgs = append(msgs, string(b))
+			}
+			assert.Equal(tc.Msgs, msgs)
+			_, err := r.Next()
+			assert.ErrorIs(err, io.EOF)
+		})
+	}
+}
+
+func TestMboxRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	msgs := []string{
+		"Subject: one\r\n\r\nFrom here\r\n>From there\r\n\r\n",
+		"Subject: two\r\n\r\n>>From everywhere\r\nbody\r\n",
+	}
+
+	var b bytes.Buffer
+	w := newMboxWriter(&b)
+	for _, i := range msgs {
+		assert.NoError(w.WriteMsg("alice@example.com", time.Unix(0, 0), strings.NewReader(i)))
+	}
+	assert.NoError(w.Flush())
+
+	r := newMboxReader(&b)
+	for _, i := range msgs {
+		m, err := r.Next()
+		assert.NoError(err)
+		assert.Equal(i, string(m))
+	}
+	_, err := r.Next()
+	assert.ErrorIs(err, io.EOF)
+}
+
+func TestImportMboxMsg(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now().Round(0)
+	past := now.Add(-24 * time.Hour).Truncate(time.Second)
+
+	for _, tc := range []struct {
+		Test    string
+		Date    time.Time
+		Clamped bool
+	}{
+		{
+			Test: "keeps past msg date",
+			Date: past,
+		},
+		{
+			Test:    "clamps future msg date to import time",
+			Date:    now.Add(365 * 24 * time.Hour),
+			Clamped: true,
+		},
+	} {
+		t.Run(tc.Test, func(t *testing.T) {
+			t.Parallel()
+
+			assert := require.New(t)
+
+			s := newTestService()
+			s.addUser("sender", "sender")
+			ml := s.addList("owner", "mylist")
+
+			msg := strings.Join([]string{
+				"From: sender@example.com",
+				"Message-ID: <msg@example.com>",
+				"Date: " + tc.Date.Format(time.RFC1123Z),
+				"Subject: Hello",
+				"Content-Type: text/plain",
+				"",
+				"hello",
+				"",
+			}, "\r\n")
+
+			before := time.Now().Round(0).UnixMilli()
+			ok, err := s.importMboxMsg(context.Background(), ml, []byte(msg), map[string]string{})
+			assert.NoError(err)
+			assert.True(ok)
+			after := time.Now().Round(0).UnixMilli()
+
+			m, err := s.lists.GetMsg(context.Background(), ml.ListID, "msg@example.com")
+			assert.NoError(err)
+			assert.Equal("sender", m.Userid)
+			assert.Equal("Hello", m.Subject)
+			assert.True(m.Processed)
+			assert.True(m.Sent)
+			if tc.Clamped {
+				assert.GreaterOrEqual(m.CreationTime, before)
+				assert.LessOrEqual(m.CreationTime, after)
+			} else {
+				assert.Equal(tc.Date.UnixMilli(), m.CreationTime)
+			}
+
+			// reimporting an imported msg is skipped
+			ok, err = s.importMboxMsg(context.Background(), ml, []byte(msg), map[string]string{})
+			assert.NoError(err)
+			assert.False(ok)
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package mailinglist
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+
+	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/user/gate"
+	"xorkevin.dev/kerrors"
+	"xorkevin.dev/klog"
+)
+
+type (
+	// CmdClient is a mailinglist cmd client
+	CmdClient struct {
+		gate            gate.Client
+		log             *klog.LevelLogger
+		term            governor.Term
+		httpc           *governor.HTTPFetcher
+		importMboxFlags importMboxFlags
+	}
+
+	importMboxFlags struct {
+		creatorid string
+		listname  string
+		file      string
+	}
+)
+
+func NewCmdClient(g gate.Client) *CmdClient {
+	return &CmdClient{
+		gate: g,
+	}
+}
+
+func (c *CmdClient) Register(r governor.ConfigRegistrar, cr governor.CmdRegistrar) {
+	cr.Register(governor.CmdDesc{
+		Usage: "import",
+		Short: "imports an mbox archive",
+		Long:  "imports an mbox archive into a list without sending msgs to list members",
+		Flags: []governor.CmdFlag{
+			{
+				Long:     "creator",
+				Short:    "c",
+				Usage:    "list creator id",
+				Required: true,
+				Value:    &c.importMboxFlags.creatorid,
+			},
+			{
+				Long:     "list",
+				Short:    "l",
+				Usage:    "list name",
+				Required: true,
+				Value:    &c.importMboxFlags.listname,
+			},
+			{
+				Long:     "file",
+				Short:    "f",
+				Usage:    "mbox file, or - for stdin",
+				Required: true,
+				Value:    &c.importMboxFlags.file,
+			},
+		},
+	}, governor.CmdHandlerFunc(c.importMbox))
+}
+
+func (c *CmdClient) Init(r governor.ClientConfigReader, kit governor.ClientKit) error {
+	c.log = klog.NewLevelLogger(kit.Logger)
+	c.term = kit.Term
+	c.httpc = governor.NewHTTPFetcher(kit.HTTPClient)
+	return nil
+}
+
+func (c *CmdClient) importMbox(args []string) (retErr error) {
+	var body io.Reader
+	if c.importMboxFlags.file == "-" {
+		body = c.term.Stdin()
+	} else {
+		f, err := c.term.FS().Open(c.importMboxFlags.file)
+		if err != nil {
+			return kerrors.WithMsg(err, "Failed to open mbox file")
+		}
+		defer func() {
+			if err := f.Close(); err != nil {
+				retErr = errors.Join(retErr, kerrors.WithMsg(err, "Failed to close mbox file"))
+			}
+		}()
+		body = f
+	}
+	r, err := c.httpc.HTTPClient.Req(
+		http.MethodPost,
+		"/c/"+url.PathEscape(c.importMboxFlags.creatorid)+"/list/"+url.PathEscape(c.importMboxFlags.listname)+"/mbox",
+		body,
+	)
+	if err != nil {
+		return kerrors.WithMsg(err, "Failed to create import mbox request")
+	}
+	r.Header.Set("Content-Type", mediaTypeMbox)
+	if err := c.gate.AddSysToken(r); err != nil {
+		return kerrors.WithMsg(err, "Failed to add systoken")
+	}
+	var res resImportMbox
+	if _, err := c.httpc.DoJSON(context.Background(), r, &res); err != nil {
+		return kerrors.WithMsg(err, "Failed importing mbox")
+	}
+	c.log.Info(context.Background(), "Imported mbox",
+		klog.AInt("imported", res.Imported),
+		klog.AInt("skipped", res.Skipped),
+	)
+	return nil
+}
@@ -0,0 +1,243 @@
+package mailinglist
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"xorkevin.dev/governor/service/dbsql"
+	"xorkevin.dev/governor/service/mail"
+	"xorkevin.dev/governor/service/mailinglist/mailinglistmodel"
+	"xorkevin.dev/kerrors"
+)
+
+type (
+	// errListCmd is returned when a list command is not allowed
+	errListCmd struct{}
+)
+
+func (e errListCmd) Error() string {
+	return "List command not allowed"
+}
+
+type (
+	emailCmdConfirm struct {
+		Listname       string
+		ListAddress    string
+		Command        string
+		Arg            string
+		ConfirmAddress string
+		Expires        string
+	}
+
+	emailCmdHelp struct {
+		Listname           string
+		Description        string
+		ListAddress        string
+		SubscribeAddress   string
+		UnsubscribeAddress string
+		ImmediateAddress   string
+		DailyAddress       string
+		WeeklyAddress      string
+		HelpAddress        string
+	}
+)
+
+func (s *Service) getCmdList(ctx context.Context, listid string) (*mailinglistmodel.ListModel, error) {
+	m, err := s.lists.GetListByID(ctx, listid)
+	if err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			return nil, kerrors.WithKind(err, errListCmd{}, "List not found")
+		}
+		return nil, kerrors.WithMsg(err, "Failed to get list")
+	}
+	return m, nil
+}
+
+func (s *Service) checkCmdMember(ctx context.Context, listid, userid string) (bool, error) {
+	if _, err := s.lists.GetMember(ctx, listid, userid); err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			return false, nil
+		}
+		return false, kerrors.WithMsg(err, "Failed to get list member")
+	}
+	return true, nil
+}
+
+// sendCmdMail sends a reply to a list command sender
+func (s *Service) sendCmdMail(ctx context.Context, from string, userid string, tpl string, emdata interface{}) error {
+	u, err := s.users.GetByID(ctx, userid)
+	if err != nil {
+		return kerrors.WithMsg(err, "Failed to get list command sender")
+	}
+	rcpts, err := s.mailer.FilterSuppressed(ctx, []mail.Addr{{Address: u.Email, Name: u.FirstName}})
+	if err != nil {
+		return kerrors.WithMsg(err, "Failed to filter suppressed recipients")
+	}
+	if len(rcpts) == 0 {
+		return nil
+	}
+	if err := s.mailer.SendTpl(
+		ctx,
+		"",
+		mail.Addr{Address: from},
+		rcpts,
+		mail.TplLocal(tpl),
+		emdata,
+		false,
+	); err != nil {
+		return kerrors.WithMsg(err, "Failed to send list command reply")
+	}
+	return nil
+}
+
+// requestListCmd stores a list command and sends the sender a confirmation
+// key which must be returned to the list before the command takes effect
+func (s *Service) requestListCmd(ctx context.Context, listid, userid string, cmd, arg string) error {
+	ml, err := s.getCmdList(ctx, listid)
+	if err != nil {
+		return err
+	}
+	isMember, err := s.checkCmdMember(ctx, ml.ListID, userid)
+	if err != nil {
+		return err
+	}
+	switch cmd {
+	case listCmdSubscribe:
+		if isMember {
+			return kerrors.WithKind(nil, errListCmd{}, "List member already added")
+		}
+		if ok, err := s.checkMemberPolicy(ctx, ml, userid); err != nil {
+			return err
+		} else if !ok {
+			return kerrors.WithKind(nil, errListCmd{}, "Not allowed to subscribe")
+		}
+	case listCmdUnsubscribe, listCmdDelivery:
+		if !isMember {
+			return kerrors.WithKind(nil, errListCmd{}, "List member not found")
+		}
+	default:
+		return kerrors.WithKind(nil, errListCmd{}, "Invalid list command")
+	}
+
+	m, key, err := s.lists.NewCmd(ml.ListID, userid, cmd, arg)
+	if err != nil {
+		return err
+	}
+	// only the latest command for a member may be confirmed
+	if err := s.lists.DeleteCmd(ctx, ml.ListID, userid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete list command")
+	}
+	if err := s.lists.InsertCmd(ctx, m); err != nil {
+		return kerrors.WithMsg(err, "Failed to add list command")
+	}
+
+	listaddr, err := s.listAddress(ctx, ml)
+	if err != nil {
+		return err
+	}
+	confirmAddr := listCmdAddress(listaddr, listCmdConfirm+mailboxCmdArgSeparator+key)
+	emdata := emailCmdConfirm{
+		Listname:       ml.Name,
+		ListAddress:    listaddr,
+		Command:        cmd,
+		Arg:            arg,
+		ConfirmAddress: confirmAddr,
+		Expires:        time.Unix(m.CreationTime, 0).Add(s.cmdduration).UTC().Format(time.RFC1123Z),
+	}
+	if err := s.sendCmdMail(ctx, confirmAddr, userid, s.tplname.cmdconfirm, emdata); err != nil {
+		return err
+	}
+	return nil
+}
+
+// confirmListCmd executes a previously requested list command
+func (s *Service) confirmListCmd(ctx context.Context, listid, userid string, key string) error {
+	ml, err := s.getCmdList(ctx, listid)
+	if err != nil {
+		return err
+	}
+	m, err := s.lists.GetCmd(ctx, ml.ListID, userid)
+	if err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			return kerrors.WithKind(err, errListCmd{}, "List command not found")
+		}
+		return kerrors.WithMsg(err, "Failed to get list command")
+	}
+	if ok, err := s.lists.ValidateCmdKey(key, m); err != nil {
+		return kerrors.WithMsg(err, "Failed to validate list command key")
+	} else if !ok {
+		return kerrors.WithKind(nil, errListCmd{}, "Invalid list command key")
+	}
+	// commands may only be confirmed once
+	if err := s.lists.DeleteCmd(ctx, ml.ListID, userid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete list command")
+	}
+	if time.Now().Round(0).After(time.Unix(m.CreationTime, 0).Add(s.cmdduration)) {
+		return kerrors.WithKind(nil, errListCmd{}, "List command expired")
+	}
+
+	isMember, err := s.checkCmdMember(ctx, ml.ListID, userid)
+	if err != nil {
+		return err
+	}
+	switch m.Cmd {
+	case listCmdSubscribe:
+		if isMember {
+			return nil
+		}
+		if ok, err := s.checkMemberPolicy(ctx, ml, userid); err != nil {
+			return err
+		} else if !ok {
+			return kerrors.WithKind(nil, errListCmd{}, "Not allowed to subscribe")
+		}
+		if err := s.lists.InsertMembers(ctx, s.lists.AddMembers(ml, []string{userid})); err != nil {
+			return kerrors.WithMsg(err, "Failed to add list members")
+		}
+	case listCmdUnsubscribe:
+		if !isMember {
+			return nil
+		}
+		if err := s.lists.DeleteMembers(ctx, ml.ListID, []string{userid}); err != nil {
+			return kerrors.WithMsg(err, "Failed to remove list member")
+		}
+	case listCmdDelivery:
+		if !isMember {
+			return kerrors.WithKind(nil, errListCmd{}, "List member not found")
+		}
+		if err := s.lists.UpdateMemberDelivery(ctx, ml.ListID, userid, m.Arg); err != nil {
+			return kerrors.WithMsg(err, "Failed to update list member delivery")
+		}
+	default:
+		return kerrors.WithKind(nil, errListCmd{}, "Invalid list command")
+	}
+	return nil
+}
+
+// sendListHelp replies to the sender with the list commands
+func (s *Service) sendListHelp(ctx context.Context, listid, userid string) error {
+	ml, err := s.getCmdList(ctx, listid)
+	if err != nil {
+		return err
+	}
+	listaddr, err := s.listAddress(ctx, ml)
+	if err != nil {
+		return err
+	}
+	helpAddr := listCmdAddress(listaddr, listCmdHelp)
+	emdata := emailCmdHelp{
+		Listname:           ml.Name,
+		Description:        ml.Description,
+		ListAddress:        listaddr,
+		SubscribeAddress:   listCmdAddress(listaddr, listCmdSubscribe),
+		UnsubscribeAddress: listCmdAddress(listaddr, listCmdUnsubscribe),
+		ImmediateAddress:   listCmdAddress(listaddr, listCmdDelivery+mailboxCmdArgSeparator+mailinglistmodel.MemberDeliveryImmediate),
+		DailyAddress:       listCmdAddress(listaddr, listCmdDelivery+mailboxCmdArgSeparator+mailinglistmodel.MemberDeliveryDaily),
+		WeeklyAddress:      listCmdAddress(listaddr, listCmdDelivery+mailboxCmdArgSeparator+mailinglistmodel.MemberDeliveryWeekly),
+		HelpAddress:        helpAddr,
+	}
+	if err := s.sendCmdMail(ctx, helpAddr, userid, s.tplname.cmdhelp, emdata); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,441 @@
+package mailinglist
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"xorkevin.dev/governor/service/mail"
+	"xorkevin.dev/governor/service/mailinglist/mailinglistmodel"
+	"xorkevin.dev/klog"
+)
+
+func (s *testService) newTestSession() *smtpSession {
+	return &smtpSession{
+		service:    s.Service,
+		log:        s.log,
+		ctx:        context.Background(),
+		id:         "reqid",
+		from:       "sender@example.com",
+		fromDomain: "example.com",
+	}
+}
+
+func TestRcptListCmd(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		Test string
+		To   string
+		Cmd  string
+		Arg  string
+		Err  error
+	}{
+		{
+			Test: "list msg",
+			To:   "owner.mylist@lists.example.com",
+		},
+		{
+			Test: "subscribe",
+			To:   "owner.mylist+subscribe@lists.example.com",
+			Cmd:  listCmdSubscribe,
+		},
+		{
+			Test: "unsubscribe",
+			To:   "owner.mylist+unsubscribe@lists.example.com",
+			Cmd:  listCmdUnsubscribe,
+		},
+		{
+			Test: "help",
+			To:   "owner.mylist+help@lists.example.com",
+			Cmd:  listCmdHelp,
+		},
+		{
+			Test: "delivery with arg",
+			To:   "owner.mylist+delivery-daily@lists.example.com",
+			Cmd:  listCmdDelivery,
+			Arg:  mailinglistmodel.MemberDeliveryDaily,
+		},
+		{
+			Test: "confirm with key",
+			To:   "owner.mylist+confirm-0123abcd@lists.example.com",
+			Cmd:  listCmdConfirm,
+			Arg:  "0123abcd",
+		},
+		{
+			Test: "delivery with invalid arg",
+			To:   "owner.mylist+delivery-hourly@lists.example.com",
+			Err:  errSMTPMailbox,
+		},
+		{
+			Test: "subscribe with arg",
+			To:   "owner.mylist+subscribe-daily@lists.example.com",
+			Err:  errSMTPMailbox,
+		},
+		{
+			Test: "confirm without key",
+			To:   "owner.mylist+confirm@lists.example.com",
+			Err:  errSMTPMailbox,
+		},
+		{
+			Test: "confirm with long key",
+			To:   "owner.mylist+confirm-" + strings.Repeat("a", lengthCapCmdKey+1) + "@lists.example.com",
+			Err:  errSMTPMailbox,
+		},
+		{
+			Test: "unknown cmd",
+			To:   "owner.mylist+bogus@lists.example.com",
+			Err:  errSMTPMailbox,
+		},
+		{
+			Test: "unknown list",
+			To:   "owner.otherlist+subscribe@lists.example.com",
+			Err:  errSMTPMailbox,
+		},
+	} {
+		t.Run(tc.Test, func(t *testing.T) {
+			t.Parallel()
+
+			assert := require.New(t)
+
+			s := newTestService()
+			s.addUser("owner", "owner")
+			ml := s.addList("owner", "mylist")
+			sess := s.newTestSession()
+
+			err := sess.Rcpt(tc.To, nil)
+			if tc.Err != nil {
+				assert.ErrorIs(err, tc.Err)
+				assert.Equal("", sess.rcptTo)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tc.To, sess.rcptTo)
+			assert.Equal(ml.ListID, sess.rcptList)
+			assert.Equal(tc.Cmd, sess.rcptCmd)
+			assert.Equal(tc.Arg, sess.rcptCmdArg)
+		})
+	}
+}
+
+func TestRequestListCmd(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		Test   string
+		Member bool
+		Cmd    string
+		Arg    string
+		Err    bool
+	}{
+		{
+			Test: "subscribe",
+			Cmd:  listCmdSubscribe,
+		},
+		{
+			Test:   "subscribe member",
+			Member: true,
+			Cmd:    listCmdSubscribe,
+			Err:    true,
+		},
+		{
+			Test:   "unsubscribe",
+			Member: true,
+			Cmd:    listCmdUnsubscribe,
+		},
+		{
+			Test: "unsubscribe non member",
+			Cmd:  listCmdUnsubscribe,
+			Err:  true,
+		},
+		{
+			Test:   "delivery",
+			Member: true,
+			Cmd:    listCmdDelivery,
+			Arg:    mailinglistmodel.MemberDeliveryWeekly,
+		},
+		{
+			Test: "delivery non member",
+			Cmd:  listCmdDelivery,
+			Arg:  mailinglistmodel.MemberDeliveryWeekly,
+			Err:  true,
+		},
+		{
+			Test: "invalid cmd",
+			Cmd:  listCmdHelp,
+			Err:  true,
+		},
+	} {
+		t.Run(tc.Test, func(t *testing.T) {
+			t.Parallel()
+
+			assert := require.New(t)
+
+			s := newTestService()
+			s.addUser("owner", "owner")
+			s.addUser("sender", "sender")
+			ml := s.addList("owner", "mylist")
+			ml.MemberPolicy = listMemberPolicyUser
+			if tc.Member {
+				assert.NoError(s.lists.InsertMembers(context.Background(), s.lists.AddMembers(ml, []string{"sender"})))
+			}
+
+			err := s.requestListCmd(context.Background(), ml.ListID, "sender", tc.Cmd, tc.Arg)
+			if tc.Err {
+				assert.ErrorIs(err, errListCmd{})
+				assert.Empty(s.lists.cmds)
+				assert.Empty(s.mailer.sent)
+				return
+			}
+			assert.NoError(err)
+
+			m, err := s.lists.GetCmd(context.Background(), ml.ListID, "sender")
+			assert.NoError(err)
+			assert.Equal(tc.Cmd, m.Cmd)
+			assert.Equal(tc.Arg, m.Arg)
+
+			assert.Equal([]string{"sender@example.com"}, s.mailer.sentTo())
+			assert.Equal(mail.TplLocal(s.tplname.cmdconfirm), s.mailer.sent[0].tpl)
+			emdata, ok := s.mailer.sent[0].emdata.(emailCmdConfirm)
+			assert.True(ok)
+			assert.Equal("owner.mylist@lists.example.com", emdata.ListAddress)
+			assert.Equal("owner.mylist+confirm-cmdkey1@lists.example.com", emdata.ConfirmAddress)
+			assert.Equal(tc.Cmd, emdata.Command)
+			assert.Equal(tc.Arg, emdata.Arg)
+		})
+	}
+
+	t.Run("only the latest cmd may be confirmed", func(t *testing.T) {
+		t.Parallel()
+
+		assert := require.New(t)
+
+		s := newTestService()
+		s.addUser("owner", "owner")
+		s.addUser("sender", "sender")
+		ml := s.addList("owner", "mylist")
+		ml.MemberPolicy = listMemberPolicyUser
+
+		ctx := context.Background()
+		assert.NoError(s.requestListCmd(ctx, ml.ListID, "sender", listCmdSubscribe, ""))
+		assert.NoError(s.requestListCmd(ctx, ml.ListID, "sender", listCmdSubscribe, ""))
+		assert.ErrorIs(s.confirmListCmd(ctx, ml.ListID, "sender", "cmdkey1"), errListCmd{})
+		assert.NoError(s.requestListCmd(ctx, ml.ListID, "sender", listCmdSubscribe, ""))
+		assert.NoError(s.confirmListCmd(ctx, ml.ListID, "sender", "cmdkey3"))
+		_, err := s.lists.GetMember(ctx, ml.ListID, "sender")
+		assert.NoError(err)
+	})
+}
+
+func TestConfirmListCmd(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		Test      string
+		WasMember bool
+		Cmd       string
+		Arg       string
+		Key       string
+		Age       time.Duration
+		Err       bool
+		IsMember  bool
+		Delivery  string
+	}{
+		{
+			Test:     "subscribe",
+			Cmd:      listCmdSubscribe,
+			Key:      "cmdkey1",
+			IsMember: true,
+			Delivery: mailinglistmodel.MemberDeliveryImmediate,
+		},
+		{
+			Test:      "unsubscribe",
+			WasMember: true,
+			Cmd:       listCmdUnsubscribe,
+			Key:       "cmdkey1",
+		},
+		{
+			Test:      "delivery",
+			WasMember: true,
+			Cmd:       listCmdDelivery,
+			Arg:       mailinglistmodel.MemberDeliveryDaily,
+			Key:       "cmdkey1",
+			IsMember:  true,
+			Delivery:  mailinglistmodel.MemberDeliveryDaily,
+		},
+		{
+			Test:     "subscribe before expiry",
+			Cmd:      listCmdSubscribe,
+			Key:      "cmdkey1",
+			Age:      23 * time.Hour,
+			IsMember: true,
+			Delivery: mailinglistmodel.MemberDeliveryImmediate,
+		},
+		{
+			Test: "expired",
+			Cmd:  listCmdSubscribe,
+			Key:  "cmdkey1",
+			Age:  25 * time.Hour,
+			Err:  true,
+		},
+		{
+			Test: "invalid key",
+			Cmd:  listCmdSubscribe,
+			Key:  "cmdkey2",
+			Err:  true,
+		},
+		{
+			Test: "delivery after unsubscribe",
+			Cmd:  listCmdDelivery,
+			Arg:  mailinglistmodel.MemberDeliveryDaily,
+			Key:  "cmdkey1",
+			Err:  true,
+		},
+	} {
+		t.Run(tc.Test, func(t *testing.T) {
+			t.Parallel()
+
+			assert := require.New(t)
+
+			s := newTestService()
+			ml := s.addList("owner", "mylist")
+			ml.MemberPolicy = listMemberPolicyUser
+			ctx := context.Background()
+			if tc.WasMember {
+				assert.NoError(s.lists.InsertMembers(ctx, s.lists.AddMembers(ml, []string{"sender"})))
+			}
+			m, _, err := s.lists.NewCmd(ml.ListID, "sender", tc.Cmd, tc.Arg)
+			assert.NoError(err)
+			m.CreationTime = time.Now().Add(-tc.Age).Unix()
+			assert.NoError(s.lists.InsertCmd(ctx, m))
+
+			err = s.confirmListCmd(ctx, ml.ListID, "sender", tc.Key)
+			if tc.Err {
+				assert.ErrorIs(err, errListCmd{})
+			} else {
+				assert.NoError(err)
+			}
+			member, err := s.lists.GetMember(ctx, ml.ListID, "sender")
+			if !tc.IsMember {
+				assert.Error(err)
+			} else {
+				assert.NoError(err)
+				assert.Equal(tc.Delivery, member.Delivery)
+			}
+			if tc.Key == "cmdkey1" {
+				// cmds may only be confirmed once
+				assert.Empty(s.lists.cmds)
+				assert.ErrorIs(s.confirmListCmd(ctx, ml.ListID, "sender", tc.Key), errListCmd{})
+			}
+		})
+	}
+}
+
+func TestDataListCmd(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		Test          string
+		FromDomain    string
+		AuthEmail     string
+		From          string
+		AutoSubmitted string
+		Err           error
+		Requested     bool
+	}{
+		{
+			Test:       "rejects unaligned sender",
+			FromDomain: "other.example.org",
+			From:       "sender@example.com",
+			Err:        errSPFAlignment,
+		},
+		{
+			Test:      "submission requests cmd",
+			AuthEmail: "sender@example.com",
+			From:      "sender@example.com",
+			Requested: true,
+		},
+		{
+			Test:          "submission accepts explicit non auto submitted cmd",
+			AuthEmail:     "sender@example.com",
+			From:          "sender@example.com",
+			AutoSubmitted: "no",
+			Requested:     true,
+		},
+		{
+			Test:      "submission rejects unaligned sender",
+			AuthEmail: "sender@example.com",
+			From:      "other@example.com",
+			Err:       errSMTPAuthSender,
+		},
+		{
+			Test:          "submission skips auto replied cmd",
+			AuthEmail:     "sender@example.com",
+			From:          "sender@example.com",
+			AutoSubmitted: "auto-replied",
+		},
+		{
+			Test:          "submission skips auto generated cmd",
+			AuthEmail:     "sender@example.com",
+			From:          "sender@example.com",
+			AutoSubmitted: "Auto-Generated",
+		},
+	} {
+		t.Run(tc.Test, func(t *testing.T) {
+			t.Parallel()
+
+			assert := require.New(t)
+
+			s := newTestService()
+			s.addUser("owner", "owner")
+			s.addUser("sender", "sender")
+			ml := s.addList("owner", "mylist")
+			ml.MemberPolicy = listMemberPolicyUser
+
+			sess := s.newTestSession()
+			if tc.FromDomain != "" {
+				sess.fromDomain = tc.FromDomain
+			}
+			if tc.AuthEmail != "" {
+				sess.submission = true
+				sess.authUserid = "sender"
+				sess.authUsername = "sender"
+				sess.authEmail = tc.AuthEmail
+				sess.ctx = klog.CtxWithAttrs(sess.ctx, klog.AString("smtp.auth.userid", "sender"))
+			}
+			assert.NoError(sess.Rcpt("owner.mylist+subscribe@lists.example.com", nil))
+
+			headers := []string{
+				"From: " + tc.From,
+				"To: owner.mylist+subscribe@lists.example.com",
+				"Message-ID: <cmd@example.com>",
+				"Subject: subscribe",
+			}
+			if tc.AutoSubmitted != "" {
+				headers = append(headers, "Auto-Submitted: "+tc.AutoSubmitted)
+			}
+			msg := strings.Join(append(headers, "", ""), "\r\n")
+
+			err := sess.Data(strings.NewReader(msg))
+			if tc.Err != nil {
+				assert.ErrorIs(err, tc.Err)
+			} else {
+				assert.NoError(err)
+			}
+			if tc.Requested {
+				m, err := s.lists.GetCmd(context.Background(), ml.ListID, "sender")
+				assert.NoError(err)
+				assert.Equal(listCmdSubscribe, m.Cmd)
+				assert.Equal([]string{"sender@example.com"}, s.mailer.sentTo())
+			} else {
+				assert.Empty(s.lists.cmds)
+				assert.Empty(s.mailer.sent)
+			}
+			// list cmds are never stored as list msgs
+			assert.Empty(s.lists.msgs)
+			assert.Empty(s.dir.names())
+		})
+	}
+}
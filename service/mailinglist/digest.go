@@ -0,0 +1,429 @@
+package mailinglist
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-message"
+	emmail "github.com/emersion/go-message/mail"
+	"xorkevin.dev/governor/service/dbsql"
+	"xorkevin.dev/governor/service/events"
+	"xorkevin.dev/governor/service/mailinglist/mailinglistmodel"
+	"xorkevin.dev/governor/service/objstore"
+	"xorkevin.dev/governor/util/ksync"
+	"xorkevin.dev/governor/util/rank"
+	"xorkevin.dev/kerrors"
+	"xorkevin.dev/klog"
+)
+
+const (
+	digestListBatchSize = 256
+	digestMsgBatchSize  = 256
+	digestIDPrefix      = "digest"
+	digestPeriodDay     = 24 * time.Hour
+	digestPeriodWeek    = 7 * digestPeriodDay
+)
+
+type (
+	digestProps struct {
+		ListID    string `json:"listid"`
+		Kind      string `json:"kind"`
+		PeriodEnd int64  `json:"period_end"`
+	}
+)
+
+// digestPeriod returns the bounds in unix milliseconds of the most recently
+// completed digest period before t. Daily periods end at midnight UTC, and
+// weekly periods end at midnight UTC on Monday.
+func digestPeriod(kind string, t time.Time) (int64, int64) {
+	t = t.UTC()
+	end := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch kind {
+	case mailinglistmodel.MemberDeliveryDaily:
+		return end.Add(-digestPeriodDay).UnixMilli(), end.UnixMilli()
+	case mailinglistmodel.MemberDeliveryWeekly:
+		end = end.AddDate(0, 0, -((int(end.Weekday()) + 6) % 7))
+		return end.Add(-digestPeriodWeek).UnixMilli(), end.UnixMilli()
+	default:
+		return 0, 0
+	}
+}
+
+func digestMsgid(kind string, periodEnd int64) string {
+	return fmt.Sprintf("%s.%s.%d", digestIDPrefix, kind, periodEnd)
+}
+
+func (s *Service) digestLoop(ctx context.Context, wg *ksync.WaitGroup) {
+	defer wg.Done()
+	ticker := time.NewTicker(s.digestinterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.scheduleDigests(ctx)
+		}
+	}
+}
+
+func (s *Service) scheduleDigests(ctx context.Context) {
+	now := time.Now().Round(0)
+	for _, kind := range []string{mailinglistmodel.MemberDeliveryDaily, mailinglistmodel.MemberDeliveryWeekly} {
+		start, end := digestPeriod(kind, now)
+		ctx := klog.CtxWithAttrs(ctx,
+			klog.AString("list.digest.kind", kind),
+			klog.AInt64("list.digest.period_end", end),
+		)
+		if err := s.scheduleDigestKind(ctx, kind, start, end, now); err != nil {
+			s.log.Err(ctx, kerrors.WithMsg(err, "Failed to schedule list digests"))
+		}
+	}
+}
+
+func (s *Service) scheduleDigestKind(ctx context.Context, kind string, start, end int64, now time.Time) error {
+	for offset := 0; ; offset += digestListBatchSize {
+		listids, err := s.lists.GetDigestLists(ctx, kind, digestListBatchSize, offset)
+		if err != nil {
+			return kerrors.WithMsg(err, "Failed to get digest lists")
+		}
+		for _, i := range listids {
+			if err := s.scheduleDigest(ctx, i, kind, start, end, now); err != nil {
+				s.log.Err(ctx, kerrors.WithMsg(err, "Failed to schedule list digest"),
+					klog.AString("list", i),
+				)
+			}
+		}
+		if len(listids) < digestListBatchSize {
+			return nil
+		}
+	}
+}
+
+func (s *Service) scheduleDigest(ctx context.Context, listid string, kind string, start, end int64, now time.Time) error {
+	if m, err := s.lists.GetDigest(ctx, listid, kind, end); err != nil {
+		if !errors.Is(err, dbsql.ErrNotFound) {
+			return kerrors.WithMsg(err, "Failed to get list digest")
+		}
+		if err := s.lists.InsertDigest(ctx, s.lists.NewDigest(listid, kind, start, end)); err != nil {
+			if errors.Is(err, dbsql.ErrUnique) {
+				// digest has been scheduled by another instance
+				return nil
+			}
+			return kerrors.WithMsg(err, "Failed to insert list digest")
+		}
+	} else {
+		// Digests that have not been sent after a full scheduling interval are
+		// assumed to have failed to publish, and are republished. Sending a
+		// digest is idempotent, so duplicate events are harmless.
+		if m.Sent || now.Add(-s.digestinterval).Unix() < m.CreationTime {
+			return nil
+		}
+	}
+	b, err := encodeListEventDigest(digestProps{
+		ListID:    listid,
+		Kind:      kind,
+		PeriodEnd: end,
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.events.Publish(ctx, events.NewMsgs(s.streammail, listid, b)...); err != nil {
+		return kerrors.WithMsg(err, "Failed to publish list digest event")
+	}
+	return nil
+}
+
+type (
+	digestEntry struct {
+		msg   mailinglistmodel.MsgModel
+		depth int
+	}
+
+	digestThread struct {
+		threadid string
+		msgs     []digestEntry
+	}
+)
+
+// groupDigestThreads groups messages by thread in order of first appearance.
+// Messages within a thread are ordered depth first by reply, with messages
+// whose parents are outside of the digest period treated as roots.
+func groupDigestThreads(msgs []mailinglistmodel.MsgModel) []digestThread {
+	var threadids []string
+	byThread := map[string][]mailinglistmodel.MsgModel{}
+	for _, i := range msgs {
+		threadid := i.ThreadID
+		if threadid == "" {
+			threadid = i.Msgid
+		}
+		if _, ok := byThread[threadid]; !ok {
+			threadids = append(threadids, threadid)
+		}
+		byThread[threadid] = append(byThread[threadid], i)
+	}
+	threads := make([]digestThread, 0, len(threadids))
+	for _, threadid := range threadids {
+		tmsgs := byThread[threadid]
+		present := make(map[string]struct{}, len(tmsgs))
+		for _, i := range tmsgs {
+			present[i.Msgid] = struct{}{}
+		}
+		var roots []mailinglistmodel.MsgModel
+		children := map[string][]mailinglistmodel.MsgModel{}
+		for _, i := range tmsgs {
+			if _, ok := present[i.ParentID]; i.ParentID == "" || !ok {
+				roots = append(roots, i)
+			} else {
+				children[i.ParentID] = append(children[i.ParentID], i)
+			}
+		}
+		entries := make([]digestEntry, 0, len(tmsgs))
+		var walk func(m mailinglistmodel.MsgModel, depth int)
+		walk = func(m mailinglistmodel.MsgModel, depth int) {
+			entries = append(entries, digestEntry{
+				msg:   m,
+				depth: depth,
+			})
+			for _, i := range children[m.Msgid] {
+				walk(i, depth+1)
+			}
+		}
+		for _, i := range roots {
+			walk(i, 0)
+		}
+		threads = append(threads, digestThread{
+			threadid: threadid,
+			msgs:     entries,
+		})
+	}
+	return threads
+}
+
+func (s *Service) listAddress(ctx context.Context, m *mailinglistmodel.ListModel) (string, error) {
+	if rank.IsValidOrgName(m.CreatorID) {
+		o, err := s.orgs.GetByID(ctx, strings.TrimPrefix(m.CreatorID, rank.ToOrgName("")))
+		if err != nil {
+			return "", kerrors.WithMsg(err, "Failed to get list owner org")
+		}
+		return o.Name + mailboxKeySeparator + m.Listname + "@" + s.orgdomain, nil
+	}
+	u, err := s.users.GetByID(ctx, m.CreatorID)
+	if err != nil {
+		return "", kerrors.WithMsg(err, "Failed to get list owner user")
+	}
+	return u.Username + mailboxKeySeparator + m.Listname + "@" + s.usrdomain, nil
+}
+
+func (s *Service) readMsgContent(ctx context.Context, listid, msgid string) (_ []byte, retErr error) {
+	obj, _, err := s.rcvMailDir.Subdir(listid).Get(ctx, s.encodeMsgid(msgid))
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get msg content")
+	}
+	defer func() {
+		if err := obj.Close(); err != nil {
+			retErr = errors.Join(retErr, kerrors.WithMsg(err, "Failed to close msg content"))
+		}
+	}()
+	b, err := io.ReadAll(obj)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to read msg content")
+	}
+	return b, nil
+}
+
+const (
+	mediaTypeMultipartMixed  = "multipart/mixed"
+	mediaTypeMultipartDigest = "multipart/digest"
+	mediaTypeTextPlain       = "text/plain"
+	mediaTypeRFC822          = "message/rfc822"
+)
+
+// writeDigest writes a multipart/mixed message with a table of contents
+// followed by a multipart/digest of the messages
+func writeDigest(w io.Writer, listaddr string, msgid string, subject string, date time.Time, threads []digestThread, contents map[string][]byte) error {
+	var h emmail.Header
+	h.SetAddressList(headerFrom, []*emmail.Address{{Address: listaddr}})
+	h.SetAddressList(headerTo, []*emmail.Address{{Address: listaddr}})
+	h.SetSubject(subject)
+	h.SetDate(date)
+	h.SetMessageID(msgid)
+	h.SetContentType(mediaTypeMultipartMixed, nil)
+	mw, err := message.CreateWriter(w, h.Header)
+	if err != nil {
+		return kerrors.WithMsg(err, "Failed to create digest writer")
+	}
+
+	toc := &bytes.Buffer{}
+	fmt.Fprintf(toc, "%s\r\n\r\nTopics:\r\n", subject)
+	n := 0
+	for _, t := range threads {
+		toc.WriteString("\r\n")
+		for _, i := range t.msgs {
+			if _, ok := contents[i.msg.Msgid]; !ok {
+				continue
+			}
+			n++
+			subj := i.msg.Subject
+			if subj == "" {
+				subj = "(no subject)"
+			}
+			fmt.Fprintf(toc, "%s%d. %s\r\n", strings.Repeat("  ", i.depth+1), n, subj)
+		}
+	}
+	var th message.Header
+	th.SetContentType(mediaTypeTextPlain, map[string]string{"charset": "utf-8"})
+	tw, err := mw.CreatePart(th)
+	if err != nil {
+		return kerrors.WithMsg(err, "Failed to create digest contents part")
+	}
+	if _, err := io.Copy(tw, toc); err != nil {
+		return kerrors.WithMsg(err, "Failed to write digest contents")
+	}
+	if err := tw.Close(); err != nil {
+		return kerrors.WithMsg(err, "Failed to close digest contents part")
+	}
+
+	var dh message.Header
+	dh.SetContentType(mediaTypeMultipartDigest, nil)
+	dw, err := mw.CreatePart(dh)
+	if err != nil {
+		return kerrors.WithMsg(err, "Failed to create digest part")
+	}
+	for _, t := range threads {
+		for _, i := range t.msgs {
+			b, ok := contents[i.msg.Msgid]
+			if !ok {
+				continue
+			}
+			var ph message.Header
+			ph.SetContentType(mediaTypeRFC822, nil)
+			pw, err := dw.CreatePart(ph)
+			if err != nil {
+				return kerrors.WithMsg(err, "Failed to create digest msg part")
+			}
+			if _, err := pw.Write(b); err != nil {
+				return kerrors.WithMsg(err, "Failed to write digest msg")
+			}
+			if err := pw.Close(); err != nil {
+				return kerrors.WithMsg(err, "Failed to close digest msg part")
+			}
+		}
+	}
+	if err := dw.Close(); err != nil {
+		return kerrors.WithMsg(err, "Failed to close digest part")
+	}
+	if err := mw.Close(); err != nil {
+		return kerrors.WithMsg(err, "Failed to close digest writer")
+	}
+	return nil
+}
+
+func (s *Service) digestEventHandler(ctx context.Context, props digestProps) error {
+	ctx = klog.CtxWithAttrs(ctx,
+		klog.AString("list", props.ListID),
+		klog.AString("list.digest.kind", props.Kind),
+		klog.AInt64("list.digest.period_end", props.PeriodEnd),
+	)
+	ml, err := s.lists.GetListByID(ctx, props.ListID)
+	if err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			s.log.Err(ctx, kerrors.WithMsg(err, "List not found"))
+			return nil
+		}
+		return kerrors.WithMsg(err, "Failed to get list")
+	}
+	dm, err := s.lists.GetDigest(ctx, props.ListID, props.Kind, props.PeriodEnd)
+	if err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			s.log.Err(ctx, kerrors.WithMsg(err, "Digest not found"))
+			return nil
+		}
+		return kerrors.WithMsg(err, "Failed to get list digest")
+	}
+	digestid := digestMsgid(dm.Kind, dm.PeriodEnd)
+	if dm.Sent {
+		if err := s.lists.DeleteSentMsgLogs(ctx, dm.ListID, []string{digestid}); err != nil {
+			return kerrors.WithMsg(err, "Failed to delete sent message logs")
+		}
+		return nil
+	}
+
+	var msgs []mailinglistmodel.MsgModel
+	for len(msgs) < s.digestmaxmsgs {
+		m, err := s.lists.GetListMsgsRange(ctx, dm.ListID, dm.PeriodStart, dm.PeriodEnd, min(digestMsgBatchSize, s.digestmaxmsgs-len(msgs)), len(msgs))
+		if err != nil {
+			return kerrors.WithMsg(err, "Failed to get list messages")
+		}
+		msgs = append(msgs, m...)
+		if len(m) < digestMsgBatchSize {
+			break
+		}
+	}
+	contents := make(map[string][]byte, len(msgs))
+	for _, i := range msgs {
+		b, err := s.readMsgContent(ctx, i.ListID, i.Msgid)
+		if err != nil {
+			if errors.Is(err, objstore.ErrNotFound) {
+				s.log.WarnErr(ctx, kerrors.WithMsg(err, "Msg content not found"),
+					klog.AString("list.msgid", i.Msgid),
+				)
+				continue
+			}
+			return err
+		}
+		contents[i.Msgid] = b
+	}
+	if len(contents) == 0 {
+		if err := s.lists.MarkDigestSent(ctx, dm.ListID, dm.Kind, dm.PeriodEnd); err != nil {
+			return kerrors.WithMsg(err, "Failed to mark list digest sent")
+		}
+		return nil
+	}
+
+	listaddr, err := s.listAddress(ctx, ml)
+	if err != nil {
+		return err
+	}
+	kindName := "Daily"
+	if dm.Kind == mailinglistmodel.MemberDeliveryWeekly {
+		kindName = "Weekly"
+	}
+	subject := fmt.Sprintf("[%s] %s digest for %s", ml.Name, kindName, time.UnixMilli(dm.PeriodStart).UTC().Format(time.DateOnly))
+	var mb bytes.Buffer
+	if err := writeDigest(&mb, listaddr, digestid+"."+dm.ListID+"@"+s.authdomain, subject, time.UnixMilli(dm.PeriodEnd), groupDigestThreads(msgs), contents); err != nil {
+		return err
+	}
+
+	for {
+		userids, err := s.lists.GetUnsentMsgs(ctx, dm.ListID, digestid, dm.Kind, mailingListSendBatchSize)
+		if err != nil {
+			return kerrors.WithMsg(err, "Failed to get unsent digests")
+		}
+		if len(userids) == 0 {
+			break
+		}
+		if err := s.fwdListMsg(ctx, ml, listaddr, digestid, mb.Bytes(), userids, false); err != nil {
+			return kerrors.WithMsg(err, "Failed to send digest")
+		}
+		if len(userids) < mailingListSendBatchSize {
+			break
+		}
+	}
+
+	if err := s.lists.MarkDigestSent(ctx, dm.ListID, dm.Kind, dm.PeriodEnd); err != nil {
+		return kerrors.WithMsg(err, "Failed to mark list digest sent")
+	}
+	if err := s.lists.DeleteSentMsgLogs(ctx, dm.ListID, []string{digestid}); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete sent message logs")
+	}
+	s.log.Info(ctx, "Sent list digest",
+		klog.AInt("list.digest.msgs", len(contents)),
+	)
+	return nil
+}
@@ -0,0 +1,217 @@
+package mailinglist
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-message"
+	"github.com/stretchr/testify/require"
+	"xorkevin.dev/governor/service/mailinglist/mailinglistmodel"
+)
+
+func TestGroupDigestThreads(t *testing.T) {
+	t.Parallel()
+
+	type entry struct {
+		Msgid string
+		Depth int
+	}
+
+	for _, tc := range []struct {
+		Test    string
+		Msgs    []mailinglistmodel.MsgModel
+		Threads map[string][]entry
+		Order   []string
+	}{
+		{
+			Test:    "empty",
+			Msgs:    nil,
+			Threads: map[string][]entry{},
+			Order:   []string{},
+		},
+		{
+			Test: "msgs without threads are their own threads",
+			Msgs: []mailinglistmodel.MsgModel{
+				{Msgid: "a"},
+				{Msgid: "b"},
+			},
+			Threads: map[string][]entry{
+				"a": {{Msgid: "a", Depth: 0}},
+				"b": {{Msgid: "b", Depth: 0}},
+			},
+			Order: []string{"a", "b"},
+		},
+		{
+			Test: "orders replies depth first",
+			Msgs: []mailinglistmodel.MsgModel{
+				{Msgid: "root", ThreadID: "root"},
+				{Msgid: "r1", ThreadID: "root", ParentID: "root"},
+				{Msgid: "r2", ThreadID: "root", ParentID: "root"},
+				{Msgid: "r1a", ThreadID: "root", ParentID: "r1"},
+			},
+			Threads: map[string][]entry{
+				"root": {
+					{Msgid: "root", Depth: 0},
+					{Msgid: "r1", Depth: 1},
+					{Msgid: "r1a", Depth: 2},
+					{Msgid: "r2", Depth: 1},
+				},
+			},
+			Order: []string{"root"},
+		},
+		{
+			Test: "treats msgs with parents outside the period as roots",
+			Msgs: []mailinglistmodel.MsgModel{
+				{Msgid: "other", ThreadID: "other"},
+				{Msgid: "r1", ThreadID: "root", ParentID: "root"},
+				{Msgid: "r2", ThreadID: "root", ParentID: "root"},
+				{Msgid: "r2a", ThreadID: "root", ParentID: "r2"},
+			},
+			Threads: map[string][]entry{
+				"other": {{Msgid: "other", Depth: 0}},
+				"root": {
+					{Msgid: "r1", Depth: 0},
+					{Msgid: "r2", Depth: 0},
+					{Msgid: "r2a", Depth: 1},
+				},
+			},
+			Order: []string{"other", "root"},
+		},
+	} {
+		t.Run(tc.Test, func(t *testing.T) {
+			t.Parallel()
+
+			assert := require.New(t)
+
+			threads := groupDigestThreads(tc.Msgs)
+			order := make([]string, 0, len(threads))
+			res := map[string][]entry{}
+			for _, i := range threads {
+				order = append(order, i.threadid)
+				entries := make([]entry, 0, len(i.msgs))
+				for _, j := range i.msgs {
+					entries = append(entries, entry{
+						Msgid: j.msg.Msgid,
+						Depth: j.depth,
+					})
+				}
+				res[i.threadid] = entries
+			}
+			assert.Equal(tc.Order, order)
+			assert.Equal(tc.Threads, res)
+		})
+	}
+}
+
+func TestDigestPeriod(t *testing.T) {
+	t.Parallel()
+
+	// Wednesday
+	now := time.Date(2023, time.March, 8, 13, 14, 15, 0, time.UTC)
+
+	for _, tc := range []struct {
+		Test  string
+		Kind  string
+		Start time.Time
+		End   time.Time
+	}{
+		{
+			Test:  "daily",
+			Kind:  mailinglistmodel.MemberDeliveryDaily,
+			Start: time.Date(2023, time.March, 7, 0, 0, 0, 0, time.UTC),
+			End:   time.Date(2023, time.March, 8, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Test:  "weekly",
+			Kind:  mailinglistmodel.MemberDeliveryWeekly,
+			Start: time.Date(2023, time.February, 27, 0, 0, 0, 0, time.UTC),
+			End:   time.Date(2023, time.March, 6, 0, 0, 0, 0, time.UTC),
+		},
+	} {
+		t.Run(tc.Test, func(t *testing.T) {
+			t.Parallel()
+
+			assert := require.New(t)
+
+			start, end := digestPeriod(tc.Kind, now)
+			assert.Equal(tc.Start.UnixMilli(), start)
+			assert.Equal(tc.End.UnixMilli(), end)
+		})
+	}
+}
+
+func TestWriteDigest(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	threads := groupDigestThreads([]mailinglistmodel.MsgModel{
+		{Msgid: "root", ThreadID: "root", Subject: "Hello"},
+		{Msgid: "reply", ThreadID: "root", ParentID: "root", Subject: "Re: Hello"},
+		{Msgid: "missing", ThreadID: "root", ParentID: "root", Subject: "Missing"},
+		{Msgid: "other", ThreadID: "other"},
+	})
+	contents := map[string][]byte{
+		"root":  []byte("Subject: Hello\r\n\r\nroot body\r\n"),
+		"reply": []byte("Subject: Re: Hello\r\n\r\nreply body\r\n"),
+		"other": []byte("Subject: \r\n\r\nother body\r\n"),
+	}
+
+	var b bytes.Buffer
+	assert.NoError(writeDigest(&b, "list@example.com", "digest.daily.1@example.com", "Daily digest", time.Unix(0, 0), threads, contents))
+
+	e, err := message.Read(&b)
+	assert.NoError(err)
+	mediaType, _, err := e.Header.ContentType()
+	assert.NoError(err)
+	assert.Equal(mediaTypeMultipartMixed, mediaType)
+	assert.Equal("Daily digest", e.Header.Get("Subject"))
+
+	mr := e.MultipartReader()
+	assert.NotNil(mr)
+
+	toc, err := mr.NextPart()
+	assert.NoError(err)
+	mediaType, _, err = toc.Header.ContentType()
+	assert.NoError(err)
+	assert.Equal(mediaTypeTextPlain, mediaType)
+	tocBody, err := io.ReadAll(toc.Body)
+	assert.NoError(err)
+	assert.Equal(strings.Join([]string{
+		"Daily digest",
+		"",
+		"Topics:",
+		"",
+		"  1. Hello",
+		"    2. Re: Hello",
+		"",
+		"  3. (no subject)",
+		"",
+	}, "\r\n"), string(tocBody))
+
+	digest, err := mr.NextPart()
+	assert.NoError(err)
+	mediaType, _, err = digest.Header.ContentType()
+	assert.NoError(err)
+	assert.Equal(mediaTypeMultipartDigest, mediaType)
+	dr := digest.MultipartReader()
+	assert.NotNil(dr)
+	for _, i := range []string{"root", "reply", "other"} {
+		p, err := dr.NextPart()
+		assert.NoError(err)
+		mediaType, _, err := p.Header.ContentType()
+		assert.NoError(err)
+		assert.Equal(mediaTypeRFC822, mediaType)
+		body, err := io.ReadAll(p.Body)
+		assert.NoError(err)
+		assert.Equal(string(contents[i]), string(body))
+	}
+	_, err = dr.NextPart()
+	assert.ErrorIs(err, io.EOF)
+
+	_, err = mr.NextPart()
+	assert.ErrorIs(err, io.EOF)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"net"
+	"strings"
 	"sync/atomic"
 	"time"
 
@@ -30,6 +31,9 @@ const (
 	listEventKindMail   = "mail"
 	listEventKindSend   = "send"
 	listEventKindDelete = "delete"
+	listEventKindDigest = "digest"
+	listEventKindHeld   = "held"
+	listEventKindIndex  = "index"
 )
 
 type (
@@ -48,6 +52,9 @@ type (
 		Mail   mailProps
 		Send   sendProps
 		Delete delProps
+		Digest digestProps
+		Held   heldProps
+		Index  indexProps
 	}
 
 	mailProps struct {
@@ -68,32 +75,50 @@ type (
 	MailingList interface{}
 
 	Service struct {
-		lists        mailinglistmodel.Repo
-		mailBucket   objstore.Bucket
-		rcvMailDir   objstore.Dir
-		events       events.Events
-		users        user.Users
-		orgs         org.Orgs
-		mailer       mail.Mailer
-		ratelimiter  ratelimit.Ratelimiter
-		gate         gate.Gate
-		log          *klog.LevelLogger
-		tracer       governor.Tracer
-		scopens      string
-		streamns     string
-		streammail   string
-		resolver     dns.Resolver
-		server       *smtp.Server
-		port         string
-		authdomain   string
-		usrdomain    string
-		orgdomain    string
-		maxmsgsize   int64
-		readtimeout  time.Duration
-		writetimeout time.Duration
-		streamsize   int64
-		eventsize    int32
-		wg           *ksync.WaitGroup
+		lists          mailinglistmodel.Repo
+		config         governor.SecretReader
+		mailBucket     objstore.Bucket
+		rcvMailDir     objstore.Dir
+		heldMailDir    objstore.Dir
+		events         events.Events
+		users          user.Users
+		orgs           org.Orgs
+		mailer         mail.Mailer
+		ratelimiter    ratelimit.Ratelimiter
+		gate           gate.Gate
+		log            *klog.LevelLogger
+		tracer         governor.Tracer
+		scopens        string
+		streamns       string
+		streammail     string
+		resolver       dns.Resolver
+		server         *smtp.Server
+		port           string
+		submission     submissionConfig
+		submissionSrv  *smtp.Server
+		submissionCert submissionCerts
+		authdomain     string
+		apiurl         string
+		keyrefresh     time.Duration
+		cmdduration    time.Duration
+		usrdomain      string
+		orgdomain      string
+		maxmsgsize     int64
+		readtimeout    time.Duration
+		writetimeout   time.Duration
+		streamsize     int64
+		eventsize      int32
+		digestinterval time.Duration
+		digestmaxmsgs  int
+		tplname        listTplName
+		wg             *ksync.WaitGroup
+	}
+
+	listTplName struct {
+		modqueue   string
+		modreject  string
+		cmdconfirm string
+		cmdhelp    string
 	}
 
 	router struct {
@@ -108,6 +133,7 @@ func New(lists mailinglistmodel.Repo, obj objstore.Bucket, ev events.Events, use
 		lists:       lists,
 		mailBucket:  obj,
 		rcvMailDir:  obj.Subdir("rcvmail"),
+		heldMailDir: obj.Subdir("heldmail"),
 		events:      ev,
 		users:       users,
 		orgs:        orgs,
@@ -127,15 +153,37 @@ func (s *Service) Register(r governor.ConfigRegistrar) {
 	s.streammail = r.Name()
 
 	r.SetDefault("port", "2525")
+	// submission clients authenticate with user apikeys, not user passwords
+	r.SetDefault("submission.enabled", false)
+	r.SetDefault("submission.port", "2587")
+	r.SetDefault("submission.tls", "submissiontls")
+	r.SetDefault("submission.authlimit", map[string]interface{}{
+		"period": 60,
+		"limit":  10,
+	})
+	r.SetDefault("submission.sendlimit", map[string]interface{}{
+		"period": 3600,
+		"limit":  60,
+	})
 	r.SetDefault("authdomain", "lists.mail.localhost")
 	r.SetDefault("usrdomain", "lists.mail.localhost")
 	r.SetDefault("orgdomain", "org.lists.mail.localhost")
+	r.SetDefault("apiurl", "http://localhost:8080/api/mailinglist")
+	r.SetDefault("unsubkey", "")
+	r.SetDefault("keyrefresh", "1m")
 	r.SetDefault("maxmsgsize", "2M")
 	r.SetDefault("readtimeout", "5s")
 	r.SetDefault("writetimeout", "5s")
 	r.SetDefault("mockdnssource", "")
 	r.SetDefault("streamsize", "200M")
 	r.SetDefault("eventsize", "16K")
+	r.SetDefault("digest.interval", "5m")
+	r.SetDefault("digest.maxmsgs", 256)
+	r.SetDefault("tpl.modqueue", "mlmodqueue")
+	r.SetDefault("tpl.modreject", "mlmodreject")
+	r.SetDefault("tpl.cmdconfirm", "mlcmdconfirm")
+	r.SetDefault("tpl.cmdhelp", "mlcmdhelp")
+	r.SetDefault("cmdduration", "24h")
 }
 
 func (s *Service) router() *router {
@@ -148,11 +196,27 @@ func (s *Service) router() *router {
 func (s *Service) Init(ctx context.Context, r governor.ConfigReader, kit governor.ServiceKit) error {
 	s.log = klog.NewLevelLogger(kit.Logger)
 	s.tracer = kit.Tracer
+	s.config = r
 
 	s.port = r.GetStr("port")
+	s.submission.enabled = r.GetBool("submission.enabled")
+	s.submission.port = r.GetStr("submission.port")
+	if err := r.Unmarshal("submission.authlimit", &s.submission.authlimit); err != nil {
+		return kerrors.WithKind(err, governor.ErrInvalidConfig, "Invalid submission auth ratelimit")
+	}
+	if s.submission.authlimit.Period <= 0 {
+		return kerrors.WithKind(nil, governor.ErrInvalidConfig, "Submission auth ratelimit period must be positive")
+	}
+	if err := r.Unmarshal("submission.sendlimit", &s.submission.sendlimit); err != nil {
+		return kerrors.WithKind(err, governor.ErrInvalidConfig, "Invalid submission send ratelimit")
+	}
+	if s.submission.sendlimit.Period <= 0 {
+		return kerrors.WithKind(nil, governor.ErrInvalidConfig, "Submission send ratelimit period must be positive")
+	}
 	s.authdomain = r.GetStr("authdomain")
 	s.usrdomain = r.GetStr("usrdomain")
 	s.orgdomain = r.GetStr("orgdomain")
+	s.apiurl = strings.TrimSuffix(r.GetStr("apiurl"), "/")
 	if limit, err := bytefmt.ToBytes(r.GetStr("maxmsgsize")); err != nil {
 		return kerrors.WithMsg(err, "Invalid mail max message size")
 	} else {
@@ -167,6 +231,14 @@ func (s *Service) Init(ctx context.Context, r governor.ConfigReader, kit governo
 	if err != nil {
 		return kerrors.WithKind(err, governor.ErrInvalidConfig, "Invalid write timeout for mail server")
 	}
+	s.keyrefresh, err = r.GetDuration("keyrefresh")
+	if err != nil {
+		return kerrors.WithKind(err, governor.ErrInvalidConfig, "Invalid key refresh")
+	}
+	s.cmdduration, err = r.GetDuration("cmdduration")
+	if err != nil {
+		return kerrors.WithKind(err, governor.ErrInvalidConfig, "Invalid list command duration")
+	}
 
 	if src := r.GetStr("mockdnssource"); src != "" {
 		var err error
@@ -189,16 +261,48 @@ func (s *Service) Init(ctx context.Context, r governor.ConfigReader, kit governo
 	}
 	s.eventsize = int32(eventsize)
 
+	s.digestinterval, err = r.GetDuration("digest.interval")
+	if err != nil {
+		return kerrors.WithKind(err, governor.ErrInvalidConfig, "Invalid digest interval")
+	}
+	if s.digestinterval <= 0 {
+		return kerrors.WithKind(nil, governor.ErrInvalidConfig, "Digest interval must be positive")
+	}
+	s.digestmaxmsgs = r.GetInt("digest.maxmsgs")
+	if s.digestmaxmsgs < 1 {
+		return kerrors.WithKind(nil, governor.ErrInvalidConfig, "Digest max msgs must be positive")
+	}
+
+	s.tplname = listTplName{
+		modqueue:   r.GetStr("tpl.modqueue"),
+		modreject:  r.GetStr("tpl.modreject"),
+		cmdconfirm: r.GetStr("tpl.cmdconfirm"),
+		cmdhelp:    r.GetStr("tpl.cmdhelp"),
+	}
+
 	s.log.Info(ctx, "Loaded config",
 		klog.AString("smtp.port", s.port),
+		klog.ABool("submission.enabled", s.submission.enabled),
+		klog.AString("submission.port", s.submission.port),
+		klog.AString("submission.authlimit", s.submission.authlimit.String()),
+		klog.AString("submission.sendlimit", s.submission.sendlimit.String()),
 		klog.AString("authdomain", s.authdomain),
 		klog.AString("usrdomain", s.usrdomain),
 		klog.AString("orgdomain", s.orgdomain),
+		klog.AString("apiurl", s.apiurl),
+		klog.AString("keyrefresh", s.keyrefresh.String()),
 		klog.AString("maxmsgsize", r.GetStr("maxmsgsize")),
 		klog.AString("readtimeout", s.readtimeout.String()),
 		klog.AString("writetimeout", s.writetimeout.String()),
 		klog.AString("streamsize", r.GetStr("streamsize")),
 		klog.AString("eventsize", r.GetStr("eventsize")),
+		klog.AString("digest.interval", s.digestinterval.String()),
+		klog.AInt("digest.maxmsgs", s.digestmaxmsgs),
+		klog.AString("tpl.modqueue", s.tplname.modqueue),
+		klog.AString("tpl.modreject", s.tplname.modreject),
+		klog.AString("tpl.cmdconfirm", s.tplname.cmdconfirm),
+		klog.AString("tpl.cmdhelp", s.tplname.cmdhelp),
+		klog.AString("cmdduration", s.cmdduration.String()),
 	)
 
 	sr := s.router()
@@ -214,34 +318,26 @@ func (s *Service) createSMTPServer() *smtp.Server {
 		log:      klog.NewLevelLogger(s.log.Logger.Sublogger("smtpserver")),
 		reqcount: &atomic.Uint32{},
 	}
-	server := smtp.NewServer(be)
-	server.Addr = ":" + s.port
-	server.Domain = s.authdomain
-	server.MaxRecipients = 1
-	server.MaxMessageBytes = s.maxmsgsize
-	server.ReadTimeout = s.readtimeout
-	server.WriteTimeout = s.writetimeout
-	server.AuthDisabled = true
-	return server
+	return mail.NewSMTPServer(be, mail.SMTPServerOpts{
+		Port:         s.port,
+		Domain:       s.authdomain,
+		MaxMsgSize:   s.maxmsgsize,
+		ReadTimeout:  s.readtimeout,
+		WriteTimeout: s.writetimeout,
+	})
 }
 
 func (s *Service) Start(ctx context.Context) error {
 	s.server = s.createSMTPServer()
 	s.wg.Add(1)
-	go func() {
-		defer s.wg.Done()
-		ctx := klog.CtxWithAttrs(ctx, klog.AString("gov.phase", "run"))
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			default:
-				if err := s.server.ListenAndServe(); err != nil {
-					s.log.Err(ctx, kerrors.WithMsg(err, "Shutting down mailinglist SMTP server"))
-				}
-			}
-		}
-	}()
+	go mail.ServeSMTP(klog.CtxWithAttrs(ctx, klog.AString("gov.phase", "run")), s.wg, s.log, s.server, "mailinglist")
+
+	if s.submission.enabled {
+		s.submissionSrv = s.createSubmissionServer()
+		s.wg.Add(1)
+		go mail.ServeSMTP(klog.CtxWithAttrs(ctx, klog.AString("gov.phase", "run")), s.wg, s.log, s.submissionSrv, "mailinglist submission")
+		s.log.Info(ctx, "Started mailinglist SMTP submission server")
+	}
 
 	s.wg.Add(1)
 	go events.NewWatcher(
@@ -265,6 +361,9 @@ func (s *Service) Start(ctx context.Context) error {
 	go s.orgs.WatchOrgs(s.streamns+".worker.orgs", events.ConsumerOpts{}, s.orgEventHandler, nil, 0).Watch(ctx, s.wg, events.WatchOpts{})
 	s.log.Info(ctx, "Subscribed to orgs stream")
 
+	s.wg.Add(1)
+	go s.digestLoop(klog.CtxWithAttrs(ctx, klog.AString("gov.phase", "run")), s.wg)
+
 	return nil
 }
 
@@ -274,6 +373,11 @@ func (s *Service) Stop(ctx context.Context) {
 			s.log.Err(ctx, kerrors.WithMsg(err, "Shutdown mailing list SMTP server error"))
 		}
 	}
+	if s.submissionSrv != nil {
+		if err := s.submissionSrv.Shutdown(ctx); err != nil {
+			s.log.Err(ctx, kerrors.WithMsg(err, "Shutdown mailing list SMTP submission server error"))
+		}
+	}
 	if err := s.wg.Wait(ctx); err != nil {
 		s.log.WarnErr(ctx, kerrors.WithMsg(err, "Failed to stop"))
 	}
@@ -336,6 +440,18 @@ func decodeListEvent(msgdata []byte) (*listEvent, error) {
 		if err := kjson.Unmarshal(m.Payload, &props.Delete); err != nil {
 			return nil, kerrors.WithKind(err, errListEvent{}, "Failed to decode delete event")
 		}
+	case listEventKindDigest:
+		if err := kjson.Unmarshal(m.Payload, &props.Digest); err != nil {
+			return nil, kerrors.WithKind(err, errListEvent{}, "Failed to decode digest event")
+		}
+	case listEventKindHeld:
+		if err := kjson.Unmarshal(m.Payload, &props.Held); err != nil {
+			return nil, kerrors.WithKind(err, errListEvent{}, "Failed to decode held event")
+		}
+	case listEventKindIndex:
+		if err := kjson.Unmarshal(m.Payload, &props.Index); err != nil {
+			return nil, kerrors.WithKind(err, errListEvent{}, "Failed to decode index event")
+		}
 	default:
 		return nil, kerrors.WithKind(nil, errListEvent{}, "Invalid list event kind")
 	}
@@ -375,6 +491,39 @@ func encodeListEventDelete(props delProps) ([]byte, error) {
 	return b, nil
 }
 
+func encodeListEventDigest(props digestProps) ([]byte, error) {
+	b, err := kjson.Marshal(listEventEnc{
+		Kind:    listEventKindDigest,
+		Payload: props,
+	})
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to encode digest props to json")
+	}
+	return b, nil
+}
+
+func encodeListEventHeld(props heldProps) ([]byte, error) {
+	b, err := kjson.Marshal(listEventEnc{
+		Kind:    listEventKindHeld,
+		Payload: props,
+	})
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to encode held props to json")
+	}
+	return b, nil
+}
+
+func encodeListEventIndex(props indexProps) ([]byte, error) {
+	b, err := kjson.Marshal(listEventEnc{
+		Kind:    listEventKindIndex,
+		Payload: props,
+	})
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to encode index props to json")
+	}
+	return b, nil
+}
+
 func (s *Service) listEventHandler(ctx context.Context, msg events.Msg) error {
 	props, err := decodeListEvent(msg.Value)
 	if err != nil {
@@ -387,6 +536,12 @@ func (s *Service) listEventHandler(ctx context.Context, msg events.Msg) error {
 		return s.sendEventHandler(ctx, props.Send)
 	case listEventKindDelete:
 		return s.deleteEventHandler(ctx, props.Delete)
+	case listEventKindDigest:
+		return s.digestEventHandler(ctx, props.Digest)
+	case listEventKindHeld:
+		return s.heldEventHandler(ctx, props.Held)
+	case listEventKindIndex:
+		return s.indexEventHandler(ctx, props.Index)
 	default:
 		return nil
 	}
@@ -0,0 +1,604 @@
+package mailinglist
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"xorkevin.dev/governor/service/dbsql"
+	"xorkevin.dev/governor/service/events"
+	"xorkevin.dev/governor/service/mail"
+	"xorkevin.dev/governor/service/mailinglist/mailinglistmodel"
+	"xorkevin.dev/governor/service/objstore"
+	"xorkevin.dev/governor/service/user"
+	"xorkevin.dev/governor/service/user/org"
+	"xorkevin.dev/kerrors"
+	"xorkevin.dev/klog"
+)
+
+type (
+	// testLists is an in memory list repo implementing the methods used by the
+	// service paths under test
+	testLists struct {
+		mailinglistmodel.Repo
+		lists    map[string]*mailinglistmodel.ListModel
+		msgs     map[string]*mailinglistmodel.MsgModel
+		pending  map[string]*mailinglistmodel.PendingModel
+		approved map[string]struct{}
+		members  map[string]*mailinglistmodel.MemberModel
+		cmds     map[string]*mailinglistmodel.CmdModel
+		cmdKeys  int
+		sent     map[string][]string
+	}
+
+	testObj struct {
+		contentType string
+		data        []byte
+	}
+
+	// testDir is an in memory object store dir
+	testDir struct {
+		objstore.Dir
+		prefix string
+		objs   map[string]testObj
+	}
+
+	testEvents struct {
+		events.Events
+		msgs []events.PublishMsg
+	}
+
+	testUsers struct {
+		user.Users
+		users map[string]*user.ResUserGet
+		roles map[string][]string
+	}
+
+	testOrgs struct {
+		org.Orgs
+		orgs map[string]*org.ResOrg
+	}
+
+	testSentMail struct {
+		to     []mail.Addr
+		tpl    mail.Tpl
+		emdata interface{}
+	}
+
+	testMailer struct {
+		mail.Mailer
+		sent []testSentMail
+		fwd  []testFwdMail
+		fail map[string]error
+	}
+
+	testFwdMail struct {
+		to   []mail.Addr
+		seal string
+		body []byte
+	}
+
+	testSecrets struct {
+		unsubkeys []string
+	}
+)
+
+func testKey(a ...string) string {
+	return strings.Join(a, "\x00")
+}
+
+func newTestLists() *testLists {
+	return &testLists{
+		lists:    map[string]*mailinglistmodel.ListModel{},
+		msgs:     map[string]*mailinglistmodel.MsgModel{},
+		pending:  map[string]*mailinglistmodel.PendingModel{},
+		approved: map[string]struct{}{},
+		members:  map[string]*mailinglistmodel.MemberModel{},
+		cmds:     map[string]*mailinglistmodel.CmdModel{},
+		sent:     map[string][]string{},
+	}
+}
+
+func (r *testLists) GetList(ctx context.Context, creatorid, listname string) (*mailinglistmodel.ListModel, error) {
+	return r.GetListByID(ctx, creatorid+"."+listname)
+}
+
+func (r *testLists) GetListByID(ctx context.Context, listid string) (*mailinglistmodel.ListModel, error) {
+	m, ok := r.lists[listid]
+	if !ok {
+		return nil, kerrors.WithKind(nil, dbsql.ErrNotFound, "List not found")
+	}
+	return m, nil
+}
+
+func (r *testLists) NewMsg(listid, msgid, userid string) *mailinglistmodel.MsgModel {
+	return &mailinglistmodel.MsgModel{
+		ListID:       listid,
+		Msgid:        msgid,
+		Userid:       userid,
+		CreationTime: time.Now().Round(0).UnixMilli(),
+	}
+}
+
+func (r *testLists) InsertMsg(ctx context.Context, m *mailinglistmodel.MsgModel) error {
+	k := testKey(m.ListID, m.Msgid)
+	if _, ok := r.msgs[k]; ok {
+		return kerrors.WithKind(nil, dbsql.ErrUnique, "Msg already exists")
+	}
+	r.msgs[k] = m
+	return nil
+}
+
+func (r *testLists) GetMsg(ctx context.Context, listid, msgid string) (*mailinglistmodel.MsgModel, error) {
+	m, ok := r.msgs[testKey(listid, msgid)]
+	if !ok {
+		return nil, kerrors.WithKind(nil, dbsql.ErrNotFound, "Msg not found")
+	}
+	return m, nil
+}
+
+func (r *testLists) UpdateMsgThread(ctx context.Context, listid, parentid, threadid string) error {
+	return nil
+}
+
+func (r *testLists) UpdateMsgChildren(ctx context.Context, listid, parentid, threadid string) error {
+	return nil
+}
+
+func (r *testLists) NewTree(listid, msgid string, t int64) *mailinglistmodel.TreeModel {
+	return &mailinglistmodel.TreeModel{
+		ListID:       listid,
+		Msgid:        msgid,
+		ParentID:     msgid,
+		Depth:        0,
+		CreationTime: t,
+	}
+}
+
+func (r *testLists) InsertTree(ctx context.Context, m *mailinglistmodel.TreeModel) error {
+	return nil
+}
+
+func (r *testLists) InsertTreeChildren(ctx context.Context, listid, msgid string) error {
+	return nil
+}
+
+func (r *testLists) UpdateListLastUpdated(ctx context.Context, listid string, t int64) error {
+	m, ok := r.lists[listid]
+	if !ok {
+		return kerrors.WithKind(nil, dbsql.ErrNotFound, "List not found")
+	}
+	m.LastUpdated = t
+	return nil
+}
+
+func (r *testLists) MarkMsgProcessed(ctx context.Context, listid, msgid string) error {
+	m, ok := r.msgs[testKey(listid, msgid)]
+	if !ok {
+		return kerrors.WithKind(nil, dbsql.ErrNotFound, "Msg not found")
+	}
+	m.Processed = true
+	return nil
+}
+
+func (r *testLists) LogSentMsg(ctx context.Context, listid, msgid string, userids []string) error {
+	k := testKey(listid, msgid)
+	r.sent[k] = append(r.sent[k], userids...)
+	return nil
+}
+
+func (r *testLists) NewPending(listid, msgid, userid string, reason string) *mailinglistmodel.PendingModel {
+	return &mailinglistmodel.PendingModel{
+		ListID:       listid,
+		Msgid:        msgid,
+		Userid:       userid,
+		Reason:       reason,
+		CreationTime: time.Now().Round(0).UnixMilli(),
+	}
+}
+
+func (r *testLists) GetPending(ctx context.Context, listid, msgid string) (*mailinglistmodel.PendingModel, error) {
+	m, ok := r.pending[testKey(listid, msgid)]
+	if !ok {
+		return nil, kerrors.WithKind(nil, dbsql.ErrNotFound, "Pending msg not found")
+	}
+	return m, nil
+}
+
+func (r *testLists) InsertPending(ctx context.Context, m *mailinglistmodel.PendingModel) error {
+	k := testKey(m.ListID, m.Msgid)
+	if _, ok := r.pending[k]; ok {
+		return kerrors.WithKind(nil, dbsql.ErrUnique, "Pending msg already exists")
+	}
+	r.pending[k] = m
+	return nil
+}
+
+func (r *testLists) DeletePending(ctx context.Context, listid, msgid string) error {
+	delete(r.pending, testKey(listid, msgid))
+	return nil
+}
+
+func (r *testLists) IsApprovedSender(ctx context.Context, listid, userid string) (bool, error) {
+	_, ok := r.approved[testKey(listid, userid)]
+	return ok, nil
+}
+
+func (r *testLists) InsertApprovedSender(ctx context.Context, listid, userid string) error {
+	r.approved[testKey(listid, userid)] = struct{}{}
+	return nil
+}
+
+func (r *testLists) GetMember(ctx context.Context, listid, userid string) (*mailinglistmodel.MemberModel, error) {
+	m, ok := r.members[testKey(listid, userid)]
+	if !ok {
+		return nil, kerrors.WithKind(nil, dbsql.ErrNotFound, "Member not found")
+	}
+	return m, nil
+}
+
+func (r *testLists) AddMembers(m *mailinglistmodel.ListModel, userids []string) []*mailinglistmodel.MemberModel {
+	members := make([]*mailinglistmodel.MemberModel, 0, len(userids))
+	for _, i := range userids {
+		members = append(members, &mailinglistmodel.MemberModel{
+			ListID:      m.ListID,
+			Userid:      i,
+			Delivery:    mailinglistmodel.MemberDeliveryImmediate,
+			LastUpdated: m.LastUpdated,
+		})
+	}
+	return members
+}
+
+func (r *testLists) InsertMembers(ctx context.Context, m []*mailinglistmodel.MemberModel) error {
+	for _, i := range m {
+		r.members[testKey(i.ListID, i.Userid)] = i
+	}
+	return nil
+}
+
+func (r *testLists) DeleteMembers(ctx context.Context, listid string, userids []string) error {
+	for _, i := range userids {
+		delete(r.members, testKey(listid, i))
+	}
+	return nil
+}
+
+func (r *testLists) UpdateMemberDelivery(ctx context.Context, listid, userid string, delivery string) error {
+	m, ok := r.members[testKey(listid, userid)]
+	if !ok {
+		return kerrors.WithKind(nil, dbsql.ErrNotFound, "Member not found")
+	}
+	m.Delivery = delivery
+	return nil
+}
+
+func (r *testLists) NewCmd(listid, userid string, cmd, arg string) (*mailinglistmodel.CmdModel, string, error) {
+	r.cmdKeys++
+	key := fmt.Sprintf("cmdkey%d", r.cmdKeys)
+	return &mailinglistmodel.CmdModel{
+		ListID:       listid,
+		Userid:       userid,
+		Cmd:          cmd,
+		Arg:          arg,
+		KeyHash:      "hash:" + key,
+		CreationTime: time.Now().Round(0).Unix(),
+	}, key, nil
+}
+
+func (r *testLists) ValidateCmdKey(key string, m *mailinglistmodel.CmdModel) (bool, error) {
+	return m.KeyHash == "hash:"+key, nil
+}
+
+func (r *testLists) GetCmd(ctx context.Context, listid, userid string) (*mailinglistmodel.CmdModel, error) {
+	m, ok := r.cmds[testKey(listid, userid)]
+	if !ok {
+		return nil, kerrors.WithKind(nil, dbsql.ErrNotFound, "Cmd not found")
+	}
+	return m, nil
+}
+
+func (r *testLists) InsertCmd(ctx context.Context, m *mailinglistmodel.CmdModel) error {
+	k := testKey(m.ListID, m.Userid)
+	if _, ok := r.cmds[k]; ok {
+		return kerrors.WithKind(nil, dbsql.ErrUnique, "Cmd already exists")
+	}
+	r.cmds[k] = m
+	return nil
+}
+
+func (r *testLists) DeleteCmd(ctx context.Context, listid, userid string) error {
+	delete(r.cmds, testKey(listid, userid))
+	return nil
+}
+
+func newTestDir() *testDir {
+	return &testDir{
+		objs: map[string]testObj{},
+	}
+}
+
+func (d *testDir) Subdir(name string) objstore.Dir {
+	return &testDir{
+		prefix: d.prefix + name + "/",
+		objs:   d.objs,
+	}
+}
+
+func (d *testDir) Get(ctx context.Context, name string) (io.ReadCloser, *objstore.ObjectInfo, error) {
+	obj, ok := d.objs[d.prefix+name]
+	if !ok {
+		return nil, nil, kerrors.WithKind(nil, objstore.ErrNotFound, "Object not found")
+	}
+	return io.NopCloser(bytes.NewReader(obj.data)), &objstore.ObjectInfo{
+		Name:        name,
+		Size:        int64(len(obj.data)),
+		ContentType: obj.contentType,
+	}, nil
+}
+
+func (d *testDir) Put(ctx context.Context, name string, contentType string, size int64, userMeta map[string]string, object io.Reader) error {
+	b, err := io.ReadAll(object)
+	if err != nil {
+		return err
+	}
+	d.objs[d.prefix+name] = testObj{
+		contentType: contentType,
+		data:        b,
+	}
+	return nil
+}
+
+func (d *testDir) Del(ctx context.Context, name string) error {
+	if _, ok := d.objs[d.prefix+name]; !ok {
+		return kerrors.WithKind(nil, objstore.ErrNotFound, "Object not found")
+	}
+	delete(d.objs, d.prefix+name)
+	return nil
+}
+
+// names returns the sorted names of all objects in the dir tree
+func (d *testDir) names() []string {
+	res := make([]string, 0, len(d.objs))
+	for k := range d.objs {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func (e *testEvents) Publish(ctx context.Context, msgs ...events.PublishMsg) error {
+	e.msgs = append(e.msgs, msgs...)
+	return nil
+}
+
+func (u *testUsers) GetByID(ctx context.Context, userid string) (*user.ResUserGet, error) {
+	m, ok := u.users[userid]
+	if !ok {
+		return nil, kerrors.WithKind(nil, user.ErrNotFound, "User not found")
+	}
+	return m, nil
+}
+
+func (u *testUsers) GetByUsername(ctx context.Context, username string) (*user.ResUserGet, error) {
+	for _, i := range u.users {
+		if i.Username == username {
+			return i, nil
+		}
+	}
+	return nil, kerrors.WithKind(nil, user.ErrNotFound, "User not found")
+}
+
+func (u *testUsers) GetByEmail(ctx context.Context, email string) (*user.ResUserGet, error) {
+	for _, i := range u.users {
+		if i.Email == email {
+			return i, nil
+		}
+	}
+	return nil, kerrors.WithKind(nil, user.ErrNotFound, "User not found")
+}
+
+func (u *testUsers) GetInfoBulk(ctx context.Context, userids []string) (*user.ResUserInfoList, error) {
+	res := make([]user.ResUserInfo, 0, len(userids))
+	for _, i := range userids {
+		m, ok := u.users[i]
+		if !ok {
+			continue
+		}
+		res = append(res, user.ResUserInfo{
+			Userid:    m.Userid,
+			Username:  m.Username,
+			Email:     m.Email,
+			FirstName: m.FirstName,
+			LastName:  m.LastName,
+		})
+	}
+	return &user.ResUserInfoList{
+		Users: res,
+	}, nil
+}
+
+func (u *testUsers) GetRoleUsers(ctx context.Context, roleName string, amount, offset int) ([]string, error) {
+	m := u.roles[roleName]
+	if offset >= len(m) {
+		return nil, nil
+	}
+	m = m[offset:]
+	if len(m) > amount {
+		m = m[:amount]
+	}
+	return m, nil
+}
+
+func (o *testOrgs) GetByID(ctx context.Context, orgid string) (*org.ResOrg, error) {
+	m, ok := o.orgs[orgid]
+	if !ok {
+		return nil, kerrors.WithMsg(nil, "Org not found")
+	}
+	return m, nil
+}
+
+func (m *testMailer) FilterSuppressed(ctx context.Context, to []mail.Addr) ([]mail.Addr, error) {
+	return to, nil
+}
+
+func (m *testMailer) SendTpl(ctx context.Context, retpath string, from mail.Addr, to []mail.Addr, tpl mail.Tpl, emdata interface{}, encrypt bool) error {
+	for _, i := range to {
+		if err, ok := m.fail[i.Address]; ok {
+			return err
+		}
+	}
+	m.sent = append(m.sent, testSentMail{
+		to:     to,
+		tpl:    tpl,
+		emdata: emdata,
+	})
+	return nil
+}
+
+func (m *testMailer) FwdStream(ctx context.Context, retpath string, to []mail.Addr, size int64, body io.Reader, encrypt bool) error {
+	return m.FwdStreamSeal(ctx, retpath, to, "", size, body, encrypt)
+}
+
+func (m *testMailer) FwdStreamSeal(ctx context.Context, retpath string, to []mail.Addr, authservid string, size int64, body io.Reader, encrypt bool) error {
+	for _, i := range to {
+		if err, ok := m.fail[i.Address]; ok {
+			return err
+		}
+	}
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	m.fwd = append(m.fwd, testFwdMail{
+		to:   to,
+		seal: authservid,
+		body: b,
+	})
+	return nil
+}
+
+// fwdTo returns the addresses of all forwarded mail in order
+func (m *testMailer) fwdTo() []string {
+	var res []string
+	for _, i := range m.fwd {
+		for _, j := range i.to {
+			res = append(res, j.Address)
+		}
+	}
+	return res
+}
+
+// sentTo returns the addresses of all sent mail in order
+func (m *testMailer) sentTo() []string {
+	var res []string
+	for _, i := range m.sent {
+		for _, j := range i.to {
+			res = append(res, j.Address)
+		}
+	}
+	return res
+}
+
+func (c *testSecrets) GetSecret(ctx context.Context, key string, cacheDuration time.Duration, target interface{}) error {
+	if key != "unsubkey" {
+		return kerrors.WithMsg(nil, "Secret not found")
+	}
+	t, ok := target.(*secretUnsub)
+	if !ok {
+		return kerrors.WithMsg(nil, "Invalid secret target")
+	}
+	t.Keys = c.unsubkeys
+	return nil
+}
+
+func (c *testSecrets) InvalidateSecret(key string) {}
+
+type (
+	testService struct {
+		*Service
+		lists  *testLists
+		dir    *testDir
+		events *testEvents
+		users  *testUsers
+		orgs   *testOrgs
+		mailer *testMailer
+	}
+)
+
+func newTestService() *testService {
+	lists := newTestLists()
+	dir := newTestDir()
+	ev := &testEvents{}
+	users := &testUsers{
+		users: map[string]*user.ResUserGet{},
+		roles: map[string][]string{},
+	}
+	orgs := &testOrgs{
+		orgs: map[string]*org.ResOrg{},
+	}
+	mailer := &testMailer{
+		fail: map[string]error{},
+	}
+	return &testService{
+		Service: &Service{
+			lists: lists,
+			config: &testSecrets{
+				unsubkeys: []string{"unsubkey"},
+			},
+			rcvMailDir:  dir.Subdir("rcvmail"),
+			heldMailDir: dir.Subdir("heldmail"),
+			events:      ev,
+			users:       users,
+			orgs:        orgs,
+			mailer:      mailer,
+			log:         klog.NewLevelLogger(klog.Discard{}),
+			streammail:  "mailinglist",
+			authdomain:  "lists.example.com",
+			apiurl:      "https://example.com/api/mailinglist",
+			usrdomain:   "lists.example.com",
+			orgdomain:   "org.lists.example.com",
+			cmdduration: 24 * time.Hour,
+			tplname: listTplName{
+				modqueue:   "mlmodqueue",
+				modreject:  "mlmodreject",
+				cmdconfirm: "mlcmdconfirm",
+				cmdhelp:    "mlcmdhelp",
+			},
+		},
+		lists:  lists,
+		dir:    dir,
+		events: ev,
+		users:  users,
+		orgs:   orgs,
+		mailer: mailer,
+	}
+}
+
+func (s *testService) addUser(userid, username string) {
+	s.users.users[userid] = &user.ResUserGet{
+		ResUserGetPublic: user.ResUserGetPublic{
+			Userid:    userid,
+			Username:  username,
+			FirstName: username,
+		},
+		Email: username + "@example.com",
+	}
+}
+
+func (s *testService) addList(creatorid, listname string) *mailinglistmodel.ListModel {
+	m := &mailinglistmodel.ListModel{
+		ListID:       creatorid + "." + listname,
+		CreatorID:    creatorid,
+		Listname:     listname,
+		Name:         listname,
+		SenderPolicy: listSenderPolicyMember,
+		MemberPolicy: listMemberPolicyOwner,
+	}
+	s.lists.lists[m.ListID] = m
+	return m
+}
@@ -2,11 +2,15 @@ package mailinglistmodel
 
 import (
 	"context"
+	"encoding/hex"
 	"errors"
 	"time"
 
 	"xorkevin.dev/forge/model/sqldb"
 	"xorkevin.dev/governor/service/dbsql"
+	"xorkevin.dev/governor/util/uid"
+	"xorkevin.dev/hunter2/h2hash"
+	"xorkevin.dev/hunter2/h2hash/blake2b"
 	"xorkevin.dev/kerrors"
 )
 
@@ -14,11 +18,19 @@ import (
 
 const (
 	keySeparator = "."
+	cmdKeySize   = 16
+)
+
+// Member delivery modes
+const (
+	MemberDeliveryImmediate = "immediate"
+	MemberDeliveryDaily     = "daily"
+	MemberDeliveryWeekly    = "weekly"
 )
 
 type (
 	Repo interface {
-		NewList(creatorid, listname string, name, desc string, senderPolicy, memberPolicy string) *ListModel
+		NewList(creatorid, listname string, name, desc string, senderPolicy, memberPolicy string, modFirstPost bool) *ListModel
 		GetList(ctx context.Context, creatorid, listname string) (*ListModel, error)
 		GetListByID(ctx context.Context, listid string) (*ListModel, error)
 		GetLists(ctx context.Context, listids []string) ([]ListModel, error)
@@ -37,11 +49,14 @@ type (
 		DeleteMembers(ctx context.Context, listid string, userids []string) error
 		DeleteListMembers(ctx context.Context, listid string) error
 		DeleteUserMembers(ctx context.Context, userid string) error
+		UpdateMemberDelivery(ctx context.Context, listid, userid string, delivery string) error
+		GetDigestLists(ctx context.Context, delivery string, limit, offset int) ([]string, error)
 		NewMsg(listid, msgid, userid string) *MsgModel
 		GetMsg(ctx context.Context, listid, msgid string) (*MsgModel, error)
 		GetListMsgs(ctx context.Context, listid string, limit, offset int) ([]MsgModel, error)
 		GetListThreads(ctx context.Context, listid string, limit, offset int) ([]MsgModel, error)
 		GetListThread(ctx context.Context, listid, threadid string, limit, offset int) ([]MsgModel, error)
+		GetListMsgsRange(ctx context.Context, listid string, after, before int64, limit, offset int) ([]MsgModel, error)
 		InsertMsg(ctx context.Context, m *MsgModel) error
 		UpdateMsgParent(ctx context.Context, listid, msgid string, parentid, threadid string) error
 		UpdateMsgChildren(ctx context.Context, listid, parentid, threadid string) error
@@ -49,7 +64,7 @@ type (
 		MarkMsgProcessed(ctx context.Context, listid, msgid string) error
 		MarkMsgSent(ctx context.Context, listid, msgid string) error
 		DeleteMsgs(ctx context.Context, listid string, msgids []string) error
-		GetUnsentMsgs(ctx context.Context, listid, msgid string, limit int) ([]string, error)
+		GetUnsentMsgs(ctx context.Context, listid, msgid string, delivery string, limit int) ([]string, error)
 		LogSentMsg(ctx context.Context, listid, msgid string, userids []string) error
 		DeleteSentMsgLogs(ctx context.Context, listid string, msgid []string) error
 		NewTree(listid, msgid string, t int64) *TreeModel
@@ -60,6 +75,33 @@ type (
 		InsertTreeEdge(ctx context.Context, listid, msgid, parentid string) error
 		InsertTreeChildren(ctx context.Context, listid, msgid string) error
 		DeleteListTrees(ctx context.Context, listid string) error
+		NewDigest(listid, kind string, periodStart, periodEnd int64) *DigestModel
+		GetDigest(ctx context.Context, listid, kind string, periodEnd int64) (*DigestModel, error)
+		InsertDigest(ctx context.Context, m *DigestModel) error
+		MarkDigestSent(ctx context.Context, listid, kind string, periodEnd int64) error
+		DeleteListDigests(ctx context.Context, listid string) error
+		NewPending(listid, msgid, userid string, reason string) *PendingModel
+		GetPending(ctx context.Context, listid, msgid string) (*PendingModel, error)
+		GetListPending(ctx context.Context, listid string, limit, offset int) ([]PendingModel, error)
+		InsertPending(ctx context.Context, m *PendingModel) error
+		DeletePending(ctx context.Context, listid, msgid string) error
+		DeleteListPending(ctx context.Context, listid string) error
+		IsApprovedSender(ctx context.Context, listid, userid string) (bool, error)
+		InsertApprovedSender(ctx context.Context, listid, userid string) error
+		DeleteApprovedSender(ctx context.Context, listid, userid string) error
+		DeleteListApprovedSenders(ctx context.Context, listid string) error
+		NewCmd(listid, userid string, cmd, arg string) (*CmdModel, string, error)
+		ValidateCmdKey(key string, m *CmdModel) (bool, error)
+		GetCmd(ctx context.Context, listid, userid string) (*CmdModel, error)
+		InsertCmd(ctx context.Context, m *CmdModel) error
+		DeleteCmd(ctx context.Context, listid, userid string) error
+		DeleteListCmds(ctx context.Context, listid string) error
+		NewSearch(listid, msgid, userid string, creationTime int64, from, subject, body string) *SearchModel
+		InsertSearch(ctx context.Context, m *SearchModel) error
+		SearchListMsgs(ctx context.Context, listid string, query string, limit, offset int) ([]SearchResult, error)
+		SearchMemberMsgs(ctx context.Context, userid string, query string, limit, offset int) ([]SearchResult, error)
+		DeleteSearchMsgs(ctx context.Context, listid string, msgids []string) error
+		DeleteListSearch(ctx context.Context, listid string) error
 		Setup(ctx context.Context) error
 	}
 
@@ -69,7 +111,14 @@ type (
 		tableMsgs    *msgModelTable
 		tableSent    *sentmsgModelTable
 		tableTree    *treeModelTable
+		tableDigests *digestModelTable
+		tablePending *pendingModelTable
+		tableSenders *senderModelTable
+		tableCmds    *cmdModelTable
+		tableSearch  *searchModelTable
 		db           dbsql.Database
+		hasher       h2hash.Hasher
+		verifier     *h2hash.Verifier
 	}
 
 	// ListModel is the db mailing list model
@@ -84,6 +133,7 @@ type (
 		Archive      bool   `model:"archive,BOOLEAN NOT NULL"`
 		SenderPolicy string `model:"sender_policy,VARCHAR(255) NOT NULL"`
 		MemberPolicy string `model:"member_policy,VARCHAR(255) NOT NULL"`
+		ModFirstPost bool   `model:"mod_first_post,BOOLEAN NOT NULL"`
 		LastUpdated  int64  `model:"last_updated,BIGINT NOT NULL"`
 		CreationTime int64  `model:"creation_time,BIGINT NOT NULL"`
 	}
@@ -95,6 +145,7 @@ type (
 		Archive      bool   `model:"archive"`
 		SenderPolicy string `model:"sender_policy"`
 		MemberPolicy string `model:"member_policy"`
+		ModFirstPost bool   `model:"mod_first_post"`
 	}
 
 	// MemberModel is the db mailing list member model
@@ -103,9 +154,15 @@ type (
 	MemberModel struct {
 		ListID      string `model:"listid,VARCHAR(255)"`
 		Userid      string `model:"userid,VARCHAR(31)"`
+		Delivery    string `model:"delivery,VARCHAR(31) NOT NULL"`
 		LastUpdated int64  `model:"last_updated,BIGINT NOT NULL"`
 	}
 
+	//forge:model:query member
+	memberDelivery struct {
+		Delivery string `model:"delivery"`
+	}
+
 	//forge:model:query list
 	//forge:model:query member
 	listLastUpdated struct {
@@ -182,10 +239,91 @@ type (
 		Depth        int    `model:"depth,INT NOT NULL"`
 		CreationTime int64  `model:"creation_time,BIGINT NOT NULL"`
 	}
+
+	// DigestModel is the db mailing list digest model
+	//forge:model digest
+	//forge:model:query digest
+	DigestModel struct {
+		ListID       string `model:"listid,VARCHAR(255)"`
+		Kind         string `model:"kind,VARCHAR(31)"`
+		PeriodEnd    int64  `model:"period_end,BIGINT"`
+		PeriodStart  int64  `model:"period_start,BIGINT NOT NULL"`
+		Sent         bool   `model:"sent,BOOL NOT NULL"`
+		CreationTime int64  `model:"creation_time,BIGINT NOT NULL"`
+	}
+
+	//forge:model:query digest
+	digestSent struct {
+		Sent bool `model:"sent"`
+	}
+
+	// PendingModel is the db mailing list message held for moderation
+	//forge:model pending
+	//forge:model:query pending
+	PendingModel struct {
+		ListID       string `model:"listid,VARCHAR(255)"`
+		Msgid        string `model:"msgid,VARCHAR(1023)"`
+		Userid       string `model:"userid,VARCHAR(31) NOT NULL"`
+		SPFPass      string `model:"spf_pass,VARCHAR(255) NOT NULL"`
+		DKIMPass     string `model:"dkim_pass,VARCHAR(255) NOT NULL"`
+		Subject      string `model:"subject,VARCHAR(255) NOT NULL"`
+		InReplyTo    string `model:"in_reply_to,VARCHAR(1023) NOT NULL"`
+		Reason       string `model:"reason,VARCHAR(31) NOT NULL"`
+		CreationTime int64  `model:"creation_time,BIGINT NOT NULL"`
+	}
+
+	// SenderModel is the db mailing list approved sender model
+	//forge:model sender
+	//forge:model:query sender
+	SenderModel struct {
+		ListID       string `model:"listid,VARCHAR(255)"`
+		Userid       string `model:"userid,VARCHAR(31)"`
+		CreationTime int64  `model:"creation_time,BIGINT NOT NULL"`
+	}
+
+	// CmdModel is the db mailing list email command pending confirmation
+	//forge:model cmd
+	//forge:model:query cmd
+	CmdModel struct {
+		ListID       string `model:"listid,VARCHAR(255)"`
+		Userid       string `model:"userid,VARCHAR(31)"`
+		Cmd          string `model:"cmd,VARCHAR(31) NOT NULL"`
+		Arg          string `model:"arg,VARCHAR(255) NOT NULL"`
+		KeyHash      string `model:"keyhash,VARCHAR(255) NOT NULL"`
+		CreationTime int64  `model:"creation_time,BIGINT NOT NULL"`
+	}
+
+	// SearchModel is the db mailing list message full text search model
+	//forge:model search
+	//forge:model:query search
+	SearchModel struct {
+		ListID       string `model:"listid,VARCHAR(255)"`
+		Msgid        string `model:"msgid,VARCHAR(1023)"`
+		Userid       string `model:"userid,VARCHAR(31) NOT NULL"`
+		CreationTime int64  `model:"creation_time,BIGINT NOT NULL"`
+		FromAddr     string `model:"from_addr,VARCHAR(511) NOT NULL"`
+		Subject      string `model:"subject,VARCHAR(255) NOT NULL"`
+		Body         string `model:"body,TEXT NOT NULL"`
+	}
+
+	// SearchResult is a mailing list message full text search result
+	SearchResult struct {
+		ListID       string
+		Msgid        string
+		Userid       string
+		CreationTime int64
+		FromAddr     string
+		Subject      string
+		Snippet      string
+	}
 )
 
 // New creates a new user repository
-func New(database dbsql.Database, tableLists, tableMembers, tableMsgs, tableSent, tableTree string) Repo {
+func New(database dbsql.Database, tableLists, tableMembers, tableMsgs, tableSent, tableTree, tableDigests, tablePending, tableSenders, tableCmds, tableSearch string) Repo {
+	hasher := blake2b.New(blake2b.Config{})
+	verifier := h2hash.NewVerifier()
+	verifier.Register(hasher)
+
 	return &repo{
 		tableLists: &listModelTable{
 			TableName: tableLists,
@@ -202,7 +340,24 @@ func New(database dbsql.Database, tableLists, tableMembers, tableMsgs, tableSent
 		tableTree: &treeModelTable{
 			TableName: tableTree,
 		},
-		db: database,
+		tableDigests: &digestModelTable{
+			TableName: tableDigests,
+		},
+		tablePending: &pendingModelTable{
+			TableName: tablePending,
+		},
+		tableSenders: &senderModelTable{
+			TableName: tableSenders,
+		},
+		tableCmds: &cmdModelTable{
+			TableName: tableCmds,
+		},
+		tableSearch: &searchModelTable{
+			TableName: tableSearch,
+		},
+		db:       database,
+		hasher:   hasher,
+		verifier: verifier,
 	}
 }
 
@@ -210,7 +365,7 @@ func toListID(creatorid, listname string) string {
 	return creatorid + keySeparator + listname
 }
 
-func (r *repo) NewList(creatorid, listname string, name, desc string, senderPolicy, memberPolicy string) *ListModel {
+func (r *repo) NewList(creatorid, listname string, name, desc string, senderPolicy, memberPolicy string, modFirstPost bool) *ListModel {
 	now := time.Now().Round(0)
 	return &ListModel{
 		ListID:       toListID(creatorid, listname),
@@ -220,6 +375,7 @@ func (r *repo) NewList(creatorid, listname string, name, desc string, senderPoli
 		Description:  desc,
 		SenderPolicy: senderPolicy,
 		MemberPolicy: memberPolicy,
+		ModFirstPost: modFirstPost,
 		LastUpdated:  now.UnixMilli(),
 		CreationTime: now.Unix(),
 	}
@@ -291,6 +447,7 @@ func (r *repo) UpdateList(ctx context.Context, m *ListModel) error {
 		Archive:      m.Archive,
 		SenderPolicy: m.SenderPolicy,
 		MemberPolicy: m.MemberPolicy,
+		ModFirstPost: m.ModFirstPost,
 	}, m.ListID); err != nil {
 		return kerrors.WithMsg(err, "Failed to update list")
 	}
@@ -399,6 +556,7 @@ func (r *repo) AddMembers(m *ListModel, userids []string) []*MemberModel {
 		members = append(members, &MemberModel{
 			ListID:      m.ListID,
 			Userid:      i,
+			Delivery:    MemberDeliveryImmediate,
 			LastUpdated: m.LastUpdated,
 		})
 	}
@@ -453,6 +611,47 @@ func (r *repo) DeleteUserMembers(ctx context.Context, userid string) error {
 	return nil
 }
 
+func (r *repo) UpdateMemberDelivery(ctx context.Context, listid, userid string, delivery string) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tableMembers.UpdmemberDeliveryByListUser(ctx, d, &memberDelivery{
+		Delivery: delivery,
+	}, listid, userid); err != nil {
+		return kerrors.WithMsg(err, "Failed to update list member delivery")
+	}
+	return nil
+}
+
+func (r *repo) GetDigestLists(ctx context.Context, delivery string, limit, offset int) (_ []string, retErr error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]string, 0, limit)
+	rows, err := d.QueryContext(ctx, "SELECT DISTINCT listid FROM "+r.tableMembers.TableName+" WHERE delivery = $3 ORDER BY listid LIMIT $1 OFFSET $2;", limit, offset, delivery)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get digest lists")
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			retErr = errors.Join(retErr, kerrors.WithMsg(err, "Failed to close db rows"))
+		}
+	}()
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, kerrors.WithMsg(err, "Failed to get digest lists")
+		}
+		res = append(res, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get digest lists")
+	}
+	return res, nil
+}
+
 func (r *repo) NewMsg(listid, msgid, userid string) *MsgModel {
 	now := time.Now().Round(0).UnixMilli()
 	return &MsgModel{
@@ -511,6 +710,42 @@ func (r *repo) GetListThread(ctx context.Context, listid, threadid string, limit
 	return m, nil
 }
 
+func (t *msgModelTable) GetMsgModelByListCreationRange(ctx context.Context, d sqldb.Executor, listid string, after, before int64, limit, offset int) (_ []MsgModel, retErr error) {
+	res := make([]MsgModel, 0, limit)
+	rows, err := d.QueryContext(ctx, "SELECT listid, msgid, userid, creation_time, spf_pass, dkim_pass, subject, in_reply_to, parent_id, thread_id, processed, sent, deleted FROM "+t.TableName+" WHERE listid = $3 AND creation_time > $4 AND creation_time <= $5 AND deleted = FALSE ORDER BY creation_time LIMIT $1 OFFSET $2;", limit, offset, listid, after, before)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			retErr = errors.Join(retErr, kerrors.WithMsg(err, "Failed to close db rows"))
+		}
+	}()
+	for rows.Next() {
+		var m MsgModel
+		if err := rows.Scan(&m.ListID, &m.Msgid, &m.Userid, &m.CreationTime, &m.SPFPass, &m.DKIMPass, &m.Subject, &m.InReplyTo, &m.ParentID, &m.ThreadID, &m.Processed, &m.Sent, &m.Deleted); err != nil {
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (r *repo) GetListMsgsRange(ctx context.Context, listid string, after, before int64, limit, offset int) ([]MsgModel, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.tableMsgs.GetMsgModelByListCreationRange(ctx, d, listid, after, before, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get list messages")
+	}
+	return m, nil
+}
+
 func (r *repo) InsertMsg(ctx context.Context, m *MsgModel) error {
 	d, err := r.db.DB(ctx)
 	if err != nil {
@@ -615,13 +850,13 @@ func (r *repo) DeleteMsgs(ctx context.Context, listid string, msgids []string) e
 	return nil
 }
 
-func (r *repo) GetUnsentMsgs(ctx context.Context, listid, msgid string, limit int) (_ []string, retErr error) {
+func (r *repo) GetUnsentMsgs(ctx context.Context, listid, msgid string, delivery string, limit int) (_ []string, retErr error) {
 	d, err := r.db.DB(ctx)
 	if err != nil {
 		return nil, err
 	}
 	res := make([]string, 0, limit)
-	rows, err := d.QueryContext(ctx, "SELECT m.userid FROM "+r.tableMembers.TableName+" m LEFT JOIN "+r.tableSent.TableName+" s ON m.listid = s.listid AND m.userid = s.userid AND s.msgid = $3 WHERE m.listid = $2 AND s.msgid IS NULL LIMIT $1;", limit, listid, msgid)
+	rows, err := d.QueryContext(ctx, "SELECT m.userid FROM "+r.tableMembers.TableName+" m LEFT JOIN "+r.tableSent.TableName+" s ON m.listid = s.listid AND m.userid = s.userid AND s.msgid = $3 WHERE m.listid = $2 AND m.delivery = $4 AND s.msgid IS NULL LIMIT $1;", limit, listid, msgid, delivery)
 	if err != nil {
 		return nil, kerrors.WithMsg(err, "Failed to get unsent list messages")
 	}
@@ -780,6 +1015,403 @@ func (r *repo) DeleteListTrees(ctx context.Context, listid string) error {
 	return nil
 }
 
+func (r *repo) NewDigest(listid, kind string, periodStart, periodEnd int64) *DigestModel {
+	return &DigestModel{
+		ListID:       listid,
+		Kind:         kind,
+		PeriodEnd:    periodEnd,
+		PeriodStart:  periodStart,
+		Sent:         false,
+		CreationTime: time.Now().Round(0).Unix(),
+	}
+}
+
+func (r *repo) GetDigest(ctx context.Context, listid, kind string, periodEnd int64) (*DigestModel, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.tableDigests.GetDigestModelByListKindPeriod(ctx, d, listid, kind, periodEnd)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get list digest")
+	}
+	return m, nil
+}
+
+func (r *repo) InsertDigest(ctx context.Context, m *DigestModel) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tableDigests.Insert(ctx, d, m); err != nil {
+		return kerrors.WithMsg(err, "Failed to insert list digest")
+	}
+	return nil
+}
+
+func (r *repo) MarkDigestSent(ctx context.Context, listid, kind string, periodEnd int64) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tableDigests.UpddigestSentByListKindPeriod(ctx, d, &digestSent{
+		Sent: true,
+	}, listid, kind, periodEnd); err != nil {
+		return kerrors.WithMsg(err, "Failed to update list digest")
+	}
+	return nil
+}
+
+func (r *repo) DeleteListDigests(ctx context.Context, listid string) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tableDigests.DelByList(ctx, d, listid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete list digests")
+	}
+	return nil
+}
+
+func (r *repo) NewPending(listid, msgid, userid string, reason string) *PendingModel {
+	return &PendingModel{
+		ListID:       listid,
+		Msgid:        msgid,
+		Userid:       userid,
+		Reason:       reason,
+		CreationTime: time.Now().Round(0).UnixMilli(),
+	}
+}
+
+func (r *repo) GetPending(ctx context.Context, listid, msgid string) (*PendingModel, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.tablePending.GetPendingModelByListMsg(ctx, d, listid, msgid)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get pending list message")
+	}
+	return m, nil
+}
+
+func (r *repo) GetListPending(ctx context.Context, listid string, limit, offset int) ([]PendingModel, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.tablePending.GetPendingModelByList(ctx, d, listid, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get pending list messages")
+	}
+	return m, nil
+}
+
+func (r *repo) InsertPending(ctx context.Context, m *PendingModel) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tablePending.Insert(ctx, d, m); err != nil {
+		return kerrors.WithMsg(err, "Failed to insert pending list message")
+	}
+	return nil
+}
+
+func (r *repo) DeletePending(ctx context.Context, listid, msgid string) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tablePending.DelByListMsg(ctx, d, listid, msgid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete pending list message")
+	}
+	return nil
+}
+
+func (r *repo) DeleteListPending(ctx context.Context, listid string) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tablePending.DelByList(ctx, d, listid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete pending list messages")
+	}
+	return nil
+}
+
+func (r *repo) IsApprovedSender(ctx context.Context, listid, userid string) (bool, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return false, err
+	}
+	if _, err := r.tableSenders.GetSenderModelByListUser(ctx, d, listid, userid); err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			return false, nil
+		}
+		return false, kerrors.WithMsg(err, "Failed to get approved list sender")
+	}
+	return true, nil
+}
+
+func (r *repo) InsertApprovedSender(ctx context.Context, listid, userid string) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tableSenders.InsertBulk(ctx, d, []*SenderModel{
+		{
+			ListID:       listid,
+			Userid:       userid,
+			CreationTime: time.Now().Round(0).Unix(),
+		},
+	}, true); err != nil {
+		return kerrors.WithMsg(err, "Failed to insert approved list sender")
+	}
+	return nil
+}
+
+func (r *repo) DeleteApprovedSender(ctx context.Context, listid, userid string) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tableSenders.DelByListUser(ctx, d, listid, userid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete approved list sender")
+	}
+	return nil
+}
+
+func (r *repo) DeleteListApprovedSenders(ctx context.Context, listid string) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tableSenders.DelByList(ctx, d, listid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete approved list senders")
+	}
+	return nil
+}
+
+func (r *repo) NewCmd(listid, userid string, cmd, arg string) (*CmdModel, string, error) {
+	keybytes, err := uid.NewKey()
+	if err != nil {
+		return nil, "", kerrors.WithMsg(err, "Failed to create list command key")
+	}
+	// keys are used in the local part of addresses, which may be case
+	// insensitive
+	key := hex.EncodeToString(keybytes.Bytes()[:cmdKeySize])
+	hash, err := r.hasher.Hash([]byte(key))
+	if err != nil {
+		return nil, "", kerrors.WithMsg(err, "Failed to hash list command key")
+	}
+	return &CmdModel{
+		ListID:       listid,
+		Userid:       userid,
+		Cmd:          cmd,
+		Arg:          arg,
+		KeyHash:      hash,
+		CreationTime: time.Now().Round(0).Unix(),
+	}, key, nil
+}
+
+func (r *repo) ValidateCmdKey(key string, m *CmdModel) (bool, error) {
+	ok, err := r.verifier.Verify([]byte(key), m.KeyHash)
+	if err != nil {
+		return false, kerrors.WithMsg(err, "Failed to verify key")
+	}
+	return ok, nil
+}
+
+func (r *repo) GetCmd(ctx context.Context, listid, userid string) (*CmdModel, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.tableCmds.GetCmdModelByListUser(ctx, d, listid, userid)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get list command")
+	}
+	return m, nil
+}
+
+func (r *repo) InsertCmd(ctx context.Context, m *CmdModel) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tableCmds.Insert(ctx, d, m); err != nil {
+		return kerrors.WithMsg(err, "Failed to insert list command")
+	}
+	return nil
+}
+
+func (r *repo) DeleteCmd(ctx context.Context, listid, userid string) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tableCmds.DelByListUser(ctx, d, listid, userid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete list command")
+	}
+	return nil
+}
+
+func (r *repo) DeleteListCmds(ctx context.Context, listid string) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tableCmds.DelByList(ctx, d, listid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete list commands")
+	}
+	return nil
+}
+
+const (
+	searchConfig = "english"
+	// searchDoc must match the expression of the search index for the index to
+	// be used
+	searchDoc = "to_tsvector('" + searchConfig + "', from_addr || ' ' || subject || ' ' || body)"
+	// searchHeadlineOpts limits the msg body snippet of a search result
+	searchHeadlineOpts = "MaxFragments=1, MaxWords=32, MinWords=8"
+)
+
+func (r *repo) NewSearch(listid, msgid, userid string, creationTime int64, from, subject, body string) *SearchModel {
+	return &SearchModel{
+		ListID:       listid,
+		Msgid:        msgid,
+		Userid:       userid,
+		CreationTime: creationTime,
+		FromAddr:     from,
+		Subject:      subject,
+		Body:         body,
+	}
+}
+
+func (r *repo) InsertSearch(ctx context.Context, m *SearchModel) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	// a msg may be indexed more than once
+	if err := r.tableSearch.InsertBulk(ctx, d, []*SearchModel{m}, true); err != nil {
+		return kerrors.WithMsg(err, "Failed to insert list message search index")
+	}
+	return nil
+}
+
+// SetupModFirstPostColumn adds the mod_first_post column to list tables created
+// before first posts could be moderated
+func (t *listModelTable) SetupModFirstPostColumn(ctx context.Context, d sqldb.Executor) error {
+	if _, err := d.ExecContext(ctx, "ALTER TABLE "+t.TableName+" ADD COLUMN IF NOT EXISTS mod_first_post BOOLEAN NOT NULL DEFAULT FALSE;"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// SetupDeliveryColumn adds the delivery column to member tables created before
+// members had delivery preferences. It must run before the member table setup,
+// which indexes the column.
+func (t *memberModelTable) SetupDeliveryColumn(ctx context.Context, d sqldb.Executor) error {
+	if _, err := d.ExecContext(ctx, "ALTER TABLE IF EXISTS "+t.TableName+" ADD COLUMN IF NOT EXISTS delivery VARCHAR(31) NOT NULL DEFAULT '"+MemberDeliveryImmediate+"';"); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *searchModelTable) SetupSearchIndex(ctx context.Context, d sqldb.Executor) error {
+	if _, err := d.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS "+t.TableName+"_search_doc_index ON "+t.TableName+" USING GIN ("+searchDoc+");"); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *searchModelTable) scanSearchResults(rows sqldb.Rows, limit int) (_ []SearchResult, retErr error) {
+	res := make([]SearchResult, 0, limit)
+	defer func() {
+		if err := rows.Close(); err != nil {
+			retErr = errors.Join(retErr, kerrors.WithMsg(err, "Failed to close db rows"))
+		}
+	}()
+	for rows.Next() {
+		var m SearchResult
+		if err := rows.Scan(&m.ListID, &m.Msgid, &m.Userid, &m.CreationTime, &m.FromAddr, &m.Subject, &m.Snippet); err != nil {
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (t *searchModelTable) SearchByList(ctx context.Context, d sqldb.Executor, listid string, query string, limit, offset int) ([]SearchResult, error) {
+	rows, err := d.QueryContext(ctx, "SELECT listid, msgid, userid, creation_time, from_addr, subject, ts_headline('"+searchConfig+"', body, q, '"+searchHeadlineOpts+"') FROM "+t.TableName+", websearch_to_tsquery('"+searchConfig+"', $3) q WHERE listid = $4 AND "+searchDoc+" @@ q ORDER BY ts_rank("+searchDoc+", q) DESC, creation_time DESC LIMIT $1 OFFSET $2;", limit, offset, query, listid)
+	if err != nil {
+		return nil, err
+	}
+	return t.scanSearchResults(rows, limit)
+}
+
+func (t *searchModelTable) SearchByMember(ctx context.Context, d sqldb.Executor, memberTableName string, userid string, query string, limit, offset int) ([]SearchResult, error) {
+	rows, err := d.QueryContext(ctx, "SELECT listid, msgid, userid, creation_time, from_addr, subject, ts_headline('"+searchConfig+"', body, q, '"+searchHeadlineOpts+"') FROM "+t.TableName+", websearch_to_tsquery('"+searchConfig+"', $3) q WHERE listid IN (SELECT listid FROM "+memberTableName+" WHERE userid = $4) AND "+searchDoc+" @@ q ORDER BY ts_rank("+searchDoc+", q) DESC, creation_time DESC LIMIT $1 OFFSET $2;", limit, offset, query, userid)
+	if err != nil {
+		return nil, err
+	}
+	return t.scanSearchResults(rows, limit)
+}
+
+func (r *repo) SearchListMsgs(ctx context.Context, listid string, query string, limit, offset int) ([]SearchResult, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.tableSearch.SearchByList(ctx, d, listid, query, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to search list messages")
+	}
+	return m, nil
+}
+
+func (r *repo) SearchMemberMsgs(ctx context.Context, userid string, query string, limit, offset int) ([]SearchResult, error) {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.tableSearch.SearchByMember(ctx, d, r.tableMembers.TableName, userid, query, limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to search member list messages")
+	}
+	return m, nil
+}
+
+func (r *repo) DeleteSearchMsgs(ctx context.Context, listid string, msgids []string) error {
+	if len(msgids) == 0 {
+		return nil
+	}
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tableSearch.DelByListMsgs(ctx, d, listid, msgids); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete list message search index")
+	}
+	return nil
+}
+
+func (r *repo) DeleteListSearch(ctx context.Context, listid string) error {
+	d, err := r.db.DB(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.tableSearch.DelByList(ctx, d, listid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete list search index")
+	}
+	return nil
+}
+
 func (r *repo) Setup(ctx context.Context) error {
 	d, err := r.db.DB(ctx)
 	if err != nil {
@@ -788,6 +1420,12 @@ func (r *repo) Setup(ctx context.Context) error {
 	if err := r.tableLists.Setup(ctx, d); err != nil {
 		return kerrors.WithMsg(err, "Failed to setup list model")
 	}
+	if err := r.tableLists.SetupModFirstPostColumn(ctx, d); err != nil {
+		return kerrors.WithMsg(err, "Failed to setup list mod first post column")
+	}
+	if err := r.tableMembers.SetupDeliveryColumn(ctx, d); err != nil {
+		return kerrors.WithMsg(err, "Failed to setup list member delivery column")
+	}
 	if err := r.tableMembers.Setup(ctx, d); err != nil {
 		return kerrors.WithMsg(err, "Failed to setup list member model")
 	}
@@ -800,5 +1438,23 @@ func (r *repo) Setup(ctx context.Context) error {
 	if err := r.tableTree.Setup(ctx, d); err != nil {
 		return kerrors.WithMsg(err, "Failed to setup list message model")
 	}
+	if err := r.tableDigests.Setup(ctx, d); err != nil {
+		return kerrors.WithMsg(err, "Failed to setup list digest model")
+	}
+	if err := r.tablePending.Setup(ctx, d); err != nil {
+		return kerrors.WithMsg(err, "Failed to setup list pending message model")
+	}
+	if err := r.tableSenders.Setup(ctx, d); err != nil {
+		return kerrors.WithMsg(err, "Failed to setup list approved sender model")
+	}
+	if err := r.tableCmds.Setup(ctx, d); err != nil {
+		return kerrors.WithMsg(err, "Failed to setup list command model")
+	}
+	if err := r.tableSearch.Setup(ctx, d); err != nil {
+		return kerrors.WithMsg(err, "Failed to setup list search model")
+	}
+	if err := r.tableSearch.SetupSearchIndex(ctx, d); err != nil {
+		return kerrors.WithMsg(err, "Failed to setup list search index")
+	}
 	return nil
 }
@@ -18,7 +18,7 @@ type (
 )
 
 func (t *listModelTable) Setup(ctx context.Context, d sqldb.Executor) error {
-	_, err := d.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+t.TableName+" (listid VARCHAR(255) PRIMARY KEY, creatorid VARCHAR(31) NOT NULL, listname VARCHAR(127) NOT NULL, name VARCHAR(255) NOT NULL, description VARCHAR(255), archive BOOLEAN NOT NULL, sender_policy VARCHAR(255) NOT NULL, member_policy VARCHAR(255) NOT NULL, last_updated BIGINT NOT NULL, creation_time BIGINT NOT NULL);")
+	_, err := d.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+t.TableName+" (listid VARCHAR(255) PRIMARY KEY, creatorid VARCHAR(31) NOT NULL, listname VARCHAR(127) NOT NULL, name VARCHAR(255) NOT NULL, description VARCHAR(255), archive BOOLEAN NOT NULL, sender_policy VARCHAR(255) NOT NULL, member_policy VARCHAR(255) NOT NULL, mod_first_post BOOLEAN NOT NULL, last_updated BIGINT NOT NULL, creation_time BIGINT NOT NULL);")
 	if err != nil {
 		return err
 	}
@@ -30,7 +30,7 @@ func (t *listModelTable) Setup(ctx context.Context, d sqldb.Executor) error {
 }
 
 func (t *listModelTable) Insert(ctx context.Context, d sqldb.Executor, m *ListModel) error {
-	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (listid, creatorid, listname, name, description, archive, sender_policy, member_policy, last_updated, creation_time) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);", m.ListID, m.CreatorID, m.Listname, m.Name, m.Description, m.Archive, m.SenderPolicy, m.MemberPolicy, m.LastUpdated, m.CreationTime)
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (listid, creatorid, listname, name, description, archive, sender_policy, member_policy, mod_first_post, last_updated, creation_time) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11);", m.ListID, m.CreatorID, m.Listname, m.Name, m.Description, m.Archive, m.SenderPolicy, m.MemberPolicy, m.ModFirstPost, m.LastUpdated, m.CreationTime)
 	if err != nil {
 		return err
 	}
@@ -43,13 +43,13 @@ func (t *listModelTable) InsertBulk(ctx context.Context, d sqldb.Executor, model
 		conflictSQL = " ON CONFLICT DO NOTHING"
 	}
 	placeholders := make([]string, 0, len(models))
-	args := make([]interface{}, 0, len(models)*10)
+	args := make([]interface{}, 0, len(models)*11)
 	for c, m := range models {
-		n := c * 10
-		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9, n+10))
-		args = append(args, m.ListID, m.CreatorID, m.Listname, m.Name, m.Description, m.Archive, m.SenderPolicy, m.MemberPolicy, m.LastUpdated, m.CreationTime)
+		n := c * 11
+		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9, n+10, n+11))
+		args = append(args, m.ListID, m.CreatorID, m.Listname, m.Name, m.Description, m.Archive, m.SenderPolicy, m.MemberPolicy, m.ModFirstPost, m.LastUpdated, m.CreationTime)
 	}
-	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (listid, creatorid, listname, name, description, archive, sender_policy, member_policy, last_updated, creation_time) VALUES "+strings.Join(placeholders, ", ")+conflictSQL+";", args...)
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (listid, creatorid, listname, name, description, archive, sender_policy, member_policy, mod_first_post, last_updated, creation_time) VALUES "+strings.Join(placeholders, ", ")+conflictSQL+";", args...)
 	if err != nil {
 		return err
 	}
@@ -58,7 +58,7 @@ func (t *listModelTable) InsertBulk(ctx context.Context, d sqldb.Executor, model
 
 func (t *listModelTable) GetListModelByID(ctx context.Context, d sqldb.Executor, listid string) (*ListModel, error) {
 	m := &ListModel{}
-	if err := d.QueryRowContext(ctx, "SELECT listid, creatorid, listname, name, description, archive, sender_policy, member_policy, last_updated, creation_time FROM "+t.TableName+" WHERE listid = $1;", listid).Scan(&m.ListID, &m.CreatorID, &m.Listname, &m.Name, &m.Description, &m.Archive, &m.SenderPolicy, &m.MemberPolicy, &m.LastUpdated, &m.CreationTime); err != nil {
+	if err := d.QueryRowContext(ctx, "SELECT listid, creatorid, listname, name, description, archive, sender_policy, member_policy, mod_first_post, last_updated, creation_time FROM "+t.TableName+" WHERE listid = $1;", listid).Scan(&m.ListID, &m.CreatorID, &m.Listname, &m.Name, &m.Description, &m.Archive, &m.SenderPolicy, &m.MemberPolicy, &m.ModFirstPost, &m.LastUpdated, &m.CreationTime); err != nil {
 		return nil, err
 	}
 	return m, nil
@@ -79,7 +79,7 @@ func (t *listModelTable) GetListModelByIDs(ctx context.Context, d sqldb.Executor
 		placeholderslistids = strings.Join(placeholders, ", ")
 	}
 	res := make([]ListModel, 0, limit)
-	rows, err := d.QueryContext(ctx, "SELECT listid, creatorid, listname, name, description, archive, sender_policy, member_policy, last_updated, creation_time FROM "+t.TableName+" WHERE listid IN (VALUES "+placeholderslistids+") LIMIT $1 OFFSET $2;", args...)
+	rows, err := d.QueryContext(ctx, "SELECT listid, creatorid, listname, name, description, archive, sender_policy, member_policy, mod_first_post, last_updated, creation_time FROM "+t.TableName+" WHERE listid IN (VALUES "+placeholderslistids+") LIMIT $1 OFFSET $2;", args...)
 	if err != nil {
 		return nil, err
 	}
@@ -90,7 +90,7 @@ func (t *listModelTable) GetListModelByIDs(ctx context.Context, d sqldb.Executor
 	}()
 	for rows.Next() {
 		var m ListModel
-		if err := rows.Scan(&m.ListID, &m.CreatorID, &m.Listname, &m.Name, &m.Description, &m.Archive, &m.SenderPolicy, &m.MemberPolicy, &m.LastUpdated, &m.CreationTime); err != nil {
+		if err := rows.Scan(&m.ListID, &m.CreatorID, &m.Listname, &m.Name, &m.Description, &m.Archive, &m.SenderPolicy, &m.MemberPolicy, &m.ModFirstPost, &m.LastUpdated, &m.CreationTime); err != nil {
 			return nil, err
 		}
 		res = append(res, m)
@@ -113,7 +113,7 @@ func (t *listModelTable) DelByCreator(ctx context.Context, d sqldb.Executor, cre
 
 func (t *listModelTable) GetListModelByCreator(ctx context.Context, d sqldb.Executor, creatorid string, limit, offset int) (_ []ListModel, retErr error) {
 	res := make([]ListModel, 0, limit)
-	rows, err := d.QueryContext(ctx, "SELECT listid, creatorid, listname, name, description, archive, sender_policy, member_policy, last_updated, creation_time FROM "+t.TableName+" WHERE creatorid = $3 ORDER BY last_updated DESC LIMIT $1 OFFSET $2;", limit, offset, creatorid)
+	rows, err := d.QueryContext(ctx, "SELECT listid, creatorid, listname, name, description, archive, sender_policy, member_policy, mod_first_post, last_updated, creation_time FROM "+t.TableName+" WHERE creatorid = $3 ORDER BY last_updated DESC LIMIT $1 OFFSET $2;", limit, offset, creatorid)
 	if err != nil {
 		return nil, err
 	}
@@ -124,7 +124,7 @@ func (t *listModelTable) GetListModelByCreator(ctx context.Context, d sqldb.Exec
 	}()
 	for rows.Next() {
 		var m ListModel
-		if err := rows.Scan(&m.ListID, &m.CreatorID, &m.Listname, &m.Name, &m.Description, &m.Archive, &m.SenderPolicy, &m.MemberPolicy, &m.LastUpdated, &m.CreationTime); err != nil {
+		if err := rows.Scan(&m.ListID, &m.CreatorID, &m.Listname, &m.Name, &m.Description, &m.Archive, &m.SenderPolicy, &m.MemberPolicy, &m.ModFirstPost, &m.LastUpdated, &m.CreationTime); err != nil {
 			return nil, err
 		}
 		res = append(res, m)
@@ -136,7 +136,7 @@ func (t *listModelTable) GetListModelByCreator(ctx context.Context, d sqldb.Exec
 }
 
 func (t *listModelTable) UpdlistPropsByID(ctx context.Context, d sqldb.Executor, m *listProps, listid string) error {
-	_, err := d.ExecContext(ctx, "UPDATE "+t.TableName+" SET (name, description, archive, sender_policy, member_policy) = ($1, $2, $3, $4, $5) WHERE listid = $6;", m.Name, m.Description, m.Archive, m.SenderPolicy, m.MemberPolicy, listid)
+	_, err := d.ExecContext(ctx, "UPDATE "+t.TableName+" SET (name, description, archive, sender_policy, member_policy, mod_first_post) = ($1, $2, $3, $4, $5, $6) WHERE listid = $7;", m.Name, m.Description, m.Archive, m.SenderPolicy, m.MemberPolicy, m.ModFirstPost, listid)
 	if err != nil {
 		return err
 	}
@@ -158,7 +158,7 @@ type (
 )
 
 func (t *memberModelTable) Setup(ctx context.Context, d sqldb.Executor) error {
-	_, err := d.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+t.TableName+" (listid VARCHAR(255), userid VARCHAR(31), last_updated BIGINT NOT NULL, PRIMARY KEY (listid, userid));")
+	_, err := d.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+t.TableName+" (listid VARCHAR(255), userid VARCHAR(31), delivery VARCHAR(31) NOT NULL, last_updated BIGINT NOT NULL, PRIMARY KEY (listid, userid));")
 	if err != nil {
 		return err
 	}
@@ -166,11 +166,15 @@ func (t *memberModelTable) Setup(ctx context.Context, d sqldb.Executor) error {
 	if err != nil {
 		return err
 	}
+	_, err = d.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS "+t.TableName+"_delivery_listid_index ON "+t.TableName+" (delivery, listid);")
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
 func (t *memberModelTable) Insert(ctx context.Context, d sqldb.Executor, m *MemberModel) error {
-	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (listid, userid, last_updated) VALUES ($1, $2, $3);", m.ListID, m.Userid, m.LastUpdated)
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (listid, userid, delivery, last_updated) VALUES ($1, $2, $3, $4);", m.ListID, m.Userid, m.Delivery, m.LastUpdated)
 	if err != nil {
 		return err
 	}
@@ -183,13 +187,13 @@ func (t *memberModelTable) InsertBulk(ctx context.Context, d sqldb.Executor, mod
 		conflictSQL = " ON CONFLICT DO NOTHING"
 	}
 	placeholders := make([]string, 0, len(models))
-	args := make([]interface{}, 0, len(models)*3)
+	args := make([]interface{}, 0, len(models)*4)
 	for c, m := range models {
-		n := c * 3
-		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d)", n+1, n+2, n+3))
-		args = append(args, m.ListID, m.Userid, m.LastUpdated)
+		n := c * 4
+		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4))
+		args = append(args, m.ListID, m.Userid, m.Delivery, m.LastUpdated)
 	}
-	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (listid, userid, last_updated) VALUES "+strings.Join(placeholders, ", ")+conflictSQL+";", args...)
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (listid, userid, delivery, last_updated) VALUES "+strings.Join(placeholders, ", ")+conflictSQL+";", args...)
 	if err != nil {
 		return err
 	}
@@ -203,7 +207,7 @@ func (t *memberModelTable) DelByList(ctx context.Context, d sqldb.Executor, list
 
 func (t *memberModelTable) GetMemberModelByListUser(ctx context.Context, d sqldb.Executor, listid string, userid string) (*MemberModel, error) {
 	m := &MemberModel{}
-	if err := d.QueryRowContext(ctx, "SELECT listid, userid, last_updated FROM "+t.TableName+" WHERE listid = $1 AND userid = $2;", listid, userid).Scan(&m.ListID, &m.Userid, &m.LastUpdated); err != nil {
+	if err := d.QueryRowContext(ctx, "SELECT listid, userid, delivery, last_updated FROM "+t.TableName+" WHERE listid = $1 AND userid = $2;", listid, userid).Scan(&m.ListID, &m.Userid, &m.Delivery, &m.LastUpdated); err != nil {
 		return nil, err
 	}
 	return m, nil
@@ -211,7 +215,7 @@ func (t *memberModelTable) GetMemberModelByListUser(ctx context.Context, d sqldb
 
 func (t *memberModelTable) GetMemberModelByList(ctx context.Context, d sqldb.Executor, listid string, limit, offset int) (_ []MemberModel, retErr error) {
 	res := make([]MemberModel, 0, limit)
-	rows, err := d.QueryContext(ctx, "SELECT listid, userid, last_updated FROM "+t.TableName+" WHERE listid = $3 ORDER BY userid LIMIT $1 OFFSET $2;", limit, offset, listid)
+	rows, err := d.QueryContext(ctx, "SELECT listid, userid, delivery, last_updated FROM "+t.TableName+" WHERE listid = $3 ORDER BY userid LIMIT $1 OFFSET $2;", limit, offset, listid)
 	if err != nil {
 		return nil, err
 	}
@@ -222,7 +226,7 @@ func (t *memberModelTable) GetMemberModelByList(ctx context.Context, d sqldb.Exe
 	}()
 	for rows.Next() {
 		var m MemberModel
-		if err := rows.Scan(&m.ListID, &m.Userid, &m.LastUpdated); err != nil {
+		if err := rows.Scan(&m.ListID, &m.Userid, &m.Delivery, &m.LastUpdated); err != nil {
 			return nil, err
 		}
 		res = append(res, m)
@@ -248,7 +252,7 @@ func (t *memberModelTable) GetMemberModelByListUsers(ctx context.Context, d sqld
 		placeholdersuserids = strings.Join(placeholders, ", ")
 	}
 	res := make([]MemberModel, 0, limit)
-	rows, err := d.QueryContext(ctx, "SELECT listid, userid, last_updated FROM "+t.TableName+" WHERE listid = $3 AND userid IN (VALUES "+placeholdersuserids+") LIMIT $1 OFFSET $2;", args...)
+	rows, err := d.QueryContext(ctx, "SELECT listid, userid, delivery, last_updated FROM "+t.TableName+" WHERE listid = $3 AND userid IN (VALUES "+placeholdersuserids+") LIMIT $1 OFFSET $2;", args...)
 	if err != nil {
 		return nil, err
 	}
@@ -259,7 +263,7 @@ func (t *memberModelTable) GetMemberModelByListUsers(ctx context.Context, d sqld
 	}()
 	for rows.Next() {
 		var m MemberModel
-		if err := rows.Scan(&m.ListID, &m.Userid, &m.LastUpdated); err != nil {
+		if err := rows.Scan(&m.ListID, &m.Userid, &m.Delivery, &m.LastUpdated); err != nil {
 			return nil, err
 		}
 		res = append(res, m)
@@ -295,7 +299,7 @@ func (t *memberModelTable) DelByUser(ctx context.Context, d sqldb.Executor, user
 
 func (t *memberModelTable) GetMemberModelByUser(ctx context.Context, d sqldb.Executor, userid string, limit, offset int) (_ []MemberModel, retErr error) {
 	res := make([]MemberModel, 0, limit)
-	rows, err := d.QueryContext(ctx, "SELECT listid, userid, last_updated FROM "+t.TableName+" WHERE userid = $3 ORDER BY last_updated DESC LIMIT $1 OFFSET $2;", limit, offset, userid)
+	rows, err := d.QueryContext(ctx, "SELECT listid, userid, delivery, last_updated FROM "+t.TableName+" WHERE userid = $3 ORDER BY last_updated DESC LIMIT $1 OFFSET $2;", limit, offset, userid)
 	if err != nil {
 		return nil, err
 	}
@@ -306,7 +310,7 @@ func (t *memberModelTable) GetMemberModelByUser(ctx context.Context, d sqldb.Exe
 	}()
 	for rows.Next() {
 		var m MemberModel
-		if err := rows.Scan(&m.ListID, &m.Userid, &m.LastUpdated); err != nil {
+		if err := rows.Scan(&m.ListID, &m.Userid, &m.Delivery, &m.LastUpdated); err != nil {
 			return nil, err
 		}
 		res = append(res, m)
@@ -317,6 +321,14 @@ func (t *memberModelTable) GetMemberModelByUser(ctx context.Context, d sqldb.Exe
 	return res, nil
 }
 
+func (t *memberModelTable) UpdmemberDeliveryByListUser(ctx context.Context, d sqldb.Executor, m *memberDelivery, listid string, userid string) error {
+	_, err := d.ExecContext(ctx, "UPDATE "+t.TableName+" SET delivery = $1 WHERE listid = $2 AND userid = $3;", m.Delivery, listid, userid)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
 func (t *memberModelTable) UpdlistLastUpdatedByList(ctx context.Context, d sqldb.Executor, m *listLastUpdated, listid string) error {
 	_, err := d.ExecContext(ctx, "UPDATE "+t.TableName+" SET last_updated = $1 WHERE listid = $2;", m.LastUpdated, listid)
 	if err != nil {
@@ -687,3 +699,334 @@ func (t *treeModelTable) GetTreeModelByListParentDepth(ctx context.Context, d sq
 	}
 	return res, nil
 }
+
+type (
+	digestModelTable struct {
+		TableName string
+	}
+)
+
+func (t *digestModelTable) Setup(ctx context.Context, d sqldb.Executor) error {
+	_, err := d.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+t.TableName+" (listid VARCHAR(255), kind VARCHAR(31), period_end BIGINT, period_start BIGINT NOT NULL, sent BOOL NOT NULL, creation_time BIGINT NOT NULL, PRIMARY KEY (listid, kind, period_end));")
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *digestModelTable) Insert(ctx context.Context, d sqldb.Executor, m *DigestModel) error {
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (listid, kind, period_end, period_start, sent, creation_time) VALUES ($1, $2, $3, $4, $5, $6);", m.ListID, m.Kind, m.PeriodEnd, m.PeriodStart, m.Sent, m.CreationTime)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *digestModelTable) InsertBulk(ctx context.Context, d sqldb.Executor, models []*DigestModel, allowConflict bool) error {
+	conflictSQL := ""
+	if allowConflict {
+		conflictSQL = " ON CONFLICT DO NOTHING"
+	}
+	placeholders := make([]string, 0, len(models))
+	args := make([]interface{}, 0, len(models)*6)
+	for c, m := range models {
+		n := c * 6
+		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6))
+		args = append(args, m.ListID, m.Kind, m.PeriodEnd, m.PeriodStart, m.Sent, m.CreationTime)
+	}
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (listid, kind, period_end, period_start, sent, creation_time) VALUES "+strings.Join(placeholders, ", ")+conflictSQL+";", args...)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *digestModelTable) GetDigestModelByListKindPeriod(ctx context.Context, d sqldb.Executor, listid string, kind string, periodend int64) (*DigestModel, error) {
+	m := &DigestModel{}
+	if err := d.QueryRowContext(ctx, "SELECT listid, kind, period_end, period_start, sent, creation_time FROM "+t.TableName+" WHERE listid = $1 AND kind = $2 AND period_end = $3;", listid, kind, periodend).Scan(&m.ListID, &m.Kind, &m.PeriodEnd, &m.PeriodStart, &m.Sent, &m.CreationTime); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (t *digestModelTable) DelByList(ctx context.Context, d sqldb.Executor, listid string) error {
+	_, err := d.ExecContext(ctx, "DELETE FROM "+t.TableName+" WHERE listid = $1;", listid)
+	return err
+}
+
+func (t *digestModelTable) UpddigestSentByListKindPeriod(ctx context.Context, d sqldb.Executor, m *digestSent, listid string, kind string, periodend int64) error {
+	_, err := d.ExecContext(ctx, "UPDATE "+t.TableName+" SET sent = $1 WHERE listid = $2 AND kind = $3 AND period_end = $4;", m.Sent, listid, kind, periodend)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+type (
+	pendingModelTable struct {
+		TableName string
+	}
+)
+
+func (t *pendingModelTable) Setup(ctx context.Context, d sqldb.Executor) error {
+	_, err := d.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+t.TableName+" (listid VARCHAR(255), msgid VARCHAR(1023), userid VARCHAR(31) NOT NULL, spf_pass VARCHAR(255) NOT NULL, dkim_pass VARCHAR(255) NOT NULL, subject VARCHAR(255) NOT NULL, in_reply_to VARCHAR(1023) NOT NULL, reason VARCHAR(31) NOT NULL, creation_time BIGINT NOT NULL, PRIMARY KEY (listid, msgid));")
+	if err != nil {
+		return err
+	}
+	_, err = d.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS "+t.TableName+"_list_creation_time_index ON "+t.TableName+" (listid, creation_time);")
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *pendingModelTable) Insert(ctx context.Context, d sqldb.Executor, m *PendingModel) error {
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (listid, msgid, userid, spf_pass, dkim_pass, subject, in_reply_to, reason, creation_time) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);", m.ListID, m.Msgid, m.Userid, m.SPFPass, m.DKIMPass, m.Subject, m.InReplyTo, m.Reason, m.CreationTime)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *pendingModelTable) InsertBulk(ctx context.Context, d sqldb.Executor, models []*PendingModel, allowConflict bool) error {
+	conflictSQL := ""
+	if allowConflict {
+		conflictSQL = " ON CONFLICT DO NOTHING"
+	}
+	placeholders := make([]string, 0, len(models))
+	args := make([]interface{}, 0, len(models)*9)
+	for c, m := range models {
+		n := c * 9
+		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9))
+		args = append(args, m.ListID, m.Msgid, m.Userid, m.SPFPass, m.DKIMPass, m.Subject, m.InReplyTo, m.Reason, m.CreationTime)
+	}
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (listid, msgid, userid, spf_pass, dkim_pass, subject, in_reply_to, reason, creation_time) VALUES "+strings.Join(placeholders, ", ")+conflictSQL+";", args...)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *pendingModelTable) GetPendingModelByListMsg(ctx context.Context, d sqldb.Executor, listid string, msgid string) (*PendingModel, error) {
+	m := &PendingModel{}
+	if err := d.QueryRowContext(ctx, "SELECT listid, msgid, userid, spf_pass, dkim_pass, subject, in_reply_to, reason, creation_time FROM "+t.TableName+" WHERE listid = $1 AND msgid = $2;", listid, msgid).Scan(&m.ListID, &m.Msgid, &m.Userid, &m.SPFPass, &m.DKIMPass, &m.Subject, &m.InReplyTo, &m.Reason, &m.CreationTime); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (t *pendingModelTable) GetPendingModelByList(ctx context.Context, d sqldb.Executor, listid string, limit, offset int) (_ []PendingModel, retErr error) {
+	res := make([]PendingModel, 0, limit)
+	rows, err := d.QueryContext(ctx, "SELECT listid, msgid, userid, spf_pass, dkim_pass, subject, in_reply_to, reason, creation_time FROM "+t.TableName+" WHERE listid = $3 ORDER BY creation_time LIMIT $1 OFFSET $2;", limit, offset, listid)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			retErr = errors.Join(retErr, fmt.Errorf("Failed to close db rows: %w", err))
+		}
+	}()
+	for rows.Next() {
+		var m PendingModel
+		if err := rows.Scan(&m.ListID, &m.Msgid, &m.Userid, &m.SPFPass, &m.DKIMPass, &m.Subject, &m.InReplyTo, &m.Reason, &m.CreationTime); err != nil {
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (t *pendingModelTable) DelByListMsg(ctx context.Context, d sqldb.Executor, listid string, msgid string) error {
+	_, err := d.ExecContext(ctx, "DELETE FROM "+t.TableName+" WHERE listid = $1 AND msgid = $2;", listid, msgid)
+	return err
+}
+
+func (t *pendingModelTable) DelByList(ctx context.Context, d sqldb.Executor, listid string) error {
+	_, err := d.ExecContext(ctx, "DELETE FROM "+t.TableName+" WHERE listid = $1;", listid)
+	return err
+}
+
+type (
+	senderModelTable struct {
+		TableName string
+	}
+)
+
+func (t *senderModelTable) Setup(ctx context.Context, d sqldb.Executor) error {
+	_, err := d.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+t.TableName+" (listid VARCHAR(255), userid VARCHAR(31), creation_time BIGINT NOT NULL, PRIMARY KEY (listid, userid));")
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *senderModelTable) Insert(ctx context.Context, d sqldb.Executor, m *SenderModel) error {
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (listid, userid, creation_time) VALUES ($1, $2, $3);", m.ListID, m.Userid, m.CreationTime)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *senderModelTable) InsertBulk(ctx context.Context, d sqldb.Executor, models []*SenderModel, allowConflict bool) error {
+	conflictSQL := ""
+	if allowConflict {
+		conflictSQL = " ON CONFLICT DO NOTHING"
+	}
+	placeholders := make([]string, 0, len(models))
+	args := make([]interface{}, 0, len(models)*3)
+	for c, m := range models {
+		n := c * 3
+		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d)", n+1, n+2, n+3))
+		args = append(args, m.ListID, m.Userid, m.CreationTime)
+	}
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (listid, userid, creation_time) VALUES "+strings.Join(placeholders, ", ")+conflictSQL+";", args...)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *senderModelTable) GetSenderModelByListUser(ctx context.Context, d sqldb.Executor, listid string, userid string) (*SenderModel, error) {
+	m := &SenderModel{}
+	if err := d.QueryRowContext(ctx, "SELECT listid, userid, creation_time FROM "+t.TableName+" WHERE listid = $1 AND userid = $2;", listid, userid).Scan(&m.ListID, &m.Userid, &m.CreationTime); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (t *senderModelTable) DelByListUser(ctx context.Context, d sqldb.Executor, listid string, userid string) error {
+	_, err := d.ExecContext(ctx, "DELETE FROM "+t.TableName+" WHERE listid = $1 AND userid = $2;", listid, userid)
+	return err
+}
+
+func (t *senderModelTable) DelByList(ctx context.Context, d sqldb.Executor, listid string) error {
+	_, err := d.ExecContext(ctx, "DELETE FROM "+t.TableName+" WHERE listid = $1;", listid)
+	return err
+}
+
+type (
+	cmdModelTable struct {
+		TableName string
+	}
+)
+
+func (t *cmdModelTable) Setup(ctx context.Context, d sqldb.Executor) error {
+	_, err := d.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+t.TableName+" (listid VARCHAR(255), userid VARCHAR(31), cmd VARCHAR(31) NOT NULL, arg VARCHAR(255) NOT NULL, keyhash VARCHAR(255) NOT NULL, creation_time BIGINT NOT NULL, PRIMARY KEY (listid, userid));")
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *cmdModelTable) Insert(ctx context.Context, d sqldb.Executor, m *CmdModel) error {
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (listid, userid, cmd, arg, keyhash, creation_time) VALUES ($1, $2, $3, $4, $5, $6);", m.ListID, m.Userid, m.Cmd, m.Arg, m.KeyHash, m.CreationTime)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *cmdModelTable) InsertBulk(ctx context.Context, d sqldb.Executor, models []*CmdModel, allowConflict bool) error {
+	conflictSQL := ""
+	if allowConflict {
+		conflictSQL = " ON CONFLICT DO NOTHING"
+	}
+	placeholders := make([]string, 0, len(models))
+	args := make([]interface{}, 0, len(models)*6)
+	for c, m := range models {
+		n := c * 6
+		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6))
+		args = append(args, m.ListID, m.Userid, m.Cmd, m.Arg, m.KeyHash, m.CreationTime)
+	}
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (listid, userid, cmd, arg, keyhash, creation_time) VALUES "+strings.Join(placeholders, ", ")+conflictSQL+";", args...)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *cmdModelTable) GetCmdModelByListUser(ctx context.Context, d sqldb.Executor, listid string, userid string) (*CmdModel, error) {
+	m := &CmdModel{}
+	if err := d.QueryRowContext(ctx, "SELECT listid, userid, cmd, arg, keyhash, creation_time FROM "+t.TableName+" WHERE listid = $1 AND userid = $2;", listid, userid).Scan(&m.ListID, &m.Userid, &m.Cmd, &m.Arg, &m.KeyHash, &m.CreationTime); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (t *cmdModelTable) DelByListUser(ctx context.Context, d sqldb.Executor, listid string, userid string) error {
+	_, err := d.ExecContext(ctx, "DELETE FROM "+t.TableName+" WHERE listid = $1 AND userid = $2;", listid, userid)
+	return err
+}
+
+func (t *cmdModelTable) DelByList(ctx context.Context, d sqldb.Executor, listid string) error {
+	_, err := d.ExecContext(ctx, "DELETE FROM "+t.TableName+" WHERE listid = $1;", listid)
+	return err
+}
+
+type (
+	searchModelTable struct {
+		TableName string
+	}
+)
+
+func (t *searchModelTable) Setup(ctx context.Context, d sqldb.Executor) error {
+	_, err := d.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+t.TableName+" (listid VARCHAR(255), msgid VARCHAR(1023), userid VARCHAR(31) NOT NULL, creation_time BIGINT NOT NULL, from_addr VARCHAR(511) NOT NULL, subject VARCHAR(255) NOT NULL, body TEXT NOT NULL, PRIMARY KEY (listid, msgid));")
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *searchModelTable) Insert(ctx context.Context, d sqldb.Executor, m *SearchModel) error {
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (listid, msgid, userid, creation_time, from_addr, subject, body) VALUES ($1, $2, $3, $4, $5, $6, $7);", m.ListID, m.Msgid, m.Userid, m.CreationTime, m.FromAddr, m.Subject, m.Body)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *searchModelTable) InsertBulk(ctx context.Context, d sqldb.Executor, models []*SearchModel, allowConflict bool) error {
+	conflictSQL := ""
+	if allowConflict {
+		conflictSQL = " ON CONFLICT DO NOTHING"
+	}
+	placeholders := make([]string, 0, len(models))
+	args := make([]interface{}, 0, len(models)*7)
+	for c, m := range models {
+		n := c * 7
+		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6, n+7))
+		args = append(args, m.ListID, m.Msgid, m.Userid, m.CreationTime, m.FromAddr, m.Subject, m.Body)
+	}
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (listid, msgid, userid, creation_time, from_addr, subject, body) VALUES "+strings.Join(placeholders, ", ")+conflictSQL+";", args...)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *searchModelTable) DelByListMsgs(ctx context.Context, d sqldb.Executor, listid string, msgids []string) error {
+	paramCount := 1
+	args := make([]interface{}, 0, paramCount+len(msgids))
+	args = append(args, listid)
+	var placeholdersmsgids string
+	{
+		placeholders := make([]string, 0, len(msgids))
+		for _, i := range msgids {
+			paramCount++
+			placeholders = append(placeholders, fmt.Sprintf("($%d)", paramCount))
+			args = append(args, i)
+		}
+		placeholdersmsgids = strings.Join(placeholders, ", ")
+	}
+	_, err := d.ExecContext(ctx, "DELETE FROM "+t.TableName+" WHERE listid = $1 AND msgid IN (VALUES "+placeholdersmsgids+");", args...)
+	return err
+}
+
+func (t *searchModelTable) DelByList(ctx context.Context, d sqldb.Executor, listid string) error {
+	_, err := d.ExecContext(ctx, "DELETE FROM "+t.TableName+" WHERE listid = $1;", listid)
+	return err
+}
@@ -0,0 +1,401 @@
+package mailinglist
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/dbsql"
+	"xorkevin.dev/governor/service/events"
+	"xorkevin.dev/governor/service/mail"
+	"xorkevin.dev/governor/service/mailinglist/mailinglistmodel"
+	"xorkevin.dev/governor/service/objstore"
+	"xorkevin.dev/governor/util/rank"
+	"xorkevin.dev/kerrors"
+	"xorkevin.dev/klog"
+)
+
+const (
+	pendingReasonModerated = "moderated"
+	pendingReasonFirstPost = "firstpost"
+)
+
+const (
+	modNotifyBatchSize = 256
+)
+
+type (
+	heldProps struct {
+		ListID string `json:"listid"`
+		MsgID  string `json:"msgid"`
+	}
+
+	resPendingMsg struct {
+		ListID       string `json:"listid"`
+		Msgid        string `json:"msgid"`
+		Userid       string `json:"userid"`
+		SPFPass      string `json:"spf_pass"`
+		DKIMPass     string `json:"dkim_pass"`
+		Subject      string `json:"subject"`
+		InReplyTo    string `json:"in_reply_to"`
+		Reason       string `json:"reason"`
+		CreationTime int64  `json:"creation_time"`
+	}
+
+	resPendingMsgs struct {
+		Msgs []resPendingMsg `json:"msgs"`
+	}
+)
+
+func (s *Service) getListByName(ctx context.Context, creatorid string, listname string) (*mailinglistmodel.ListModel, error) {
+	m, err := s.lists.GetList(ctx, creatorid, listname)
+	if err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			return nil, governor.ErrWithRes(err, http.StatusNotFound, "", "List not found")
+		}
+		return nil, kerrors.WithMsg(err, "Failed to get list")
+	}
+	return m, nil
+}
+
+func (s *Service) getPendingMsg(ctx context.Context, listid, msgid string) (*mailinglistmodel.PendingModel, error) {
+	m, err := s.lists.GetPending(ctx, listid, msgid)
+	if err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			return nil, governor.ErrWithRes(err, http.StatusNotFound, "", "Pending msg not found")
+		}
+		return nil, kerrors.WithMsg(err, "Failed to get pending msg")
+	}
+	return m, nil
+}
+
+func (s *Service) getPendingMsgs(ctx context.Context, creatorid string, listname string, amount, offset int) (*resPendingMsgs, error) {
+	ml, err := s.getListByName(ctx, creatorid, listname)
+	if err != nil {
+		return nil, err
+	}
+	m, err := s.lists.GetListPending(ctx, ml.ListID, amount, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get pending msgs")
+	}
+	res := make([]resPendingMsg, 0, len(m))
+	for _, i := range m {
+		res = append(res, resPendingMsg{
+			ListID:       i.ListID,
+			Msgid:        i.Msgid,
+			Userid:       i.Userid,
+			SPFPass:      i.SPFPass,
+			DKIMPass:     i.DKIMPass,
+			Subject:      i.Subject,
+			InReplyTo:    i.InReplyTo,
+			Reason:       i.Reason,
+			CreationTime: i.CreationTime,
+		})
+	}
+	return &resPendingMsgs{
+		Msgs: res,
+	}, nil
+}
+
+func (s *Service) getPendingMsgContent(ctx context.Context, creatorid string, listname string, msgid string) (io.ReadCloser, string, error) {
+	ml, err := s.getListByName(ctx, creatorid, listname)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := s.getPendingMsg(ctx, ml.ListID, msgid); err != nil {
+		return nil, "", err
+	}
+	obj, objinfo, err := s.heldMailDir.Subdir(ml.ListID).Get(ctx, s.encodeMsgid(msgid))
+	if err != nil {
+		if errors.Is(err, objstore.ErrNotFound) {
+			return nil, "", governor.ErrWithRes(err, http.StatusNotFound, "", "Msg content not found")
+		}
+		return nil, "", kerrors.WithMsg(err, "Failed to get msg content")
+	}
+	return obj, objinfo.ContentType, nil
+}
+
+// releaseHeldMsg moves held msg content into the received mail dir
+func (s *Service) releaseHeldMsg(ctx context.Context, listid, msgid string) (retErr error) {
+	obj, objinfo, err := s.heldMailDir.Subdir(listid).Get(ctx, s.encodeMsgid(msgid))
+	if err != nil {
+		if errors.Is(err, objstore.ErrNotFound) {
+			return governor.ErrWithRes(err, http.StatusNotFound, "", "Msg content not found")
+		}
+		return kerrors.WithMsg(err, "Failed to get msg content")
+	}
+	defer func() {
+		if err := obj.Close(); err != nil {
+			retErr = errors.Join(retErr, kerrors.WithMsg(err, "Failed to close msg content"))
+		}
+	}()
+	if err := s.rcvMailDir.Subdir(listid).Put(ctx, s.encodeMsgid(msgid), objinfo.ContentType, objinfo.Size, nil, obj); err != nil {
+		return kerrors.WithMsg(err, "Failed to store mail msg")
+	}
+	return nil
+}
+
+func (s *Service) approvePendingMsg(ctx context.Context, creatorid string, listname string, msgid string, whitelist bool) error {
+	ml, err := s.getListByName(ctx, creatorid, listname)
+	if err != nil {
+		return err
+	}
+	m, err := s.getPendingMsg(ctx, ml.ListID, msgid)
+	if err != nil {
+		return err
+	}
+	if err := s.releaseHeldMsg(ctx, m.ListID, m.Msgid); err != nil {
+		return err
+	}
+	msg := s.lists.NewMsg(m.ListID, m.Msgid, m.Userid)
+	msg.SPFPass = m.SPFPass
+	msg.DKIMPass = m.DKIMPass
+	msg.Subject = m.Subject
+	msg.InReplyTo = m.InReplyTo
+	if err := s.lists.InsertMsg(ctx, msg); err != nil {
+		if !errors.Is(err, dbsql.ErrUnique) {
+			return kerrors.WithMsg(err, "Failed to add list msg")
+		}
+		// Message has already been approved, but not guaranteed to be sent yet,
+		// hence must continue with publishing the event.
+	}
+	b, err := encodeListEventMail(mailProps{
+		ListID: m.ListID,
+		MsgID:  m.Msgid,
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.events.Publish(ctx, events.NewMsgs(s.streammail, m.ListID, b)...); err != nil {
+		return kerrors.WithMsg(err, "Failed to publish list event")
+	}
+	if err := s.lists.MarkMsgProcessed(ctx, m.ListID, m.Msgid); err != nil {
+		return kerrors.WithMsg(err, "Failed to mark list message processed")
+	}
+	// approving a first post allows the sender to post without moderation
+	// from then on
+	if whitelist || m.Reason == pendingReasonFirstPost {
+		if err := s.lists.InsertApprovedSender(ctx, m.ListID, m.Userid); err != nil {
+			return kerrors.WithMsg(err, "Failed to approve list sender")
+		}
+	}
+	if err := s.deletePendingMsg(ctx, m.ListID, m.Msgid); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Service) deletePendingMsg(ctx context.Context, listid, msgid string) error {
+	if err := s.heldMailDir.Subdir(listid).Del(ctx, s.encodeMsgid(msgid)); err != nil {
+		if !errors.Is(err, objstore.ErrNotFound) {
+			return kerrors.WithMsg(err, "Failed to delete held msg content")
+		}
+	}
+	if err := s.lists.DeletePending(ctx, listid, msgid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete pending msg")
+	}
+	return nil
+}
+
+type (
+	emailModQueue struct {
+		ListID      string
+		Listname    string
+		ListAddress string
+		Msgid       string
+		Sender      string
+		Subject     string
+		Reason      string
+	}
+
+	emailModReject struct {
+		ListID      string
+		Listname    string
+		ListAddress string
+		Msgid       string
+		Subject     string
+		Reason      string
+	}
+)
+
+func (s *Service) rejectPendingMsg(ctx context.Context, creatorid string, listname string, msgid string, reason string) error {
+	ml, err := s.getListByName(ctx, creatorid, listname)
+	if err != nil {
+		return err
+	}
+	m, err := s.getPendingMsg(ctx, ml.ListID, msgid)
+	if err != nil {
+		return err
+	}
+	if err := s.deletePendingMsg(ctx, m.ListID, m.Msgid); err != nil {
+		return err
+	}
+
+	// must make best effort attempt to notify the sender
+	ctx = klog.ExtendCtx(context.Background(), ctx)
+	if err := s.sendModReject(ctx, ml, m, reason); err != nil {
+		s.log.Err(ctx, kerrors.WithMsg(err, "Failed to send rejection notification"))
+	}
+	return nil
+}
+
+func (s *Service) sendModReject(ctx context.Context, ml *mailinglistmodel.ListModel, m *mailinglistmodel.PendingModel, reason string) error {
+	sender, err := s.users.GetByID(ctx, m.Userid)
+	if err != nil {
+		return kerrors.WithMsg(err, "Failed to get sender user")
+	}
+	listaddr, err := s.listAddress(ctx, ml)
+	if err != nil {
+		return err
+	}
+	emdata := emailModReject{
+		ListID:      ml.ListID,
+		Listname:    ml.Name,
+		ListAddress: listaddr,
+		Msgid:       m.Msgid,
+		Subject:     m.Subject,
+		Reason:      reason,
+	}
+	rcpts, err := s.mailer.FilterSuppressed(ctx, []mail.Addr{{Address: sender.Email, Name: sender.FirstName}})
+	if err != nil {
+		return kerrors.WithMsg(err, "Failed to filter suppressed recipients")
+	}
+	if len(rcpts) == 0 {
+		return nil
+	}
+	if err := s.mailer.SendTpl(
+		ctx,
+		"",
+		mail.Addr{},
+		rcpts,
+		mail.TplLocal(s.tplname.modreject),
+		emdata,
+		false,
+	); err != nil {
+		return kerrors.WithMsg(err, "Failed to send rejection notification")
+	}
+	return nil
+}
+
+// listModerators returns a batch of the moderators of a list, which are the
+// owner of a user list or the mods of an org
+func (s *Service) listModerators(ctx context.Context, creatorid string, limit, offset int) ([]string, error) {
+	if !rank.IsValidOrgName(creatorid) {
+		if offset > 0 {
+			return nil, nil
+		}
+		return []string{creatorid}, nil
+	}
+	userids, err := s.users.GetRoleUsers(ctx, rank.ToModName(creatorid), limit, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to get org mods")
+	}
+	return userids, nil
+}
+
+func (s *Service) heldEventHandler(ctx context.Context, props heldProps) error {
+	ml, err := s.lists.GetListByID(ctx, props.ListID)
+	if err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			s.log.Err(ctx, kerrors.WithMsg(err, "List not found"))
+			return nil
+		}
+		return kerrors.WithMsg(err, "Failed to get list")
+	}
+	m, err := s.lists.GetPending(ctx, props.ListID, props.MsgID)
+	if err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			// msg has already been moderated
+			return nil
+		}
+		return kerrors.WithMsg(err, "Failed to get pending msg")
+	}
+	sender, err := s.users.GetByID(ctx, m.Userid)
+	if err != nil {
+		return kerrors.WithMsg(err, "Failed to get sender user")
+	}
+	listaddr, err := s.listAddress(ctx, ml)
+	if err != nil {
+		return err
+	}
+	emdata := emailModQueue{
+		ListID:      ml.ListID,
+		Listname:    ml.Name,
+		ListAddress: listaddr,
+		Msgid:       m.Msgid,
+		Sender:      sender.Username,
+		Subject:     m.Subject,
+		Reason:      m.Reason,
+	}
+	for offset := 0; ; offset += modNotifyBatchSize {
+		userids, err := s.listModerators(ctx, ml.CreatorID, modNotifyBatchSize, offset)
+		if err != nil {
+			return err
+		}
+		if len(userids) == 0 {
+			break
+		}
+		mods, err := s.users.GetInfoBulk(ctx, userids)
+		if err != nil {
+			return kerrors.WithMsg(err, "Failed to get list moderator users")
+		}
+		rcpts := make([]mail.Addr, 0, len(mods.Users))
+		for _, i := range mods.Users {
+			rcpts = append(rcpts, mail.Addr{
+				Address: i.Email,
+				Name:    i.FirstName,
+			})
+		}
+		rcpts, err = s.mailer.FilterSuppressed(ctx, rcpts)
+		if err != nil {
+			return kerrors.WithMsg(err, "Failed to filter suppressed recipients")
+		}
+		for _, i := range rcpts {
+			if err := s.mailer.SendTpl(
+				ctx,
+				"",
+				mail.Addr{},
+				[]mail.Addr{i},
+				mail.TplLocal(s.tplname.modqueue),
+				emdata,
+				false,
+			); err != nil {
+				// a failed notification must not cause mods that have already been
+				// notified to be notified again on redelivery
+				s.log.Err(ctx, kerrors.WithMsg(err, "Failed to send moderation notification"),
+					klog.AString("list.mod.addr", i.Address),
+				)
+			}
+		}
+		if len(userids) < modNotifyBatchSize {
+			break
+		}
+	}
+	return nil
+}
+
+func (s *Service) deleteListModeration(ctx context.Context, listid string) error {
+	for {
+		m, err := s.lists.GetListPending(ctx, listid, msgDeleteBatchSize, 0)
+		if err != nil {
+			return kerrors.WithMsg(err, "Failed to get pending msgs")
+		}
+		if len(m) == 0 {
+			break
+		}
+		for _, i := range m {
+			if err := s.deletePendingMsg(ctx, i.ListID, i.Msgid); err != nil {
+				return err
+			}
+		}
+		if len(m) < msgDeleteBatchSize {
+			break
+		}
+	}
+	if err := s.lists.DeleteListApprovedSenders(ctx, listid); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete approved list senders")
+	}
+	return nil
+}
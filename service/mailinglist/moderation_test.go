@@ -0,0 +1,309 @@
+package mailinglist
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/mail"
+	"xorkevin.dev/governor/service/mailinglist/mailinglistmodel"
+	"xorkevin.dev/governor/service/user/org"
+	"xorkevin.dev/governor/util/rank"
+)
+
+// kinds returns the kinds of the published list events
+func (e *testEvents) kinds(t *testing.T) []string {
+	t.Helper()
+	res := make([]string, 0, len(e.msgs))
+	for _, i := range e.msgs {
+		ev, err := decodeListEvent(i.Value)
+		require.NoError(t, err)
+		res = append(res, ev.Kind)
+	}
+	return res
+}
+
+func requireErrStatus(t *testing.T, status int, err error) {
+	t.Helper()
+	require.Error(t, err)
+	var errres *governor.ErrorRes
+	require.True(t, errors.As(err, &errres))
+	require.Equal(t, status, errres.Status)
+}
+
+func (s *testService) holdTestMsg(t *testing.T, ml *mailinglistmodel.ListModel, msgid, userid, reason string) {
+	t.Helper()
+	m := s.lists.NewPending(ml.ListID, msgid, userid, reason)
+	m.Subject = "Hello"
+	s.lists.pending[testKey(ml.ListID, msgid)] = m
+	require.NoError(t, s.heldMailDir.Subdir(ml.ListID).Put(context.Background(), s.encodeMsgid(msgid), "message/rfc822", 5, nil, strings.NewReader("hello")))
+}
+
+func TestApprovePendingMsg(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		Test      string
+		Reason    string
+		Whitelist bool
+		Approved  bool
+	}{
+		{
+			Test:   "moderated msg",
+			Reason: pendingReasonModerated,
+		},
+		{
+			Test:      "moderated msg with whitelist",
+			Reason:    pendingReasonModerated,
+			Whitelist: true,
+			Approved:  true,
+		},
+		{
+			Test:     "first post approves sender",
+			Reason:   pendingReasonFirstPost,
+			Approved: true,
+		},
+	} {
+		t.Run(tc.Test, func(t *testing.T) {
+			t.Parallel()
+
+			assert := require.New(t)
+
+			s := newTestService()
+			ml := s.addList("owner", "mylist")
+			s.holdTestMsg(t, ml, "msg@example.com", "sender", tc.Reason)
+
+			assert.NoError(s.approvePendingMsg(context.Background(), "owner", "mylist", "msg@example.com", tc.Whitelist))
+
+			msg, ok := s.lists.msgs[testKey(ml.ListID, "msg@example.com")]
+			assert.True(ok)
+			assert.Equal("sender", msg.Userid)
+			assert.Equal("Hello", msg.Subject)
+			assert.True(msg.Processed)
+			assert.Empty(s.lists.pending)
+			assert.Equal([]string{"rcvmail/" + ml.ListID + "/" + s.encodeMsgid("msg@example.com")}, s.dir.names())
+			assert.Equal([]string{listEventKindMail}, s.events.kinds(t))
+			ok, err := s.lists.IsApprovedSender(context.Background(), ml.ListID, "sender")
+			assert.NoError(err)
+			assert.Equal(tc.Approved, ok)
+		})
+	}
+
+	t.Run("not found", func(t *testing.T) {
+		t.Parallel()
+
+		s := newTestService()
+		s.addList("owner", "mylist")
+		requireErrStatus(t, http.StatusNotFound, s.approvePendingMsg(context.Background(), "owner", "mylist", "msg@example.com", false))
+	})
+}
+
+func TestRejectPendingMsg(t *testing.T) {
+	t.Parallel()
+
+	t.Run("rejects and notifies sender", func(t *testing.T) {
+		t.Parallel()
+
+		assert := require.New(t)
+
+		s := newTestService()
+		s.addUser("owner", "owner")
+		s.addUser("sender", "sender")
+		ml := s.addList("owner", "mylist")
+		s.holdTestMsg(t, ml, "msg@example.com", "sender", pendingReasonModerated)
+
+		assert.NoError(s.rejectPendingMsg(context.Background(), "owner", "mylist", "msg@example.com", "off topic"))
+
+		assert.Empty(s.lists.pending)
+		assert.Empty(s.lists.msgs)
+		assert.Empty(s.dir.names())
+		assert.Empty(s.events.msgs)
+		assert.Len(s.mailer.sent, 1)
+		assert.Equal([]string{"sender@example.com"}, s.mailer.sentTo())
+		assert.Equal(mail.TplLocal(s.tplname.modreject), s.mailer.sent[0].tpl)
+		emdata, ok := s.mailer.sent[0].emdata.(emailModReject)
+		assert.True(ok)
+		assert.Equal("owner.mylist@lists.example.com", emdata.ListAddress)
+		assert.Equal("off topic", emdata.Reason)
+	})
+
+	t.Run("notification failure does not fail rejection", func(t *testing.T) {
+		t.Parallel()
+
+		assert := require.New(t)
+
+		s := newTestService()
+		s.addUser("owner", "owner")
+		s.addUser("sender", "sender")
+		s.mailer.fail["sender@example.com"] = errors.New("Failed to send")
+		ml := s.addList("owner", "mylist")
+		s.holdTestMsg(t, ml, "msg@example.com", "sender", pendingReasonModerated)
+
+		assert.NoError(s.rejectPendingMsg(context.Background(), "owner", "mylist", "msg@example.com", "off topic"))
+		assert.Empty(s.lists.pending)
+		assert.Empty(s.mailer.sent)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		t.Parallel()
+
+		s := newTestService()
+		s.addList("owner", "mylist")
+		requireErrStatus(t, http.StatusNotFound, s.rejectPendingMsg(context.Background(), "owner", "mylist", "msg@example.com", ""))
+	})
+}
+
+func TestHoldReason(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		Test         string
+		SenderPolicy string
+		ModFirstPost bool
+		Sender       string
+		Approved     bool
+		Reason       string
+	}{
+		{
+			Test:         "unmoderated list",
+			SenderPolicy: listSenderPolicyMember,
+			Sender:       "sender",
+			Reason:       "",
+		},
+		{
+			Test:         "moderated list",
+			SenderPolicy: listSenderPolicyModerated,
+			Sender:       "sender",
+			Reason:       pendingReasonModerated,
+		},
+		{
+			Test:         "moderated list takes precedence over first post",
+			SenderPolicy: listSenderPolicyModerated,
+			ModFirstPost: true,
+			Sender:       "sender",
+			Reason:       pendingReasonModerated,
+		},
+		{
+			Test:         "first post",
+			SenderPolicy: listSenderPolicyMember,
+			ModFirstPost: true,
+			Sender:       "sender",
+			Reason:       pendingReasonFirstPost,
+		},
+		{
+			Test:         "owner is not moderated",
+			SenderPolicy: listSenderPolicyModerated,
+			Sender:       "owner",
+			Reason:       "",
+		},
+		{
+			Test:         "approved sender is not moderated",
+			SenderPolicy: listSenderPolicyMember,
+			ModFirstPost: true,
+			Sender:       "sender",
+			Approved:     true,
+			Reason:       "",
+		},
+	} {
+		t.Run(tc.Test, func(t *testing.T) {
+			t.Parallel()
+
+			assert := require.New(t)
+
+			s := newTestService()
+			ml := s.addList("owner", "mylist")
+			if tc.Approved {
+				assert.NoError(s.lists.InsertApprovedSender(context.Background(), ml.ListID, tc.Sender))
+			}
+			sess := &smtpSession{
+				service:      s.Service,
+				log:          s.log,
+				rcptList:     ml.ListID,
+				rcptOwner:    ml.CreatorID,
+				senderPolicy: tc.SenderPolicy,
+				modFirstPost: tc.ModFirstPost,
+			}
+			reason, err := sess.holdReason(context.Background(), tc.Sender)
+			assert.NoError(err)
+			assert.Equal(tc.Reason, reason)
+		})
+	}
+}
+
+func TestHoldMsg(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	s := newTestService()
+	ml := s.addList("owner", "mylist")
+	sess := &smtpSession{
+		service: s.Service,
+		log:     s.log,
+	}
+	msg := s.lists.NewMsg(ml.ListID, "msg@example.com", "sender")
+	msg.Subject = "Hello"
+
+	assert.NoError(sess.holdMsg(context.Background(), msg, pendingReasonFirstPost, "message/rfc822", []byte("hello")))
+
+	m, err := s.lists.GetPending(context.Background(), ml.ListID, "msg@example.com")
+	assert.NoError(err)
+	assert.Equal("sender", m.Userid)
+	assert.Equal("Hello", m.Subject)
+	assert.Equal(pendingReasonFirstPost, m.Reason)
+	assert.Empty(s.lists.msgs)
+	assert.Equal([]string{"heldmail/" + ml.ListID + "/" + s.encodeMsgid("msg@example.com")}, s.dir.names())
+	assert.Equal([]string{listEventKindHeld}, s.events.kinds(t))
+
+	// holding an already held msg is idempotent
+	assert.NoError(sess.holdMsg(context.Background(), msg, pendingReasonFirstPost, "message/rfc822", []byte("hello")))
+	assert.Len(s.lists.pending, 1)
+	assert.Len(s.events.msgs, 1)
+}
+
+func TestHeldEventHandler(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	s := newTestService()
+	s.addUser("sender", "sender")
+	s.addUser("mod1", "mod1")
+	s.addUser("mod2", "mod2")
+	s.addUser("mod3", "mod3")
+	s.orgs.orgs["myorg"] = &org.ResOrg{
+		OrgID: "myorg",
+		Name:  "myorg",
+	}
+	creatorid := rank.ToOrgName("myorg")
+	s.users.roles[rank.ToModName(creatorid)] = []string{"mod1", "mod2", "mod3"}
+	ml := s.addList(creatorid, "mylist")
+	s.holdTestMsg(t, ml, "msg@example.com", "sender", pendingReasonModerated)
+	s.mailer.fail["mod2@example.com"] = errors.New("Failed to send")
+
+	ctx := context.Background()
+	assert.NoError(s.heldEventHandler(ctx, heldProps{
+		ListID: ml.ListID,
+		MsgID:  "msg@example.com",
+	}))
+	assert.Equal([]string{"mod1@example.com", "mod3@example.com"}, s.mailer.sentTo())
+	for _, i := range s.mailer.sent {
+		assert.Equal(mail.TplLocal(s.tplname.modqueue), i.tpl)
+		emdata, ok := i.emdata.(emailModQueue)
+		assert.True(ok)
+		assert.Equal("myorg.mylist@org.lists.example.com", emdata.ListAddress)
+		assert.Equal("sender", emdata.Sender)
+	}
+
+	// already moderated msgs are skipped
+	delete(s.lists.pending, testKey(ml.ListID, "msg@example.com"))
+	assert.NoError(s.heldEventHandler(ctx, heldProps{
+		ListID: ml.ListID,
+		MsgID:  "msg@example.com",
+	}))
+	assert.Len(s.mailer.sent, 2)
+}
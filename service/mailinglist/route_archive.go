@@ -0,0 +1,66 @@
+package mailinglist
+
+import (
+	"net/http"
+
+	"xorkevin.dev/governor"
+	"xorkevin.dev/kerrors"
+)
+
+type (
+	//forge:valid
+	reqExportMbox struct {
+		CreatorID string `valid:"creatorID,has" json:"-"`
+		Listname  string `valid:"listname,has" json:"-"`
+		Threadid  string `valid:"msgid,opt" json:"-"`
+	}
+)
+
+func (s *router) exportMbox(c *governor.Context) {
+	req := reqExportMbox{
+		CreatorID: c.Param("creatorid"),
+		Listname:  c.Param("listname"),
+		Threadid:  c.Query("threadid"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	ml, err := s.s.getListByName(c.Ctx(), req.CreatorID, req.Listname)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.SetHeader("Content-Type", mediaTypeMbox)
+	c.WriteStatus(http.StatusOK)
+	if err := s.s.exportListMbox(c.Ctx(), ml, req.Threadid, c.Res()); err != nil {
+		// the response status has already been written
+		s.s.log.Err(c.Ctx(), kerrors.WithMsg(err, "Failed to export mbox"))
+		return
+	}
+}
+
+type (
+	//forge:valid
+	reqImportMbox struct {
+		CreatorID string `valid:"creatorID,has" json:"-"`
+		Listname  string `valid:"listname,has" json:"-"`
+	}
+)
+
+func (s *router) importMbox(c *governor.Context) {
+	req := reqImportMbox{
+		CreatorID: c.Param("creatorid"),
+		Listname:  c.Param("listname"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.importListMbox(c.Ctx(), req.CreatorID, req.Listname, c.Req().Body)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
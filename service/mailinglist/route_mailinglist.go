@@ -300,6 +300,7 @@ type (
 		Desc         string `valid:"desc" json:"desc"`
 		SenderPolicy string `valid:"senderPolicy" json:"sender_policy"`
 		MemberPolicy string `valid:"memberPolicy" json:"member_policy"`
+		ModFirstPost bool   `json:"mod_first_post"`
 	}
 )
 
@@ -314,7 +315,7 @@ func (s *router) createList(c *governor.Context) {
 		c.WriteError(err)
 		return
 	}
-	res, err := s.s.createList(c.Ctx(), req.CreatorID, req.Listname, req.Name, req.Desc, req.SenderPolicy, req.MemberPolicy)
+	res, err := s.s.createList(c.Ctx(), req.CreatorID, req.Listname, req.Name, req.Desc, req.SenderPolicy, req.MemberPolicy, req.ModFirstPost)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -332,6 +333,7 @@ type (
 		Archive      bool   `json:"archive"`
 		SenderPolicy string `valid:"senderPolicy" json:"sender_policy"`
 		MemberPolicy string `valid:"memberPolicy" json:"member_policy"`
+		ModFirstPost bool   `json:"mod_first_post"`
 	}
 )
 
@@ -347,7 +349,7 @@ func (s *router) updateList(c *governor.Context) {
 		c.WriteError(err)
 		return
 	}
-	if err := s.s.updateList(c.Ctx(), req.CreatorID, req.Listname, req.Name, req.Desc, req.Archive, req.SenderPolicy, req.MemberPolicy); err != nil {
+	if err := s.s.updateList(c.Ctx(), req.CreatorID, req.Listname, req.Name, req.Desc, req.Archive, req.SenderPolicy, req.MemberPolicy, req.ModFirstPost); err != nil {
 		c.WriteError(err)
 		return
 	}
@@ -397,6 +399,76 @@ func (s *router) unsubList(c *governor.Context) {
 	c.WriteStatus(http.StatusNoContent)
 }
 
+type (
+	//forge:valid
+	reqUnsubToken struct {
+		Token string `valid:"unsubToken,has" json:"-"`
+	}
+)
+
+func (s *router) unsubOneClick(c *governor.Context) {
+	req := reqUnsubToken{
+		Token: c.Param("token"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	if err := s.s.unsubOneClick(c.Ctx(), req.Token); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteStatus(http.StatusNoContent)
+}
+
+func (s *router) getSub(c *governor.Context) {
+	req := reqSub{
+		CreatorID: c.Param("creatorid"),
+		Listname:  c.Param("listname"),
+		Userid:    gate.GetCtxUserid(c),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getMember(c.Ctx(), req.CreatorID, req.Listname, req.Userid)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqSubDelivery struct {
+		CreatorID string `valid:"creatorID,has" json:"-"`
+		Listname  string `valid:"listname,has" json:"-"`
+		Userid    string `valid:"userid,has" json:"-"`
+		Delivery  string `valid:"delivery" json:"delivery"`
+	}
+)
+
+func (s *router) updateSubDelivery(c *governor.Context) {
+	var req reqSubDelivery
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
+	}
+	req.CreatorID = c.Param("creatorid")
+	req.Listname = c.Param("listname")
+	req.Userid = gate.GetCtxUserid(c)
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	if err := s.s.updateMemberDelivery(c.Ctx(), req.CreatorID, req.Listname, req.Userid, req.Delivery); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteStatus(http.StatusNoContent)
+}
+
 type (
 	//forge:valid
 	reqUpdListMembers struct {
@@ -513,9 +585,19 @@ func (s *router) mountRoutes(r governor.Router) {
 	m.PostCtx("/c/{creatorid}", s.createList, gate.MemberF(s.s.gate, s.listOwner, scopeMailinglistWrite), s.rt)
 	m.GetCtx("/c/{creatorid}/latest", s.getCreatorLists, s.rt)
 	m.GetCtx("/latest", s.getPersonalLists, gate.User(s.s.gate, scopeMailinglistRead), s.rt)
+	m.GetCtx("/search", s.searchMemberMsgs, gate.User(s.s.gate, scopeMailinglistRead), s.rt)
 	m.PutCtx("/c/{creatorid}/list/{listname}", s.updateList, gate.MemberF(s.s.gate, s.listOwner, scopeMailinglistWrite), s.rt)
 	m.PatchCtx("/c/{creatorid}/list/{listname}/sub", s.subList, gate.NoBanF(s.s.gate, s.listNoBan, scopeMailinglistSubWrite), s.rt)
 	m.PatchCtx("/c/{creatorid}/list/{listname}/unsub", s.unsubList, gate.User(s.s.gate, scopeMailinglistSubWrite), s.rt)
+	m.PostCtx("/unsub/{token}", s.unsubOneClick, s.rt)
+	m.GetCtx("/c/{creatorid}/list/{listname}/sub", s.getSub, gate.User(s.s.gate, scopeMailinglistRead), s.rt)
+	m.PatchCtx("/c/{creatorid}/list/{listname}/sub/delivery", s.updateSubDelivery, gate.User(s.s.gate, scopeMailinglistSubWrite), s.rt)
+	m.GetCtx("/c/{creatorid}/list/{listname}/mod/msgs", s.getPendingMsgs, gate.ModF(s.s.gate, s.listOwner, scopeMailinglistWrite), s.rt)
+	m.GetCtx("/c/{creatorid}/list/{listname}/mod/msgs/id/{msgid}/content", s.getPendingMsgContent, gate.ModF(s.s.gate, s.listOwner, scopeMailinglistWrite), s.rt)
+	m.PostCtx("/c/{creatorid}/list/{listname}/mod/msgs/id/{msgid}/approve", s.approvePendingMsg, gate.ModF(s.s.gate, s.listOwner, scopeMailinglistWrite), s.rt)
+	m.PostCtx("/c/{creatorid}/list/{listname}/mod/msgs/id/{msgid}/reject", s.rejectPendingMsg, gate.ModF(s.s.gate, s.listOwner, scopeMailinglistWrite), s.rt)
+	m.GetCtx("/c/{creatorid}/list/{listname}/mbox", s.exportMbox, gate.MemberF(s.s.gate, s.listOwner, scopeMailinglistRead), s.rt)
+	m.PostCtx("/c/{creatorid}/list/{listname}/mbox", s.importMbox, gate.System(s.s.gate, scopeMailinglistWrite), s.rt)
 	m.DeleteCtx("/c/{creatorid}/list/{listname}/msgs", s.deleteMsgs, gate.MemberF(s.s.gate, s.listOwner, scopeMailinglistWrite), s.rt)
 	m.PatchCtx("/c/{creatorid}/list/{listname}/member", s.updateListMembers, gate.MemberF(s.s.gate, s.listOwner, scopeMailinglistWrite), s.rt)
 	m.DeleteCtx("/c/{creatorid}/list/{listname}", s.deleteList, gate.MemberF(s.s.gate, s.listOwner, scopeMailinglistWrite), s.rt)
@@ -525,6 +607,7 @@ func (s *router) mountRoutes(r governor.Router) {
 	m.GetCtx("/l/{listid}/threads/id/{threadid}/msgs", s.getListThread, s.rt)
 	m.GetCtx("/l/{listid}/msgs/id/{msgid}", s.getListMsg, s.rt)
 	m.GetCtx("/l/{listid}/msgs/id/{msgid}/content", s.getListMsgContent, cachecontrol.ControlCtx(true, nil, 60, s.getListMsgCC), s.rt)
+	m.GetCtx("/l/{listid}/search", s.searchListMsgs, s.rt)
 	m.GetCtx("/l/{listid}/member", s.getListMembers, s.rt)
 	m.GetCtx("/l/{listid}/member/ids", s.getListMemberIDs, s.rt)
 }
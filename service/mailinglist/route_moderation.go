@@ -0,0 +1,146 @@
+package mailinglist
+
+import (
+	"net/http"
+	"net/url"
+
+	"xorkevin.dev/governor"
+	"xorkevin.dev/kerrors"
+)
+
+type (
+	//forge:valid
+	reqPendingMsgs struct {
+		CreatorID string `valid:"creatorID,has" json:"-"`
+		Listname  string `valid:"listname,has" json:"-"`
+		Amount    int    `valid:"amount" json:"-"`
+		Offset    int    `valid:"offset" json:"-"`
+	}
+)
+
+func (s *router) getPendingMsgs(c *governor.Context) {
+	req := reqPendingMsgs{
+		CreatorID: c.Param("creatorid"),
+		Listname:  c.Param("listname"),
+		Amount:    c.QueryInt("amount", -1),
+		Offset:    c.QueryInt("offset", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.getPendingMsgs(c.Ctx(), req.CreatorID, req.Listname, req.Amount, req.Offset)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqPendingMsg struct {
+		CreatorID string `valid:"creatorID,has" json:"-"`
+		Listname  string `valid:"listname,has" json:"-"`
+		Msgid     string `valid:"msgid,has" json:"-"`
+	}
+)
+
+func (s *router) getPendingMsgContent(c *governor.Context) {
+	msgid, err := url.QueryUnescape(c.Param("msgid"))
+	if err != nil {
+		c.WriteError(governor.ErrWithRes(err, http.StatusBadRequest, "", "Invalid msg id"))
+		return
+	}
+	req := reqPendingMsg{
+		CreatorID: c.Param("creatorid"),
+		Listname:  c.Param("listname"),
+		Msgid:     msgid,
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+
+	msg, contentType, err := s.s.getPendingMsgContent(c.Ctx(), req.CreatorID, req.Listname, req.Msgid)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	defer func() {
+		if err := msg.Close(); err != nil {
+			s.s.log.Err(c.Ctx(), kerrors.WithMsg(err, "Failed to close msg content"))
+		}
+	}()
+	c.WriteFile(http.StatusOK, contentType, msg)
+}
+
+type (
+	//forge:valid
+	reqApprovePendingMsg struct {
+		CreatorID string `valid:"creatorID,has" json:"-"`
+		Listname  string `valid:"listname,has" json:"-"`
+		Msgid     string `valid:"msgid,has" json:"-"`
+		Whitelist bool   `json:"whitelist"`
+	}
+)
+
+func (s *router) approvePendingMsg(c *governor.Context) {
+	msgid, err := url.QueryUnescape(c.Param("msgid"))
+	if err != nil {
+		c.WriteError(governor.ErrWithRes(err, http.StatusBadRequest, "", "Invalid msg id"))
+		return
+	}
+	var req reqApprovePendingMsg
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
+	}
+	req.CreatorID = c.Param("creatorid")
+	req.Listname = c.Param("listname")
+	req.Msgid = msgid
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	if err := s.s.approvePendingMsg(c.Ctx(), req.CreatorID, req.Listname, req.Msgid, req.Whitelist); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteStatus(http.StatusNoContent)
+}
+
+type (
+	//forge:valid
+	reqRejectPendingMsg struct {
+		CreatorID string `valid:"creatorID,has" json:"-"`
+		Listname  string `valid:"listname,has" json:"-"`
+		Msgid     string `valid:"msgid,has" json:"-"`
+		Reason    string `valid:"reason" json:"reason"`
+	}
+)
+
+func (s *router) rejectPendingMsg(c *governor.Context) {
+	msgid, err := url.QueryUnescape(c.Param("msgid"))
+	if err != nil {
+		c.WriteError(governor.ErrWithRes(err, http.StatusBadRequest, "", "Invalid msg id"))
+		return
+	}
+	var req reqRejectPendingMsg
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
+	}
+	req.CreatorID = c.Param("creatorid")
+	req.Listname = c.Param("listname")
+	req.Msgid = msgid
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	if err := s.s.rejectPendingMsg(c.Ctx(), req.CreatorID, req.Listname, req.Msgid, req.Reason); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteStatus(http.StatusNoContent)
+}
@@ -0,0 +1,66 @@
+package mailinglist
+
+import (
+	"net/http"
+
+	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/user/gate"
+)
+
+type (
+	//forge:valid
+	reqSearchListMsgs struct {
+		Listid string `valid:"listid,has" json:"-"`
+		Query  string `valid:"query,has" json:"-"`
+		Amount int    `valid:"amount" json:"-"`
+		Offset int    `valid:"offset" json:"-"`
+	}
+)
+
+func (s *router) searchListMsgs(c *governor.Context) {
+	req := reqSearchListMsgs{
+		Listid: c.Param("listid"),
+		Query:  c.Query("q"),
+		Amount: c.QueryInt("amount", -1),
+		Offset: c.QueryInt("offset", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.searchListMsgs(c.Ctx(), req.Listid, req.Query, req.Amount, req.Offset)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	//forge:valid
+	reqSearchMemberMsgs struct {
+		Userid string `valid:"userid,has" json:"-"`
+		Query  string `valid:"query,has" json:"-"`
+		Amount int    `valid:"amount" json:"-"`
+		Offset int    `valid:"offset" json:"-"`
+	}
+)
+
+func (s *router) searchMemberMsgs(c *governor.Context) {
+	req := reqSearchMemberMsgs{
+		Userid: gate.GetCtxUserid(c),
+		Query:  c.Query("q"),
+		Amount: c.QueryInt("amount", -1),
+		Offset: c.QueryInt("offset", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := s.s.searchMemberMsgs(c.Ctx(), req.Userid, req.Query, req.Amount, req.Offset)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
@@ -0,0 +1,216 @@
+package mailinglist
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/emersion/go-message"
+	emmail "github.com/emersion/go-message/mail"
+	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/dbsql"
+	"xorkevin.dev/kerrors"
+)
+
+const (
+	// searchBodyCap limits the amount of msg body text that is indexed
+	searchBodyCap = 65536
+	// searchFromCap is the length of the search model from_addr column
+	searchFromCap = 511
+
+	mediaTypeTextHTML     = "text/html"
+	dispositionAttachment = "attachment"
+)
+
+type (
+	indexProps struct {
+		ListID string `json:"listid"`
+		MsgID  string `json:"msgid"`
+	}
+
+	resSearchMsg struct {
+		ListID       string `json:"listid"`
+		Msgid        string `json:"msgid"`
+		Userid       string `json:"userid"`
+		CreationTime int64  `json:"creation_time"`
+		From         string `json:"from"`
+		Subject      string `json:"subject"`
+		Snippet      string `json:"snippet"`
+	}
+
+	resSearchMsgs struct {
+		Msgs []resSearchMsg `json:"msgs"`
+	}
+)
+
+// truncateText returns at most n bytes of valid utf-8 from s
+func truncateText(s string, n int) string {
+	s = strings.ReplaceAll(s, "\x00", "")
+	if len(s) > n {
+		s = s[:n]
+	}
+	return strings.ToValidUTF8(s, "")
+}
+
+// stripHTML returns the text content of an html document
+func stripHTML(s string) string {
+	var b strings.Builder
+	inTag := false
+	for _, c := range s {
+		switch {
+		case c == '<':
+			inTag = true
+		case c == '>' && inTag:
+			inTag = false
+			b.WriteByte(' ')
+		case !inTag:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// extractMsgText returns the from address, subject, and body text of a msg.
+// The body text is taken from all inline text/plain parts, or from the
+// text/html parts if there are no text/plain parts.
+func extractMsgText(msg io.Reader) (string, string, string, error) {
+	m, err := message.Read(msg)
+	if err != nil && !message.IsUnknownCharset(err) && !message.IsUnknownEncoding(err) {
+		return "", "", "", kerrors.WithMsg(err, "Failed to parse msg")
+	}
+	headers := emmail.Header{
+		Header: m.Header,
+	}
+	from := headers.Get(headerFrom)
+	if fromAddrs, err := headers.AddressList(headerFrom); err == nil && len(fromAddrs) > 0 {
+		from = fromAddrs[0].String()
+	}
+	subject, _ := headers.Subject()
+
+	var plain, html bytes.Buffer
+	if err := m.Walk(func(path []int, entity *message.Entity, err error) error {
+		if err != nil && !message.IsUnknownCharset(err) && !message.IsUnknownEncoding(err) {
+			return err
+		}
+		if entity.MultipartReader() != nil {
+			return nil
+		}
+		if disp, _, err := entity.Header.ContentDisposition(); err == nil && disp == dispositionAttachment {
+			return nil
+		}
+		mediaType, _, err := entity.Header.ContentType()
+		if err != nil {
+			return nil
+		}
+		var w *bytes.Buffer
+		switch mediaType {
+		case mediaTypeTextPlain:
+			w = &plain
+		case mediaTypeTextHTML:
+			w = &html
+		default:
+			return nil
+		}
+		if w.Len() >= searchBodyCap {
+			return nil
+		}
+		if _, err := io.Copy(w, io.LimitReader(entity.Body, int64(searchBodyCap-w.Len()))); err != nil {
+			return kerrors.WithMsg(err, "Failed to read msg part")
+		}
+		w.WriteByte('\n')
+		return nil
+	}); err != nil {
+		return "", "", "", kerrors.WithMsg(err, "Failed to read msg parts")
+	}
+	body := plain.String()
+	if plain.Len() == 0 {
+		body = stripHTML(html.String())
+	}
+	return truncateText(from, searchFromCap), truncateText(subject, maxSubjectLength), truncateText(body, searchBodyCap), nil
+}
+
+func (s *Service) indexEventHandler(ctx context.Context, props indexProps) (retErr error) {
+	m, err := s.lists.GetMsg(ctx, props.ListID, props.MsgID)
+	if err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			// msg has been deleted
+			return nil
+		}
+		return kerrors.WithMsg(err, "Failed to get list msg")
+	}
+	if m.Deleted {
+		return nil
+	}
+	obj, _, err := s.rcvMailDir.Subdir(m.ListID).Get(ctx, s.encodeMsgid(m.Msgid))
+	if err != nil {
+		return kerrors.WithMsg(err, "Failed to get msg content")
+	}
+	defer func() {
+		if err := obj.Close(); err != nil {
+			retErr = errors.Join(retErr, kerrors.WithMsg(err, "Failed to close msg content"))
+		}
+	}()
+	from, subject, body, err := extractMsgText(obj)
+	if err != nil {
+		// a malformed msg is still searchable by its subject
+		s.log.WarnErr(ctx, kerrors.WithMsg(err, "Failed to extract msg text"))
+		subject = m.Subject
+	}
+	if err := s.lists.InsertSearch(ctx, s.lists.NewSearch(m.ListID, m.Msgid, m.Userid, m.CreationTime, from, subject, body)); err != nil {
+		return kerrors.WithMsg(err, "Failed to index list msg")
+	}
+	return nil
+}
+
+func (s *Service) searchListMsgs(ctx context.Context, listid string, query string, amount, offset int) (*resSearchMsgs, error) {
+	if _, err := s.lists.GetListByID(ctx, listid); err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			return nil, governor.ErrWithRes(err, http.StatusNotFound, "", "List not found")
+		}
+		return nil, kerrors.WithMsg(err, "Failed to get list")
+	}
+	m, err := s.lists.SearchListMsgs(ctx, listid, query, amount, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to search list msgs")
+	}
+	res := make([]resSearchMsg, 0, len(m))
+	for _, i := range m {
+		res = append(res, resSearchMsg{
+			ListID:       i.ListID,
+			Msgid:        i.Msgid,
+			Userid:       i.Userid,
+			CreationTime: i.CreationTime,
+			From:         i.FromAddr,
+			Subject:      i.Subject,
+			Snippet:      i.Snippet,
+		})
+	}
+	return &resSearchMsgs{
+		Msgs: res,
+	}, nil
+}
+
+func (s *Service) searchMemberMsgs(ctx context.Context, userid string, query string, amount, offset int) (*resSearchMsgs, error) {
+	m, err := s.lists.SearchMemberMsgs(ctx, userid, query, amount, offset)
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to search list msgs")
+	}
+	res := make([]resSearchMsg, 0, len(m))
+	for _, i := range m {
+		res = append(res, resSearchMsg{
+			ListID:       i.ListID,
+			Msgid:        i.Msgid,
+			Userid:       i.Userid,
+			CreationTime: i.CreationTime,
+			From:         i.FromAddr,
+			Subject:      i.Subject,
+			Snippet:      i.Snippet,
+		})
+	}
+	return &resSearchMsgs{
+		Msgs: res,
+	}, nil
+}
@@ -0,0 +1,129 @@
+package mailinglist
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractMsgText(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		Test    string
+		Msg     string
+		From    string
+		Subject string
+		Body    string
+		Err     bool
+	}{
+		{
+			Test: "plain text msg",
+			Msg: strings.Join([]string{
+				"From: Alice <alice@example.com>",
+				"Subject: Hello world",
+				"Content-Type: text/plain; charset=utf-8",
+				"",
+				"hello there",
+			}, "\r\n"),
+			From:    `"Alice" <alice@example.com>`,
+			Subject: "Hello world",
+			Body:    "hello there\n",
+		},
+		{
+			Test: "prefers plain text over html",
+			Msg: strings.Join([]string{
+				"From: alice@example.com",
+				"Subject: Alternatives",
+				`Content-Type: multipart/alternative; boundary="b"`,
+				"",
+				"--b",
+				"Content-Type: text/plain",
+				"",
+				"plain text",
+				"--b",
+				"Content-Type: text/html",
+				"",
+				"<p>html text</p>",
+				"--b--",
+			}, "\r\n"),
+			From:    "<alice@example.com>",
+			Subject: "Alternatives",
+			Body:    "plain text\n",
+		},
+		{
+			Test: "falls back to stripped html",
+			Msg: strings.Join([]string{
+				"From: alice@example.com",
+				"Subject: Html",
+				"Content-Type: text/html",
+				"",
+				"<p>html <b>text</b></p>",
+			}, "\r\n"),
+			From:    "<alice@example.com>",
+			Subject: "Html",
+			Body:    " html  text  \n",
+		},
+		{
+			Test: "skips attachments",
+			Msg: strings.Join([]string{
+				"From: alice@example.com",
+				"Subject: Attachment",
+				`Content-Type: multipart/mixed; boundary="b"`,
+				"",
+				"--b",
+				"Content-Type: text/plain",
+				"",
+				"inline text",
+				"--b",
+				"Content-Type: text/plain",
+				`Content-Disposition: attachment; filename="notes.txt"`,
+				"",
+				"attached text",
+				"--b--",
+			}, "\r\n"),
+			From:    "<alice@example.com>",
+			Subject: "Attachment",
+			Body:    "inline text\n",
+		},
+		{
+			Test: "truncates long body",
+			Msg: strings.Join([]string{
+				"From: alice@example.com",
+				"Subject: Long",
+				"Content-Type: text/plain",
+				"",
+				strings.Repeat("a", searchBodyCap*2),
+			}, "\r\n"),
+			From:    "<alice@example.com>",
+			Subject: "Long",
+			Body:    strings.Repeat("a", searchBodyCap),
+		},
+		{
+			Test: "malformed header",
+			Msg: strings.Join([]string{
+				"From alice@example.com",
+				"",
+				"body",
+			}, "\r\n"),
+			Err: true,
+		},
+	} {
+		t.Run(tc.Test, func(t *testing.T) {
+			t.Parallel()
+
+			assert := require.New(t)
+
+			from, subject, body, err := extractMsgText(strings.NewReader(tc.Msg))
+			if tc.Err {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tc.From, from)
+			assert.Equal(tc.Subject, subject)
+			assert.Equal(tc.Body, body)
+		})
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"xorkevin.dev/governor"
 	"xorkevin.dev/governor/service/dbsql"
 	"xorkevin.dev/governor/service/events"
+	"xorkevin.dev/governor/service/mailinglist/mailinglistmodel"
 	"xorkevin.dev/governor/service/objstore"
 	"xorkevin.dev/governor/service/user/gate"
 	"xorkevin.dev/governor/util/rank"
@@ -27,13 +28,14 @@ type (
 		Archive      bool   `json:"archive"`
 		SenderPolicy string `json:"sender_policy"`
 		MemberPolicy string `json:"member_policy"`
+		ModFirstPost bool   `json:"mod_first_post"`
 		LastUpdated  int64  `json:"last_updated"`
 		CreationTime int64  `json:"creation_time"`
 	}
 )
 
-func (s *Service) createList(ctx context.Context, creatorid string, listname string, name, desc string, senderPolicy, memberPolicy string) (*resList, error) {
-	list := s.lists.NewList(creatorid, listname, name, desc, senderPolicy, memberPolicy)
+func (s *Service) createList(ctx context.Context, creatorid string, listname string, name, desc string, senderPolicy, memberPolicy string, modFirstPost bool) (*resList, error) {
+	list := s.lists.NewList(creatorid, listname, name, desc, senderPolicy, memberPolicy, modFirstPost)
 	if err := s.lists.InsertList(ctx, list); err != nil {
 		if errors.Is(err, dbsql.ErrUnique) {
 			return nil, governor.ErrWithRes(err, http.StatusBadRequest, "", "List id already taken")
@@ -49,12 +51,13 @@ func (s *Service) createList(ctx context.Context, creatorid string, listname str
 		Archive:      list.Archive,
 		SenderPolicy: list.SenderPolicy,
 		MemberPolicy: list.MemberPolicy,
+		ModFirstPost: list.ModFirstPost,
 		LastUpdated:  list.LastUpdated,
 		CreationTime: list.CreationTime,
 	}, nil
 }
 
-func (s *Service) updateList(ctx context.Context, creatorid string, listname string, name, desc string, archive bool, senderPolicy, memberPolicy string) error {
+func (s *Service) updateList(ctx context.Context, creatorid string, listname string, name, desc string, archive bool, senderPolicy, memberPolicy string, modFirstPost bool) error {
 	m, err := s.lists.GetList(ctx, creatorid, listname)
 	if err != nil {
 		if errors.Is(err, dbsql.ErrNotFound) {
@@ -67,6 +70,7 @@ func (s *Service) updateList(ctx context.Context, creatorid string, listname str
 	m.Archive = archive
 	m.SenderPolicy = senderPolicy
 	m.MemberPolicy = memberPolicy
+	m.ModFirstPost = modFirstPost
 	if err := s.lists.UpdateList(ctx, m); err != nil {
 		return kerrors.WithMsg(err, "Failed to update list")
 	}
@@ -92,23 +96,10 @@ func (s *Service) subscribe(ctx context.Context, creatorid string, listname stri
 		}
 		return kerrors.WithMsg(err, "Failed to get list")
 	}
-	isOrg := rank.IsValidOrgName(creatorid)
-	switch m.MemberPolicy {
-	case listMemberPolicyOwner:
-		if isOrg {
-			if ok, err := gate.AuthMember(ctx, s.gate, userid, creatorid); err != nil {
-				return kerrors.WithMsg(err, "Failed to get user membership")
-			} else if !ok {
-				return governor.ErrWithRes(nil, http.StatusForbidden, "", "Not the list owner")
-			}
-		} else {
-			if userid != creatorid {
-				return governor.ErrWithRes(nil, http.StatusForbidden, "", "Not the list owner")
-			}
-		}
-	case listMemberPolicyUser:
-	default:
-		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Invalid list member policy")
+	if ok, err := s.checkMemberPolicy(ctx, m, userid); err != nil {
+		return err
+	} else if !ok {
+		return governor.ErrWithRes(nil, http.StatusForbidden, "", "Not the list owner")
 	}
 	if members, err := s.lists.GetListMembers(ctx, m.ListID, []string{userid}); err != nil {
 		return kerrors.WithMsg(err, "Failed to get list members")
@@ -130,6 +121,25 @@ func (s *Service) subscribe(ctx context.Context, creatorid string, listname stri
 	return nil
 }
 
+// checkMemberPolicy returns whether a user may subscribe to a list
+func (s *Service) checkMemberPolicy(ctx context.Context, m *mailinglistmodel.ListModel, userid string) (bool, error) {
+	switch m.MemberPolicy {
+	case listMemberPolicyOwner:
+		if rank.IsValidOrgName(m.CreatorID) {
+			ok, err := gate.AuthMember(ctx, s.gate, userid, m.CreatorID)
+			if err != nil {
+				return false, kerrors.WithMsg(err, "Failed to get user membership")
+			}
+			return ok, nil
+		}
+		return userid == m.CreatorID, nil
+	case listMemberPolicyUser:
+		return true, nil
+	default:
+		return false, governor.ErrWithRes(nil, http.StatusBadRequest, "", "Invalid list member policy")
+	}
+}
+
 func (s *Service) removeListMembers(ctx context.Context, creatorid string, listname string, userids []string) error {
 	m, err := s.lists.GetList(ctx, creatorid, listname)
 	if err != nil {
@@ -149,6 +159,62 @@ func (s *Service) removeListMembers(ctx context.Context, creatorid string, listn
 	return nil
 }
 
+type (
+	resMember struct {
+		ListID      string `json:"listid"`
+		Userid      string `json:"userid"`
+		Delivery    string `json:"delivery"`
+		LastUpdated int64  `json:"last_updated"`
+	}
+)
+
+func (s *Service) getMember(ctx context.Context, creatorid string, listname string, userid string) (*resMember, error) {
+	m, err := s.lists.GetList(ctx, creatorid, listname)
+	if err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			return nil, governor.ErrWithRes(err, http.StatusNotFound, "", "List not found")
+		}
+		return nil, kerrors.WithMsg(err, "Failed to get list")
+	}
+	member, err := s.lists.GetMember(ctx, m.ListID, userid)
+	if err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			return nil, governor.ErrWithRes(err, http.StatusNotFound, "", "List member does not exist")
+		}
+		return nil, kerrors.WithMsg(err, "Failed to get list member")
+	}
+	return &resMember{
+		ListID:      member.ListID,
+		Userid:      member.Userid,
+		Delivery:    member.Delivery,
+		LastUpdated: member.LastUpdated,
+	}, nil
+}
+
+func (s *Service) updateMemberDelivery(ctx context.Context, creatorid string, listname string, userid string, delivery string) error {
+	m, err := s.lists.GetList(ctx, creatorid, listname)
+	if err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			return governor.ErrWithRes(err, http.StatusNotFound, "", "List not found")
+		}
+		return kerrors.WithMsg(err, "Failed to get list")
+	}
+	return s.setMemberDelivery(ctx, m.ListID, userid, delivery)
+}
+
+func (s *Service) setMemberDelivery(ctx context.Context, listid string, userid string, delivery string) error {
+	if _, err := s.lists.GetMember(ctx, listid, userid); err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			return governor.ErrWithRes(err, http.StatusNotFound, "", "List member does not exist")
+		}
+		return kerrors.WithMsg(err, "Failed to get list member")
+	}
+	if err := s.lists.UpdateMemberDelivery(ctx, listid, userid, delivery); err != nil {
+		return kerrors.WithMsg(err, "Failed to update list member delivery")
+	}
+	return nil
+}
+
 func (s *Service) deleteList(ctx context.Context, creatorid string, listname string) error {
 	m, err := s.lists.GetList(ctx, creatorid, listname)
 	if err != nil {
@@ -189,6 +255,7 @@ func (s *Service) getList(ctx context.Context, listid string) (*resList, error)
 		Archive:      m.Archive,
 		SenderPolicy: m.SenderPolicy,
 		MemberPolicy: m.MemberPolicy,
+		ModFirstPost: m.ModFirstPost,
 		LastUpdated:  m.LastUpdated,
 		CreationTime: m.CreationTime,
 	}, nil
@@ -262,6 +329,7 @@ func (s *Service) getLists(ctx context.Context, listids []string) (*resLists, er
 			Archive:      i.Archive,
 			SenderPolicy: i.SenderPolicy,
 			MemberPolicy: i.MemberPolicy,
+			ModFirstPost: i.ModFirstPost,
 			LastUpdated:  i.LastUpdated,
 			CreationTime: i.CreationTime,
 		})
@@ -287,6 +355,7 @@ func (s *Service) getCreatorLists(ctx context.Context, creatorid string, amount,
 			Archive:      i.Archive,
 			SenderPolicy: i.SenderPolicy,
 			MemberPolicy: i.MemberPolicy,
+			ModFirstPost: i.ModFirstPost,
 			LastUpdated:  i.LastUpdated,
 			CreationTime: i.CreationTime,
 		})
@@ -330,6 +399,9 @@ func (s *Service) deleteMsgs(ctx context.Context, creatorid string, listname str
 	if err := s.lists.DeleteSentMsgLogs(ctx, m.ListID, msgids); err != nil {
 		return kerrors.WithMsg(err, "Failed to delete sent message logs")
 	}
+	if err := s.lists.DeleteSearchMsgs(ctx, m.ListID, msgids); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete message search index")
+	}
 	if err := s.lists.DeleteMsgs(ctx, m.ListID, msgids); err != nil {
 		return kerrors.WithMsg(err, "Failed to delete messages")
 	}
@@ -528,6 +600,18 @@ func (s *Service) deleteEventHandler(ctx context.Context, props delProps) error
 	if err := s.lists.DeleteListTrees(ctx, props.ListID); err != nil {
 		return kerrors.WithMsg(err, "Failed to delete list trees")
 	}
+	if err := s.lists.DeleteListDigests(ctx, props.ListID); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete list digests")
+	}
+	if err := s.deleteListModeration(ctx, props.ListID); err != nil {
+		return err
+	}
+	if err := s.lists.DeleteListCmds(ctx, props.ListID); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete list commands")
+	}
+	if err := s.lists.DeleteListSearch(ctx, props.ListID); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete list search index")
+	}
 
 	for {
 		msgs, err := s.lists.GetListMsgs(ctx, props.ListID, msgDeleteBatchSize, 0)
@@ -567,6 +651,13 @@ func (s *Service) mailEventHandler(ctx context.Context, props mailProps) error {
 	if err != nil {
 		return err
 	}
+	bi, err := encodeListEventIndex(indexProps{
+		ListID: props.ListID,
+		MsgID:  props.MsgID,
+	})
+	if err != nil {
+		return err
+	}
 
 	ml, err := s.lists.GetListByID(ctx, props.ListID)
 	if err != nil {
@@ -589,6 +680,18 @@ func (s *Service) mailEventHandler(ctx context.Context, props mailProps) error {
 			return kerrors.WithMsg(err, "Failed to mark list msg")
 		}
 	}
+	if err := s.threadListMsg(ctx, ml, m); err != nil {
+		return err
+	}
+
+	if err := s.events.Publish(ctx, events.NewMsgs(s.streammail, props.ListID, b, bi)...); err != nil {
+		return kerrors.WithMsg(err, "Failed to publish mail send event")
+	}
+	return nil
+}
+
+// threadListMsg adds a list msg to the thread tree of its parent
+func (s *Service) threadListMsg(ctx context.Context, ml *mailinglistmodel.ListModel, m *mailinglistmodel.MsgModel) error {
 	// In a closure table, every node must also point to itself with depth 0, so
 	// insert a node that does that.
 	if err := s.lists.InsertTree(ctx, s.lists.NewTree(m.ListID, m.Msgid, m.CreationTime)); err != nil {
@@ -645,10 +748,6 @@ func (s *Service) mailEventHandler(ctx context.Context, props mailProps) error {
 			return kerrors.WithMsg(err, "Failed to update list last updated")
 		}
 	}
-
-	if err := s.events.Publish(ctx, events.NewMsgs(s.streammail, props.ListID, b)...); err != nil {
-		return kerrors.WithMsg(err, "Failed to publish mail send event")
-	}
 	return nil
 }
 
@@ -657,7 +756,8 @@ const (
 )
 
 func (s *Service) sendEventHandler(ctx context.Context, props sendProps) error {
-	if _, err := s.lists.GetListByID(ctx, props.ListID); err != nil {
+	ml, err := s.lists.GetListByID(ctx, props.ListID)
+	if err != nil {
 		if errors.Is(err, dbsql.ErrNotFound) {
 			s.log.Err(ctx, kerrors.WithMsg(err, "List not found"))
 			return nil
@@ -708,29 +808,21 @@ func (s *Service) sendEventHandler(ctx context.Context, props sendProps) error {
 		return err
 	}
 
+	listaddr, err := s.listAddress(ctx, ml)
+	if err != nil {
+		return err
+	}
+
 	for {
-		userids, err := s.lists.GetUnsentMsgs(ctx, props.ListID, props.MsgID, mailingListSendBatchSize)
+		userids, err := s.lists.GetUnsentMsgs(ctx, props.ListID, props.MsgID, mailinglistmodel.MemberDeliveryImmediate, mailingListSendBatchSize)
 		if err != nil {
 			return kerrors.WithMsg(err, "Failed to get unsent messages")
 		}
 		if len(userids) == 0 {
 			break
 		}
-		recipients, err := s.users.GetInfoBulk(ctx, userids)
-		if err != nil {
-			return kerrors.WithMsg(err, "Failed to get list member users")
-		}
-		if len(recipients.Users) > 0 {
-			rcpts := make([]string, 0, len(recipients.Users))
-			for _, i := range recipients.Users {
-				rcpts = append(rcpts, i.Email)
-			}
-			if err := s.mailer.FwdStream(ctx, "", rcpts, int64(mb.Len()), bytes.NewReader(mb.Bytes()), false); err != nil {
-				return kerrors.WithMsg(err, "Failed to send mail message")
-			}
-		}
-		if err := s.lists.LogSentMsg(ctx, props.ListID, props.MsgID, userids); err != nil {
-			return kerrors.WithMsg(err, "Failed to log sent mail messages")
+		if err := s.fwdListMsg(ctx, ml, listaddr, props.MsgID, mb.Bytes(), userids, true); err != nil {
+			return err
 		}
 		if len(userids) < mailingListSendBatchSize {
 			break
@@ -22,6 +22,9 @@ import (
 	"github.com/emersion/go-smtp"
 	"xorkevin.dev/governor/service/dbsql"
 	"xorkevin.dev/governor/service/events"
+	"xorkevin.dev/governor/service/mail"
+	"xorkevin.dev/governor/service/mailinglist/mailinglistmodel"
+	"xorkevin.dev/governor/service/ratelimit"
 	"xorkevin.dev/governor/service/user"
 	"xorkevin.dev/governor/service/user/gate"
 	"xorkevin.dev/governor/service/user/org"
@@ -86,6 +89,26 @@ var (
 		EnhancedCode: smtp.EnhancedCode{5, 7, 2},
 		Message:      "Unauthorized to send to this mailing list",
 	}
+	errSMTPAuthRequired = &smtp.SMTPError{
+		Code:         530,
+		EnhancedCode: smtp.EnhancedCode{5, 7, 0},
+		Message:      "Authentication required",
+	}
+	errSMTPAuthCreds = &smtp.SMTPError{
+		Code:         535,
+		EnhancedCode: smtp.EnhancedCode{5, 7, 8},
+		Message:      "Invalid authentication credentials",
+	}
+	errSMTPAuthSender = &smtp.SMTPError{
+		Code:         550,
+		EnhancedCode: smtp.EnhancedCode{5, 7, 1},
+		Message:      "From address does not belong to the authenticated user",
+	}
+	errSMTPRatelimit = &smtp.SMTPError{
+		Code:         451,
+		EnhancedCode: smtp.EnhancedCode{4, 7, 1},
+		Message:      "Rate limit exceeded",
+	}
 	errSMTPSeq = &smtp.SMTPError{
 		Code:         503,
 		EnhancedCode: smtp.EnhancedCode{5, 5, 1},
@@ -134,9 +157,10 @@ var (
 )
 
 type smtpBackend struct {
-	service  *Service
-	log      *klog.LevelLogger
-	reqcount *atomic.Uint32
+	service    *Service
+	log        *klog.LevelLogger
+	reqcount   *atomic.Uint32
+	submission bool
 }
 
 func (s *smtpBackend) NewSession(c *smtp.Conn) (smtp.Session, error) {
@@ -156,14 +180,21 @@ func (s *smtpBackend) NewSession(c *smtp.Conn) (smtp.Session, error) {
 		klog.AString("smtp.session.ip", addr.String()),
 		klog.AString("smtp.session.helo", hostname),
 	)
-	return &smtpSession{
-		service: s.service,
-		be:      s,
-		log:     klog.NewLevelLogger(s.log.Logger.Sublogger("session")),
-		ctx:     ctx,
-		srcip:   addr,
-		helo:    hostname,
-	}, nil
+	sess := &smtpSession{
+		service:    s.service,
+		be:         s,
+		log:        klog.NewLevelLogger(s.log.Logger.Sublogger("session")),
+		ctx:        ctx,
+		srcip:      addr,
+		helo:       hostname,
+		submission: s.submission,
+	}
+	if s.submission {
+		return &smtpSubmissionSession{
+			smtpSession: sess,
+		}, nil
+	}
+	return sess, nil
 }
 
 type smtpSession struct {
@@ -180,8 +211,15 @@ type smtpSession struct {
 	rcptTo       string
 	rcptList     string
 	rcptOwner    string
+	rcptCmd      string
+	rcptCmdArg   string
 	senderPolicy string
+	modFirstPost bool
 	isOrg        bool
+	submission   bool
+	authUserid   string
+	authUsername string
+	authEmail    string
 }
 
 func (s *smtpSession) checkSPF(ctx context.Context, domain, from string) (authres.ResultValue, error, error) {
@@ -210,10 +248,6 @@ const (
 	mailidRandSize = 8
 )
 
-func (s *smtpSession) AuthPlain(username, password string) error {
-	return smtp.ErrAuthUnsupported
-}
-
 func (s *smtpSession) Mail(from string, opts *smtp.MailOptions) error {
 	ctx := klog.CtxWithAttrs(s.ctx,
 		klog.AString("smtp.cmd", "mail"),
@@ -223,6 +257,10 @@ func (s *smtpSession) Mail(from string, opts *smtp.MailOptions) error {
 	ctx = klog.CtxWithAttrs(ctx,
 		klog.AString("reqid", id),
 	)
+	if s.submission && s.authUserid == "" {
+		s.log.Warn(ctx, "Unauthenticated smtp submission")
+		return errSMTPAuthRequired
+	}
 	addr, err := gomail.ParseAddress(from)
 	if err != nil {
 		s.log.WarnErr(ctx, kerrors.WithMsg(err, "Failed to parse smtp from addr"))
@@ -233,6 +271,25 @@ func (s *smtpSession) Mail(from string, opts *smtp.MailOptions) error {
 		s.log.WarnErr(ctx, kerrors.WithMsg(err, "Failed to parse smtp from addr parts"))
 		return errSMTPFromAddr
 	}
+	if s.authUserid != "" {
+		// authenticated senders are not subject to spf checks, since they may
+		// submit from any host
+		if err := s.service.ratelimiter.Ratelimit(ctx, []ratelimit.Tag{
+			{
+				Key:    submissionRatelimitKeyUser,
+				Value:  s.authUserid,
+				Params: s.service.submission.sendlimit,
+			},
+		}); err != nil {
+			s.log.WarnErr(ctx, kerrors.WithMsg(err, "Smtp submission ratelimited"))
+			return errSMTPRatelimit
+		}
+		s.id = id
+		s.from = addr.Address
+		s.fromDomain = domain
+		s.fromSPF = authres.ResultNone
+		return nil
+	}
 	// DMARC requires checking RFC5321.MailFrom identity and not RFC5321.HELO
 	result, spfErr, err := s.checkSPF(ctx, domain, from)
 	if err != nil {
@@ -251,12 +308,20 @@ func (s *smtpSession) Mail(from string, opts *smtp.MailOptions) error {
 }
 
 const (
-	mailboxKeySeparator    = "."
-	listSenderPolicyOwner  = "owner"
-	listSenderPolicyMember = "member"
-	listSenderPolicyUser   = "user"
-	listMemberPolicyOwner  = "owner"
-	listMemberPolicyUser   = "user"
+	mailboxKeySeparator       = "."
+	mailboxCmdSeparator       = "+"
+	mailboxCmdArgSeparator    = "-"
+	listCmdDelivery           = "delivery"
+	listCmdUnsubscribe        = "unsubscribe"
+	listCmdSubscribe          = "subscribe"
+	listCmdHelp               = "help"
+	listCmdConfirm            = "confirm"
+	listSenderPolicyOwner     = "owner"
+	listSenderPolicyMember    = "member"
+	listSenderPolicyUser      = "user"
+	listSenderPolicyModerated = "moderated"
+	listMemberPolicyOwner     = "owner"
+	listMemberPolicyUser      = "user"
 )
 
 func (s *smtpSession) Rcpt(to string, _ *smtp.RcptOptions) error {
@@ -294,6 +359,38 @@ func (s *smtpSession) Rcpt(to string, _ *smtp.RcptOptions) error {
 		s.log.Warn(ctx, "Failed to parse smtp to addr parts")
 		return errSMTPMailbox
 	}
+	// list command addresses are of the form creator.listname+cmd-arg
+	listname, cmdStr, _ := strings.Cut(listname, mailboxCmdSeparator)
+	cmd, cmdArg, _ := strings.Cut(cmdStr, mailboxCmdArgSeparator)
+	switch cmd {
+	case "":
+	case listCmdDelivery:
+		if err := validDelivery(cmdArg); err != nil {
+			s.log.Warn(ctx, "Invalid list command arg",
+				klog.AString("list.cmd", cmd),
+			)
+			return errSMTPMailbox
+		}
+	case listCmdSubscribe, listCmdUnsubscribe, listCmdHelp:
+		if cmdArg != "" {
+			s.log.Warn(ctx, "Invalid list command arg",
+				klog.AString("list.cmd", cmd),
+			)
+			return errSMTPMailbox
+		}
+	case listCmdConfirm:
+		if err := validhasCmdKey(cmdArg); err != nil {
+			s.log.Warn(ctx, "Invalid list command arg",
+				klog.AString("list.cmd", cmd),
+			)
+			return errSMTPMailbox
+		}
+	default:
+		s.log.Warn(ctx, "Invalid list command",
+			klog.AString("list.cmd", cmd),
+		)
+		return errSMTPMailbox
+	}
 	isOrg := domain == s.service.orgdomain
 	if domain != s.service.usrdomain && !isOrg {
 		s.log.Warn(ctx, "Invalid smtp to domain")
@@ -339,7 +436,7 @@ func (s *smtpSession) Rcpt(to string, _ *smtp.RcptOptions) error {
 		return errSMTPBase
 	}
 
-	if list.Archive {
+	if list.Archive && cmd == "" {
 		s.log.Warn(ctx, "Mailbox is archived",
 			klog.AString("list", list.ListID),
 		)
@@ -349,7 +446,10 @@ func (s *smtpSession) Rcpt(to string, _ *smtp.RcptOptions) error {
 	s.rcptTo = to
 	s.rcptList = list.ListID
 	s.rcptOwner = list.CreatorID
+	s.rcptCmd = cmd
+	s.rcptCmdArg = cmdArg
 	s.senderPolicy = list.SenderPolicy
+	s.modFirstPost = list.ModFirstPost
 	s.isOrg = isOrg
 	return nil
 }
@@ -357,7 +457,10 @@ func (s *smtpSession) Rcpt(to string, _ *smtp.RcptOptions) error {
 const (
 	headerMessageID             = "Message-ID"
 	headerFrom                  = "From"
+	headerTo                    = "To"
 	headerInReplyTo             = "In-Reply-To"
+	headerReferences            = "References"
+	headerAutoSubmitted         = "Auto-Submitted"
 	headerAuthenticationResults = "Authentication-Results"
 	headerReceivedSPF           = "Received-SPF"
 	headerReceived              = "Received"
@@ -365,6 +468,7 @@ const (
 
 const (
 	maxSubjectLength = 127
+	autoSubmittedNo  = "no"
 )
 
 func (s *smtpSession) isAligned(a, b string) bool {
@@ -414,7 +518,7 @@ func (s *smtpSession) checkListPolicy(ctx context.Context, senderid string, msgi
 			s.log.Err(ctx, kerrors.WithMsg(err, "Failed to get list member"))
 			return errSMTPBase
 		}
-	case listSenderPolicyUser:
+	case listSenderPolicyUser, listSenderPolicyModerated:
 		if ok, err := gate.AuthUser(ctx, s.service.gate, senderid); err != nil {
 			s.log.Err(ctx, kerrors.WithMsg(err, "Failed to auth user"))
 			return errSMTPBase
@@ -429,6 +533,34 @@ func (s *smtpSession) checkListPolicy(ctx context.Context, senderid string, msgi
 	return nil
 }
 
+// holdReason returns the reason a msg from the sender must be held for
+// moderation, or the empty string if it may be sent immediately
+func (s *smtpSession) holdReason(ctx context.Context, senderid string) (string, error) {
+	var reason string
+	if s.senderPolicy == listSenderPolicyModerated {
+		reason = pendingReasonModerated
+	} else if s.modFirstPost {
+		reason = pendingReasonFirstPost
+	} else {
+		return "", nil
+	}
+	if s.isOrg {
+		if ok, err := gate.AuthMod(ctx, s.service.gate, senderid, s.rcptOwner); err != nil {
+			return "", kerrors.WithMsg(err, "Failed to auth org mod")
+		} else if ok {
+			return "", nil
+		}
+	} else if senderid == s.rcptOwner {
+		return "", nil
+	}
+	if ok, err := s.service.lists.IsApprovedSender(ctx, s.rcptList, senderid); err != nil {
+		return "", kerrors.WithMsg(err, "Failed to get approved list sender")
+	} else if ok {
+		return "", nil
+	}
+	return reason, nil
+}
+
 func (s *smtpSession) Data(r io.Reader) error {
 	ctx := klog.CtxWithAttrs(s.ctx,
 		klog.AString("smtp.cmd", "data"),
@@ -487,6 +619,10 @@ func (s *smtpSession) Data(r io.Reader) error {
 		return errMailBody
 	}
 
+	if s.authUserid != "" {
+		return s.submitData(ctx, m, headers, msgid, contentType, fromAddr)
+	}
+
 	if !s.isAligned(s.fromDomain, fromAddrDomain) {
 		s.log.Warn(ctx, "Failed spf alignment",
 			klog.AString("smtp.sender.addr", fromAddr),
@@ -510,8 +646,10 @@ func (s *smtpSession) Data(r io.Reader) error {
 		klog.AString("sender", sender.Userid),
 	)
 
-	if err := s.checkListPolicy(ctx, sender.Userid, msgid); err != nil {
-		return err
+	if s.rcptCmd == "" {
+		if err := s.checkListPolicy(ctx, sender.Userid, msgid); err != nil {
+			return err
+		}
 	}
 
 	dmarcRec, dmarcErr := dmarc.LookupWithOptions(fromAddrDomain, &dmarc.LookupOptions{
@@ -537,7 +675,12 @@ func (s *smtpSession) Data(r io.Reader) error {
 		dkimResults = nil
 	}
 
-	authResults := make([]authres.Result, 0, 3+len(dkimResults))
+	arcResult, arcErr := mail.VerifyARC(ctx, b.Bytes(), s.service.resolver)
+	if arcErr != nil {
+		s.log.WarnErr(ctx, kerrors.WithMsg(arcErr, "Failed arc validation"))
+	}
+
+	authResults := make([]authres.Result, 0, 4+len(dkimResults))
 	var spfReason string
 	var spfHeader string
 	switch s.fromSPF {
@@ -595,6 +738,10 @@ func (s *smtpSession) Data(r io.Reader) error {
 			Identifier: i.Identifier,
 		})
 	}
+	authResults = append(authResults, &authres.GenericResult{
+		Method: "arc",
+		Value:  arcResult,
+	})
 	if dmarcErr != nil {
 		var res authres.ResultValue = authres.ResultPermError
 		if errors.Is(dmarcErr, dmarc.ErrNoPolicy) {
@@ -623,10 +770,75 @@ func (s *smtpSession) Data(r io.Reader) error {
 			From:   fromAddrDomain,
 		})
 	}
+	if s.rcptCmd != "" {
+		// list commands modify the sender's membership, and thus must be
+		// authenticated regardless of the sender domain's DMARC policy
+		if !dmarcPassSPF && alignedDKIM == nil {
+			s.log.Warn(ctx, "Failed list command sender authentication")
+			return errSMTPAuthSend
+		}
+		// automatic responses, e.g. vacation replies to a confirmation, must
+		// not execute commands on behalf of the sender
+		if v := headers.Get(headerAutoSubmitted); v != "" && !strings.EqualFold(v, autoSubmittedNo) {
+			s.log.Info(ctx, "Skipped auto submitted list command")
+			return nil
+		}
+		return s.execListCmd(ctx, sender.Userid)
+	}
+
 	m.Header.Add(headerAuthenticationResults, authres.Format(s.service.authdomain, authResults))
 	m.Header.Add(headerReceivedSPF, spfHeader)
 	m.Header.Add(headerReceived, fmt.Sprintf("from %s (%s [%s]) by %s with %s id %s for %s; %s", s.helo, s.helo, s.srcip.String(), s.service.authdomain, "ESMTPS", s.id, s.rcptTo, time.Now().Round(0).UTC().Format(time.RFC1123Z)))
 
+	var spfPass, dkimPass string
+	if dmarcPassSPF {
+		spfPass = s.fromDomain
+	}
+	if alignedDKIM != nil {
+		dkimPass = alignedDKIM.Domain
+	}
+	return s.storeMsg(ctx, m, headers, msgid, contentType, sender.Userid, spfPass, dkimPass)
+}
+
+// submitData handles a msg from an authenticated sender, which may only send
+// as itself
+func (s *smtpSession) submitData(ctx context.Context, m *message.Entity, headers emmail.Header, msgid string, contentType string, fromAddr string) error {
+	if !strings.EqualFold(fromAddr, s.authEmail) {
+		s.log.Warn(ctx, "Smtp submission from header does not match authenticated user",
+			klog.AString("smtp.sender.addr", fromAddr),
+		)
+		return errSMTPAuthSender
+	}
+
+	ctx = klog.CtxWithAttrs(ctx,
+		klog.AString("sender", s.authUserid),
+	)
+
+	if s.rcptCmd != "" {
+		if v := headers.Get(headerAutoSubmitted); v != "" && !strings.EqualFold(v, autoSubmittedNo) {
+			s.log.Info(ctx, "Skipped auto submitted list command")
+			return nil
+		}
+		return s.execListCmd(ctx, s.authUserid)
+	}
+	if err := s.checkListPolicy(ctx, s.authUserid, msgid); err != nil {
+		return err
+	}
+
+	m.Header.Add(headerAuthenticationResults, authres.Format(s.service.authdomain, []authres.Result{
+		&authres.AuthResult{
+			Value: authres.ResultPass,
+			Auth:  s.authUsername,
+		},
+	}))
+	m.Header.Add(headerReceived, fmt.Sprintf("from %s (%s [%s]) by %s with %s id %s for %s; %s", s.helo, s.helo, s.srcip.String(), s.service.authdomain, "ESMTPSA", s.id, s.rcptTo, time.Now().Round(0).UTC().Format(time.RFC1123Z)))
+
+	return s.storeMsg(ctx, m, headers, msgid, contentType, s.authUserid, "", "")
+}
+
+// storeMsg stores a verified msg and publishes it to the list, or holds it for
+// moderation
+func (s *smtpSession) storeMsg(ctx context.Context, m *message.Entity, headers emmail.Header, msgid string, contentType string, senderid string, spfPass, dkimPass string) error {
 	var mb bytes.Buffer
 	if err := m.WriteTo(&mb); err != nil {
 		s.log.Err(ctx, kerrors.WithMsg(err, "Failed to write mail msg"))
@@ -661,28 +873,34 @@ func (s *smtpSession) Data(r io.Reader) error {
 		return nil
 	}
 
-	// must make a best effort to save the message and publish the event
-	ctx = klog.ExtendCtx(context.Background(), ctx)
-	if err := s.service.rcvMailDir.Subdir(s.rcptList).Put(ctx, s.service.encodeMsgid(msgid), contentType, int64(mb.Len()), nil, bytes.NewReader(mb.Bytes())); err != nil {
-		s.log.Err(ctx, kerrors.WithMsg(err, "Failed to store mail msg"))
-		return errSMTPBaseExists
-	}
-	msg := s.service.lists.NewMsg(s.rcptList, msgid, sender.Userid)
+	msg := s.service.lists.NewMsg(s.rcptList, msgid, senderid)
 	if subject, err := headers.Subject(); err == nil {
 		if len(subject) > maxSubjectLength {
 			subject = subject[:maxSubjectLength]
 		}
 		msg.Subject = subject
 	}
-	if dmarcPassSPF {
-		msg.SPFPass = s.fromDomain
-	}
-	if alignedDKIM != nil {
-		msg.DKIMPass = alignedDKIM.Domain
-	}
+	msg.SPFPass = spfPass
+	msg.DKIMPass = dkimPass
 	if inReplyTo, err := headers.MsgIDList(headerInReplyTo); err == nil && len(inReplyTo) == 1 {
 		msg.InReplyTo = inReplyTo[0]
 	}
+
+	holdReason, err := s.holdReason(ctx, senderid)
+	if err != nil {
+		s.log.Err(ctx, err)
+		return errSMTPBase
+	}
+
+	// must make a best effort to save the message and publish the event
+	ctx = klog.ExtendCtx(context.Background(), ctx)
+	if holdReason != "" {
+		return s.holdMsg(ctx, msg, holdReason, contentType, mb.Bytes())
+	}
+	if err := s.service.rcvMailDir.Subdir(s.rcptList).Put(ctx, s.service.encodeMsgid(msgid), contentType, int64(mb.Len()), nil, bytes.NewReader(mb.Bytes())); err != nil {
+		s.log.Err(ctx, kerrors.WithMsg(err, "Failed to store mail msg"))
+		return errSMTPBaseExists
+	}
 	if err := s.service.lists.InsertMsg(ctx, msg); err != nil {
 		if !errors.Is(err, dbsql.ErrUnique) {
 			s.log.Err(ctx, kerrors.WithMsg(err, "Failed to add list msg"))
@@ -703,6 +921,71 @@ func (s *smtpSession) Data(r io.Reader) error {
 	return nil
 }
 
+func (s *smtpSession) holdMsg(ctx context.Context, msg *mailinglistmodel.MsgModel, reason string, contentType string, body []byte) error {
+	ctx = klog.CtxWithAttrs(ctx,
+		klog.AString("list.hold.reason", reason),
+	)
+	if err := s.service.heldMailDir.Subdir(msg.ListID).Put(ctx, s.service.encodeMsgid(msg.Msgid), contentType, int64(len(body)), nil, bytes.NewReader(body)); err != nil {
+		s.log.Err(ctx, kerrors.WithMsg(err, "Failed to store held mail msg"))
+		return errSMTPBaseExists
+	}
+	m := s.service.lists.NewPending(msg.ListID, msg.Msgid, msg.Userid, reason)
+	m.SPFPass = msg.SPFPass
+	m.DKIMPass = msg.DKIMPass
+	m.Subject = msg.Subject
+	m.InReplyTo = msg.InReplyTo
+	if err := s.service.lists.InsertPending(ctx, m); err != nil {
+		if errors.Is(err, dbsql.ErrUnique) {
+			// msg is already held for moderation
+			return nil
+		}
+		s.log.Err(ctx, kerrors.WithMsg(err, "Failed to add pending list msg"))
+		return errSMTPBaseExists
+	}
+	j, err := encodeListEventHeld(heldProps{
+		ListID: msg.ListID,
+		MsgID:  msg.Msgid,
+	})
+	if err != nil {
+		s.log.Err(ctx, kerrors.WithMsg(err, "Failed to encode list event to json"))
+		return errSMTPBaseExists
+	}
+	if err := s.service.events.Publish(ctx, events.NewMsgs(s.service.streammail, msg.ListID, j)...); err != nil {
+		s.log.Err(ctx, kerrors.WithMsg(err, "Failed to publish list event"))
+		return errSMTPBaseExists
+	}
+	s.log.Info(ctx, "Held mail for moderation")
+	return nil
+}
+
+func (s *smtpSession) execListCmd(ctx context.Context, senderid string) error {
+	ctx = klog.CtxWithAttrs(ctx,
+		klog.AString("list.cmd", s.rcptCmd),
+	)
+	var err error
+	switch s.rcptCmd {
+	case listCmdHelp:
+		err = s.service.sendListHelp(ctx, s.rcptList, senderid)
+	case listCmdConfirm:
+		err = s.service.confirmListCmd(ctx, s.rcptList, senderid, s.rcptCmdArg)
+	default:
+		ctx = klog.CtxWithAttrs(ctx,
+			klog.AString("list.cmd.arg", s.rcptCmdArg),
+		)
+		err = s.service.requestListCmd(ctx, s.rcptList, senderid, s.rcptCmd, s.rcptCmdArg)
+	}
+	if err != nil {
+		if errors.Is(err, errListCmd{}) {
+			s.log.WarnErr(ctx, kerrors.WithMsg(err, "Failed list command"))
+			return errSMTPAuthSend
+		}
+		s.log.Err(ctx, kerrors.WithMsg(err, "Failed to execute list command"))
+		return errSMTPBase
+	}
+	s.log.Info(ctx, "Executed list command")
+	return nil
+}
+
 func (s *smtpSession) Reset() {
 	s.id = ""
 	s.from = ""
@@ -711,7 +994,10 @@ func (s *smtpSession) Reset() {
 	s.rcptTo = ""
 	s.rcptList = ""
 	s.rcptOwner = ""
+	s.rcptCmd = ""
+	s.rcptCmdArg = ""
 	s.senderPolicy = ""
+	s.modFirstPost = false
 	s.isOrg = false
 }
 
@@ -0,0 +1,185 @@
+package mailinglist
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/emersion/go-sasl"
+	"github.com/emersion/go-smtp"
+	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/gate/apikey"
+	"xorkevin.dev/governor/service/mail"
+	"xorkevin.dev/governor/service/ratelimit"
+	"xorkevin.dev/governor/service/user"
+	"xorkevin.dev/governor/service/user/token"
+	"xorkevin.dev/kerrors"
+	"xorkevin.dev/klog"
+)
+
+const (
+	submissionRatelimitKeyIP   = "mlsubmit.ip"
+	submissionRatelimitKeyUser = "mlsubmit.user"
+	apikeyPrefix               = "ga."
+)
+
+type (
+	submissionConfig struct {
+		enabled   bool
+		port      string
+		authlimit ratelimit.Params
+		sendlimit ratelimit.Params
+	}
+
+	secretSubmissionTLS struct {
+		Cert string `mapstructure:"cert"`
+		Key  string `mapstructure:"key"`
+	}
+
+	// submissionCerts caches the parsed submission tls certificate
+	submissionCerts struct {
+		mu   sync.Mutex
+		pem  secretSubmissionTLS
+		cert *tls.Certificate
+	}
+)
+
+func (s *Service) getSubmissionCert(ctx context.Context) (*tls.Certificate, error) {
+	var secret secretSubmissionTLS
+	if err := s.config.GetSecret(ctx, "submission.tls", s.keyrefresh, &secret); err != nil {
+		return nil, kerrors.WithKind(err, governor.ErrInvalidConfig, "Invalid submission tls secret")
+	}
+	if secret.Cert == "" || secret.Key == "" {
+		return nil, kerrors.WithKind(nil, governor.ErrInvalidConfig, "No submission tls cert present")
+	}
+	s.submissionCert.mu.Lock()
+	defer s.submissionCert.mu.Unlock()
+	if s.submissionCert.cert != nil && s.submissionCert.pem == secret {
+		return s.submissionCert.cert, nil
+	}
+	cert, err := tls.X509KeyPair([]byte(secret.Cert), []byte(secret.Key))
+	if err != nil {
+		return nil, kerrors.WithKind(err, governor.ErrInvalidConfig, "Invalid submission tls cert")
+	}
+	s.submissionCert.pem = secret
+	s.submissionCert.cert = &cert
+	return &cert, nil
+}
+
+func (s *Service) createSubmissionServer() *smtp.Server {
+	be := &smtpBackend{
+		service:    s,
+		log:        klog.NewLevelLogger(s.log.Logger.Sublogger("submissionserver")),
+		reqcount:   &atomic.Uint32{},
+		submission: true,
+	}
+	server := mail.NewSMTPServer(be, mail.SMTPServerOpts{
+		Port:         s.submission.port,
+		Domain:       s.authdomain,
+		MaxMsgSize:   s.maxmsgsize,
+		ReadTimeout:  s.readtimeout,
+		WriteTimeout: s.writetimeout,
+	})
+	// auth is only allowed after STARTTLS, and mail is only accepted after auth
+	server.AllowInsecureAuth = false
+	server.TLSConfig = &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			cert, err := s.getSubmissionCert(hello.Context())
+			if err != nil {
+				s.log.Err(hello.Context(), kerrors.WithMsg(err, "Failed to get submission tls cert"))
+				return nil, err
+			}
+			return cert, nil
+		},
+	}
+	return server
+}
+
+// smtpSubmissionSession is an smtp session that requires senders to
+// authenticate with SASL PLAIN, where the username is the governor username
+// and the password is a user apikey of the form ga.keyid.key with the submit
+// scope. User passwords are not accepted, so that a leaked smtp client
+// credential may be revoked without changing the user password.
+type smtpSubmissionSession struct {
+	*smtpSession
+}
+
+func (s *smtpSubmissionSession) AuthMechanisms() []string {
+	return []string{sasl.Plain}
+}
+
+func (s *smtpSubmissionSession) Auth(mech string) (sasl.Server, error) {
+	if mech != sasl.Plain {
+		return nil, smtp.ErrAuthUnknownMechanism
+	}
+	return sasl.NewPlainServer(s.authPlain), nil
+}
+
+func (s *smtpSubmissionSession) authPlain(identity, username, password string) error {
+	ctx := klog.CtxWithAttrs(s.ctx,
+		klog.AString("smtp.cmd", "auth"),
+		klog.AString("smtp.auth.username", username),
+	)
+	if identity != "" && identity != username {
+		s.log.Warn(ctx, "Invalid smtp auth identity")
+		return errSMTPAuthCreds
+	}
+	if err := s.service.ratelimiter.Ratelimit(ctx, []ratelimit.Tag{
+		{
+			Key:    submissionRatelimitKeyIP,
+			Value:  s.srcip.String(),
+			Params: s.service.submission.authlimit,
+		},
+	}); err != nil {
+		s.log.WarnErr(ctx, kerrors.WithMsg(err, "Smtp auth ratelimited"))
+		return errSMTPRatelimit
+	}
+	apitoken, ok := strings.CutPrefix(password, apikeyPrefix)
+	if !ok {
+		s.log.Warn(ctx, "Invalid smtp auth apikey")
+		return errSMTPAuthCreds
+	}
+	keyid, key, ok := strings.Cut(apitoken, ".")
+	if !ok {
+		s.log.Warn(ctx, "Invalid smtp auth apikey")
+		return errSMTPAuthCreds
+	}
+	userscope, err := s.service.gate.CheckKey(ctx, keyid, key)
+	if err != nil {
+		if errors.Is(err, apikey.ErrInvalidKey) || errors.Is(err, apikey.ErrNotFound) {
+			s.log.WarnErr(ctx, kerrors.WithMsg(err, "Invalid smtp auth apikey"))
+			return errSMTPAuthCreds
+		}
+		s.log.Err(ctx, kerrors.WithMsg(err, "Failed to check smtp auth apikey"))
+		return errSMTPBase
+	}
+	if !token.HasScope(userscope.Scope, s.service.scopens+".submit:write") {
+		s.log.Warn(ctx, "Smtp auth apikey lacks submit scope")
+		return errSMTPAuthCreds
+	}
+	u, err := s.service.users.GetByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, user.ErrNotFound) {
+			s.log.WarnErr(ctx, kerrors.WithMsg(err, "Smtp auth user not found"))
+			return errSMTPAuthCreds
+		}
+		s.log.Err(ctx, kerrors.WithMsg(err, "Failed to get smtp auth user"))
+		return errSMTPBase
+	}
+	if u.Userid != userscope.Userid {
+		s.log.Warn(ctx, "Smtp auth apikey does not belong to user")
+		return errSMTPAuthCreds
+	}
+	s.authUserid = u.Userid
+	s.authUsername = u.Username
+	s.authEmail = u.Email
+	s.ctx = klog.CtxWithAttrs(s.ctx,
+		klog.AString("smtp.auth.userid", u.Userid),
+	)
+	s.log.Info(ctx, "Authenticated smtp submission user")
+	return nil
+}
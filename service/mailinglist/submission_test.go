@@ -0,0 +1,200 @@
+package mailinglist
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"xorkevin.dev/governor/service/gate/apikey"
+	"xorkevin.dev/governor/service/ratelimit"
+	"xorkevin.dev/governor/service/user/gate"
+	"xorkevin.dev/kerrors"
+	"xorkevin.dev/klog"
+)
+
+type (
+	testGate struct {
+		gate.Gate
+		keys   map[string]testKeyScope
+		checks int
+	}
+
+	testKeyScope struct {
+		key   string
+		scope apikey.UserScope
+	}
+
+	testRatelimiter struct {
+		ratelimit.Ratelimiter
+		err  error
+		tags []ratelimit.Tag
+	}
+)
+
+func (g *testGate) CheckKey(ctx context.Context, keyid, key string) (*apikey.UserScope, error) {
+	g.checks++
+	m, ok := g.keys[keyid]
+	if !ok {
+		return nil, kerrors.WithKind(nil, apikey.ErrNotFound, "Key not found")
+	}
+	if m.key != key {
+		return nil, kerrors.WithKind(nil, apikey.ErrInvalidKey, "Invalid key")
+	}
+	return &m.scope, nil
+}
+
+func (r *testRatelimiter) Ratelimit(ctx context.Context, tags []ratelimit.Tag) error {
+	r.tags = append(r.tags, tags...)
+	return r.err
+}
+
+func TestAuthPlain(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		Test        string
+		Identity    string
+		Username    string
+		Password    string
+		Ratelimited bool
+		Unchecked   bool
+		Err         error
+	}{
+		{
+			Test:     "authenticates with apikey",
+			Username: "user1",
+			Password: "ga.key1.secret1",
+		},
+		{
+			Test:     "authenticates with matching identity",
+			Identity: "user1",
+			Username: "user1",
+			Password: "ga.key1.secret1",
+		},
+		{
+			Test:      "rejects mismatched identity",
+			Identity:  "user2",
+			Username:  "user1",
+			Password:  "ga.key1.secret1",
+			Unchecked: true,
+			Err:       errSMTPAuthCreds,
+		},
+		{
+			Test:        "rejects when ratelimited before checking key",
+			Username:    "user1",
+			Password:    "ga.key1.secret1",
+			Ratelimited: true,
+			Err:         errSMTPRatelimit,
+		},
+		{
+			Test:     "rejects user password",
+			Username: "user1",
+			Password: "password",
+			Err:      errSMTPAuthCreds,
+		},
+		{
+			Test:     "rejects apikey without keyid",
+			Username: "user1",
+			Password: "ga.secret1",
+			Err:      errSMTPAuthCreds,
+		},
+		{
+			Test:     "rejects wrong key",
+			Username: "user1",
+			Password: "ga.key1.secret2",
+			Err:      errSMTPAuthCreds,
+		},
+		{
+			Test:     "rejects unknown key",
+			Username: "user1",
+			Password: "ga.key3.secret1",
+			Err:      errSMTPAuthCreds,
+		},
+		{
+			Test:     "rejects apikey without submit scope",
+			Username: "user1",
+			Password: "ga.key2.secret2",
+			Err:      errSMTPAuthCreds,
+		},
+		{
+			Test:     "rejects apikey of other user",
+			Username: "user2",
+			Password: "ga.key1.secret1",
+			Err:      errSMTPAuthCreds,
+		},
+		{
+			Test:     "rejects unknown user",
+			Username: "user3",
+			Password: "ga.key1.secret1",
+			Err:      errSMTPAuthCreds,
+		},
+	} {
+		t.Run(tc.Test, func(t *testing.T) {
+			t.Parallel()
+
+			assert := require.New(t)
+
+			s := newTestService()
+			s.scopens = "gov.mailinglist"
+			s.addUser("user1", "user1")
+			s.addUser("user2", "user2")
+			g := &testGate{
+				keys: map[string]testKeyScope{
+					"key1": {
+						key: "secret1",
+						scope: apikey.UserScope{
+							Userid: "user1",
+							Scope:  "gov.mailinglist.submit:write",
+						},
+					},
+					"key2": {
+						key: "secret2",
+						scope: apikey.UserScope{
+							Userid: "user1",
+							Scope:  "gov.user.apikey:read",
+						},
+					},
+				},
+			}
+			s.gate = g
+			limiter := &testRatelimiter{}
+			if tc.Ratelimited {
+				limiter.err = errors.New("Ratelimited")
+			}
+			s.ratelimiter = limiter
+
+			sess := &smtpSubmissionSession{
+				smtpSession: &smtpSession{
+					service:    s.Service,
+					log:        klog.NewLevelLogger(klog.Discard{}),
+					ctx:        context.Background(),
+					srcip:      netip.MustParseAddr("192.0.2.1"),
+					submission: true,
+				},
+			}
+
+			err := sess.authPlain(tc.Identity, tc.Username, tc.Password)
+			if tc.Unchecked {
+				assert.Empty(limiter.tags)
+			} else {
+				assert.Len(limiter.tags, 1)
+				assert.Equal(submissionRatelimitKeyIP, limiter.tags[0].Key)
+				assert.Equal("192.0.2.1", limiter.tags[0].Value)
+			}
+			if tc.Ratelimited {
+				assert.Equal(0, g.checks)
+			}
+			if tc.Err != nil {
+				assert.ErrorIs(err, tc.Err)
+				assert.Equal("", sess.authUserid)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal("user1", sess.authUserid)
+			assert.Equal("user1", sess.authUsername)
+			assert.Equal("user1@example.com", sess.authEmail)
+		})
+	}
+}
@@ -0,0 +1,307 @@
+package mailinglist
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/emersion/go-message/textproto"
+	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/dbsql"
+	"xorkevin.dev/governor/service/mail"
+	"xorkevin.dev/governor/service/mailinglist/mailinglistmodel"
+	"xorkevin.dev/governor/service/user"
+	"xorkevin.dev/kerrors"
+)
+
+const (
+	headerListID              = "List-Id"
+	headerListPost            = "List-Post"
+	headerListArchive         = "List-Archive"
+	headerListUnsubscribe     = "List-Unsubscribe"
+	headerListUnsubscribePost = "List-Unsubscribe-Post"
+
+	listUnsubscribeOneClick = "List-Unsubscribe=One-Click"
+)
+
+const (
+	unsubTokenSeparator = "."
+	unsubMACSize        = 16
+)
+
+type (
+	// errUnsubToken is returned when an unsubscribe token is invalid
+	errUnsubToken struct{}
+)
+
+func (e errUnsubToken) Error() string {
+	return "Invalid unsubscribe token"
+}
+
+func unsubMAC(key []byte, listid, userid string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(listid))
+	h.Write([]byte(unsubTokenSeparator))
+	h.Write([]byte(userid))
+	return h.Sum(nil)[:unsubMACSize]
+}
+
+// encodeUnsubToken returns a token of the form listid.userid.mac, where the
+// list and user ids are base64 encoded and the mac authenticates both
+func encodeUnsubToken(key []byte, listid, userid string) string {
+	encList := base64.RawURLEncoding.EncodeToString([]byte(listid))
+	encUser := base64.RawURLEncoding.EncodeToString([]byte(userid))
+	mac := base64.RawURLEncoding.EncodeToString(unsubMAC(key, encList, encUser))
+	return encList + unsubTokenSeparator + encUser + unsubTokenSeparator + mac
+}
+
+// decodeUnsubToken parses an unsubscribe token and authenticates it with any
+// of the keys, returning the list id and user id
+func decodeUnsubToken(keys [][]byte, token string) (string, string, error) {
+	encList, rest, ok := strings.Cut(token, unsubTokenSeparator)
+	if !ok || encList == "" {
+		return "", "", kerrors.WithKind(nil, errUnsubToken{}, "Invalid unsubscribe token list")
+	}
+	encUser, encMAC, ok := strings.Cut(rest, unsubTokenSeparator)
+	if !ok || encUser == "" {
+		return "", "", kerrors.WithKind(nil, errUnsubToken{}, "Invalid unsubscribe token user")
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(encMAC)
+	if err != nil {
+		return "", "", kerrors.WithKind(err, errUnsubToken{}, "Invalid unsubscribe token mac")
+	}
+	valid := false
+	for _, i := range keys {
+		if hmac.Equal(mac, unsubMAC(i, encList, encUser)) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return "", "", kerrors.WithKind(nil, errUnsubToken{}, "Failed to authenticate unsubscribe token")
+	}
+	listid, err := base64.RawURLEncoding.DecodeString(encList)
+	if err != nil {
+		return "", "", kerrors.WithKind(err, errUnsubToken{}, "Invalid unsubscribe token list")
+	}
+	userid, err := base64.RawURLEncoding.DecodeString(encUser)
+	if err != nil {
+		return "", "", kerrors.WithKind(err, errUnsubToken{}, "Invalid unsubscribe token user")
+	}
+	return string(listid), string(userid), nil
+}
+
+type (
+	secretUnsub struct {
+		Keys []string `mapstructure:"secrets"`
+	}
+)
+
+func (s *Service) getUnsubKeys(ctx context.Context) ([][]byte, error) {
+	var unsubSecrets secretUnsub
+	if err := s.config.GetSecret(ctx, "unsubkey", s.keyrefresh, &unsubSecrets); err != nil {
+		return nil, kerrors.WithKind(err, governor.ErrInvalidConfig, "Invalid unsubkey secrets")
+	}
+	if len(unsubSecrets.Keys) == 0 {
+		return nil, kerrors.WithKind(nil, governor.ErrInvalidConfig, "No unsubkey present")
+	}
+	keys := make([][]byte, 0, len(unsubSecrets.Keys))
+	for _, i := range unsubSecrets.Keys {
+		if i == "" {
+			return nil, kerrors.WithKind(nil, governor.ErrInvalidConfig, "Empty unsubkey")
+		}
+		keys = append(keys, []byte(i))
+	}
+	return keys, nil
+}
+
+func (s *Service) unsubOneClick(ctx context.Context, token string) error {
+	keys, err := s.getUnsubKeys(ctx)
+	if err != nil {
+		return err
+	}
+	listid, userid, err := decodeUnsubToken(keys, token)
+	if err != nil {
+		return governor.ErrWithRes(err, http.StatusBadRequest, "", "Invalid unsubscribe token")
+	}
+	m, err := s.lists.GetListByID(ctx, listid)
+	if err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			return governor.ErrWithRes(err, http.StatusNotFound, "", "List not found")
+		}
+		return kerrors.WithMsg(err, "Failed to get list")
+	}
+	if _, err := s.lists.GetMember(ctx, m.ListID, userid); err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			// repeated unsubscribe requests must succeed
+			return nil
+		}
+		return kerrors.WithMsg(err, "Failed to get list member")
+	}
+	return s.removeListMembers(ctx, m.CreatorID, m.Listname, []string{userid})
+}
+
+// splitMsgHeader parses the header of a msg and returns it along with the
+// remaining msg body
+func splitMsgHeader(msg []byte) (textproto.Header, []byte, error) {
+	br := bufio.NewReader(bytes.NewReader(msg))
+	h, err := textproto.ReadHeader(br)
+	if err != nil {
+		return textproto.Header{}, nil, kerrors.WithMsg(err, "Failed to read msg header")
+	}
+	body, err := io.ReadAll(br)
+	if err != nil {
+		return textproto.Header{}, nil, kerrors.WithMsg(err, "Failed to read msg body")
+	}
+	return h, body, nil
+}
+
+// listIDPhrase formats a list name as the phrase of an RFC 2919 List-Id
+func listIDPhrase(name string) string {
+	if !isASCII(name) {
+		return mime.QEncoding.Encode("utf-8", name)
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(name) + `"`
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// listCmdAddress returns the address of a list command
+func listCmdAddress(listaddr string, cmd string) string {
+	local, domain, _ := strings.Cut(listaddr, "@")
+	return local + mailboxCmdSeparator + cmd + "@" + domain
+}
+
+// setListHeaders replaces any RFC 2369 and RFC 2919 list headers on a msg with
+// those of the list
+func (s *Service) setListHeaders(h *textproto.Header, ml *mailinglistmodel.ListModel, listaddr string) {
+	h.Del(headerListID)
+	h.Del(headerListPost)
+	h.Del(headerListArchive)
+	h.Del(headerListUnsubscribe)
+	h.Del(headerListUnsubscribePost)
+	h.Add(headerListArchive, "<"+s.apiurl+"/l/"+url.PathEscape(ml.ListID)+"/msgs>")
+	h.Add(headerListPost, "<mailto:"+listaddr+">")
+	h.Add(headerListID, listIDPhrase(ml.Name)+" <"+ml.ListID+"."+s.authdomain+">")
+}
+
+// fwdListMsg sends a msg to each list member with list headers, including a
+// per member RFC 8058 one-click unsubscribe url. Members are logged as sent as
+// the msg is sent to them, so that a failed send is retried only for the
+// members that have not yet been sent the msg.
+func (s *Service) fwdListMsg(ctx context.Context, ml *mailinglistmodel.ListModel, listaddr string, msgid string, msg []byte, userids []string, seal bool) error {
+	if len(userids) == 0 {
+		return nil
+	}
+	recipients, err := s.users.GetInfoBulk(ctx, userids)
+	if err != nil {
+		return kerrors.WithMsg(err, "Failed to get list member users")
+	}
+	rcpts := make([]mail.Addr, 0, len(recipients.Users))
+	for _, i := range recipients.Users {
+		rcpts = append(rcpts, mail.Addr{
+			Address: i.Email,
+		})
+	}
+	rcpts, err = s.mailer.FilterSuppressed(ctx, rcpts)
+	if err != nil {
+		return kerrors.WithMsg(err, "Failed to filter suppressed recipients")
+	}
+	allowed := make(map[string]struct{}, len(rcpts))
+	for _, i := range rcpts {
+		allowed[i.Address] = struct{}{}
+	}
+	toSend := make([]user.ResUserInfo, 0, len(rcpts))
+	pending := make(map[string]struct{}, len(rcpts))
+	for _, i := range recipients.Users {
+		if _, ok := allowed[i.Email]; ok {
+			toSend = append(toSend, i)
+			pending[i.Userid] = struct{}{}
+		}
+	}
+	// members that no longer exist or are suppressed are never sent the msg
+	sent := make([]string, 0, len(userids))
+	for _, i := range userids {
+		if _, ok := pending[i]; !ok {
+			sent = append(sent, i)
+		}
+	}
+	if err := s.sendListMsgRcpts(ctx, ml, listaddr, msg, toSend, seal, func(userid string) {
+		sent = append(sent, userid)
+	}); err != nil {
+		if err := s.logSentMsg(ctx, ml.ListID, msgid, sent); err != nil {
+			s.log.Err(ctx, err)
+		}
+		return err
+	}
+	return s.logSentMsg(ctx, ml.ListID, msgid, sent)
+}
+
+func (s *Service) logSentMsg(ctx context.Context, listid, msgid string, userids []string) error {
+	if len(userids) == 0 {
+		return nil
+	}
+	if err := s.lists.LogSentMsg(ctx, listid, msgid, userids); err != nil {
+		return kerrors.WithMsg(err, "Failed to log sent mail messages")
+	}
+	return nil
+}
+
+// sendListMsgRcpts sends a msg to each recipient, calling onSent after each
+// successful send
+func (s *Service) sendListMsgRcpts(ctx context.Context, ml *mailinglistmodel.ListModel, listaddr string, msg []byte, recipients []user.ResUserInfo, seal bool, onSent func(userid string)) error {
+	if len(recipients) == 0 {
+		return nil
+	}
+	keys, err := s.getUnsubKeys(ctx)
+	if err != nil {
+		return err
+	}
+	h, body, err := splitMsgHeader(msg)
+	if err != nil {
+		return err
+	}
+	s.setListHeaders(&h, ml, listaddr)
+	unsubAddr := listCmdAddress(listaddr, listCmdUnsubscribe)
+
+	var mb bytes.Buffer
+	for _, i := range recipients {
+		hc := h.Copy()
+		hc.Add(headerListUnsubscribePost, listUnsubscribeOneClick)
+		hc.Add(headerListUnsubscribe, "<"+s.apiurl+"/unsub/"+encodeUnsubToken(keys[0], ml.ListID, i.Userid)+">, <mailto:"+unsubAddr+">")
+		mb.Reset()
+		if err := textproto.WriteHeader(&mb, hc); err != nil {
+			return kerrors.WithMsg(err, "Failed to write msg header")
+		}
+		mb.Write(body)
+		to := []mail.Addr{{Address: i.Email}}
+		if seal {
+			if err := s.mailer.FwdStreamSeal(ctx, "", to, s.authdomain, int64(mb.Len()), bytes.NewReader(mb.Bytes()), false); err != nil {
+				return kerrors.WithMsg(err, "Failed to send mail message")
+			}
+		} else {
+			if err := s.mailer.FwdStream(ctx, "", to, int64(mb.Len()), bytes.NewReader(mb.Bytes()), false); err != nil {
+				return kerrors.WithMsg(err, "Failed to send mail message")
+			}
+		}
+		onSent(i.Userid)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package mailinglist
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnsubToken(t *testing.T) {
+	t.Parallel()
+
+	key := []byte("current-unsub-key")
+	oldKey := []byte("previous-unsub-key")
+	otherKey := []byte("other-unsub-key")
+
+	token := encodeUnsubToken(key, "mylist", "myuser")
+	parts := strings.Split(token, unsubTokenSeparator)
+	encOther := base64.RawURLEncoding.EncodeToString([]byte("otheruser"))
+
+	for _, tc := range []struct {
+		Test   string
+		Keys   [][]byte
+		Token  string
+		ListID string
+		UserID string
+		Err    bool
+	}{
+		{
+			Test:   "round trips",
+			Keys:   [][]byte{key},
+			Token:  token,
+			ListID: "mylist",
+			UserID: "myuser",
+		},
+		{
+			Test:   "accepts rotated key",
+			Keys:   [][]byte{key, oldKey},
+			Token:  encodeUnsubToken(oldKey, "mylist", "myuser"),
+			ListID: "mylist",
+			UserID: "myuser",
+		},
+		{
+			Test:   "round trips ids with separator",
+			Keys:   [][]byte{key},
+			Token:  encodeUnsubToken(key, "org.mylist", "my.user"),
+			ListID: "org.mylist",
+			UserID: "my.user",
+		},
+		{
+			Test:  "rejects unknown key",
+			Keys:  [][]byte{otherKey},
+			Token: token,
+			Err:   true,
+		},
+		{
+			Test:  "rejects tampered user",
+			Keys:  [][]byte{key},
+			Token: parts[0] + unsubTokenSeparator + encOther + unsubTokenSeparator + parts[2],
+			Err:   true,
+		},
+		{
+			Test:  "rejects tampered list",
+			Keys:  [][]byte{key},
+			Token: encOther + unsubTokenSeparator + parts[1] + unsubTokenSeparator + parts[2],
+			Err:   true,
+		},
+		{
+			Test:  "rejects tampered mac",
+			Keys:  [][]byte{key},
+			Token: parts[0] + unsubTokenSeparator + parts[1] + unsubTokenSeparator + base64.RawURLEncoding.EncodeToString(make([]byte, unsubMACSize)),
+			Err:   true,
+		},
+		{
+			Test:  "rejects invalid mac encoding",
+			Keys:  [][]byte{key},
+			Token: parts[0] + unsubTokenSeparator + parts[1] + unsubTokenSeparator + "!!",
+			Err:   true,
+		},
+		{
+			Test:  "rejects missing mac",
+			Keys:  [][]byte{key},
+			Token: parts[0] + unsubTokenSeparator + parts[1],
+			Err:   true,
+		},
+		{
+			Test:  "rejects empty user",
+			Keys:  [][]byte{key},
+			Token: parts[0] + unsubTokenSeparator + unsubTokenSeparator + parts[2],
+			Err:   true,
+		},
+		{
+			Test:  "rejects empty",
+			Keys:  [][]byte{key},
+			Token: "",
+			Err:   true,
+		},
+	} {
+		t.Run(tc.Test, func(t *testing.T) {
+			t.Parallel()
+
+			assert := require.New(t)
+
+			listid, userid, err := decodeUnsubToken(tc.Keys, tc.Token)
+			if tc.Err {
+				assert.ErrorIs(err, errUnsubToken{})
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tc.ListID, listid)
+			assert.Equal(tc.UserID, userid)
+		})
+	}
+}
+
+func TestFwdListMsg(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	s := newTestService()
+	s.addUser("user1", "user1")
+	s.addUser("user2", "user2")
+	s.addUser("user3", "user3")
+	ml := s.addList("owner", "mylist")
+	s.mailer.fail["user2@example.com"] = errors.New("Failed to send")
+
+	ctx := context.Background()
+	msg := []byte("Subject: Hello\r\n\r\nhello\r\n")
+	userids := []string{"deleted", "user1", "user2", "user3"}
+
+	assert.Error(s.fwdListMsg(ctx, ml, "owner.mylist@lists.example.com", "msg@example.com", msg, userids, true))
+	assert.Equal([]string{"user1@example.com"}, s.mailer.fwdTo())
+	assert.Equal("lists.example.com", s.mailer.fwd[0].seal)
+	assert.Contains(string(s.mailer.fwd[0].body), "List-Unsubscribe: <https://example.com/api/mailinglist/unsub/")
+	// members sent the msg before the failure are logged so a retry does not
+	// send to them again
+	assert.Equal([]string{"deleted", "user1"}, s.lists.sent[testKey(ml.ListID, "msg@example.com")])
+
+	delete(s.mailer.fail, "user2@example.com")
+	assert.NoError(s.fwdListMsg(ctx, ml, "owner.mylist@lists.example.com", "msg@example.com", msg, []string{"user2", "user3"}, true))
+	assert.Equal([]string{"user1@example.com", "user2@example.com", "user3@example.com"}, s.mailer.fwdTo())
+	assert.Equal([]string{"deleted", "user1", "user2", "user3"}, s.lists.sent[testKey(ml.ListID, "msg@example.com")])
+}
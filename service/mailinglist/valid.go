@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/mailinglist/mailinglistmodel"
 )
 
 //go:generate forge validation
@@ -15,6 +16,10 @@ const (
 	lengthCapListname  = 127
 	lengthCapMsgid     = 1023
 	lengthCapName      = 127
+	lengthCapReason    = 1023
+	lengthCapToken     = 1023
+	lengthCapCmdKey    = 63
+	lengthCapQuery     = 255
 	amountCap          = 255
 )
 
@@ -102,7 +107,7 @@ func validDesc(desc string) error {
 
 func validSenderPolicy(pol string) error {
 	switch pol {
-	case listSenderPolicyOwner, listSenderPolicyMember, listSenderPolicyUser:
+	case listSenderPolicyOwner, listSenderPolicyMember, listSenderPolicyUser, listSenderPolicyModerated:
 		return nil
 	default:
 		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Invalid sender policy")
@@ -118,6 +123,35 @@ func validMemberPolicy(pol string) error {
 	}
 }
 
+func validDelivery(delivery string) error {
+	switch delivery {
+	case mailinglistmodel.MemberDeliveryImmediate, mailinglistmodel.MemberDeliveryDaily, mailinglistmodel.MemberDeliveryWeekly:
+		return nil
+	default:
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Invalid delivery mode")
+	}
+}
+
+func validhasUnsubToken(token string) error {
+	if len(token) == 0 {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Token must be provided")
+	}
+	if len(token) > lengthCapToken {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Token must be shorter than 1024 characters")
+	}
+	return nil
+}
+
+func validhasCmdKey(key string) error {
+	if len(key) == 0 {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Key must be provided")
+	}
+	if len(key) > lengthCapCmdKey {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Key must be shorter than 64 characters")
+	}
+	return nil
+}
+
 func validAmount(amt int) error {
 	if amt < 1 {
 		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Amount must be positive")
@@ -145,6 +179,13 @@ func validhasMsgid(msgid string) error {
 	return nil
 }
 
+func validoptMsgid(msgid string) error {
+	if len(msgid) > lengthCapMsgid {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Msg id must be shorter than 1024 characters")
+	}
+	return nil
+}
+
 func validhasMsgids(msgids []string) error {
 	if len(msgids) == 0 {
 		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Msg ids must be provided")
@@ -159,3 +200,20 @@ func validhasMsgids(msgids []string) error {
 	}
 	return nil
 }
+
+func validReason(reason string) error {
+	if len(reason) > lengthCapReason {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Reason must be shorter than 1024 characters")
+	}
+	return nil
+}
+
+func validhasQuery(query string) error {
+	if len(query) == 0 {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Query must be provided")
+	}
+	if len(query) > lengthCapQuery {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Query must be shorter than 256 characters")
+	}
+	return nil
+}
@@ -2,6 +2,29 @@
 
 package mailinglist
 
+func (r reqExportMbox) valid() error {
+	if err := validhasCreatorID(r.CreatorID); err != nil {
+		return err
+	}
+	if err := validhasListname(r.Listname); err != nil {
+		return err
+	}
+	if err := validoptMsgid(r.Threadid); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqImportMbox) valid() error {
+	if err := validhasCreatorID(r.CreatorID); err != nil {
+		return err
+	}
+	if err := validhasListname(r.Listname); err != nil {
+		return err
+	}
+	return nil
+}
+
 func (r reqCreatorLists) valid() error {
 	if err := validhasCreatorID(r.CreatorID); err != nil {
 		return err
@@ -141,6 +164,29 @@ func (r reqSub) valid() error {
 	return nil
 }
 
+func (r reqUnsubToken) valid() error {
+	if err := validhasUnsubToken(r.Token); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqSubDelivery) valid() error {
+	if err := validhasCreatorID(r.CreatorID); err != nil {
+		return err
+	}
+	if err := validhasListname(r.Listname); err != nil {
+		return err
+	}
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validDelivery(r.Delivery); err != nil {
+		return err
+	}
+	return nil
+}
+
 func (r reqUpdListMembers) valid() error {
 	if err := validhasCreatorID(r.CreatorID); err != nil {
 		return err
@@ -176,3 +222,93 @@ func (r reqMsgIDs) valid() error {
 	}
 	return nil
 }
+
+func (r reqPendingMsgs) valid() error {
+	if err := validhasCreatorID(r.CreatorID); err != nil {
+		return err
+	}
+	if err := validhasListname(r.Listname); err != nil {
+		return err
+	}
+	if err := validAmount(r.Amount); err != nil {
+		return err
+	}
+	if err := validOffset(r.Offset); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqPendingMsg) valid() error {
+	if err := validhasCreatorID(r.CreatorID); err != nil {
+		return err
+	}
+	if err := validhasListname(r.Listname); err != nil {
+		return err
+	}
+	if err := validhasMsgid(r.Msgid); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqApprovePendingMsg) valid() error {
+	if err := validhasCreatorID(r.CreatorID); err != nil {
+		return err
+	}
+	if err := validhasListname(r.Listname); err != nil {
+		return err
+	}
+	if err := validhasMsgid(r.Msgid); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqRejectPendingMsg) valid() error {
+	if err := validhasCreatorID(r.CreatorID); err != nil {
+		return err
+	}
+	if err := validhasListname(r.Listname); err != nil {
+		return err
+	}
+	if err := validhasMsgid(r.Msgid); err != nil {
+		return err
+	}
+	if err := validReason(r.Reason); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqSearchListMsgs) valid() error {
+	if err := validhasListid(r.Listid); err != nil {
+		return err
+	}
+	if err := validhasQuery(r.Query); err != nil {
+		return err
+	}
+	if err := validAmount(r.Amount); err != nil {
+		return err
+	}
+	if err := validOffset(r.Offset); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqSearchMemberMsgs) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasQuery(r.Query); err != nil {
+		return err
+	}
+	if err := validAmount(r.Amount); err != nil {
+		return err
+	}
+	if err := validOffset(r.Offset); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,615 @@
+package objstore
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"xorkevin.dev/governor/util/kjson"
+	"xorkevin.dev/kerrors"
+)
+
+var _ Bucket = (*FSBucket)(nil)
+
+const (
+	fsDirObj       = "obj"
+	fsDirTmp       = "tmp"
+	fsDirMultipart = "multipart"
+	fsFileUpload   = "upload"
+	fsPartPrefix   = "part."
+	fsTrailerLen   = 8
+)
+
+type (
+	// FSBucket is a [Bucket] stored in a local directory
+	//
+	// Each object is stored as a file containing the object data followed by
+	// its metadata, and is written atomically by renaming a temporary file into
+	// place. Since objects are stored at their names as paths, an object may
+	// not be named as the directory of another object.
+	FSBucket struct {
+		root string
+	}
+
+	fsObjectMeta struct {
+		Size         int64             `json:"size"`
+		ContentType  string            `json:"content_type"`
+		ETag         string            `json:"etag"`
+		LastModified int64             `json:"last_modified"`
+		UserMeta     map[string]string `json:"user_meta,omitempty"`
+	}
+
+	fsUploadMeta struct {
+		Name        string            `json:"name"`
+		ContentType string            `json:"content_type"`
+		UserMeta    map[string]string `json:"user_meta,omitempty"`
+	}
+
+	fsObjectReader struct {
+		io.Reader
+		io.Closer
+	}
+)
+
+// NewFSBucket creates a new bucket stored in the directory root
+func NewFSBucket(root string) *FSBucket {
+	return &FSBucket{
+		root: root,
+	}
+}
+
+func fsPing(root string) error {
+	info, err := os.Stat(root)
+	if err != nil {
+		return kerrors.WithKind(err, ErrConn, "Failed to stat objstore dir")
+	}
+	if !info.IsDir() {
+		return kerrors.WithKind(nil, ErrConn, "Objstore path is not a dir")
+	}
+	return nil
+}
+
+func fsDelBucket(root string) error {
+	if _, err := os.Stat(root); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return kerrors.WithKind(err, ErrNotFound, "Failed to get bucket")
+		}
+		return kerrors.WithKind(err, ErrClient, "Failed to get bucket")
+	}
+	empty := true
+	if err := filepath.WalkDir(filepath.Join(root, fsDirObj), func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			empty = false
+			return fs.SkipAll
+		}
+		return nil
+	}); err != nil {
+		return kerrors.WithKind(err, ErrClient, "Failed to read bucket")
+	}
+	if !empty {
+		return kerrors.WithKind(nil, ErrClient, "Failed to remove non-empty bucket")
+	}
+	if err := os.RemoveAll(root); err != nil {
+		return kerrors.WithKind(err, ErrClient, "Failed to remove bucket")
+	}
+	return nil
+}
+
+// Init creates the bucket dir if it does not exist
+func (b *FSBucket) Init(ctx context.Context) error {
+	for _, i := range []string{fsDirObj, fsDirTmp, fsDirMultipart} {
+		if err := os.MkdirAll(filepath.Join(b.root, i), 0o700); err != nil {
+			return kerrors.WithKind(err, ErrClient, "Failed to create bucket")
+		}
+	}
+	return nil
+}
+
+func (b *FSBucket) Ping(ctx context.Context) error {
+	return fsPing(b.root)
+}
+
+func (b *FSBucket) objPath(name string) (string, error) {
+	if name == "." || !fs.ValidPath(name) {
+		return "", kerrors.WithKind(nil, ErrClient, "Invalid object name")
+	}
+	return filepath.Join(b.root, fsDirObj, filepath.FromSlash(name)), nil
+}
+
+func (b *FSBucket) uploadPath(uploadid string) (string, error) {
+	if uploadid == "" || uploadid == "." || uploadid == ".." || strings.ContainsAny(uploadid, `/\`) {
+		return "", kerrors.WithKind(nil, ErrNotFound, "Failed to find multipart upload")
+	}
+	return filepath.Join(b.root, fsDirMultipart, uploadid), nil
+}
+
+// writeFile atomically writes a file at dst from a temporary file
+func (b *FSBucket) writeFile(dst string, write func(w io.Writer) error) (retErr error) {
+	f, err := os.CreateTemp(filepath.Join(b.root, fsDirTmp), "obj-*")
+	if err != nil {
+		return kerrors.WithKind(err, ErrClient, "Failed to create temp file")
+	}
+	renamed := false
+	defer func() {
+		if !renamed {
+			if err := os.Remove(f.Name()); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				retErr = errors.Join(retErr, kerrors.WithKind(err, ErrClient, "Failed to remove temp file"))
+			}
+		}
+	}()
+	if err := write(f); err != nil {
+		if cerr := f.Close(); cerr != nil {
+			err = errors.Join(err, kerrors.WithKind(cerr, ErrClient, "Failed to close temp file"))
+		}
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		if cerr := f.Close(); cerr != nil {
+			err = errors.Join(err, cerr)
+		}
+		return kerrors.WithKind(err, ErrClient, "Failed to sync temp file")
+	}
+	if err := f.Close(); err != nil {
+		return kerrors.WithKind(err, ErrClient, "Failed to close temp file")
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+		return kerrors.WithKind(err, ErrClient, "Failed to create object dir")
+	}
+	if err := os.Rename(f.Name(), dst); err != nil {
+		return kerrors.WithKind(err, ErrClient, "Failed to move object into place")
+	}
+	renamed = true
+	return nil
+}
+
+// writeObject atomically writes object data followed by its metadata. If the
+// etag of meta is unset, it is computed as the md5 of the data.
+func (b *FSBucket) writeObject(dst string, size int64, object io.Reader, meta fsObjectMeta) (*fsObjectMeta, error) {
+	if size >= 0 {
+		object = io.LimitReader(object, size)
+	}
+	if err := b.writeFile(dst, func(w io.Writer) error {
+		h := md5.New()
+		n, err := io.Copy(io.MultiWriter(w, h), object)
+		if err != nil {
+			return kerrors.WithKind(err, ErrClient, "Failed to write object")
+		}
+		if size >= 0 && n != size {
+			return kerrors.WithKind(nil, ErrClient, "Object size mismatch")
+		}
+		meta.Size = n
+		if meta.ETag == "" {
+			meta.ETag = hex.EncodeToString(h.Sum(nil))
+		}
+		meta.LastModified = time.Now().Round(0).Unix()
+		metab, err := kjson.Marshal(meta)
+		if err != nil {
+			return kerrors.WithKind(err, ErrClient, "Failed to encode object metadata")
+		}
+		metab = binary.BigEndian.AppendUint64(metab, uint64(len(metab)))
+		if _, err := w.Write(metab); err != nil {
+			return kerrors.WithKind(err, ErrClient, "Failed to write object metadata")
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+func readObjectMeta(f *os.File) (*fsObjectMeta, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, kerrors.WithKind(err, ErrClient, "Failed to stat object")
+	}
+	if info.IsDir() {
+		return nil, kerrors.WithKind(nil, ErrNotFound, "Failed to find object")
+	}
+	size := info.Size()
+	if size < fsTrailerLen {
+		return nil, kerrors.WithKind(nil, ErrClient, "Malformed object")
+	}
+	var trailer [fsTrailerLen]byte
+	if _, err := f.ReadAt(trailer[:], size-fsTrailerLen); err != nil {
+		return nil, kerrors.WithKind(err, ErrClient, "Failed to read object metadata")
+	}
+	metaLen := binary.BigEndian.Uint64(trailer[:])
+	if metaLen > uint64(size-fsTrailerLen) {
+		return nil, kerrors.WithKind(nil, ErrClient, "Malformed object")
+	}
+	b := make([]byte, metaLen)
+	if _, err := f.ReadAt(b, size-fsTrailerLen-int64(metaLen)); err != nil {
+		return nil, kerrors.WithKind(err, ErrClient, "Failed to read object metadata")
+	}
+	var meta fsObjectMeta
+	if err := kjson.Unmarshal(b, &meta); err != nil {
+		return nil, kerrors.WithKind(err, ErrClient, "Malformed object metadata")
+	}
+	if meta.Size != size-fsTrailerLen-int64(metaLen) {
+		return nil, kerrors.WithKind(nil, ErrClient, "Malformed object")
+	}
+	return &meta, nil
+}
+
+func openObject(p string) (_ *os.File, _ *fsObjectMeta, retErr error) {
+	f, err := os.Open(p)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ENOTDIR) {
+			return nil, nil, kerrors.WithKind(err, ErrNotFound, "Failed to find object")
+		}
+		return nil, nil, kerrors.WithKind(err, ErrClient, "Failed to open object")
+	}
+	meta, err := readObjectMeta(f)
+	if err != nil {
+		if cerr := f.Close(); cerr != nil {
+			err = errors.Join(err, kerrors.WithKind(cerr, ErrClient, "Failed to close object"))
+		}
+		return nil, nil, err
+	}
+	return f, meta, nil
+}
+
+func statObject(p string) (*fsObjectMeta, error) {
+	f, meta, err := openObject(p)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, kerrors.WithKind(err, ErrClient, "Failed to close object")
+	}
+	return meta, nil
+}
+
+func (m fsObjectMeta) objectInfo(name string) *ObjectInfo {
+	return &ObjectInfo{
+		Name:         name,
+		Size:         m.Size,
+		ContentType:  m.ContentType,
+		ETag:         m.ETag,
+		LastModified: m.LastModified,
+		UserMeta:     m.UserMeta,
+	}
+}
+
+// Stat returns metadata of an object from the bucket
+func (b *FSBucket) Stat(ctx context.Context, name string) (*ObjectInfo, error) {
+	p, err := b.objPath(name)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := statObject(p)
+	if err != nil {
+		return nil, err
+	}
+	return meta.objectInfo(name), nil
+}
+
+// Get gets an object from the bucket
+func (b *FSBucket) Get(ctx context.Context, name string) (io.ReadCloser, *ObjectInfo, error) {
+	p, err := b.objPath(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, meta, err := openObject(p)
+	if err != nil {
+		return nil, nil, err
+	}
+	return fsObjectReader{
+		Reader: io.NewSectionReader(f, 0, meta.Size),
+		Closer: f,
+	}, meta.objectInfo(name), nil
+}
+
+// Put puts a new object into the bucket
+func (b *FSBucket) Put(ctx context.Context, name string, contentType string, size int64, userMeta map[string]string, object io.Reader) error {
+	p, err := b.objPath(name)
+	if err != nil {
+		return err
+	}
+	if _, err := b.writeObject(p, size, object, fsObjectMeta{
+		ContentType: contentType,
+		UserMeta:    userMeta,
+	}); err != nil {
+		return kerrors.WithMsg(err, "Failed to save object to bucket")
+	}
+	return nil
+}
+
+// Del removes an object from the bucket
+func (b *FSBucket) Del(ctx context.Context, name string) error {
+	p, err := b.objPath(name)
+	if err != nil {
+		return err
+	}
+	if _, err := statObject(p); err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return kerrors.WithKind(err, ErrNotFound, "Failed to find object")
+		}
+		return kerrors.WithKind(err, ErrClient, "Failed to remove object")
+	}
+	// remove now empty parent dirs, which fails on the first non-empty dir
+	objroot := filepath.Join(b.root, fsDirObj)
+	for d := filepath.Dir(p); d != objroot && strings.HasPrefix(d, objroot); d = filepath.Dir(d) {
+		if err := os.Remove(d); err != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// List returns up to limit objects with names beginning with the prefix in
+// lexicographic order, starting after the object name after
+func (b *FSBucket) List(ctx context.Context, prefix string, limit int, after string) ([]ObjectInfo, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+	objroot := filepath.Join(b.root, fsDirObj)
+	start := objroot
+	if k := strings.LastIndex(prefix, "/"); k >= 0 {
+		if !fs.ValidPath(prefix[:k]) {
+			return nil, nil
+		}
+		start = filepath.Join(objroot, filepath.FromSlash(prefix[:k]))
+	}
+	var names []string
+	if err := filepath.WalkDir(start, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(objroot, p)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if strings.HasPrefix(name, prefix) && name > after {
+			names = append(names, name)
+		}
+		return nil
+	}); err != nil {
+		return nil, kerrors.WithKind(err, ErrClient, "Failed to list objects")
+	}
+	slices.Sort(names)
+	res := make([]ObjectInfo, 0, min(len(names), limit))
+	for _, i := range names {
+		meta, err := statObject(filepath.Join(objroot, filepath.FromSlash(i)))
+		if err != nil {
+			// objects removed during listing are skipped
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			return nil, kerrors.WithMsg(err, "Failed to list objects")
+		}
+		res = append(res, *meta.objectInfo(i))
+		if len(res) >= limit {
+			break
+		}
+	}
+	return res, nil
+}
+
+// PresignGet is unsupported for a local directory bucket
+func (b *FSBucket) PresignGet(ctx context.Context, name string, expiry time.Duration) (string, error) {
+	return "", kerrors.WithKind(nil, ErrUnsupported, "Presigned urls are unsupported by the fs objstore backend")
+}
+
+// PresignPut is unsupported for a local directory bucket
+func (b *FSBucket) PresignPut(ctx context.Context, name string, contentType string, expiry time.Duration) (string, error) {
+	return "", kerrors.WithKind(nil, ErrUnsupported, "Presigned urls are unsupported by the fs objstore backend")
+}
+
+// InitMultipart begins a multipart upload of an object and returns its upload
+// id
+func (b *FSBucket) InitMultipart(ctx context.Context, name string, contentType string, userMeta map[string]string) (string, error) {
+	if _, err := b.objPath(name); err != nil {
+		return "", err
+	}
+	uploadid, err := newUploadID()
+	if err != nil {
+		return "", err
+	}
+	p, err := b.uploadPath(uploadid)
+	if err != nil {
+		return "", err
+	}
+	meta, err := kjson.Marshal(fsUploadMeta{
+		Name:        name,
+		ContentType: contentType,
+		UserMeta:    userMeta,
+	})
+	if err != nil {
+		return "", kerrors.WithKind(err, ErrClient, "Failed to encode multipart upload metadata")
+	}
+	if err := b.writeFile(filepath.Join(p, fsFileUpload), func(w io.Writer) error {
+		if _, err := w.Write(meta); err != nil {
+			return kerrors.WithKind(err, ErrClient, "Failed to write multipart upload metadata")
+		}
+		return nil
+	}); err != nil {
+		return "", kerrors.WithMsg(err, "Failed to create multipart upload")
+	}
+	return uploadid, nil
+}
+
+func (b *FSBucket) getUpload(name string, uploadid string) (string, *fsUploadMeta, error) {
+	p, err := b.uploadPath(uploadid)
+	if err != nil {
+		return "", nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(p, fsFileUpload))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", nil, kerrors.WithKind(err, ErrNotFound, "Failed to find multipart upload")
+		}
+		return "", nil, kerrors.WithKind(err, ErrClient, "Failed to read multipart upload")
+	}
+	var meta fsUploadMeta
+	if err := kjson.Unmarshal(data, &meta); err != nil {
+		return "", nil, kerrors.WithKind(err, ErrClient, "Malformed multipart upload metadata")
+	}
+	if meta.Name != name {
+		return "", nil, kerrors.WithKind(nil, ErrNotFound, "Failed to find multipart upload")
+	}
+	return p, &meta, nil
+}
+
+func partPath(uploadPath string, number int) string {
+	return filepath.Join(uploadPath, fsPartPrefix+strconv.Itoa(number))
+}
+
+func (m fsObjectMeta) partInfo(number int) *PartInfo {
+	return &PartInfo{
+		Number:       number,
+		Size:         m.Size,
+		ETag:         m.ETag,
+		LastModified: m.LastModified,
+	}
+}
+
+// PutPart uploads a part of a multipart upload
+func (b *FSBucket) PutPart(ctx context.Context, name string, uploadid string, number int, size int64, part io.Reader) (*PartInfo, error) {
+	if number < 1 {
+		return nil, kerrors.WithKind(nil, ErrClient, "Invalid part number")
+	}
+	p, _, err := b.getUpload(name, uploadid)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := b.writeObject(partPath(p, number), size, part, fsObjectMeta{})
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to upload part")
+	}
+	return meta.partInfo(number), nil
+}
+
+// PresignPart is unsupported for a local directory bucket
+func (b *FSBucket) PresignPart(ctx context.Context, name string, uploadid string, number int, expiry time.Duration) (string, error) {
+	return "", kerrors.WithKind(nil, ErrUnsupported, "Presigned urls are unsupported by the fs objstore backend")
+}
+
+// ListParts returns the uploaded parts of a multipart upload ordered by part
+// number
+func (b *FSBucket) ListParts(ctx context.Context, name string, uploadid string) ([]PartInfo, error) {
+	p, _, err := b.getUpload(name, uploadid)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(p)
+	if err != nil {
+		return nil, kerrors.WithKind(err, ErrClient, "Failed to list parts")
+	}
+	var res []PartInfo
+	for _, i := range entries {
+		num, ok := strings.CutPrefix(i.Name(), fsPartPrefix)
+		if !ok {
+			continue
+		}
+		number, err := strconv.Atoi(num)
+		if err != nil {
+			continue
+		}
+		meta, err := statObject(partPath(p, number))
+		if err != nil {
+			return nil, kerrors.WithMsg(err, "Failed to list parts")
+		}
+		res = append(res, *meta.partInfo(number))
+	}
+	slices.SortFunc(res, func(a, b PartInfo) int {
+		return a.Number - b.Number
+	})
+	return res, nil
+}
+
+// CompleteMultipart assembles the uploaded parts into an object
+func (b *FSBucket) CompleteMultipart(ctx context.Context, name string, uploadid string, parts []PartInfo) (retErr error) {
+	p, upload, err := b.getUpload(name, uploadid)
+	if err != nil {
+		return err
+	}
+	objPath, err := b.objPath(name)
+	if err != nil {
+		return err
+	}
+	var files []*os.File
+	defer func() {
+		for _, i := range files {
+			if err := i.Close(); err != nil {
+				retErr = errors.Join(retErr, kerrors.WithKind(err, ErrClient, "Failed to close part"))
+			}
+		}
+	}()
+	var readers []io.Reader
+	var size int64
+	parts, err = checkCompleteParts(parts, func(number int) (*PartInfo, bool) {
+		f, meta, err := openObject(partPath(p, number))
+		if err != nil {
+			return nil, false
+		}
+		files = append(files, f)
+		readers = append(readers, io.NewSectionReader(f, 0, meta.Size))
+		size += meta.Size
+		return meta.partInfo(number), true
+	})
+	if err != nil {
+		return err
+	}
+	etag, err := multipartETag(parts)
+	if err != nil {
+		return err
+	}
+	if _, err := b.writeObject(objPath, size, io.MultiReader(readers...), fsObjectMeta{
+		ContentType: upload.ContentType,
+		ETag:        etag,
+		UserMeta:    upload.UserMeta,
+	}); err != nil {
+		return kerrors.WithMsg(err, "Failed to complete multipart upload")
+	}
+	if err := os.RemoveAll(p); err != nil {
+		return kerrors.WithKind(err, ErrClient, "Failed to remove multipart upload")
+	}
+	return nil
+}
+
+// AbortMultipart aborts a multipart upload and removes its uploaded parts
+func (b *FSBucket) AbortMultipart(ctx context.Context, name string, uploadid string) error {
+	p, _, err := b.getUpload(name, uploadid)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(p); err != nil {
+		return kerrors.WithKind(err, ErrClient, "Failed to abort multipart upload")
+	}
+	return nil
+}
+
+func (b *FSBucket) Subdir(prefix string) Dir {
+	return &dir{
+		prefix: prefix,
+		base:   b,
+	}
+}
@@ -0,0 +1,404 @@
+package objstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"xorkevin.dev/governor/util/uid"
+	"xorkevin.dev/kerrors"
+)
+
+var _ Bucket = (*MemBucket)(nil)
+
+type (
+	memStore struct {
+		mu      sync.Mutex
+		buckets map[string]*MemBucket
+	}
+
+	// MemBucket is an in-memory [Bucket]
+	MemBucket struct {
+		mu      sync.RWMutex
+		objects map[string]memObject
+		uploads map[string]*memUpload
+	}
+
+	memObject struct {
+		info ObjectInfo
+		data []byte
+	}
+
+	memUpload struct {
+		name        string
+		contentType string
+		userMeta    map[string]string
+		parts       map[int]memObject
+	}
+)
+
+func newMemStore() *memStore {
+	return &memStore{
+		buckets: map[string]*MemBucket{},
+	}
+}
+
+func (s *memStore) getBucket(name string) *MemBucket {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.buckets[name]
+	if !ok {
+		b = NewMemBucket()
+		s.buckets[name] = b
+	}
+	return b
+}
+
+func (s *memStore) delBucket(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.buckets[name]
+	if !ok {
+		return kerrors.WithKind(nil, ErrNotFound, "Failed to get bucket")
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.objects) != 0 {
+		return kerrors.WithKind(nil, ErrClient, "Failed to remove non-empty bucket")
+	}
+	delete(s.buckets, name)
+	return nil
+}
+
+// NewMemBucket creates a new in-memory bucket
+func NewMemBucket() *MemBucket {
+	return &MemBucket{
+		objects: map[string]memObject{},
+		uploads: map[string]*memUpload{},
+	}
+}
+
+// Init is a no-op for an in-memory bucket
+func (b *MemBucket) Init(ctx context.Context) error {
+	return nil
+}
+
+func (b *MemBucket) Ping(ctx context.Context) error {
+	return nil
+}
+
+func cloneObjectInfo(info ObjectInfo) *ObjectInfo {
+	info.UserMeta = maps.Clone(info.UserMeta)
+	return &info
+}
+
+// Stat returns metadata of an object from the bucket
+func (b *MemBucket) Stat(ctx context.Context, name string) (*ObjectInfo, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	obj, ok := b.objects[name]
+	if !ok {
+		return nil, kerrors.WithKind(nil, ErrNotFound, "Failed to find object")
+	}
+	return cloneObjectInfo(obj.info), nil
+}
+
+// Get gets an object from the bucket
+func (b *MemBucket) Get(ctx context.Context, name string) (io.ReadCloser, *ObjectInfo, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	obj, ok := b.objects[name]
+	if !ok {
+		return nil, nil, kerrors.WithKind(nil, ErrNotFound, "Failed to find object")
+	}
+	// object data is never modified after being stored, and may be shared
+	return io.NopCloser(bytes.NewReader(obj.data)), cloneObjectInfo(obj.info), nil
+}
+
+func readObject(size int64, object io.Reader) ([]byte, string, error) {
+	if size >= 0 {
+		object = io.LimitReader(object, size)
+	}
+	var buf bytes.Buffer
+	h := md5.New()
+	n, err := io.Copy(io.MultiWriter(&buf, h), object)
+	if err != nil {
+		return nil, "", kerrors.WithKind(err, ErrClient, "Failed to read object")
+	}
+	if size >= 0 && n != size {
+		return nil, "", kerrors.WithKind(nil, ErrClient, "Object size mismatch")
+	}
+	return buf.Bytes(), hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Put puts a new object into the bucket
+func (b *MemBucket) Put(ctx context.Context, name string, contentType string, size int64, userMeta map[string]string, object io.Reader) error {
+	data, etag, err := readObject(size, object)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.objects[name] = memObject{
+		info: ObjectInfo{
+			Name:         name,
+			Size:         int64(len(data)),
+			ContentType:  contentType,
+			ETag:         etag,
+			LastModified: time.Now().Round(0).Unix(),
+			UserMeta:     maps.Clone(userMeta),
+		},
+		data: data,
+	}
+	return nil
+}
+
+// Del removes an object from the bucket
+func (b *MemBucket) Del(ctx context.Context, name string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.objects[name]; !ok {
+		return kerrors.WithKind(nil, ErrNotFound, "Failed to find object")
+	}
+	delete(b.objects, name)
+	return nil
+}
+
+// List returns up to limit objects with names beginning with the prefix in
+// lexicographic order, starting after the object name after
+func (b *MemBucket) List(ctx context.Context, prefix string, limit int, after string) ([]ObjectInfo, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	names := make([]string, 0, len(b.objects))
+	for k := range b.objects {
+		if strings.HasPrefix(k, prefix) && k > after {
+			names = append(names, k)
+		}
+	}
+	slices.Sort(names)
+	if len(names) > limit {
+		names = names[:limit]
+	}
+	res := make([]ObjectInfo, 0, len(names))
+	for _, i := range names {
+		res = append(res, *cloneObjectInfo(b.objects[i].info))
+	}
+	return res, nil
+}
+
+// PresignGet is unsupported for an in-memory bucket
+func (b *MemBucket) PresignGet(ctx context.Context, name string, expiry time.Duration) (string, error) {
+	return "", kerrors.WithKind(nil, ErrUnsupported, "Presigned urls are unsupported by the mem objstore backend")
+}
+
+// PresignPut is unsupported for an in-memory bucket
+func (b *MemBucket) PresignPut(ctx context.Context, name string, contentType string, expiry time.Duration) (string, error) {
+	return "", kerrors.WithKind(nil, ErrUnsupported, "Presigned urls are unsupported by the mem objstore backend")
+}
+
+func newUploadID() (string, error) {
+	u, err := uid.New()
+	if err != nil {
+		return "", kerrors.WithMsg(err, "Failed to create upload id")
+	}
+	return u.Base64(), nil
+}
+
+// InitMultipart begins a multipart upload of an object and returns its upload
+// id
+func (b *MemBucket) InitMultipart(ctx context.Context, name string, contentType string, userMeta map[string]string) (string, error) {
+	uploadid, err := newUploadID()
+	if err != nil {
+		return "", err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.uploads[uploadid] = &memUpload{
+		name:        name,
+		contentType: contentType,
+		userMeta:    maps.Clone(userMeta),
+		parts:       map[int]memObject{},
+	}
+	return uploadid, nil
+}
+
+func (b *MemBucket) getUploadLocked(name string, uploadid string) (*memUpload, error) {
+	upload, ok := b.uploads[uploadid]
+	if !ok || upload.name != name {
+		return nil, kerrors.WithKind(nil, ErrNotFound, "Failed to find multipart upload")
+	}
+	return upload, nil
+}
+
+// PutPart uploads a part of a multipart upload
+func (b *MemBucket) PutPart(ctx context.Context, name string, uploadid string, number int, size int64, part io.Reader) (*PartInfo, error) {
+	if number < 1 {
+		return nil, kerrors.WithKind(nil, ErrClient, "Invalid part number")
+	}
+	data, etag, err := readObject(size, part)
+	if err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	upload, err := b.getUploadLocked(name, uploadid)
+	if err != nil {
+		return nil, err
+	}
+	p := memObject{
+		info: ObjectInfo{
+			Size:         int64(len(data)),
+			ETag:         etag,
+			LastModified: time.Now().Round(0).Unix(),
+		},
+		data: data,
+	}
+	upload.parts[number] = p
+	return &PartInfo{
+		Number:       number,
+		Size:         p.info.Size,
+		ETag:         p.info.ETag,
+		LastModified: p.info.LastModified,
+	}, nil
+}
+
+// PresignPart is unsupported for an in-memory bucket
+func (b *MemBucket) PresignPart(ctx context.Context, name string, uploadid string, number int, expiry time.Duration) (string, error) {
+	return "", kerrors.WithKind(nil, ErrUnsupported, "Presigned urls are unsupported by the mem objstore backend")
+}
+
+// ListParts returns the uploaded parts of a multipart upload ordered by part
+// number
+func (b *MemBucket) ListParts(ctx context.Context, name string, uploadid string) ([]PartInfo, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	upload, err := b.getUploadLocked(name, uploadid)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]PartInfo, 0, len(upload.parts))
+	for k, v := range upload.parts {
+		res = append(res, PartInfo{
+			Number:       k,
+			Size:         v.info.Size,
+			ETag:         v.info.ETag,
+			LastModified: v.info.LastModified,
+		})
+	}
+	slices.SortFunc(res, func(a, b PartInfo) int {
+		return a.Number - b.Number
+	})
+	return res, nil
+}
+
+// multipartETag computes the etag of a multipart object in the same way as
+// S3, which is the md5 of the concatenated binary part md5s followed by the
+// number of parts
+func multipartETag(parts []PartInfo) (string, error) {
+	h := md5.New()
+	for _, i := range parts {
+		b, err := hex.DecodeString(i.ETag)
+		if err != nil {
+			return "", kerrors.WithKind(err, ErrClient, "Invalid part etag")
+		}
+		h.Write(b)
+	}
+	return hex.EncodeToString(h.Sum(nil)) + "-" + strconv.Itoa(len(parts)), nil
+}
+
+// checkCompleteParts validates that the parts to complete are in ascending
+// order and match the uploaded parts
+func checkCompleteParts(parts []PartInfo, uploaded func(number int) (*PartInfo, bool)) ([]PartInfo, error) {
+	if len(parts) == 0 {
+		return nil, kerrors.WithKind(nil, ErrClient, "No parts to complete")
+	}
+	res := make([]PartInfo, 0, len(parts))
+	prev := 0
+	for _, i := range parts {
+		if i.Number <= prev {
+			return nil, kerrors.WithKind(nil, ErrClient, "Invalid part order")
+		}
+		prev = i.Number
+		p, ok := uploaded(i.Number)
+		if !ok || p.ETag != strings.Trim(i.ETag, `"`) {
+			return nil, kerrors.WithKind(nil, ErrClient, "Invalid part")
+		}
+		res = append(res, *p)
+	}
+	return res, nil
+}
+
+// CompleteMultipart assembles the uploaded parts into an object
+func (b *MemBucket) CompleteMultipart(ctx context.Context, name string, uploadid string, parts []PartInfo) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	upload, err := b.getUploadLocked(name, uploadid)
+	if err != nil {
+		return err
+	}
+	parts, err = checkCompleteParts(parts, func(number int) (*PartInfo, bool) {
+		p, ok := upload.parts[number]
+		if !ok {
+			return nil, false
+		}
+		return &PartInfo{
+			Number:       number,
+			Size:         p.info.Size,
+			ETag:         p.info.ETag,
+			LastModified: p.info.LastModified,
+		}, true
+	})
+	if err != nil {
+		return err
+	}
+	etag, err := multipartETag(parts)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	for _, i := range parts {
+		buf.Write(upload.parts[i.Number].data)
+	}
+	b.objects[name] = memObject{
+		info: ObjectInfo{
+			Name:         name,
+			Size:         int64(buf.Len()),
+			ContentType:  upload.contentType,
+			ETag:         etag,
+			LastModified: time.Now().Round(0).Unix(),
+			UserMeta:     upload.userMeta,
+		},
+		data: buf.Bytes(),
+	}
+	delete(b.uploads, uploadid)
+	return nil
+}
+
+// AbortMultipart aborts a multipart upload and removes its uploaded parts
+func (b *MemBucket) AbortMultipart(ctx context.Context, name string, uploadid string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, err := b.getUploadLocked(name, uploadid); err != nil {
+		return err
+	}
+	delete(b.uploads, uploadid)
+	return nil
+}
+
+func (b *MemBucket) Subdir(prefix string) Dir {
+	return &dir{
+		prefix: prefix,
+		base:   b,
+	}
+}
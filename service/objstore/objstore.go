@@ -5,6 +5,11 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
@@ -19,6 +24,12 @@ const (
 	subdirpathSeparator = "/"
 )
 
+const (
+	backendMinio = "minio"
+	backendFS    = "fs"
+	backendMem   = "mem"
+)
+
 type (
 	// Objstore is a service wrapper around a object storage client
 	Objstore interface {
@@ -28,38 +39,52 @@ type (
 	}
 
 	objstoreClient struct {
-		client *minio.Client
-		auth   minioauth
+		client  *minio.Client
+		presign *minio.Client
+		auth    minioauth
 	}
 
 	Service struct {
-		lc         *lifecycle.Lifecycle[objstoreClient]
-		clientname string
-		addr       string
-		sslmode    bool
-		location   string
-		config     governor.SecretReader
-		log        *klog.LevelLogger
-		hbfailed   int
-		hbmaxfail  int
-		wg         *ksync.WaitGroup
+		lc               *lifecycle.Lifecycle[objstoreClient]
+		backend          string
+		fsdir            string
+		mem              *memStore
+		clientname       string
+		addr             string
+		sslmode          bool
+		location         string
+		presignAddr      string
+		presignSSLMode   bool
+		presignMaxExpiry time.Duration
+		config           governor.SecretReader
+		log              *klog.LevelLogger
+		hbfailed         int
+		hbmaxfail        int
+		wg               *ksync.WaitGroup
 	}
 )
 
 // New creates a new object store service instance
 func New() *Service {
 	return &Service{
+		mem:      newMemStore(),
 		hbfailed: 0,
 		wg:       ksync.NewWaitGroup(),
 	}
 }
 
 func (s *Service) Register(r governor.ConfigRegistrar) {
+	r.SetDefault("backend", backendMinio)
+	r.SetDefault("fs.dir", "objstore")
 	r.SetDefault("auth", "")
 	r.SetDefault("host", "localhost")
 	r.SetDefault("port", "9000")
 	r.SetDefault("sslmode", false)
 	r.SetDefault("location", "us-east-1")
+	r.SetDefault("presign.host", "")
+	r.SetDefault("presign.port", "")
+	r.SetDefault("presign.sslmode", false)
+	r.SetDefault("presign.maxexpiry", "1h")
 	r.SetDefault("hbinterval", "5s")
 	r.SetDefault("hbmaxfail", 5)
 }
@@ -71,12 +96,16 @@ var (
 	ErrClient errClient
 	// ErrNotFound is returned when an object is not found
 	ErrNotFound errNotFound
+	// ErrUnsupported is returned when an operation is unsupported by the
+	// objstore backend
+	ErrUnsupported errUnsupported
 )
 
 type (
-	errConn     struct{}
-	errClient   struct{}
-	errNotFound struct{}
+	errConn        struct{}
+	errClient      struct{}
+	errNotFound    struct{}
+	errUnsupported struct{}
 )
 
 func (e errConn) Error() string {
@@ -91,14 +120,54 @@ func (e errNotFound) Error() string {
 	return "Object not found"
 }
 
+func (e errUnsupported) Error() string {
+	return "Objstore operation unsupported"
+}
+
 func (s *Service) Init(ctx context.Context, r governor.ConfigReader, kit governor.ServiceKit) error {
 	s.log = klog.NewLevelLogger(kit.Logger)
 	s.config = r
 	s.clientname = r.Config().Instance
 
+	s.backend = r.GetStr("backend")
+	switch s.backend {
+	case backendMinio:
+	case backendFS:
+		s.fsdir = r.GetStr("fs.dir")
+		if s.fsdir == "" {
+			return kerrors.WithKind(nil, governor.ErrInvalidConfig, "Empty fs dir")
+		}
+		s.log.Info(ctx, "Loaded config",
+			klog.AString("backend", s.backend),
+			klog.AString("fs.dir", s.fsdir),
+		)
+		return nil
+	case backendMem:
+		s.log.Info(ctx, "Loaded config",
+			klog.AString("backend", s.backend),
+		)
+		return nil
+	default:
+		return kerrors.WithKind(nil, governor.ErrInvalidConfig, "Invalid objstore backend")
+	}
+
 	s.addr = fmt.Sprintf("%s:%s", r.GetStr("host"), r.GetStr("port"))
 	s.sslmode = r.GetBool("sslmode")
 	s.location = r.GetStr("location")
+	s.presignAddr = s.addr
+	s.presignSSLMode = s.sslmode
+	if host := r.GetStr("presign.host"); host != "" {
+		s.presignAddr = host
+		if port := r.GetStr("presign.port"); port != "" {
+			s.presignAddr = fmt.Sprintf("%s:%s", host, port)
+		}
+		s.presignSSLMode = r.GetBool("presign.sslmode")
+	}
+	presignMaxExpiry, err := r.GetDuration("presign.maxexpiry")
+	if err != nil {
+		return kerrors.WithMsg(err, "Failed to parse presign max expiry")
+	}
+	s.presignMaxExpiry = presignMaxExpiry
 	hbinterval, err := r.GetDuration("hbinterval")
 	if err != nil {
 		return kerrors.WithMsg(err, "Failed to parse hbinterval")
@@ -106,9 +175,13 @@ func (s *Service) Init(ctx context.Context, r governor.ConfigReader, kit governo
 	s.hbmaxfail = r.GetInt("hbmaxfail")
 
 	s.log.Info(ctx, "Loaded config",
+		klog.AString("backend", s.backend),
 		klog.AString("addr", s.addr),
 		klog.ABool("sslmode", s.sslmode),
 		klog.AString("location", s.location),
+		klog.AString("presign.addr", s.presignAddr),
+		klog.ABool("presign.sslmode", s.presignSSLMode),
+		klog.AString("presign.maxexpiry", s.presignMaxExpiry.String()),
 		klog.AString("hbinterval", hbinterval.String()),
 		klog.AInt("hbmaxfail", s.hbmaxfail),
 	)
@@ -198,6 +271,16 @@ func (s *Service) handleGetClient(ctx context.Context, m *lifecycle.State[objsto
 		s.config.InvalidateSecret("auth")
 		return nil, kerrors.WithKind(err, ErrConn, "Failed to ping objstore")
 	}
+	// presigned urls are signed locally against the publicly reachable
+	// endpoint, and setting the region prevents bucket location lookups
+	presignClient, err := minio.New(s.presignAddr, &minio.Options{
+		Creds:  credentials.NewStaticV4(auth.Username, auth.Password, ""),
+		Secure: s.presignSSLMode,
+		Region: s.location,
+	})
+	if err != nil {
+		return nil, kerrors.WithKind(err, ErrClient, "Failed to create objstore presign client")
+	}
 
 	m.Stop(ctx)
 
@@ -207,8 +290,9 @@ func (s *Service) handleGetClient(ctx context.Context, m *lifecycle.State[objsto
 	)
 
 	client := &objstoreClient{
-		client: objClient,
-		auth:   auth,
+		client:  objClient,
+		presign: presignClient,
+		auth:    auth,
 	}
 	m.Store(client)
 
@@ -234,15 +318,18 @@ func (s *Service) Setup(ctx context.Context, req governor.ReqSetup) error {
 }
 
 func (s *Service) Health(ctx context.Context) error {
+	if s.backend != backendMinio {
+		return nil
+	}
 	if s.lc.Load(ctx) == nil {
 		return kerrors.WithKind(nil, ErrConn, "Objstore service not ready")
 	}
 	return nil
 }
 
-func (s *Service) getClient(ctx context.Context) (*minio.Client, error) {
+func (s *Service) getObjstoreClient(ctx context.Context) (*objstoreClient, error) {
 	if client := s.lc.Load(ctx); client != nil {
-		return client.client, nil
+		return client, nil
 	}
 
 	client, err := s.lc.Construct(ctx)
@@ -250,9 +337,32 @@ func (s *Service) getClient(ctx context.Context) (*minio.Client, error) {
 		// explicitly return nil in order to prevent usage of any cached client
 		return nil, err
 	}
+	return client, nil
+}
+
+func (s *Service) getClient(ctx context.Context) (*minio.Client, error) {
+	client, err := s.getObjstoreClient(ctx)
+	if err != nil {
+		return nil, err
+	}
 	return client.client, nil
 }
 
+func (s *Service) getPresignClient(ctx context.Context) (*minio.Client, error) {
+	client, err := s.getObjstoreClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.presign, nil
+}
+
+func (s *Service) presignExpiry(expiry time.Duration) time.Duration {
+	if expiry <= 0 || expiry > s.presignMaxExpiry {
+		return s.presignMaxExpiry
+	}
+	return expiry
+}
+
 func (s *Service) clientPing(ctx context.Context, client *minio.Client) error {
 	if _, err := client.GetBucketLocation(ctx, "healthcheck-probe-"+s.clientname); err != nil {
 		if minio.ToErrorResponse(err).StatusCode != http.StatusNotFound {
@@ -263,6 +373,12 @@ func (s *Service) clientPing(ctx context.Context, client *minio.Client) error {
 }
 
 func (s *Service) Ping(ctx context.Context) error {
+	switch s.backend {
+	case backendFS:
+		return fsPing(s.fsdir)
+	case backendMem:
+		return nil
+	}
 	client, err := s.getClient(ctx)
 	if err != nil {
 		return err
@@ -271,7 +387,25 @@ func (s *Service) Ping(ctx context.Context) error {
 }
 
 // GetBucket returns the bucket of the given name
+//
+// Buckets are usually retrieved before the service is initialized, so the
+// backend of the bucket is resolved on each use.
 func (s *Service) GetBucket(name string) Bucket {
+	return &svcBucket{
+		s:    s,
+		name: name,
+	}
+}
+
+// getBackendBucket returns the bucket of the given name from the configured
+// backend
+func (s *Service) getBackendBucket(name string) Bucket {
+	switch s.backend {
+	case backendFS:
+		return NewFSBucket(filepath.Join(s.fsdir, name))
+	case backendMem:
+		return s.mem.getBucket(name)
+	}
 	return &bucket{
 		s:        s,
 		name:     name,
@@ -281,6 +415,12 @@ func (s *Service) GetBucket(name string) Bucket {
 
 // DelBucket deletes the bucket if it exists
 func (s *Service) DelBucket(ctx context.Context, name string) error {
+	switch s.backend {
+	case backendFS:
+		return fsDelBucket(filepath.Join(s.fsdir, name))
+	case backendMem:
+		return s.mem.delBucket(name)
+	}
 	client, err := s.getClient(ctx)
 	if err != nil {
 		return err
@@ -297,6 +437,7 @@ func (s *Service) DelBucket(ctx context.Context, name string) error {
 type (
 	// ObjectInfo is stored object metadata
 	ObjectInfo struct {
+		Name         string
 		Size         int64
 		ContentType  string
 		ETag         string
@@ -304,6 +445,14 @@ type (
 		UserMeta     map[string]string
 	}
 
+	// PartInfo is uploaded multipart upload part metadata
+	PartInfo struct {
+		Number       int
+		Size         int64
+		ETag         string
+		LastModified int64
+	}
+
 	// Dir is a collection of objects in the store at a specified directory
 	Dir interface {
 		Ping(ctx context.Context) error
@@ -311,6 +460,15 @@ type (
 		Get(ctx context.Context, name string) (io.ReadCloser, *ObjectInfo, error)
 		Put(ctx context.Context, name string, contentType string, size int64, userMeta map[string]string, object io.Reader) error
 		Del(ctx context.Context, name string) error
+		List(ctx context.Context, prefix string, limit int, after string) ([]ObjectInfo, error)
+		PresignGet(ctx context.Context, name string, expiry time.Duration) (string, error)
+		PresignPut(ctx context.Context, name string, contentType string, expiry time.Duration) (string, error)
+		InitMultipart(ctx context.Context, name string, contentType string, userMeta map[string]string) (string, error)
+		PutPart(ctx context.Context, name string, uploadid string, number int, size int64, part io.Reader) (*PartInfo, error)
+		PresignPart(ctx context.Context, name string, uploadid string, number int, expiry time.Duration) (string, error)
+		ListParts(ctx context.Context, name string, uploadid string) ([]PartInfo, error)
+		CompleteMultipart(ctx context.Context, name string, uploadid string, parts []PartInfo) error
+		AbortMultipart(ctx context.Context, name string, uploadid string) error
 		Subdir(name string) Dir
 	}
 
@@ -320,6 +478,11 @@ type (
 		Init(ctx context.Context) error
 	}
 
+	svcBucket struct {
+		s    *Service
+		name string
+	}
+
 	bucket struct {
 		s        *Service
 		name     string
@@ -328,10 +491,77 @@ type (
 
 	dir struct {
 		prefix string
-		base   *bucket
+		base   Bucket
 	}
 )
 
+func (b *svcBucket) Init(ctx context.Context) error {
+	return b.s.getBackendBucket(b.name).Init(ctx)
+}
+
+func (b *svcBucket) Ping(ctx context.Context) error {
+	return b.s.getBackendBucket(b.name).Ping(ctx)
+}
+
+func (b *svcBucket) Stat(ctx context.Context, name string) (*ObjectInfo, error) {
+	return b.s.getBackendBucket(b.name).Stat(ctx, name)
+}
+
+func (b *svcBucket) Get(ctx context.Context, name string) (io.ReadCloser, *ObjectInfo, error) {
+	return b.s.getBackendBucket(b.name).Get(ctx, name)
+}
+
+func (b *svcBucket) Put(ctx context.Context, name string, contentType string, size int64, userMeta map[string]string, object io.Reader) error {
+	return b.s.getBackendBucket(b.name).Put(ctx, name, contentType, size, userMeta, object)
+}
+
+func (b *svcBucket) Del(ctx context.Context, name string) error {
+	return b.s.getBackendBucket(b.name).Del(ctx, name)
+}
+
+func (b *svcBucket) List(ctx context.Context, prefix string, limit int, after string) ([]ObjectInfo, error) {
+	return b.s.getBackendBucket(b.name).List(ctx, prefix, limit, after)
+}
+
+func (b *svcBucket) PresignGet(ctx context.Context, name string, expiry time.Duration) (string, error) {
+	return b.s.getBackendBucket(b.name).PresignGet(ctx, name, expiry)
+}
+
+func (b *svcBucket) PresignPut(ctx context.Context, name string, contentType string, expiry time.Duration) (string, error) {
+	return b.s.getBackendBucket(b.name).PresignPut(ctx, name, contentType, expiry)
+}
+
+func (b *svcBucket) InitMultipart(ctx context.Context, name string, contentType string, userMeta map[string]string) (string, error) {
+	return b.s.getBackendBucket(b.name).InitMultipart(ctx, name, contentType, userMeta)
+}
+
+func (b *svcBucket) PutPart(ctx context.Context, name string, uploadid string, number int, size int64, part io.Reader) (*PartInfo, error) {
+	return b.s.getBackendBucket(b.name).PutPart(ctx, name, uploadid, number, size, part)
+}
+
+func (b *svcBucket) PresignPart(ctx context.Context, name string, uploadid string, number int, expiry time.Duration) (string, error) {
+	return b.s.getBackendBucket(b.name).PresignPart(ctx, name, uploadid, number, expiry)
+}
+
+func (b *svcBucket) ListParts(ctx context.Context, name string, uploadid string) ([]PartInfo, error) {
+	return b.s.getBackendBucket(b.name).ListParts(ctx, name, uploadid)
+}
+
+func (b *svcBucket) CompleteMultipart(ctx context.Context, name string, uploadid string, parts []PartInfo) error {
+	return b.s.getBackendBucket(b.name).CompleteMultipart(ctx, name, uploadid, parts)
+}
+
+func (b *svcBucket) AbortMultipart(ctx context.Context, name string, uploadid string) error {
+	return b.s.getBackendBucket(b.name).AbortMultipart(ctx, name, uploadid)
+}
+
+func (b *svcBucket) Subdir(prefix string) Dir {
+	return &dir{
+		prefix: prefix,
+		base:   b,
+	}
+}
+
 // Init creates the bucket if it does not exist
 func (b *bucket) Init(ctx context.Context) error {
 	client, err := b.s.getClient(ctx)
@@ -370,6 +600,7 @@ func (b *bucket) Stat(ctx context.Context, name string) (*ObjectInfo, error) {
 		return nil, kerrors.WithKind(err, ErrClient, "Failed to stat object")
 	}
 	return &ObjectInfo{
+		Name:         name,
 		Size:         info.Size,
 		ContentType:  info.ContentType,
 		ETag:         info.ETag,
@@ -396,6 +627,7 @@ func (b *bucket) Get(ctx context.Context, name string) (io.ReadCloser, *ObjectIn
 		return nil, nil, kerrors.WithKind(err, ErrClient, "Failed to stat object")
 	}
 	return obj, &ObjectInfo{
+		Name:         name,
 		Size:         info.Size,
 		ContentType:  info.ContentType,
 		ETag:         info.ETag,
@@ -431,29 +663,324 @@ func (b *bucket) Del(ctx context.Context, name string) error {
 	return nil
 }
 
+// List returns up to limit objects with names beginning with the prefix in
+// lexicographic order, starting after the object name after
+func (b *bucket) List(ctx context.Context, prefix string, limit int, after string) ([]ObjectInfo, error) {
+	client, err := b.s.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		return nil, nil
+	}
+	// cancel the listing once limit objects have been read in order to stop
+	// retrieving further pages
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	res := make([]ObjectInfo, 0, limit)
+	for i := range client.ListObjects(ctx, b.name, minio.ListObjectsOptions{
+		Prefix:     prefix,
+		Recursive:  true,
+		MaxKeys:    limit,
+		StartAfter: after,
+		// object listings only include the content type and user metadata
+		// when requested
+		WithMetadata: true,
+	}) {
+		if i.Err != nil {
+			return nil, kerrors.WithKind(i.Err, ErrClient, "Failed to list objects")
+		}
+		contentType, userMeta := listedObjectMeta(i.ContentType, i.UserMetadata)
+		res = append(res, ObjectInfo{
+			Name:         i.Key,
+			Size:         i.Size,
+			ContentType:  contentType,
+			ETag:         i.ETag,
+			LastModified: i.LastModified.Unix(),
+			UserMeta:     userMeta,
+		})
+		if len(res) >= limit {
+			break
+		}
+	}
+	return res, nil
+}
+
+const (
+	headerContentType    = "Content-Type"
+	headerUserMetaPrefix = "X-Amz-Meta-"
+	mediaTypeOctet       = "application/octet-stream"
+)
+
+// listedObjectMeta returns the content type and user metadata of a listed
+// object in the same form as [bucket.Stat]
+//
+// The metadata of a listed object is returned as headers rather than being
+// parsed like that of a stat object.
+func listedObjectMeta(contentType string, meta map[string]string) (string, map[string]string) {
+	var userMeta map[string]string
+	for k, v := range meta {
+		k = http.CanonicalHeaderKey(k)
+		if k == headerContentType {
+			if contentType == "" {
+				contentType = v
+			}
+			continue
+		}
+		if name, ok := strings.CutPrefix(k, headerUserMetaPrefix); ok {
+			if userMeta == nil {
+				userMeta = map[string]string{}
+			}
+			userMeta[name] = v
+		}
+	}
+	if contentType == "" {
+		contentType = mediaTypeOctet
+	}
+	return contentType, userMeta
+}
+
+// PresignGet returns a presigned url to get an object from the bucket
+func (b *bucket) PresignGet(ctx context.Context, name string, expiry time.Duration) (string, error) {
+	client, err := b.s.getPresignClient(ctx)
+	if err != nil {
+		return "", err
+	}
+	u, err := client.PresignedGetObject(ctx, b.name, name, b.s.presignExpiry(expiry), nil)
+	if err != nil {
+		return "", kerrors.WithKind(err, ErrClient, "Failed to presign get object")
+	}
+	return u.String(), nil
+}
+
+// PresignPut returns a presigned url to put an object with the content type
+// into the bucket
+func (b *bucket) PresignPut(ctx context.Context, name string, contentType string, expiry time.Duration) (string, error) {
+	client, err := b.s.getPresignClient(ctx)
+	if err != nil {
+		return "", err
+	}
+	var headers http.Header
+	if contentType != "" {
+		headers = http.Header{}
+		headers.Set("Content-Type", contentType)
+	}
+	u, err := client.PresignHeader(ctx, http.MethodPut, b.name, name, b.s.presignExpiry(expiry), nil, headers)
+	if err != nil {
+		return "", kerrors.WithKind(err, ErrClient, "Failed to presign put object")
+	}
+	return u.String(), nil
+}
+
+// InitMultipart begins a multipart upload of an object and returns its upload
+// id
+func (b *bucket) InitMultipart(ctx context.Context, name string, contentType string, userMeta map[string]string) (string, error) {
+	client, err := b.s.getClient(ctx)
+	if err != nil {
+		return "", err
+	}
+	uploadid, err := minio.Core{Client: client}.NewMultipartUpload(ctx, b.name, name, minio.PutObjectOptions{ContentType: contentType, UserMetadata: userMeta})
+	if err != nil {
+		return "", kerrors.WithKind(err, ErrClient, "Failed to create multipart upload")
+	}
+	return uploadid, nil
+}
+
+// PutPart uploads a part of a multipart upload
+func (b *bucket) PutPart(ctx context.Context, name string, uploadid string, number int, size int64, part io.Reader) (*PartInfo, error) {
+	client, err := b.s.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	info, err := minio.Core{Client: client}.PutObjectPart(ctx, b.name, name, uploadid, number, part, size, minio.PutObjectPartOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
+			return nil, kerrors.WithKind(err, ErrNotFound, "Failed to find multipart upload")
+		}
+		return nil, kerrors.WithKind(err, ErrClient, "Failed to upload part")
+	}
+	return &PartInfo{
+		Number:       info.PartNumber,
+		Size:         info.Size,
+		ETag:         info.ETag,
+		LastModified: info.LastModified.Unix(),
+	}, nil
+}
+
+// PresignPart returns a presigned url to upload a part of a multipart upload
+func (b *bucket) PresignPart(ctx context.Context, name string, uploadid string, number int, expiry time.Duration) (string, error) {
+	client, err := b.s.getPresignClient(ctx)
+	if err != nil {
+		return "", err
+	}
+	params := url.Values{}
+	params.Set("uploadId", uploadid)
+	params.Set("partNumber", strconv.Itoa(number))
+	u, err := client.Presign(ctx, http.MethodPut, b.name, name, b.s.presignExpiry(expiry), params)
+	if err != nil {
+		return "", kerrors.WithKind(err, ErrClient, "Failed to presign upload part")
+	}
+	return u.String(), nil
+}
+
+const (
+	listPartsBatchSize = 1000
+)
+
+// ListParts returns the uploaded parts of a multipart upload ordered by part
+// number
+func (b *bucket) ListParts(ctx context.Context, name string, uploadid string) ([]PartInfo, error) {
+	client, err := b.s.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	core := minio.Core{Client: client}
+	var res []PartInfo
+	marker := 0
+	for {
+		parts, err := core.ListObjectParts(ctx, b.name, name, uploadid, marker, listPartsBatchSize)
+		if err != nil {
+			if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
+				return nil, kerrors.WithKind(err, ErrNotFound, "Failed to find multipart upload")
+			}
+			return nil, kerrors.WithKind(err, ErrClient, "Failed to list parts")
+		}
+		for _, i := range parts.ObjectParts {
+			res = append(res, PartInfo{
+				Number:       i.PartNumber,
+				Size:         i.Size,
+				ETag:         i.ETag,
+				LastModified: i.LastModified.Unix(),
+			})
+		}
+		if !parts.IsTruncated {
+			break
+		}
+		marker = parts.NextPartNumberMarker
+	}
+	return res, nil
+}
+
+// CompleteMultipart assembles the uploaded parts into an object
+func (b *bucket) CompleteMultipart(ctx context.Context, name string, uploadid string, parts []PartInfo) error {
+	client, err := b.s.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	completeParts := make([]minio.CompletePart, 0, len(parts))
+	for _, i := range parts {
+		completeParts = append(completeParts, minio.CompletePart{
+			PartNumber: i.Number,
+			ETag:       i.ETag,
+		})
+	}
+	if _, err := (minio.Core{Client: client}).CompleteMultipartUpload(ctx, b.name, name, uploadid, completeParts, minio.PutObjectOptions{}); err != nil {
+		if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
+			return kerrors.WithKind(err, ErrNotFound, "Failed to find multipart upload")
+		}
+		return kerrors.WithKind(err, ErrClient, "Failed to complete multipart upload")
+	}
+	return nil
+}
+
+// AbortMultipart aborts a multipart upload and removes its uploaded parts
+func (b *bucket) AbortMultipart(ctx context.Context, name string, uploadid string) error {
+	client, err := b.s.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	if err := (minio.Core{Client: client}).AbortMultipartUpload(ctx, b.name, name, uploadid); err != nil {
+		if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
+			return kerrors.WithKind(err, ErrNotFound, "Failed to find multipart upload")
+		}
+		return kerrors.WithKind(err, ErrClient, "Failed to abort multipart upload")
+	}
+	return nil
+}
+
 func (d *dir) Ping(ctx context.Context) error {
 	return d.base.Ping(ctx)
 }
 
+func (d *dir) path(name string) string {
+	return d.prefix + subdirpathSeparator + name
+}
+
 func (d *dir) Stat(ctx context.Context, name string) (*ObjectInfo, error) {
-	return d.base.Stat(ctx, d.prefix+subdirpathSeparator+name)
+	info, err := d.base.Stat(ctx, d.path(name))
+	if err != nil {
+		return nil, err
+	}
+	info.Name = name
+	return info, nil
 }
 
 func (d *dir) Get(ctx context.Context, name string) (io.ReadCloser, *ObjectInfo, error) {
-	return d.base.Get(ctx, d.prefix+subdirpathSeparator+name)
+	obj, info, err := d.base.Get(ctx, d.path(name))
+	if err != nil {
+		return nil, nil, err
+	}
+	info.Name = name
+	return obj, info, nil
 }
 
 func (d *dir) Put(ctx context.Context, name string, contentType string, size int64, userMeta map[string]string, object io.Reader) error {
-	return d.base.Put(ctx, d.prefix+subdirpathSeparator+name, contentType, size, userMeta, object)
+	return d.base.Put(ctx, d.path(name), contentType, size, userMeta, object)
 }
 
 func (d *dir) Del(ctx context.Context, name string) error {
-	return d.base.Del(ctx, d.prefix+subdirpathSeparator+name)
+	return d.base.Del(ctx, d.path(name))
+}
+
+func (d *dir) List(ctx context.Context, prefix string, limit int, after string) ([]ObjectInfo, error) {
+	if after != "" {
+		after = d.path(after)
+	}
+	res, err := d.base.List(ctx, d.path(prefix), limit, after)
+	if err != nil {
+		return nil, err
+	}
+	for n, i := range res {
+		res[n].Name = strings.TrimPrefix(i.Name, d.path(""))
+	}
+	return res, nil
+}
+
+func (d *dir) PresignGet(ctx context.Context, name string, expiry time.Duration) (string, error) {
+	return d.base.PresignGet(ctx, d.path(name), expiry)
+}
+
+func (d *dir) PresignPut(ctx context.Context, name string, contentType string, expiry time.Duration) (string, error) {
+	return d.base.PresignPut(ctx, d.path(name), contentType, expiry)
+}
+
+func (d *dir) InitMultipart(ctx context.Context, name string, contentType string, userMeta map[string]string) (string, error) {
+	return d.base.InitMultipart(ctx, d.path(name), contentType, userMeta)
+}
+
+func (d *dir) PutPart(ctx context.Context, name string, uploadid string, number int, size int64, part io.Reader) (*PartInfo, error) {
+	return d.base.PutPart(ctx, d.path(name), uploadid, number, size, part)
+}
+
+func (d *dir) PresignPart(ctx context.Context, name string, uploadid string, number int, expiry time.Duration) (string, error) {
+	return d.base.PresignPart(ctx, d.path(name), uploadid, number, expiry)
+}
+
+func (d *dir) ListParts(ctx context.Context, name string, uploadid string) ([]PartInfo, error) {
+	return d.base.ListParts(ctx, d.path(name), uploadid)
+}
+
+func (d *dir) CompleteMultipart(ctx context.Context, name string, uploadid string, parts []PartInfo) error {
+	return d.base.CompleteMultipart(ctx, d.path(name), uploadid, parts)
+}
+
+func (d *dir) AbortMultipart(ctx context.Context, name string, uploadid string) error {
+	return d.base.AbortMultipart(ctx, d.path(name), uploadid)
 }
 
 func (d *dir) Subdir(prefix string) Dir {
 	return &dir{
-		prefix: d.prefix + subdirpathSeparator + prefix,
+		prefix: d.path(prefix),
 		base:   d.base,
 	}
 }
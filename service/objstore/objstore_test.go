@@ -0,0 +1,262 @@
+package objstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/governortest"
+	"xorkevin.dev/klog"
+)
+
+func TestBuckets(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		Test   string
+		Bucket func(t *testing.T) Bucket
+	}{
+		{
+			Test: "mem",
+			Bucket: func(t *testing.T) Bucket {
+				return NewMemBucket()
+			},
+		},
+		{
+			Test: "fs",
+			Bucket: func(t *testing.T) Bucket {
+				return NewFSBucket(t.TempDir())
+			},
+		},
+	} {
+		t.Run(tc.Test, func(t *testing.T) {
+			t.Parallel()
+			assert := require.New(t)
+
+			ctx := context.Background()
+			b := tc.Bucket(t)
+			assert.NoError(b.Init(ctx))
+			assert.NoError(b.Ping(ctx))
+
+			d := b.Subdir("test")
+
+			_, err := d.Stat(ctx, "missing")
+			assert.ErrorIs(err, ErrNotFound)
+			_, _, err = d.Get(ctx, "missing")
+			assert.ErrorIs(err, ErrNotFound)
+			assert.ErrorIs(d.Del(ctx, "missing"), ErrNotFound)
+
+			body := "hello, world"
+			bodysum := md5.Sum([]byte(body))
+			assert.NoError(d.Put(ctx, "obj/a", "text/plain", int64(len(body)), map[string]string{"key": "value"}, strings.NewReader(body)))
+			info, err := d.Stat(ctx, "obj/a")
+			assert.NoError(err)
+			assert.Equal("obj/a", info.Name)
+			assert.Equal(int64(len(body)), info.Size)
+			assert.Equal("text/plain", info.ContentType)
+			assert.Equal(hex.EncodeToString(bodysum[:]), info.ETag)
+			assert.Equal(map[string]string{"key": "value"}, info.UserMeta)
+
+			obj, info, err := d.Get(ctx, "obj/a")
+			assert.NoError(err)
+			assert.Equal("obj/a", info.Name)
+			objbody, err := io.ReadAll(obj)
+			assert.NoError(err)
+			assert.NoError(obj.Close())
+			assert.Equal(body, string(objbody))
+
+			assert.Error(d.Put(ctx, "obj/short", "text/plain", int64(len(body))+1, nil, strings.NewReader(body)))
+			_, err = d.Stat(ctx, "obj/short")
+			assert.ErrorIs(err, ErrNotFound)
+
+			for _, i := range []string{"obj/c", "obj/b", "obj2"} {
+				assert.NoError(d.Put(ctx, i, "text/plain", -1, nil, strings.NewReader(i)))
+			}
+			assert.NoError(b.Put(ctx, "other", "text/plain", -1, nil, strings.NewReader("other")))
+
+			list, err := d.List(ctx, "obj/", 2, "")
+			assert.NoError(err)
+			assert.Len(list, 2)
+			assert.Equal("obj/a", list[0].Name)
+			assert.Equal("obj/b", list[1].Name)
+			list, err = d.List(ctx, "obj/", 2, "obj/b")
+			assert.NoError(err)
+			assert.Len(list, 1)
+			assert.Equal("obj/c", list[0].Name)
+			list, err = d.List(ctx, "", 8, "")
+			assert.NoError(err)
+			assert.Len(list, 4)
+			assert.Equal("obj2", list[3].Name)
+
+			assert.NoError(d.Del(ctx, "obj/a"))
+			_, err = d.Stat(ctx, "obj/a")
+			assert.ErrorIs(err, ErrNotFound)
+
+			_, err = d.PresignGet(ctx, "obj/b", 0)
+			assert.ErrorIs(err, ErrUnsupported)
+
+			uploadid, err := d.InitMultipart(ctx, "multi", "application/octet-stream", map[string]string{"key": "multi"})
+			assert.NoError(err)
+			part1 := bytes.Repeat([]byte("a"), 64)
+			part2 := []byte("bc")
+			p2, err := d.PutPart(ctx, "multi", uploadid, 2, int64(len(part2)), bytes.NewReader(part2))
+			assert.NoError(err)
+			p1, err := d.PutPart(ctx, "multi", uploadid, 1, int64(len(part1)), bytes.NewReader(part1))
+			assert.NoError(err)
+			parts, err := d.ListParts(ctx, "multi", uploadid)
+			assert.NoError(err)
+			assert.Len(parts, 2)
+			assert.Equal(1, parts[0].Number)
+			assert.Equal(p1.ETag, parts[0].ETag)
+			assert.Equal(2, parts[1].Number)
+			assert.Equal(p2.ETag, parts[1].ETag)
+			_, err = d.ListParts(ctx, "other", uploadid)
+			assert.ErrorIs(err, ErrNotFound)
+
+			assert.ErrorIs(d.CompleteMultipart(ctx, "multi", uploadid, []PartInfo{*p2, *p1}), ErrClient)
+			assert.NoError(d.CompleteMultipart(ctx, "multi", uploadid, []PartInfo{*p1, *p2}))
+			_, err = d.ListParts(ctx, "multi", uploadid)
+			assert.ErrorIs(err, ErrNotFound)
+
+			obj, info, err = d.Get(ctx, "multi")
+			assert.NoError(err)
+			objbody, err = io.ReadAll(obj)
+			assert.NoError(err)
+			assert.NoError(obj.Close())
+			assert.Equal(append(bytes.Clone(part1), part2...), objbody)
+			assert.Equal("application/octet-stream", info.ContentType)
+			assert.Equal(map[string]string{"key": "multi"}, info.UserMeta)
+			assert.True(strings.HasSuffix(info.ETag, "-2"))
+
+			uploadid, err = d.InitMultipart(ctx, "aborted", "text/plain", nil)
+			assert.NoError(err)
+			assert.NoError(d.AbortMultipart(ctx, "aborted", uploadid))
+			_, err = d.PutPart(ctx, "aborted", uploadid, 1, 1, strings.NewReader("a"))
+			assert.True(errors.Is(err, ErrNotFound))
+		})
+	}
+}
+
+func TestService(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		Test   string
+		Config func(t *testing.T) map[string]any
+	}{
+		{
+			Test: "mem",
+			Config: func(t *testing.T) map[string]any {
+				return map[string]any{
+					"backend": "mem",
+				}
+			},
+		},
+		{
+			Test: "fs",
+			Config: func(t *testing.T) map[string]any {
+				return map[string]any{
+					"backend": "fs",
+					"fs": map[string]any{
+						"dir": t.TempDir(),
+					},
+				}
+			},
+		},
+	} {
+		t.Run(tc.Test, func(t *testing.T) {
+			t.Parallel()
+			assert := require.New(t)
+
+			ctx := context.Background()
+
+			obj := New()
+			// buckets are retrieved before the service is initialized
+			b := obj.GetBucket("test")
+			d := b.Subdir("dir")
+
+			server := governortest.NewTestServer(t, map[string]any{
+				"objstore": tc.Config(t),
+			}, nil, nil)
+			server.Register("objstore", "/null/obj", obj)
+			assert.NoError(server.Start(ctx, governor.Flags{}, klog.Discard{}))
+
+			assert.NoError(obj.Ping(ctx))
+			assert.NoError(b.Init(ctx))
+			assert.NoError(b.Ping(ctx))
+
+			body := "hello, world"
+			assert.NoError(d.Put(ctx, "a", "text/plain", int64(len(body)), nil, strings.NewReader(body)))
+			o, info, err := obj.GetBucket("test").Get(ctx, "dir/a")
+			assert.NoError(err)
+			assert.Equal("dir/a", info.Name)
+			objbody, err := io.ReadAll(o)
+			assert.NoError(err)
+			assert.NoError(o.Close())
+			assert.Equal(body, string(objbody))
+
+			_, err = b.PresignGet(ctx, "dir/a", 0)
+			assert.ErrorIs(err, ErrUnsupported)
+
+			assert.NoError(d.Del(ctx, "a"))
+			_, err = b.Stat(ctx, "dir/a")
+			assert.ErrorIs(err, ErrNotFound)
+		})
+	}
+}
+
+func TestListedObjectMeta(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		Test        string
+		ContentType string
+		Meta        map[string]string
+		ExpType     string
+		ExpMeta     map[string]string
+	}{
+		{
+			Test:    "no metadata",
+			ExpType: "application/octet-stream",
+		},
+		{
+			Test: "metadata headers",
+			Meta: map[string]string{
+				"content-type":     "text/plain",
+				"X-Amz-Meta-Key":   "value",
+				"x-amz-meta-other": "other",
+				"Cache-Control":    "no-cache",
+			},
+			ExpType: "text/plain",
+			ExpMeta: map[string]string{
+				"Key":   "value",
+				"Other": "other",
+			},
+		},
+		{
+			Test:        "listed content type",
+			ContentType: "image/png",
+			Meta: map[string]string{
+				"Content-Type": "text/plain",
+			},
+			ExpType: "image/png",
+		},
+	} {
+		t.Run(tc.Test, func(t *testing.T) {
+			t.Parallel()
+
+			assert := require.New(t)
+
+			contentType, userMeta := listedObjectMeta(tc.ContentType, tc.Meta)
+			assert.Equal(tc.ExpType, contentType)
+			assert.Equal(tc.ExpMeta, userMeta)
+		})
+	}
+}
@@ -2,6 +2,8 @@ package profile
 
 import (
 	"context"
+	"encoding/json"
+	"time"
 
 	"xorkevin.dev/governor"
 	"xorkevin.dev/governor/service/events"
@@ -10,6 +12,8 @@ import (
 	"xorkevin.dev/governor/service/ratelimit"
 	"xorkevin.dev/governor/service/user"
 	"xorkevin.dev/governor/service/user/gate"
+	"xorkevin.dev/governor/util/bytefmt"
+	"xorkevin.dev/governor/util/kjson"
 	"xorkevin.dev/governor/util/ksync"
 	"xorkevin.dev/kerrors"
 	"xorkevin.dev/klog"
@@ -23,12 +27,22 @@ type (
 		profiles      profilemodel.Repo
 		profileBucket objstore.Bucket
 		profileDir    objstore.Dir
+		uploadDir     objstore.Dir
+		variantDir    objstore.Dir
 		users         user.Users
+		events        events.Events
 		ratelimiter   ratelimit.Ratelimiter
 		gate          gate.Gate
 		log           *klog.LevelLogger
+		tracer        governor.Tracer
 		scopens       string
 		streamns      string
+		streamprofile string
+		streamsize    int64
+		eventsize     int32
+		uploadExpiry  time.Duration
+		uploadMaxSize int64
+		uploadAsync   bool
 		wg            *ksync.WaitGroup
 	}
 
@@ -39,12 +53,15 @@ type (
 )
 
 // New creates a new Profiles service
-func New(profiles profilemodel.Repo, obj objstore.Bucket, users user.Users, ratelimiter ratelimit.Ratelimiter, g gate.Gate) *Service {
+func New(profiles profilemodel.Repo, obj objstore.Bucket, users user.Users, ev events.Events, ratelimiter ratelimit.Ratelimiter, g gate.Gate) *Service {
 	return &Service{
 		profiles:      profiles,
 		profileBucket: obj,
 		profileDir:    obj.Subdir("profileimage"),
+		uploadDir:     obj.Subdir("profileimageupload"),
+		variantDir:    obj.Subdir("profileimagevariant"),
 		users:         users,
+		events:        ev,
 		ratelimiter:   ratelimiter,
 		gate:          g,
 		wg:            ksync.NewWaitGroup(),
@@ -54,6 +71,13 @@ func New(profiles profilemodel.Repo, obj objstore.Bucket, users user.Users, rate
 func (s *Service) Register(r governor.ConfigRegistrar) {
 	s.scopens = "gov." + r.Name()
 	s.streamns = r.Name()
+	s.streamprofile = r.Name()
+
+	r.SetDefault("streamsize", "200M")
+	r.SetDefault("eventsize", "2K")
+	r.SetDefault("imageupload.expiry", "15m")
+	r.SetDefault("imageupload.maxsize", "8M")
+	r.SetDefault("imageupload.async", false)
 }
 
 func (s *Service) router() *router {
@@ -65,6 +89,35 @@ func (s *Service) router() *router {
 
 func (s *Service) Init(ctx context.Context, r governor.ConfigReader, kit governor.ServiceKit) error {
 	s.log = klog.NewLevelLogger(kit.Logger)
+	s.tracer = kit.Tracer
+
+	var err error
+	s.streamsize, err = bytefmt.ToBytes(r.GetStr("streamsize"))
+	if err != nil {
+		return kerrors.WithMsg(err, "Invalid stream size")
+	}
+	eventsize, err := bytefmt.ToBytes(r.GetStr("eventsize"))
+	if err != nil {
+		return kerrors.WithMsg(err, "Invalid msg size")
+	}
+	s.eventsize = int32(eventsize)
+	s.uploadExpiry, err = r.GetDuration("imageupload.expiry")
+	if err != nil {
+		return kerrors.WithMsg(err, "Failed to parse image upload expiry")
+	}
+	s.uploadMaxSize, err = bytefmt.ToBytes(r.GetStr("imageupload.maxsize"))
+	if err != nil {
+		return kerrors.WithMsg(err, "Invalid image upload max size")
+	}
+	s.uploadAsync = r.GetBool("imageupload.async")
+
+	s.log.Info(ctx, "Loaded config",
+		klog.AString("streamsize", r.GetStr("streamsize")),
+		klog.AString("eventsize", r.GetStr("eventsize")),
+		klog.AString("imageupload.expiry", s.uploadExpiry.String()),
+		klog.AString("imageupload.maxsize", bytefmt.ToString(s.uploadMaxSize)),
+		klog.ABool("imageupload.async", s.uploadAsync),
+	)
 
 	sr := s.router()
 	sr.mountProfileRoutes(kit.Router)
@@ -73,6 +126,20 @@ func (s *Service) Init(ctx context.Context, r governor.ConfigReader, kit governo
 }
 
 func (s *Service) Start(ctx context.Context) error {
+	s.wg.Add(1)
+	go events.NewWatcher(
+		s.events,
+		s.log.Logger,
+		s.tracer,
+		s.streamprofile,
+		s.streamns+".worker",
+		events.ConsumerOpts{},
+		events.HandlerFunc(s.profileEventHandler),
+		nil,
+		0,
+	).Watch(ctx, s.wg, events.WatchOpts{})
+	s.log.Info(ctx, "Subscribed to profile stream")
+
 	s.wg.Add(1)
 	go s.users.WatchUsers(s.streamns+".worker.users", events.ConsumerOpts{}, s.userEventHandler, nil, 0).Watch(ctx, s.wg, events.WatchOpts{})
 	s.log.Info(ctx, "Subscribed to users stream")
@@ -86,6 +153,18 @@ func (s *Service) Stop(ctx context.Context) {
 }
 
 func (s *Service) Setup(ctx context.Context, req governor.ReqSetup) error {
+	if err := s.events.InitStream(ctx, s.streamprofile, events.StreamOpts{
+		Partitions:     16,
+		Replicas:       1,
+		ReplicaQuorum:  1,
+		RetentionAge:   30 * 24 * time.Hour,
+		RetentionBytes: int(s.streamsize),
+		MaxMsgBytes:    int(s.eventsize),
+	}); err != nil {
+		return kerrors.WithMsg(err, "Failed to init profile stream")
+	}
+	s.log.Info(ctx, "Created profile stream")
+
 	if err := s.profiles.Setup(ctx); err != nil {
 		return err
 	}
@@ -101,6 +180,65 @@ func (s *Service) Health(ctx context.Context) error {
 	return nil
 }
 
+// ErrProfileEvent is returned when the profile event is malformed
+var ErrProfileEvent errProfileEvent
+
+type (
+	errProfileEvent struct{}
+)
+
+func (e errProfileEvent) Error() string {
+	return "Malformed profile event"
+}
+
+const (
+	profileEventKindImageUpload = "imageupload"
+)
+
+type (
+	profileEventDec struct {
+		Kind    string          `json:"kind"`
+		Payload json.RawMessage `json:"payload"`
+	}
+
+	profileEventEnc struct {
+		Kind    string      `json:"kind"`
+		Payload interface{} `json:"payload"`
+	}
+
+	imageUploadProps struct {
+		Userid string `json:"userid"`
+	}
+)
+
+func encodeProfileEventImageUpload(props imageUploadProps) ([]byte, error) {
+	b, err := kjson.Marshal(profileEventEnc{
+		Kind:    profileEventKindImageUpload,
+		Payload: props,
+	})
+	if err != nil {
+		return nil, kerrors.WithMsg(err, "Failed to encode image upload props to json")
+	}
+	return b, nil
+}
+
+func (s *Service) profileEventHandler(ctx context.Context, msg events.Msg) error {
+	var m profileEventDec
+	if err := kjson.Unmarshal(msg.Value, &m); err != nil {
+		return kerrors.WithKind(err, ErrProfileEvent, "Failed to decode profile event")
+	}
+	switch m.Kind {
+	case profileEventKindImageUpload:
+		var props imageUploadProps
+		if err := kjson.Unmarshal(m.Payload, &props); err != nil {
+			return kerrors.WithKind(err, ErrProfileEvent, "Failed to decode image upload event")
+		}
+		return s.imageUploadEventHandler(ctx, props)
+	default:
+		return nil
+	}
+}
+
 func (s *Service) userEventHandler(ctx context.Context, props user.UserEvent) error {
 	switch props.Kind {
 	case user.UserEventKindCreate:
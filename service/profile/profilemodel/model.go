@@ -3,6 +3,7 @@ package profilemodel
 import (
 	"context"
 
+	"xorkevin.dev/forge/model/sqldb"
 	"xorkevin.dev/governor/service/dbsql"
 	"xorkevin.dev/kerrors"
 )
@@ -31,10 +32,11 @@ type (
 	//forge:model profile
 	//forge:model:query profile
 	Model struct {
-		Userid string `model:"userid,VARCHAR(31) PRIMARY KEY"`
-		Email  string `model:"contact_email,VARCHAR(255)"`
-		Bio    string `model:"bio,VARCHAR(4095)"`
-		Image  string `model:"profile_image_url,VARCHAR(4095)"`
+		Userid      string `model:"userid,VARCHAR(31) PRIMARY KEY"`
+		Email       string `model:"contact_email,VARCHAR(255)"`
+		Bio         string `model:"bio,VARCHAR(4095)"`
+		Image       string `model:"profile_image_url,VARCHAR(4095)"`
+		Placeholder string `model:"profile_image_placeholder,VARCHAR(255)"`
 	}
 )
 
@@ -127,5 +129,17 @@ func (r *repo) Setup(ctx context.Context) error {
 	if err := r.table.Setup(ctx, d); err != nil {
 		return kerrors.WithMsg(err, "Failed to setup profile model")
 	}
+	if err := r.table.SetupPlaceholderColumn(ctx, d); err != nil {
+		return kerrors.WithMsg(err, "Failed to setup profile image placeholder column")
+	}
+	return nil
+}
+
+// SetupPlaceholderColumn adds the profile_image_placeholder column to profile
+// tables created before profile images had placeholders
+func (t *profileModelTable) SetupPlaceholderColumn(ctx context.Context, d sqldb.Executor) error {
+	if _, err := d.ExecContext(ctx, "ALTER TABLE "+t.TableName+" ADD COLUMN IF NOT EXISTS profile_image_placeholder VARCHAR(255);"); err != nil {
+		return err
+	}
 	return nil
 }
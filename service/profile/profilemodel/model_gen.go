@@ -18,7 +18,7 @@ type (
 )
 
 func (t *profileModelTable) Setup(ctx context.Context, d sqldb.Executor) error {
-	_, err := d.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+t.TableName+" (userid VARCHAR(31) PRIMARY KEY, contact_email VARCHAR(255), bio VARCHAR(4095), profile_image_url VARCHAR(4095));")
+	_, err := d.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+t.TableName+" (userid VARCHAR(31) PRIMARY KEY, contact_email VARCHAR(255), bio VARCHAR(4095), profile_image_url VARCHAR(4095), profile_image_placeholder VARCHAR(255));")
 	if err != nil {
 		return err
 	}
@@ -26,7 +26,7 @@ func (t *profileModelTable) Setup(ctx context.Context, d sqldb.Executor) error {
 }
 
 func (t *profileModelTable) Insert(ctx context.Context, d sqldb.Executor, m *Model) error {
-	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (userid, contact_email, bio, profile_image_url) VALUES ($1, $2, $3, $4);", m.Userid, m.Email, m.Bio, m.Image)
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (userid, contact_email, bio, profile_image_url, profile_image_placeholder) VALUES ($1, $2, $3, $4, $5);", m.Userid, m.Email, m.Bio, m.Image, m.Placeholder)
 	if err != nil {
 		return err
 	}
@@ -39,13 +39,13 @@ func (t *profileModelTable) InsertBulk(ctx context.Context, d sqldb.Executor, mo
 		conflictSQL = " ON CONFLICT DO NOTHING"
 	}
 	placeholders := make([]string, 0, len(models))
-	args := make([]interface{}, 0, len(models)*4)
+	args := make([]interface{}, 0, len(models)*5)
 	for c, m := range models {
-		n := c * 4
-		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4))
-		args = append(args, m.Userid, m.Email, m.Bio, m.Image)
+		n := c * 5
+		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5))
+		args = append(args, m.Userid, m.Email, m.Bio, m.Image, m.Placeholder)
 	}
-	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (userid, contact_email, bio, profile_image_url) VALUES "+strings.Join(placeholders, ", ")+conflictSQL+";", args...)
+	_, err := d.ExecContext(ctx, "INSERT INTO "+t.TableName+" (userid, contact_email, bio, profile_image_url, profile_image_placeholder) VALUES "+strings.Join(placeholders, ", ")+conflictSQL+";", args...)
 	if err != nil {
 		return err
 	}
@@ -54,7 +54,7 @@ func (t *profileModelTable) InsertBulk(ctx context.Context, d sqldb.Executor, mo
 
 func (t *profileModelTable) GetModelByID(ctx context.Context, d sqldb.Executor, userid string) (*Model, error) {
 	m := &Model{}
-	if err := d.QueryRowContext(ctx, "SELECT userid, contact_email, bio, profile_image_url FROM "+t.TableName+" WHERE userid = $1;", userid).Scan(&m.Userid, &m.Email, &m.Bio, &m.Image); err != nil {
+	if err := d.QueryRowContext(ctx, "SELECT userid, contact_email, bio, profile_image_url, profile_image_placeholder FROM "+t.TableName+" WHERE userid = $1;", userid).Scan(&m.Userid, &m.Email, &m.Bio, &m.Image, &m.Placeholder); err != nil {
 		return nil, err
 	}
 	return m, nil
@@ -75,7 +75,7 @@ func (t *profileModelTable) GetModelByIDs(ctx context.Context, d sqldb.Executor,
 		placeholdersuserids = strings.Join(placeholders, ", ")
 	}
 	res := make([]Model, 0, limit)
-	rows, err := d.QueryContext(ctx, "SELECT userid, contact_email, bio, profile_image_url FROM "+t.TableName+" WHERE userid IN (VALUES "+placeholdersuserids+") LIMIT $1 OFFSET $2;", args...)
+	rows, err := d.QueryContext(ctx, "SELECT userid, contact_email, bio, profile_image_url, profile_image_placeholder FROM "+t.TableName+" WHERE userid IN (VALUES "+placeholdersuserids+") LIMIT $1 OFFSET $2;", args...)
 	if err != nil {
 		return nil, err
 	}
@@ -86,7 +86,7 @@ func (t *profileModelTable) GetModelByIDs(ctx context.Context, d sqldb.Executor,
 	}()
 	for rows.Next() {
 		var m Model
-		if err := rows.Scan(&m.Userid, &m.Email, &m.Bio, &m.Image); err != nil {
+		if err := rows.Scan(&m.Userid, &m.Email, &m.Bio, &m.Image, &m.Placeholder); err != nil {
 			return nil, err
 		}
 		res = append(res, m)
@@ -98,7 +98,7 @@ func (t *profileModelTable) GetModelByIDs(ctx context.Context, d sqldb.Executor,
 }
 
 func (t *profileModelTable) UpdModelByID(ctx context.Context, d sqldb.Executor, m *Model, userid string) error {
-	_, err := d.ExecContext(ctx, "UPDATE "+t.TableName+" SET (userid, contact_email, bio, profile_image_url) = ($1, $2, $3, $4) WHERE userid = $5;", m.Userid, m.Email, m.Bio, m.Image, userid)
+	_, err := d.ExecContext(ctx, "UPDATE "+t.TableName+" SET (userid, contact_email, bio, profile_image_url, profile_image_placeholder) = ($1, $2, $3, $4, $5) WHERE userid = $6;", m.Userid, m.Email, m.Bio, m.Image, m.Placeholder, userid)
 	if err != nil {
 		return err
 	}
@@ -69,7 +69,7 @@ type (
 )
 
 func (s *router) updateImage(c *governor.Context) {
-	img, err := image.LoadImage(c, "image")
+	img, err := image.LoadImage(c, "image", s.s.uploadMaxSize)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -91,6 +91,57 @@ func (s *router) updateImage(c *governor.Context) {
 	c.WriteStatus(http.StatusNoContent)
 }
 
+type (
+	//forge:valid
+	reqProfileImageUpload struct {
+		Userid      string `valid:"userid,has" json:"-"`
+		ContentType string `valid:"contentType" json:"content_type"`
+	}
+)
+
+func (s *router) presignImageUpload(c *governor.Context) {
+	var req reqProfileImageUpload
+	if err := c.Bind(&req, false); err != nil {
+		c.WriteError(err)
+		return
+	}
+	req.Userid = gate.GetCtxUserid(c)
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+
+	res, err := s.s.presignImageUpload(c.Ctx(), req.Userid, req.ContentType)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+
+	c.WriteJSON(http.StatusOK, res)
+}
+
+func (s *router) commitImageUpload(c *governor.Context) {
+	req := reqProfileGetID{
+		Userid: gate.GetCtxUserid(c),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+
+	async, err := s.s.commitImageUpload(c.Ctx(), req.Userid)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	if async {
+		c.WriteStatus(http.StatusAccepted)
+		return
+	}
+
+	c.WriteStatus(http.StatusNoContent)
+}
+
 func (s *router) deleteProfile(c *governor.Context) {
 	req := reqProfileGetID{
 		Userid: c.Param("id"),
@@ -161,6 +212,37 @@ func (s *router) getProfileImage(c *governor.Context) {
 	c.WriteFile(http.StatusOK, contentType, image)
 }
 
+type (
+	//forge:valid
+	reqProfileImageVariant struct {
+		Userid  string `valid:"userid,has" json:"-"`
+		Variant string `valid:"variant,has" json:"-"`
+	}
+)
+
+func (s *router) getProfileImageVariant(c *governor.Context) {
+	req := reqProfileImageVariant{
+		Userid:  c.Param("id"),
+		Variant: c.Param("variant"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+
+	image, contentType, err := s.s.getProfileImageVariant(c.Ctx(), req.Userid, req.Variant)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	defer func() {
+		if err := image.Close(); err != nil {
+			s.s.log.Err(c.Ctx(), kerrors.WithMsg(err, "Failed to close profile image"))
+		}
+	}()
+	c.WriteFile(http.StatusOK, contentType, image)
+}
+
 type (
 	//forge:valid
 	reqGetProfiles struct {
@@ -201,6 +283,23 @@ func (s *router) getProfileImageCC(c *governor.Context) (string, error) {
 	return objinfo.ETag, nil
 }
 
+func (s *router) getProfileImageVariantCC(c *governor.Context) (string, error) {
+	req := reqProfileImageVariant{
+		Userid:  c.Param("id"),
+		Variant: c.Param("variant"),
+	}
+	if err := req.valid(); err != nil {
+		return "", err
+	}
+
+	objinfo, err := s.s.statProfileImageVariant(c.Ctx(), req.Userid, req.Variant)
+	if err != nil {
+		return "", err
+	}
+
+	return objinfo.ETag, nil
+}
+
 func (s *router) mountProfileRoutes(r governor.Router) {
 	m := governor.NewMethodRouter(r)
 	scopeProfileRead := s.s.scopens + ":read"
@@ -208,9 +307,12 @@ func (s *router) mountProfileRoutes(r governor.Router) {
 	m.PostCtx("", s.createProfile, gate.User(s.s.gate, scopeProfileWrite), s.rt)
 	m.PutCtx("", s.updateProfile, gate.User(s.s.gate, scopeProfileWrite), s.rt)
 	m.PutCtx("/image", s.updateImage, gate.User(s.s.gate, scopeProfileWrite), s.rt)
+	m.PostCtx("/image/upload", s.presignImageUpload, gate.User(s.s.gate, scopeProfileWrite), s.rt)
+	m.PutCtx("/image/upload", s.commitImageUpload, gate.User(s.s.gate, scopeProfileWrite), s.rt)
 	m.DeleteCtx("/id/{id}", s.deleteProfile, gate.OwnerOrAdminParam(s.s.gate, "id", scopeProfileWrite), s.rt)
 	m.GetCtx("", s.getOwnProfile, gate.User(s.s.gate, scopeProfileRead), s.rt)
 	m.GetCtx("/id/{id}", s.getProfile, s.rt)
 	m.GetCtx("/id/{id}/image", s.getProfileImage, cachecontrol.ControlCtx(true, nil, 60, s.getProfileImageCC), s.rt)
+	m.GetCtx("/id/{id}/image/{variant}", s.getProfileImageVariant, cachecontrol.ControlCtx(true, nil, 60, s.getProfileImageVariantCC), s.rt)
 	m.GetCtx("/ids", s.getProfilesBulk, s.rt)
 }
@@ -5,12 +5,15 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"time"
 
 	"xorkevin.dev/governor"
 	"xorkevin.dev/governor/service/dbsql"
+	"xorkevin.dev/governor/service/events"
 	"xorkevin.dev/governor/service/image"
 	"xorkevin.dev/governor/service/objstore"
 	"xorkevin.dev/kerrors"
+	"xorkevin.dev/klog"
 )
 
 type (
@@ -19,10 +22,11 @@ type (
 	}
 
 	resProfileModel struct {
-		Userid string `json:"userid"`
-		Email  string `json:"contact_email"`
-		Bio    string `json:"bio"`
-		Image  string `json:"image"`
+		Userid      string `json:"userid"`
+		Email       string `json:"contact_email"`
+		Bio         string `json:"bio"`
+		Image       string `json:"image"`
+		Placeholder string `json:"placeholder"`
 	}
 
 	resProfiles struct {
@@ -64,12 +68,27 @@ func (s *Service) updateProfile(ctx context.Context, userid, email, bio string)
 }
 
 const (
-	imgSize      = 384
-	imgQuality   = 85
-	thumbSize    = 8
-	thumbQuality = 0
+	imgSize            = 384
+	imgQuality         = 85
+	thumbSize          = 8
+	thumbQuality       = 0
+	placeholderXComps  = 4
+	placeholderYComps  = 4
+	imgVariantSmall    = "sm"
+	imgVariantSmallLen = 96
 )
 
+var imgVariants = []image.Variant{
+	{
+		Name:      imgVariantSmall,
+		Width:     imgVariantSmallLen,
+		Height:    imgVariantSmallLen,
+		Fill:      true,
+		MediaType: image.MediaTypeJpeg,
+		Quality:   imgQuality,
+	},
+}
+
 func (s *Service) updateImage(ctx context.Context, userid string, img image.Image) error {
 	m, err := s.profiles.GetByID(ctx, userid)
 	if err != nil {
@@ -86,6 +105,10 @@ func (s *Service) updateImage(ctx context.Context, userid string, img image.Imag
 	if err != nil {
 		return kerrors.WithMsg(err, "Failed to encode image thumbnail")
 	}
+	placeholder, err := img.ToBlurhash(placeholderXComps, placeholderYComps)
+	if err != nil {
+		return kerrors.WithMsg(err, "Failed to encode image placeholder")
+	}
 	imgJpeg, err := img.ToJpeg(imgQuality)
 	if err != nil {
 		return kerrors.WithMsg(err, "Failed to encode image")
@@ -94,14 +117,146 @@ func (s *Service) updateImage(ctx context.Context, userid string, img image.Imag
 	if err := s.profileDir.Put(ctx, userid, image.MediaTypeJpeg, int64(imgJpeg.Len()), nil, imgJpeg); err != nil {
 		return kerrors.WithMsg(err, "Failed to save profile picture")
 	}
+	if err := image.PutVariants(ctx, s.variantDir, userid, img, imgVariants, nil); err != nil {
+		return kerrors.WithMsg(err, "Failed to save profile picture variants")
+	}
 
 	m.Image = thumb64
+	m.Placeholder = placeholder
 	if err := s.profiles.Update(ctx, m); err != nil {
 		return kerrors.WithMsg(err, "Failed to update profile")
 	}
 	return nil
 }
 
+type (
+	resProfileImageUpload struct {
+		URL         string `json:"url"`
+		ContentType string `json:"content_type"`
+		Expires     int64  `json:"expires"`
+	}
+)
+
+func (s *Service) presignImageUpload(ctx context.Context, userid string, contentType string) (*resProfileImageUpload, error) {
+	if !image.IsSupportedMediaType(contentType) {
+		return nil, governor.ErrWithRes(nil, http.StatusUnsupportedMediaType, "", contentType+" is unsupported")
+	}
+	if _, err := s.profiles.GetByID(ctx, userid); err != nil {
+		if errors.Is(err, dbsql.ErrNotFound) {
+			return nil, governor.ErrWithRes(err, http.StatusNotFound, "", "No profile found with that id")
+		}
+		return nil, kerrors.WithMsg(err, "Failed to get profile")
+	}
+	now := time.Now().Round(0)
+	u, err := s.uploadDir.PresignPut(ctx, userid, contentType, s.uploadExpiry)
+	if err != nil {
+		if errors.Is(err, objstore.ErrUnsupported) {
+			return nil, governor.ErrWithRes(err, http.StatusNotImplemented, "", "Direct profile image uploads are unsupported")
+		}
+		return nil, kerrors.WithMsg(err, "Failed to create profile image upload url")
+	}
+	return &resProfileImageUpload{
+		URL:         u,
+		ContentType: contentType,
+		Expires:     now.Add(s.uploadExpiry).Unix(),
+	}, nil
+}
+
+// commitImageUpload processes an uploaded profile image, and returns true if
+// it will be processed asynchronously
+func (s *Service) commitImageUpload(ctx context.Context, userid string) (bool, error) {
+	if !s.uploadAsync {
+		if err := s.processImageUpload(ctx, userid); err != nil {
+			return false, err
+		}
+		return false, nil
+	}
+
+	objinfo, err := s.uploadDir.Stat(ctx, userid)
+	if err != nil {
+		if errors.Is(err, objstore.ErrNotFound) {
+			return false, governor.ErrWithRes(err, http.StatusNotFound, "", "Profile image upload not found")
+		}
+		return false, kerrors.WithMsg(err, "Failed to get profile image upload")
+	}
+	if objinfo.Size > s.uploadMaxSize {
+		if err := s.uploadDir.Del(ctx, userid); err != nil {
+			s.log.Err(ctx, kerrors.WithMsg(err, "Failed to delete profile image upload"))
+		}
+		return false, governor.ErrWithRes(nil, http.StatusRequestEntityTooLarge, "", "Profile image is too large")
+	}
+	if !image.IsSupportedMediaType(objinfo.ContentType) {
+		return false, governor.ErrWithRes(nil, http.StatusUnsupportedMediaType, "", objinfo.ContentType+" is unsupported")
+	}
+	b, err := encodeProfileEventImageUpload(imageUploadProps{
+		Userid: userid,
+	})
+	if err != nil {
+		return false, err
+	}
+	if err := s.events.Publish(ctx, events.NewMsgs(s.streamprofile, userid, b)...); err != nil {
+		return false, kerrors.WithMsg(err, "Failed to publish profile image upload event")
+	}
+	return true, nil
+}
+
+func (s *Service) imageUploadEventHandler(ctx context.Context, props imageUploadProps) error {
+	if err := s.processImageUpload(ctx, props.Userid); err != nil {
+		var rerr *governor.ErrorRes
+		if errors.As(err, &rerr) {
+			// errors with responses are caused by the upload and cannot be
+			// resolved by retrying
+			s.log.WarnErr(ctx, kerrors.WithMsg(err, "Failed to process profile image upload"),
+				klog.AString("userid", props.Userid),
+			)
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *Service) processImageUpload(ctx context.Context, userid string) (retErr error) {
+	obj, objinfo, err := s.uploadDir.Get(ctx, userid)
+	if err != nil {
+		if errors.Is(err, objstore.ErrNotFound) {
+			return governor.ErrWithRes(err, http.StatusNotFound, "", "Profile image upload not found")
+		}
+		return kerrors.WithMsg(err, "Failed to get profile image upload")
+	}
+	shouldClose := true
+	defer func() {
+		if shouldClose {
+			if err := obj.Close(); err != nil {
+				retErr = errors.Join(retErr, kerrors.WithMsg(err, "Failed to close profile image upload"))
+			}
+		}
+	}()
+	if objinfo.Size > s.uploadMaxSize {
+		if err := s.uploadDir.Del(ctx, userid); err != nil {
+			s.log.Err(ctx, kerrors.WithMsg(err, "Failed to delete profile image upload"))
+		}
+		return governor.ErrWithRes(nil, http.StatusRequestEntityTooLarge, "", "Profile image is too large")
+	}
+	img, err := image.FromReader(obj, objinfo.ContentType, s.uploadMaxSize)
+	if err != nil {
+		return err
+	}
+	shouldClose = false
+	if err := obj.Close(); err != nil {
+		return kerrors.WithMsg(err, "Failed to close profile image upload")
+	}
+	if err := s.updateImage(ctx, userid, img); err != nil {
+		return err
+	}
+	if err := s.uploadDir.Del(ctx, userid); err != nil {
+		if !errors.Is(err, objstore.ErrNotFound) {
+			return kerrors.WithMsg(err, "Failed to delete profile image upload")
+		}
+	}
+	return nil
+}
+
 func (s *Service) deleteProfile(ctx context.Context, userid string) error {
 	m, err := s.profiles.GetByID(ctx, userid)
 	if err != nil {
@@ -116,6 +271,14 @@ func (s *Service) deleteProfile(ctx context.Context, userid string) error {
 			return kerrors.WithMsg(err, "Failed to delete profile picture")
 		}
 	}
+	if err := s.uploadDir.Del(ctx, userid); err != nil {
+		if !errors.Is(err, objstore.ErrNotFound) {
+			return kerrors.WithMsg(err, "Failed to delete profile image upload")
+		}
+	}
+	if err := image.DelVariants(ctx, s.variantDir, userid, imgVariants); err != nil {
+		return kerrors.WithMsg(err, "Failed to delete profile picture variants")
+	}
 
 	if err := s.profiles.Delete(ctx, m); err != nil {
 		return kerrors.WithMsg(err, "Failed to delete profile")
@@ -132,10 +295,11 @@ func (s *Service) getProfile(ctx context.Context, userid string) (*resProfileMod
 		return nil, kerrors.WithMsg(err, "Failed to get profile")
 	}
 	return &resProfileModel{
-		Userid: m.Userid,
-		Email:  m.Email,
-		Bio:    m.Bio,
-		Image:  m.Image,
+		Userid:      m.Userid,
+		Email:       m.Email,
+		Bio:         m.Bio,
+		Image:       m.Image,
+		Placeholder: m.Placeholder,
 	}, nil
 }
 
@@ -161,6 +325,43 @@ func (s *Service) getProfileImage(ctx context.Context, userid string) (io.ReadCl
 	return obj, objinfo.ContentType, nil
 }
 
+func (s *Service) getVariantDir(variant string) (objstore.Dir, error) {
+	if _, ok := image.FindVariant(imgVariants, variant); !ok {
+		return nil, governor.ErrWithRes(nil, http.StatusNotFound, "", "Profile image variant not found")
+	}
+	return s.variantDir.Subdir(variant), nil
+}
+
+func (s *Service) statProfileImageVariant(ctx context.Context, userid string, variant string) (*objstore.ObjectInfo, error) {
+	d, err := s.getVariantDir(variant)
+	if err != nil {
+		return nil, err
+	}
+	objinfo, err := d.Stat(ctx, userid)
+	if err != nil {
+		if errors.Is(err, objstore.ErrNotFound) {
+			return nil, governor.ErrWithRes(err, http.StatusNotFound, "", "Profile image not found")
+		}
+		return nil, kerrors.WithMsg(err, "Failed to get profile image")
+	}
+	return objinfo, nil
+}
+
+func (s *Service) getProfileImageVariant(ctx context.Context, userid string, variant string) (io.ReadCloser, string, error) {
+	d, err := s.getVariantDir(variant)
+	if err != nil {
+		return nil, "", err
+	}
+	obj, objinfo, err := d.Get(ctx, userid)
+	if err != nil {
+		if errors.Is(err, objstore.ErrNotFound) {
+			return nil, "", governor.ErrWithRes(err, http.StatusNotFound, "", "Profile image not found")
+		}
+		return nil, "", kerrors.WithMsg(err, "Failed to get profile image")
+	}
+	return obj, objinfo.ContentType, nil
+}
+
 func (s *Service) getProfilesBulk(ctx context.Context, userids []string) (*resProfiles, error) {
 	m, err := s.profiles.GetBulk(ctx, userids)
 	if err != nil {
@@ -170,10 +371,11 @@ func (s *Service) getProfilesBulk(ctx context.Context, userids []string) (*resPr
 	res := make([]resProfileModel, 0, len(m))
 	for _, i := range m {
 		res = append(res, resProfileModel{
-			Userid: i.Userid,
-			Email:  i.Email,
-			Bio:    i.Bio,
-			Image:  i.Image,
+			Userid:      i.Userid,
+			Email:       i.Email,
+			Bio:         i.Bio,
+			Image:       i.Image,
+			Placeholder: i.Placeholder,
 		})
 	}
 
@@ -50,6 +50,26 @@ func validBio(bio string) error {
 	return nil
 }
 
+func validContentType(contentType string) error {
+	if len(contentType) < 1 {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Content type must be provided")
+	}
+	if len(contentType) > lengthCap {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Content type must be shorter than 32 characters")
+	}
+	return nil
+}
+
+func validhasVariant(variant string) error {
+	if len(variant) < 1 {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Variant must be provided")
+	}
+	if len(variant) > lengthCap {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Variant must be shorter than 32 characters")
+	}
+	return nil
+}
+
 func validhasUserids(userids []string) error {
 	if len(userids) == 0 {
 		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "IDs must be provided")
@@ -22,6 +22,26 @@ func (r reqProfileGetID) valid() error {
 	return nil
 }
 
+func (r reqProfileImageUpload) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validContentType(r.ContentType); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r reqProfileImageVariant) valid() error {
+	if err := validhasUserid(r.Userid); err != nil {
+		return err
+	}
+	if err := validhasVariant(r.Variant); err != nil {
+		return err
+	}
+	return nil
+}
+
 func (r reqGetProfiles) valid() error {
 	if err := validhasUserids(r.Userids); err != nil {
 		return err
@@ -2,10 +2,12 @@ package template
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	htmlTemplate "html/template"
 	"io"
 	"io/fs"
+	"slices"
 	"strings"
 	textTemplate "text/template"
 
@@ -19,12 +21,14 @@ type (
 	Template interface {
 		Execute(dst io.Writer, kind Kind, templateName string, data interface{}) error
 		ExecuteHTML(dst io.Writer, kind Kind, templateName string, data interface{}) error
+		Sample(kind Kind, sampleName string) ([]byte, error)
 	}
 
 	Service struct {
-		tt  *textTemplate.Template
-		ht  *htmlTemplate.Template
-		log *klog.LevelLogger
+		tt      *textTemplate.Template
+		ht      *htmlTemplate.Template
+		samples map[string][]byte
+		log     *klog.LevelLogger
 	}
 )
 
@@ -47,6 +51,7 @@ func (s *Service) Register(r governor.ConfigRegistrar) {
 	r.SetDefault("dir", "templates")
 	r.SetDefault("txtglob", "*.txt.tmpl")
 	r.SetDefault("htmlglob", "*.html.tmpl")
+	r.SetDefault("sampleglob", "*.sample.json")
 }
 
 const (
@@ -83,6 +88,11 @@ func (s *Service) Init(ctx context.Context, r governor.ConfigReader, kit governo
 		}
 	}
 	s.ht = ht
+	samples, err := loadSamples(templateDir, r.GetStr("sampleglob"))
+	if err != nil {
+		return err
+	}
+	s.samples = samples
 
 	if k := tt.DefinedTemplates(); k != "" {
 		s.log.Info(ctx, "Loaded text templates",
@@ -94,9 +104,40 @@ func (s *Service) Init(ctx context.Context, r governor.ConfigReader, kit governo
 			klog.AString("templates", strings.TrimLeft(k, "; ")),
 		)
 	}
+	if len(s.samples) > 0 {
+		names := make([]string, 0, len(s.samples))
+		for k := range s.samples {
+			names = append(names, k)
+		}
+		slices.Sort(names)
+		s.log.Info(ctx, "Loaded template sample data",
+			klog.AString("samples", strings.Join(names, ", ")),
+		)
+	}
 	return nil
 }
 
+// loadSamples loads sample template data which is used to preview templates
+// during development
+func loadSamples(fsys fs.FS, pattern string) (map[string][]byte, error) {
+	names, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return nil, kerrors.WithKind(err, governor.ErrInvalidConfig, "Invalid template sample glob")
+	}
+	samples := make(map[string][]byte, len(names))
+	for _, i := range names {
+		b, err := fs.ReadFile(fsys, i)
+		if err != nil {
+			return nil, kerrors.WithKind(err, governor.ErrInvalidConfig, "Failed to read template sample data")
+		}
+		if !json.Valid(b) {
+			return nil, kerrors.WithKind(nil, governor.ErrInvalidConfig, fmt.Sprintf("Invalid template sample data %s", i))
+		}
+		samples[i] = b
+	}
+	return samples, nil
+}
+
 func (s *Service) Start(ctx context.Context) error {
 	return nil
 }
@@ -164,3 +205,17 @@ func (s *Service) ExecuteHTML(dst io.Writer, kind Kind, templateName string, dat
 	}
 	return nil
 }
+
+// Sample returns the sample data for a template encoded as json
+func (s *Service) Sample(kind Kind, sampleName string) ([]byte, error) {
+	switch kind {
+	case KindLocal:
+		b, ok := s.samples[sampleName]
+		if !ok {
+			return nil, kerrors.WithKind(nil, ErrTemplateDNE, fmt.Sprintf("Template sample %s does not exist", sampleName))
+		}
+		return b, nil
+	default:
+		return nil, kerrors.WithKind(nil, ErrTemplateDNE, "Invalid template sample kind")
+	}
+}
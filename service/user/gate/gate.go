@@ -82,6 +82,7 @@ type (
 		AuthenticateCtx(v Authorizer, scope string) governor.MiddlewareCtx
 		Authenticate(v Authorizer, scope string) governor.Middleware
 		Authorize(ctx context.Context, userid string, roles rank.Rank) (rank.Rank, error)
+		CheckKey(ctx context.Context, keyid, key string) (*apikey.UserScope, error)
 	}
 
 	Service struct {
@@ -421,6 +422,11 @@ func (s *Service) Authorize(ctx context.Context, userid string, roles rank.Rank)
 	return s.roles.IntersectRoles(ctx, userid, roles)
 }
 
+// CheckKey checks an apikey and returns its user and scope
+func (s *Service) CheckKey(ctx context.Context, keyid, key string) (*apikey.UserScope, error) {
+	return s.apikeys.Check(ctx, keyid, key)
+}
+
 func checkErrBool(b bool, err error) bool {
 	if err != nil {
 		return false
@@ -15,6 +15,7 @@ import (
 	"xorkevin.dev/governor/service/user/oauth/oauthappmodel"
 	"xorkevin.dev/governor/service/user/oauth/oauthconnmodel"
 	"xorkevin.dev/governor/service/user/token"
+	"xorkevin.dev/governor/util/bytefmt"
 	"xorkevin.dev/governor/util/ksync"
 	"xorkevin.dev/kerrors"
 	"xorkevin.dev/klog"
@@ -37,6 +38,7 @@ type (
 		kvclient        kvstore.KVStore
 		oauthBucket     objstore.Bucket
 		logoImgDir      objstore.Dir
+		logoVariantDir  objstore.Dir
 		users           user.Users
 		events          events.Events
 		ratelimiter     ratelimit.Ratelimiter
@@ -49,6 +51,7 @@ type (
 		accessDuration  time.Duration
 		refreshDuration time.Duration
 		keyCache        time.Duration
+		logoMaxSize     int64
 		realm           string
 		issuer          string
 		epauth          string
@@ -79,17 +82,18 @@ func New(
 	g gate.Gate,
 ) *Service {
 	return &Service{
-		apps:        apps,
-		connections: connections,
-		tokenizer:   tokenizer,
-		kvclient:    kv.Subtree("client"),
-		oauthBucket: obj,
-		logoImgDir:  obj.Subdir("logo"),
-		users:       users,
-		events:      ev,
-		ratelimiter: ratelimiter,
-		gate:        g,
-		wg:          ksync.NewWaitGroup(),
+		apps:           apps,
+		connections:    connections,
+		tokenizer:      tokenizer,
+		kvclient:       kv.Subtree("client"),
+		oauthBucket:    obj,
+		logoImgDir:     obj.Subdir("logo"),
+		logoVariantDir: obj.Subdir("logovariant"),
+		users:          users,
+		events:         ev,
+		ratelimiter:    ratelimiter,
+		gate:           g,
+		wg:             ksync.NewWaitGroup(),
 	}
 }
 
@@ -102,6 +106,7 @@ func (s *Service) Register(r governor.ConfigRegistrar) {
 	r.SetDefault("accessduration", "5m")
 	r.SetDefault("refreshduration", "168h")
 	r.SetDefault("keycache", "24h")
+	r.SetDefault("logo.maxsize", "8M")
 	r.SetDefault("realm", "governor")
 	r.SetDefault("ephost", "http://localhost:8080")
 	r.SetDefault("epprofile", "http://localhost:8080/u/{{.Username}}")
@@ -135,6 +140,10 @@ func (s *Service) Init(ctx context.Context, r governor.ConfigReader, kit governo
 	if err != nil {
 		return kerrors.WithMsg(err, "Failed to parse key cache duration")
 	}
+	s.logoMaxSize, err = bytefmt.ToBytes(r.GetStr("logo.maxsize"))
+	if err != nil {
+		return kerrors.WithMsg(err, "Failed to parse logo max size")
+	}
 
 	s.realm = r.GetStr("realm")
 	s.issuer = r.GetStr("issuer")
@@ -160,6 +169,7 @@ func (s *Service) Init(ctx context.Context, r governor.ConfigReader, kit governo
 		klog.AString("accessduration", s.accessDuration.String()),
 		klog.AString("refreshduration", s.refreshDuration.String()),
 		klog.AString("keycache", s.keyCache.String()),
+		klog.AString("logo.maxsize", bytefmt.ToString(s.logoMaxSize)),
 		klog.AString("issuer", s.issuer),
 		klog.AString("authorization_endpoint", s.epauth),
 		klog.AString("token_endpoint", s.eptoken),
@@ -55,6 +55,36 @@ func (s *router) getAppLogo(c *governor.Context) {
 	c.WriteFile(http.StatusOK, contentType, img)
 }
 
+type (
+	//forge:valid
+	reqAppLogoVariant struct {
+		ClientID string `valid:"clientID,has" json:"-"`
+		Variant  string `valid:"variant,has" json:"-"`
+	}
+)
+
+func (s *router) getAppLogoVariant(c *governor.Context) {
+	req := reqAppLogoVariant{
+		ClientID: c.Param("clientid"),
+		Variant:  c.Param("variant"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	img, contentType, err := s.s.getLogoVariantImage(c.Ctx(), req.ClientID, req.Variant)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	defer func() {
+		if err := img.Close(); err != nil {
+			s.s.log.Err(c.Ctx(), kerrors.WithMsg(err, "Failed to close app logo"))
+		}
+	}()
+	c.WriteFile(http.StatusOK, contentType, img)
+}
+
 type (
 	//forge:valid
 	reqGetAppGroup struct {
@@ -167,7 +197,7 @@ func (s *router) updateApp(c *governor.Context) {
 }
 
 func (s *router) updateAppLogo(c *governor.Context) {
-	img, err := image.LoadImage(c, "image")
+	img, err := image.LoadImage(c, "image", s.s.logoMaxSize)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -238,12 +268,30 @@ func (s *router) getAppLogoCC(c *governor.Context) (string, error) {
 	return objinfo.ETag, nil
 }
 
+func (s *router) getAppLogoVariantCC(c *governor.Context) (string, error) {
+	req := reqAppLogoVariant{
+		ClientID: c.Param("clientid"),
+		Variant:  c.Param("variant"),
+	}
+	if err := req.valid(); err != nil {
+		return "", err
+	}
+
+	objinfo, err := s.s.statLogoVariantImage(c.Ctx(), req.ClientID, req.Variant)
+	if err != nil {
+		return "", err
+	}
+
+	return objinfo.ETag, nil
+}
+
 func (s *router) mountAppRoutes(r governor.Router) {
 	m := governor.NewMethodRouter(r)
 	scopeAppRead := s.s.scopens + ".app:read"
 	scopeAppWrite := s.s.scopens + ".app:write"
 	m.GetCtx("/id/{clientid}", s.getApp, s.rt)
 	m.GetCtx("/id/{clientid}/image", s.getAppLogo, cachecontrol.ControlCtx(true, nil, 60, s.getAppLogoCC), s.rt)
+	m.GetCtx("/id/{clientid}/image/{variant}", s.getAppLogoVariant, cachecontrol.ControlCtx(true, nil, 60, s.getAppLogoVariantCC), s.rt)
 	m.GetCtx("", s.getAppGroup, gate.Member(s.s.gate, s.s.rolens, scopeAppRead), s.rt)
 	m.GetCtx("/ids", s.getAppBulk, s.rt)
 	m.PostCtx("", s.createApp, gate.Member(s.s.gate, s.s.rolens, scopeAppWrite), s.rt)
@@ -189,11 +189,23 @@ func (s *Service) updateApp(ctx context.Context, clientid string, name, url, red
 }
 
 const (
-	imgSize      = 256
-	thumbSize    = 8
-	thumbQuality = 0
+	imgSize             = 256
+	thumbSize           = 8
+	thumbQuality        = 0
+	logoVariantSmall    = "sm"
+	logoVariantSmallLen = 64
 )
 
+var logoVariants = []image.Variant{
+	{
+		Name:      logoVariantSmall,
+		Width:     logoVariantSmallLen,
+		Height:    logoVariantSmallLen,
+		Fill:      true,
+		MediaType: image.MediaTypePng,
+	},
+}
+
 func (s *Service) updateLogo(ctx context.Context, clientid string, img image.Image) error {
 	m, err := s.apps.GetByID(ctx, clientid)
 	if err != nil {
@@ -217,6 +229,9 @@ func (s *Service) updateLogo(ctx context.Context, clientid string, img image.Ima
 	if err := s.logoImgDir.Put(ctx, m.ClientID, image.MediaTypePng, int64(imgpng.Len()), nil, imgpng); err != nil {
 		return kerrors.WithMsg(err, "Failed to save app logo")
 	}
+	if err := image.PutVariants(ctx, s.logoVariantDir, m.ClientID, img, logoVariants, nil); err != nil {
+		return kerrors.WithMsg(err, "Failed to save app logo variants")
+	}
 
 	m.Logo = thumb64
 	if err := s.apps.UpdateProps(ctx, m); err != nil {
@@ -242,6 +257,9 @@ func (s *Service) deleteApp(ctx context.Context, clientid string) error {
 			return kerrors.WithMsg(err, "Unable to delete app logo")
 		}
 	}
+	if err := image.DelVariants(ctx, s.logoVariantDir, clientid, logoVariants); err != nil {
+		return kerrors.WithMsg(err, "Unable to delete app logo variants")
+	}
 
 	if err := s.apps.Delete(ctx, m); err != nil {
 		return kerrors.WithMsg(err, "Failed to delete oauth app")
@@ -293,6 +311,43 @@ func (s *Service) getLogo(ctx context.Context, clientid string) (io.ReadCloser,
 	return obj, objinfo.ContentType, nil
 }
 
+func (s *Service) getLogoVariantDir(variant string) (objstore.Dir, error) {
+	if _, ok := image.FindVariant(logoVariants, variant); !ok {
+		return nil, governor.ErrWithRes(nil, http.StatusNotFound, "", "OAuth app logo variant not found")
+	}
+	return s.logoVariantDir.Subdir(variant), nil
+}
+
+func (s *Service) statLogoVariantImage(ctx context.Context, clientid string, variant string) (*objstore.ObjectInfo, error) {
+	d, err := s.getLogoVariantDir(variant)
+	if err != nil {
+		return nil, err
+	}
+	objinfo, err := d.Stat(ctx, clientid)
+	if err != nil {
+		if errors.Is(err, objstore.ErrNotFound) {
+			return nil, governor.ErrWithRes(err, http.StatusNotFound, "", "OAuth app logo not found")
+		}
+		return nil, kerrors.WithMsg(err, "Failed to get oauth app logo")
+	}
+	return objinfo, nil
+}
+
+func (s *Service) getLogoVariantImage(ctx context.Context, clientid string, variant string) (io.ReadCloser, string, error) {
+	d, err := s.getLogoVariantDir(variant)
+	if err != nil {
+		return nil, "", err
+	}
+	obj, objinfo, err := d.Get(ctx, clientid)
+	if err != nil {
+		if errors.Is(err, objstore.ErrNotFound) {
+			return nil, "", governor.ErrWithRes(err, http.StatusNotFound, "", "OAuth app logo not found")
+		}
+		return nil, "", kerrors.WithMsg(err, "Failed to get app logo")
+	}
+	return obj, objinfo.ContentType, nil
+}
+
 func (s *Service) clearCache(ctx context.Context, clientid string) {
 	if err := s.kvclient.Del(ctx, clientid); err != nil {
 		s.log.Err(ctx, kerrors.WithMsg(err, "Failed to clear oauth client from cache"))
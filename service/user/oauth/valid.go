@@ -16,6 +16,7 @@ const (
 	amountCap         = 255
 	lengthCapURL      = 512
 	lengthCapRedirect = 512
+	lengthCapVariant  = 31
 )
 
 func validhasUserid(userid string) error {
@@ -112,3 +113,13 @@ func validRedirect(rawurl string) error {
 	}
 	return nil
 }
+
+func validhasVariant(variant string) error {
+	if len(variant) == 0 {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Variant must be provided")
+	}
+	if len(variant) > lengthCapVariant {
+		return governor.ErrWithRes(nil, http.StatusBadRequest, "", "Variant must be shorter than 32 characters")
+	}
+	return nil
+}
@@ -9,6 +9,16 @@ func (r reqAppGet) valid() error {
 	return nil
 }
 
+func (r reqAppLogoVariant) valid() error {
+	if err := validhasClientID(r.ClientID); err != nil {
+		return err
+	}
+	if err := validhasVariant(r.Variant); err != nil {
+		return err
+	}
+	return nil
+}
+
 func (r reqGetAppGroup) valid() error {
 	if err := validoptUserid(r.CreatorID); err != nil {
 		return err